@@ -0,0 +1,206 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+//AccountAddressCommentPrefix 按账户创建的地址在钱包备注中记录账户和序号，格式为ow:<账户ID>:<序号>
+const AccountAddressCommentPrefix = "ow:"
+
+//AccountAddress 按openwallet账户和HD序号创建的地址
+type AccountAddress struct {
+	ID         string `storm:"id"` //<账户ID>/<序号>
+	AccountID  string `storm:"index"`
+	Index      uint64
+	Address    string `storm:"unique"`
+	CreateTime int64
+}
+
+func accountAddressID(accountID string, index uint64) string {
+	return fmt.Sprintf("%s/%d", accountID, index)
+}
+
+//accountAddressComment 地址备注中记录的账户和序号
+func accountAddressComment(accountID string, index uint64) string {
+	return fmt.Sprintf("%s%s:%d", AccountAddressCommentPrefix, accountID, index)
+}
+
+//parseAccountAddressComment 解析地址备注中的账户和序号
+func parseAccountAddressComment(comment string) (string, uint64, bool) {
+	if !strings.HasPrefix(comment, AccountAddressCommentPrefix) {
+		return "", 0, false
+	}
+	comment = strings.TrimPrefix(comment, AccountAddressCommentPrefix)
+	i := strings.LastIndex(comment, ":")
+	if i <= 0 {
+		return "", 0, false
+	}
+	index, err := strconv.ParseUint(comment[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return comment[:i], index, true
+}
+
+func checkAccountID(accountID string) error {
+	if len(accountID) == 0 {
+		return fmt.Errorf("account id is empty")
+	}
+	if strings.ContainsAny(accountID, " \t\r\n/") {
+		return fmt.Errorf("account id: %s contains invalid characters", accountID)
+	}
+	return nil
+}
+
+func (wm *WalletManager) openAccountAddressDB() (*storm.DB, error) {
+	return storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+}
+
+//CreateAddressForAccount 创建账户第index个永不过期的地址，相同的账户和序号总是返回同一个地址：
+//先查本地记录，本地没有时按钱包中地址的备注查找（本地数据库丢失或重建后），都没有时才创建
+func (wm *WalletManager) CreateAddressForAccount(accountID string, index uint64) (string, error) {
+
+	if err := checkAccountID(accountID); err != nil {
+		return "", err
+	}
+
+	wm.accountAddressMu.Lock()
+	defer wm.accountAddressMu.Unlock()
+
+	record, err := wm.getAccountAddress(accountID, index)
+	if err != nil {
+		return "", err
+	}
+	if record != nil {
+		return record.Address, nil
+	}
+
+	comment := accountAddressComment(accountID, index)
+	addrs, err := wm.walletClient.ListAddresses(context.Background(), true)
+	if err != nil {
+		return "", err
+	}
+	address := ""
+	for _, a := range addrs {
+		if a.Comment == comment && !a.Expired {
+			address = a.Address
+			break
+		}
+	}
+
+	if len(address) == 0 {
+		address, err = wm.CreateAddress(AddressTypeRegular, AddressExpirationNever, comment)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return address, wm.saveAccountAddress(accountID, index, address)
+}
+
+func (wm *WalletManager) getAccountAddress(accountID string, index uint64) (*AccountAddress, error) {
+
+	db, err := wm.openAccountAddressDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var record AccountAddress
+	err = db.One("ID", accountAddressID(accountID, index), &record)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (wm *WalletManager) saveAccountAddress(accountID string, index uint64, address string) error {
+
+	db, err := wm.openAccountAddressDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Save(&AccountAddress{
+		ID:         accountAddressID(accountID, index),
+		AccountID:  accountID,
+		Index:      index,
+		Address:    address,
+		CreateTime: time.Now().Unix(),
+	})
+}
+
+//AccountAddresses 列出账户按序号创建的地址，按序号排序
+func (wm *WalletManager) AccountAddresses(accountID string) ([]*AccountAddress, error) {
+
+	db, err := wm.openAccountAddressDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*AccountAddress
+	err = db.Find("AccountID", accountID, &list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Index < list[j].Index
+	})
+	return list, nil
+}
+
+//RestoreAccountAddresses 按钱包中地址的备注重建本地的账户地址记录，返回新登记的数量
+func (wm *WalletManager) RestoreAccountAddresses() (int, error) {
+
+	addrs, err := wm.walletClient.ListAddresses(context.Background(), true)
+	if err != nil {
+		return 0, err
+	}
+
+	wm.accountAddressMu.Lock()
+	defer wm.accountAddressMu.Unlock()
+
+	restored := 0
+	for _, a := range addrs {
+		accountID, index, ok := parseAccountAddressComment(a.Comment)
+		if !ok || a.Expired || checkAccountID(accountID) != nil {
+			continue
+		}
+		record, err := wm.getAccountAddress(accountID, index)
+		if err != nil {
+			return restored, err
+		}
+		if record != nil {
+			continue
+		}
+		if err = wm.saveAccountAddress(accountID, index, a.Address); err != nil {
+			return restored, err
+		}
+		wm.addressBook.addCreated(a.Type, a.Comment, a.Address)
+		restored++
+	}
+	return restored, nil
+}
+
+//CreateRemoteAddressForAccount 客户端通过服务端创建账户第index个地址
+func (wm *WalletManager) CreateRemoteAddressForAccount(accountID string, index uint64) (string, error) {
+	if wm.Config.enableserver {
+		return "", fmt.Errorf("server mode can not create remote address, use CreateAddressForAccount")
+	}
+
+	return wm.client.CreateAddressForAccount(accountID, index)
+}
@@ -0,0 +1,93 @@
+package beam
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseAccountAddressComment(t *testing.T) {
+	tests := []struct {
+		comment   string
+		accountID string
+		index     uint64
+		ok        bool
+	}{
+		{accountAddressComment("acc1", 3), "acc1", 3, true},
+		{"ow:a:b:12", "a:b", 12, true},
+		{"ow:acc1:", "", 0, false},
+		{"ow::1", "", 0, false},
+		{"owacc1:1", "", 0, false},
+		{DefaultAddressComment, "", 0, false},
+	}
+	for _, test := range tests {
+		accountID, index, ok := parseAccountAddressComment(test.comment)
+		if accountID != test.accountID || index != test.index || ok != test.ok {
+			t.Errorf("parseAccountAddressComment(%s) = %s, %d, %v", test.comment, accountID, index, ok)
+		}
+	}
+}
+
+func TestCreateAddressForAccount(t *testing.T) {
+
+	var created []string
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list": rpcResult(`[
+			{"address":"restored1","type":"regular","comment":"ow:acc1:1","own":true},
+			{"address":"expired2","type":"regular","comment":"ow:acc1:2","own":true,"expired":true},
+			{"address":"other","type":"regular","comment":"self","own":true}
+		]`),
+		"create_address": func(params map[string]interface{}) (string, error) {
+			created = append(created, params["comment"].(string))
+			return fmt.Sprintf(`"new%d"`, len(created)), nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if _, err := wm.CreateAddressForAccount("", 0); err == nil {
+		t.Errorf("CreateAddressForAccount with empty account should fail")
+	}
+
+	//钱包中已有备注的地址直接使用
+	address, err := wm.CreateAddressForAccount("acc1", 1)
+	if err != nil || address != "restored1" || len(created) != 0 {
+		t.Errorf("CreateAddressForAccount = %s, err = %v, created = %v", address, err, created)
+	}
+
+	//过期的地址不再使用，重新创建
+	address, err = wm.CreateAddressForAccount("acc1", 2)
+	if err != nil || address != "new1" || len(created) != 1 || created[0] != "ow:acc1:2" {
+		t.Errorf("CreateAddressForAccount = %s, err = %v, created = %v", address, err, created)
+	}
+
+	//相同账户和序号返回同一个地址
+	address, _ = wm.CreateAddressForAccount("acc1", 2)
+	if address != "new1" || len(created) != 1 {
+		t.Errorf("repeated CreateAddressForAccount = %s, created = %v", address, created)
+	}
+	address, _ = wm.CreateAddressForAccount("acc2", 2)
+	if address != "new2" {
+		t.Errorf("CreateAddressForAccount acc2 = %s", address)
+	}
+
+	list, err := wm.AccountAddresses("acc1")
+	if err != nil || len(list) != 2 || list[0].Index != 1 || list[1].Address != "new1" {
+		t.Errorf("AccountAddresses = %v, err = %v", list, err)
+	}
+
+	//本地数据库重建后按钱包备注恢复
+	wm.Config.dbPath = t.TempDir()
+	n, err := wm.RestoreAccountAddresses()
+	if err != nil || n != 1 {
+		t.Errorf("RestoreAccountAddresses = %d, err = %v", n, err)
+	}
+	if entry, err := wm.AddressBook().Get("restored1"); err != nil || entry.Comment != "ow:acc1:1" {
+		t.Errorf("address book entry = %+v, err = %v", entry, err)
+	}
+	if n, _ = wm.RestoreAccountAddresses(); n != 0 {
+		t.Errorf("repeated RestoreAccountAddresses = %d, want 0", n)
+	}
+}
@@ -0,0 +1,57 @@
+package beam
+
+import (
+	"fmt"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//余额模型
+	BalanceModelAddress = "address" //按地址记录余额，充值地址订阅到各自的账户
+	BalanceModelAccount = "account" //整个beam钱包作为一个账户，按accountalias订阅
+)
+
+//isAccountModel 是否把整个beam钱包作为一个账户
+func (wm *WalletManager) isAccountModel() bool {
+	return wm.Config.balancemodel == BalanceModelAccount
+}
+
+//checkBalanceModel 校验余额模型配置，账户模型需要配置accountalias
+func (wm *WalletManager) checkBalanceModel() error {
+	switch wm.Config.balancemodel {
+	case BalanceModelAddress:
+	case BalanceModelAccount:
+		if len(wm.Config.accountalias) == 0 {
+			return fmt.Errorf("accountalias is required when balancemodel is %s", BalanceModelAccount)
+		}
+	default:
+		return fmt.Errorf("unknown balancemodel: %s", wm.Config.balancemodel)
+	}
+	return nil
+}
+
+//scanTarget 订阅地址查询的目标，账户模型下地址为账户别名
+func (wm *WalletManager) scanTarget(address string) openwallet.ScanTarget {
+	if wm.isAccountModel() {
+		return openwallet.ScanTarget{
+			Address:          address,
+			Alias:            address,
+			Symbol:           wm.Symbol(),
+			BalanceModelType: openwallet.BalanceModelTypeAccount,
+		}
+	}
+	return openwallet.ScanTarget{
+		Address:          address,
+		BalanceModelType: openwallet.BalanceModelTypeAddress,
+	}
+}
+
+//accountModelTx 账户模型下钱包一方的地址换成账户别名：收款交易换接收方，发送交易换发送方
+func (wm *WalletManager) accountModelTx(trx *Transaction) {
+	if trx.Income {
+		trx.Receiver = wm.Config.accountalias
+	} else {
+		trx.Sender = wm.Config.accountalias
+	}
+}
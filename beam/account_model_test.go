@@ -0,0 +1,72 @@
+package beam
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestCheckBalanceModel(t *testing.T) {
+	wm := NewWalletManager()
+	if err := wm.checkBalanceModel(); err != nil || wm.BalanceModelType() != openwallet.BalanceModelTypeAddress {
+		t.Errorf("default balance model = %v, err = %v", wm.BalanceModelType(), err)
+	}
+	wm.Config.balancemodel = BalanceModelAccount
+	if err := wm.checkBalanceModel(); err == nil {
+		t.Errorf("account model without accountalias should fail")
+	}
+	wm.Config.accountalias = "exchange"
+	if err := wm.checkBalanceModel(); err != nil || wm.BalanceModelType() != openwallet.BalanceModelTypeAccount {
+		t.Errorf("account balance model = %v, err = %v", wm.BalanceModelType(), err)
+	}
+	wm.Config.balancemodel = "utxo"
+	if err := wm.checkBalanceModel(); err == nil {
+		t.Errorf("unknown balance model should fail")
+	}
+}
+
+func TestExtractTransaction_AccountModel(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.balancemodel = BalanceModelAccount
+	wm.Config.accountalias = "exchange"
+	scanner := NewBEAMBlockScanner(wm)
+
+	var targets []openwallet.ScanTarget
+	extract := func(tx string) ExtractResult {
+		result := gjson.Parse(tx)
+		return scanner.ExtractTransaction(context.Background(), 10, "h10", NewTransaction(&result), func(target openwallet.ScanTarget) (string, bool) {
+			targets = append(targets, target)
+			return "account1", target.Address == "exchange"
+		})
+	}
+
+	//收款：接收方记为账户别名，只查询账户别名
+	result := extract(`{"txId":"t1","income":true,"sender":"payer","receiver":"deposit1","value":100000000,"fee":100}`)
+	list := result.extractData["account1"]
+	if len(list) != 1 || len(list[0].TxOutputs) != 1 || len(list[0].TxInputs) != 0 {
+		t.Errorf("income extract = %v", result.extractData)
+		return
+	}
+	if list[0].TxOutputs[0].Recharge.Address != "exchange" || list[0].Transaction.From[0] != "payer:1" {
+		t.Errorf("income output = %+v, from = %v", list[0].TxOutputs[0].Recharge, list[0].Transaction.From)
+	}
+	if len(targets) != 1 || targets[0].BalanceModelType != openwallet.BalanceModelTypeAccount || targets[0].Alias != "exchange" {
+		t.Errorf("scan targets = %+v", targets)
+	}
+
+	//发送：发送方记为账户别名，手续费计入输入
+	targets = nil
+	result = extract(`{"txId":"t2","income":false,"sender":"hot","receiver":"user","value":50000000,"fee":100}`)
+	list = result.extractData["account1"]
+	if len(list) != 1 || len(list[0].TxInputs) != 2 || len(list[0].TxOutputs) != 0 {
+		t.Errorf("outgoing extract = %v", result.extractData)
+		return
+	}
+	if list[0].TxInputs[0].Recharge.Address != "exchange" || list[0].Transaction.To[0] != "user:0.5" || len(targets) != 1 {
+		t.Errorf("outgoing input = %+v, to = %v, targets = %+v", list[0].TxInputs[0].Recharge, list[0].Transaction.To, targets)
+	}
+}
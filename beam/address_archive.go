@@ -0,0 +1,186 @@
+package beam
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//LateDepositEvent 已归档的充值地址仍然收到付款
+type LateDepositEvent struct {
+	Address     string `json:"address"`
+	Label       string `json:"label"` //地址簿中的标签
+	TxID        string `json:"txid"`
+	Amount      string `json:"amount"`
+	AssetID     int64  `json:"assetId"`
+	BlockHeight uint64 `json:"blockHeight"`
+	ArchiveTime int64  `json:"archiveTime"`
+	Time        int64  `json:"time"`
+}
+
+//LateDepositHandler 归档地址收到付款的告警处理，如通知运维联系用户
+type LateDepositHandler func(event *LateDepositEvent)
+
+//AddLateDepositHandler 添加归档地址收到付款的告警处理，需要在启动扫块前添加
+func (wm *WalletManager) AddLateDepositHandler(handler LateDepositHandler) {
+	wm.lateDepositHandlers = append(wm.lateDepositHandlers, handler)
+}
+
+//Archive 归档充值地址：地址池不再分配，收到的余额不汇总，扫块仍然提取，收到付款时发出告警
+func (b *AddressBook) Archive(address string) error {
+	return b.setArchived(address, true)
+}
+
+//Unarchive 取消归档，地址池中未分配的地址恢复分配
+func (b *AddressBook) Unarchive(address string) error {
+	return b.setArchived(address, false)
+}
+
+func (b *AddressBook) setArchived(address string, archived bool) error {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	db, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var entry AddressBookEntry
+	if err = db.One("Address", address, &entry); err == storm.ErrNotFound {
+		return fmt.Errorf("address: %s not found in address book", address)
+	} else if err != nil {
+		return err
+	}
+	if entry.Purpose != AddressPurposeDeposit {
+		return fmt.Errorf("address: %s is not a deposit address", address)
+	}
+	if entry.Archived == archived {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	entry.Archived = archived
+	entry.ArchiveTime = 0
+	if archived {
+		entry.ArchiveTime = now
+	}
+	entry.UpdateTime = now
+	if err = db.Save(&entry); err != nil {
+		return err
+	}
+
+	if archived {
+		b.wm.Log.Infof("archive deposit address: %s", address)
+	} else {
+		b.wm.Log.Infof("unarchive deposit address: %s", address)
+	}
+	return nil
+}
+
+//archived 已归档的地址
+func (b *AddressBook) archived() (map[string]*AddressBookEntry, error) {
+
+	list, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	archived := make(map[string]*AddressBookEntry)
+	for _, entry := range list {
+		if entry.Archived {
+			archived[entry.Address] = entry
+		}
+	}
+	return archived, nil
+}
+
+//archivedBalance 归档地址收到的仍未花费的BEAM，按本地账本中归档地址的入账交易匹配UTXO的创建交易，
+//beam钱包的地址共用同一个UTXO池，汇总时从可汇总余额中扣除
+func (wm *WalletManager) archivedBalance() (uint64, error) {
+
+	archived, err := wm.addressBook.archived()
+	if err != nil {
+		return 0, err
+	}
+	if len(archived) == 0 {
+		return 0, nil
+	}
+
+	db, err := wm.addressBook.open()
+	if err != nil {
+		return 0, err
+	}
+	txids := make(map[string]bool)
+	for address := range archived {
+		var records []*LedgerRecord
+		err = db.Find("Address", address, &records)
+		if err != nil && err != storm.ErrNotFound {
+			db.Close()
+			return 0, err
+		}
+		for _, r := range records {
+			if r.Direction == LedgerDirectionIn && r.AssetID == BeamAssetID {
+				txids[r.TxID] = true
+			}
+		}
+	}
+	db.Close()
+	if len(txids) == 0 {
+		return 0, nil
+	}
+
+	coins, err := wm.spendableCoins()
+	if err != nil {
+		return 0, err
+	}
+	balance := uint64(0)
+	for _, coin := range coins {
+		if txids[coin.CreateTxID] {
+			balance += coin.Amount
+		}
+	}
+	return balance, nil
+}
+
+//summaryBalance 可汇总的余额，扣除归档地址收到的余额，查询失败时不汇总
+func (wm *WalletManager) summaryBalance(available uint64) (uint64, error) {
+
+	archived, err := wm.archivedBalance()
+	if err != nil {
+		return 0, fmt.Errorf("load balance of archived addresses failed, unexpected error: %v", err)
+	}
+	if archived >= available {
+		return 0, nil
+	}
+	return available - archived, nil
+}
+
+//checkLateDeposit 接收方为已归档的充值地址时，交易扩展参数附带to_archived并发出告警，查询失败不影响提取
+func (wm *WalletManager) checkLateDeposit(transx *openwallet.Transaction, tx *Transaction) {
+
+	entry, err := wm.addressBook.Get(tx.Receiver)
+	if err != nil || !entry.Archived {
+		return
+	}
+
+	transx.SetExtParam("to_archived", true)
+
+	event := &LateDepositEvent{
+		Address:     entry.Address,
+		Label:       entry.Label,
+		TxID:        tx.TxID,
+		Amount:      transx.Amount,
+		AssetID:     tx.AssetID,
+		BlockHeight: tx.BlockHeight,
+		ArchiveTime: entry.ArchiveTime,
+		Time:        time.Now().Unix(),
+	}
+	wm.Log.Warningf("archived address: %s received tx: %s, amount: %s", event.Address, event.TxID, event.Amount)
+
+	for _, handler := range wm.lateDepositHandlers {
+		handler(event)
+	}
+}
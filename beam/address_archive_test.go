@@ -0,0 +1,91 @@
+package beam
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestAddressArchive(t *testing.T) {
+
+	server := newRPCTestServer(rpcTestMethods{
+		"wallet_status": rpcResult(`{"current_height":100,"available":1000}`),
+		"get_utxo": rpcResult(`[
+			{"id":"u1","asset_id":0,"amount":300,"maturity":10,"createTxId":"old-deposit","status":1},
+			{"id":"u2","asset_id":0,"amount":700,"maturity":10,"createTxId":"active-deposit","status":1}
+		]`),
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	book := wm.AddressBook()
+
+	book.add(AddressTypeRegular, "", AddressPurposeDeposit, "old", "active", "pool1", "pool2")
+	book.add(AddressTypeRegular, "", AddressPurposeSend, "sender")
+	if err := book.Archive("sender"); err == nil {
+		t.Errorf("archive send address should fail")
+	}
+	if err := book.Archive("unknown"); err == nil {
+		t.Errorf("archive unknown address should fail")
+	}
+	if err := book.Archive("old"); err != nil {
+		t.Fatalf("Archive unexpected error: %v", err)
+	}
+	if entry, _ := book.Get("old"); !entry.Archived || entry.ArchiveTime == 0 {
+		t.Errorf("archived entry = %+v", entry)
+	}
+
+	//地址池不分配已归档的地址
+	pool := wm.AddressPool()
+	pool.add("pool1")
+	pool.add("pool2")
+	book.Archive("pool1")
+	if address, err := pool.AllocateAddress(""); err != nil || address != "pool2" {
+		t.Errorf("AllocateAddress = %s, err = %v, want pool2", address, err)
+	}
+	if available, _ := pool.Available(); available != 0 {
+		t.Errorf("Available = %d, want 0", available)
+	}
+	book.Unarchive("pool1")
+	if available, _ := pool.Available(); available != 1 {
+		t.Errorf("Available after unarchive = %d, want 1", available)
+	}
+
+	//归档地址收到的余额不汇总
+	wm.SaveLedgerRecords(NewLedgerRecord("old-deposit", "old", LedgerDirectionIn), NewLedgerRecord("active-deposit", "active", LedgerDirectionIn))
+	if balance, err := wm.summaryBalance(1000); err != nil || balance != 700 {
+		t.Errorf("summaryBalance = %d, err = %v, want 700", balance, err)
+	}
+
+	//归档地址仍然提取，附带to_archived并告警
+	var events []*LateDepositEvent
+	wm.AddLateDepositHandler(func(event *LateDepositEvent) {
+		events = append(events, event)
+	})
+	scanner := NewBEAMBlockScanner(wm)
+	extract := func(tx string) *openwallet.Transaction {
+		result := gjson.Parse(tx)
+		extracted := scanner.ExtractTransaction(context.Background(), 10, "h10", NewTransaction(&result), func(target openwallet.ScanTarget) (string, bool) {
+			return "user", true
+		})
+		if list := extracted.extractData["user"]; len(list) == 1 {
+			return list[0].Transaction
+		}
+		return nil
+	}
+	transx := extract(`{"txId":"late","income":true,"sender":"payer","receiver":"old","value":100,"fee":100}`)
+	if transx == nil || !transx.GetExtParam().Get("to_archived").Bool() {
+		t.Errorf("late deposit extract = %+v", transx)
+	}
+	if len(events) != 1 || events[0].Address != "old" || events[0].TxID != "late" || events[0].BlockHeight != 10 {
+		t.Errorf("late deposit events = %+v", events)
+	}
+	transx = extract(`{"txId":"normal","income":true,"sender":"payer","receiver":"active","value":100,"fee":100}`)
+	if transx == nil || transx.GetExtParam().Get("to_archived").Exists() || len(events) != 1 {
+		t.Errorf("active deposit extract = %+v, events = %d", transx, len(events))
+	}
+}
@@ -0,0 +1,361 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//地址用途
+	AddressPurposeDeposit = "deposit" //充值地址，通过适配器创建的地址，不能作为提现的发送地址
+	AddressPurposeSend    = "send"    //发送地址，作为提现的发送地址使用过
+)
+
+//AddressBookEntry 地址簿记录，通过适配器创建的地址自动登记，标签由运维设置，如用户ID
+type AddressBookEntry struct {
+	Address     string `storm:"id"`
+	Type        string //地址类型
+	Label       string `storm:"index"` //标签，如地址所属的用户
+	Comment     string //创建地址时钱包中的备注
+	Purpose     string //地址用途，为空表示未分类（在适配器外创建或登记地址簿之前创建的地址）
+	Archived    bool   //已归档的充值地址，不再分配，收到的余额不汇总，扫块仍然提取
+	ArchiveTime int64
+	CreateTime  int64
+	UpdateTime  int64
+}
+
+//AddressBook 地址簿，记录在本地数据库
+type AddressBook struct {
+	wm *WalletManager
+	mu sync.Mutex
+}
+
+func newAddressBook(wm *WalletManager) *AddressBook {
+	return &AddressBook{wm: wm}
+}
+
+//AddressBook 地址簿
+func (wm *WalletManager) AddressBook() *AddressBook {
+	return wm.addressBook
+}
+
+func (b *AddressBook) open() (*storm.DB, error) {
+	return storm.Open(filepath.Join(b.wm.Config.dbPath, b.wm.Config.BlockchainFile))
+}
+
+//Add 登记地址，已登记的地址保留原来的标签
+func (b *AddressBook) Add(addressType, comment string, addresses ...string) error {
+	return b.add(addressType, comment, "", addresses...)
+}
+
+//add 登记地址，已登记的地址保留原来的标签和用途
+func (b *AddressBook) add(addressType, comment, purpose string, addresses ...string) error {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	db, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, address := range addresses {
+		var entry AddressBookEntry
+		if findErr := tx.One("Address", address, &entry); findErr == nil {
+			continue
+		}
+		entry = AddressBookEntry{
+			Address:    address,
+			Type:       addressType,
+			Comment:    comment,
+			Purpose:    purpose,
+			CreateTime: now,
+			UpdateTime: now,
+		}
+		if err = tx.Save(&entry); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//addCreated 登记新创建的充值地址，登记失败不影响地址创建，记录日志
+func (b *AddressBook) addCreated(addressType, comment string, addresses ...string) {
+	if err := b.add(addressType, comment, AddressPurposeDeposit, addresses...); err != nil {
+		b.wm.Log.Errorf("add %d addresses to address book failed, unexpected error: %v", len(addresses), err)
+	}
+}
+
+//SetLabel 设置地址的标签，地址没有登记时需要是钱包自己的地址
+func (b *AddressBook) SetLabel(address, label string) error {
+
+	entry, err := b.Get(address)
+	if err != nil {
+		v, validateErr := b.wm.walletClient.GetAddressValidation(context.Background(), address)
+		if validateErr != nil {
+			return validateErr
+		}
+		if !v.IsValid || !v.IsMine {
+			return fmt.Errorf("address: %s does not belong to the wallet", address)
+		}
+		entry = &AddressBookEntry{Address: address, Type: v.Type, CreateTime: time.Now().Unix()}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	db, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	entry.Label = label
+	entry.UpdateTime = time.Now().Unix()
+	return db.Save(entry)
+}
+
+//SetPurpose 设置地址的用途，purpose为deposit、send或空，地址没有登记时登记为普通地址
+func (b *AddressBook) SetPurpose(address, purpose string) error {
+
+	switch purpose {
+	case AddressPurposeDeposit, AddressPurposeSend, "":
+	default:
+		return fmt.Errorf("unknown address purpose: %s", purpose)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	db, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	now := time.Now().Unix()
+	var entry AddressBookEntry
+	if err = db.One("Address", address, &entry); err == storm.ErrNotFound {
+		entry = AddressBookEntry{Address: address, Type: AddressTypeRegular, CreateTime: now}
+	} else if err != nil {
+		return err
+	}
+	entry.Purpose = purpose
+	entry.UpdateTime = now
+	return db.Save(&entry)
+}
+
+//purposes 已分类地址的用途
+func (b *AddressBook) purposes() (map[string]string, error) {
+
+	list, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	purposes := make(map[string]string)
+	for _, entry := range list {
+		if len(entry.Purpose) > 0 {
+			purposes[entry.Address] = entry.Purpose
+		}
+	}
+	return purposes, nil
+}
+
+//Get 查询地址的登记记录
+func (b *AddressBook) Get(address string) (*AddressBookEntry, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	db, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var entry AddressBookEntry
+	if err = db.One("Address", address, &entry); err != nil {
+		return nil, fmt.Errorf("address: %s not found in address book", address)
+	}
+	return &entry, nil
+}
+
+//List 列出全部登记的地址，按创建时间排序
+func (b *AddressBook) List() ([]*AddressBookEntry, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	db, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*AddressBookEntry
+	err = db.All(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreateTime < list[j].CreateTime
+	})
+	return list, nil
+}
+
+//Search 按地址、标签或备注搜索，不区分大小写
+func (b *AddressBook) Search(query string) ([]*AddressBookEntry, error) {
+
+	list, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	result := make([]*AddressBookEntry, 0)
+	for _, entry := range list {
+		if strings.Contains(strings.ToLower(entry.Address), query) ||
+			strings.Contains(strings.ToLower(entry.Label), query) ||
+			strings.Contains(strings.ToLower(entry.Comment), query) {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+//Sync 把钱包中自己的地址登记到地址簿，用于登记地址簿之前或在适配器外创建的地址，返回新登记的数量
+func (b *AddressBook) Sync() (int, error) {
+
+	addrs, err := b.wm.walletClient.ListAddresses(context.Background(), true)
+	if err != nil {
+		return 0, err
+	}
+
+	list, err := b.List()
+	if err != nil {
+		return 0, err
+	}
+	exist := make(map[string]bool)
+	for _, entry := range list {
+		exist[entry.Address] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	db, err := b.open()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	added := 0
+	now := time.Now().Unix()
+	for _, a := range addrs {
+		if exist[a.Address] {
+			continue
+		}
+		entry := &AddressBookEntry{
+			Address:    a.Address,
+			Type:       a.Type,
+			Comment:    a.Comment,
+			CreateTime: a.CreateTime,
+			UpdateTime: now,
+		}
+		if err = tx.Save(entry); err != nil {
+			return 0, err
+		}
+		exist[a.Address] = true
+		added++
+	}
+
+	return added, tx.Commit()
+}
+
+//AddressMetadata 地址在本地登记的标签和所属账户，提取交易单时写入交易扩展参数
+type AddressMetadata struct {
+	Label        string
+	AccountID    string //按账户创建的地址所属的账户
+	AddressIndex uint64 //按账户创建的地址的序号
+}
+
+//addressMetadata 查询地址簿的标签和按账户创建的地址记录，都没有时返回nil
+func (wm *WalletManager) addressMetadata(address string) (*AddressMetadata, error) {
+
+	db, err := wm.addressBook.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var (
+		meta    AddressMetadata
+		found   bool
+		entry   AddressBookEntry
+		account AccountAddress
+	)
+	err = db.One("Address", address, &entry)
+	if err == nil {
+		meta.Label = entry.Label
+		found = len(entry.Label) > 0
+	} else if err != storm.ErrNotFound {
+		return nil, err
+	}
+	err = db.One("Address", address, &account)
+	if err == nil {
+		meta.AccountID = account.AccountID
+		meta.AddressIndex = account.Index
+		found = true
+	} else if err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return &meta, nil
+}
+
+//setAddressMetadata 把地址的标签和所属账户写入交易扩展参数，prefix为from或to，查询失败不影响提取，记录日志
+func (wm *WalletManager) setAddressMetadata(tx *openwallet.Transaction, prefix, address string) {
+
+	meta, err := wm.addressMetadata(address)
+	if err != nil {
+		wm.Log.Warningf("load metadata of address: %s failed, unexpected error: %v", address, err)
+		return
+	}
+	if meta == nil {
+		return
+	}
+	if len(meta.Label) > 0 {
+		tx.SetExtParam(prefix+"_label", meta.Label)
+	}
+	if len(meta.AccountID) > 0 {
+		tx.SetExtParam(prefix+"_account_id", meta.AccountID)
+		tx.SetExtParam(prefix+"_address_index", meta.AddressIndex)
+	}
+}
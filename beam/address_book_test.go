@@ -0,0 +1,117 @@
+package beam
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestAddressBook(t *testing.T) {
+
+	server := newRPCTestServer(rpcTestMethods{
+		"create_address": rpcResult(`"deposit1"`),
+		"addr_list": rpcResult(`[{"address":"deposit1","own":true,"comment":"self","type":"regular","create_time":100},` +
+			`{"address":"legacy","own":true,"comment":"old","type":"regular","create_time":50}]`),
+		"validate_address": func(params map[string]interface{}) (string, error) {
+			if params["address"] == "mine" {
+				return `{"is_valid":true,"is_mine":true,"type":"regular"}`, nil
+			}
+			return `{"is_valid":true,"is_mine":false}`, nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	book := wm.AddressBook()
+
+	//通过适配器创建的地址自动登记
+	address, err := wm.CreateAddress("", "", "")
+	if err != nil {
+		t.Fatalf("CreateAddress unexpected error: %v", err)
+	}
+	entry, err := book.Get(address)
+	if err != nil || entry.Type != AddressTypeRegular || entry.Comment != DefaultAddressComment {
+		t.Errorf("address book entry = %+v, err = %v", entry, err)
+	}
+
+	if err := book.SetLabel(address, "user-1001"); err != nil {
+		t.Errorf("SetLabel unexpected error: %v", err)
+	}
+	//重复登记保留标签
+	book.Add(AddressTypeRegular, "", address)
+	if entry, _ = book.Get(address); entry.Label != "user-1001" {
+		t.Errorf("label = %s, want user-1001", entry.Label)
+	}
+
+	//没有登记的地址需要是钱包自己的地址
+	if err := book.SetLabel("other", "user-1002"); err == nil {
+		t.Errorf("SetLabel should fail for address not owned by the wallet")
+	}
+	if err := book.SetLabel("mine", "user-1002"); err != nil {
+		t.Errorf("SetLabel unexpected error: %v", err)
+	}
+
+	added, err := book.Sync()
+	if err != nil || added != 1 {
+		t.Errorf("Sync = %d, err = %v", added, err)
+	}
+
+	list, err := book.List()
+	if err != nil || len(list) != 3 || list[0].Address != "legacy" {
+		t.Errorf("List = %d, err = %v", len(list), err)
+	}
+
+	result, err := book.Search("USER-1001")
+	if err != nil || len(result) != 1 || result[0].Address != address {
+		t.Errorf("Search label = %v, err = %v", result, err)
+	}
+	if result, _ = book.Search("old"); len(result) != 1 || result[0].Address != "legacy" {
+		t.Errorf("Search comment = %v", result)
+	}
+}
+
+func TestBEAMBlockScanner_ExtractAddressMetadata(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.AddressBook().Add("regular", "", "deposit1", "hot")
+	wm.AddressBook().SetLabel("deposit1", "user-1001")
+	wm.saveAccountAddress("acc1", 7, "deposit1")
+	scanner := NewBEAMBlockScanner(wm)
+
+	extract := func(tx string) *openwallet.Transaction {
+		result := gjson.Parse(tx)
+		trx := NewTransaction(&result)
+		r := scanner.ExtractTransaction(context.Background(), 10, "h10", trx, func(target openwallet.ScanTarget) (string, bool) {
+			return "acc1", target.Address == "deposit1" || target.Address == "hot"
+		})
+		for _, list := range r.extractData {
+			return list[0].Transaction
+		}
+		return nil
+	}
+
+	tx := extract(`{"txId":"t1","income":true,"sender":"external","receiver":"deposit1","value":100,"fee":100}`)
+	if tx == nil {
+		t.Fatalf("deposit is not extracted")
+	}
+	ext := tx.GetExtParam()
+	if ext.Get("to_label").String() != "user-1001" || ext.Get("to_account_id").String() != "acc1" ||
+		ext.Get("to_address_index").Uint() != 7 || ext.Get("from_label").Exists() {
+		t.Errorf("deposit ext param = %s", tx.ExtParam)
+	}
+
+	//没有标签的地址不写入
+	tx = extract(`{"txId":"t2","income":false,"sender":"hot","receiver":"external","value":100,"fee":100}`)
+	if tx == nil {
+		t.Fatalf("withdrawal is not extracted")
+	}
+	ext = tx.GetExtParam()
+	if ext.Get("from_label").Exists() || ext.Get("from_account_id").Exists() || ext.Get("to_label").Exists() {
+		t.Errorf("withdrawal ext param = %s", tx.ExtParam)
+	}
+}
@@ -0,0 +1,152 @@
+package beam
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/mr-tron/base58"
+)
+
+const (
+	//SBBS钱包ID：4字节通道号加32字节公钥，十六进制去掉开头的0
+	WalletIDChannelSize = 4
+	WalletIDPubKeySize  = 32
+	WalletIDSize        = WalletIDChannelSize + WalletIDPubKeySize
+
+	//旧版SBBS地址十六进制的最小长度，公钥开头最多两个字节为0
+	MinWalletIDHexLength = 2 * (WalletIDPubKeySize - 2)
+
+	//新版地址（token）为base58编码的交易参数，离线地址带付款凭证，长度较长
+	MinAddressTokenSize   = WalletIDPubKeySize
+	MaxAddressTokenLength = 4096
+)
+
+//AddressDecoder 地址解析器，只校验地址格式，地址是否可用需要钱包API的validate_address确认
+type AddressDecoder struct {
+	openwallet.AddressDecoderV2Base
+	wm *WalletManager
+}
+
+//NewAddressDecoder 地址解析器
+func NewAddressDecoder(wm *WalletManager) *AddressDecoder {
+	decoder := AddressDecoder{}
+	decoder.wm = wm
+	return &decoder
+}
+
+//isWalletIDHex 是否为旧版SBBS地址：十六进制的钱包ID
+func isWalletIDHex(address string) bool {
+	if len(address) < MinWalletIDHexLength || len(address) > 2*WalletIDSize {
+		return false
+	}
+	_, err := decodeWalletID(address)
+	return err == nil
+}
+
+//decodeWalletID 解析十六进制的钱包ID，返回36字节
+func decodeWalletID(address string) ([]byte, error) {
+	if len(address) > 2*WalletIDSize {
+		return nil, fmt.Errorf("address: %s is too long", address)
+	}
+	padded := strings.Repeat("0", 2*WalletIDSize-len(address)) + address
+	id, err := hex.DecodeString(padded)
+	if err != nil {
+		return nil, fmt.Errorf("address: %s is not hex", address)
+	}
+	for _, b := range id[WalletIDChannelSize:] {
+		if b != 0 {
+			return id, nil
+		}
+	}
+	return nil, fmt.Errorf("address: %s public key is empty", address)
+}
+
+//decodeAddressToken 解析base58编码的新版地址
+func decodeAddressToken(address string) ([]byte, error) {
+	if len(address) > MaxAddressTokenLength {
+		return nil, fmt.Errorf("address: %s is too long", address)
+	}
+	//十六进制的钱包ID也可能是合法的base58字符串
+	if isWalletIDHex(address) {
+		return nil, fmt.Errorf("address: %s is a wallet id", address)
+	}
+	data, err := base58.Decode(address)
+	if err != nil {
+		return nil, fmt.Errorf("address: %s is not base58", address)
+	}
+	if len(data) < MinAddressTokenSize {
+		return nil, fmt.Errorf("address: %s is too short", address)
+	}
+	return data, nil
+}
+
+//AddressDecode 地址解析，旧版SBBS地址返回36字节的钱包ID，新版地址返回base58解码后的交易参数
+func (decoder *AddressDecoder) AddressDecode(addr string, opts ...interface{}) ([]byte, error) {
+	if isWalletIDHex(addr) {
+		return decodeWalletID(addr)
+	}
+	data, err := decodeAddressToken(addr)
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrAdressDecodeFailed, "%v", err)
+	}
+	return data, nil
+}
+
+//AddressEncode 把SBBS公钥编码为旧版SBBS地址，pub为32字节公钥或33字节压缩公钥，opts[0]可指定uint32通道号，默认为0
+func (decoder *AddressDecoder) AddressEncode(pub []byte, opts ...interface{}) (string, error) {
+
+	if len(pub) == WalletIDPubKeySize+1 {
+		pub = pub[1:]
+	}
+	if len(pub) != WalletIDPubKeySize {
+		return "", openwallet.Errorf(openwallet.ErrAdressEncodeFailed, "public key length: %d is invalid", len(pub))
+	}
+
+	var channel uint32
+	if len(opts) > 0 {
+		c, ok := opts[0].(uint32)
+		if !ok {
+			return "", openwallet.Errorf(openwallet.ErrAdressEncodeFailed, "channel should be uint32")
+		}
+		channel = c
+	}
+
+	id := make([]byte, 0, WalletIDSize)
+	id = append(id, byte(channel>>24), byte(channel>>16), byte(channel>>8), byte(channel))
+	id = append(id, pub...)
+	address := strings.TrimLeft(hex.EncodeToString(id), "0")
+	if !isWalletIDHex(address) {
+		return "", openwallet.Errorf(openwallet.ErrAdressEncodeFailed, "public key is invalid")
+	}
+	return address, nil
+}
+
+//PublicKeyToAddress 公钥转旧版SBBS地址，beam主网和测试网的地址格式相同
+func (decoder *AddressDecoder) PublicKeyToAddress(pub []byte, isTestnet bool) (string, error) {
+	return decoder.AddressEncode(pub)
+}
+
+//AddressVerify 地址格式校验：旧版SBBS地址或base58编码的新版地址（普通、离线、最大隐私、公开离线），
+//opts[0]可指定地址类型，离线、最大隐私和公开离线地址只能是新版地址
+func (decoder *AddressDecoder) AddressVerify(address string, opts ...interface{}) bool {
+
+	addressType := ""
+	if len(opts) > 0 {
+		addressType, _ = opts[0].(string)
+	}
+
+	switch addressType {
+	case "", AddressTypeRegular:
+		if isWalletIDHex(address) {
+			return true
+		}
+	case AddressTypeOffline, AddressTypeMaxPrivacy, AddressTypePublicOffline:
+	default:
+		return false
+	}
+
+	_, err := decodeAddressToken(address)
+	return err == nil
+}
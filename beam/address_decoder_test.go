@@ -0,0 +1,77 @@
+package beam
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/mr-tron/base58"
+)
+
+func TestAddressDecoder_AddressEncode(t *testing.T) {
+
+	decoder := NewAddressDecoder(NewWalletManager())
+	pub := bytes.Repeat([]byte{0xab}, WalletIDPubKeySize)
+
+	address, err := decoder.PublicKeyToAddress(pub, false)
+	if err != nil || address != "ab"+address[2:] || len(address) != 2*WalletIDPubKeySize {
+		t.Errorf("PublicKeyToAddress = %s, err = %v", address, err)
+	}
+
+	address, err = decoder.AddressEncode(append([]byte{0x02}, pub...), uint32(0x1f))
+	if err != nil || address != "1f"+hex.EncodeToString(pub) {
+		t.Errorf("AddressEncode with channel = %s, err = %v", address, err)
+	}
+	id, err := decoder.AddressDecode(address)
+	if err != nil || len(id) != WalletIDSize || id[3] != 0x1f || !bytes.Equal(id[WalletIDChannelSize:], pub) {
+		t.Errorf("AddressDecode = %x, err = %v", id, err)
+	}
+
+	if _, err := decoder.AddressEncode(pub[:20]); err == nil {
+		t.Errorf("AddressEncode should fail for short public key")
+	}
+	if _, err := decoder.AddressEncode(pub, "1"); err == nil {
+		t.Errorf("AddressEncode should fail for invalid channel")
+	}
+	if _, err := decoder.PrivateKeyToWIF(pub, false); err == nil {
+		t.Errorf("PrivateKeyToWIF should not be supported")
+	}
+}
+
+func TestAddressDecoder_AddressVerify(t *testing.T) {
+
+	decoder := NewAddressDecoder(NewWalletManager())
+	regular, _ := decoder.AddressEncode(bytes.Repeat([]byte{0x5c}, WalletIDPubKeySize))
+	token := base58.Encode(bytes.Repeat([]byte{0x7e}, 120))
+
+	tests := []struct {
+		address     string
+		addressType string
+		want        bool
+	}{
+		{regular, "", true},
+		{regular, AddressTypeRegular, true},
+		{regular, AddressTypeOffline, false},
+		{token, "", true},
+		{token, AddressTypeMaxPrivacy, true},
+		{token, "unknown", false},
+		{"", "", false},
+		{"to", "", false},
+		{regular + "00000000000", "", false},
+		{base58.Encode([]byte{1, 2, 3}), "", false},
+		{"0" + regular[1:] + "0l", "", false},
+	}
+	for _, test := range tests {
+		if got := decoder.AddressVerify(test.address, test.addressType); got != test.want {
+			t.Errorf("AddressVerify(%s, %s) = %v, want %v", test.address, test.addressType, got, test.want)
+		}
+	}
+
+	data, err := decoder.AddressDecode(token)
+	if err != nil || len(data) != 120 {
+		t.Errorf("AddressDecode token = %d, err = %v", len(data), err)
+	}
+	if _, err := decoder.AddressDecode("not an address"); err == nil {
+		t.Errorf("AddressDecode should fail for invalid address")
+	}
+}
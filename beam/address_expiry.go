@@ -0,0 +1,319 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/timer"
+)
+
+const (
+	//地址即将过期或已过期时的处理方式
+	AddressExpiryActionRenew   = "renew"   //修改为永不过期，失败时重新创建地址
+	AddressExpiryActionReissue = "reissue" //重新创建永不过期的地址替换
+	AddressExpiryActionNotify  = "notify"  //只通知
+
+	//地址过期检查的结果
+	AddressExpiryExpiring = "expiring" //即将过期或已过期，没有处理
+	AddressExpiryRenewed  = "renewed"  //已修改为永不过期
+	AddressExpiryReissued = "reissued" //已创建替换地址
+	AddressExpiryFailed   = "failed"   //续期和替换都失败
+)
+
+//ExpireTime 地址的过期时间，0表示永不过期
+func (a *WalletAddress) ExpireTime() int64 {
+	if a.Duration == 0 {
+		return 0
+	}
+	return a.CreateTime + a.Duration
+}
+
+//EditAddress 修改自己的地址，expiration为never、24h、auto或expired，comment为空时不修改备注
+func (c *WalletClient) EditAddress(ctx context.Context, address, comment, expiration string) error {
+
+	request := map[string]interface{}{
+		"address":    address,
+		"expiration": expiration,
+	}
+	if len(comment) > 0 {
+		request["comment"] = comment
+	}
+
+	_, err := c.callContext(ctx, "edit_address", request)
+	return err
+}
+
+//AddressRemap 过期地址的替换地址，付款方仍使用旧地址时需要提示更换
+type AddressRemap struct {
+	OldAddress string `storm:"id"`
+	NewAddress string `storm:"index"`
+	Label      string
+	Time       int64
+}
+
+//AddressExpiryEvent 地址即将过期或已过期的处理结果
+type AddressExpiryEvent struct {
+	Address    string `json:"address"`
+	TxID       string `json:"txid,omitempty"`       //收款失败的交易单，由收款失败触发替换时有值
+	NewAddress string `json:"newAddress,omitempty"` //替换地址
+	Label      string `json:"label"`                //地址簿中的标签
+	Action     string `json:"action"`
+	Expired    bool   `json:"expired"`
+	ExpireTime int64  `json:"expireTime"`
+	Error      string `json:"error,omitempty"`
+	Time       int64  `json:"time"`
+}
+
+//AddressExpiryHandler 地址过期的处理，替换地址时可通知业务系统更新用户的充值地址
+type AddressExpiryHandler func(event *AddressExpiryEvent)
+
+//AddAddressExpiryHandler 添加地址过期的处理，需要在启动地址过期检查任务前添加
+func (wm *WalletManager) AddAddressExpiryHandler(handler AddressExpiryHandler) {
+	wm.addressExpiryHandlers = append(wm.addressExpiryHandlers, handler)
+}
+
+func (wm *WalletManager) openAddressRemapDB() (*storm.DB, error) {
+	return storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+}
+
+//RemappedAddress 过期地址的替换地址
+func (wm *WalletManager) RemappedAddress(address string) (string, bool) {
+
+	db, err := wm.openAddressRemapDB()
+	if err != nil {
+		return "", false
+	}
+	defer db.Close()
+
+	var remap AddressRemap
+	if err = db.One("OldAddress", address, &remap); err != nil {
+		return "", false
+	}
+	return remap.NewAddress, true
+}
+
+//CheckAddressExpiry 检查钱包自己的地址，在addressexpirywarning内过期或已过期的地址按addressexpiryaction处理
+func (wm *WalletManager) CheckAddressExpiry() ([]*AddressExpiryEvent, error) {
+
+	addrs, err := wm.walletClient.ListAddresses(context.Background(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deadline := now.Add(wm.Config.addressexpirywarning).Unix()
+	events := make([]*AddressExpiryEvent, 0)
+
+	for _, a := range addrs {
+		expireTime := a.ExpireTime()
+		if expireTime == 0 && !a.Expired {
+			continue
+		}
+		if expireTime > deadline && !a.Expired {
+			continue
+		}
+		//已替换的过期地址不再处理
+		if _, ok := wm.RemappedAddress(a.Address); ok {
+			continue
+		}
+
+		event := &AddressExpiryEvent{
+			Address:    a.Address,
+			Expired:    a.Expired || (expireTime > 0 && expireTime <= now.Unix()),
+			ExpireTime: expireTime,
+			Time:       now.Unix(),
+		}
+		if entry, entryErr := wm.addressBook.Get(a.Address); entryErr == nil {
+			event.Label = entry.Label
+		}
+
+		if wm.Config.addressexpiryaction == AddressExpiryActionNotify {
+			//同一地址只通知一次
+			if wm.addressExpiryNotified[a.Address] == expireTime {
+				continue
+			}
+			wm.addressExpiryNotified[a.Address] = expireTime
+			event.Action = AddressExpiryExpiring
+		} else {
+			wm.handleAddressExpiry(a, event)
+		}
+
+		wm.notifyAddressExpiry(event)
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+//handleAddressExpiry 续期或替换地址
+func (wm *WalletManager) handleAddressExpiry(a *WalletAddress, event *AddressExpiryEvent) {
+
+	if wm.Config.addressexpiryaction == AddressExpiryActionRenew {
+		err := wm.walletClient.EditAddress(context.Background(), a.Address, "", AddressExpirationNever)
+		if err == nil {
+			event.Action = AddressExpiryRenewed
+			return
+		}
+		wm.Log.Warningf("renew address: %s failed, reissue a replacement, unexpected error: %v", a.Address, err)
+	}
+
+	newAddress, err := wm.reissueAddress(a, event.Label)
+	if err != nil {
+		event.Action = AddressExpiryFailed
+		event.Error = err.Error()
+		return
+	}
+	event.Action = AddressExpiryReissued
+	event.NewAddress = newAddress
+}
+
+//reissueAddress 创建相同类型的永不过期地址替换过期地址，地址簿标签复制到新地址
+func (wm *WalletManager) reissueAddress(a *WalletAddress, label string) (string, error) {
+
+	addressType := a.Type
+	if len(addressType) == 0 {
+		addressType = AddressTypeRegular
+	}
+
+	var (
+		newAddress string
+		err        error
+	)
+	if addressType == AddressTypeOffline {
+		newAddress, err = wm.CreateOfflineAddress(0, a.Comment)
+	} else {
+		newAddress, err = wm.CreateAddress(addressType, AddressExpirationNever, a.Comment)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if len(label) > 0 {
+		if labelErr := wm.addressBook.SetLabel(newAddress, label); labelErr != nil {
+			wm.Log.Warningf("label address: %s failed, unexpected error: %v", newAddress, labelErr)
+		}
+	}
+
+	db, err := wm.openAddressRemapDB()
+	if err != nil {
+		return newAddress, err
+	}
+	defer db.Close()
+
+	err = db.Save(&AddressRemap{OldAddress: a.Address, NewAddress: newAddress, Label: label, Time: time.Now().Unix()})
+	if err != nil {
+		return newAddress, fmt.Errorf("save address remap failed, unexpected error: %v", err)
+	}
+
+	//按账户创建的地址，账户的同一序号改为新地址
+	var account AccountAddress
+	if err = db.One("Address", a.Address, &account); err == nil {
+		account.Address = newAddress
+		account.CreateTime = time.Now().Unix()
+		if err = db.Save(&account); err != nil {
+			return newAddress, fmt.Errorf("save account address failed, unexpected error: %v", err)
+		}
+	}
+	return newAddress, nil
+}
+
+//isAddressExpiredFailure 交易单是否因为接收方地址过期而失败
+func isAddressExpiredFailure(tx *Transaction) bool {
+	reason := strings.ToLower(tx.FailureReason)
+	return tx.Status == TxStatusFailed && strings.Contains(reason, "address") && strings.Contains(reason, "expire")
+}
+
+//ReissueExpiredReceiveAddresses 检查失败的收款交易单，因为收款地址过期而失败时为同一账户创建替换地址并通知地址过期的处理，
+//已替换的地址不再处理
+func (wm *WalletManager) ReissueExpiredReceiveAddresses() ([]*AddressExpiryEvent, error) {
+
+	txs, err := wm.walletClient.GetTransactionsByStatus(context.Background(), TxStatusFailed)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		own    map[string]*WalletAddress
+		events = make([]*AddressExpiryEvent, 0)
+	)
+	for _, tx := range txs {
+		if !tx.Income || !isAddressExpiredFailure(tx) {
+			continue
+		}
+		if _, ok := wm.RemappedAddress(tx.Receiver); ok {
+			continue
+		}
+
+		//按需加载钱包自己的地址，替换地址使用相同的类型和备注
+		if own == nil {
+			addrs, listErr := wm.walletClient.ListAddresses(context.Background(), true)
+			if listErr != nil {
+				return events, listErr
+			}
+			own = make(map[string]*WalletAddress, len(addrs))
+			for _, a := range addrs {
+				own[a.Address] = a
+			}
+		}
+		a, ok := own[tx.Receiver]
+		if !ok {
+			continue
+		}
+
+		event := &AddressExpiryEvent{
+			Address:    a.Address,
+			TxID:       tx.TxID,
+			Expired:    true,
+			ExpireTime: a.ExpireTime(),
+			Time:       time.Now().Unix(),
+		}
+		if entry, entryErr := wm.addressBook.Get(a.Address); entryErr == nil {
+			event.Label = entry.Label
+		}
+		newAddress, reissueErr := wm.reissueAddress(a, event.Label)
+		if reissueErr != nil {
+			event.Action = AddressExpiryFailed
+			event.Error = reissueErr.Error()
+		} else {
+			event.Action = AddressExpiryReissued
+			event.NewAddress = newAddress
+		}
+
+		wm.notifyAddressExpiry(event)
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+//notifyAddressExpiry 记录日志并通知地址过期的处理
+func (wm *WalletManager) notifyAddressExpiry(event *AddressExpiryEvent) {
+
+	wm.Log.Warningf("address: %s expire time: %d, expired: %v, action: %s, new address: %s %s",
+		event.Address, event.ExpireTime, event.Expired, event.Action, event.NewAddress, event.Error)
+
+	for _, handler := range wm.addressExpiryHandlers {
+		handler(event)
+	}
+}
+
+//StartAddressExpiryWatcher 启动地址过期检查任务
+func (wm *WalletManager) StartAddressExpiryWatcher() {
+
+	if !wm.IsFeatureEnabled(FeatureAddressExpiry) || wm.addressExpiryWatcher != nil {
+		return
+	}
+
+	wm.Log.Infof("The timer for address expiry watcher start now. Execute by every %v seconds.", wm.Config.addressexpirycheckperiod.Seconds())
+
+	wm.addressExpiryWatcher = timer.NewTask(wm.Config.addressexpirycheckperiod, func() {
+		if _, err := wm.CheckAddressExpiry(); err != nil {
+			wm.Log.Errorf("check address expiry unexpected error: %v", err)
+		}
+	})
+	wm.addressExpiryWatcher.Start()
+}
@@ -0,0 +1,137 @@
+package beam
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCheckAddressExpiry(t *testing.T) {
+
+	now := time.Now().Unix()
+	edited := make(map[string]interface{})
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list": rpcResult(fmt.Sprintf(`[
+			{"address":"never","type":"regular","own":true,"create_time":%d,"duration":0,"expired":false},
+			{"address":"soon","type":"regular","own":true,"create_time":%d,"duration":3600,"expired":false},
+			{"address":"old","type":"regular","own":true,"create_time":%d,"duration":60,"expired":true},
+			{"address":"later","type":"regular","own":true,"create_time":%d,"duration":86400,"expired":false}
+		]`, now, now-3000, now-7200, now)),
+		"edit_address": func(params map[string]interface{}) (string, error) {
+			address := params["address"].(string)
+			edited[address] = params["expiration"]
+			if address == "old" {
+				return "", &rpcTestError{Code: -32603, Message: "address expired"}
+			}
+			return `"done"`, nil
+		},
+		"create_address":   rpcResult(`"renewed-old"`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":true,"type":"regular"}`),
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	wm.AddressBook().Add(AddressTypeRegular, "", "old")
+	wm.AddressBook().SetLabel("old", "user-1")
+
+	var notified []*AddressExpiryEvent
+	wm.AddAddressExpiryHandler(func(event *AddressExpiryEvent) {
+		notified = append(notified, event)
+	})
+
+	events, err := wm.CheckAddressExpiry()
+	if err != nil {
+		t.Errorf("CheckAddressExpiry unexpected error: %v", err)
+		return
+	}
+	if len(events) != 2 || len(notified) != 2 {
+		t.Errorf("events = %d, notified = %d, want 2", len(events), len(notified))
+		return
+	}
+
+	if events[0].Address != "soon" || events[0].Action != AddressExpiryRenewed || events[0].Expired {
+		t.Errorf("soon event = %+v", events[0])
+	}
+	if edited["soon"] != AddressExpirationNever {
+		t.Errorf("edit_address expiration = %v", edited["soon"])
+	}
+
+	if events[1].Address != "old" || events[1].Action != AddressExpiryReissued || events[1].NewAddress != "renewed-old" || !events[1].Expired {
+		t.Errorf("old event = %+v", events[1])
+	}
+	if address, ok := wm.RemappedAddress("old"); !ok || address != "renewed-old" {
+		t.Errorf("RemappedAddress = %s, %v", address, ok)
+	}
+	if entry, err := wm.AddressBook().Get("renewed-old"); err != nil || entry.Label != "user-1" {
+		t.Errorf("address book entry = %+v, err = %v", entry, err)
+	}
+
+	//只通知时同一地址不重复通知
+	wm.Config.addressexpiryaction = AddressExpiryActionNotify
+	events, _ = wm.CheckAddressExpiry()
+	if len(events) != 1 || events[0].Address != "soon" || events[0].Action != AddressExpiryExpiring {
+		t.Errorf("notify events = %+v", events)
+	}
+	if events, _ = wm.CheckAddressExpiry(); len(events) != 0 {
+		t.Errorf("repeated notify events = %d, want 0", len(events))
+	}
+}
+
+func TestReissueExpiredReceiveAddresses(t *testing.T) {
+
+	now := time.Now().Unix()
+	server := newRPCTestServer(rpcTestMethods{
+		"tx_list": rpcResult(`[
+			{"txId":"t1","income":true,"receiver":"expired1","status":4,"failure_reason":"Address expired"},
+			{"txId":"t2","income":true,"receiver":"expired1","status":4,"failure_reason":"Address expired"},
+			{"txId":"t3","income":true,"receiver":"other","status":4,"failure_reason":"Transaction expired"},
+			{"txId":"t4","income":false,"receiver":"external","status":4,"failure_reason":"Address expired"},
+			{"txId":"t5","income":true,"receiver":"unknown","status":4,"failure_reason":"Address expired"}
+		]`),
+		"addr_list": rpcResult(fmt.Sprintf(`[
+			{"address":"expired1","type":"regular","own":true,"comment":"ow:acc1:3","create_time":%d,"duration":60,"expired":true},
+			{"address":"other","type":"regular","own":true,"create_time":%d,"duration":0,"expired":false}
+		]`, now-7200, now)),
+		"create_address":   rpcResult(`"replacement1"`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":true,"type":"regular"}`),
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	wm.AddressBook().Add(AddressTypeRegular, "", "expired1")
+	wm.AddressBook().SetLabel("expired1", "user-1")
+	wm.saveAccountAddress("acc1", 3, "expired1")
+
+	var notified []*AddressExpiryEvent
+	wm.AddAddressExpiryHandler(func(event *AddressExpiryEvent) {
+		notified = append(notified, event)
+	})
+
+	events, err := wm.ReissueExpiredReceiveAddresses()
+	if err != nil {
+		t.Fatalf("ReissueExpiredReceiveAddresses unexpected error: %v", err)
+	}
+	//同一地址的多笔失败交易只替换一次
+	if len(events) != 1 || len(notified) != 1 {
+		t.Fatalf("events = %d, notified = %d, want 1", len(events), len(notified))
+	}
+	e := events[0]
+	if e.Address != "expired1" || e.TxID != "t1" || e.NewAddress != "replacement1" || e.Action != AddressExpiryReissued || e.Label != "user-1" {
+		t.Errorf("event = %+v", e)
+	}
+	if a, err := wm.getAccountAddress("acc1", 3); err != nil || a.Address != "replacement1" {
+		t.Errorf("account address = %+v, %v", a, err)
+	}
+	if entry, err := wm.AddressBook().Get("replacement1"); err != nil || entry.Label != "user-1" {
+		t.Errorf("replacement label = %+v, %v", entry, err)
+	}
+
+	//已替换的地址不再处理
+	if events, _ = wm.ReissueExpiredReceiveAddresses(); len(events) != 0 {
+		t.Errorf("events after reissue = %+v", events)
+	}
+}
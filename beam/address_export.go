@@ -0,0 +1,296 @@
+package beam
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//addressExportColumns 地址导出CSV的列名
+var addressExportColumns = []string{"address", "type", "label", "comment", "createtime", "expiretime", "purpose"}
+
+//ExportedAddress 导出的充值地址，用于在适配器实例之间迁移地址簿
+type ExportedAddress struct {
+	Address    string `json:"address"`
+	Type       string `json:"type"`
+	Label      string `json:"label"`
+	Comment    string `json:"comment"`
+	CreateTime int64  `json:"createTime"`
+	ExpireTime int64  `json:"expireTime"` //0表示永不过期
+	Purpose    string `json:"purpose"`    //地址用途，deposit或send
+}
+
+//AddressImportResult 地址导入结果
+type AddressImportResult struct {
+	Imported int //新登记的地址
+	Updated  int //补充了标签的地址
+	Skipped  int //已登记且没有变化的地址
+}
+
+//ExportAddresses 导出地址簿中的全部地址（CSV或JSON，按扩展名），钱包中能查到的地址带上过期时间，返回导出的数量
+func (wm *WalletManager) ExportAddresses(filePath string) (int, error) {
+
+	entries, err := wm.addressBook.List()
+	if err != nil {
+		return 0, err
+	}
+
+	expireTimes := make(map[string]int64)
+	addrs, err := wm.walletClient.ListAddresses(context.Background(), true)
+	if err != nil {
+		wm.Log.Warningf("list addresses for expire time failed, unexpected error: %v", err)
+	}
+	for _, a := range addrs {
+		expireTimes[a.Address] = a.ExpireTime()
+	}
+
+	list := make([]*ExportedAddress, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, &ExportedAddress{
+			Address:    entry.Address,
+			Type:       entry.Type,
+			Label:      entry.Label,
+			Comment:    entry.Comment,
+			CreateTime: entry.CreateTime,
+			ExpireTime: expireTimes[entry.Address],
+			Purpose:    entry.Purpose,
+		})
+	}
+
+	format := strings.ToLower(filepath.Ext(filePath))
+	if format != ".json" && format != ".csv" {
+		return 0, fmt.Errorf("unsupported address file format: %s", filePath)
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if format == ".json" {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(list)
+	} else {
+		err = writeAddressCSV(f, list)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	wm.Log.Infof("export %d addresses to %s", len(list), filePath)
+	return len(list), nil
+}
+
+//ImportAddresses 导入ExportAddresses导出的地址（CSV或JSON）到地址簿，已登记的地址只补充空的标签；
+//verify为true时通过钱包API确认地址属于当前钱包，备注为账户地址格式时同时恢复账户地址记录
+func (wm *WalletManager) ImportAddresses(filePath string, verify bool) (*AddressImportResult, error) {
+
+	var list []*ExportedAddress
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		err = json.NewDecoder(f).Decode(&list)
+	case ".csv":
+		list, err = readAddressCSV(f)
+	default:
+		return nil, fmt.Errorf("unsupported address file format: %s", filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//先校验全部记录，有错误时不导入
+	for i, a := range list {
+		a.Address = strings.TrimSpace(a.Address)
+		if !wm.Decoder.AddressVerify(a.Address) {
+			return nil, fmt.Errorf("record %d: address: %s is invalid", i+1, a.Address)
+		}
+		if !verify {
+			continue
+		}
+		v, err := wm.walletClient.GetAddressValidation(context.Background(), a.Address)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+		if !v.IsValid || !v.IsMine {
+			return nil, fmt.Errorf("record %d: address: %s does not belong to the wallet", i+1, a.Address)
+		}
+	}
+
+	result, err := wm.addressBook.importAddresses(list)
+	if err != nil {
+		return nil, err
+	}
+
+	wm.accountAddressMu.Lock()
+	defer wm.accountAddressMu.Unlock()
+
+	for _, a := range list {
+		accountID, index, ok := parseAccountAddressComment(a.Comment)
+		if !ok || checkAccountID(accountID) != nil {
+			continue
+		}
+		record, err := wm.getAccountAddress(accountID, index)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			if err = wm.saveAccountAddress(accountID, index, a.Address); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	wm.Log.Infof("import addresses: %d imported, %d updated, %d skipped", result.Imported, result.Updated, result.Skipped)
+	return result, nil
+}
+
+//importAddresses 登记导入的地址，保留导入的创建时间
+func (b *AddressBook) importAddresses(list []*ExportedAddress) (*AddressImportResult, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	db, err := b.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &AddressImportResult{}
+	now := time.Now().Unix()
+	for _, a := range list {
+		var entry AddressBookEntry
+		if findErr := tx.One("Address", a.Address, &entry); findErr == nil {
+			if len(entry.Label) > 0 || len(a.Label) == 0 {
+				result.Skipped++
+				continue
+			}
+			entry.Label = a.Label
+			entry.UpdateTime = now
+			result.Updated++
+		} else {
+			entry = AddressBookEntry{
+				Address:    a.Address,
+				Type:       a.Type,
+				Label:      a.Label,
+				Comment:    a.Comment,
+				Purpose:    a.Purpose,
+				CreateTime: a.CreateTime,
+				UpdateTime: now,
+			}
+			if entry.CreateTime == 0 {
+				entry.CreateTime = now
+			}
+			result.Imported++
+		}
+		if err = tx.Save(&entry); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, tx.Commit()
+}
+
+//writeAddressCSV 写入CSV，第一行为列名
+func writeAddressCSV(w io.Writer, list []*ExportedAddress) error {
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(addressExportColumns); err != nil {
+		return err
+	}
+	for _, a := range list {
+		row := []string{
+			a.Address,
+			a.Type,
+			a.Label,
+			a.Comment,
+			strconv.FormatInt(a.CreateTime, 10),
+			strconv.FormatInt(a.ExpireTime, 10),
+			a.Purpose,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+//readAddressCSV 读取CSV，第一行为列名，address列必须存在
+func readAddressCSV(r io.Reader) ([]*ExportedAddress, error) {
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("address csv file is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["address"]; !ok {
+		return nil, fmt.Errorf("address csv column address is missing")
+	}
+
+	get := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	list := make([]*ExportedAddress, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		a := &ExportedAddress{
+			Address: get(row, "address"),
+			Type:    get(row, "type"),
+			Label:   get(row, "label"),
+			Comment: get(row, "comment"),
+			Purpose: get(row, "purpose"),
+		}
+		if ts := get(row, "createtime"); len(ts) > 0 {
+			a.CreateTime, err = strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if ts := get(row, "expiretime"); len(ts) > 0 {
+			a.ExpireTime, err = strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+		list = append(list, a)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,96 @@
+package beam
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportImportAddresses(t *testing.T) {
+
+	addr1 := "3ab404a243fd09f827e8941e419e523a5b21e17c70563bfbc211dbe0e87ca95"
+	addr2 := "2b11e4cbbd3c8f2a2d4d7ad1b6c4f0aa5ff28b0fdc27d0ef9e7d2bd0c77bf12"
+	foreign := "19f0e5bcd1b7b6a8e0b9a2b2ac0f4da7be6a3c0ff5e12a8f4bd31c8e0d9a7c1"
+
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list": rpcResult(fmt.Sprintf(`[{"address":"%s","type":"regular","own":true,"create_time":1000,"duration":3600}]`, addr2)),
+		"validate_address": func(params map[string]interface{}) (string, error) {
+			return fmt.Sprintf(`{"is_valid":true,"is_mine":%v,"type":"regular"}`, params["address"] != foreign), nil
+		},
+	})
+	defer server.Close()
+
+	newManager := func() *WalletManager {
+		wm := NewWalletManager()
+		wm.Config.dbPath = t.TempDir()
+		wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+		return wm
+	}
+
+	src := newManager()
+	src.AddressBook().Add(AddressTypeRegular, accountAddressComment("acc1", 0), addr1)
+	src.AddressBook().Add(AddressTypeRegular, DefaultAddressComment, addr2)
+	src.AddressBook().SetLabel(addr1, "user, \"1\"")
+
+	for _, ext := range []string{".csv", ".json"} {
+		filePath := filepath.Join(t.TempDir(), "addresses"+ext)
+		n, err := src.ExportAddresses(filePath)
+		if err != nil || n != 2 {
+			t.Errorf("ExportAddresses %s = %d, err = %v", ext, n, err)
+			continue
+		}
+
+		dst := newManager()
+		dst.AddressBook().Add(AddressTypeRegular, DefaultAddressComment, addr2)
+		result, err := dst.ImportAddresses(filePath, true)
+		if err != nil || result.Imported != 1 || result.Skipped != 1 {
+			t.Errorf("ImportAddresses %s = %+v, err = %v", ext, result, err)
+			continue
+		}
+		entry, err := dst.AddressBook().Get(addr1)
+		if err != nil || entry.Label != "user, \"1\"" || entry.Comment != "ow:acc1:0" {
+			t.Errorf("imported entry %s = %+v, err = %v", ext, entry, err)
+		}
+		if list, _ := dst.AccountAddresses("acc1"); len(list) != 1 || list[0].Address != addr1 {
+			t.Errorf("imported account addresses %s = %v", ext, list)
+		}
+	}
+
+	//导出的过期时间
+	filePath := filepath.Join(t.TempDir(), "addresses.json")
+	src.ExportAddresses(filePath)
+	data, _ := ioutil.ReadFile(filePath)
+	var list []*ExportedAddress
+	json.Unmarshal(data, &list)
+	expireTimes := make(map[string]int64)
+	for _, a := range list {
+		expireTimes[a.Address] = a.ExpireTime
+	}
+	if len(list) != 2 || expireTimes[addr1] != 0 || expireTimes[addr2] != 4600 {
+		t.Errorf("exported addresses = %s", data)
+	}
+
+	//导入已登记但没有标签的地址时补充标签
+	dst := newManager()
+	dst.AddressBook().Add(AddressTypeRegular, DefaultAddressComment, addr1)
+	result, err := dst.ImportAddresses(filePath, false)
+	if err != nil || result.Updated != 1 || result.Imported != 1 {
+		t.Errorf("ImportAddresses update = %+v, err = %v", result, err)
+	}
+
+	//不属于钱包的地址，整个文件不导入
+	filePath = filepath.Join(t.TempDir(), "foreign.csv")
+	ioutil.WriteFile(filePath, []byte("address,label\n"+addr1+",a\n"+foreign+",b\n"), 0644)
+	dst = newManager()
+	if _, err = dst.ImportAddresses(filePath, true); err == nil {
+		t.Errorf("ImportAddresses foreign address should fail")
+	}
+	if entries, _ := dst.AddressBook().List(); len(entries) != 0 {
+		t.Errorf("address book after failed import = %d entries", len(entries))
+	}
+	if _, err = dst.ImportAddresses(filePath, false); err != nil {
+		t.Errorf("ImportAddresses without verify unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package beam
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+//parseAddressPatterns 解析逗号分隔的地址或通配符模式，模式支持*和?
+func parseAddressPatterns(value string) ([]string, error) {
+	patterns := make([]string, 0)
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if len(pattern) == 0 {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid address pattern: %s", pattern)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+//matchAddress 地址是否匹配其中一个地址或模式
+func matchAddress(patterns []string, address string) bool {
+	for _, pattern := range patterns {
+		if pattern == address {
+			return true
+		}
+		if ok, _ := path.Match(pattern, address); ok {
+			return true
+		}
+	}
+	return false
+}
+
+//isExtractDenied 交易单的发送方或接收方在extractdeny中时不提取
+func (wm *WalletManager) isExtractDenied(tx *Transaction) bool {
+	if len(wm.Config.extractdeny) == 0 {
+		return false
+	}
+	return matchAddress(wm.Config.extractdeny, tx.Sender) || matchAddress(wm.Config.extractdeny, tx.Receiver)
+}
+
+//isExtractAllowed 配置了extractallow时，只有匹配的地址才作为订阅地址提取
+func (wm *WalletManager) isExtractAllowed(address string) bool {
+	if len(wm.Config.extractallow) == 0 {
+		return true
+	}
+	return matchAddress(wm.Config.extractallow, address)
+}
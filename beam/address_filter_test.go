@@ -0,0 +1,79 @@
+package beam
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestParseAddressPatterns(t *testing.T) {
+
+	patterns, err := parseAddressPatterns(" a1, 3f*,,b? ")
+	if err != nil || len(patterns) != 3 {
+		t.Errorf("parseAddressPatterns = %v, err = %v", patterns, err)
+		return
+	}
+
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"a1", true},
+		{"a12", false},
+		{"3fabc", true},
+		{"b2", true},
+		{"b22", false},
+	}
+	for _, test := range tests {
+		if got := matchAddress(patterns, test.address); got != test.want {
+			t.Errorf("matchAddress(%s) = %v, want %v", test.address, got, test.want)
+		}
+	}
+
+	if _, err := parseAddressPatterns("[a"); err == nil {
+		t.Errorf("parseAddressPatterns should fail for invalid pattern")
+	}
+}
+
+func TestBEAMBlockScanner_ExtractAddressFilter(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.extractdeny = []string{"abuse*"}
+	scanner := NewBEAMBlockScanner(wm)
+
+	var queried []string
+	extract := func(tx string) ExtractResult {
+		result := gjson.Parse(tx)
+		trx := NewTransaction(&result)
+		return scanner.ExtractTransaction(context.Background(), 10, "h10", trx, func(target openwallet.ScanTarget) (string, bool) {
+			queried = append(queried, target.Address)
+			return "user", target.Address != "other"
+		})
+	}
+
+	//黑名单中的付款方，整笔交易不提取，也不查询订阅地址
+	result := extract(`{"txId":"t1","income":true,"sender":"abuser1","receiver":"deposit","value":100,"fee":100}`)
+	if !result.Success || len(result.extractData) != 0 || len(queried) != 0 {
+		t.Errorf("denied tx extract = %v, queried = %v", result.extractData, queried)
+	}
+
+	result = extract(`{"txId":"t2","income":true,"sender":"other","receiver":"deposit","value":100,"fee":100}`)
+	if len(result.extractData["user"]) != 1 {
+		t.Errorf("allowed tx extract = %v", result.extractData)
+	}
+
+	//白名单之外的地址不作为订阅地址
+	wm.Config.extractallow = []string{"hot*"}
+	queried = nil
+	result = extract(`{"txId":"t3","income":true,"sender":"other","receiver":"deposit","value":100,"fee":100}`)
+	if len(result.extractData) != 0 || len(queried) != 0 {
+		t.Errorf("not allowed tx extract = %v, queried = %v", result.extractData, queried)
+	}
+	result = extract(`{"txId":"t4","income":true,"sender":"other","receiver":"hot1","value":100,"fee":100}`)
+	if len(result.extractData["user"]) != 1 || len(queried) != 1 || queried[0] != "hot1" {
+		t.Errorf("allowed tx extract = %v, queried = %v", result.extractData, queried)
+	}
+}
@@ -0,0 +1,257 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+const (
+	//地址映射的来源
+	AddressMappingManual = "manual" //运维登记
+	AddressMappingWallet = "wallet" //从钱包addr_list同步的钱包ID
+)
+
+//AddressMapping 同一身份的另一种地址形式（别名）到充值地址（规范地址）的映射，
+//如付款方填写的SBBS钱包ID或另一个token，扫块时接收方和发送方都换成规范地址再匹配订阅地址
+type AddressMapping struct {
+	Alias      string `storm:"id"`
+	Canonical  string `storm:"index"`
+	Source     string
+	CreateTime int64
+}
+
+//AddressMapper 地址映射表，记录在本地数据库，加载后缓存在内存
+type AddressMapper struct {
+	wm    *WalletManager
+	mu    sync.RWMutex
+	cache map[string]string //别名 -> 规范地址，nil表示还没有加载
+}
+
+func newAddressMapper(wm *WalletManager) *AddressMapper {
+	return &AddressMapper{wm: wm}
+}
+
+//AddressMapper 地址映射表
+func (wm *WalletManager) AddressMapper() *AddressMapper {
+	return wm.addressMapper
+}
+
+func (m *AddressMapper) open() (*storm.DB, error) {
+	return storm.Open(filepath.Join(m.wm.Config.dbPath, m.wm.Config.BlockchainFile))
+}
+
+//canonicalizeAddress 地址规范化：去掉首尾空白，SBBS钱包ID转小写并去掉开头的0，token区分大小写保持不变
+func canonicalizeAddress(address string) string {
+	address = strings.TrimSpace(address)
+	lower := strings.ToLower(address)
+	if isWalletIDHex(lower) {
+		return strings.TrimLeft(lower, "0")
+	}
+	return address
+}
+
+//load 从数据库加载映射表，调用方需要持有写锁
+func (m *AddressMapper) load() error {
+
+	if m.cache != nil {
+		return nil
+	}
+
+	db, err := m.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var list []*AddressMapping
+	err = db.All(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return err
+	}
+
+	m.cache = make(map[string]string, len(list))
+	for _, mapping := range list {
+		m.cache[mapping.Alias] = mapping.Canonical
+	}
+	return nil
+}
+
+//Resolve 地址的规范形式，有映射时返回规范地址
+func (m *AddressMapper) Resolve(address string) string {
+
+	address = canonicalizeAddress(address)
+	if len(address) == 0 {
+		return address
+	}
+
+	m.mu.RLock()
+	loaded := m.cache != nil
+	canonical, ok := m.cache[address]
+	m.mu.RUnlock()
+
+	if !loaded {
+		m.mu.Lock()
+		err := m.load()
+		canonical, ok = m.cache[address]
+		m.mu.Unlock()
+		if err != nil {
+			m.wm.Log.Warningf("load address mappings failed, unexpected error: %v", err)
+			return address
+		}
+	}
+
+	if ok {
+		return canonical
+	}
+	return address
+}
+
+//Map 登记别名到规范地址的映射，规范地址本身是别名时映射到它的规范地址，不允许改变已有的映射
+func (m *AddressMapper) Map(alias, canonical string) error {
+	return m.save(alias, canonical, AddressMappingManual)
+}
+
+func (m *AddressMapper) save(alias, canonical, source string) error {
+
+	alias = canonicalizeAddress(alias)
+	canonical = canonicalizeAddress(canonical)
+	for _, address := range []string{alias, canonical} {
+		if !m.wm.Decoder.AddressVerify(address) {
+			return fmt.Errorf("address: %s is invalid", address)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.load(); err != nil {
+		return err
+	}
+
+	if target, ok := m.cache[canonical]; ok {
+		canonical = target
+	}
+	if alias == canonical {
+		return fmt.Errorf("address: %s can not be mapped to itself", alias)
+	}
+	if target, ok := m.cache[alias]; ok {
+		if target == canonical {
+			return nil
+		}
+		return fmt.Errorf("address: %s is already mapped to %s", alias, target)
+	}
+	//别名已经是其他地址的规范地址时，映射链会让扫块结果依赖登记顺序
+	for a, target := range m.cache {
+		if target == alias {
+			return fmt.Errorf("address: %s is the canonical address of %s", alias, a)
+		}
+	}
+
+	db, err := m.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Save(&AddressMapping{Alias: alias, Canonical: canonical, Source: source, CreateTime: time.Now().Unix()})
+	if err != nil {
+		return err
+	}
+	m.cache[alias] = canonical
+	return nil
+}
+
+//Unmap 删除别名的映射
+func (m *AddressMapper) Unmap(alias string) error {
+
+	alias = canonicalizeAddress(alias)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.load(); err != nil {
+		return err
+	}
+
+	db, err := m.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteStruct(&AddressMapping{Alias: alias})
+	if err != nil {
+		if err == storm.ErrNotFound {
+			return fmt.Errorf("address: %s is not mapped", alias)
+		}
+		return err
+	}
+	delete(m.cache, alias)
+	return nil
+}
+
+//List 列出全部映射，canonical不为空时只列出该规范地址的别名，按创建时间排序
+func (m *AddressMapper) List(canonical string) ([]*AddressMapping, error) {
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	db, err := m.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*AddressMapping
+	if len(canonical) > 0 {
+		err = db.Find("Canonical", canonicalizeAddress(canonical), &list)
+	} else {
+		err = db.All(&list)
+	}
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreateTime < list[j].CreateTime
+	})
+	return list, nil
+}
+
+//Sync 把钱包自己的地址对应的SBBS钱包ID登记为别名，返回新登记的数量；
+//多个地址对应同一个钱包ID时只映射到第一个地址
+func (m *AddressMapper) Sync() (int, error) {
+
+	addrs, err := m.wm.walletClient.ListAddresses(context.Background(), true)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, a := range addrs {
+		alias := canonicalizeAddress(a.WalletID)
+		canonical := canonicalizeAddress(a.Address)
+		if len(alias) == 0 || alias == canonical {
+			continue
+		}
+		m.mu.RLock()
+		_, exist := m.cache[alias]
+		m.mu.RUnlock()
+		if exist {
+			continue
+		}
+		if err = m.save(alias, canonical, AddressMappingWallet); err != nil {
+			m.wm.Log.Warningf("map wallet id: %s to address: %s failed, unexpected error: %v", alias, canonical, err)
+			continue
+		}
+		added++
+	}
+	return added, nil
+}
@@ -0,0 +1,95 @@
+package beam
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/mr-tron/base58"
+	"github.com/tidwall/gjson"
+)
+
+func TestAddressMapper(t *testing.T) {
+
+	walletID := "3ab404a243fd09f827e8941e419e523a5b21e17c70563bfbc211dbe0e87ca95"
+	token := base58.Encode(bytes.Repeat([]byte{0x7e}, 120))
+	other := base58.Encode(bytes.Repeat([]byte{0x5a}, 120))
+
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list": rpcResult(fmt.Sprintf(`[
+			{"address":"%s","wallet_id":"%s","type":"regular","own":true},
+			{"address":"%s","wallet_id":"%s","type":"regular","own":true},
+			{"address":"%s","wallet_id":"%s","type":"regular","own":true}
+		]`, token, walletID, other, walletID, walletID, walletID)),
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	mapper := wm.AddressMapper()
+
+	//钱包ID不区分大小写，开头的0可以省略
+	if address := mapper.Resolve(" 00" + strings.ToUpper(walletID)); address != walletID {
+		t.Errorf("Resolve wallet id = %s, want %s", address, walletID)
+	}
+
+	//同一个钱包ID只映射到第一个地址
+	n, err := mapper.Sync()
+	if err != nil || n != 1 {
+		t.Errorf("Sync = %d, err = %v", n, err)
+	}
+	if address := mapper.Resolve(strings.ToUpper(walletID)); address != token {
+		t.Errorf("Resolve mapped wallet id = %s, want %s", address, token)
+	}
+	if n, _ = mapper.Sync(); n != 0 {
+		t.Errorf("repeated Sync = %d, want 0", n)
+	}
+
+	if err = mapper.Map(walletID, other); err == nil {
+		t.Errorf("Map to another address should fail")
+	}
+	if err = mapper.Map(token, other); err == nil {
+		t.Errorf("Map a canonical address should fail")
+	}
+	if err = mapper.Map("not an address", token); err == nil {
+		t.Errorf("Map invalid address should fail")
+	}
+	//规范地址是别名时映射到它的规范地址
+	if err = mapper.Map(other, walletID); err != nil {
+		t.Errorf("Map unexpected error: %v", err)
+	}
+	list, err := mapper.List(token)
+	if err != nil || len(list) != 2 {
+		t.Errorf("List = %d, err = %v", len(list), err)
+	}
+
+	//重新加载后映射不变
+	wm.addressMapper = newAddressMapper(wm)
+	if address := wm.AddressMapper().Resolve(other); address != token {
+		t.Errorf("Resolve after reload = %s, want %s", address, token)
+	}
+
+	//扫块时付款方填写的钱包ID换成规范地址
+	scanner := NewBEAMBlockScanner(wm)
+	trx := gjson.Parse(fmt.Sprintf(`{"txId":"t1","income":true,"sender":"sender","receiver":"%s","value":100,"fee":100}`, walletID))
+	result := scanner.ExtractTransaction(context.Background(), 10, "h10", NewTransaction(&trx), func(target openwallet.ScanTarget) (string, bool) {
+		return "user", target.Address == token
+	})
+	if len(result.extractData["user"]) != 1 {
+		t.Errorf("extract mapped receiver = %v", result.extractData)
+	}
+
+	if err = wm.AddressMapper().Unmap(walletID); err != nil {
+		t.Errorf("Unmap unexpected error: %v", err)
+	}
+	if address := wm.AddressMapper().Resolve(walletID); address != walletID {
+		t.Errorf("Resolve after Unmap = %s, want %s", address, walletID)
+	}
+	if err = wm.AddressMapper().Unmap(walletID); err == nil {
+		t.Errorf("Unmap twice should fail")
+	}
+}
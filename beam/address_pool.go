@@ -0,0 +1,225 @@
+package beam
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/timer"
+)
+
+//PoolAddress 预先创建的永不过期充值地址
+type PoolAddress struct {
+	Address      string `storm:"id"`
+	Used         bool
+	Label        string //分配时指定的标签，如用户ID
+	CreateTime   int64
+	AllocateTime int64
+}
+
+//AddressPool 充值地址池，后台按低水位补充，用户注册时直接分配，避免同步创建地址的延迟
+type AddressPool struct {
+	wm        *WalletManager
+	mu        sync.Mutex
+	refilling bool
+	task      *timer.TaskTimer
+}
+
+func newAddressPool(wm *WalletManager) *AddressPool {
+	return &AddressPool{wm: wm}
+}
+
+//AddressPool 充值地址池
+func (wm *WalletManager) AddressPool() *AddressPool {
+	return wm.addressPool
+}
+
+func (p *AddressPool) open() (*storm.DB, error) {
+	return storm.Open(filepath.Join(p.wm.Config.dbPath, p.wm.Config.BlockchainFile))
+}
+
+//Available 地址池中未分配的地址数量
+func (p *AddressPool) Available() (int, error) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	list, err := p.unused()
+	if err != nil {
+		return 0, err
+	}
+	return len(list), nil
+}
+
+//unused 未分配且未归档的地址，按创建时间排序
+func (p *AddressPool) unused() ([]*PoolAddress, error) {
+
+	db, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*PoolAddress
+	//storm的索引不记录零值，未分配的地址需要按条件查询
+	err = db.Select(q.Eq("Used", false)).Find(&list)
+	db.Close()
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	//已归档的地址不再分配
+	archived, err := p.wm.addressBook.archived()
+	if err != nil {
+		return nil, err
+	}
+	unused := list[:0]
+	for _, a := range list {
+		if archived[a.Address] == nil {
+			unused = append(unused, a)
+		}
+	}
+	list = unused
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreateTime < list[j].CreateTime
+	})
+	return list, nil
+}
+
+//AllocateAddress 从地址池分配一个充值地址并标记为已使用，label不为空时同时设置地址簿标签；
+//地址池为空时同步创建地址，开启addresspool时分配后低于低水位在后台补充
+func (p *AddressPool) AllocateAddress(label string) (string, error) {
+
+	address, err := p.allocate(label)
+	if err != nil {
+		return "", err
+	}
+
+	if len(label) > 0 {
+		if labelErr := p.wm.addressBook.SetLabel(address, label); labelErr != nil {
+			p.wm.Log.Warningf("label address: %s failed, unexpected error: %v", address, labelErr)
+		}
+	}
+
+	if p.wm.IsFeatureEnabled(FeatureAddressPool) {
+		go p.Refill()
+	}
+	return address, nil
+}
+
+func (p *AddressPool) allocate(label string) (string, error) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	list, err := p.unused()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().Unix()
+	record := &PoolAddress{CreateTime: now}
+	if len(list) > 0 {
+		record = list[0]
+	} else {
+		p.wm.Log.Warningf("address pool is empty, create deposit address synchronously")
+		record.Address, err = p.wm.CreateAddress(AddressTypeRegular, AddressExpirationNever, DefaultAddressComment)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	record.Used = true
+	record.Label = label
+	record.AllocateTime = now
+
+	db, err := p.open()
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	if err = db.Save(record); err != nil {
+		return "", err
+	}
+	return record.Address, nil
+}
+
+//Refill 未分配的地址低于addresspoollowwater时创建地址补充到addresspoolsize，返回创建的数量
+func (p *AddressPool) Refill() (int, error) {
+
+	p.mu.Lock()
+	if p.refilling {
+		p.mu.Unlock()
+		return 0, nil
+	}
+	list, err := p.unused()
+	if err != nil {
+		p.mu.Unlock()
+		return 0, err
+	}
+	if len(list) >= p.wm.Config.addresspoollowwater || len(list) >= p.wm.Config.addresspoolsize {
+		p.mu.Unlock()
+		return 0, nil
+	}
+	p.refilling = true
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.refilling = false
+		p.mu.Unlock()
+	}()
+
+	need := p.wm.Config.addresspoolsize - len(list)
+	created := 0
+	for i := 0; i < need; i++ {
+		address, err := p.wm.CreateAddress(AddressTypeRegular, AddressExpirationNever, DefaultAddressComment)
+		if err != nil {
+			return created, fmt.Errorf("refill address pool failed after %d addresses, unexpected error: %v", created, err)
+		}
+		if err = p.add(address); err != nil {
+			return created, err
+		}
+		created++
+	}
+
+	p.wm.Log.Infof("address pool refilled %d addresses", created)
+	return created, nil
+}
+
+func (p *AddressPool) add(address string) error {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	db, err := p.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Save(&PoolAddress{Address: address, CreateTime: time.Now().Unix()})
+}
+
+//StartAddressPool 启动充值地址池的补充任务
+func (wm *WalletManager) StartAddressPool() {
+
+	if !wm.IsFeatureEnabled(FeatureAddressPool) || wm.addressPool.task != nil {
+		return
+	}
+
+	wm.Log.Infof("The timer for address pool refill start now. Execute by every %v seconds.", wm.Config.addresspoolrefillperiod.Seconds())
+
+	wm.addressPool.task = timer.NewTask(wm.Config.addresspoolrefillperiod, func() {
+		_, err := wm.addressPool.Refill()
+		if err != nil {
+			wm.Log.Errorf("address pool refill unexpected error: %v", err)
+		}
+	})
+	wm.addressPool.task.Start()
+}
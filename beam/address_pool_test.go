@@ -0,0 +1,68 @@
+package beam
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAddressPool(t *testing.T) {
+
+	var created int
+	server := newRPCTestServer(rpcTestMethods{
+		"create_address": func(params map[string]interface{}) (string, error) {
+			created++
+			if params["expiration"] != AddressExpirationNever {
+				t.Errorf("create_address expiration = %v", params["expiration"])
+			}
+			return fmt.Sprintf(`"pool%d"`, created), nil
+		},
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":true,"type":"regular"}`),
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.addresspoolsize = 5
+	wm.Config.addresspoollowwater = 2
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	pool := wm.AddressPool()
+
+	//地址池为空时同步创建
+	address, err := pool.AllocateAddress("user-1")
+	if err != nil || address != "pool1" {
+		t.Errorf("AllocateAddress = %s, err = %v", address, err)
+	}
+
+	n, err := pool.Refill()
+	if err != nil || n != 5 {
+		t.Errorf("Refill = %d, err = %v", n, err)
+	}
+	if available, _ := pool.Available(); available != 5 {
+		t.Errorf("Available = %d, want 5", available)
+	}
+
+	for i := 0; i < 3; i++ {
+		address, err = pool.AllocateAddress(fmt.Sprintf("user-%d", i+2))
+		if err != nil {
+			t.Errorf("AllocateAddress unexpected error: %v", err)
+		}
+	}
+	if created != 6 {
+		t.Errorf("create_address calls = %d, want 6", created)
+	}
+	if entry, err := wm.AddressBook().Get(address); err != nil || entry.Label != "user-4" {
+		t.Errorf("address book entry = %+v, err = %v", entry, err)
+	}
+
+	//高于低水位时不补充
+	if n, _ = pool.Refill(); n != 0 {
+		t.Errorf("Refill above low water = %d, want 0", n)
+	}
+	pool.AllocateAddress("")
+	if n, _ = pool.Refill(); n != 4 {
+		t.Errorf("Refill below low water = %d, want 4", n)
+	}
+	if available, _ := pool.Available(); available != 5 {
+		t.Errorf("Available = %d, want 5", available)
+	}
+}
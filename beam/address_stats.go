@@ -0,0 +1,238 @@
+package beam
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/shopspring/decimal"
+)
+
+//AddressStats 地址的使用统计，按地址和资产分别统计，随账本记录更新
+type AddressStats struct {
+	ID             string `storm:"id"` //<地址>_<资产ID>
+	Address        string `storm:"index"`
+	AssetID        int64
+	AccountID      string
+	FirstSeen      int64  //第一笔交易的时间
+	LastDeposit    int64  //最后一笔入账的时间，0表示没有入账
+	LastWithdraw   int64  //最后一笔出账的时间，0表示没有出账
+	TxCount        uint64 //交易单数量，同一交易单的入账和出账只计一次
+	DepositCount   uint64
+	DepositVolume  string //入账总额
+	WithdrawVolume string //出账总额，不含手续费
+}
+
+func addressStatsID(address string, assetID int64) string {
+	return fmt.Sprintf("%s_%d", address, assetID)
+}
+
+//LastActive 最后一笔交易的时间
+func (s *AddressStats) LastActive() int64 {
+	if s.LastDeposit > s.LastWithdraw {
+		return s.LastDeposit
+	}
+	return s.LastWithdraw
+}
+
+//updateAddressStats 在保存账本记录的事务中累计地址的使用统计，只对新保存的记录调用，
+//counted为true时同一交易单另一方向的记录已计数，不重复计入交易单数量
+func updateAddressStats(tx storm.Node, r *LedgerRecord, counted bool) error {
+
+	var stats AddressStats
+	err := tx.One("ID", addressStatsID(r.Address, r.AssetID), &stats)
+	if err == storm.ErrNotFound {
+		stats = AddressStats{
+			ID:             addressStatsID(r.Address, r.AssetID),
+			Address:        r.Address,
+			AssetID:        r.AssetID,
+			FirstSeen:      r.CreateTime,
+			DepositVolume:  "0",
+			WithdrawVolume: "0",
+		}
+	} else if err != nil {
+		return err
+	}
+
+	amount, err := decimal.NewFromString(r.Amount)
+	if err != nil {
+		amount = decimal.Zero
+	}
+	if len(r.AccountID) > 0 {
+		stats.AccountID = r.AccountID
+	}
+	if r.CreateTime > 0 && (stats.FirstSeen == 0 || r.CreateTime < stats.FirstSeen) {
+		stats.FirstSeen = r.CreateTime
+	}
+
+	if !counted {
+		stats.TxCount++
+	}
+
+	if r.Direction == LedgerDirectionIn {
+		volume, _ := decimal.NewFromString(stats.DepositVolume)
+		stats.DepositVolume = volume.Add(amount).String()
+		stats.DepositCount++
+		if r.CreateTime > stats.LastDeposit {
+			stats.LastDeposit = r.CreateTime
+		}
+	} else {
+		volume, _ := decimal.NewFromString(stats.WithdrawVolume)
+		stats.WithdrawVolume = volume.Add(amount).String()
+		if r.CreateTime > stats.LastWithdraw {
+			stats.LastWithdraw = r.CreateTime
+		}
+	}
+
+	return tx.Save(&stats)
+}
+
+func (wm *WalletManager) openAddressStatsDB() (*storm.DB, error) {
+	return storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+}
+
+//GetAddressStats 查询地址的使用统计，每种资产一条，地址没有交易时返回空
+func (wm *WalletManager) GetAddressStats(address string) ([]*AddressStats, error) {
+
+	db, err := wm.openAddressStatsDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*AddressStats
+	err = db.Find("Address", address, &list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+//ListAddressStats 列出全部地址的使用统计
+func (wm *WalletManager) ListAddressStats() ([]*AddressStats, error) {
+
+	db, err := wm.openAddressStatsDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*AddressStats
+	err = db.All(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+//DormantAddresses 超过idle没有交易的充值地址，地址簿中从未收到过交易的充值地址按登记时间计算，按最后交易时间排序
+func (wm *WalletManager) DormantAddresses(idle time.Duration) ([]*AddressStats, error) {
+
+	list, err := wm.ListAddressStats()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := wm.addressBook.List()
+	if err != nil {
+		return nil, err
+	}
+
+	lastActive := make(map[string]*AddressStats)
+	for _, s := range list {
+		if exist, ok := lastActive[s.Address]; !ok || s.LastActive() > exist.LastActive() {
+			lastActive[s.Address] = s
+		}
+	}
+
+	deadline := time.Now().Add(-idle).Unix()
+	dormant := make([]*AddressStats, 0)
+	for _, entry := range entries {
+		if entry.Purpose == AddressPurposeSend {
+			continue
+		}
+		s, ok := lastActive[entry.Address]
+		if !ok {
+			//没有交易的地址
+			if entry.CreateTime <= deadline {
+				dormant = append(dormant, &AddressStats{ID: addressStatsID(entry.Address, BeamAssetID), Address: entry.Address, DepositVolume: "0", WithdrawVolume: "0"})
+			}
+			continue
+		}
+		if s.LastActive() <= deadline {
+			dormant = append(dormant, s)
+		}
+	}
+
+	sort.SliceStable(dormant, func(i, j int) bool {
+		return dormant[i].LastActive() < dormant[j].LastActive()
+	})
+	return dormant, nil
+}
+
+//TopDepositors 资产入账总额最大的地址，limit为0时返回全部
+func (wm *WalletManager) TopDepositors(assetID int64, limit int) ([]*AddressStats, error) {
+
+	list, err := wm.ListAddressStats()
+	if err != nil {
+		return nil, err
+	}
+
+	top := make([]*AddressStats, 0)
+	volumes := make(map[string]decimal.Decimal)
+	for _, s := range list {
+		if s.AssetID != assetID || s.DepositCount == 0 {
+			continue
+		}
+		volumes[s.ID], _ = decimal.NewFromString(s.DepositVolume)
+		top = append(top, s)
+	}
+	sort.SliceStable(top, func(i, j int) bool {
+		return volumes[top[i].ID].GreaterThan(volumes[top[j].ID])
+	})
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
+	return top, nil
+}
+
+//RebuildAddressStats 按本地账本重新计算全部地址的使用统计，用于升级前已有的账本，返回统计的地址数量
+func (wm *WalletManager) RebuildAddressStats() (int, error) {
+
+	db, err := wm.openAddressStatsDB()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err = tx.Drop(&AddressStats{}); err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+
+	var records []*LedgerRecord
+	err = tx.All(&records)
+	if err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+
+	counted := make(map[string]bool)
+	addresses := make(map[string]bool)
+	for _, r := range records {
+		key := r.TxID + "_" + r.Address
+		if err = updateAddressStats(tx, r, counted[key]); err != nil {
+			return 0, err
+		}
+		counted[key] = true
+		addresses[r.Address] = true
+	}
+
+	return len(addresses), tx.Commit()
+}
+
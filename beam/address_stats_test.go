@@ -0,0 +1,85 @@
+package beam
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddressStats(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+
+	now := time.Now().Unix()
+	old := now - 100*86400
+	record := func(txid, address, direction, amount string, createTime int64, assetID int64) *LedgerRecord {
+		r := NewLedgerRecord(txid, address, direction)
+		r.Amount = amount
+		r.CreateTime = createTime
+		r.AssetID = assetID
+		r.AccountID = "account"
+		return r
+	}
+	records := []*LedgerRecord{
+		record("t1", "heavy", LedgerDirectionIn, "10", old, BeamAssetID),
+		record("t2", "heavy", LedgerDirectionIn, "5.5", now-60, BeamAssetID),
+		//转给自己，入账和出账只计一笔交易单
+		record("t3", "heavy", LedgerDirectionOut, "1", now, BeamAssetID),
+		record("t3", "heavy", LedgerDirectionIn, "1", now, BeamAssetID),
+		record("t4", "light", LedgerDirectionIn, "2", old, BeamAssetID),
+		record("t5", "light", LedgerDirectionIn, "100", old, 7),
+	}
+	if err := wm.SaveLedgerRecords(records...); err != nil {
+		t.Fatalf("SaveLedgerRecords unexpected error: %v", err)
+	}
+	//重复保存不重复统计
+	if err := wm.SaveLedgerRecords(records[0], records[3]); err != nil {
+		t.Fatalf("SaveLedgerRecords unexpected error: %v", err)
+	}
+
+	check := func(name string) {
+		list, err := wm.GetAddressStats("heavy")
+		if err != nil || len(list) != 1 {
+			t.Errorf("%s: GetAddressStats = %+v, err = %v", name, list, err)
+			return
+		}
+		s := list[0]
+		if s.TxCount != 3 || s.DepositCount != 3 || s.DepositVolume != "16.5" || s.WithdrawVolume != "1" ||
+			s.FirstSeen != old || s.LastDeposit != now || s.LastWithdraw != now || s.AccountID != "account" {
+			t.Errorf("%s: heavy stats = %+v", name, s)
+		}
+		if list, _ = wm.GetAddressStats("light"); len(list) != 2 {
+			t.Errorf("%s: light stats = %+v, want 2 assets", name, list)
+		}
+	}
+	check("save")
+
+	top, err := wm.TopDepositors(BeamAssetID, 0)
+	if err != nil || len(top) != 2 || top[0].Address != "heavy" || top[1].Address != "light" {
+		t.Errorf("TopDepositors = %+v, err = %v", top, err)
+	}
+	if top, _ = wm.TopDepositors(BeamAssetID, 1); len(top) != 1 {
+		t.Errorf("TopDepositors limit 1 = %d", len(top))
+	}
+	if top, _ = wm.TopDepositors(7, 0); len(top) != 1 || top[0].Address != "light" {
+		t.Errorf("TopDepositors asset 7 = %+v", top)
+	}
+
+	//地址簿中的发送地址不算休眠地址，从未收到交易的充值地址按登记时间计算
+	wm.addressBook.add(AddressTypeRegular, "", AddressPurposeDeposit, "heavy", "light", "unused")
+	wm.addressBook.add(AddressTypeRegular, "", AddressPurposeSend, "sender")
+	dormant, err := wm.DormantAddresses(30 * 24 * time.Hour)
+	if err != nil || len(dormant) != 1 || dormant[0].Address != "light" {
+		t.Errorf("DormantAddresses 30 days = %+v, err = %v", dormant, err)
+	}
+	dormant, _ = wm.DormantAddresses(0)
+	if len(dormant) != 3 || dormant[0].Address != "unused" {
+		t.Errorf("DormantAddresses 0 = %+v", dormant)
+	}
+
+	count, err := wm.RebuildAddressStats()
+	if err != nil || count != 2 {
+		t.Errorf("RebuildAddressStats = %d, err = %v", count, err)
+	}
+	check("rebuild")
+}
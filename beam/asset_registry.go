@@ -0,0 +1,248 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//AssetRegistry 机密资产登记表，资产信息通过钱包API查询后缓存在内存和本地数据库，
+//资产元数据发行后不会改变，需要更新时调用Refresh
+type AssetRegistry struct {
+	wm    *WalletManager
+	mu    sync.Mutex
+	infos map[int64]*AssetInfo
+}
+
+func newAssetRegistry(wm *WalletManager) *AssetRegistry {
+	return &AssetRegistry{
+		wm:    wm,
+		infos: make(map[int64]*AssetInfo),
+	}
+}
+
+//AssetRegistry 机密资产登记表
+func (wm *WalletManager) AssetRegistry() *AssetRegistry {
+	return wm.assetRegistry
+}
+
+func (r *AssetRegistry) openDB() (*storm.DB, error) {
+	return storm.Open(filepath.Join(r.wm.Config.dbPath, r.wm.Config.BlockchainFile))
+}
+
+//Get 查询资产信息，依次查找内存、本地数据库和钱包API
+func (r *AssetRegistry) Get(assetID int64) (*AssetInfo, error) {
+
+	r.mu.Lock()
+	info, ok := r.infos[assetID]
+	r.mu.Unlock()
+	if ok {
+		return r.withDecimals(info), nil
+	}
+
+	info, err := r.load(assetID)
+	if err != nil {
+		return r.Refresh(assetID)
+	}
+
+	r.mu.Lock()
+	r.infos[assetID] = info
+	r.mu.Unlock()
+	return r.withDecimals(info), nil
+}
+
+//Refresh 通过钱包API重新查询资产信息并更新缓存
+func (r *AssetRegistry) Refresh(assetID int64) (*AssetInfo, error) {
+
+	info, err := r.wm.walletClient.GetAssetInfo(context.Background(), assetID)
+	if err != nil {
+		return nil, err
+	}
+	info.UpdateTime = time.Now().Unix()
+
+	//本地数据库保存失败不影响使用，下次启动重新查询
+	if err := r.save(info); err != nil {
+		r.wm.Log.Warningf("save asset: %d info failed, unexpected error: %v", assetID, err)
+	}
+
+	r.mu.Lock()
+	r.infos[assetID] = info
+	r.mu.Unlock()
+	return r.withDecimals(info), nil
+}
+
+//List 列出本地登记的资产信息，按资产ID排序
+func (r *AssetRegistry) List() ([]*AssetInfo, error) {
+
+	db, err := r.openDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*AssetInfo
+	err = db.All(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	for i, info := range list {
+		list[i] = r.withDecimals(info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].AssetID < list[j].AssetID
+	})
+	return list, nil
+}
+
+//Decimals 资产数量的小数位数，按assetdecimals配置，没有配置时与BEAM相同，最小单位为groth
+func (r *AssetRegistry) Decimals(assetID int64) int32 {
+	if decimals, ok := r.wm.Config.assetdecimals[assetID]; ok {
+		return decimals
+	}
+	return r.wm.Decimal()
+}
+
+//withDecimals 复制资产信息并设置小数位数，配置修改后立即生效
+func (r *AssetRegistry) withDecimals(info *AssetInfo) *AssetInfo {
+	copied := *info
+	copied.Decimals = r.Decimals(info.AssetID)
+	return &copied
+}
+
+func (r *AssetRegistry) load(assetID int64) (*AssetInfo, error) {
+
+	db, err := r.openDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var info AssetInfo
+	err = db.One("AssetID", assetID, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (r *AssetRegistry) save(info *AssetInfo) error {
+
+	db, err := r.openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Save(info)
+}
+
+//assetDecimals 资产数量的小数位数，BEAM使用主币精度
+func (wm *WalletManager) assetDecimals(assetID int64) int32 {
+	if assetID == BeamAssetID {
+		return wm.Decimal()
+	}
+	return wm.assetRegistry.Decimals(assetID)
+}
+
+//GetAssetBalance 查询钱包的资产余额，可用余额为已成熟的可用UTXO，未确认余额为接收中的UTXO
+func (wm *WalletManager) GetAssetBalance(assetID int64) (*openwallet.Balance, error) {
+
+	status, err := wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	filter := UtxoFilter{AssetID: &assetID}
+	utxos, err := wm.walletClient.ListUTXO(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var available, receiving []*Utxo
+	for _, utxo := range utxos {
+		switch {
+		case utxo.Status == UtxoStatusAvailable && utxo.Maturity <= status.CurrentHeight:
+			available = append(available, utxo)
+		case utxo.Status == UtxoStatusIncoming:
+			receiving = append(receiving, utxo)
+		}
+	}
+
+	decimals := wm.assetDecimals(assetID)
+	confirmBalance := common.IntToDecimals(int64(sumCoins(available)), decimals)
+	unconfirmedBalance := common.IntToDecimals(int64(sumCoins(receiving)), decimals)
+
+	return &openwallet.Balance{
+		Symbol:           wm.Symbol(),
+		Balance:          confirmBalance.Add(unconfirmedBalance).String(),
+		ConfirmBalance:   confirmBalance.String(),
+		UnconfirmBalance: unconfirmedBalance.String(),
+	}, nil
+}
+
+//ContractDecoder 机密资产余额查询，钱包不区分地址，返回钱包的资产余额
+type ContractDecoder struct {
+	openwallet.SmartContractDecoderBase
+	wm *WalletManager
+}
+
+//NewContractDecoder 机密资产解析器
+func NewContractDecoder(wm *WalletManager) *ContractDecoder {
+	decoder := ContractDecoder{}
+	decoder.wm = wm
+	return &decoder
+}
+
+//GetTokenBalanceByAddress 查询资产余额，合约地址为资产ID
+func (decoder *ContractDecoder) GetTokenBalanceByAddress(contract openwallet.SmartContract, address ...string) ([]*openwallet.TokenBalance, error) {
+
+	assetID, err := ParseAssetID(openwallet.Coin{IsContract: true, Contract: contract})
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := decoder.wm.GetAssetBalance(assetID)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.wm.isAccountModel() && len(address) > 0 {
+		balance.Address = address[0]
+	}
+
+	return []*openwallet.TokenBalance{{Contract: &contract, Balance: balance}}, nil
+}
+
+//parseAssetDecimals 解析资产小数位数配置，格式：1:6,7:2
+func parseAssetDecimals(s string) (map[int64]int32, error) {
+	decimals := make(map[int64]int32)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		kv := strings.SplitN(item, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid asset decimals: %s", item)
+		}
+		assetID, err := strconv.ParseInt(strings.TrimSpace(kv[0]), 10, 64)
+		if err != nil || assetID <= 0 {
+			return nil, fmt.Errorf("invalid asset id: %s", item)
+		}
+		d, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 32)
+		if err != nil || d < 0 || d > 18 {
+			return nil, fmt.Errorf("invalid asset decimals: %s", item)
+		}
+		decimals[assetID] = int32(d)
+	}
+	return decimals, nil
+}
@@ -0,0 +1,106 @@
+package beam
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestParseAssetDecimals(t *testing.T) {
+
+	decimals, err := parseAssetDecimals(" 1:6, 7:2 ")
+	if err != nil || len(decimals) != 2 || decimals[1] != 6 || decimals[7] != 2 {
+		t.Errorf("parseAssetDecimals = %v, %v", decimals, err)
+	}
+
+	for _, s := range []string{"1", "0:6", "a:6", "1:-1", "1:19"} {
+		if _, err := parseAssetDecimals(s); err == nil {
+			t.Errorf("parseAssetDecimals(%s) should fail", s)
+		}
+	}
+}
+
+func TestAssetRegistry_Get(t *testing.T) {
+
+	var (
+		sent      map[string]interface{}
+		infoCalls int
+	)
+	server := newAssetTestServer(&sent, &infoCalls)
+	defer server.Close()
+
+	dbPath := t.TempDir()
+	wm := NewWalletManager()
+	wm.Config.dbPath = dbPath
+	wm.Config.assetdecimals = map[int64]int32{7: 2}
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	info, err := wm.AssetRegistry().Get(7)
+	if err != nil || info.UnitName != "TST" || info.Decimals != 2 {
+		t.Errorf("Get = %+v, %v", info, err)
+	}
+
+	//重启后从本地数据库读取，不再查询钱包API
+	wm = NewWalletManager()
+	wm.Config.dbPath = dbPath
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	info, err = wm.AssetRegistry().Get(7)
+	if err != nil || info.Name != "Test Coin" || info.Decimals != wm.Decimal() || infoCalls != 1 {
+		t.Errorf("Get from db = %+v, %v, calls = %d", info, err, infoCalls)
+	}
+
+	list, err := wm.AssetRegistry().List()
+	if err != nil || len(list) != 1 || list[0].AssetID != 7 {
+		t.Errorf("List = %v, %v", list, err)
+	}
+
+	if _, err = wm.AssetRegistry().Get(8); err == nil {
+		t.Errorf("Get unknown asset should fail")
+	}
+}
+
+func TestAssetRegistry_ExtractAmount(t *testing.T) {
+
+	result := gjson.Parse(`{"txId":"t1","asset_id":7,"value":12345,"fee":100,"income":true}`)
+	tx := NewTransaction(&result)
+
+	wm := NewWalletManager()
+	wm.Config.assetdecimals = map[int64]int32{7: 2}
+	wm.assetRegistry.infos[7] = &AssetInfo{AssetID: 7, ShortName: "TST"}
+	scanner := NewBEAMBlockScanner(wm)
+
+	data := &openwallet.TxExtractData{}
+	scanner.extractTxInput(tx, data)
+	scanner.extractTxOutput(tx, data)
+	if data.TxOutputs[0].Amount != "123.45" || data.TxOutputs[0].Coin.Contract.Decimals != 2 {
+		t.Errorf("output = %+v", data.TxOutputs[0])
+	}
+	//手续费按BEAM的精度转换
+	if data.TxInputs[1].Amount != "0.000001" {
+		t.Errorf("fee input = %+v", data.TxInputs[1])
+	}
+}
+
+func TestContractDecoder_GetTokenBalanceByAddress(t *testing.T) {
+
+	var (
+		sent      map[string]interface{}
+		infoCalls int
+	)
+	server := newAssetTestServer(&sent, &infoCalls)
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.assetdecimals = map[int64]int32{7: 2}
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	balances, err := wm.GetSmartContractDecoder().GetTokenBalanceByAddress(openwallet.SmartContract{Address: "7"})
+	if err != nil || len(balances) != 1 {
+		t.Errorf("GetTokenBalanceByAddress = %v, %v", balances, err)
+		return
+	}
+	if b := balances[0].Balance; b.ConfirmBalance != "5" || b.Balance != "5" || b.UnconfirmBalance != "0" {
+		t.Errorf("balance = %+v", b)
+	}
+}
@@ -0,0 +1,234 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//BEAM的资产ID，其他资产为机密资产（Confidential Assets）
+	BeamAssetID = int64(0)
+
+	//机密资产的合约协议
+	AssetProtocol = "CA"
+)
+
+//AssetInfo 机密资产信息，名称等来自资产发行时的元数据
+type AssetInfo struct {
+	AssetID       int64  `storm:"id"`
+	Emission      uint64 //发行量，单位groth
+	IsOwned       bool   //资产由本钱包发行
+	LockHeight    uint64
+	RefreshHeight uint64
+	OwnerID       string
+	Metadata      string
+	Name          string //元数据N
+	ShortName     string //元数据SN
+	UnitName      string //元数据UN
+	Decimals      int32  //资产数量的小数位数，读取时按配置设置
+	UpdateTime    int64
+
+	/*
+		{
+		  "asset_id": 1,
+		  "emission": 100000000,
+		  "emission_str": "100000000",
+		  "isOwned": 1,
+		  "lockHeight": 14,
+		  "metadata": "STD:SCH_VER=1;N=Coin;SN=CN;UN=CN;NTHUN=GROTH",
+		  "metadata_pairs": {
+		    "N": "Coin",
+		    "NTHUN": "GROTH",
+		    "SCH_VER": "1",
+		    "SN": "CN",
+		    "UN": "CN"
+		  },
+		  "ownerId": "64e2dd05fb7bad1a3a8dd1cd0ae6d6a11da1d1d8c4bc1b74a7e5e4cce4e81e2b",
+		  "refreshHeight": 16
+		}
+	*/
+}
+
+func NewAssetInfo(result *gjson.Result) *AssetInfo {
+	obj := AssetInfo{}
+	obj.AssetID = result.Get("asset_id").Int()
+	obj.Emission = result.Get("emission").Uint()
+	obj.IsOwned = result.Get("isOwned").Bool()
+	obj.LockHeight = result.Get("lockHeight").Uint()
+	obj.RefreshHeight = result.Get("refreshHeight").Uint()
+	obj.OwnerID = result.Get("ownerId").String()
+	obj.Metadata = result.Get("metadata").String()
+	obj.Name = result.Get("metadata_pairs.N").String()
+	obj.ShortName = result.Get("metadata_pairs.SN").String()
+	obj.UnitName = result.Get("metadata_pairs.UN").String()
+	return &obj
+}
+
+//Token 资产的简称，元数据没有简称时使用资产ID
+func (a *AssetInfo) Token() string {
+	if len(a.ShortName) > 0 {
+		return a.ShortName
+	}
+	if len(a.UnitName) > 0 {
+		return a.UnitName
+	}
+	return fmt.Sprintf("%s%d", AssetProtocol, a.AssetID)
+}
+
+//GetAssetInfo 获取钱包已知的机密资产信息
+func (c *WalletClient) GetAssetInfo(ctx context.Context, assetID int64) (*AssetInfo, error) {
+
+	request := map[string]interface{}{
+		"asset_id": assetID,
+	}
+
+	r, err := c.callContext(ctx, "get_asset_info", request)
+	if err != nil {
+		return nil, err
+	}
+	return NewAssetInfo(r), nil
+}
+
+//SendAssetTransaction 发送机密资产交易，value为资产数量，手续费使用BEAM支付，assetID为0时发送BEAM
+func (c *WalletClient) SendAssetTransaction(ctx context.Context, from, to string, assetID int64, value, fee uint64, comment, txID string) (string, error) {
+	return c.sendTransaction(ctx, from, to, assetID, value, fee, comment, txID, nil)
+}
+
+//GetAssetInfo 获取机密资产信息，通过资产登记表缓存
+func (wm *WalletManager) GetAssetInfo(assetID int64) (*AssetInfo, error) {
+	return wm.assetRegistry.Get(assetID)
+}
+
+//AssetCoin 资产ID对应的openwallet币种，BEAM为主币，其他资产为合约代币，合约地址为资产ID
+func (wm *WalletManager) AssetCoin(assetID int64) openwallet.Coin {
+
+	if assetID == BeamAssetID {
+		return openwallet.Coin{
+			Symbol:     wm.Symbol(),
+			IsContract: false,
+		}
+	}
+
+	address := strconv.FormatInt(assetID, 10)
+	contract := openwallet.SmartContract{
+		ContractID: openwallet.GenContractID(wm.Symbol(), address),
+		Symbol:     wm.Symbol(),
+		Address:    address,
+		Token:      fmt.Sprintf("%s%d", AssetProtocol, assetID),
+		Protocol:   AssetProtocol,
+		Decimals:   uint64(wm.assetDecimals(assetID)),
+	}
+
+	//资产信息查询失败不影响扫块，使用资产ID作为简称
+	if info, err := wm.GetAssetInfo(assetID); err == nil {
+		contract.Token = info.Token()
+		contract.Name = info.Name
+	} else {
+		wm.Log.Warningf("get asset: %d info failed, unexpected error: %v", assetID, err)
+	}
+
+	return openwallet.Coin{
+		Symbol:     wm.Symbol(),
+		IsContract: true,
+		ContractID: contract.ContractID,
+		Contract:   contract,
+	}
+}
+
+//ParseAssetID 币种对应的资产ID，合约代币的合约地址为资产ID
+func ParseAssetID(coin openwallet.Coin) (int64, error) {
+
+	if !coin.IsContract {
+		return BeamAssetID, nil
+	}
+
+	assetID, err := strconv.ParseInt(strings.TrimPrefix(coin.Contract.Address, "0x"), 10, 64)
+	if err != nil || assetID <= 0 {
+		return 0, openwallet.Errorf(openwallet.ErrContractNotFound, "invalid asset id: %s", coin.Contract.Address)
+	}
+	return assetID, nil
+}
+
+//EstimateAssetFee 估算发送机密资产的手续费，资产和BEAM各按有找零计算
+func (wm *WalletManager) EstimateAssetFee(to string) (uint64, error) {
+
+	params := FeeParams{Kernels: 1, Outputs: 2}
+	v, err := wm.walletClient.GetAddressValidation(context.Background(), to)
+	if err != nil {
+		return 0, err
+	}
+	if isShieldedAddressType(v.Type) {
+		params.ShieldedOutputs++
+	} else {
+		params.Outputs++
+	}
+
+	return wm.applyFee(params.MinFee()), nil
+}
+
+//estimateWithdrawalFee 估算提现的手续费，机密资产按资产交易估算
+func (wm *WalletManager) estimateWithdrawalFee(assetID int64, amount uint64, to string) (uint64, error) {
+	if assetID != BeamAssetID {
+		return wm.EstimateAssetFee(to)
+	}
+	return wm.EstimateFee(amount, []string{to})
+}
+
+//assetWithdrawal 机密资产提现的发送数量和手续费，资产全额发送，手续费另外使用BEAM支付
+func (wm *WalletManager) assetWithdrawal(assetID int64, amount, fee uint64) (*WithdrawalAmount, error) {
+
+	if amount == 0 {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "amount must be greater than 0")
+	}
+
+	status, err := wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if status.Available < fee {
+		return nil, openwallet.Errorf(openwallet.ErrInsufficientFees, "wallet available balance is not enough to pay fee")
+	}
+
+	filter := SpendableFilter(status.CurrentHeight)
+	filter.AssetID = &assetID
+	utxos, err := wm.walletClient.ListUTXO(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if sumCoins(utxos) < amount {
+		return nil, openwallet.Errorf(openwallet.ErrInsufficientTokenBalanceOfAddress, "asset: %d available balance is not enough", assetID)
+	}
+
+	return &WithdrawalAmount{Send: amount, Fee: fee}, nil
+}
+
+//sendAssetWithdrawal 发送机密资产提现交易，受处理中提现数量限制，由钱包选择UTXO，override为true时跳过提现地址白名单
+func (wm *WalletManager) sendAssetWithdrawal(from, to string, assetID int64, withdrawal *WithdrawalAmount, comment, txID string, override bool) (txid string, err error) {
+
+	//提现策略检查
+	done, err := wm.withdrawalPolicy.Evaluate(&WithdrawalPolicyRequest{TxID: txID, From: from, To: to, AssetID: assetID, Amount: withdrawal.Send, Fee: withdrawal.Fee, Override: override})
+	if err != nil {
+		return "", err
+	}
+	//tx_send返回无法确认交易未发出的错误（如超时）时不回退当天的提现总额
+	var sendErr error
+	defer func() { done(err == nil || (sendErr != nil && !txNotSent(sendErr))) }()
+
+	release, err := wm.withdrawals.Acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	txid, err = wm.walletClient.SendAssetTransaction(context.Background(), from, to, assetID, withdrawal.Send, withdrawal.Fee, comment, txID)
+	if err != nil {
+		sendErr = err
+		return "", wm.sendFailed(err, from, to, assetID, withdrawal, comment, txID, "")
+	}
+	return txid, nil
+}
@@ -0,0 +1,136 @@
+package beam
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func newAssetTestServer(sent *map[string]interface{}, infoCalls *int) *httptest.Server {
+	return newRPCTestServer(rpcTestMethods{
+		"get_asset_info": func(params map[string]interface{}) (string, error) {
+			*infoCalls++
+			if params["asset_id"] != float64(7) {
+				return "", &rpcTestError{Code: -32003, Message: "asset not found"}
+			}
+			return `{"asset_id":7,"emission":500,"isOwned":0,"metadata":"STD:SCH_VER=1;N=Test Coin;SN=TST;UN=TST;NTHUN=GROTH","metadata_pairs":{"N":"Test Coin","SN":"TST","UN":"TST"}}`, nil
+		},
+		"addr_list":        rpcResult(`[{"address":"from","own":true,"comment":"self"}]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false,"type":"regular"}`),
+		"wallet_status":    rpcResult(`{"current_height":100,"available":1000}`),
+		"get_utxo":         rpcResult(`[{"id":"a1","asset_id":7,"amount":300,"maturity":10,"status":1},{"id":"a2","asset_id":7,"amount":200,"maturity":10,"status":1}]`),
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			*sent = params
+			return `{"txId":"asset-tx"}`, nil
+		},
+	})
+}
+
+func TestWalletManager_AssetCoin(t *testing.T) {
+
+	var (
+		sent      map[string]interface{}
+		infoCalls int
+	)
+	server := newAssetTestServer(&sent, &infoCalls)
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if coin := wm.AssetCoin(BeamAssetID); coin.IsContract || coin.Symbol != Symbol {
+		t.Errorf("AssetCoin(0) = %+v", coin)
+	}
+
+	coin := wm.AssetCoin(7)
+	if !coin.IsContract || coin.ContractID != openwallet.GenContractID(Symbol, "7") || coin.Contract.Token != "TST" || coin.Contract.Name != "Test Coin" {
+		t.Errorf("AssetCoin(7) = %+v", coin)
+	}
+	wm.AssetCoin(7)
+	if infoCalls != 1 {
+		t.Errorf("get_asset_info calls = %d, want 1", infoCalls)
+	}
+
+	//资产信息查询失败时使用资产ID作为简称
+	if coin := wm.AssetCoin(8); !coin.IsContract || coin.Contract.Token != "CA8" {
+		t.Errorf("AssetCoin(8) = %+v", coin)
+	}
+
+	if assetID, err := ParseAssetID(coin); err != nil || assetID != 7 {
+		t.Errorf("ParseAssetID = %d, %v", assetID, err)
+	}
+	if _, err := ParseAssetID(openwallet.Coin{IsContract: true, Contract: openwallet.SmartContract{Address: "abc"}}); err == nil {
+		t.Errorf("ParseAssetID should fail for invalid address")
+	}
+}
+
+func TestNewTransaction_AssetID(t *testing.T) {
+
+	result := gjson.Parse(`{"txId":"t1","asset_id":7,"value":100,"fee":100,"income":true}`)
+	tx := NewTransaction(&result)
+
+	wm := NewWalletManager()
+	wm.assetRegistry.infos[7] = &AssetInfo{AssetID: 7, ShortName: "TST"}
+	scanner := NewBEAMBlockScanner(wm)
+
+	data := &openwallet.TxExtractData{}
+	scanner.extractTxInput(tx, data)
+	scanner.extractTxOutput(tx, data)
+	if len(data.TxInputs) != 2 || len(data.TxOutputs) != 1 {
+		t.Errorf("inputs = %d, outputs = %d", len(data.TxInputs), len(data.TxOutputs))
+		return
+	}
+	if !data.TxInputs[0].Coin.IsContract || !data.TxOutputs[0].Coin.IsContract || data.TxOutputs[0].Coin.Contract.Token != "TST" {
+		t.Errorf("asset coin = %+v", data.TxOutputs[0].Coin)
+	}
+	//手续费使用BEAM支付
+	if data.TxInputs[1].Coin.IsContract || data.TxInputs[1].Amount != "0.000001" {
+		t.Errorf("fee input = %+v", data.TxInputs[1])
+	}
+}
+
+func TestTransactionDecoder_SubmitAssetTransaction(t *testing.T) {
+
+	var (
+		sent      map[string]interface{}
+		infoCalls int
+	)
+	server := newAssetTestServer(&sent, &infoCalls)
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	decoder := NewTransactionDecoder(wm)
+
+	coin := wm.AssetCoin(7)
+	rawTx := &openwallet.RawTransaction{
+		Coin: coin,
+		To:   map[string]string{"to": "0.000004"},
+	}
+	tx, err := decoder.SubmitRawTransaction(nil, rawTx)
+	if err != nil {
+		t.Errorf("SubmitRawTransaction unexpected error: %v", err)
+		return
+	}
+	if tx.TxID != "asset-tx" || !tx.Coin.IsContract {
+		t.Errorf("tx = %+v", tx)
+	}
+	//资产全额发送，不从发送数量中扣除手续费
+	if sent["asset_id"] != float64(7) || sent["value"] != float64(400) || sent["fee"] != float64(100) {
+		t.Errorf("tx_send params = %v", sent)
+	}
+
+	//资产余额不足
+	rawTx = &openwallet.RawTransaction{
+		Coin: coin,
+		To:   map[string]string{"to": "0.00001"},
+	}
+	if _, err = decoder.SubmitRawTransaction(nil, rawTx); err == nil {
+		t.Errorf("SubmitRawTransaction should fail when asset balance is not enough")
+	}
+}
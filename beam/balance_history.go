@@ -0,0 +1,65 @@
+package beam
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//GetBalanceAtHeight 按本地账本计算地址在指定高度（含）的BEAM余额：入账减出账和手续费，用于日终对账和争议处理；
+//beam钱包的余额不区分地址，结果是该地址记账的收支净额，只包含扫块提取和迁移导入的交易
+func (wm *WalletManager) GetBalanceAtHeight(address string, height uint64) (*openwallet.Balance, error) {
+	return wm.GetAssetBalanceAtHeight(address, BeamAssetID, height)
+}
+
+//GetAssetBalanceAtHeight 按本地账本计算地址在指定高度（含）的资产余额，机密资产的手续费计入BEAM余额
+func (wm *WalletManager) GetAssetBalanceAtHeight(address string, assetID int64, height uint64) (*openwallet.Balance, error) {
+
+	address = wm.addressMapper.Resolve(address)
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var records []*LedgerRecord
+	err = db.Select(q.Eq("Address", address), q.Lte("BlockHeight", height)).Find(&records)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	balance := decimal.Zero
+	for _, r := range records {
+		if r.AssetID == assetID {
+			amount, err := decimal.NewFromString(r.Amount)
+			if err != nil {
+				return nil, fmt.Errorf("ledger record: %s amount: %s is invalid", r.ID, r.Amount)
+			}
+			if r.Direction == LedgerDirectionOut {
+				balance = balance.Sub(amount)
+			} else {
+				balance = balance.Add(amount)
+			}
+		}
+		if assetID == BeamAssetID && r.Direction == LedgerDirectionOut && len(r.Fees) > 0 {
+			fees, err := decimal.NewFromString(r.Fees)
+			if err != nil {
+				return nil, fmt.Errorf("ledger record: %s fees: %s is invalid", r.ID, r.Fees)
+			}
+			balance = balance.Sub(fees)
+		}
+	}
+
+	return &openwallet.Balance{
+		Symbol:           wm.Symbol(),
+		Address:          address,
+		Balance:          balance.String(),
+		ConfirmBalance:   balance.String(),
+		UnconfirmBalance: "0",
+	}, nil
+}
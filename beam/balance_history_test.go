@@ -0,0 +1,55 @@
+package beam
+
+import (
+	"testing"
+)
+
+func TestGetBalanceAtHeight(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+
+	record := func(txid, direction, amount, fees string, height uint64, assetID int64) *LedgerRecord {
+		r := NewLedgerRecord(txid, "deposit", direction)
+		r.Amount = amount
+		r.Fees = fees
+		r.BlockHeight = height
+		r.AssetID = assetID
+		return r
+	}
+	err := wm.SaveLedgerRecords(
+		record("t1", LedgerDirectionIn, "10", "", 100, BeamAssetID),
+		record("t2", LedgerDirectionOut, "2.5", "0.001", 200, BeamAssetID),
+		record("t3", LedgerDirectionIn, "50", "", 200, 7),
+		record("t4", LedgerDirectionOut, "20", "0.002", 300, 7),
+		record("t5", LedgerDirectionIn, "1", "", 400, BeamAssetID),
+	)
+	if err != nil {
+		t.Errorf("SaveLedgerRecords unexpected error: %v", err)
+		return
+	}
+
+	tests := []struct {
+		assetID int64
+		height  uint64
+		want    string
+	}{
+		{BeamAssetID, 99, "0"},
+		{BeamAssetID, 100, "10"},
+		{BeamAssetID, 200, "7.499"},
+		{BeamAssetID, 300, "7.497"},
+		{BeamAssetID, 1000, "8.497"},
+		{7, 200, "50"},
+		{7, 300, "30"},
+	}
+	for _, test := range tests {
+		b, err := wm.GetAssetBalanceAtHeight("deposit", test.assetID, test.height)
+		if err != nil || b.Balance != test.want || b.ConfirmBalance != test.want {
+			t.Errorf("GetAssetBalanceAtHeight(%d, %d) = %+v, err = %v, want %s", test.assetID, test.height, b, err, test.want)
+		}
+	}
+
+	if b, _ := wm.GetBalanceAtHeight("other", 1000); b.Balance != "0" {
+		t.Errorf("GetBalanceAtHeight other = %s, want 0", b.Balance)
+	}
+}
@@ -0,0 +1,477 @@
+package beam
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	//批量提现每一行的处理结果
+	BatchRowInvalid   = "invalid"   //地址或金额错误
+	BatchRowPending   = "pending"   //验证通过，未发送
+	BatchRowSent      = "sent"      //本次发送
+	BatchRowSkipped   = "skipped"   //之前已发送，断点续发时跳过
+	BatchRowFailed    = "failed"    //发送失败，之后的行不再发送；或交易在钱包中失败、取消
+	BatchRowConfirmed = "confirmed" //交易已完成
+)
+
+//BatchWithdrawalRow 批量提现的一行及处理结果
+type BatchWithdrawalRow struct {
+	Line    int //数据行的序号，从1开始
+	Address string
+	Amount  string //单位BEAM
+	Memo    string
+	Send    uint64 //接收方到账金额，单位groth
+	Fee     uint64 //估算的手续费，单位groth
+	Status  string
+	TxID    string
+	Error   string
+}
+
+//BatchWithdrawalReport 批量提现的结果
+type BatchWithdrawalReport struct {
+	BatchID     string
+	Rows        []*BatchWithdrawalRow
+	TotalAmount uint64 //需要支付的提现总额，不含手续费
+	TotalFee    uint64 //估算的手续费总额
+	Sent        int
+	Skipped     int
+	Confirmed   int //交易已完成的行数，查询批次报告时统计
+	Failed      int //失败的行数，查询批次报告时统计
+	//Checkpoint 第一个未完成的行序号，全部完成时为0，修正问题后重新执行同一文件从该行继续
+	Checkpoint int
+	CreateTime int64
+	UpdateTime int64
+}
+
+//WithdrawalBatch 本地保存的批量提现批次，记录每个接收方的处理结果
+type WithdrawalBatch struct {
+	BatchID    string `storm:"id"`
+	Rows       []*BatchWithdrawalRow
+	CreateTime int64
+	UpdateTime int64
+}
+
+//BatchWithdraw 按CSV（列名address,amount,memo）批量提现，先验证全部地址和金额并估算手续费，
+//dryRun为true时只验证不发送；批次ID为文件内容的摘要，重新执行同一文件时跳过已发送的行
+func (wm *WalletManager) BatchWithdraw(filePath string, dryRun bool) (*BatchWithdrawalReport, error) {
+
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := readBatchWithdrawalCSV(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(data)
+	batchID := hex.EncodeToString(hash[:8])
+
+	return wm.BatchSend(batchID, rows, dryRun)
+}
+
+//BatchSend 在同一个批次ID下按顺序逐个发送提现，先验证全部地址和金额并估算手续费，dryRun为true时只验证不发送；
+//每一行以批次ID和行号作为业务订单号记录交易ID，发送失败时停止，使用相同的批次ID重新执行时跳过已发送的行；
+//每一行的处理结果保存在本地，通过GetBatchReport查询
+func (wm *WalletManager) BatchSend(batchID string, rows []*BatchWithdrawalRow, dryRun bool) (*BatchWithdrawalReport, error) {
+
+	if len(batchID) == 0 {
+		return nil, fmt.Errorf("batch id is empty")
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("batch withdrawal is empty")
+	}
+	for i, row := range rows {
+		row.Line = i + 1
+		row.Status = ""
+		row.TxID = ""
+		row.Error = ""
+	}
+
+	report := &BatchWithdrawalReport{BatchID: batchID, Rows: rows}
+
+	//验证全部行，有错误时不发送
+	invalid := 0
+	for _, row := range rows {
+		if err := wm.prepareBatchRow(row); err != nil {
+			row.Status = BatchRowInvalid
+			row.Error = err.Error()
+			invalid++
+			continue
+		}
+		row.Status = BatchRowPending
+		report.TotalAmount += row.Send
+		report.TotalFee += row.Fee
+	}
+	if invalid > 0 {
+		return report, fmt.Errorf("%d rows of batch withdrawal are invalid", invalid)
+	}
+
+	walletStatus, err := wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return report, err
+	}
+
+	//断点续发时已发送的行不再需要余额
+	required := uint64(0)
+	for _, row := range rows {
+		txID, err := wm.batchSentTxID(batchRowSid(batchID, row))
+		if err != nil {
+			return report, err
+		}
+		if len(txID) > 0 {
+			row.Status = BatchRowSkipped
+			row.TxID = txID
+			report.Skipped++
+			continue
+		}
+		required += row.Send + row.Fee
+	}
+	available := wm.unreservedBalance(walletStatus.Available)
+	if available < required {
+		return report, fmt.Errorf("wallet available balance %d is not enough for batch withdrawal %d", available, required)
+	}
+
+	if dryRun {
+		report.Checkpoint = batchCheckpoint(rows)
+		return report, nil
+	}
+
+	from, err := wm.SenderAddress("")
+	if err != nil {
+		return report, err
+	}
+
+	batch, err := wm.loadWithdrawalBatch(batchID)
+	if err != nil {
+		batch = &WithdrawalBatch{BatchID: batchID, CreateTime: time.Now().Unix()}
+	}
+	batch.Rows = rows
+	report.CreateTime = batch.CreateTime
+
+	for _, row := range rows {
+		if row.Status == BatchRowSkipped {
+			continue
+		}
+
+		err = wm.sendBatchRow(from, batchRowSid(batchID, row), row)
+		if err != nil {
+			row.Status = BatchRowFailed
+			row.Error = err.Error()
+			report.Checkpoint = row.Line
+			wm.Log.Errorf("batch withdrawal: %s line %d to: %s failed, unexpected error: %v", batchID, row.Line, row.Address, err)
+			wm.saveWithdrawalBatch(batch)
+			return report, err
+		}
+		row.Status = BatchRowSent
+		report.Sent++
+		wm.Log.Infof("batch withdrawal: %s line %d to: %s sent, txid: %s", batchID, row.Line, row.Address, row.TxID)
+		wm.saveWithdrawalBatch(batch)
+	}
+
+	wm.saveWithdrawalBatch(batch)
+	report.UpdateTime = batch.UpdateTime
+	return report, nil
+}
+
+//GetBatchReport 查询批次的处理结果，已发送的行按钱包中的交易状态更新为已完成或失败
+func (wm *WalletManager) GetBatchReport(batchID string) (*BatchWithdrawalReport, error) {
+
+	batch, err := wm.loadWithdrawalBatch(batchID)
+	if err != nil {
+		return nil, fmt.Errorf("batch withdrawal: %s not found", batchID)
+	}
+
+	changed := false
+	for _, row := range batch.Rows {
+		if len(row.TxID) == 0 || row.Status == BatchRowConfirmed {
+			continue
+		}
+		tx, err := wm.walletClient.GetTransaction(context.Background(), row.TxID)
+		if err != nil || tx.TxID != row.TxID {
+			continue
+		}
+		switch tx.Status {
+		case TxStatusCompleted:
+			row.Status = BatchRowConfirmed
+			row.Error = ""
+			changed = true
+		case TxStatusFailed, TxStatusCanceled:
+			if row.Status != BatchRowFailed {
+				row.Status = BatchRowFailed
+				row.Error = fmt.Sprintf("transaction %s", tx.StatusString)
+				changed = true
+			}
+		}
+	}
+	if changed {
+		wm.saveWithdrawalBatch(batch)
+	}
+
+	return newBatchReport(batch), nil
+}
+
+//ListBatches 列出本地保存的批次，按创建时间排序，不查询交易状态
+func (wm *WalletManager) ListBatches() ([]*BatchWithdrawalReport, error) {
+
+	db, err := wm.openBatchDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var batches []*WithdrawalBatch
+	err = db.All(&batches)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.SliceStable(batches, func(i, j int) bool {
+		return batches[i].CreateTime < batches[j].CreateTime
+	})
+
+	reports := make([]*BatchWithdrawalReport, 0, len(batches))
+	for _, batch := range batches {
+		reports = append(reports, newBatchReport(batch))
+	}
+	return reports, nil
+}
+
+//newBatchReport 按每一行的状态统计批次结果
+func newBatchReport(batch *WithdrawalBatch) *BatchWithdrawalReport {
+
+	report := &BatchWithdrawalReport{
+		BatchID:    batch.BatchID,
+		Rows:       batch.Rows,
+		CreateTime: batch.CreateTime,
+		UpdateTime: batch.UpdateTime,
+	}
+	for _, row := range batch.Rows {
+		report.TotalAmount += row.Send
+		report.TotalFee += row.Fee
+		switch row.Status {
+		case BatchRowSent:
+			report.Sent++
+		case BatchRowSkipped:
+			report.Skipped++
+		case BatchRowConfirmed:
+			report.Confirmed++
+		case BatchRowFailed:
+			report.Failed++
+		}
+	}
+	report.Checkpoint = batchCheckpoint(batch.Rows)
+	return report
+}
+
+func (wm *WalletManager) openBatchDB() (*storm.DB, error) {
+	return storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+}
+
+func (wm *WalletManager) loadWithdrawalBatch(batchID string) (*WithdrawalBatch, error) {
+
+	db, err := wm.openBatchDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var batch WithdrawalBatch
+	err = db.One("BatchID", batchID, &batch)
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+//saveWithdrawalBatch 保存批次，保存失败不影响发送，断点续发通过业务订单号的交易ID判断
+func (wm *WalletManager) saveWithdrawalBatch(batch *WithdrawalBatch) {
+
+	batch.UpdateTime = time.Now().Unix()
+
+	db, err := wm.openBatchDB()
+	if err != nil {
+		wm.Log.Warningf("save batch withdrawal: %s failed, unexpected error: %v", batch.BatchID, err)
+		return
+	}
+	defer db.Close()
+
+	if err := db.Save(batch); err != nil {
+		wm.Log.Warningf("save batch withdrawal: %s failed, unexpected error: %v", batch.BatchID, err)
+	}
+}
+
+//prepareBatchRow 验证一行的地址和金额，估算手续费
+func (wm *WalletManager) prepareBatchRow(row *BatchWithdrawalRow) error {
+
+	amountDec, err := decimal.NewFromString(row.Amount)
+	if err != nil {
+		return fmt.Errorf("amount: %s is invalid", row.Amount)
+	}
+	amountDec = amountDec.Shift(wm.Decimal())
+	if amountDec.Sign() <= 0 || !amountDec.Equal(amountDec.Truncate(0)) {
+		return fmt.Errorf("amount: %s is invalid", row.Amount)
+	}
+	amount := uint64(amountDec.IntPart())
+
+	err = wm.checkWithdrawalAddress(row.Address)
+	if err != nil {
+		return err
+	}
+
+	err = wm.withdrawalPolicy.CheckDestination(row.Address, false)
+	if err != nil {
+		return err
+	}
+
+	err = checkTxComment(row.Memo)
+	if err != nil {
+		return err
+	}
+
+	fee, err := wm.EstimateFee(amount, []string{row.Address})
+	if err != nil {
+		return err
+	}
+
+	withdrawal, err := wm.CalcWithdrawal(amount, fee, wm.Config.withdrawmode)
+	if err != nil {
+		return err
+	}
+
+	row.Send = withdrawal.Send
+	row.Fee = withdrawal.Fee
+	return nil
+}
+
+//sendBatchRow 发送一行，发送前记录交易ID，中断后重新执行不会重复发送
+func (wm *WalletManager) sendBatchRow(from, sid string, row *BatchWithdrawalRow) error {
+
+	txID, _ := wm.GetSendTxID(sid)
+	if len(txID) > 0 {
+		//钱包中已有的交易是失败或取消的，需要新的交易ID，其他情况用原交易ID重发，钱包不会重复发送
+		tx, err := wm.walletClient.GetTransaction(context.Background(), txID)
+		if err == nil && tx.TxID == txID && (tx.Status == TxStatusFailed || tx.Status == TxStatusCanceled) {
+			txID = ""
+		}
+	}
+	if len(txID) == 0 {
+		var err error
+		txID, err = wm.walletClient.GenerateTxID(context.Background())
+		if err != nil {
+			return err
+		}
+		err = wm.SaveSendTxID(sid, txID)
+		if err != nil {
+			return err
+		}
+	}
+
+	txid, err := wm.sendWithdrawal(from, row.Address, &WithdrawalAmount{Send: row.Send, Fee: row.Fee}, row.Memo, txID, sid, false)
+	if err != nil {
+		return err
+	}
+	row.TxID = txid
+	return nil
+}
+
+//batchSentTxID 业务订单号已发送的交易ID，钱包中没有该交易或交易失败、取消时返回空；
+//无法确认交易状态（如连接失败、超时）时返回错误，避免重复发送
+func (wm *WalletManager) batchSentTxID(sid string) (string, error) {
+	txID, _ := wm.GetSendTxID(sid)
+	if len(txID) == 0 {
+		return "", nil
+	}
+	tx, err := wm.walletClient.GetTransaction(context.Background(), txID)
+	if err != nil {
+		//钱包返回的json-rpc错误表示没有该交易
+		if errorClass(err) == "rpc" {
+			return "", nil
+		}
+		return "", fmt.Errorf("get tx: %s of %s failed, unexpected error: %v", txID, sid, err)
+	}
+	if tx.TxID != txID || tx.Status == TxStatusFailed || tx.Status == TxStatusCanceled {
+		return "", nil
+	}
+	return txID, nil
+}
+
+//batchRowSid 批量提现一行的业务订单号
+func batchRowSid(batchID string, row *BatchWithdrawalRow) string {
+	return fmt.Sprintf("batch-%s-%d", batchID, row.Line)
+}
+
+//batchCheckpoint 第一个未发送或失败的行序号
+func batchCheckpoint(rows []*BatchWithdrawalRow) int {
+	for _, row := range rows {
+		if row.Status != BatchRowSkipped && row.Status != BatchRowSent && row.Status != BatchRowConfirmed {
+			return row.Line
+		}
+	}
+	return 0
+}
+
+//readBatchWithdrawalCSV 读取CSV，第一行为列名，跳过地址和金额都为空的行
+func readBatchWithdrawalCSV(r io.Reader) ([]*BatchWithdrawalRow, error) {
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) < 2 {
+		return nil, fmt.Errorf("batch withdrawal csv file is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"address", "amount"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("batch withdrawal csv column %s is missing", name)
+		}
+	}
+
+	get := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := make([]*BatchWithdrawalRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		address := get(record, "address")
+		if len(address) == 0 && len(get(record, "amount")) == 0 {
+			continue
+		}
+		rows = append(rows, &BatchWithdrawalRow{
+			Line:    len(rows) + 1,
+			Address: address,
+			Amount:  get(record, "amount"),
+			Memo:    get(record, "memo"),
+		})
+	}
+
+	return rows, nil
+}
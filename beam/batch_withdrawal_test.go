@@ -0,0 +1,237 @@
+package beam
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadBatchWithdrawalCSV(t *testing.T) {
+
+	rows, err := readBatchWithdrawalCSV(strings.NewReader("Address, Amount, Memo\na,1.5,first\n,,\nb,2\n"))
+	if err != nil {
+		t.Errorf("readBatchWithdrawalCSV unexpected error: %v", err)
+		return
+	}
+	if len(rows) != 2 || rows[0].Memo != "first" || rows[1].Line != 2 || rows[1].Amount != "2" {
+		t.Errorf("rows = %+v, %+v", rows[0], rows[1])
+	}
+
+	if _, err = readBatchWithdrawalCSV(strings.NewReader("address,memo\na,first\n")); err == nil {
+		t.Errorf("csv without amount column should fail")
+	}
+}
+
+func TestWalletManager_BatchWithdraw(t *testing.T) {
+
+	var (
+		ids     int
+		offline = true
+		sends   []string
+		sent    = make(map[string]bool)
+	)
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list": rpcResult(`[{"address":"from","own":true,"comment":"self"}]`),
+		"validate_address": func(params map[string]interface{}) (string, error) {
+			return fmt.Sprintf(`{"is_valid":%v,"is_mine":false,"type":"regular"}`, params["address"] != "bad"), nil
+		},
+		"calc_change":   rpcResult(`{"change":5,"explicit_fee":100}`),
+		"wallet_status": rpcResult(`{"available":100000000}`),
+		"generate_tx_id": func(map[string]interface{}) (string, error) {
+			ids++
+			return fmt.Sprintf(`"tx%d"`, ids), nil
+		},
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			if offline && params["address"] == "receiver" {
+				return "", &rpcTestError{Code: -32001, Message: "receiver offline"}
+			}
+			txID := params["txId"].(string)
+			sends = append(sends, params["address"].(string))
+			sent[txID] = true
+			return fmt.Sprintf(`{"txId":"%s"}`, txID), nil
+		},
+		"tx_status": func(params map[string]interface{}) (string, error) {
+			txID := params["txId"].(string)
+			if !sent[txID] {
+				return "", &rpcTestError{Code: -32003, Message: "tx not found"}
+			}
+			return fmt.Sprintf(`{"txId":"%s","status":1}`, txID), nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	//有错误的行时不发送
+	invalidFile := filepath.Join(t.TempDir(), "invalid.csv")
+	ioutil.WriteFile(invalidFile, []byte("address,amount,memo\na,0.00001,\nbad,0.00001,\nc,abc,\n"), 0644)
+	report, err := wm.BatchWithdraw(invalidFile, false)
+	if err == nil || report.Rows[1].Status != BatchRowInvalid || report.Rows[2].Status != BatchRowInvalid || len(sends) != 0 {
+		t.Errorf("BatchWithdraw invalid file = %+v, %v, sends %v", report, err, sends)
+	}
+
+	file := filepath.Join(t.TempDir(), "batch.csv")
+	ioutil.WriteFile(file, []byte("address,amount,memo\na,0.00001,first\nreceiver,0.00001,\nc,0.00002,\n"), 0644)
+
+	report, err = wm.BatchWithdraw(file, true)
+	if err != nil || report.TotalAmount != 4000 || report.TotalFee != 300 || report.Checkpoint != 1 || len(sends) != 0 {
+		t.Errorf("BatchWithdraw dry run = %+v, %v", report, err)
+	}
+
+	//第2行失败时停止，断点为第2行
+	report, err = wm.BatchWithdraw(file, false)
+	if err == nil || report.Sent != 1 || report.Checkpoint != 2 || report.Rows[1].Status != BatchRowFailed || report.Rows[2].Status != BatchRowPending {
+		t.Errorf("BatchWithdraw = %+v, %v", report, err)
+	}
+
+	//重新执行时跳过已发送的第1行，失败的行使用相同的交易ID重发
+	offline = false
+	report, err = wm.BatchWithdraw(file, false)
+	if err != nil || report.Sent != 2 || report.Skipped != 1 || report.Checkpoint != 0 {
+		t.Errorf("BatchWithdraw resume = %+v, %v", report, err)
+	}
+	if strings.Join(sends, ",") != "a,receiver,c" {
+		t.Errorf("sends = %v, want a,receiver,c", sends)
+	}
+	if report.Rows[1].TxID != "tx2" {
+		t.Errorf("resumed row txid = %s, want tx2", report.Rows[1].TxID)
+	}
+}
+
+func TestWalletManager_BatchSend(t *testing.T) {
+
+	var ids int
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list":        rpcResult(`[{"address":"from","own":true,"comment":"self"}]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false,"type":"regular"}`),
+		"calc_change":      rpcResult(`{"change":5,"explicit_fee":100}`),
+		"wallet_status":    rpcResult(`{"available":100000000}`),
+		"generate_tx_id": func(map[string]interface{}) (string, error) {
+			ids++
+			return fmt.Sprintf(`"tx%d"`, ids), nil
+		},
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			return fmt.Sprintf(`{"txId":"%s"}`, params["txId"]), nil
+		},
+		"tx_status": func(params map[string]interface{}) (string, error) {
+			//tx1已完成，tx2失败，tx3处理中
+			txID := params["txId"].(string)
+			status := map[string]string{"tx1": "3", "tx2": "4", "tx3": "1"}[txID]
+			if len(status) == 0 {
+				return "", &rpcTestError{Code: -32003, Message: "tx not found"}
+			}
+			return fmt.Sprintf(`{"txId":"%s","status":%s,"status_string":"status %s"}`, txID, status, status), nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	rows := []*BatchWithdrawalRow{
+		{Address: "a", Amount: "0.00001"},
+		{Address: "b", Amount: "0.00002"},
+		{Address: "c", Amount: "0.00003"},
+	}
+	if _, err := wm.BatchSend("", rows, false); err == nil {
+		t.Errorf("BatchSend without batch id should fail")
+	}
+
+	report, err := wm.BatchSend("payroll", rows, false)
+	if err != nil || report.BatchID != "payroll" || report.Sent != 3 {
+		t.Errorf("BatchSend = %+v, %v", report, err)
+		return
+	}
+
+	report, err = wm.GetBatchReport("payroll")
+	if err != nil {
+		t.Errorf("GetBatchReport unexpected error: %v", err)
+		return
+	}
+	if report.Confirmed != 1 || report.Failed != 1 || report.Sent != 1 || report.Checkpoint != 2 || report.TotalAmount != 6000 {
+		t.Errorf("GetBatchReport = %+v", report)
+	}
+	if report.Rows[0].Status != BatchRowConfirmed || report.Rows[1].Status != BatchRowFailed || report.Rows[1].Error != "transaction status 4" {
+		t.Errorf("rows = %+v, %+v", report.Rows[0], report.Rows[1])
+	}
+
+	if _, err := wm.GetBatchReport("unknown"); err == nil {
+		t.Errorf("GetBatchReport unknown batch should fail")
+	}
+
+	batches, err := wm.ListBatches()
+	if err != nil || len(batches) != 1 || batches[0].BatchID != "payroll" || batches[0].Confirmed != 1 {
+		t.Errorf("ListBatches = %v, %v", batches, err)
+	}
+}
+
+func TestWalletManager_SendBatchRowResume(t *testing.T) {
+
+	var sent []string
+	walletDown := false
+	methods := rpcTestMethods{
+		"addr_list":        rpcResult(`[{"address":"from","own":true,"comment":"self"}]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false,"type":"regular"}`),
+		"generate_tx_id":   rpcResult(`"new1"`),
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			sent = append(sent, params["txId"].(string))
+			return fmt.Sprintf(`{"txId":"%s"}`, params["txId"]), nil
+		},
+		"tx_status": func(params map[string]interface{}) (string, error) {
+			//sent1处理中，failed1失败
+			txID := params["txId"].(string)
+			status := map[string]string{"sent1": "1", "failed1": "4"}[txID]
+			return fmt.Sprintf(`{"txId":"%s","status":%s,"status_string":"status %s"}`, txID, status, status), nil
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if walletDown {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		methods.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	//处理中的交易用原交易ID重发，不能生成新的交易ID
+	wm.SaveSendTxID("batch-resume-1", "sent1")
+	row := &BatchWithdrawalRow{Line: 1, Address: "a", Send: 1000, Fee: 100}
+	if err := wm.sendBatchRow("from", "batch-resume-1", row); err != nil {
+		t.Errorf("sendBatchRow unexpected error: %v", err)
+		return
+	}
+	if len(sent) != 1 || sent[0] != "sent1" {
+		t.Errorf("sendBatchRow in progress tx sent = %v, want [sent1]", sent)
+	}
+
+	//失败的交易需要新的交易ID
+	sent = nil
+	wm.SaveSendTxID("batch-resume-2", "failed1")
+	row = &BatchWithdrawalRow{Line: 2, Address: "a", Send: 1000, Fee: 100}
+	if err := wm.sendBatchRow("from", "batch-resume-2", row); err != nil {
+		t.Errorf("sendBatchRow unexpected error: %v", err)
+		return
+	}
+	if len(sent) != 1 || sent[0] != "new1" {
+		t.Errorf("sendBatchRow failed tx sent = %v, want [new1]", sent)
+	}
+
+	//无法确认交易状态时中止
+	walletDown = true
+	if txID, err := wm.batchSentTxID("batch-resume-1"); err == nil {
+		t.Errorf("batchSentTxID with wallet down = %s, want error", txID)
+	}
+}
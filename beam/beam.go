@@ -1,11 +1,15 @@
 package beam
 
 import (
+	"fmt"
 	"github.com/astaxie/beego/config"
 	"github.com/blocktree/openwallet/common/file"
 	"github.com/blocktree/openwallet/log"
 	"github.com/blocktree/openwallet/openwallet"
 	"github.com/blocktree/openwallet/owtp"
+	"github.com/shopspring/decimal"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -31,6 +35,9 @@ func (wm *WalletManager) Decimal() int32 {
 
 //BalanceModelType 余额模型类别
 func (wm *WalletManager) BalanceModelType() openwallet.BalanceModelType {
+	if wm.isAccountModel() {
+		return openwallet.BalanceModelTypeAccount
+	}
 	return openwallet.BalanceModelTypeAddress
 }
 
@@ -39,6 +46,11 @@ func (wm *WalletManager) GetAddressDecode() openwallet.AddressDecoder {
 	return wm.Decoder
 }
 
+//GetAddressDecoderV2 地址解析器V2
+func (wm *WalletManager) GetAddressDecoderV2() openwallet.AddressDecoderV2 {
+	return wm.Decoder
+}
+
 //GetTransactionDecoder 交易单解析器
 func (wm *WalletManager) GetTransactionDecoder() openwallet.TransactionDecoder {
 	return wm.TxDecoder
@@ -57,25 +69,286 @@ func (wm *WalletManager) LoadAssetsConfig(c config.Configer) error {
 		err error
 	)
 
+	//加密配置值的密钥，需要先于其他配置加载
+	wm.Config.secretkeyfile = c.String("secretkeyfile")
+	wm.Config.secretkey, err = loadSecretKey(wm.Config.secretkeyfile)
+	if err != nil {
+		return err
+	}
+	wm.secretProviders.Register(&EncSecretProvider{Key: wm.Config.secretkey})
+
+	//外部KMS，vaulttoken可以使用env:、file:或enc:来源
+	wm.Config.vaultaddr = c.DefaultString("vaultaddr", os.Getenv("VAULT_ADDR"))
+	if len(wm.Config.vaultaddr) > 0 {
+		vaultToken, err := newSecretSource("vaulttoken", c.DefaultString("vaulttoken", "env:VAULT_TOKEN"), wm.secretProviders)
+		if err != nil {
+			return err
+		}
+		wm.secretProviders.Register(NewVaultSecretProvider(wm.Config.vaultaddr, vaultToken.String()))
+	}
+	wm.Config.awskmsregion = c.DefaultString("awskmsregion", os.Getenv("AWS_REGION"))
+	wm.Config.awskmsendpoint = c.String("awskmsendpoint")
+	if len(wm.Config.awskmsregion) > 0 || len(wm.Config.awskmsendpoint) > 0 {
+		wm.secretProviders.Register(NewAWSKMSSecretProvider(wm.Config.awskmsregion, wm.Config.awskmsendpoint))
+	}
+
 	wm.Config.walletapi = c.String("walletapi")
 	wm.Config.explorerapi = c.String("explorerapi")
 	wm.Config.remoteserver = c.String("remoteserver")
 	wm.Config.enableserver, _ = c.Bool("enableserver")
 	wm.Config.fixfees = c.String("fixfees")
+	wm.Config.feemultiplier = c.DefaultFloat("feemultiplier", DefaultFeeMultiplier)
+	if wm.Config.feemultiplier < 1 {
+		return fmt.Errorf("feemultiplier should not be less than 1")
+	}
+	wm.Config.coinselection = c.DefaultString("coinselection", CoinSelectWallet)
+	switch wm.Config.coinselection {
+	case CoinSelectWallet, CoinSelectLargestFirst, CoinSelectSmallestFirst, CoinSelectBranchAndBound, CoinSelectConsolidate:
+	default:
+		return fmt.Errorf("unknown coinselection: %s", wm.Config.coinselection)
+	}
+	utxolocktimeout := c.String("utxolocktimeout")
+	if len(utxolocktimeout) > 0 {
+		wm.Config.utxolocktimeout, err = time.ParseDuration(utxolocktimeout)
+		if err != nil {
+			return err
+		}
+	}
+	wm.Config.dustthreshold = c.DefaultString("dustthreshold", DefaultDustThreshold)
+	wm.Config.dustcount = c.DefaultInt("dustcount", DefaultDustCount)
+	wm.Config.dustquiethours, err = parseQuietHours(c.String("dustquiethours"))
+	if err != nil {
+		return err
+	}
+	dustconsolidateperiod := c.String("dustconsolidateperiod")
+	if len(dustconsolidateperiod) > 0 {
+		wm.Config.dustconsolidateperiod, err = time.ParseDuration(dustconsolidateperiod)
+		if err != nil {
+			return err
+		}
+	}
+	wm.Config.withdrawmode = c.DefaultString("withdrawmode", WithdrawModeFixed)
+	switch wm.Config.withdrawmode {
+	case WithdrawModeFixed, WithdrawModeExact, WithdrawModeNet:
+	default:
+		return fmt.Errorf("unknown withdrawmode: %s", wm.Config.withdrawmode)
+	}
+	wm.Config.withdrawfrom = c.String("withdrawfrom")
+	wm.Config.balancemodel = c.DefaultString("balancemodel", BalanceModelAddress)
+	wm.Config.accountalias = c.String("accountalias")
+	err = wm.checkBalanceModel()
+	if err != nil {
+		return err
+	}
+	wm.Config.sendnodes = make([]string, 0)
+	for _, path := range strings.Split(c.String("sendnodes"), ",") {
+		path = strings.TrimSpace(path)
+		if len(path) > 0 {
+			wm.Config.sendnodes = append(wm.Config.sendnodes, path)
+		}
+	}
 	wm.Config.connecttype = c.String("connecttype")
 	wm.Config.enablekeyagreement, _ = c.Bool("enablekeyagreement")
 	wm.Config.enablessl, _ = c.Bool("enablessl")
 	wm.Config.requesttimeout, _ = c.Int("requesttimeout")
 	wm.Config.trustnodeid = c.String("trustnodeid")
-	wm.Config.cert = c.String("cert")
+	cert, err := newSecretSource("cert", c.String("cert"), wm.secretProviders)
+	if err != nil {
+		return err
+	}
+	wm.Config.cert = cert.String()
 	wm.Config.logdebug, _ = c.Bool("logdebug")
 	wm.Config.logdir = c.String("logdir")
 	wm.Config.summaryaddress = c.String("summaryaddress")
 	wm.Config.summarythreshold = c.String("summarythreshold")
 	wm.Config.summaryperiod = c.String("summaryperiod")
-	wm.walletClient = NewWalletClient(wm.Config.walletapi, wm.Config.explorerapi, wm.Config.logdebug)
+	wm.Config.summarycron = c.String("summarycron")
+	if len(wm.Config.summarycron) > 0 {
+		if _, err = ParseCronSchedule(wm.Config.summarycron); err != nil {
+			return err
+		}
+	}
+	wm.Config.summaryaccounts, err = parseSummaryAccounts(c.String("summaryaccounts"))
+	if err != nil {
+		return err
+	}
+	wm.Config.summarysplit, err = parseSummarySplit(c.String("summarysplit"), wm.Decimal())
+	if err != nil {
+		return err
+	}
+	wm.Config.summarymaxlag = uint64(c.DefaultInt64("summarymaxlag", DefaultSummaryMaxLag))
+	wm.Config.summarymaxpendingwithdrawals = c.DefaultInt("summarymaxpendingwithdrawals", DefaultSummaryMaxPendingWithdrawals)
+	wm.Config.summarywebhook = c.String("summarywebhook")
+	wm.Config.summaryminbalance = c.String("summaryminbalance")
+	if err = checkSummaryThreshold(wm.Config.summaryminbalance); err != nil {
+		return err
+	}
+	wm.Config.summaryfeefrom = c.DefaultString("summaryfeefrom", SummaryFeeFromAmount)
+	switch wm.Config.summaryfeefrom {
+	case SummaryFeeFromAmount:
+	case SummaryFeeFromReserve:
+		wm.Config.summaryfeereserve = c.String("summaryfeereserve")
+		if len(wm.Config.summaryfeereserve) == 0 {
+			return fmt.Errorf("summaryfeereserve is not setup")
+		}
+		if err = checkSummaryThreshold(wm.Config.summaryfeereserve); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown summaryfeefrom: %s", wm.Config.summaryfeefrom)
+	}
+	wm.Config.summarymaxamount = c.String("summarymaxamount")
+	if err = checkSummaryThreshold(wm.Config.summarymaxamount); err != nil {
+		return err
+	}
+	wm.Config.summarymaxtxs = c.DefaultInt("summarymaxtxs", 0)
+	if err = checkSummaryThrottle(wm.Config.summarysplit, wm.Config.summarymaxtxs); err != nil {
+		return err
+	}
+	summarymininterval := c.String("summarymininterval")
+	if len(summarymininterval) > 0 {
+		wm.Config.summarymininterval, err = time.ParseDuration(summarymininterval)
+		if err != nil {
+			return err
+		}
+	}
+	walletClient := NewWalletClient(wm.Config.walletapi, wm.Config.explorerapi, wm.Config.logdebug)
+	wm.walletClient = walletClient
+	walletClient.SetMetrics(wm.Metrics)
+	wm.Config.rpctrace, _ = c.Bool("rpctrace")
+	wm.Config.rpctracedir = c.String("rpctracedir")
+	err = walletClient.SetTrace(wm.Config.rpctrace, wm.Config.rpctracedir)
+	if err != nil {
+		return err
+	}
+
+	wm.Config.walletapipolicy = c.DefaultString("walletapipolicy", WalletAPIPolicyFailover)
+	switch wm.Config.walletapipolicy {
+	case WalletAPIPolicyFailover, WalletAPIPolicyRoundRobin:
+	default:
+		return fmt.Errorf("unknown walletapipolicy: %s", wm.Config.walletapipolicy)
+	}
+	walletClient.SetWalletAPIPolicy(wm.Config.walletapipolicy)
+	wm.Config.walletapitransport = c.DefaultString("walletapitransport", WalletAPITransportHTTP)
+	err = walletClient.SetTransport(wm.Config.walletapitransport)
+	if err != nil {
+		return err
+	}
+
+	walletapihealthcheckperiod := c.String("walletapihealthcheckperiod")
+	if len(walletapihealthcheckperiod) > 0 {
+		wm.Config.walletapihealthcheckperiod, err = time.ParseDuration(walletapihealthcheckperiod)
+		if err != nil {
+			return err
+		}
+	}
+
+	wm.Config.rpcretry.Count = c.DefaultInt("rpcretrycount", DefaultRPCRetryCount)
+	wm.Config.rpcretry.Jitter = c.DefaultFloat("rpcretryjitter", DefaultRPCRetryJitter)
+	rpcretrydelay := c.String("rpcretrydelay")
+	if len(rpcretrydelay) > 0 {
+		wm.Config.rpcretry.Delay, err = time.ParseDuration(rpcretrydelay)
+		if err != nil {
+			return err
+		}
+	}
+	rpcretrymaxdelay := c.String("rpcretrymaxdelay")
+	if len(rpcretrymaxdelay) > 0 {
+		wm.Config.rpcretry.MaxDelay, err = time.ParseDuration(rpcretrymaxdelay)
+		if err != nil {
+			return err
+		}
+	}
+	walletClient.SetRetryPolicy(wm.Config.rpcretry)
+
+	rpctimeout := c.String("rpctimeout")
+	if len(rpctimeout) > 0 {
+		wm.Config.rpctimeout, err = time.ParseDuration(rpctimeout)
+		if err != nil {
+			return err
+		}
+	}
+	wm.Config.rpcmethodtimeouts, err = parseMethodTimeouts(c.String("rpcmethodtimeouts"))
+	if err != nil {
+		return err
+	}
+	walletClient.SetTimeouts(wm.Config.rpctimeout, wm.Config.rpcmethodtimeouts)
+
+	wm.Config.walletapicafile = c.String("walletapicafile")
+	wm.Config.walletapicertfile = c.String("walletapicertfile")
+	wm.Config.walletapikeyfile = c.String("walletapikeyfile")
+	wm.Config.walletapiinsecure = c.DefaultBool("walletapiinsecure", false)
+	tlsConfig, err := NewTLSConfig(wm.Config.walletapicafile, wm.Config.walletapicertfile, wm.Config.walletapikeyfile, wm.Config.walletapiinsecure)
+	if err != nil {
+		return err
+	}
+	walletClient.SetTLSConfig(tlsConfig)
+
+	//密码和API Key支持env:、file:、enc:、vault:、awskms:和prompt来源
+	wm.Config.walletapipassword, err = newSecretSource("walletapipassword", c.String("walletapipassword"), wm.secretProviders)
+	if err != nil {
+		return err
+	}
+	wm.Config.walletapikey, err = newSecretSource("walletapikey", c.String("walletapikey"), wm.secretProviders)
+	if err != nil {
+		return err
+	}
+	wm.Config.walletapiauth = WalletAPIAuth{
+		User:         c.String("walletapiuser"),
+		APIKeyHeader: c.DefaultString("walletapikeyheader", DefaultWalletAPIKeyHeader),
+	}
+	wm.Config.walletapiauth, err = wm.walletAPIAuth()
+	if err != nil {
+		return err
+	}
+	walletClient.SetAuth(wm.Config.walletapiauth)
+	walletClient.SetAuthSource(wm.walletAPIAuth)
+
+	wm.Config.blocksources = make([]string, 0)
+	for _, source := range strings.Split(c.DefaultString("blocksources", BlockSourceExplorer), ",") {
+		source = strings.TrimSpace(source)
+		switch source {
+		case BlockSourceExplorer, BlockSourceWallet:
+			wm.Config.blocksources = append(wm.Config.blocksources, source)
+		case "":
+		default:
+			return fmt.Errorf("unknown block source: %s", source)
+		}
+	}
+	if len(wm.Config.blocksources) == 0 {
+		return fmt.Errorf("blocksources is empty")
+	}
+	walletClient.SetBlockSources(wm.Config.blocksources)
+	wm.Config.rpcratelimit = c.DefaultFloat("rpcratelimit", 0)
+	wm.Config.rpcburst = c.DefaultInt("rpcburst", DefaultRPCBurst)
+	walletClient.SetRateLimit(wm.Config.rpcratelimit, wm.Config.rpcburst)
+	wm.Config.rpcbreakerthreshold = c.DefaultInt("rpcbreakerthreshold", DefaultRPCBreakerThreshold)
+	rpcbreakercooldown := c.String("rpcbreakercooldown")
+	if len(rpcbreakercooldown) > 0 {
+		wm.Config.rpcbreakercooldown, err = time.ParseDuration(rpcbreakercooldown)
+		if err != nil {
+			return err
+		}
+	}
+	walletClient.SetCircuitBreaker(wm.Config.rpcbreakerthreshold, wm.Config.rpcbreakercooldown)
+	wm.Config.walletapiversion = c.String("walletapiversion")
+	if len(wm.Config.walletapiversion) > 0 {
+		if _, err = ParseWalletAPIVersion(wm.Config.walletapiversion); err != nil {
+			return err
+		}
+	}
+	wm.Config.rpcbatchsize = uint64(c.DefaultInt64("rpcbatchsize", DefaultRPCBatchSize))
+
 	wm.Config.walletdatafile = c.String("walletdatafile")
 	wm.Config.walletdatabackupdir = c.String("walletdatabackupdir")
+	wm.Config.walletbackuppassword, err = newSecretSource("walletbackuppassword", c.String("walletbackuppassword"), wm.secretProviders)
+	if err != nil {
+		return err
+	}
+	wm.Config.walletpassword, err = newSecretSource("walletpassword", c.String("walletpassword"), wm.secretProviders)
+	if err != nil {
+		return err
+	}
 
 	txsendingtimeout := c.String("txsendingtimeout")
 	if len(txsendingtimeout) == 0 {
@@ -87,12 +360,209 @@ func (wm *WalletManager) LoadAssetsConfig(c config.Configer) error {
 		}
 	}
 
-	if wm.Config.enableserver {
-		wm.server, err = NewServer(wm)
+	shieldedtxsendingtimeout := c.String("shieldedtxsendingtimeout")
+	if len(shieldedtxsendingtimeout) > 0 {
+		wm.Config.shieldedtxsendingtimeout, err = time.ParseDuration(shieldedtxsendingtimeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	txexpirycheckperiod := c.String("txexpirycheckperiod")
+	if len(txexpirycheckperiod) > 0 {
+		wm.Config.txexpirycheckperiod, err = time.ParseDuration(txexpirycheckperiod)
+		if err != nil {
+			return err
+		}
+	}
+
+	wm.Config.resendmaxattempts = c.DefaultInt("resendmaxattempts", DefaultResendMaxAttempts)
+	resendbackoff := c.String("resendbackoff")
+	if len(resendbackoff) > 0 {
+		wm.Config.resendbackoff, err = time.ParseDuration(resendbackoff)
+		if err != nil {
+			return err
+		}
+	}
+	resendmaxbackoff := c.String("resendmaxbackoff")
+	if len(resendmaxbackoff) > 0 {
+		wm.Config.resendmaxbackoff, err = time.ParseDuration(resendmaxbackoff)
+		if err != nil {
+			return err
+		}
+	}
+	resendperiod := c.String("resendperiod")
+	if len(resendperiod) > 0 {
+		wm.Config.resendperiod, err = time.ParseDuration(resendperiod)
+		if err != nil {
+			return err
+		}
+	}
+
+	wm.Config.assetdecimals, err = parseAssetDecimals(c.String("assetdecimals"))
+	if err != nil {
+		return err
+	}
+
+	for name, enabled := range defaultFeatures {
+		wm.Config.features[name] = c.DefaultBool("features::"+name, enabled)
+	}
+
+	wm.Config.metricsaddr = c.String("metricsaddr")
+	wm.Config.walleteventapi = c.String("walleteventapi")
+	wm.Config.legacyfeeinput = c.DefaultBool("legacyfeeinput", false)
+	wm.Config.blockcachesize = c.DefaultInt("blockcachesize", DefaultBlockCacheSize)
+	wm.blockCache = NewBlockCache(wm.Config.blockcachesize)
+	wm.Config.mineraddress = c.String("mineraddress")
+
+	wm.Config.maxinflightwithdrawals = c.DefaultInt("maxinflightwithdrawals", 0)
+	wm.Config.withdrawaloverflow = c.DefaultString("withdrawaloverflow", WithdrawalOverflowQueue)
+	switch wm.Config.withdrawaloverflow {
+	case WithdrawalOverflowQueue, WithdrawalOverflowReject, WithdrawalOverflowShedOldest:
+	default:
+		return fmt.Errorf("unknown withdrawaloverflow: %s", wm.Config.withdrawaloverflow)
+	}
+	wm.Config.withdrawalqueuesize = c.DefaultInt("withdrawalqueuesize", DefaultWithdrawalQueueSize)
+
+	withdrawalqueuetimeout := c.String("withdrawalqueuetimeout")
+	if len(withdrawalqueuetimeout) > 0 {
+		wm.Config.withdrawalqueuetimeout, err = time.ParseDuration(withdrawalqueuetimeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	withdrawalsendperiod := c.String("withdrawalsendperiod")
+	if len(withdrawalsendperiod) > 0 {
+		wm.Config.withdrawalsendperiod, err = time.ParseDuration(withdrawalsendperiod)
+		if err != nil {
+			return err
+		}
+	}
+	wm.Config.largeblockthreshold = c.DefaultInt64("largeblockthreshold", DefaultLargeBlockThreshold)
+	wm.Config.mindeposit = c.String("mindeposit")
+	if len(wm.Config.mindeposit) > 0 {
+		if _, err = decimal.NewFromString(wm.Config.mindeposit); err != nil {
+			return fmt.Errorf("invalid mindeposit: %s", wm.Config.mindeposit)
+		}
+	}
+	wm.Config.notifydustdeposit = c.DefaultBool("notifydustdeposit", false)
+	wm.Config.extractallow, err = parseAddressPatterns(c.String("extractallow"))
+	if err != nil {
+		return err
+	}
+	wm.Config.extractdeny, err = parseAddressPatterns(c.String("extractdeny"))
+	if err != nil {
+		return err
+	}
+	wm.Config.addresspoolsize = c.DefaultInt("addresspoolsize", DefaultAddressPoolSize)
+	wm.Config.addresspoollowwater = c.DefaultInt("addresspoollowwater", DefaultAddressPoolLowWater)
+	if wm.Config.addresspoollowwater > wm.Config.addresspoolsize {
+		return fmt.Errorf("addresspoollowwater: %d is greater than addresspoolsize: %d", wm.Config.addresspoollowwater, wm.Config.addresspoolsize)
+	}
+	addresspoolrefillperiod := c.String("addresspoolrefillperiod")
+	if len(addresspoolrefillperiod) > 0 {
+		wm.Config.addresspoolrefillperiod, err = time.ParseDuration(addresspoolrefillperiod)
+		if err != nil {
+			return err
+		}
+	}
+	addressexpirywarning := c.String("addressexpirywarning")
+	if len(addressexpirywarning) > 0 {
+		wm.Config.addressexpirywarning, err = time.ParseDuration(addressexpirywarning)
+		if err != nil {
+			return err
+		}
+	}
+	addressexpirycheckperiod := c.String("addressexpirycheckperiod")
+	if len(addressexpirycheckperiod) > 0 {
+		wm.Config.addressexpirycheckperiod, err = time.ParseDuration(addressexpirycheckperiod)
+		if err != nil {
+			return err
+		}
+	}
+	wm.Config.addressexpiryaction = c.DefaultString("addressexpiryaction", AddressExpiryActionRenew)
+	switch wm.Config.addressexpiryaction {
+	case AddressExpiryActionRenew, AddressExpiryActionReissue, AddressExpiryActionNotify:
+	default:
+		return fmt.Errorf("invalid addressexpiryaction: %s", wm.Config.addressexpiryaction)
+	}
+	ownershipchallengettl := c.String("ownershipchallengettl")
+	if len(ownershipchallengettl) > 0 {
+		wm.Config.ownershipchallengettl, err = time.ParseDuration(ownershipchallengettl)
 		if err != nil {
 			return err
 		}
-		wm.server.Listen()
+	}
+	wm.Config.walletcli = c.DefaultString("walletcli", DefaultWalletCLI)
+	walletsynctimeout := c.String("walletsynctimeout")
+	if len(walletsynctimeout) > 0 {
+		wm.Config.walletsynctimeout, err = time.ParseDuration(walletsynctimeout)
+		if err != nil {
+			return err
+		}
+	}
+	wm.Config.withdrawalpolicyfile = c.String("withdrawalpolicyfile")
+	err = wm.withdrawalPolicy.Reload()
+	if err != nil {
+		return err
+	}
+	wm.Config.enablewithdrawwhitelist = c.DefaultBool("enablewithdrawwhitelist", false)
+	wm.Config.withdrawwhitelist, err = parseAddressPatterns(c.String("withdrawwhitelist"))
+	if err != nil {
+		return err
+	}
+	wm.Config.withdrawwhitelistoverride = c.DefaultBool("withdrawwhitelistoverride", false)
+	wm.Config.txpagesize = uint64(c.DefaultInt64("txpagesize", DefaultTxPageSize))
+	if wm.Config.txpagesize == 0 {
+		wm.Config.txpagesize = DefaultTxPageSize
+	}
+	walletClient.SetTxPageSize(wm.Config.txpagesize)
+
+	wm.Config.unscanmaxattempts = c.DefaultInt("unscanmaxattempts", DefaultUnscanMaxAttempts)
+
+	unscanretrybackoff := c.String("unscanretrybackoff")
+	if len(unscanretrybackoff) > 0 {
+		wm.Config.unscanretrybackoff, err = time.ParseDuration(unscanretrybackoff)
+		if err != nil {
+			return err
+		}
+	}
+
+	unscanretrymaxbackoff := c.String("unscanretrymaxbackoff")
+	if len(unscanretrymaxbackoff) > 0 {
+		wm.Config.unscanretrymaxbackoff, err = time.ParseDuration(unscanretrymaxbackoff)
+		if err != nil {
+			return err
+		}
+	}
+
+	wm.Config.blockretentioncount = uint64(c.DefaultInt64("blockretentioncount", 0))
+
+	blockretentionperiod := c.String("blockretentionperiod")
+	if len(blockretentionperiod) > 0 {
+		wm.Config.blockretentionperiod, err = time.ParseDuration(blockretentionperiod)
+		if err != nil {
+			return err
+		}
+	}
+
+	blockpruneperiod := c.String("blockpruneperiod")
+	if len(blockpruneperiod) > 0 {
+		wm.Config.blockpruneperiod, err = time.ParseDuration(blockpruneperiod)
+		if err != nil {
+			return err
+		}
+	}
+
+	if wm.Config.enableserver {
+		if wm.IsFeatureEnabled(FeatureServer) {
+			wm.server, err = NewServer(wm)
+			if err != nil {
+				return err
+			}
+			wm.server.Listen()
+		}
 	} else {
 		wm.client, err = NewClient(wm)
 		if err != nil {
@@ -100,7 +570,8 @@ func (wm *WalletManager) LoadAssetsConfig(c config.Configer) error {
 		}
 	}
 
-	//建立日志文件夹
+	//建立数据库和日志文件夹
+	file.MkdirAll(wm.Config.dbPath)
 	file.MkdirAll(wm.Config.logdir)
 	file.MkdirAll(wm.Config.walletdatabackupdir)
 
@@ -115,6 +586,39 @@ func (wm *WalletManager) LoadAssetsConfig(c config.Configer) error {
 	wm.SetupLog(wm.Config.logdir, logfile, wm.Config.logdebug)
 	owtp.Debug = wm.Config.logdebug
 
+	//启动本地区块清理任务
+	wm.StartBlockPruner()
+
+	//启动钱包API节点健康检查
+	wm.StartWalletEndpointChecker()
+
+	//探测钱包API版本
+	go wm.NegotiateWalletAPIVersion()
+
+	//启动监控指标服务
+	wm.StartMetricsServer()
+
+	//启动钱包事件订阅
+	wm.StartWalletEventListener()
+
+	//启动提现队列
+	wm.StartWithdrawalQueue()
+
+	//启动碎片UTXO合并
+	wm.StartDustConsolidator()
+
+	//启动发送超时交易单检查
+	wm.StartExpiredTxWatcher()
+
+	//启动交易单重发
+	wm.StartTxResender()
+
+	//启动充值地址池补充
+	wm.StartAddressPool()
+
+	//启动地址过期检查
+	wm.StartAddressExpiryWatcher()
+
 	return nil
 }
 
@@ -132,3 +636,17 @@ func (wm *WalletManager) GetAssetsLogger() *log.OWLogger {
 func (wm *WalletManager) GetSmartContractDecoder() openwallet.SmartContractDecoder {
 	return wm.ContractDecoder
 }
+
+//IsFeatureEnabled 功能模块是否开启
+func (wm *WalletManager) IsFeatureEnabled(name string) bool {
+	return wm.Config.features[name]
+}
+
+//GetCapabilities 获取功能模块开关状态
+func (wm *WalletManager) GetCapabilities() map[string]bool {
+	capabilities := make(map[string]bool, len(wm.Config.features))
+	for name, enabled := range wm.Config.features {
+		capabilities[name] = enabled
+	}
+	return capabilities
+}
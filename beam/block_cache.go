@@ -0,0 +1,103 @@
+package beam
+
+import (
+	"container/list"
+	"sync"
+)
+
+//BlockCache 最近区块的LRU缓存，按高度和hash索引，减少重复请求浏览器API
+type BlockCache struct {
+	mu       sync.Mutex
+	size     int
+	ll       *list.List
+	byHeight map[uint64]*list.Element
+	byHash   map[string]*list.Element
+}
+
+//NewBlockCache 创建区块缓存，size为0表示不缓存
+func NewBlockCache(size int) *BlockCache {
+	return &BlockCache{
+		size:     size,
+		ll:       list.New(),
+		byHeight: make(map[uint64]*list.Element),
+		byHash:   make(map[string]*list.Element),
+	}
+}
+
+//Add 缓存区块，相同高度的旧区块会被替换
+func (c *BlockCache) Add(block *Block) {
+	if c.size <= 0 || block == nil || len(block.Hash) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.byHeight[block.Height]; ok {
+		c.removeElement(e)
+	}
+	if e, ok := c.byHash[block.Hash]; ok {
+		c.removeElement(e)
+	}
+
+	e := c.ll.PushFront(block)
+	c.byHeight[block.Height] = e
+	c.byHash[block.Hash] = e
+
+	for c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+//GetByHeight 按高度查找缓存的区块
+func (c *BlockCache) GetByHeight(height uint64) (*Block, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.byHeight[height]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*Block), true
+}
+
+//GetByHash 按hash查找缓存的区块
+func (c *BlockCache) GetByHash(hash string) (*Block, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.byHash[hash]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*Block), true
+}
+
+//Remove 删除高度的缓存，分叉时调用
+func (c *BlockCache) Remove(height uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.byHeight[height]; ok {
+		c.removeElement(e)
+	}
+}
+
+//Len 缓存的区块数量
+func (c *BlockCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *BlockCache) removeElement(e *list.Element) {
+	block := c.ll.Remove(e).(*Block)
+	if c.byHeight[block.Height] == e {
+		delete(c.byHeight, block.Height)
+	}
+	if c.byHash[block.Hash] == e {
+		delete(c.byHash, block.Hash)
+	}
+}
@@ -0,0 +1,45 @@
+package beam
+
+import (
+	"testing"
+)
+
+func TestBlockCache(t *testing.T) {
+
+	c := NewBlockCache(2)
+	c.Add(&Block{Height: 1, Hash: "a"})
+	c.Add(&Block{Height: 2, Hash: "b"})
+
+	//访问高度1，高度2变为最久未使用
+	if _, ok := c.GetByHeight(1); !ok {
+		t.Errorf("block height 1 should be cached")
+	}
+
+	c.Add(&Block{Height: 3, Hash: "c"})
+	if _, ok := c.GetByHash("b"); ok {
+		t.Errorf("block hash b should be evicted")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+
+	//分叉后相同高度的区块替换旧区块
+	c.Add(&Block{Height: 3, Hash: "d"})
+	if _, ok := c.GetByHash("c"); ok {
+		t.Errorf("block hash c should be replaced")
+	}
+	if block, ok := c.GetByHeight(3); !ok || block.Hash != "d" {
+		t.Errorf("block height 3 should be d")
+	}
+
+	c.Remove(3)
+	if _, ok := c.GetByHash("d"); ok {
+		t.Errorf("block hash d should be removed")
+	}
+
+	disabled := NewBlockCache(0)
+	disabled.Add(&Block{Height: 1, Hash: "a"})
+	if disabled.Len() != 0 {
+		t.Errorf("disabled cache should be empty")
+	}
+}
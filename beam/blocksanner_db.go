@@ -3,8 +3,11 @@ package beam
 import (
 	"fmt"
 	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/blocktree/openwallet/timer"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 //GetLocalNewBlock 获取本地记录的区块高度和hash
@@ -52,8 +55,72 @@ func (wm *WalletManager) SaveLocalBlock(block *Block) {
 	defer db.Close()
 
 	db.Save(block)
+
+	//记录hash到高度的索引，本地区块被清理后仍可按hash查找
+	db.From(blockchainBucket).Set(blockHashIndexBucket, block.Hash, &block.Height)
+}
+
+//GetBlockHeightByHash 通过本地索引查找区块hash对应的高度
+func (wm *WalletManager) GetBlockHeightByHash(hash string) (uint64, error) {
+
+	var height uint64
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	err = db.From(blockchainBucket).Get(blockHashIndexBucket, hash, &height)
+	if err != nil {
+		return 0, err
+	}
+
+	return height, nil
+}
+
+//DeleteBlockHashIndex 删除区块hash索引，分叉的区块不再有效
+func (wm *WalletManager) DeleteBlockHashIndex(hash string) error {
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.From(blockchainBucket).Delete(blockHashIndexBucket, hash)
+}
+
+//SaveSendTxID 记录业务订单号对应的交易ID，发送前记录，重发时使用相同的交易ID
+func (wm *WalletManager) SaveSendTxID(sid, txID string) error {
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Set(sendTxIDBucket, sid, &txID)
 }
 
+//GetSendTxID 获取业务订单号对应的交易ID
+func (wm *WalletManager) GetSendTxID(sid string) (string, error) {
+
+	var txID string
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	err = db.Get(sendTxIDBucket, sid, &txID)
+	if err != nil {
+		return "", err
+	}
+
+	return txID, nil
+}
 
 //GetLocalBlock 获取本地区块数据
 func (wm *WalletManager) GetLocalBlock(height uint64) (*Block, error) {
@@ -76,6 +143,83 @@ func (wm *WalletManager) GetLocalBlock(height uint64) (*Block, error) {
 	return &block, nil
 }
 
+//PruneLocalBlocks 按保留策略清理本地区块，分叉检测只需要最近的区块
+//同时配置了数量和时长时，只清理两者都超出的区块
+//@return 清理的区块数量
+func (wm *WalletManager) PruneLocalBlocks() (int, error) {
+
+	var (
+		blockHeight uint64
+		matchers    = make([]q.Matcher, 0)
+	)
+
+	if wm.Config.blockretentioncount == 0 && wm.Config.blockretentionperiod == 0 {
+		return 0, nil
+	}
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	db.Get(blockchainBucket, "blockHeight", &blockHeight)
+
+	if wm.Config.blockretentioncount > 0 {
+		if blockHeight <= wm.Config.blockretentioncount {
+			return 0, nil
+		}
+		matchers = append(matchers, q.Lte("Height", blockHeight-wm.Config.blockretentioncount))
+	}
+
+	if wm.Config.blockretentionperiod > 0 {
+		matchers = append(matchers, q.Lt("Time", time.Now().Add(-wm.Config.blockretentionperiod).Unix()))
+	}
+
+	query := db.Select(q.And(matchers...))
+	count, err := query.Count(new(Block))
+	if err != nil {
+		return 0, err
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	err = query.Delete(new(Block))
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+//StartBlockPruner 启动后台区块清理任务，未配置保留策略时不启动
+func (wm *WalletManager) StartBlockPruner() {
+
+	if wm.Config.blockretentioncount == 0 && wm.Config.blockretentionperiod == 0 {
+		return
+	}
+
+	if wm.blockPruner != nil {
+		return
+	}
+
+	wm.Log.Infof("The timer for block pruner start now. Execute by every %v seconds.", wm.Config.blockpruneperiod.Seconds())
+
+	wm.blockPruner = timer.NewTask(wm.Config.blockpruneperiod, func() {
+		count, err := wm.PruneLocalBlocks()
+		if err != nil {
+			wm.Log.Errorf("prune local blocks unexpected error: %v", err)
+			return
+		}
+		if count > 0 {
+			wm.Log.Infof("prune local blocks: %d", count)
+		}
+	})
+	wm.blockPruner.Start()
+}
+
 //DeleteUnscanRecord 删除指定高度的未扫记录
 func (wm *WalletManager) DeleteUnscanRecord(height uint64) error {
 	//获取本地区块高度
@@ -118,9 +262,116 @@ func (bs *BEAMBlockScanner) SaveUnscanRecord(record *UnscanRecord) error {
 	}
 	defer db.Close()
 
+	//已存在的记录保留重试状态，避免重复失败时计数被重置
+	var exist UnscanRecord
+	if findErr := db.One("ID", record.ID, &exist); findErr == nil {
+		record.Attempts = exist.Attempts
+		record.NextRetryTime = exist.NextRetryTime
+		record.Dead = exist.Dead
+		record.CreateTime = exist.CreateTime
+	}
+
 	return db.Save(record)
 }
 
+//MarkUnscanRecordFailed 记录指定高度重扫失败，按指数退避计算下次重试时间，超过最大次数进入死信状态
+func (wm *WalletManager) MarkUnscanRecordFailed(height uint64, reason string) error {
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var list []*UnscanRecord
+	err = db.Find("BlockHeight", height, &list)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, r := range list {
+		r.Attempts++
+		if len(reason) > 0 {
+			r.Reason = reason
+		}
+		if r.Attempts >= wm.Config.unscanmaxattempts {
+			r.Dead = true
+			wm.Log.Warningf("unscan record of block height: %d has failed %d times, move to dead letter", r.BlockHeight, r.Attempts)
+		} else {
+			r.NextRetryTime = now.Add(unscanRetryDelay(r.Attempts, wm.Config.unscanretrybackoff, wm.Config.unscanretrymaxbackoff)).Unix()
+		}
+		db.Save(r)
+	}
+
+	return nil
+}
+
+//GetDeadUnscanRecords 获取超过最大重试次数的死信记录
+func (wm *WalletManager) GetDeadUnscanRecords() ([]*UnscanRecord, error) {
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*UnscanRecord
+	err = db.Find("Dead", true, &list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+//RequeueDeadUnscanRecords 重置死信记录，重新加入重扫队列。ids为空时重置全部死信记录
+func (wm *WalletManager) RequeueDeadUnscanRecords(ids ...string) error {
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var list []*UnscanRecord
+	err = db.Find("Dead", true, &list)
+	if err != nil {
+		if err == storm.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	filter := make(map[string]bool)
+	for _, id := range ids {
+		filter[id] = true
+	}
+
+	for _, r := range list {
+		if len(filter) > 0 && !filter[r.ID] {
+			continue
+		}
+		r.Dead = false
+		r.Attempts = 0
+		r.NextRetryTime = 0
+		db.Save(r)
+	}
+
+	return nil
+}
+
+//unscanRetryDelay 计算第attempts次失败后的等待时间
+func unscanRetryDelay(attempts int, backoff, maxBackoff time.Duration) time.Duration {
+	delay := backoff
+	for i := 1; i < attempts; i++ {
+		delay = delay * 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
 //获取未扫记录
 func (wm *WalletManager) GetUnscanRecords() ([]*UnscanRecord, error) {
 	//获取本地区块高度
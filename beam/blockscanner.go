@@ -1,15 +1,20 @@
 package beam
 
 import (
+	"context"
 	"fmt"
 	"github.com/blocktree/openwallet/common"
 	"github.com/blocktree/openwallet/openwallet"
 	"github.com/shopspring/decimal"
 	"math/big"
+	"sync"
+	"time"
 )
 
 const (
-	blockchainBucket = "blockchain" // blockchain dataset
+	blockchainBucket     = "blockchain"     // blockchain dataset
+	blockHashIndexBucket = "blockHashIndex" // block hash => height, nested in blockchain bucket
+	sendTxIDBucket       = "sendTxID"       // openwallet sid => beam txId
 	//periodOfTask      = 5 * time.Second // task interval
 	maxExtractingSize = 10 // thread count
 )
@@ -18,10 +23,15 @@ const (
 type BEAMBlockScanner struct {
 	*openwallet.BlockScannerBase
 
-	CurrentBlockHeight   uint64         //当前区块高度
-	extractingCH         chan struct{}  //扫描工作令牌
-	wm                   *WalletManager //钱包管理者
-	RescanLastBlockCount uint64         //重扫上N个区块数量
+	CurrentBlockHeight   uint64                //当前区块高度
+	extractingCH         chan struct{}         //扫描工作令牌
+	wm                   *WalletManager        //钱包管理者
+	RescanLastBlockCount uint64                //重扫上N个区块数量
+	rate                 scanRate              //扫描速度统计
+	ctx                  context.Context       //扫描上下文，停止扫描时取消
+	cancel               context.CancelFunc    //取消扫描上下文
+	taskWG               sync.WaitGroup        //运行中的扫描任务
+	scanTargetFuncV2     BlockScanTargetFuncV2 //扫描目标查找函数v2
 }
 
 //ExtractResult 扫描完成的提取结果
@@ -32,6 +42,11 @@ type ExtractResult struct {
 	Success     bool
 }
 
+//ExtractData 提取结果，key为订阅的数据源标识
+func (r ExtractResult) ExtractData() map[string][]*openwallet.TxExtractData {
+	return r.extractData
+}
+
 //SaveResult result
 type SaveResult struct {
 	TxID        string
@@ -63,7 +78,8 @@ func (bs *BEAMBlockScanner) GetBalanceByAddress(address ...string) ([]*openwalle
 		return nil, err
 	}
 
-	confirmBalance := common.IntToDecimals(int64(wallet.Available), bs.wm.Decimal())
+	//可用余额扣除提现队列中未广播的提现预留的余额
+	confirmBalance := common.IntToDecimals(int64(bs.wm.unreservedBalance(wallet.Available)), bs.wm.Decimal())
 	unconfirmedBalance := common.IntToDecimals(int64(wallet.Receiving), bs.wm.Decimal())
 	balance := confirmBalance.Add(unconfirmedBalance)
 
@@ -73,6 +89,10 @@ func (bs *BEAMBlockScanner) GetBalanceByAddress(address ...string) ([]*openwalle
 		ConfirmBalance:   confirmBalance.String(),
 		UnconfirmBalance: unconfirmedBalance.String(),
 	}
+	//账户模型按账户别名查询，余额为整个钱包的余额
+	if bs.wm.isAccountModel() && len(address) > 0 {
+		b.Address = address[0]
+	}
 
 	return []*openwallet.Balance{b}, nil
 }
@@ -133,11 +153,52 @@ func (bs *BEAMBlockScanner) SetRescanBlockHeight(height uint64) error {
 }
 
 func (bs *BEAMBlockScanner) GetBlockByHash(hash string) (*Block, error) {
-	return bs.wm.walletClient.GetBlockByHash(hash)
+	if block, ok := bs.wm.blockCache.GetByHash(hash); ok {
+		return block, nil
+	}
+
+	//优先使用扫描时记录的本地索引，按高度获取区块
+	if height, err := bs.wm.GetBlockHeightByHash(hash); err == nil {
+		block, err := bs.getCachedBlockByHeight(bs.context(), height)
+		if err == nil {
+			if block.Hash == hash {
+				return block, nil
+			}
+			//索引的区块已分叉
+			bs.wm.DeleteBlockHashIndex(hash)
+			return nil, fmt.Errorf("block hash: %s has been forked on height: %d", hash, height)
+		}
+	}
+
+	block, err := bs.wm.walletClient.GetBlockByHash(bs.context(), hash)
+	if err != nil {
+		return nil, err
+	}
+	bs.wm.blockCache.Add(block)
+	return block, nil
 }
 
+//GetBlockByHeight 从浏览器API获取区块，并更新区块缓存
 func (bs *BEAMBlockScanner) GetBlockByHeight(height uint64) (*Block, error) {
-	return bs.wm.walletClient.GetBlockByHeight(height)
+	return bs.getBlockByHeight(bs.context(), height)
+}
+
+//getBlockByHeight 使用ctx获取区块并加入缓存
+func (bs *BEAMBlockScanner) getBlockByHeight(ctx context.Context, height uint64) (*Block, error) {
+	block, err := bs.wm.walletClient.GetBlockByHeight(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	bs.wm.blockCache.Add(block)
+	return block, nil
+}
+
+//getCachedBlockByHeight 优先使用缓存的区块，用于已扫描过的高度
+func (bs *BEAMBlockScanner) getCachedBlockByHeight(ctx context.Context, height uint64) (*Block, error) {
+	if block, ok := bs.wm.blockCache.GetByHeight(height); ok {
+		return block, nil
+	}
+	return bs.getBlockByHeight(ctx, height)
 }
 
 //GetScannedBlockHeader 获取当前扫描的区块头
@@ -192,14 +253,38 @@ func (bs *BEAMBlockScanner) GetGlobalMaxBlockHeight() uint64 {
 
 //GetTransaction
 func (bs *BEAMBlockScanner) GetTransaction(hash string) (*Transaction, error) {
-	return bs.wm.walletClient.GetTransaction(hash)
+	return bs.wm.walletClient.GetTransaction(bs.context(), hash)
 }
 
 //ScanBlockTask 扫描任务
 func (bs *BEAMBlockScanner) ScanBlockTask() {
 
+	if !bs.wm.IsFeatureEnabled(FeatureScanner) {
+		bs.wm.Log.Debug("block scanner feature is disabled")
+		return
+	}
+
+	bs.taskWG.Add(1)
+	defer bs.taskWG.Done()
+
+	//钱包API熔断中，探测恢复前暂停扫描
+	if err := bs.wm.probeCircuit(bs.context()); err != nil {
+		bs.wm.Log.Std.Info("block scanner paused, wallet api is unavailable: %v", err)
+		return
+	}
+
 	//:清除超时的交易单
 	bs.wm.ClearExpireTx()
+	//释放已结束交易占用的UTXO
+	if err := bs.wm.ReleaseUtxoLocks(); err != nil {
+		bs.wm.Log.Std.Info("release utxo locks failed; unexpected error: %v", err)
+	}
+	//收款地址过期导致收款失败时创建替换地址
+	if bs.wm.IsFeatureEnabled(FeatureAddressExpiry) {
+		if _, err := bs.wm.ReissueExpiredReceiveAddresses(); err != nil {
+			bs.wm.Log.Std.Info("reissue expired receive addresses failed; unexpected error: %v", err)
+		}
+	}
 
 	//获取本地区块高度
 	blockHeader, err := bs.GetScannedBlockHeader()
@@ -213,8 +298,14 @@ func (bs *BEAMBlockScanner) ScanBlockTask() {
 
 	for {
 
-		if !bs.Scanning {
-			//区块扫描器已暂停，马上结束本次任务
+		if !bs.Scanning || bs.context().Err() != nil {
+			//区块扫描器已暂停或停止，当前区块已完成，马上结束本次任务
+			return
+		}
+
+		if bs.wm.circuitOpen() {
+			//钱包API熔断，不再继续扫描产生未扫记录，下次任务探测恢复后继续
+			bs.wm.Log.Std.Info("block scanner paused on height: %d, wallet api circuit is open", currentHeight)
 			return
 		}
 
@@ -226,6 +317,12 @@ func (bs *BEAMBlockScanner) ScanBlockTask() {
 			break
 		}
 
+		if maxHeight > currentHeight {
+			bs.wm.Metrics.ScannerLag.Set(float64(maxHeight - currentHeight))
+		} else {
+			bs.wm.Metrics.ScannerLag.Set(0)
+		}
+
 		//是否已到最新高度
 		if currentHeight >= maxHeight {
 			bs.wm.Log.Std.Info("block scanner has scanned full chain data. Current height: %d", maxHeight)
@@ -234,9 +331,22 @@ func (bs *BEAMBlockScanner) ScanBlockTask() {
 
 		//继续扫描下一个区块
 		currentHeight = currentHeight + 1
+		blockStartTime := time.Now()
 
 		bs.wm.Log.Std.Info("block scanner scanning height: %d ...", currentHeight)
 
+		//追块时批量预取后续区块的交易单，maxHeight是钱包已同步的高度
+		if batch := bs.wm.Config.rpcbatchsize; batch > 1 && maxHeight > currentHeight {
+			to := currentHeight + batch - 1
+			if to > maxHeight {
+				to = maxHeight
+			}
+			err = bs.wm.PrefetchTransactions(bs.context(), currentHeight, to)
+			if err != nil {
+				bs.wm.Log.Std.Warn("prefetch transactions from height: %d to %d failed, unexpected error: %v", currentHeight, to, err)
+			}
+		}
+
 		block, err := bs.GetBlockByHeight(currentHeight)
 		if err != nil {
 			bs.wm.Log.Std.Info("block scanner can not get new block data; unexpected error: %v", err)
@@ -282,6 +392,10 @@ func (bs *BEAMBlockScanner) ScanBlockTask() {
 			//bs.DeleteRechargesByHeight(currentHeight - 1)
 			//删除上一区块链的未扫记录
 			bs.wm.DeleteUnscanRecord(currentHeight - 1)
+			//删除分叉区块的缓存
+			bs.wm.blockCache.Remove(currentHeight - 1)
+			//删除预取的交易单
+			bs.wm.txPrefetch.reset()
 			currentHeight = currentHeight - 2 //倒退2个区块重新扫描
 			if currentHeight <= 0 {
 				currentHeight = 1
@@ -320,7 +434,7 @@ func (bs *BEAMBlockScanner) ScanBlockTask() {
 
 		} else {
 
-			err = bs.BatchExtractTransaction(block.Height, block.Hash)
+			err = bs.extractBlock(block)
 			if err != nil {
 				bs.wm.Log.Std.Info("block scanner can not extractRechargeRecords; unexpected error: %v", err)
 				return
@@ -333,6 +447,10 @@ func (bs *BEAMBlockScanner) ScanBlockTask() {
 			bs.wm.SaveLocalNewBlock(currentHeight, currentHash)
 			bs.wm.SaveLocalBlock(block)
 
+			bs.wm.Metrics.BlocksScanned.Inc()
+			bs.rate.record(time.Now())
+			bs.wm.Metrics.BlockScanDuration.Observe(time.Since(blockStartTime).Seconds())
+
 			isFork = false
 
 			//通知新区块给观测者，异步处理
@@ -341,6 +459,10 @@ func (bs *BEAMBlockScanner) ScanBlockTask() {
 
 	}
 
+	if bs.context().Err() != nil {
+		return
+	}
+
 	//重扫前N个块，为保证记录找到
 	for i := currentHeight - bs.RescanLastBlockCount; i < currentHeight; i++ {
 		bs.scanBlock(i)
@@ -351,6 +473,58 @@ func (bs *BEAMBlockScanner) ScanBlockTask() {
 
 }
 
+//Run 运行扫描器，创建新的扫描上下文
+func (bs *BEAMBlockScanner) Run() error {
+	bs.Mu.Lock()
+	bs.ctx, bs.cancel = context.WithCancel(context.Background())
+	bs.Mu.Unlock()
+	return bs.BlockScannerBase.Run()
+}
+
+//Stop 停止扫描，取消扫描上下文，等待正在处理的区块完成
+func (bs *BEAMBlockScanner) Stop() error {
+	return bs.Shutdown(context.Background())
+}
+
+//Shutdown 停止扫描，等待正在处理的区块完成，ctx超时后不再等待
+//未完成提取的区块不会保存扫描高度，下次启动会重新扫描
+func (bs *BEAMBlockScanner) Shutdown(ctx context.Context) error {
+
+	bs.Mu.Lock()
+	if bs.cancel != nil {
+		bs.cancel()
+	}
+	bs.Mu.Unlock()
+
+	err := bs.BlockScannerBase.Stop()
+	if err != nil {
+		return err
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		bs.taskWG.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//context 当前扫描上下文
+func (bs *BEAMBlockScanner) context() context.Context {
+	bs.Mu.RLock()
+	defer bs.Mu.RUnlock()
+	if bs.ctx == nil {
+		return context.Background()
+	}
+	return bs.ctx
+}
+
 //ScanBlock 扫描指定高度区块
 func (bs *BEAMBlockScanner) ScanBlock(height uint64) error {
 
@@ -380,7 +554,7 @@ func (bs *BEAMBlockScanner) scanBlock(height uint64) (*Block, error) {
 
 	bs.wm.Log.Std.Info("block scanner scanning height: %d ...", block.Height)
 
-	err = bs.BatchExtractTransaction(block.Height, block.Hash)
+	err = bs.extractBlock(block)
 	if err != nil {
 		bs.wm.Log.Std.Info("block scanner can not extractRechargeRecords; unexpected error: %v", err)
 	}
@@ -400,9 +574,18 @@ func (bs *BEAMBlockScanner) RescanFailedRecord() {
 		bs.wm.Log.Std.Info("block scanner can not get rescan data; unexpected error: %v", err)
 	}
 
+	bs.wm.Metrics.UnscanRecords.Set(float64(len(list)))
+
+	now := time.Now().Unix()
+
 	//组合成批处理
 	for _, r := range list {
 
+		//死信记录和未到重试时间的记录跳过
+		if r.Dead || r.NextRetryTime > now {
+			continue
+		}
+
 		if _, exist := blockMap[r.BlockHeight]; !exist {
 			blockMap[r.BlockHeight] = make([]string, 0)
 		}
@@ -423,15 +606,17 @@ func (bs *BEAMBlockScanner) RescanFailedRecord() {
 
 		bs.wm.Log.Std.Info("block scanner rescanning height: %d ...", height)
 
-		block, err := bs.GetBlockByHeight(height)
+		block, err := bs.getCachedBlockByHeight(bs.context(), height)
 		if err != nil {
 			bs.wm.Log.Std.Info("block scanner can not get new block data; unexpected error: %v", err)
+			bs.wm.MarkUnscanRecordFailed(height, err.Error())
 			continue
 		}
 
-		err = bs.BatchExtractTransaction(height, block.Hash)
+		err = bs.extractBlock(block)
 		if err != nil {
 			bs.wm.Log.Std.Info("block scanner can not extractRechargeRecords; unexpected error: %v", err)
+			bs.wm.MarkUnscanRecordFailed(height, err.Error())
 			continue
 		}
 
@@ -459,10 +644,11 @@ func (bs *BEAMBlockScanner) BatchExtractTransaction(blockHeight uint64, blockHas
 		done       = 0 //完成标记
 		failed     = 0
 		shouldDone = 0 //需要完成的总数
+		ctx        = bs.context()
 	)
 
 	// 获取查找本地交易单和远程服务上的交易单
-	txs, err := bs.wm.GetTransactionsByHeight(blockHeight)
+	txs, err := bs.wm.GetTransactionsByHeight(ctx, blockHeight)
 	if err != nil {
 		return err
 	}
@@ -486,20 +672,7 @@ func (bs *BEAMBlockScanner) BatchExtractTransaction(blockHeight uint64, blockHas
 		//回收创建的地址
 		for gets := range result {
 
-			if gets.Success {
-
-				notifyErr := bs.newExtractDataNotify(height, gets.extractData)
-				//saveErr := bs.SaveRechargeToWalletDB(height, gets.Recharges)
-				if notifyErr != nil {
-					failed++ //标记保存失败数
-					bs.wm.Log.Std.Info("newExtractDataNotify unexpected error: %v", notifyErr)
-				}
-
-			} else {
-				//记录未扫区块
-				unscanRecord := NewUnscanRecord(height, "", "")
-				bs.SaveUnscanRecord(unscanRecord)
-				bs.wm.Log.Std.Info("block height: %d extract failed.", height)
+			if !bs.saveExtractResult(height, gets) {
 				failed++ //标记保存失败数
 			}
 			//累计完成的线程数
@@ -519,7 +692,7 @@ func (bs *BEAMBlockScanner) BatchExtractTransaction(blockHeight uint64, blockHas
 			go func(mBlockHeight uint64, mTx *Transaction, end chan struct{}, mProducer chan<- ExtractResult) {
 
 				//导出提出的交易
-				mProducer <- bs.ExtractTransaction(mBlockHeight, eBlockHash, mTx, bs.ScanTargetFunc)
+				mProducer <- bs.ExtractTransaction(ctx, mBlockHeight, eBlockHash, mTx, bs.scanTargetFunc())
 				//释放
 				<-end
 
@@ -547,6 +720,128 @@ func (bs *BEAMBlockScanner) BatchExtractTransaction(blockHeight uint64, blockHas
 	//return nil
 }
 
+//StreamExtractTransaction 分页拉取交易单，直接送入提取线程池，不一次性加载整个区块的交易单
+//用于交易数量异常多的大区块，保持内存平稳
+func (bs *BEAMBlockScanner) StreamExtractTransaction(blockHeight uint64, blockHash string) error {
+
+	var (
+		wg       sync.WaitGroup
+		failed   = 0
+		seen     = make(map[string]bool)
+		pageSize = bs.wm.Config.txpagesize
+		results  = make(chan ExtractResult)
+		saved    = make(chan struct{})
+		ctx      = bs.context()
+	)
+
+	//保存工作，单线程通知观测者
+	go func() {
+		for gets := range results {
+			if !bs.saveExtractResult(blockHeight, gets) {
+				failed++
+			}
+		}
+		close(saved)
+	}()
+
+	//提取工作，使用扫描工作令牌控制并发
+	extract := func(tx *Transaction) {
+		if seen[tx.TxID] {
+			return
+		}
+		seen[tx.TxID] = true
+		bs.extractingCH <- struct{}{}
+		wg.Add(1)
+		go func(mTx *Transaction) {
+			defer func() {
+				<-bs.extractingCH
+				wg.Done()
+			}()
+			results <- bs.ExtractTransaction(ctx, blockHeight, blockHash, mTx, bs.scanTargetFunc())
+		}(tx)
+	}
+
+	finish := func() {
+		wg.Wait()
+		close(results)
+		<-saved
+	}
+
+	//本地钱包分页拉取
+	it := bs.wm.walletClient.IterateTransactions(HeightFilter(blockHeight), pageSize)
+	for !it.Done() {
+		txs, err := it.Next(ctx)
+		if err != nil {
+			finish()
+			return err
+		}
+		for _, tx := range txs {
+			extract(tx)
+		}
+	}
+
+	//远程服务的交易单
+	if bs.wm.client != nil {
+		remoteTxs, err := bs.wm.client.GetTransactionsByHeight(blockHeight)
+		if err != nil {
+			finish()
+			return err
+		}
+		for _, tx := range remoteTxs {
+			extract(tx)
+		}
+	}
+
+	finish()
+
+	if failed > 0 {
+		return fmt.Errorf("block scanner saveWork failed")
+	}
+
+	return nil
+}
+
+//extractBlock 提取区块交易单，交易数量超过阈值的大区块使用分页流式提取
+func (bs *BEAMBlockScanner) extractBlock(block *Block) error {
+	//挖矿奖励没有钱包交易单，空区块也需要提取
+	bs.extractCoinbase(block)
+
+	//空区块不需要查询交易单，直接跳过
+	if block.Empty {
+		bs.wm.Log.Std.Debug("block height: %d is empty, skip extracting", block.Height)
+		return nil
+	}
+
+	threshold := bs.wm.Config.largeblockthreshold
+	if threshold > 0 && block.KernelCount > threshold {
+		bs.wm.Log.Std.Info("block height: %d has %d kernels, extract by streaming", block.Height, block.KernelCount)
+		return bs.StreamExtractTransaction(block.Height, block.Hash)
+	}
+	return bs.BatchExtractTransaction(block.Height, block.Hash)
+}
+
+//saveExtractResult 处理提取结果，通知观测者，失败时记录未扫区块
+func (bs *BEAMBlockScanner) saveExtractResult(height uint64, gets ExtractResult) bool {
+
+	if gets.Success {
+
+		bs.wm.Metrics.TxExtracted.Inc()
+		notifyErr := bs.newExtractDataNotify(height, gets.extractData)
+		if notifyErr != nil {
+			bs.wm.Log.Std.Info("newExtractDataNotify unexpected error: %v", notifyErr)
+			return false
+		}
+		return true
+	}
+
+	//记录未扫区块
+	unscanRecord := NewUnscanRecord(height, "", "")
+	bs.SaveUnscanRecord(unscanRecord)
+	bs.wm.Log.Std.Info("block height: %d extract failed.", height)
+	bs.wm.Metrics.ExtractFailures.Inc()
+	return false
+}
+
 //extractRuntime 提取运行时
 func (bs *BEAMBlockScanner) extractRuntime(producer chan ExtractResult, worker chan ExtractResult, quit chan struct{}) {
 
@@ -583,8 +878,8 @@ func (bs *BEAMBlockScanner) extractRuntime(producer chan ExtractResult, worker c
 
 }
 
-//提取交易单
-func (bs *BEAMBlockScanner) ExtractTransaction(blockHeight uint64, blockHash string, trx *Transaction, scanTargetFunc openwallet.BlockScanTargetFunc) ExtractResult {
+//ExtractTransaction 提取交易单，没有区块哈希时使用ctx查询区块
+func (bs *BEAMBlockScanner) ExtractTransaction(ctx context.Context, blockHeight uint64, blockHash string, trx *Transaction, scanTargetFunc openwallet.BlockScanTargetFunc) ExtractResult {
 	var (
 		success = true
 		result  = ExtractResult{
@@ -595,7 +890,7 @@ func (bs *BEAMBlockScanner) ExtractTransaction(blockHeight uint64, blockHash str
 	)
 
 	if len(blockHash) == 0 {
-		block, err := bs.GetBlockByHeight(trx.BlockHeight)
+		block, err := bs.getCachedBlockByHeight(ctx, trx.BlockHeight)
 		if err == nil {
 			blockHash = block.Hash
 			blockHeight = block.Height
@@ -605,21 +900,53 @@ func (bs *BEAMBlockScanner) ExtractTransaction(blockHeight uint64, blockHash str
 	trx.BlockHeight = blockHeight
 	trx.BlockHash = blockHash
 
+	//离线地址通过付款凭证收到的隐私池付款，接收方记为登记的离线充值地址
+	if bs.wm.IsFeatureEnabled(FeatureShielded) {
+		if address := bs.wm.offlineReceiver(trx); len(address) > 0 {
+			trx.Receiver = address
+		}
+	}
+
+	//付款方可能填写了充值地址的另一种形式，如SBBS钱包ID，换成登记的规范地址再匹配
+	trx.Sender = bs.wm.addressMapper.Resolve(trx.Sender)
+	trx.Receiver = bs.wm.addressMapper.Resolve(trx.Receiver)
+
+	//合约交易没有对方地址，接收方记为调用的合约ID，可订阅合约ID提取DApp的合约交易
+	if trx.TxType == TxTypeContract && len(trx.ContractIDs) > 0 && len(trx.Receiver) == 0 && bs.wm.IsFeatureEnabled(FeatureShaders) {
+		trx.Receiver = trx.ContractIDs[0]
+	}
+
+	//对方地址在extractdeny中的交易单不提取，如内部地址之间的调拨和恶意付款方
+	if bs.wm.isExtractDenied(trx) {
+		bs.wm.Log.Std.Debug("tx: %s sender: %s receiver: %s is denied, skip extracting", trx.TxID, trx.Sender, trx.Receiver)
+		result.Success = true
+		return result
+	}
+
+	//账户模型下钱包一方记为账户别名，只查询账户别名的订阅
+	accountModel := bs.wm.isAccountModel()
+	if accountModel {
+		bs.wm.accountModelTx(trx)
+	}
+
 	//提出交易单明细
 	from := trx.Sender
 	to := trx.Receiver
 
+	var (
+		accountId, accountId2 string
+		ok1, ok2              bool
+	)
+
 	//bs.wm.Log.Std.Info("block scanner scanning tx: %+v", txid)
 	//订阅地址为交易单中的发送者
-	accountId, ok1 := scanTargetFunc(openwallet.ScanTarget{
-		Address:          from,
-		BalanceModelType: openwallet.BalanceModelTypeAddress,
-	})
+	if (accountModel && !trx.Income) || (!accountModel && bs.wm.isExtractAllowed(from)) {
+		accountId, ok1 = scanTargetFunc(bs.wm.scanTarget(from))
+	}
 	//订阅地址为交易单中的接收者
-	accountId2, ok2 := scanTargetFunc(openwallet.ScanTarget{
-		Address:          to,
-		BalanceModelType: openwallet.BalanceModelTypeAddress,
-	})
+	if (accountModel && trx.Income) || (!accountModel && bs.wm.isExtractAllowed(to)) {
+		accountId2, ok2 = scanTargetFunc(bs.wm.scanTarget(to))
+	}
 
 	//相同账户
 	if accountId == accountId2 && len(accountId) > 0 && len(accountId2) > 0 {
@@ -656,15 +983,12 @@ func (bs *BEAMBlockScanner) InitExtractResult(tx *Transaction, sourceKey string,
 
 	amount := decimal.Zero
 	feeAmount := decimal.Zero
-	coin := openwallet.Coin{
-		Symbol:     bs.wm.Symbol(),
-		IsContract: false,
-	}
+	coin := bs.wm.AssetCoin(tx.AssetID)
 	value := new(big.Int)
 	value.SetUint64(tx.Value)
 	fee := new(big.Int)
 	fee.SetUint64(tx.Fee)
-	amount = common.BigIntToDecimals(value, bs.wm.Decimal())
+	amount = common.BigIntToDecimals(value, bs.wm.assetDecimals(tx.AssetID))
 	feeAmount = common.BigIntToDecimals(fee, bs.wm.Decimal())
 	transx := &openwallet.Transaction{
 		Fees:        feeAmount.String(),
@@ -672,7 +996,7 @@ func (bs *BEAMBlockScanner) InitExtractResult(tx *Transaction, sourceKey string,
 		BlockHash:   tx.BlockHash,
 		BlockHeight: tx.BlockHeight,
 		TxID:        tx.TxID,
-		Decimal:     bs.wm.Decimal(),
+		Decimal:     bs.wm.assetDecimals(tx.AssetID),
 		Amount:      amount.String(),
 		ConfirmTime: tx.CreateTime,
 		From:        []string{tx.Sender + ":" + amount.String()},
@@ -682,6 +1006,33 @@ func (bs *BEAMBlockScanner) InitExtractResult(tx *Transaction, sourceKey string,
 	}
 
 	transx.SetExtParam("kernel", tx.Kernel)
+	//交易备注，交易所用于匹配充值
+	if len(tx.Comment) > 0 {
+		transx.SetExtParam("comment", tx.Comment)
+	}
+	if tx.Coinbase {
+		transx.SetExtParam("coinbase", true)
+	}
+	if tx.AssetID != BeamAssetID {
+		transx.SetExtParam("asset_id", tx.AssetID)
+	}
+	//隐私池交易没有可追踪的对方地址
+	if tx.Shielded && bs.wm.IsFeatureEnabled(FeatureShielded) {
+		transx.SetExtParam("shielded", true)
+		transx.SetExtParam("tx_type", tx.TxType)
+	}
+	if tx.TxType == TxTypeContract && bs.wm.IsFeatureEnabled(FeatureShaders) {
+		transx.SetExtParam("tx_type", tx.TxType)
+		transx.SetExtParam("contract_ids", tx.ContractIDs)
+	}
+	//订阅地址的标签和所属账户，下游不需要再查询地址归属
+	if operate == 0 || operate == 1 {
+		bs.wm.setAddressMetadata(transx, "from", tx.Sender)
+	}
+	if operate == 0 || operate == 2 {
+		bs.wm.setAddressMetadata(transx, "to", tx.Receiver)
+		bs.wm.checkLateDeposit(transx, tx)
+	}
 
 	wxID := openwallet.GenTransactionWxID(transx)
 	transx.WxID = wxID
@@ -704,14 +1055,11 @@ func (bs *BEAMBlockScanner) InitExtractResult(tx *Transaction, sourceKey string,
 func (bs *BEAMBlockScanner) extractTxInput(tx *Transaction, txExtractData *openwallet.TxExtractData) {
 
 	amount := decimal.Zero
-	coin := openwallet.Coin{
-		Symbol:     bs.wm.Symbol(),
-		IsContract: false,
-	}
+	coin := bs.wm.AssetCoin(tx.AssetID)
 
 	value := new(big.Int)
 	value.SetUint64(tx.Value)
-	amount = common.BigIntToDecimals(value, bs.wm.Decimal())
+	amount = common.BigIntToDecimals(value, bs.wm.assetDecimals(tx.AssetID))
 
 	//主网from交易转账信息，第一个TxInput
 	txInput := &openwallet.TxInput{}
@@ -733,6 +1081,18 @@ func (bs *BEAMBlockScanner) extractTxInput(tx *Transaction, txExtractData *openw
 	tmp := *txInput
 	feeCharge := &tmp
 	feeCharge.Amount = fees.String()
+	//机密资产交易的手续费使用BEAM支付
+	feeCharge.Coin = bs.wm.AssetCoin(BeamAssetID)
+
+	if !bs.wm.Config.legacyfeeinput {
+		//手续费输入使用独立的SID，Index固定为1，避免与转账输入的SID重复
+		if tx.Fee == 0 {
+			return
+		}
+		feeCharge.Sid = openwallet.GenTxInputSID(tx.TxID, bs.wm.Symbol(), feeCharge.Coin.ContractID, FeeInputIndex)
+		feeCharge.Index = FeeInputIndex
+	}
+
 	txExtractData.TxInputs = append(txExtractData.TxInputs, feeCharge)
 }
 
@@ -740,14 +1100,11 @@ func (bs *BEAMBlockScanner) extractTxInput(tx *Transaction, txExtractData *openw
 func (bs *BEAMBlockScanner) extractTxOutput(tx *Transaction, txExtractData *openwallet.TxExtractData) {
 
 	amount := decimal.Zero
-	coin := openwallet.Coin{
-		Symbol:     bs.wm.Symbol(),
-		IsContract: false,
-	}
+	coin := bs.wm.AssetCoin(tx.AssetID)
 
 	value := new(big.Int)
 	value.SetUint64(tx.Value)
-	amount = common.BigIntToDecimals(value, bs.wm.Decimal())
+	amount = common.BigIntToDecimals(value, bs.wm.assetDecimals(tx.AssetID))
 
 	//主网to交易转账信息,只有一个TxOutPut
 	txOutput := &openwallet.TxOutPut{}
@@ -766,11 +1123,33 @@ func (bs *BEAMBlockScanner) extractTxOutput(tx *Transaction, txExtractData *open
 //newExtractDataNotify 发送通知
 //发送通知
 func (bs *BEAMBlockScanner) newExtractDataNotify(height uint64, extractData map[string][]*openwallet.TxExtractData) error {
-	for o, _ := range bs.Observers {
-		for key, array := range extractData {
-			for _, data := range array {
+	for key, array := range extractData {
+		for _, data := range array {
+
+			//迁移前已入账的交易不再通知，防止重复入账
+			if data.Transaction != nil && bs.wm.IsLegacyCredited(data.Transaction.TxID) {
+				bs.wm.Log.Std.Info("tx: %s has been credited by legacy integration, skip notify", data.Transaction.TxID)
+				continue
+			}
+
+			//低于最小充值金额的充值记录到本地数据库，默认不通知，防止粉尘攻击刷充值记录
+			if bs.wm.isDustDeposit(data) {
+				err := bs.wm.saveDustDeposit(key, data, bs.wm.Config.notifydustdeposit)
+				if err != nil {
+					bs.wm.Log.Std.Error("tx: %s save dust deposit failed, unexpected error: %v", data.Transaction.TxID, err)
+				}
+				if !bs.wm.Config.notifydustdeposit {
+					bs.wm.Log.Std.Info("tx: %s amount: %s is below mindeposit, skip notify", data.Transaction.TxID, data.Transaction.Amount)
+					continue
+				}
+				data.Transaction.SetExtParam("dust", true)
+			}
+
+			notified := true
+			for o, _ := range bs.Observers {
 				err := o.BlockExtractDataNotify(key, data)
 				if err != nil {
+					notified = false
 					bs.wm.Log.Error("BlockExtractDataNotify unexpected error:", err)
 					//记录未扫区块
 					unscanRecord := NewUnscanRecord(height, "", "ExtractData Notify failed.")
@@ -780,6 +1159,10 @@ func (bs *BEAMBlockScanner) newExtractDataNotify(height uint64, extractData map[
 					}
 				}
 			}
+
+			if notified {
+				bs.recordLedger(key, data)
+			}
 		}
 	}
 	return nil
@@ -791,6 +1174,6 @@ func (bs *BEAMBlockScanner) ExtractTransactionData(txid string, scanAddressFunc
 	if err != nil {
 		return nil, err
 	}
-	result := bs.ExtractTransaction(0, "", tx, scanAddressFunc)
+	result := bs.ExtractTransaction(bs.context(), 0, "", tx, scanAddressFunc)
 	return result.extractData, nil
 }
@@ -3,7 +3,9 @@ package beam
 import (
 	"github.com/blocktree/openwallet/log"
 	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
 	"testing"
+	"time"
 )
 
 func TestBEAMBlockScanner_GetCurrentBlock(t *testing.T) {
@@ -98,3 +100,92 @@ func TestBEAMBlockScanner_ExtractTransactionData(t *testing.T) {
 		}
 	}
 }
+
+func TestUnscanRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{10, time.Hour},
+	}
+	for _, test := range tests {
+		got := unscanRetryDelay(test.attempts, time.Minute, time.Hour)
+		if got != test.want {
+			t.Errorf("attempts %d: delay = %v, want %v", test.attempts, got, test.want)
+		}
+	}
+}
+
+func TestNewBlock_Empty(t *testing.T) {
+	tests := []struct {
+		json  string
+		empty bool
+	}{
+		{`{"height": 20516, "inputs": [], "kernels": [{"fee": 0}], "outputs": [{"coinbase": true}]}`, true},
+		{`{"height": 20517, "inputs": [{"height": 20000}], "kernels": [{"fee": 100}, {"fee": 0}], "outputs": [{"coinbase": true}, {"coinbase": false}]}`, false},
+		{`{"height": 20518}`, false},
+		//花费隐私池UTXO的交易没有普通输入
+		{`{"height": 20519, "inputs": [], "kernels": [{"fee": 0}, {"fee": 1001000}], "outputs": [{"coinbase": true}, {"coinbase": false}]}`, false},
+	}
+
+	for _, test := range tests {
+		result := gjson.Parse(test.json)
+		block := NewBlock(&result)
+		if block.Empty != test.empty {
+			t.Errorf("block height: %d Empty = %v, want %v", block.Height, block.Empty, test.empty)
+		}
+	}
+}
+
+func TestScanTargetFuncFromV2(t *testing.T) {
+	scanTargetFuncV2 := func(target ScanTargetParam) ScanTargetResult {
+		if target.Symbol != Symbol {
+			return ScanTargetResult{}
+		}
+		if target.ScanTargetType == ScanTargetTypeAccountAddress && target.ScanTarget == "receiver" {
+			return ScanTargetResult{SourceKey: "account", Exist: true}
+		}
+		if target.ScanTargetType == ScanTargetTypeAccountAlias && target.ScanTarget == "hot" {
+			return ScanTargetResult{SourceKey: "hot-account", Exist: true}
+		}
+		return ScanTargetResult{}
+	}
+
+	scanTargetFunc := ScanTargetFuncFromV2(Symbol, scanTargetFuncV2)
+
+	key, ok := scanTargetFunc(openwallet.ScanTarget{Address: "receiver", BalanceModelType: openwallet.BalanceModelTypeAddress})
+	if !ok || key != "account" {
+		t.Errorf("scanTargetFunc(receiver) = %s, %v, want account, true", key, ok)
+	}
+
+	_, ok = scanTargetFunc(openwallet.ScanTarget{Address: "unknown", BalanceModelType: openwallet.BalanceModelTypeAddress})
+	if ok {
+		t.Errorf("scanTargetFunc(unknown) should not exist")
+	}
+
+	//账户模型按账户别名查找
+	key, ok = scanTargetFunc(openwallet.ScanTarget{Address: "hot", Alias: "hot", BalanceModelType: openwallet.BalanceModelTypeAccount})
+	if !ok || key != "hot-account" {
+		t.Errorf("scanTargetFunc(hot) = %s, %v, want hot-account, true", key, ok)
+	}
+}
+
+func TestWalletManager_GetBlockHeightByHash(t *testing.T) {
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+
+	wm.SaveLocalBlock(&Block{Height: 100, Hash: "a"})
+
+	height, err := wm.GetBlockHeightByHash("a")
+	if err != nil || height != 100 {
+		t.Errorf("GetBlockHeightByHash(a) = %d, %v, want 100", height, err)
+	}
+
+	wm.DeleteBlockHashIndex("a")
+	if _, err := wm.GetBlockHeightByHash("a"); err == nil {
+		t.Errorf("block hash a should be deleted")
+	}
+}
@@ -250,6 +250,34 @@ func (c *Client) CreateBatchAddress(count, workerSize uint64) ([]string, error)
 	return addrs, retErr
 }
 
+//CreateAddressForAccount
+func (c *Client) CreateAddressForAccount(accountID string, index uint64) (string, error) {
+
+	var (
+		address string
+		retErr  error
+	)
+
+	params := map[string]interface{}{
+		"accountID": accountID,
+		"index":     index,
+	}
+
+	err := c.node.Call(trustHostID, "createAddressForAccount", params,
+		true, func(resp owtp.Response) {
+			if resp.Status == owtp.StatusSuccess {
+				address = resp.JsonData().Get("address").String()
+			} else {
+				retErr = openwallet.Errorf(resp.Status, resp.Msg)
+			}
+		})
+	if err != nil {
+		return "", err
+	}
+
+	return address, retErr
+}
+
 //GetWalletBalance
 func (c *Client) GetWalletBalance() (*openwallet.Balance, error) {
 
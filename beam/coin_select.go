@@ -0,0 +1,250 @@
+package beam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//提现选择UTXO的方式
+	CoinSelectWallet         = "wallet"      //由钱包选择
+	CoinSelectLargestFirst   = "largest"     //优先使用大额UTXO，输入最少
+	CoinSelectSmallestFirst  = "smallest"    //优先使用小额UTXO，减少碎片
+	CoinSelectBranchAndBound = "bnb"         //查找不需要找零的组合，找不到时按largest选择
+	CoinSelectConsolidate    = "consolidate" //在满足金额后继续带上小额UTXO，提现时顺便合并碎片
+
+	//bnb最多尝试的次数
+	bnbMaxTries = 100000
+)
+
+//ErrInsufficientCoins 可用的UTXO不足
+var ErrInsufficientCoins = errors.New("no enough spendable coins")
+
+//SelectCoins 按strategy从utxos中选择总额不低于target的UTXO
+func SelectCoins(strategy string, utxos []*Utxo, target uint64) ([]*Utxo, error) {
+
+	sorted := make([]*Utxo, len(utxos))
+	copy(sorted, utxos)
+
+	switch strategy {
+	case CoinSelectLargestFirst:
+		sortCoins(sorted, false)
+		return selectInOrder(sorted, target)
+	case CoinSelectSmallestFirst:
+		sortCoins(sorted, true)
+		return selectInOrder(sorted, target)
+	case CoinSelectBranchAndBound:
+		sortCoins(sorted, false)
+		if selected := selectBranchAndBound(sorted, target, FeeOutput); selected != nil {
+			return selected, nil
+		}
+		return selectInOrder(sorted, target)
+	case CoinSelectConsolidate:
+		sortCoins(sorted, true)
+		return selectConsolidate(sorted, target, MaxSplitCoins)
+	default:
+		return nil, fmt.Errorf("unknown coin selection: %s", strategy)
+	}
+}
+
+//sortCoins 按金额排序，ascending为false时从大到小
+func sortCoins(utxos []*Utxo, ascending bool) {
+	sort.SliceStable(utxos, func(i, j int) bool {
+		if ascending {
+			return utxos[i].Amount < utxos[j].Amount
+		}
+		return utxos[i].Amount > utxos[j].Amount
+	})
+}
+
+//sumCoins UTXO总额
+func sumCoins(utxos []*Utxo) uint64 {
+	var total uint64
+	for _, utxo := range utxos {
+		total += utxo.Amount
+	}
+	return total
+}
+
+//selectInOrder 按顺序选择直到满足金额
+func selectInOrder(utxos []*Utxo, target uint64) ([]*Utxo, error) {
+	var total uint64
+	for i, utxo := range utxos {
+		total += utxo.Amount
+		if total >= target {
+			return utxos[:i+1], nil
+		}
+	}
+	return nil, ErrInsufficientCoins
+}
+
+//selectBranchAndBound 深度优先查找总额在[target, target+tolerance]之间的组合，
+//utxos需要从大到小排序，找不到时返回nil
+func selectBranchAndBound(utxos []*Utxo, target, tolerance uint64) []*Utxo {
+
+	//remaining[i] 第i个及之后的UTXO总额，用于剪枝
+	remaining := make([]uint64, len(utxos)+1)
+	for i := len(utxos) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + utxos[i].Amount
+	}
+
+	var (
+		tries    int
+		selected = make([]int, 0)
+		search   func(i int, total uint64) bool
+	)
+	search = func(i int, total uint64) bool {
+		tries++
+		if total >= target {
+			return total <= target+tolerance
+		}
+		if i == len(utxos) || tries > bnbMaxTries || total+remaining[i] < target {
+			return false
+		}
+
+		//先尝试包含，再尝试不包含
+		selected = append(selected, i)
+		if search(i+1, total+utxos[i].Amount) {
+			return true
+		}
+		selected = selected[:len(selected)-1]
+		return search(i+1, total)
+	}
+
+	if !search(0, 0) {
+		return nil
+	}
+
+	result := make([]*Utxo, 0, len(selected))
+	for _, i := range selected {
+		result = append(result, utxos[i])
+	}
+	return result
+}
+
+//selectConsolidate 从小到大选择满足金额后，继续带上剩余最小的UTXO直到maxCoins个，utxos需要从小到大排序
+func selectConsolidate(utxos []*Utxo, target uint64, maxCoins int) ([]*Utxo, error) {
+
+	selected, err := selectInOrder(utxos, target)
+	if err != nil {
+		return nil, err
+	}
+
+	//输入数量超过上限时改为从大到小选择
+	if len(selected) > maxCoins {
+		largest := make([]*Utxo, len(utxos))
+		copy(largest, utxos)
+		sortCoins(largest, false)
+		return selectInOrder(largest, target)
+	}
+
+	if len(utxos) > maxCoins {
+		return utxos[:maxCoins], nil
+	}
+	return utxos, nil
+}
+
+//selectWithdrawalCoins 按配置的方式选择支付total的UTXO并锁定，返回UTXO ID，由钱包选择时返回nil，
+//交易发出后需要绑定交易ID，发送失败时需要释放
+func (wm *WalletManager) selectWithdrawalCoins(total uint64, sid string) ([]string, error) {
+
+	if wm.Config.coinselection == CoinSelectWallet || len(wm.Config.coinselection) == 0 {
+		return nil, nil
+	}
+
+	status, err := wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	assetID := int64(0)
+	filter := SpendableFilter(status.CurrentHeight)
+	filter.AssetID = &assetID
+	utxos, err := wm.walletClient.ListUTXO(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := wm.utxoLocks.selectAndLock(wm.Config.coinselection, utxos, total, sid)
+	if err != nil {
+		return nil, err
+	}
+
+	coinIDs := make([]string, 0, len(selected))
+	for _, utxo := range selected {
+		coinIDs = append(coinIDs, utxo.ID)
+	}
+
+	wm.Log.Std.Info("coin selection: %s selected %d coins, total: %d, target: %d", wm.Config.coinselection, len(selected), sumCoins(selected), total)
+	return coinIDs, nil
+}
+
+//sendWithdrawal 发送提现交易：受处理中提现数量限制，按配置选择并锁定UTXO，发送成功后UTXO锁绑定交易ID，
+//override为true时跳过提现地址白名单
+func (wm *WalletManager) sendWithdrawal(from, to string, withdrawal *WithdrawalAmount, comment, txID, sid string, override bool) (txid string, err error) {
+
+	//提现策略检查
+	done, err := wm.withdrawalPolicy.Evaluate(&WithdrawalPolicyRequest{Sid: sid, TxID: txID, From: from, To: to, AssetID: BeamAssetID, Amount: withdrawal.Send, Fee: withdrawal.Fee, Override: override})
+	if err != nil {
+		return "", err
+	}
+	//tx_send返回无法确认交易未发出的错误（如超时）时不回退当天的提现总额
+	var sendErr error
+	defer func() { done(err == nil || (sendErr != nil && !txNotSent(sendErr))) }()
+
+	//处理中的提现数量达到上限时，按配置排队、拒绝或取消最早的交易单
+	release, err := wm.withdrawals.Acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	//按配置的方式选择UTXO并锁定，交易结束前其他提现不会选中
+	coinIDs, err := wm.selectWithdrawalCoins(withdrawal.Total(), sid)
+	if err != nil {
+		return "", openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "%v", err)
+	}
+
+	txid, err = wm.walletClient.SendTransactionWithCoins(context.Background(), from, to, withdrawal.Send, withdrawal.Fee, comment, txID, coinIDs)
+	if err != nil {
+		sendErr = err
+		wm.releaseUnsentCoins(coinIDs, txID, err)
+		return "", wm.sendFailed(err, from, to, BeamAssetID, withdrawal, comment, txID, sid)
+	}
+
+	if len(coinIDs) > 0 {
+		if bindErr := wm.utxoLocks.bind(coinIDs, txid); bindErr != nil {
+			wm.Log.Errorf("bind utxo locks to tx: %s failed, unexpected error: %v", txid, bindErr)
+		}
+	}
+
+	return txid, nil
+}
+
+//releaseUnsentCoins tx_send失败后处理UTXO锁：确定交易没有发出时释放，否则钱包可能已接受交易，
+//锁绑定txID，交易完成、取消或失败后由ReleaseUtxoLocks释放
+func (wm *WalletManager) releaseUnsentCoins(coinIDs []string, txID string, sendErr error) {
+
+	if len(coinIDs) == 0 {
+		return
+	}
+
+	if txNotSent(sendErr) {
+		if unlockErr := wm.utxoLocks.unlock(coinIDs); unlockErr != nil {
+			wm.Log.Errorf("release utxo locks failed, unexpected error: %v", unlockErr)
+		}
+		return
+	}
+
+	if len(txID) == 0 {
+		wm.Log.Warningf("tx may be sent without txid, keep %d utxo locks until utxolocktimeout", len(coinIDs))
+		return
+	}
+	if bindErr := wm.utxoLocks.bind(coinIDs, txID); bindErr != nil {
+		wm.Log.Errorf("bind utxo locks to tx: %s failed, unexpected error: %v", txID, bindErr)
+	}
+}
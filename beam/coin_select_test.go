@@ -0,0 +1,117 @@
+package beam
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func testCoins(amounts ...uint64) []*Utxo {
+	utxos := make([]*Utxo, 0, len(amounts))
+	for i, amount := range amounts {
+		utxos = append(utxos, &Utxo{ID: string(rune('a' + i)), Amount: amount, Status: UtxoStatusAvailable})
+	}
+	return utxos
+}
+
+func coinIDs(utxos []*Utxo) string {
+	ids := make([]string, 0, len(utxos))
+	for _, utxo := range utxos {
+		ids = append(ids, utxo.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSelectCoins(t *testing.T) {
+
+	//a:50 b:5 c:30 d:12 e:8
+	utxos := testCoins(50, 5, 30, 12, 8)
+	tests := []struct {
+		strategy string
+		target   uint64
+		want     string
+	}{
+		{CoinSelectLargestFirst, 60, "a,c"},
+		{CoinSelectSmallestFirst, 20, "b,e,d"},
+		//超出金额不超过一个输出的手续费时不需要找零
+		{CoinSelectBranchAndBound, 50, "a"},
+		{CoinSelectBranchAndBound, 61, "a,d"},
+		{CoinSelectBranchAndBound, 38, "c,d"},
+		{CoinSelectConsolidate, 10, "b,e,d,c,a"},
+	}
+	for _, tt := range tests {
+		selected, err := SelectCoins(tt.strategy, utxos, tt.target)
+		if err != nil || coinIDs(selected) != tt.want {
+			t.Errorf("SelectCoins(%s, %d) = %s, %v, want %s", tt.strategy, tt.target, coinIDs(selected), err, tt.want)
+		}
+	}
+
+	//找不到不需要找零的组合时按largest选择
+	if selected, _ := SelectCoins(CoinSelectBranchAndBound, testCoins(3, 100), 50); coinIDs(selected) != "b" {
+		t.Errorf("SelectCoins(bnb) fallback = %s, want b", coinIDs(selected))
+	}
+
+	if _, err := SelectCoins(CoinSelectLargestFirst, utxos, 106); err != ErrInsufficientCoins {
+		t.Errorf("SelectCoins should fail with insufficient coins, got %v", err)
+	}
+	if _, err := SelectCoins("unknown", utxos, 1); err == nil {
+		t.Errorf("SelectCoins should fail with unknown strategy")
+	}
+
+	//输入的顺序不变
+	if coinIDs(utxos) != "a,b,c,d,e" {
+		t.Errorf("SelectCoins should not reorder input, got %s", coinIDs(utxos))
+	}
+}
+
+func TestSelectConsolidate_MaxCoins(t *testing.T) {
+
+	utxos := testCoins(1, 2, 3, 100)
+	selected, err := selectConsolidate(utxos, 3, 2)
+	if err != nil || coinIDs(selected) != "a,b" {
+		t.Errorf("selectConsolidate = %s, %v, want a,b", coinIDs(selected), err)
+	}
+
+	//从小到大超过上限时改为从大到小
+	selected, err = selectConsolidate(utxos, 50, 2)
+	if err != nil || coinIDs(selected) != "d" {
+		t.Errorf("selectConsolidate = %s, %v, want d", coinIDs(selected), err)
+	}
+}
+
+func TestWalletManager_SendWithdrawal_KeepLocks(t *testing.T) {
+
+	var sendErr error
+	server := newRPCTestServer(rpcTestMethods{
+		"wallet_status": rpcResult(`{"current_height":100,"available":1000}`),
+		"get_utxo":      rpcResult(`[{"id":"a","asset_id":0,"amount":1000,"maturity":10,"status":1}]`),
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			return "", sendErr
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.coinselection = CoinSelectLargestFirst
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	withdrawal := &WithdrawalAmount{Send: 100, Fee: 10}
+
+	//钱包返回错误时交易没有发出，释放UTXO锁
+	sendErr = &rpcTestError{Code: -32001, Message: "send failed"}
+	if _, err := wm.sendWithdrawal("from", "to", withdrawal, "", "t1", "order-1", false); err == nil {
+		t.Errorf("sendWithdrawal should fail")
+	}
+	if locks, _ := wm.GetUtxoLocks(); len(locks) != 0 {
+		t.Errorf("locks after rpc error = %+v", locks)
+	}
+
+	//网关超时时钱包可能已接受交易，UTXO锁绑定交易ID
+	sendErr = rpcTestStatus(http.StatusGatewayTimeout)
+	if _, err := wm.sendWithdrawal("from", "to", withdrawal, "", "t2", "order-2", false); err == nil {
+		t.Errorf("sendWithdrawal should fail")
+	}
+	if locks, _ := wm.GetUtxoLocks(); len(locks) != 1 || locks[0].UtxoID != "a" || locks[0].TxID != "t2" {
+		t.Errorf("locks after gateway timeout = %+v", locks)
+	}
+}
@@ -0,0 +1,91 @@
+package beam
+
+import (
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//钱包UTXO类型
+	UtxoTypeMine = "mine" //挖矿奖励
+)
+
+//CoinbaseTxID 挖矿奖励没有交易单，使用区块hash作为交易单ID
+func CoinbaseTxID(blockHash string) string {
+	return "coinbase_" + blockHash
+}
+
+//matchCoinbaseUtxos 查找区块挖矿奖励属于钱包的UTXO，
+//挖矿奖励UTXO没有创建交易单，通过与区块挖矿奖励输出相同的成熟高度匹配
+func matchCoinbaseUtxos(block *Block, utxos []*Utxo) []*Utxo {
+	matched := make([]*Utxo, 0)
+	if block.CoinbaseMaturity == 0 {
+		return matched
+	}
+	for _, utxo := range utxos {
+		if utxo.Type == UtxoTypeMine && utxo.Maturity == block.CoinbaseMaturity {
+			matched = append(matched, utxo)
+		}
+	}
+	return matched
+}
+
+//newCoinbaseTransaction 生成挖矿奖励交易单，没有发送者
+func newCoinbaseTransaction(block *Block, address string, value uint64) *Transaction {
+	return &Transaction{
+		TxID:        CoinbaseTxID(block.Hash),
+		Value:       value,
+		Receiver:    address,
+		Income:      true,
+		Status:      TxStatusCompleted,
+		CreateTime:  block.Time,
+		BlockHeight: block.Height,
+		BlockHash:   block.Hash,
+		Coinbase:    true,
+	}
+}
+
+//extractCoinbase 提取钱包节点挖到的区块奖励，作为挖矿地址的充值通知订阅的账户，
+//需要开启coinbase功能并配置mineraddress
+func (bs *BEAMBlockScanner) extractCoinbase(block *Block) {
+
+	address := bs.wm.Config.mineraddress
+	//账户模型下挖矿奖励记到钱包账户
+	if bs.wm.isAccountModel() {
+		address = bs.wm.Config.accountalias
+	}
+	scanTargetFunc := bs.scanTargetFunc()
+	if !bs.wm.IsFeatureEnabled(FeatureCoinbase) || len(address) == 0 || block.CoinbaseMaturity == 0 || scanTargetFunc == nil {
+		return
+	}
+
+	accountID, ok := scanTargetFunc(bs.wm.scanTarget(address))
+	if !ok {
+		return
+	}
+
+	result := ExtractResult{
+		BlockHeight: block.Height,
+		TxID:        CoinbaseTxID(block.Hash),
+		extractData: make(map[string][]*openwallet.TxExtractData),
+	}
+
+	utxos, err := bs.wm.walletClient.GetUtxoList(bs.context())
+	if err != nil {
+		bs.wm.Log.Std.Info("block height: %d get utxo list failed, unexpected error: %v", block.Height, err)
+		bs.saveExtractResult(block.Height, result)
+		return
+	}
+
+	var value uint64
+	for _, utxo := range matchCoinbaseUtxos(block, utxos) {
+		value += utxo.Amount
+	}
+	if value == 0 {
+		return
+	}
+
+	bs.wm.Log.Std.Info("block height: %d mined by wallet, reward: %d", block.Height, value)
+	bs.InitExtractResult(newCoinbaseTransaction(block, address, value), accountID, &result, 2)
+	result.Success = true
+	bs.saveExtractResult(block.Height, result)
+}
@@ -0,0 +1,33 @@
+package beam
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestMatchCoinbaseUtxos(t *testing.T) {
+
+	result := gjson.Parse(`{"hash":"c2a7","height":20516,"inputs":[],"subsidy":8000000000,
+		"outputs":[{"coinbase":false,"maturity":20517},{"coinbase":true,"maturity":20756}]}`)
+	block := NewBlock(&result)
+	if block.Subsidy != 8000000000 || block.CoinbaseMaturity != 20756 {
+		t.Errorf("unexpected block: %+v", block)
+		return
+	}
+
+	utxos := []*Utxo{
+		{ID: "a", Amount: 8000000000, Type: UtxoTypeMine, Maturity: 20756},
+		{ID: "b", Amount: 8000000000, Type: UtxoTypeMine, Maturity: 20757},
+		{ID: "c", Amount: 100, Type: "norm", Maturity: 20756},
+	}
+	matched := matchCoinbaseUtxos(block, utxos)
+	if len(matched) != 1 || matched[0].ID != "a" {
+		t.Errorf("matched = %+v, want utxo a", matched)
+	}
+
+	tx := newCoinbaseTransaction(block, "miner", matched[0].Amount)
+	if tx.TxID != "coinbase_c2a7" || !tx.Coinbase || tx.BlockHeight != 20516 {
+		t.Errorf("unexpected coinbase tx: %+v", tx)
+	}
+}
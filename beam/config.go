@@ -2,7 +2,6 @@ package beam
 
 import (
 	"github.com/blocktree/go-owcrypt"
-	"github.com/blocktree/openwallet/common/file"
 	"path/filepath"
 	"strings"
 	"time"
@@ -14,7 +13,123 @@ const (
 	CurveType = owcrypt.ECC_CURVE_SECP256K1
 
 	//交易单发送超时时限
-	DefaultTxSendingTimeout =  5 * time.Minute
+	DefaultTxSendingTimeout = 5 * time.Minute
+	//发送超时交易单的检查间隔
+	DefaultTxExpiryCheckPeriod = 1 * time.Minute
+	//发送失败的交易单最多重发次数
+	DefaultResendMaxAttempts = 5
+	//重发的初始等待时间，每次失败后加倍
+	DefaultResendBackoff = 30 * time.Second
+	//重发的最长等待时间
+	DefaultResendMaxBackoff = 10 * time.Minute
+	//重发任务的执行间隔
+	DefaultResendPeriod = 10 * time.Second
+
+	//未扫记录最大重试次数
+	DefaultUnscanMaxAttempts = 10
+	//未扫记录重试的初始等待时间
+	DefaultUnscanRetryBackoff = 1 * time.Minute
+	//未扫记录重试的最大等待时间
+	DefaultUnscanRetryMaxBackoff = 1 * time.Hour
+
+	//本地区块数据清理周期
+	DefaultBlockPrunePeriod = 10 * time.Minute
+
+	//大区块阈值，区块内核数量超过阈值时分页流式提取
+	DefaultLargeBlockThreshold = 1000
+	//分页拉取交易单的每页数量
+	DefaultTxPageSize = 200
+
+	//最近区块缓存数量
+	DefaultBlockCacheSize = 1000
+
+	//钱包API节点健康检查周期
+	DefaultWalletAPIHealthCheckPeriod = 30 * time.Second
+
+	//只读请求遇到临时错误时的最大重试次数
+	DefaultRPCRetryCount = 3
+	//第一次重试的等待时间，每次重试翻倍
+	DefaultRPCRetryDelay = 200 * time.Millisecond
+	//重试的最长等待时间
+	DefaultRPCRetryMaxDelay = 5 * time.Second
+	//重试等待时间的随机抖动比例
+	DefaultRPCRetryJitter = 0.2
+
+	//钱包API和浏览器API的默认请求超时
+	DefaultRPCTimeout = 30 * time.Second
+
+	//追块时一次批量预取交易单的区块数量
+	DefaultRPCBatchSize = 20
+
+	//钱包API请求限流的默认突发数量
+	DefaultRPCBurst = 10
+
+	//钱包API连续失败熔断的默认次数和冷却时间
+	DefaultRPCBreakerThreshold = 5
+	DefaultRPCBreakerCooldown  = 30 * time.Second
+
+	//Beam最低手续费规则，单位groth
+	FeeKernel         = 10
+	FeeOutput         = 10
+	FeeShieldedInput  = 1000
+	FeeShieldedOutput = 1000000
+	//普通交易的最低手续费
+	MinRegularFee = 100
+
+	//手续费的默认倍数
+	DefaultFeeMultiplier = 1.0
+
+	//UTXO锁定后交易一直没有发出时的释放时间
+	DefaultUtxoLockTimeout = 10 * time.Minute
+
+	//金额小于该值的UTXO视为碎片，单位BEAM
+	DefaultDustThreshold = "0.01"
+	//碎片UTXO达到该数量时合并
+	DefaultDustCount = 50
+	//碎片合并任务的检查间隔
+	DefaultDustConsolidatePeriod = 30 * time.Minute
+
+	//手续费输入的Index，转账输入的Index为0
+	FeeInputIndex = 1
+
+	//提现排队的最大数量
+	DefaultWithdrawalQueueSize = 100
+	//提现排队的最长等待时间
+	DefaultWithdrawalQueueTimeout = 5 * time.Minute
+	//提现队列的发送间隔
+	DefaultWithdrawalSendPeriod = 5 * time.Second
+
+	//隐私池交易的发送超时，隐私交易体积大，证明生成和上链比普通交易慢
+	DefaultShieldedTxSendingTimeout = 30 * time.Minute
+
+	//汇总时钱包节点和区块扫描允许落后的区块数
+	DefaultSummaryMaxLag = 10
+	//汇总时允许的处理中提现数量
+	DefaultSummaryMaxPendingWithdrawals = 10
+
+	//充值地址池补充后的地址数量
+	DefaultAddressPoolSize = 100
+	//充值地址池的低水位，未分配的地址低于该数量时补充
+	DefaultAddressPoolLowWater = 20
+	//充值地址池的检查间隔
+	DefaultAddressPoolRefillPeriod = time.Minute
+
+	//地址在该时间内过期时提前处理
+	DefaultAddressExpiryWarning = time.Hour
+	//地址过期的检查间隔
+	DefaultAddressExpiryCheckPeriod = 10 * time.Minute
+
+	//地址所有权挑战的有效期
+	DefaultOwnershipChallengeTTL = 24 * time.Hour
+	//地址所有权挑战的最大金额，单位groth
+	MaxOwnershipChallengeAmount = 100000
+
+	//引导时等待钱包同步的最长时间
+	DefaultWalletSyncTimeout = 2 * time.Hour
+	//引导时钱包同步的检查间隔
+	DefaultWalletSyncCheckPeriod = 10 * time.Second
+	//beam-wallet命令行
+	DefaultWalletCLI = "beam-wallet"
 )
 
 const (
@@ -34,6 +149,75 @@ const (
 	TxStatusRegistering = 5
 )
 
+const (
+	//交易类型，隐私池交易的输入或输出在隐私池中，没有对应的普通UTXO
+	TxTypeSimple          = 0
+	TxTypePushTransaction = 7  //发送到离线地址或最大隐私地址，输出进入隐私池
+	TxTypePullTransaction = 8  //花费隐私池中的UTXO
+	TxTypeContract        = 12 //调用shader合约
+)
+
+const (
+	//地址有效期
+	AddressExpiration24h   = "24h"   //24小时后过期
+	AddressExpirationNever = "never" //永不过期，交易所充值地址必须使用
+	AddressExpirationAuto  = "auto"  //使用钱包默认的有效期
+
+	//标记自己创建的地址
+	DefaultAddressComment = "self"
+)
+
+const (
+	//交易备注的最大长度，单位字节，备注随交易发给接收方并记录在钱包的交易记录中
+	MaxTxCommentLength = 1024
+)
+
+const (
+	//地址类型，离线、最大隐私和公开离线地址收到的付款为隐私池交易
+	AddressTypeRegular       = "regular"        //普通SBBS地址，付款时接收方钱包需要在线
+	AddressTypeOffline       = "offline"        //离线地址，接收方钱包离线时可接收有限次数的付款
+	AddressTypeMaxPrivacy    = "max_privacy"    //最大隐私地址，只能接收一次付款
+	AddressTypePublicOffline = "public_offline" //公开离线地址，可重复接收付款，隐私性较低
+)
+
+const (
+	//功能模块开关，配置在[features]段落
+	FeatureScanner           = "scanner"           //区块扫描
+	FeatureSummary           = "summary"           //定时汇总
+	FeatureServer            = "server"            //OWTP服务端
+	FeatureWebhooks          = "webhooks"          //Webhook推送
+	FeatureMetrics           = "metrics"           //监控指标
+	FeatureShielded          = "shielded"          //隐私池交易支持
+	FeatureWalletEvents      = "walletevents"      //订阅钱包事件
+	FeatureCoinbase          = "coinbase"          //挖矿奖励提取
+	FeatureWithdrawalQueue   = "withdrawalqueue"   //提现队列定时发送
+	FeatureDustConsolidation = "dustconsolidation" //碎片UTXO定时合并
+	FeatureExpiredTxWatcher  = "expiredtxwatcher"  //发送超时交易单定时取消
+	FeatureTxResend          = "txresend"          //发送失败的交易单自动重发
+	FeatureShaders           = "shaders"           //shader合约调用
+	FeatureAddressPool       = "addresspool"       //充值地址池后台补充
+	FeatureAddressExpiry     = "addressexpiry"     //地址过期检查和续期
+)
+
+//defaultFeatures 功能模块默认开关，已有功能默认开启，新功能默认关闭
+var defaultFeatures = map[string]bool{
+	FeatureScanner:           true,
+	FeatureSummary:           true,
+	FeatureServer:            true,
+	FeatureWebhooks:          false,
+	FeatureMetrics:           false,
+	FeatureShielded:          false,
+	FeatureWalletEvents:      false,
+	FeatureCoinbase:          false,
+	FeatureWithdrawalQueue:   false,
+	FeatureDustConsolidation: false,
+	FeatureExpiredTxWatcher:  false,
+	FeatureTxResend:          false,
+	FeatureShaders:           false,
+	FeatureAddressPool:       false,
+	FeatureAddressExpiry:     false,
+}
+
 type WalletConfig struct {
 
 	//币种
@@ -52,6 +236,30 @@ type WalletConfig struct {
 	CurveType uint32
 	//固定手续费
 	fixfees string
+	//按最低手续费规则估算的手续费乘以该倍数
+	feemultiplier float64
+	//提现选择UTXO的方式：wallet，largest，smallest，bnb，consolidate
+	coinselection string
+	//UTXO锁定后交易一直没有发出时的释放时间
+	utxolocktimeout time.Duration
+	//金额小于该值的UTXO视为碎片，单位BEAM，为0时不合并
+	dustthreshold string
+	//碎片UTXO达到该数量时合并
+	dustcount int
+	//碎片合并只在该时段内执行，为nil时不限时段
+	dustquiethours *quietHours
+	//碎片合并任务的检查间隔
+	dustconsolidateperiod time.Duration
+	//提现金额的计算方式：fixed，exact，net
+	withdrawmode string
+	//提现使用的发送地址，为空时使用钱包的第一个地址
+	withdrawfrom string
+	//余额模型：address按地址，account整个钱包作为一个账户
+	balancemodel string
+	//账户模型下钱包对应的openwallet资产账户别名
+	accountalias string
+	//发送节点的配置文件，NodeGroup加载
+	sendnodes []string
 	// 远程服务
 	remoteserver string
 	//是否开启协商密码通信
@@ -72,6 +280,10 @@ type WalletConfig struct {
 	enableserver bool
 	//是否输出LogDebugg日志
 	logdebug bool
+	//记录钱包API和浏览器API的请求和响应，用于排查节点兼容问题
+	rpctrace bool
+	//请求跟踪文件目录，为空时写日志
+	rpctracedir string
 	//通信证书私钥
 	cert string
 	//汇总地址
@@ -80,14 +292,175 @@ type WalletConfig struct {
 	summarythreshold string
 	//汇总时间周期
 	summaryperiod string
+	//汇总的cron表达式，设置时代替summaryperiod
+	summarycron string
+	//按openwallet账户的汇总策略
+	summaryaccounts map[string]*SummaryPolicy
+	//拆分汇总的目标地址，设置时代替summaryaddress
+	summarysplit []*SummaryTarget
 	//日志路径
 	logdir string
 	//交易单发送超时
 	txsendingtimeout time.Duration
+	//开启shielded时隐私池交易的发送超时
+	shieldedtxsendingtimeout time.Duration
+	//发送超时交易单的检查间隔
+	txexpirycheckperiod time.Duration
+	//发送失败的交易单最多重发次数，包括第一次发送
+	resendmaxattempts int
+	//重发的初始等待时间，每次失败后加倍
+	resendbackoff time.Duration
+	//重发的最长等待时间
+	resendmaxbackoff time.Duration
+	//重发任务的执行间隔
+	resendperiod time.Duration
 	//钱包wallet.db备份目录
 	walletdatabackupdir string
 	//钱包wallet.db绝对路径
 	walletdatafile string
+	//钱包备份的加密口令，配置后汇总完成时的备份改为加密备份
+	walletbackuppassword *secretSource
+	//wallet.db的密码，恢复钱包和修改密码时使用
+	walletpassword *secretSource
+	//未扫记录最大重试次数，超过后进入死信状态
+	unscanmaxattempts int
+	//未扫记录重试的初始等待时间，每次失败翻倍
+	unscanretrybackoff time.Duration
+	//未扫记录重试的最大等待时间
+	unscanretrymaxbackoff time.Duration
+	//本地区块保留数量，0表示不按数量清理
+	blockretentioncount uint64
+	//本地区块保留时长，0表示不按时间清理
+	blockretentionperiod time.Duration
+	//本地区块清理周期
+	blockpruneperiod time.Duration
+	//功能模块开关
+	features map[string]bool
+	//监控指标HTTP服务监听地址
+	metricsaddr string
+	//大区块阈值，0表示不使用流式提取
+	largeblockthreshold int64
+	//分页拉取交易单的每页数量
+	txpagesize uint64
+	//钱包API的TCP地址，用于订阅钱包事件
+	walleteventapi string
+	//同时处理中的提现交易最大数量，0表示不限制
+	maxinflightwithdrawals int
+	//提现数量达到上限时的处理方式：queue，reject，shedoldest
+	withdrawaloverflow string
+	//提现排队的最大数量
+	withdrawalqueuesize int
+	//提现排队的最长等待时间
+	withdrawalqueuetimeout time.Duration
+	//提现队列的发送间隔
+	withdrawalsendperiod time.Duration
+	//汇总时钱包节点和区块扫描允许落后的区块数，0表示不检查
+	summarymaxlag uint64
+	//处理中的提现达到该数量时推迟汇总，0表示不检查
+	summarymaxpendingwithdrawals int
+	//汇总报告推送地址，开启webhooks功能时每次汇总后POST报告
+	summarywebhook string
+	//汇总后热钱包至少保留的余额
+	summaryminbalance string
+	//汇总手续费的支付方式：amount，reserve
+	summaryfeefrom string
+	//按reserve方式支付手续费时预留的余额
+	summaryfeereserve string
+	//每笔汇总交易的最大金额，为空表示不限制
+	summarymaxamount string
+	//每次汇总最多发送的交易数量，0表示不限制
+	summarymaxtxs int
+	//两次发送汇总交易的最小间隔，0表示不限制
+	summarymininterval time.Duration
+	//手续费输入使用旧的格式：复制转账输入，SID和Index与转账输入相同
+	legacyfeeinput bool
+	//最近区块缓存数量，0表示不缓存
+	blockcachesize int
+	//挖矿地址，钱包节点挖到的区块奖励记到该地址
+	mineraddress string
+	//多个钱包API的选择方式：failover，roundrobin
+	walletapipolicy string
+	//钱包API的传输方式：http，websocket
+	walletapitransport string
+	//钱包API节点健康检查周期
+	walletapihealthcheckperiod time.Duration
+	//只读请求的重试策略
+	rpcretry RetryPolicy
+	//钱包API和浏览器API的默认请求超时，0表示不超时
+	rpctimeout time.Duration
+	//按方法单独配置的请求超时
+	rpcmethodtimeouts map[string]time.Duration
+	//https的自定义CA证书
+	walletapicafile string
+	//双向认证的客户端证书和私钥
+	walletapicertfile string
+	walletapikeyfile  string
+	//不校验服务端证书，只用于测试
+	walletapiinsecure bool
+	//钱包API的认证信息
+	walletapiauth WalletAPIAuth
+	//钱包API代理的Basic Auth密码和API Key的来源
+	walletapipassword *secretSource
+	walletapikey      *secretSource
+	//配置加密密钥文件，为空时使用BEAM_SECRET_KEY环境变量
+	secretkeyfile string
+	//配置加密密钥
+	secretkey string
+	//Vault地址，为空时使用VAULT_ADDR环境变量
+	vaultaddr string
+	//AWS KMS的区域和自定义endpoint
+	awskmsregion   string
+	awskmsendpoint string
+	//区块数据来源，按顺序获取：explorer，wallet
+	blocksources []string
+	//钱包API版本，为空时启动后通过get_version探测
+	walletapiversion string
+	//钱包API每秒请求数限制，0表示不限制
+	rpcratelimit float64
+	//钱包API请求突发数量
+	rpcburst int
+	//钱包API连续失败多少次后熔断，0表示不熔断
+	rpcbreakerthreshold int
+	//熔断后的冷却时间，冷却结束后探测钱包API是否恢复
+	rpcbreakercooldown time.Duration
+	//追块时一次批量预取交易单的区块数量，小于2表示不预取
+	rpcbatchsize uint64
+	//机密资产数量的小数位数，没有配置的资产与BEAM相同
+	assetdecimals map[int64]int32
+	//最小充值金额，单位BEAM，低于该金额的充值记为粉尘充值，为空表示不过滤
+	mindeposit string
+	//粉尘充值是否通知观测者，通知时交易扩展参数带dust标记
+	notifydustdeposit bool
+	//只提取匹配的地址，为空表示不限制
+	extractallow []string
+	//发送方或接收方匹配时不提取交易单
+	extractdeny []string
+	//提现策略文件，修改后自动重新加载，为空表示不使用
+	withdrawalpolicyfile string
+	//开启提现地址白名单
+	enablewithdrawwhitelist bool
+	//提现地址白名单中的地址或通配符模式，whitelist add登记的地址也在白名单中
+	withdrawwhitelist []string
+	//是否允许交易单扩展参数whitelist_override跳过白名单
+	withdrawwhitelistoverride bool
+	//充值地址池补充后的地址数量
+	addresspoolsize int
+	//充值地址池的低水位
+	addresspoollowwater int
+	//充值地址池的检查间隔
+	addresspoolrefillperiod time.Duration
+	//地址在该时间内过期时提前处理
+	addressexpirywarning time.Duration
+	//地址过期的检查间隔
+	addressexpirycheckperiod time.Duration
+	//地址即将过期时的处理方式：renew、reissue或notify
+	addressexpiryaction string
+	//地址所有权挑战的有效期
+	ownershipchallengettl time.Duration
+	//beam-wallet命令行路径，用于按助记词恢复钱包
+	walletcli string
+	//引导时等待钱包同步的最长时间
+	walletsynctimeout time.Duration
 }
 
 func NewConfig(symbol string) *WalletConfig {
@@ -97,6 +470,60 @@ func NewConfig(symbol string) *WalletConfig {
 	//币种
 	c.Symbol = symbol
 	c.CurveType = CurveType
+	c.unscanmaxattempts = DefaultUnscanMaxAttempts
+	c.unscanretrybackoff = DefaultUnscanRetryBackoff
+	c.unscanretrymaxbackoff = DefaultUnscanRetryMaxBackoff
+	c.blockpruneperiod = DefaultBlockPrunePeriod
+	c.largeblockthreshold = DefaultLargeBlockThreshold
+	c.txpagesize = DefaultTxPageSize
+	c.withdrawaloverflow = WithdrawalOverflowQueue
+	c.withdrawalqueuesize = DefaultWithdrawalQueueSize
+	c.withdrawalqueuetimeout = DefaultWithdrawalQueueTimeout
+	c.withdrawalsendperiod = DefaultWithdrawalSendPeriod
+	c.summarymaxlag = DefaultSummaryMaxLag
+	c.txexpirycheckperiod = DefaultTxExpiryCheckPeriod
+	c.shieldedtxsendingtimeout = DefaultShieldedTxSendingTimeout
+	c.resendmaxattempts = DefaultResendMaxAttempts
+	c.resendbackoff = DefaultResendBackoff
+	c.resendmaxbackoff = DefaultResendMaxBackoff
+	c.resendperiod = DefaultResendPeriod
+	c.blockcachesize = DefaultBlockCacheSize
+	c.walletapipolicy = WalletAPIPolicyFailover
+	c.feemultiplier = DefaultFeeMultiplier
+	c.coinselection = CoinSelectWallet
+	c.utxolocktimeout = DefaultUtxoLockTimeout
+	c.dustthreshold = DefaultDustThreshold
+	c.dustcount = DefaultDustCount
+	c.dustconsolidateperiod = DefaultDustConsolidatePeriod
+	c.walletapitransport = WalletAPITransportHTTP
+	c.walletapihealthcheckperiod = DefaultWalletAPIHealthCheckPeriod
+	c.rpcretry = defaultRetryPolicy()
+	c.rpctimeout = DefaultRPCTimeout
+	c.rpcmethodtimeouts = make(map[string]time.Duration)
+	c.blocksources = []string{BlockSourceExplorer}
+	c.withdrawmode = WithdrawModeFixed
+	c.balancemodel = BalanceModelAddress
+	c.rpcbatchsize = DefaultRPCBatchSize
+	c.assetdecimals = make(map[int64]int32)
+	c.summaryaccounts = make(map[string]*SummaryPolicy)
+	c.rpcburst = DefaultRPCBurst
+	c.rpcbreakerthreshold = DefaultRPCBreakerThreshold
+	c.rpcbreakercooldown = DefaultRPCBreakerCooldown
+	c.summarymaxpendingwithdrawals = DefaultSummaryMaxPendingWithdrawals
+	c.summaryfeefrom = SummaryFeeFromAmount
+	c.addresspoolsize = DefaultAddressPoolSize
+	c.addresspoollowwater = DefaultAddressPoolLowWater
+	c.addresspoolrefillperiod = DefaultAddressPoolRefillPeriod
+	c.addressexpirywarning = DefaultAddressExpiryWarning
+	c.addressexpirycheckperiod = DefaultAddressExpiryCheckPeriod
+	c.addressexpiryaction = AddressExpiryActionRenew
+	c.ownershipchallengettl = DefaultOwnershipChallengeTTL
+	c.walletcli = DefaultWalletCLI
+	c.walletsynctimeout = DefaultWalletSyncTimeout
+	c.features = make(map[string]bool)
+	for name, enabled := range defaultFeatures {
+		c.features[name] = enabled
+	}
 
 	//区块链数据
 	//blockchainDir = filepath.Join("data", strings.ToLower(Symbol), "blockchain")
@@ -109,8 +536,5 @@ func NewConfig(symbol string) *WalletConfig {
 	//本地数据库文件路径
 	c.dbPath = filepath.Join("data", strings.ToLower(c.Symbol), "db")
 
-	//创建目录
-	file.MkdirAll(c.dbPath)
-
 	return &c
 }
@@ -0,0 +1,161 @@
+package beam
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//cronSearchYears 查找下次执行时间的最大范围，超过时表示表达式不会触发，如2月30日
+const cronSearchYears = 5
+
+//cronMacros 常用的预定义表达式
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+//CronSchedule 五段式cron表达式：分 时 日 月 周，每段支持*、逗号列表、a-b范围和/n步长，
+//周日为0或7；日和周都有限制时满足任一即可
+type CronSchedule struct {
+	spec    string
+	minute  [60]bool
+	hour    [24]bool
+	dom     [32]bool
+	month   [13]bool
+	dow     [7]bool
+	domStar bool
+	dowStar bool
+}
+
+//ParseCronSchedule 解析cron表达式
+func ParseCronSchedule(spec string) (*CronSchedule, error) {
+
+	spec = strings.TrimSpace(spec)
+	expr := spec
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression: %s should have 5 fields", spec)
+	}
+
+	s := &CronSchedule{spec: spec}
+	var (
+		dow [8]bool
+		err error
+	)
+	if err = parseCronField(fields[0], 0, 59, s.minute[:]); err != nil {
+		return nil, err
+	}
+	if err = parseCronField(fields[1], 0, 23, s.hour[:]); err != nil {
+		return nil, err
+	}
+	if err = parseCronField(fields[2], 1, 31, s.dom[:]); err != nil {
+		return nil, err
+	}
+	if err = parseCronField(fields[3], 1, 12, s.month[:]); err != nil {
+		return nil, err
+	}
+	if err = parseCronField(fields[4], 0, 7, dow[:]); err != nil {
+		return nil, err
+	}
+	copy(s.dow[:], dow[:7])
+	s.dow[0] = s.dow[0] || dow[7]
+	s.domStar = fields[2] == "*"
+	s.dowStar = fields[4] == "*"
+
+	if s.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron expression: %s never fires", spec)
+	}
+	return s, nil
+}
+
+//parseCronField 解析一段表达式，把匹配的值在bits中标记为true
+func parseCronField(field string, min, max int, bits []bool) error {
+
+	for _, part := range strings.Split(field, ",") {
+
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid cron step: %s", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			n, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return fmt.Errorf("invalid cron value: %s", part)
+			}
+			low, high = n, n
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return fmt.Errorf("invalid cron value: %s", part)
+				}
+			} else if step > 1 {
+				//a/n表示从a开始到最大值
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return fmt.Errorf("cron value: %s out of range %d-%d", part, min, max)
+		}
+
+		for v := low; v <= high; v += step {
+			bits[v] = true
+		}
+	}
+	return nil
+}
+
+//String cron表达式
+func (s *CronSchedule) String() string {
+	return s.spec
+}
+
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	if s.domStar || s.dowStar {
+		return s.dom[t.Day()] && s.dow[t.Weekday()]
+	}
+	return s.dom[t.Day()] || s.dow[t.Weekday()]
+}
+
+//Next t之后下一次执行的时间，精确到分钟，按t的时区计算，不会触发时返回零值
+func (s *CronSchedule) Next(t time.Time) time.Time {
+
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if !s.month[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !s.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,46 @@
+package beam
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronSchedule_Next(t *testing.T) {
+
+	base := time.Date(2026, 1, 30, 10, 17, 45, 0, time.UTC) //周五
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"* * * * *", "2026-01-30 10:18"},
+		{"*/15 * * * *", "2026-01-30 10:30"},
+		{"0 3 * * *", "2026-01-31 03:00"},
+		{"30 2-4 * * *", "2026-01-31 02:30"},
+		{"0 0 1 * *", "2026-02-01 00:00"},
+		{"@daily", "2026-01-31 00:00"},
+		{"0 1 * * 1-5", "2026-02-02 01:00"},
+		{"0 1 * * 7", "2026-02-01 01:00"},
+		{"0 4 31 * *", "2026-01-31 04:00"},
+		{"0 4 29 2 *", "2028-02-29 04:00"},
+		//日和周都有限制时满足任一即可
+		{"0 0 15 * 6", "2026-01-31 00:00"},
+		{"5/20 10 * * *", "2026-01-30 10:25"},
+		{"0,45 10,22 * * *", "2026-01-30 10:45"},
+	}
+	for _, test := range tests {
+		s, err := ParseCronSchedule(test.spec)
+		if err != nil {
+			t.Errorf("ParseCronSchedule(%s) unexpected error: %v", test.spec, err)
+			continue
+		}
+		if next := s.Next(base).Format("2006-01-02 15:04"); next != test.want {
+			t.Errorf("%s next = %s, want %s", test.spec, next, test.want)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "0 0 30 2 *"} {
+		if _, err := ParseCronSchedule(spec); err == nil {
+			t.Errorf("ParseCronSchedule(%s) should fail", spec)
+		}
+	}
+}
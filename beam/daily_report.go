@@ -0,0 +1,174 @@
+package beam
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/shopspring/decimal"
+)
+
+//DailyReportDateFormat 日报的日期格式，按UTC计算
+const DailyReportDateFormat = "2006-01-02"
+
+//dailyReportColumns 日报CSV的列名
+var dailyReportColumns = []string{"date", "account", "asset", "deposits", "depositamount", "withdrawals", "withdrawalamount", "fees"}
+
+//DailyReportRow 一个账户一种资产一天的充值和提现汇总，金额单位为BEAM或资产
+type DailyReportRow struct {
+	Date             string `json:"date"`
+	AccountID        string `json:"account"`
+	AssetID          int64  `json:"asset"`
+	Deposits         int    `json:"deposits"` //充值笔数
+	DepositAmount    string `json:"depositAmount"`
+	Withdrawals      int    `json:"withdrawals"` //提现笔数
+	WithdrawalAmount string `json:"withdrawalAmount"`
+	Fees             string `json:"fees"` //提现手续费，单位BEAM
+
+	deposit    decimal.Decimal
+	withdrawal decimal.Decimal
+	fees       decimal.Decimal
+}
+
+//DailyReport 按UTC日期、账户和资产汇总区块扫描提取的充值（入账）和提现（出账），
+//包含start到end（含）之间的每一天，没有交易的日期不输出；迁移导入的记录不计入
+func (wm *WalletManager) DailyReport(start, end time.Time) ([]*DailyReportRow, error) {
+
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	if !start.Before(end) {
+		return nil, fmt.Errorf("report start date: %s is after end date", start.Format(DailyReportDateFormat))
+	}
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var records []*LedgerRecord
+	err = db.Select(
+		q.Eq("Source", LedgerSourceScanner),
+		q.Gte("CreateTime", start.Unix()),
+		q.Lt("CreateTime", end.Unix()),
+	).Find(&records)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	rows := make(map[string]*DailyReportRow)
+	for _, r := range records {
+		date := time.Unix(r.CreateTime, 0).UTC().Format(DailyReportDateFormat)
+		key := fmt.Sprintf("%s_%s_%d", date, r.AccountID, r.AssetID)
+		row, ok := rows[key]
+		if !ok {
+			row = &DailyReportRow{Date: date, AccountID: r.AccountID, AssetID: r.AssetID}
+			rows[key] = row
+		}
+
+		amount, err := decimal.NewFromString(r.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("ledger record: %s amount: %s is invalid", r.ID, r.Amount)
+		}
+		if r.Direction == LedgerDirectionIn {
+			row.Deposits++
+			row.deposit = row.deposit.Add(amount)
+			continue
+		}
+		row.Withdrawals++
+		row.withdrawal = row.withdrawal.Add(amount)
+		if len(r.Fees) > 0 {
+			fees, err := decimal.NewFromString(r.Fees)
+			if err != nil {
+				return nil, fmt.Errorf("ledger record: %s fees: %s is invalid", r.ID, r.Fees)
+			}
+			row.fees = row.fees.Add(fees)
+		}
+	}
+
+	list := make([]*DailyReportRow, 0, len(rows))
+	for _, row := range rows {
+		row.DepositAmount = row.deposit.String()
+		row.WithdrawalAmount = row.withdrawal.String()
+		row.Fees = row.fees.String()
+		list = append(list, row)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Date != list[j].Date {
+			return list[i].Date < list[j].Date
+		}
+		if list[i].AccountID != list[j].AccountID {
+			return list[i].AccountID < list[j].AccountID
+		}
+		return list[i].AssetID < list[j].AssetID
+	})
+	return list, nil
+}
+
+//ExportDailyReport 生成start到end（含）的日报并写入文件（CSV或JSON，按扩展名），返回行数
+func (wm *WalletManager) ExportDailyReport(filePath string, start, end time.Time) (int, error) {
+
+	format := strings.ToLower(filepath.Ext(filePath))
+	if format != ".json" && format != ".csv" {
+		return 0, fmt.Errorf("unsupported report file format: %s", filePath)
+	}
+
+	list, err := wm.DailyReport(start, end)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if format == ".json" {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(list)
+	} else {
+		err = writeDailyReportCSV(f, list)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	wm.Log.Infof("export %d daily report rows to %s", len(list), filePath)
+	return len(list), nil
+}
+
+func writeDailyReportCSV(w io.Writer, list []*DailyReportRow) error {
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(dailyReportColumns); err != nil {
+		return err
+	}
+	for _, row := range list {
+		record := []string{
+			row.Date,
+			row.AccountID,
+			strconv.FormatInt(row.AssetID, 10),
+			strconv.Itoa(row.Deposits),
+			row.DepositAmount,
+			strconv.Itoa(row.Withdrawals),
+			row.WithdrawalAmount,
+			row.Fees,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
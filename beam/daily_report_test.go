@@ -0,0 +1,82 @@
+package beam
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDailyReport(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	record := func(txID, account, direction, amount, fees string, assetID int64, at time.Time) *LedgerRecord {
+		r := NewLedgerRecord(txID, account+"_address", direction)
+		r.AccountID, r.Amount, r.Fees, r.AssetID = account, amount, fees, assetID
+		r.CreateTime = at.Unix()
+		r.Source = LedgerSourceScanner
+		return r
+	}
+	legacy := record("t0", "acct1", LedgerDirectionIn, "9", "", 0, day.Add(time.Hour))
+	legacy.Source = LedgerSourceLegacy
+	err := wm.SaveLedgerRecords(
+		legacy,
+		record("t1", "acct1", LedgerDirectionIn, "1.5", "", 0, day.Add(time.Hour)),
+		record("t2", "acct1", LedgerDirectionIn, "0.5", "", 0, day.Add(23*time.Hour)),
+		record("t3", "acct1", LedgerDirectionOut, "1", "0.001", 0, day.Add(12*time.Hour)),
+		record("t4", "acct2", LedgerDirectionIn, "7", "", 3, day.Add(2*time.Hour)),
+		record("t5", "acct1", LedgerDirectionIn, "2", "", 0, day.AddDate(0, 0, 1)),
+		record("t6", "acct1", LedgerDirectionIn, "4", "", 0, day.AddDate(0, 0, 3)),
+	)
+	if err != nil {
+		t.Fatalf("SaveLedgerRecords unexpected error: %v", err)
+	}
+
+	rows, err := wm.DailyReport(day, day.AddDate(0, 0, 1))
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("DailyReport = %d rows, err = %v", len(rows), err)
+	}
+	if r := rows[0]; r.Date != "2026-10-01" || r.AccountID != "acct1" || r.Deposits != 2 || r.DepositAmount != "2" ||
+		r.Withdrawals != 1 || r.WithdrawalAmount != "1" || r.Fees != "0.001" {
+		t.Errorf("acct1 row = %+v", r)
+	}
+	if r := rows[1]; r.AccountID != "acct2" || r.AssetID != 3 || r.DepositAmount != "7" || r.Fees != "0" {
+		t.Errorf("acct2 row = %+v", r)
+	}
+	if r := rows[2]; r.Date != "2026-10-02" || r.DepositAmount != "2" {
+		t.Errorf("next day row = %+v", r)
+	}
+
+	if _, err = wm.DailyReport(day.AddDate(0, 0, 1), day); err == nil {
+		t.Errorf("start after end should fail")
+	}
+
+	csvFile := filepath.Join(t.TempDir(), "report.csv")
+	if n, err := wm.ExportDailyReport(csvFile, day, day); err != nil || n != 2 {
+		t.Fatalf("ExportDailyReport csv = %d, err = %v", n, err)
+	}
+	data, _ := ioutil.ReadFile(csvFile)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || lines[0] != strings.Join(dailyReportColumns, ",") || lines[1] != "2026-10-01,acct1,0,2,2,1,1,0.001" {
+		t.Errorf("csv report = %q", lines)
+	}
+
+	jsonFile := filepath.Join(t.TempDir(), "report.json")
+	if _, err = wm.ExportDailyReport(jsonFile, day, day.AddDate(0, 0, 5)); err != nil {
+		t.Fatalf("ExportDailyReport json unexpected error: %v", err)
+	}
+	var exported []*DailyReportRow
+	data, _ = ioutil.ReadFile(jsonFile)
+	if err = json.Unmarshal(data, &exported); err != nil || len(exported) != 4 || exported[3].Date != "2026-10-04" {
+		t.Errorf("json report = %s, err = %v", data, err)
+	}
+
+	if _, err = wm.ExportDailyReport(filepath.Join(t.TempDir(), "report.txt"), day, day); err == nil {
+		t.Errorf("unsupported format should fail")
+	}
+}
@@ -0,0 +1,161 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/timer"
+)
+
+//quietHours 每天的空闲时段，end小于start时跨过零点
+type quietHours struct {
+	start int //从零点开始的分钟数
+	end   int
+}
+
+//parseQuietHours 解析HH:MM-HH:MM格式的空闲时段，为空时返回nil表示不限时段
+func parseQuietHours(s string) (*quietHours, error) {
+
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid quiet hours: %s", s)
+	}
+
+	minutes := make([]int, 2)
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid quiet hours: %s", s)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+	if minutes[0] == minutes[1] {
+		return nil, fmt.Errorf("invalid quiet hours: %s", s)
+	}
+
+	return &quietHours{start: minutes[0], end: minutes[1]}, nil
+}
+
+//contains t是否在空闲时段内，nil表示不限时段
+func (q *quietHours) contains(t time.Time) bool {
+	if q == nil {
+		return true
+	}
+	m := t.Hour()*60 + t.Minute()
+	if q.start < q.end {
+		return m >= q.start && m < q.end
+	}
+	return m >= q.start || m < q.end
+}
+
+//dustThreshold 配置的碎片金额上限，最小单位
+func (wm *WalletManager) dustThreshold() uint64 {
+	return common.StringNumToBigIntWithExp(wm.Config.dustthreshold, wm.Decimal()).Uint64()
+}
+
+//ConsolidateDust 未锁定的可花费UTXO中，金额小于dustthreshold的碎片达到dustcount个时，
+//从小到大最多合并MaxSplitCoins个到钱包自己的地址；碎片数量不足时返回空的交易ID，
+//合并使用的UTXO会锁定，交易结束前提现不会选中
+func (wm *WalletManager) ConsolidateDust() (string, error) {
+
+	threshold := wm.dustThreshold()
+	if threshold == 0 {
+		return "", nil
+	}
+
+	coins, err := wm.spendableCoins()
+	if err != nil {
+		return "", err
+	}
+
+	//隐私池UTXO的输入手续费高，不作为碎片合并
+	dust := make([]*Utxo, 0)
+	for _, utxo := range coins {
+		if utxo.Amount < threshold && !utxo.IsShielded() {
+			dust = append(dust, utxo)
+		}
+	}
+	if len(dust) < wm.Config.dustcount || len(dust) < 2 {
+		return "", nil
+	}
+
+	fee := wm.applyFee(FeeParams{Kernels: 1, Outputs: 1}.MinFee())
+
+	selected, err := wm.utxoLocks.lockWith(dust, "", func(available []*Utxo) ([]*Utxo, error) {
+		sortCoins(available, true)
+		if len(available) > MaxSplitCoins {
+			available = available[:MaxSplitCoins]
+		}
+		if len(available) < 2 || sumCoins(available) <= fee {
+			return nil, ErrInsufficientCoins
+		}
+		return available, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("no enough dust coins to consolidate: %v", err)
+	}
+
+	coinIDs := make([]string, 0, len(selected))
+	for _, utxo := range selected {
+		coinIDs = append(coinIDs, utxo.ID)
+	}
+	total := sumCoins(selected)
+
+	txID, err := wm.consolidateLocked(coinIDs, total, fee)
+	if err != nil {
+		if unlockErr := wm.utxoLocks.unlock(coinIDs); unlockErr != nil {
+			wm.Log.Errorf("release utxo locks failed, unexpected error: %v", unlockErr)
+		}
+		return "", err
+	}
+
+	if bindErr := wm.utxoLocks.bind(coinIDs, txID); bindErr != nil {
+		wm.Log.Errorf("bind utxo locks to tx: %s failed, unexpected error: %v", txID, bindErr)
+	}
+
+	wm.Log.Infof("Consolidate %d dust coins of %d, total: %d, fee: %d, tx: %s", len(coinIDs), len(dust), total, fee, txID)
+	return txID, nil
+}
+
+//consolidateLocked 把已锁定的UTXO合并到钱包自己的地址
+func (wm *WalletManager) consolidateLocked(coinIDs []string, total, fee uint64) (string, error) {
+
+	addresses, err := wm.walletClient.GetAddressList(context.Background())
+	if err != nil {
+		return "", err
+	}
+	if len(addresses) == 0 {
+		return "", fmt.Errorf("wallet has no address to receive consolidated coins")
+	}
+
+	return wm.walletClient.ConsolidateCoins(context.Background(), addresses[0], coinIDs, total-fee, fee)
+}
+
+//StartDustConsolidator 启动碎片合并任务，只在dustquiethours时段内检查和合并
+func (wm *WalletManager) StartDustConsolidator() {
+
+	if !wm.IsFeatureEnabled(FeatureDustConsolidation) || wm.dustConsolidator != nil {
+		return
+	}
+
+	wm.Log.Infof("The timer for dust consolidation start now. Execute by every %v seconds.", wm.Config.dustconsolidateperiod.Seconds())
+
+	wm.dustConsolidator = timer.NewTask(wm.Config.dustconsolidateperiod, func() {
+		if !wm.Config.dustquiethours.contains(time.Now()) {
+			return
+		}
+		_, err := wm.ConsolidateDust()
+		if err != nil {
+			wm.Log.Errorf("consolidate dust coins unexpected error: %v", err)
+		}
+	})
+	wm.dustConsolidator.Start()
+}
@@ -0,0 +1,96 @@
+package beam
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2020, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		hours string
+		t     time.Time
+		want  bool
+	}{
+		{"", at(12, 0), true},
+		{"02:00-05:00", at(2, 0), true},
+		{"02:00-05:00", at(4, 59), true},
+		{"02:00-05:00", at(5, 0), false},
+		{"23:30-01:00", at(23, 45), true},
+		{"23:30-01:00", at(0, 30), true},
+		{"23:30-01:00", at(12, 0), false},
+	}
+	for _, tt := range tests {
+		q, err := parseQuietHours(tt.hours)
+		if err != nil {
+			t.Errorf("parseQuietHours(%s) unexpected error: %v", tt.hours, err)
+			continue
+		}
+		if got := q.contains(tt.t); got != tt.want {
+			t.Errorf("%s contains %s = %v, want %v", tt.hours, tt.t.Format("15:04"), got, tt.want)
+		}
+	}
+
+	for _, hours := range []string{"02:00", "2-5", "02:00-02:00", "25:00-05:00"} {
+		if _, err := parseQuietHours(hours); err == nil {
+			t.Errorf("parseQuietHours(%s) should fail", hours)
+		}
+	}
+}
+
+func TestWalletManager_ConsolidateDust(t *testing.T) {
+
+	var sent map[string]interface{}
+	server := newRPCTestServer(rpcTestMethods{
+		"wallet_status": rpcResult(`{"current_height":100}`),
+		"get_utxo": rpcResult(`[
+			{"id":"big","asset_id":0,"amount":100000000,"maturity":10,"status":1},
+			{"id":"d1","asset_id":0,"amount":300,"maturity":10,"status":1},
+			{"id":"d2","asset_id":0,"amount":200,"maturity":10,"status":1},
+			{"id":"d3","asset_id":0,"amount":100,"maturity":10,"status":1},
+			{"id":"d4","asset_id":0,"amount":400,"maturity":10,"status":1}
+		]`),
+		"addr_list": rpcResult(`[{"address":"self-addr","own":true,"expired":false,"comment":"self"}]`),
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			sent = params
+			return `{"txId":"tx1"}`, nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.Config.dustthreshold = "0.00001"
+	wm.Config.dustcount = 4
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	//d4已被提现锁定，碎片数量不足
+	wm.utxoLocks.selectAndLock(CoinSelectLargestFirst, []*Utxo{{ID: "d4", Amount: 400}}, 400, "order-1")
+	txID, err := wm.ConsolidateDust()
+	if err != nil || len(txID) > 0 || sent != nil {
+		t.Errorf("ConsolidateDust below count = %s, %v", txID, err)
+	}
+
+	wm.Config.dustcount = 3
+	txID, err = wm.ConsolidateDust()
+	if err != nil || txID != "tx1" {
+		t.Errorf("ConsolidateDust = %s, %v", txID, err)
+		return
+	}
+	coins, _ := sent["coins"].([]interface{})
+	if len(coins) != 3 || coins[0] != "d3" || sent["value"] != float64(500) || sent["fee"] != float64(100) || sent["address"] != "self-addr" {
+		t.Errorf("tx_send params = %v", sent)
+	}
+
+	//合并中的UTXO已锁定，不会重复合并
+	sent = nil
+	txID, err = wm.ConsolidateDust()
+	if err != nil || len(txID) > 0 || sent != nil {
+		t.Errorf("ConsolidateDust again = %s, %v", txID, err)
+	}
+}
@@ -0,0 +1,108 @@
+package beam
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//DustDeposit 低于最小充值金额的充值记录，不入账，用于审计粉尘攻击
+type DustDeposit struct {
+	ID          string `storm:"id"` //与入账账本记录的ID相同
+	TxID        string `storm:"index"`
+	Address     string `storm:"index"`
+	AccountID   string
+	Amount      string
+	Comment     string
+	BlockHeight uint64
+	BlockHash   string
+	CreateTime  int64 //交易时间
+	RecordTime  int64 //记录时间
+	Notified    bool  //是否已带dust标记通知观测者
+}
+
+//isDustDeposit 提取结果是否为低于最小充值金额的BEAM充值，只有输出没有输入的提取结果为充值
+func (wm *WalletManager) isDustDeposit(data *openwallet.TxExtractData) bool {
+
+	if len(wm.Config.mindeposit) == 0 || data.Transaction == nil {
+		return false
+	}
+	if len(data.TxInputs) > 0 || len(data.TxOutputs) == 0 || data.Transaction.Coin.IsContract {
+		return false
+	}
+
+	min, err := decimal.NewFromString(wm.Config.mindeposit)
+	if err != nil || min.Sign() <= 0 {
+		return false
+	}
+	amount, err := decimal.NewFromString(data.Transaction.Amount)
+	if err != nil {
+		return false
+	}
+	return amount.LessThan(min)
+}
+
+//saveDustDeposit 记录粉尘充值，已存在的记录更新通知状态
+func (wm *WalletManager) saveDustDeposit(sourceKey string, data *openwallet.TxExtractData, notified bool) error {
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	comment := data.Transaction.GetExtParam().Get("comment").String()
+	now := time.Now().Unix()
+	for _, output := range data.TxOutputs {
+		r := &DustDeposit{
+			ID:          ledgerRecordID(output.Recharge.TxID, output.Recharge.Address, LedgerDirectionIn),
+			TxID:        output.Recharge.TxID,
+			Address:     output.Recharge.Address,
+			AccountID:   sourceKey,
+			Amount:      output.Recharge.Amount,
+			Comment:     comment,
+			BlockHeight: output.Recharge.BlockHeight,
+			BlockHash:   output.Recharge.BlockHash,
+			CreateTime:  output.Recharge.CreateAt,
+			RecordTime:  now,
+			Notified:    notified,
+		}
+		var exist DustDeposit
+		if findErr := db.One("ID", r.ID, &exist); findErr == nil {
+			r.RecordTime = exist.RecordTime
+		}
+		if err = db.Save(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ListDustDeposits 列出粉尘充值记录，address为空时列出全部，按交易时间排序
+func (wm *WalletManager) ListDustDeposits(address string) ([]*DustDeposit, error) {
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*DustDeposit
+	if len(address) > 0 {
+		err = db.Find("Address", address, &list)
+	} else {
+		err = db.All(&list)
+	}
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreateTime < list[j].CreateTime
+	})
+	return list, nil
+}
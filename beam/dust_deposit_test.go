@@ -0,0 +1,76 @@
+package beam
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+type dustTestObserver struct {
+	notified []*openwallet.TxExtractData
+}
+
+func (o *dustTestObserver) BlockScanNotify(header *openwallet.BlockHeader) error {
+	return nil
+}
+
+func (o *dustTestObserver) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	o.notified = append(o.notified, data)
+	return nil
+}
+
+func TestBEAMBlockScanner_DustDeposit(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.mindeposit = "0.001"
+	scanner := NewBEAMBlockScanner(wm)
+	observer := &dustTestObserver{}
+	scanner.AddObserver(observer)
+
+	extract := func(tx string) map[string][]*openwallet.TxExtractData {
+		result := gjson.Parse(tx)
+		trx := NewTransaction(&result)
+		return scanner.ExtractTransaction(context.Background(), 10, "h10", trx, func(target openwallet.ScanTarget) (string, bool) {
+			if target.Address == "deposit" {
+				return "user", true
+			}
+			return "", false
+		}).extractData
+	}
+
+	//1000 groth = 0.00001 BEAM，低于最小充值金额
+	scanner.newExtractDataNotify(10, extract(`{"txId":"dust","income":true,"sender":"attacker","receiver":"deposit","value":1000,"fee":100}`))
+	scanner.newExtractDataNotify(10, extract(`{"txId":"real","income":true,"sender":"payer","receiver":"deposit","value":100000000,"fee":100}`))
+	//出账不受最小充值金额限制
+	scanner.newExtractDataNotify(10, extract(`{"txId":"out","income":false,"sender":"deposit","receiver":"other","value":1000,"fee":100}`))
+
+	if len(observer.notified) != 2 || observer.notified[0].Transaction.TxID != "real" || observer.notified[1].Transaction.TxID != "out" {
+		t.Errorf("notified = %d, want real and out", len(observer.notified))
+	}
+
+	list, err := wm.ListDustDeposits("deposit")
+	if err != nil || len(list) != 1 {
+		t.Errorf("ListDustDeposits = %d, err = %v", len(list), err)
+		return
+	}
+	if list[0].TxID != "dust" || list[0].AccountID != "user" || list[0].Amount != "0.00001" || list[0].Notified {
+		t.Errorf("dust deposit = %+v", list[0])
+	}
+	if records, _ := wm.GetLedgerRecordsByTxID("dust"); len(records) != 0 {
+		t.Errorf("dust deposit should not be recorded in ledger")
+	}
+
+	//开启粉尘充值通知时带dust标记
+	wm.Config.notifydustdeposit = true
+	observer.notified = nil
+	scanner.newExtractDataNotify(10, extract(`{"txId":"dust","income":true,"sender":"attacker","receiver":"deposit","value":1000,"fee":100}`))
+	if len(observer.notified) != 1 || !observer.notified[0].Transaction.GetExtParam().Get("dust").Bool() {
+		t.Errorf("dust deposit should be notified with dust flag")
+	}
+	if list, _ = wm.ListDustDeposits(""); len(list) != 1 || !list[0].Notified {
+		t.Errorf("dust deposits = %d, want 1 notified", len(list))
+	}
+}
@@ -0,0 +1,135 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/blocktree/openwallet/log"
+	"github.com/imroc/req"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//区块数据来源
+	BlockSourceExplorer = "explorer" //浏览器节点REST API
+	BlockSourceWallet   = "wallet"   //钱包API的block_details，只能按高度查询，没有内核和输出数据
+)
+
+//ExplorerClient beam浏览器节点的REST API，提供钱包API没有的区块数据
+type ExplorerClient struct {
+	URL    string
+	Debug  bool
+	client *req.Req
+	opts   *rpcOptions
+}
+
+// get GET request, the request is aborted when ctx is done.
+func (c *ExplorerClient) get(ctx context.Context, path string) (result *gjson.Result, err error) {
+
+	if c.client == nil || len(c.URL) == 0 {
+		return nil, fmt.Errorf("API url is not setup. ")
+	}
+
+	if c.Debug {
+		log.Std.Info("Start Request API...")
+	}
+
+	method := explorerMethod(path)
+	path = c.URL + "/" + path
+
+	defer func(start time.Time) {
+		c.opts.observe(rpcAPIExplorer, method, start, err)
+	}(time.Now())
+
+	//浏览器API都是只读请求，遇到临时错误时按重试策略重试
+	var r *req.Resp
+	err = c.opts.retry(ctx, true, func() (err error) {
+		attemptCtx, cancel := c.opts.withTimeout(ctx, method)
+		defer cancel()
+		start := time.Now()
+		r, err = c.client.Get(path, attemptCtx)
+		if err == nil {
+			_, err = r.ToBytes()
+		}
+		if c.opts.tracer != nil {
+			var (
+				response []byte
+				status   int
+			)
+			if r != nil && r.Response() != nil {
+				response, status = r.Bytes(), r.Response().StatusCode
+			}
+			c.opts.tracer.trace(newTraceID(), rpcAPIExplorer, method, path, start, nil, response, status, err)
+		}
+		if err == nil && r.Response().StatusCode >= http.StatusInternalServerError {
+			err = isError(r)
+		}
+		return err
+	})
+
+	if c.Debug {
+		log.Std.Info("Request API Completed")
+	}
+
+	if c.Debug {
+		log.Std.Info("%+v", r)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp := gjson.ParseBytes(r.Bytes())
+	err = isError(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+//GetBlockchainInfo
+func (c *ExplorerClient) GetBlockchainInfo(ctx context.Context) (*BlockchainInfo, error) {
+
+	r, err := c.get(ctx, "status")
+	if err != nil {
+		return nil, err
+	}
+	chain := NewBlockchainInfo(r)
+	return chain, nil
+}
+
+//GetBlockByHeight
+func (c *ExplorerClient) GetBlockByHeight(ctx context.Context, height uint64) (*Block, error) {
+	path := fmt.Sprintf("block?height=%d", height)
+	r, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	block := NewBlock(r)
+	return block, nil
+}
+
+//GetBlockByHash
+func (c *ExplorerClient) GetBlockByHash(ctx context.Context, hash string) (*Block, error) {
+	path := fmt.Sprintf("block?hash=%s", hash)
+	r, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	block := NewBlock(r)
+	return block, nil
+}
+
+//GetBlockByKernel
+func (c *ExplorerClient) GetBlockByKernel(ctx context.Context, kernel string) (*Block, error) {
+	path := fmt.Sprintf("block?kernel=%s", kernel)
+	r, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	block := NewBlock(r)
+	return block, nil
+}
@@ -0,0 +1,44 @@
+package beam
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWalletClient_BlockSources(t *testing.T) {
+
+	explorer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer explorer.Close()
+
+	wallet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"block_hash":"c2a7","previous_block":"4b9e","height":20516,"timestamp":1550157362}}`))
+	}))
+	defer wallet.Close()
+
+	c := NewWalletClient(wallet.URL, explorer.URL, false)
+	c.SetRetryPolicy(RetryPolicy{})
+
+	if _, err := c.GetBlockByHeight(context.Background(), 20516); err == nil {
+		t.Errorf("GetBlockByHeight should fail when explorer is unavailable")
+	}
+
+	//浏览器API不可用时使用钱包API
+	c.SetBlockSources([]string{BlockSourceExplorer, BlockSourceWallet})
+	block, err := c.GetBlockByHeight(context.Background(), 20516)
+	if err != nil {
+		t.Errorf("GetBlockByHeight failed unexpected error: %v", err)
+		return
+	}
+	if block.Hash != "c2a7" || block.PrevBlockHash != "4b9e" || block.Height != 20516 || block.KernelCount != -1 {
+		t.Errorf("unexpected block: %+v", block)
+	}
+
+	//钱包API不能按hash查询
+	if _, err := c.GetBlockByHash(context.Background(), "c2a7"); err == nil {
+		t.Errorf("GetBlockByHash should fail when explorer is unavailable")
+	}
+}
@@ -0,0 +1,171 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+const (
+	//提现金额的计算方式
+	WithdrawModeFixed = "fixed" //发送金额为提现金额，使用固定手续费
+	WithdrawModeExact = "exact" //发送金额为提现金额，手续费由钱包calc_change计算
+	WithdrawModeNet   = "net"   //接收方到账金额为提现金额减去钱包calc_change计算的手续费
+)
+
+//FeeParams 交易的内核、输出和隐私输入输出数量，普通输入不收手续费
+type FeeParams struct {
+	Kernels         int
+	Outputs         int
+	ShieldedInputs  int
+	ShieldedOutputs int
+}
+
+//MinFee 按Beam的最低手续费规则计算，单位groth
+func (p FeeParams) MinFee() uint64 {
+	fee := uint64(p.Kernels)*FeeKernel +
+		uint64(p.Outputs)*FeeOutput +
+		uint64(p.ShieldedInputs)*FeeShieldedInput +
+		uint64(p.ShieldedOutputs)*FeeShieldedOutput
+	if fee < MinRegularFee {
+		fee = MinRegularFee
+	}
+	return fee
+}
+
+//isShieldedAddressType 发送到离线和最大隐私地址的输出为隐私输出
+func isShieldedAddressType(addressType string) bool {
+	switch addressType {
+	case AddressTypeOffline, AddressTypeMaxPrivacy, AddressTypePublicOffline:
+		return true
+	}
+	return false
+}
+
+//isShieldedTxType 发送到隐私池或花费隐私池UTXO的交易
+func isShieldedTxType(txType int64) bool {
+	return txType == TxTypePushTransaction || txType == TxTypePullTransaction
+}
+
+//addInputs 花费的隐私池UTXO计入隐私输入
+func (p *FeeParams) addInputs(coins []*Utxo) {
+	for _, utxo := range coins {
+		if utxo.IsShielded() {
+			p.ShieldedInputs++
+		}
+	}
+}
+
+//applyFee 最低手续费乘以配置的倍数，不低于配置的固定手续费
+func (wm *WalletManager) applyFee(fee uint64) uint64 {
+	if wm.Config.feemultiplier > 1 {
+		fee = uint64(math.Ceil(float64(fee) * wm.Config.feemultiplier))
+	}
+	if fixFee := wm.fixFee(); fee < fixFee {
+		fee = fixFee
+	}
+	return fee
+}
+
+//DefaultFee 一个输出加找零的普通交易的手续费
+func (wm *WalletManager) DefaultFee() uint64 {
+	return wm.applyFee(FeeParams{Kernels: 1, Outputs: 2}.MinFee())
+}
+
+//EstimateFee 估算向outputs地址各发送一个输出、共发送amount的手续费，
+//按地址类型区分普通输出和隐私输出，钱包计算没有找零时不计找零输出
+func (wm *WalletManager) EstimateFee(amount uint64, outputs []string) (uint64, error) {
+
+	params := FeeParams{Kernels: 1}
+	for _, address := range outputs {
+		v, err := wm.walletClient.GetAddressValidation(context.Background(), address)
+		if err != nil {
+			return 0, err
+		}
+		if isShieldedAddressType(v.Type) {
+			params.ShieldedOutputs++
+		} else {
+			params.Outputs++
+		}
+	}
+
+	//钱包不支持calc_change时按有找零计算
+	change, err := wm.walletClient.CalcChange(context.Background(), amount, params.MinFee())
+	if err != nil || change.Change > 0 {
+		params.Outputs++
+	}
+
+	return wm.applyFee(params.MinFee()), nil
+}
+
+//ChangeResult calc_change的计算结果
+type ChangeResult struct {
+	Change      uint64 //找零
+	ExplicitFee uint64 //钱包实际收取的手续费
+}
+
+//CalcChange 计算发送指定金额的找零和手续费，fee为0时使用钱包的最低手续费
+func (c *WalletClient) CalcChange(ctx context.Context, amount, fee uint64) (*ChangeResult, error) {
+
+	request := map[string]interface{}{
+		"amount": amount,
+	}
+	if fee > 0 {
+		request["fee"] = fee
+	}
+
+	r, err := c.callContext(ctx, "calc_change", request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChangeResult{
+		Change:      r.Get("change").Uint(),
+		ExplicitFee: r.Get("explicit_fee").Uint(),
+	}, nil
+}
+
+//WithdrawalAmount 提现实际发送的金额、手续费和找零
+type WithdrawalAmount struct {
+	Send   uint64 //接收方到账金额
+	Fee    uint64 //手续费
+	Change uint64 //找零，固定手续费方式不计算
+}
+
+//Total 钱包需要支付的总额
+func (w *WithdrawalAmount) Total() uint64 {
+	return w.Send + w.Fee
+}
+
+//CalcWithdrawal 按提现方式计算提现金额amount实际发送的金额和手续费，fee为最低手续费
+func (wm *WalletManager) CalcWithdrawal(amount, fee uint64, mode string) (*WithdrawalAmount, error) {
+
+	switch mode {
+	case WithdrawModeFixed, "":
+		return &WithdrawalAmount{Send: amount, Fee: fee}, nil
+	case WithdrawModeExact:
+		change, err := wm.walletClient.CalcChange(context.Background(), amount, fee)
+		if err != nil {
+			return nil, err
+		}
+		return &WithdrawalAmount{Send: amount, Fee: change.ExplicitFee, Change: change.Change}, nil
+	case WithdrawModeNet:
+		//手续费与发送金额有关，扣除手续费后重新计算，手续费变化时再扣除一次
+		for i := 0; i < 2; i++ {
+			if amount <= fee {
+				return nil, fmt.Errorf("withdrawal amount %d not enough to pay fee %d", amount, fee)
+			}
+			change, err := wm.walletClient.CalcChange(context.Background(), amount-fee, fee)
+			if err != nil {
+				return nil, err
+			}
+			if change.ExplicitFee <= fee {
+				return &WithdrawalAmount{Send: amount - fee, Fee: fee, Change: change.Change}, nil
+			}
+			fee = change.ExplicitFee
+		}
+		return nil, fmt.Errorf("withdrawal fee of amount %d is not stable", amount)
+	default:
+		return nil, fmt.Errorf("unknown withdrawmode: %s", mode)
+	}
+}
@@ -0,0 +1,141 @@
+package beam
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//newCalcChangeServer 手续费为100，发送金额超过1000时为200
+func newCalcChangeServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		var body struct {
+			Params struct {
+				Amount uint64 `json:"amount"`
+				Fee    uint64 `json:"fee"`
+			} `json:"params"`
+		}
+		json.Unmarshal(data, &body)
+		fee := uint64(100)
+		if body.Params.Amount > 1000 {
+			fee = 200
+		}
+		if body.Params.Fee > fee {
+			fee = body.Params.Fee
+		}
+		w.Write([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":{"change":5,"change_str":"5","explicit_fee":%d}}`, fee)))
+	}))
+}
+
+func TestWalletManager_CalcWithdrawal(t *testing.T) {
+
+	server := newCalcChangeServer()
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	tests := []struct {
+		amount, fee uint64
+		mode        string
+		send, total uint64
+	}{
+		{500, 10, WithdrawModeFixed, 500, 510},
+		{500, 10, WithdrawModeExact, 500, 600},
+		{500, 10, WithdrawModeNet, 400, 500},
+		{2000, 10, WithdrawModeExact, 2000, 2200},
+		{1150, 100, WithdrawModeNet, 950, 1150},
+		{1500, 100, WithdrawModeNet, 1300, 1500},
+	}
+	for _, tt := range tests {
+		w, err := wm.CalcWithdrawal(tt.amount, tt.fee, tt.mode)
+		if err != nil {
+			t.Errorf("CalcWithdrawal(%d, %s) unexpected error: %v", tt.amount, tt.mode, err)
+			continue
+		}
+		if w.Send != tt.send || w.Total() != tt.total {
+			t.Errorf("CalcWithdrawal(%d, %s) = %+v, want send %d total %d", tt.amount, tt.mode, w, tt.send, tt.total)
+		}
+	}
+
+	if _, err := wm.CalcWithdrawal(100, 10, WithdrawModeNet); err == nil {
+		t.Errorf("CalcWithdrawal should fail when amount not enough to pay fee")
+	}
+	if _, err := wm.CalcWithdrawal(100, 10, "unknown"); err == nil {
+		t.Errorf("CalcWithdrawal should fail with unknown mode")
+	}
+}
+
+func TestFeeParams_MinFee(t *testing.T) {
+
+	tests := []struct {
+		params FeeParams
+		want   uint64
+	}{
+		{FeeParams{Kernels: 1, Outputs: 2}, MinRegularFee},
+		{FeeParams{Kernels: 1, Outputs: 10}, 110},
+		{FeeParams{Kernels: 1, Outputs: 1, ShieldedOutputs: 1}, 1000020},
+		{FeeParams{Kernels: 1, Outputs: 1, ShieldedInputs: 2}, 2020},
+	}
+	for _, tt := range tests {
+		if got := tt.params.MinFee(); got != tt.want {
+			t.Errorf("%+v MinFee = %d, want %d", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestWalletManager_EstimateFee(t *testing.T) {
+
+	server := newRPCTestServer(rpcTestMethods{
+		"validate_address": func(params map[string]interface{}) (string, error) {
+			addressType := "regular"
+			if params["address"] == "shielded" {
+				addressType = "max_privacy"
+			}
+			return fmt.Sprintf(`{"is_valid":true,"is_mine":false,"type":"%s"}`, addressType), nil
+		},
+		"calc_change": func(params map[string]interface{}) (string, error) {
+			//金额为1000时正好没有找零
+			change := 5
+			if params["amount"] == float64(1000) {
+				change = 0
+			}
+			return fmt.Sprintf(`{"change":%d,"explicit_fee":100}`, change), nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	wm.Config.fixfees = "0"
+
+	tests := []struct {
+		amount     uint64
+		outputs    []string
+		multiplier float64
+		want       uint64
+	}{
+		{500, []string{"regular"}, 1, MinRegularFee},
+		{1000, []string{"shielded"}, 1, 1000010},
+		{500, []string{"shielded"}, 1, 1000020},
+		{500, []string{"regular"}, 1.5, 150},
+	}
+	for _, tt := range tests {
+		wm.Config.feemultiplier = tt.multiplier
+		fee, err := wm.EstimateFee(tt.amount, tt.outputs)
+		if err != nil || fee != tt.want {
+			t.Errorf("EstimateFee(%d, %v) x %v = %d, %v, want %d", tt.amount, tt.outputs, tt.multiplier, fee, err, tt.want)
+		}
+	}
+
+	//不低于配置的固定手续费
+	wm.Config.feemultiplier = 1
+	wm.Config.fixfees = "0.00001"
+	if fee, _ := wm.EstimateFee(500, []string{"regular"}); fee != 1000 {
+		t.Errorf("EstimateFee with fixfees = %d, want 1000", fee)
+	}
+}
@@ -0,0 +1,196 @@
+package beam
+
+import (
+	"fmt"
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/crypto"
+	"github.com/blocktree/openwallet/openwallet"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	//账本记录方向
+	LedgerDirectionIn  = "in"  //入账
+	LedgerDirectionOut = "out" //出账
+
+	//账本记录来源
+	LedgerSourceScanner = "scanner" //区块扫描器提取
+	LedgerSourceLegacy  = "legacy"  //从其他BEAM集成迁移导入
+)
+
+//LedgerRecord 地址账本记录，每笔交易的每个地址每个方向一条记录
+type LedgerRecord struct {
+	ID          string `storm:"id"` // primary key
+	TxID        string `storm:"index"`
+	Address     string `storm:"index"`
+	AccountID   string
+	Direction   string
+	Amount      string
+	Fees        string
+	BlockHeight uint64 `storm:"index"`
+	BlockHash   string
+	CreateTime  int64
+	Source      string
+	AssetID     int64  //机密资产ID，BEAM为0
+	Peer        string //对方地址
+}
+
+func NewLedgerRecord(txID, address, direction string) *LedgerRecord {
+	obj := LedgerRecord{}
+	obj.TxID = txID
+	obj.Address = address
+	obj.Direction = direction
+	obj.ID = ledgerRecordID(txID, address, direction)
+	return &obj
+}
+
+func ledgerRecordID(txID, address, direction string) string {
+	return common.Bytes2Hex(crypto.SHA256([]byte(fmt.Sprintf("%s_%s_%s", txID, address, direction))))
+}
+
+//newLedgerRecords 把提取结果转为账本记录，输入为出账，输出为入账
+func newLedgerRecords(sourceKey string, data *openwallet.TxExtractData) []*LedgerRecord {
+
+	var (
+		records = make([]*LedgerRecord, 0)
+		exist   = make(map[string]bool)
+		tx      = data.Transaction
+	)
+
+	if tx == nil {
+		return records
+	}
+
+	assetID, _ := ParseAssetID(tx.Coin)
+
+	add := func(recharge openwallet.Recharge, direction string) {
+		r := NewLedgerRecord(recharge.TxID, recharge.Address, direction)
+		//同一地址同一方向只记录一次，手续费记录在Fees
+		if exist[r.ID] {
+			return
+		}
+		exist[r.ID] = true
+		r.AccountID = sourceKey
+		r.Amount = recharge.Amount
+		r.BlockHeight = recharge.BlockHeight
+		r.BlockHash = recharge.BlockHash
+		r.CreateTime = recharge.CreateAt
+		r.Source = LedgerSourceScanner
+		r.AssetID = assetID
+		if direction == LedgerDirectionOut {
+			r.Fees = tx.Fees
+			r.Peer = ledgerPeer(tx.To)
+		} else {
+			r.Peer = ledgerPeer(tx.From)
+		}
+		records = append(records, r)
+	}
+
+	for _, input := range data.TxInputs {
+		add(input.Recharge, LedgerDirectionOut)
+	}
+
+	for _, output := range data.TxOutputs {
+		add(output.Recharge, LedgerDirectionIn)
+	}
+
+	return records
+}
+
+//ledgerOtherDirection 账本记录的另一方向
+func ledgerOtherDirection(direction string) string {
+	if direction == LedgerDirectionIn {
+		return LedgerDirectionOut
+	}
+	return LedgerDirectionIn
+}
+
+//ledgerPeer 提取结果中的对方地址，格式为address:amount
+func ledgerPeer(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	i := strings.LastIndex(list[0], ":")
+	if i < 0 {
+		return list[0]
+	}
+	return list[0][:i]
+}
+
+//SaveLedgerRecords 保存账本记录，已存在的记录不覆盖
+func (wm *WalletManager) SaveLedgerRecords(records ...*LedgerRecord) error {
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range records {
+		var exist LedgerRecord
+		if findErr := tx.One("ID", r.ID, &exist); findErr == nil {
+			continue
+		}
+		//同一交易单另一方向的记录已保存时，地址统计不重复计入交易单数量
+		counted := tx.One("ID", ledgerRecordID(r.TxID, r.Address, ledgerOtherDirection(r.Direction)), &exist) == nil
+		if err = tx.Save(r); err != nil {
+			return err
+		}
+		if err = updateAddressStats(tx, r, counted); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//GetLedgerRecordsByTxID 获取交易单相关的账本记录
+func (wm *WalletManager) GetLedgerRecordsByTxID(txID string) ([]*LedgerRecord, error) {
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*LedgerRecord
+	err = db.Find("TxID", txID, &list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+//IsLegacyCredited 交易单是否已在迁移前的集成中入账，已入账的交易不再通知，防止重复入账
+func (wm *WalletManager) IsLegacyCredited(txID string) bool {
+	list, err := wm.GetLedgerRecordsByTxID(txID)
+	if err != nil {
+		return false
+	}
+	for _, r := range list {
+		if r.Source == LedgerSourceLegacy {
+			return true
+		}
+	}
+	return false
+}
+
+//recordLedger 把提取结果记录到账本
+func (bs *BEAMBlockScanner) recordLedger(sourceKey string, data *openwallet.TxExtractData) {
+	records := newLedgerRecords(sourceKey, data)
+	if len(records) == 0 {
+		return
+	}
+	err := bs.wm.SaveLedgerRecords(records...)
+	if err != nil {
+		bs.wm.Log.Errorf("save ledger records failed, unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package beam
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//LegacyRecord 其他BEAM集成导出的充值提现记录
+type LegacyRecord struct {
+	TxID        string `json:"txid"`
+	Address     string `json:"address"`
+	Direction   string `json:"direction"` //deposit/in，withdrawal/out
+	Amount      string `json:"amount"`
+	BlockHeight uint64 `json:"height"`
+	AccountID   string `json:"accountid"`
+	CreateTime  int64  `json:"time"`
+}
+
+//LegacyImportResult 迁移导入结果
+type LegacyImportResult struct {
+	Imported int
+	Skipped  int
+}
+
+//ImportLegacyHistory 导入其他BEAM集成导出的充值提现记录（CSV或JSON），写入本地账本，
+//扫描器遇到已导入的交易不再通知，避免迁移后重复入账
+func (wm *WalletManager) ImportLegacyHistory(filePath string) (*LegacyImportResult, error) {
+
+	var (
+		records []*LegacyRecord
+		err     error
+	)
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		err = json.NewDecoder(f).Decode(&records)
+	case ".csv":
+		records, err = readLegacyCSV(f)
+	default:
+		return nil, fmt.Errorf("unsupported legacy file format: %s", filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LegacyImportResult{}
+	ledger := make([]*LedgerRecord, 0, len(records))
+	for i, r := range records {
+		direction, err := legacyDirection(r.Direction)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+		if len(r.TxID) == 0 || len(r.Address) == 0 {
+			return nil, fmt.Errorf("record %d: txid and address are required", i+1)
+		}
+
+		exist, err := wm.GetLedgerRecordsByTxID(r.TxID)
+		if err != nil {
+			return nil, err
+		}
+		if len(exist) > 0 {
+			result.Skipped++
+			continue
+		}
+
+		l := NewLedgerRecord(r.TxID, r.Address, direction)
+		l.AccountID = r.AccountID
+		l.Amount = r.Amount
+		l.BlockHeight = r.BlockHeight
+		l.CreateTime = r.CreateTime
+		if l.CreateTime == 0 {
+			l.CreateTime = time.Now().Unix()
+		}
+		l.Source = LedgerSourceLegacy
+		ledger = append(ledger, l)
+		result.Imported++
+	}
+
+	err = wm.SaveLedgerRecords(ledger...)
+	if err != nil {
+		return nil, err
+	}
+
+	wm.Log.Infof("import legacy history: %d imported, %d skipped", result.Imported, result.Skipped)
+
+	return result, nil
+}
+
+//readLegacyCSV 读取CSV，第一行为列名
+func readLegacyCSV(r io.Reader) ([]*LegacyRecord, error) {
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("legacy csv file is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	get := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	records := make([]*LegacyRecord, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		r := &LegacyRecord{
+			TxID:      get(row, "txid"),
+			Address:   get(row, "address"),
+			Direction: get(row, "direction"),
+			Amount:    get(row, "amount"),
+			AccountID: get(row, "accountid"),
+		}
+		if height := get(row, "height"); len(height) > 0 {
+			r.BlockHeight, err = strconv.ParseUint(height, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if ts := get(row, "time"); len(ts) > 0 {
+			r.CreateTime, err = strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+func legacyDirection(direction string) (string, error) {
+	switch strings.ToLower(direction) {
+	case "deposit", LedgerDirectionIn:
+		return LedgerDirectionIn, nil
+	case "withdrawal", "withdraw", LedgerDirectionOut:
+		return LedgerDirectionOut, nil
+	}
+	return "", fmt.Errorf("unknown direction: %s", direction)
+}
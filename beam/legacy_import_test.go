@@ -0,0 +1,30 @@
+package beam
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadLegacyCSV(t *testing.T) {
+	data := `txid,address,direction,amount,height,accountid
+72f8f349f9244b11b0e6471250ca68a1,21aff5eb4da2591321ac12bb280ac69ea39a33472166c600ec122cf3381b6c9e772,deposit,0.0001,221412,account1
+`
+	records, err := readLegacyCSV(strings.NewReader(data))
+	if err != nil {
+		t.Errorf("readLegacyCSV failed unexpected error: %v", err)
+		return
+	}
+	if len(records) != 1 {
+		t.Errorf("records count = %d, want 1", len(records))
+		return
+	}
+	r := records[0]
+	if r.TxID != "72f8f349f9244b11b0e6471250ca68a1" || r.BlockHeight != 221412 || r.AccountID != "account1" {
+		t.Errorf("unexpected record: %+v", r)
+	}
+
+	direction, err := legacyDirection(r.Direction)
+	if err != nil || direction != LedgerDirectionIn {
+		t.Errorf("legacyDirection(%s) = %s, %v", r.Direction, direction, err)
+	}
+}
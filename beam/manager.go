@@ -1,6 +1,7 @@
 package beam
 
 import (
+	"context"
 	"fmt"
 	"github.com/blocktree/openwallet/common"
 	"github.com/blocktree/openwallet/common/file"
@@ -11,34 +12,91 @@ import (
 	"github.com/shopspring/decimal"
 	"math/big"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 type WalletManager struct {
 	openwallet.AssetsAdapterBase
 
-	node            *owtp.OWTPNode
-	Config          *WalletConfig                   // 节点配置
-	Decoder         openwallet.AddressDecoder       //地址编码器
-	TxDecoder       openwallet.TransactionDecoder   //交易单编码器
-	Log             *log.OWLogger                   //日志工具
-	ContractDecoder openwallet.SmartContractDecoder //智能合约解析器
-	Blockscanner    *BEAMBlockScanner               //区块扫描器
-	walletClient    *WalletClient                   //本地封装的http client
-	client          *Client                         //节点作为客户端
-	server          *Server                         //节点作为服务端
+	node                  *owtp.OWTPNode
+	Config                *WalletConfig                   // 节点配置
+	Decoder               openwallet.AddressDecoderV2     //地址编码器
+	TxDecoder             openwallet.TransactionDecoder   //交易单编码器
+	Log                   *log.OWLogger                   //日志工具
+	ContractDecoder       openwallet.SmartContractDecoder //智能合约解析器
+	Blockscanner          *BEAMBlockScanner               //区块扫描器
+	walletClient          WalletAPI                       //钱包节点操作，默认为本地封装的http client
+	client                *Client                         //节点作为客户端
+	server                *Server                         //节点作为服务端
+	blockPruner           *timer.TaskTimer                //本地区块清理任务
+	Metrics               *Metrics                        //监控指标
+	walletEvents          *WalletEventListener            //钱包事件订阅
+	withdrawals           *WithdrawalLimiter              //提现数量限制
+	blockCache            *BlockCache                     //最近区块缓存
+	walletEndpointChecker *timer.TaskTimer                //钱包API节点健康检查任务
+	dustConsolidator      *timer.TaskTimer                //碎片UTXO合并任务
+	expiredTxWatcher      *timer.TaskTimer                //发送超时交易单检查任务
+	txResender            *timer.TaskTimer                //发送失败交易单重发任务
+	txPrefetch            *txPrefetch                     //追块时批量预取的交易单
+	utxoLocks             *utxoLockTable                  //提现交易占用的UTXO
+	withdrawalQueue       *WithdrawalQueue                //按幂等键提交的提现队列
+	assetRegistry         *AssetRegistry                  //机密资产登记表
+	reservations          *ReservationLedger              //未广播提现的余额预留
+	withdrawalPolicy      *WithdrawalPolicyEngine         //提现策略
+	withdrawalWhitelist   *WithdrawalWhitelist            //提现地址白名单
+	addressBook           *AddressBook                    //地址簿
+	addressPool           *AddressPool                    //充值地址池
+	addressExpiryWatcher  *timer.TaskTimer                //地址过期检查任务
+	addressMapper         *AddressMapper                  //地址别名映射
+	accountAddressMu      *sync.Mutex                     //按账户创建地址的互斥锁
+	summaryRunning        chan struct{}                   //正在执行的汇总，定时和手动触发不同时执行
+	secretProviders       *SecretProviders                //敏感配置提供者
+	addressExpiryNotified map[string]int64                //已通知即将过期的地址
+
+	summarySkippedHandlers []SummarySkippedHandler         //汇总跳过事件处理
+	expiredTxHandlers      []ExpiredTxHandler              //发送超时交易单告警处理
+	addressExpiryHandlers  []AddressExpiryHandler          //地址过期处理
+	lateDepositHandlers    []LateDepositHandler            //归档地址收到付款告警处理
+	sendFailureHandlers    map[string][]SendFailureHandler //发送失败分类处理
 }
 
 func NewWalletManager() *WalletManager {
 	wm := WalletManager{}
 	wm.Config = NewConfig(Symbol)
+	wm.Metrics = NewMetrics()
 	wm.Blockscanner = NewBEAMBlockScanner(&wm)
-	//wm.Decoder = NewAddressDecoder(&wm)
+	wm.withdrawals = NewWithdrawalLimiter(&wm)
+	wm.blockCache = NewBlockCache(DefaultBlockCacheSize)
+	wm.txPrefetch = newTxPrefetch()
+	wm.utxoLocks = newUtxoLockTable(&wm)
+	wm.withdrawalQueue = newWithdrawalQueue(&wm)
+	wm.assetRegistry = newAssetRegistry(&wm)
+	wm.reservations = newReservationLedger(&wm)
+	wm.withdrawalPolicy = newWithdrawalPolicyEngine(&wm)
+	wm.withdrawalWhitelist = newWithdrawalWhitelist(&wm)
+	wm.addressBook = newAddressBook(&wm)
+	wm.addressPool = newAddressPool(&wm)
+	wm.addressMapper = newAddressMapper(&wm)
+	wm.accountAddressMu = &sync.Mutex{}
+	wm.summaryRunning = make(chan struct{}, 1)
+	wm.secretProviders = newSecretProviders()
+	wm.sendFailureHandlers = make(map[string][]SendFailureHandler)
+	wm.addressExpiryNotified = make(map[string]int64)
+	wm.ContractDecoder = NewContractDecoder(&wm)
+	wm.Decoder = NewAddressDecoder(&wm)
 	wm.TxDecoder = NewTransactionDecoder(&wm)
 	wm.Log = log.NewOWLogger(wm.Symbol())
 	return &wm
 }
 
+//SetDBPath 设置本地数据库文件夹，默认为data/beam/db，在LoadAssetsConfig时创建
+func (wm *WalletManager) SetDBPath(dbPath string) {
+	wm.Config.dbPath = dbPath
+	file.MkdirAll(dbPath)
+}
+
 func (wm WalletManager) CreateRemoteWalletAddress(count, workerSize uint64) ([]string, error) {
 	if wm.Config.enableserver {
 		return nil, fmt.Errorf("server mode can not create remote address, use create local address")
@@ -69,7 +127,64 @@ func (wm WalletManager) GetRemoteWalletBalance() (*openwallet.Balance, error) {
 }
 
 func (wm WalletManager) CreateLocalWalletAddress(count, workerSize uint64) ([]string, error) {
-	return wm.walletClient.CreateBatchAddress(count, workerSize)
+	addrs, err := wm.walletClient.CreateBatchAddress(context.Background(), count, workerSize)
+	if len(addrs) > 0 {
+		wm.addressBook.addCreated(AddressTypeRegular, DefaultAddressComment, addrs...)
+	}
+	return addrs, err
+}
+
+//CreateAddress 创建一个地址，addressType为空时创建普通地址，expiration为空时永不过期，comment为空时标记为自己创建的地址；
+//离线、最大隐私和公开离线地址需要开启shielded，钱包API版本不低于v6
+func (wm *WalletManager) CreateAddress(addressType, expiration, comment string) (string, error) {
+
+	if len(addressType) == 0 {
+		addressType = AddressTypeRegular
+	}
+	err := wm.checkAddressType(addressType)
+	if err != nil {
+		return "", err
+	}
+
+	switch expiration {
+	case "":
+		expiration = AddressExpirationNever
+	case AddressExpiration24h, AddressExpirationNever, AddressExpirationAuto:
+	default:
+		return "", fmt.Errorf("unknown address expiration: %s", expiration)
+	}
+
+	if len(comment) == 0 {
+		comment = DefaultAddressComment
+	}
+
+	address, err := wm.walletClient.CreateAddressWithType(context.Background(), addressType, expiration, comment)
+	if err != nil {
+		return "", err
+	}
+
+	wm.addressBook.addCreated(addressType, comment, address)
+	return address, nil
+}
+
+//checkAddressType 检查是否支持创建该类型的地址，离线、最大隐私和公开离线地址需要开启shielded，钱包API版本不低于v6
+func (wm *WalletManager) checkAddressType(addressType string) error {
+
+	switch addressType {
+	case AddressTypeRegular:
+	case AddressTypeOffline, AddressTypeMaxPrivacy, AddressTypePublicOffline:
+		if !wm.IsFeatureEnabled(FeatureShielded) {
+			return fmt.Errorf("address type: %s requires feature shielded", addressType)
+		}
+		if c, ok := wm.httpWalletClient(); ok {
+			if v := c.WalletAPIVersion(); v != nil && v.Before(6, 0) {
+				return fmt.Errorf("address type: %s is not supported by wallet api v%s", addressType, v)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown address type: %s", addressType)
+	}
+	return nil
 }
 
 func (wm WalletManager) GetLocalWalletBalance() (*openwallet.Balance, error) {
@@ -86,13 +201,13 @@ func (wm WalletManager) GetLocalWalletBalance() (*openwallet.Balance, error) {
 }
 
 func (wm WalletManager) GetLocalWalletAddress() ([]string, error) {
-	return wm.walletClient.GetAddressList()
+	return wm.walletClient.GetAddressList(context.Background())
 }
 
 //GetTransactionsByHeight
 func (wm *WalletManager) GetTransaction(txid string) (*Transaction, error) {
 
-	localTx, err := wm.walletClient.GetTransaction(txid)
+	localTx, err := wm.walletClient.GetTransaction(context.Background(), txid)
 	if err != nil {
 		wm.Log.Errorf("Local GetTransaction failed, unexpected error %v", err)
 	}
@@ -116,12 +231,16 @@ func (wm *WalletManager) GetTransaction(txid string) (*Transaction, error) {
 }
 
 //GetTransactionsByHeight
-func (wm *WalletManager) GetTransactionsByHeight(height uint64) ([]*Transaction, error) {
+func (wm *WalletManager) GetTransactionsByHeight(ctx context.Context, height uint64) ([]*Transaction, error) {
 
+	var err error
 	trxMap := make(map[string]*Transaction, 0)
 	trxs := make([]*Transaction, 0)
 
-	localTrxs, err := wm.client.GetTransactionsByHeight(height)
+	localTrxs, ok := wm.txPrefetch.take(height)
+	if !ok {
+		localTrxs, err = wm.walletClient.GetTransactionsByHeight(ctx, height)
+	}
 	if err != nil {
 		wm.Log.Errorf("Local GetTransactionsByHeight failed, unexpected error %v", err)
 		return nil, err
@@ -151,6 +270,59 @@ func (wm *WalletManager) GetTransactionsByHeight(height uint64) ([]*Transaction,
 	return trxs, nil
 }
 
+//Shutdown 优雅关闭，停止扫描器并等待正在处理的区块完成，关闭服务端监听
+func (wm *WalletManager) Shutdown(ctx context.Context) error {
+
+	var err error
+
+	if wm.Blockscanner.Scanning {
+		err = wm.Blockscanner.Shutdown(ctx)
+		if err != nil {
+			wm.Log.Errorf("block scanner shutdown unexpected error: %v", err)
+		}
+	}
+
+	if wm.server != nil {
+		wm.server.Close()
+	}
+
+	if wm.walletEvents != nil {
+		wm.walletEvents.Stop()
+	}
+
+	wm.withdrawalQueue.Stop()
+
+	if c, ok := wm.httpWalletClient(); ok {
+		c.Close()
+	}
+
+	if wm.walletEndpointChecker != nil {
+		wm.walletEndpointChecker.Stop()
+	}
+
+	if wm.dustConsolidator != nil {
+		wm.dustConsolidator.Stop()
+	}
+
+	if wm.expiredTxWatcher != nil {
+		wm.expiredTxWatcher.Stop()
+	}
+
+	if wm.txResender != nil {
+		wm.txResender.Stop()
+	}
+
+	if wm.addressPool.task != nil {
+		wm.addressPool.task.Stop()
+	}
+
+	if wm.addressExpiryWatcher != nil {
+		wm.addressExpiryWatcher.Stop()
+	}
+
+	return err
+}
+
 func (wm WalletManager) GetRemoteBlockByHeight(height uint64) (*Block, error) {
 	//if wm.Config.enableserver {
 	//	return nil, fmt.Errorf("server mode can not create remote address, use create local address")
@@ -159,12 +331,41 @@ func (wm WalletManager) GetRemoteBlockByHeight(height uint64) (*Block, error) {
 	return wm.client.GetBlockByHeight(height)
 }
 
+//StartSummaryWallet 启动汇总任务，配置summarycron时按cron表达式执行，否则按summaryperiod周期执行
 func (wm *WalletManager) StartSummaryWallet() error {
 
 	var (
 		endRunning = make(chan bool, 1)
 	)
 
+	if !wm.IsFeatureEnabled(FeatureSummary) {
+		return fmt.Errorf("summary feature is disabled")
+	}
+
+	if len(wm.Config.summaryaddress) == 0 && len(wm.Config.summarysplit) == 0 {
+		return fmt.Errorf("summary address is not setup")
+	}
+
+	if len(wm.Config.summarythreshold) == 0 {
+		return fmt.Errorf("summary threshold is not setup")
+	}
+
+	if len(wm.Config.summarycron) > 0 {
+		schedule, err := ParseCronSchedule(wm.Config.summarycron)
+		if err != nil {
+			return err
+		}
+
+		wm.Log.Infof("The cron for summary task start now. Execute by schedule: %s", schedule)
+
+		//按cron表达式启动钱包汇总程序，不马上执行
+		go wm.runSummaryCron(schedule)
+
+		<-endRunning
+
+		return nil
+	}
+
 	cycleTime := wm.Config.summaryperiod
 	if len(cycleTime) == 0 {
 		cycleTime = "1m"
@@ -175,14 +376,6 @@ func (wm *WalletManager) StartSummaryWallet() error {
 		return err
 	}
 
-	if len(wm.Config.summaryaddress) == 0 {
-		return fmt.Errorf("summary address is not setup")
-	}
-
-	if len(wm.Config.summarythreshold) == 0 {
-		return fmt.Errorf("summary threshold is not setup")
-	}
-
 	wm.Log.Infof("The timer for summary task start now. Execute by every %v seconds.", cycleSec.Seconds())
 
 	//启动钱包汇总程序
@@ -197,35 +390,128 @@ func (wm *WalletManager) StartSummaryWallet() error {
 	return nil
 }
 
+//SummarySchedule 配置的汇总cron表达式，没有配置summarycron时返回nil
+func (wm *WalletManager) SummarySchedule() (*CronSchedule, error) {
+	if len(wm.Config.summarycron) == 0 {
+		return nil, nil
+	}
+	return ParseCronSchedule(wm.Config.summarycron)
+}
+
+//runSummaryCron 按cron表达式的时间执行汇总
+func (wm *WalletManager) runSummaryCron(schedule *CronSchedule) {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			wm.Log.Errorf("summary cron: %s never fires again, summary task stopped", schedule)
+			return
+		}
+		wm.Log.Infof("Next summary task at %s", next.Format("2006-01-02 15:04:05"))
+		time.Sleep(time.Until(next))
+		wm.SummaryWallets()
+	}
+}
+
 //SummaryWallets 执行汇总流程
 func (wm *WalletManager) SummaryWallets() {
+	wm.runSummary(SummaryTriggerSchedule)
+}
+
+//TriggerSummary 手动触发一次汇总，汇总正在执行或系统状态不佳被推迟时返回错误，余额未超过阈值时txid为空，
+//拆分汇总时返回逗号分隔的多个txid
+func (wm *WalletManager) TriggerSummary() (string, error) {
+
+	if len(wm.Config.summaryaddress) == 0 && len(wm.Config.summarysplit) == 0 {
+		return "", fmt.Errorf("summary address is not setup")
+	}
+
+	wm.Log.Infof("summary task triggered manually")
+	return wm.runSummary(SummaryTriggerManual)
+}
+
+//runSummary 检查系统状态后汇总到summaryaddress，然后清理超时的交易和UTXO锁，每次执行保存一份汇总报告
+func (wm *WalletManager) runSummary(trigger string) (string, error) {
+
+	select {
+	case wm.summaryRunning <- struct{}{}:
+		defer func() { <-wm.summaryRunning }()
+	default:
+		wm.Log.Warningf("summary task is running, skip this one")
+		return "", fmt.Errorf("summary task is running")
+	}
 
 	wm.Log.Infof("[Summary Task Start]------%s", common.TimeFormat("2006-01-02 15:04:05"))
 
-	txId, _, _, err := wm.SummaryWalletProcess(wm.Config.summaryaddress)
-	if err != nil {
-		wm.Log.Errorf("summary wallet unexpected error: %v", err)
+	var (
+		txId   string
+		err    error
+		report = newSummaryRunReport(trigger)
+	)
+
+	//系统状态不佳时推迟汇总，等待下一个周期
+	if event := wm.CheckSummaryHealth(); event != nil {
+		wm.emitSummarySkipped(event)
+		err = fmt.Errorf("summary task skipped, reason: %s, %s", event.Reason, event.Detail)
+		report.Status = SummaryStatusSkipped
+	} else if len(wm.Config.summarysplit) > 0 {
+		//按比例或固定金额拆分汇总到多个地址
+		splitReport, splitErr := wm.SummarySplitProcess(wm.Config.summarysplit)
+		if splitErr != nil {
+			err = splitErr
+			report.Status = SummaryStatusFailed
+			wm.Log.Errorf("summary wallet unexpected error: %v", err)
+		} else {
+			report.addSplitReport(splitReport)
+			txId = strings.Join(report.TxIDs, ",")
+		}
+
+		wm.Log.Infof("[Summary Task End] txId =%s ------%s", txId, common.TimeFormat("2006-01-02 15:04:05"))
+	} else {
+		var summaryAmount, fee string
+		txId, summaryAmount, fee, err = wm.SummaryWalletProcess(wm.Config.summaryaddress)
+		if err != nil {
+			wm.Log.Errorf("summary wallet unexpected error: %v", err)
+		}
+		report.addSummaryResult(wm.Config.summaryaddress, txId, summaryAmount, fee, err, wm.Decimal())
+
+		wm.Log.Infof("[Summary Task End] txId =%s ------%s", txId, common.TimeFormat("2006-01-02 15:04:05"))
 	}
 
-	wm.Log.Infof("[Summary Task End] txId =%s ------%s", txId, common.TimeFormat("2006-01-02 15:04:05"))
+	if err != nil {
+		report.Error = err.Error()
+	}
+	wm.saveSummaryReport(report)
+	if len(report.TxIDs) > 0 {
+		if saveErr := wm.saveLastSummarySweep(time.Now()); saveErr != nil {
+			wm.Log.Errorf("save last summary sweep time failed, unexpected error: %v", saveErr)
+		}
+	}
 
 	//:清楚超时的交易
 	wm.ClearExpireTx()
+	wm.ReleaseUtxoLocks()
+
+	return txId, err
 }
 
-//汇总到目标地址
+//汇总到目标地址，超过summarymaxamount时拆分为多笔，txId为逗号分隔
 //return txId,summaryAmount,feeAmount,err
 func (wm *WalletManager) SummaryWalletProcess(summaryToAddress string) (string, string, string, error) {
 	if summaryToAddress == "" {
 		return "", "", "", fmt.Errorf("param SummaryToAddress is null")
 
 	}
-	status, err := wm.walletClient.GetWalletStatus()
+	status, err := wm.walletClient.GetWalletStatus(context.Background())
 	if err != nil {
 		return "", "", "", fmt.Errorf("get local wallet balance failed, unexpected error: %v", err)
 	}
 
-	balance := common.IntToDecimals(int64(status.Available), wm.Decimal())
+	//预留给未广播提现的余额和归档地址收到的余额不汇总
+	available, err := wm.summaryBalance(wm.unreservedBalance(status.Available))
+	if err != nil {
+		return "", "", "", err
+	}
+	balance := common.IntToDecimals(int64(available), wm.Decimal())
 	threshold, _ := decimal.NewFromString(wm.Config.summarythreshold)
 
 	wm.Log.Infof("Summary Wallet Current Balance: %v, threshold: %v", balance.String(), threshold.String())
@@ -233,54 +519,63 @@ func (wm *WalletManager) SummaryWalletProcess(summaryToAddress string) (string,
 	//如果余额大于阀值，汇总的地址
 	if balance.GreaterThan(threshold) {
 
-		feesDec, _ := decimal.NewFromString(wm.Config.fixfees)
-		sumAmount := balance.Sub(feesDec)
+		//保留summaryminbalance，按summaryfeefrom支付手续费，超过summarymaxamount时拆分为多笔
+		amounts, fee, err := wm.summaryChunks(available, summaryToAddress)
+		if err != nil || len(amounts) == 0 {
+			return "", "", "", err
+		}
+		total := uint64(0)
+		for _, amount := range amounts {
+			total += amount
+		}
+		feesDec := common.IntToDecimals(int64(fee), wm.Decimal())
+		sumAmount := common.IntToDecimals(int64(total), wm.Decimal())
 
 		wm.Log.Infof("Summary Wallet Current Balance = %s ", balance.String())
 		wm.Log.Infof("Summary Wallet Summary Amount = %s ", sumAmount.String())
-		wm.Log.Infof("Summary Wallet Summary Fee = %s ", wm.Config.fixfees)
+		wm.Log.Infof("Summary Wallet Summary Fee = %s ", feesDec.String())
+		wm.Log.Infof("Summary Wallet Summary Txs = %d ", len(amounts))
 		wm.Log.Infof("Summary Wallet Summary Address = %v ", wm.Config.summaryaddress)
 		wm.Log.Infof("Summary Wallet Start Create Summary Transaction")
 
-		fixFees := common.StringNumToBigIntWithExp(wm.Config.fixfees, wm.Decimal())
-
-		//检查余额是否超过最低转账
-		addrBalance_BI := new(big.Int)
-		addrBalance_BI.SetUint64(status.Available)
-		sumAmount_BI := new(big.Int)
-		//减去手续费
-		sumAmount_BI.Sub(addrBalance_BI, fixFees)
-		if sumAmount_BI.Cmp(big.NewInt(0)) <= 0 {
-			return "", "", "", fmt.Errorf("summary amount not enough pay fee, ")
-		}
-
-		//取一个地址作为发送
-		addresses, err := wm.walletClient.GetAddressList()
+		//发送地址使用withdrawfrom配置或钱包的第一个地址
+		from, err := wm.SenderAddress("")
 		if err != nil {
 			return "", "", "", err
 		}
 
-		if addresses == nil || len(addresses) == 0 {
-			return "", "", "", fmt.Errorf("wallet address is not created")
+		var (
+			txids   = make([]string, 0, len(amounts))
+			sent    = new(big.Int)
+			fixFees = new(big.Int)
+			sendErr error
+		)
+		for _, amount := range amounts {
+			txid, err := wm.walletClient.SendTransaction(context.Background(), from, summaryToAddress, amount, fee, "", "")
+			if err != nil {
+				//已发送的交易保留，剩余金额留到下次汇总
+				sendErr = err
+				break
+			}
+			wm.Log.Infof("[Success] txid: %s", txid)
+			txids = append(txids, txid)
+			sent.Add(sent, new(big.Int).SetUint64(amount))
+			fixFees.Add(fixFees, new(big.Int).SetUint64(fee))
 		}
-
-		from := addresses[0]
-
-		txid, err := wm.walletClient.SendTransaction(from, summaryToAddress, sumAmount_BI.Uint64(), fixFees.Uint64(), "")
-		if err != nil {
-			return "", "", "", err
+		if len(txids) == 0 {
+			return "", "", "", sendErr
 		}
 
-		wm.Log.Infof("[Success] txid: %s", txid)
 		fee_dec := decimal.NewFromBigInt(fixFees, wm.Decimal()*-1)
-		summary_dec := decimal.NewFromBigInt(sumAmount_BI, wm.Decimal()*-1).Add(feesDec).Neg()
+		summary_dec := decimal.NewFromBigInt(sent, wm.Decimal()*-1).Add(fee_dec).Neg()
 		backErr := wm.BackupWalletData()
 		if backErr != nil {
 			wm.Log.Infof("Backup wallet data failed: %v", backErr)
 		} else {
 			wm.Log.Infof("Backup wallet data success")
 		}
-		return txid, summary_dec.String(), fee_dec.String(), nil
+		//拆分为多笔时返回逗号分隔的txid，部分发送失败时同时返回错误
+		return strings.Join(txids, ","), summary_dec.String(), fee_dec.String(), sendErr
 		//完成一次汇总备份一次wallet.db
 
 	}
@@ -288,20 +583,35 @@ func (wm *WalletManager) SummaryWalletProcess(summaryToAddress string) (string,
 	return "", "", "", nil
 }
 
-//ClearExpireTx
+//ClearExpireTx 取消发送超过txsendingtimeout仍在发送中的提现交易单，释放锁定的UTXO并发出告警，
+//接收方离线时SBBS发送会一直等待；收款交易单不取消；开启shielded时隐私池交易按shieldedtxsendingtimeout计算
 func (wm *WalletManager) ClearExpireTx() error {
 
-	txs, err := wm.walletClient.GetTransactionsByStatus(TxStatusInProgress)
-	if err != nil {
-		return err
+	var (
+		txs []*Transaction
+		err error
+	)
+
+	if wm.walletEvents != nil && wm.walletEvents.Connected() {
+		//已订阅钱包事件，使用事件跟踪的发送中交易单
+		txs = wm.walletEvents.InProgressTxs()
+	} else {
+		txs, err = wm.walletClient.GetTransactionsByStatus(context.Background(), TxStatusInProgress)
+		if err != nil {
+			return err
+		}
 	}
 
 	currentServerTime := time.Now()
 
 	for _, tx := range txs {
+		if tx.Income {
+			continue
+		}
+
 		//计算交易发送过期时间
 		txCreateTimestamp := time.Unix(tx.CreateTime, 0)
-		expiredTime := txCreateTimestamp.Add(wm.Config.txsendingtimeout)
+		expiredTime := txCreateTimestamp.Add(wm.txSendingTimeout(tx))
 
 		//log.Infof("txCreateTimestamp = %s", txCreateTimestamp.String())
 		//log.Infof("currentServerTime = %s", currentServerTime.String())
@@ -311,18 +621,112 @@ func (wm *WalletManager) ClearExpireTx() error {
 
 			log.Infof("In Progress Tx: %s is expired", tx.TxID)
 
-			flag, cancelErr := wm.walletClient.CancelTx(tx.TxID)
+			flag, cancelErr := wm.walletClient.CancelTx(context.Background(), tx.TxID)
+			wm.notifyExpiredTx(tx, currentServerTime, flag, cancelErr)
 			if cancelErr != nil {
 				return cancelErr
 			}
 			log.Infof("Cancel Tx: %s = %v", tx.TxID, flag)
+
+			if flag {
+				if err := wm.utxoLocks.unlockTx(tx.TxID); err != nil {
+					wm.Log.Errorf("release utxo locks of tx: %s failed, unexpected error: %v", tx.TxID, err)
+				}
+			}
 		}
 	}
 	return nil
 }
 
+//CancelTransaction 取消卡住的提现交易单，释放锁定的UTXO，
+//只能取消钱包发出且未上链的交易单，接收方离线时SBBS发送会一直等待
+func (wm *WalletManager) CancelTransaction(txID string) error {
+
+	tx, err := wm.walletClient.GetTransaction(context.Background(), txID)
+	if err != nil {
+		return err
+	}
+
+	if tx.Income {
+		return fmt.Errorf("tx: %s is incoming, can not be canceled", txID)
+	}
+
+	if tx.Status != TxStatusPending && tx.Status != TxStatusInProgress {
+		return fmt.Errorf("tx: %s status is %s, can not be canceled", txID, tx.StatusString)
+	}
+
+	flag, err := wm.walletClient.CancelTx(context.Background(), txID)
+	if err != nil {
+		return err
+	}
+	if !flag {
+		return fmt.Errorf("tx: %s cancel failed", txID)
+	}
+
+	err = wm.utxoLocks.unlockTx(txID)
+	if err != nil {
+		wm.Log.Errorf("release utxo locks of tx: %s failed, unexpected error: %v", txID, err)
+	}
+
+	wm.Log.Infof("Cancel Tx: %s success", txID)
+	return nil
+}
+
+//DeleteTransaction 从钱包历史中删除已取消或失败的交易单
+func (wm *WalletManager) DeleteTransaction(txID string) error {
+
+	tx, err := wm.walletClient.GetTransaction(context.Background(), txID)
+	if err != nil {
+		return err
+	}
+
+	if tx.Status != TxStatusCanceled && tx.Status != TxStatusFailed {
+		return fmt.Errorf("tx: %s status is %s, can not be deleted", txID, tx.StatusString)
+	}
+
+	flag, err := wm.walletClient.DeleteTx(context.Background(), txID)
+	if err != nil {
+		return err
+	}
+	if !flag {
+		return fmt.Errorf("tx: %s delete failed", txID)
+	}
+
+	wm.Log.Infof("Delete Tx: %s success", txID)
+	return nil
+}
+
+//DeleteFailedTransactions 删除钱包历史中全部已取消和失败的交易单，返回删除的数量
+func (wm *WalletManager) DeleteFailedTransactions() (int, error) {
+
+	deleted := 0
+	for _, status := range []int{TxStatusCanceled, TxStatusFailed} {
+		txs, err := wm.walletClient.GetTransactionsByStatus(context.Background(), status)
+		if err != nil {
+			return deleted, err
+		}
+		for _, tx := range txs {
+			flag, err := wm.walletClient.DeleteTx(context.Background(), tx.TxID)
+			if err != nil {
+				return deleted, err
+			}
+			if flag {
+				deleted++
+			}
+		}
+	}
+
+	wm.Log.Infof("Delete %d canceled and failed txs", deleted)
+	return deleted, nil
+}
+
 //BackupWalletData
 func (wm *WalletManager) BackupWalletData() error {
+	//配置了备份口令时导出加密备份
+	if password := wm.Config.walletbackuppassword.String(); len(password) > 0 {
+		_, err := wm.ExportWalletBackup(wm.Config.walletdatabackupdir, password)
+		return err
+	}
 	walletDbName := "wallet.db_" + strconv.FormatInt(time.Now().Unix(), 10)
 	//备份钱包文件
 	return file.Copy(wm.Config.walletdatafile, wm.Config.walletdatabackupdir+walletDbName)
@@ -351,10 +755,29 @@ func (wm *WalletManager) SummaryWalletProcessRemote(summaryToAddress string) (st
 
 //验证地址格式
 func (wm *WalletManager) ValidateAddress(address string) (bool, error) {
-	return wm.walletClient.ValidateAddress(address)
+	return wm.walletClient.ValidateAddress(context.Background(), address)
 
 }
 
+//checkWithdrawalAddress 发送前验证提现地址，拒绝格式错误的地址和钱包自己的地址
+func (wm *WalletManager) checkWithdrawalAddress(address string) error {
+
+	v, err := wm.walletClient.GetAddressValidation(context.Background(), address)
+	if err != nil {
+		return err
+	}
+
+	if !v.IsValid {
+		return openwallet.Errorf(openwallet.ErrAdressDecodeFailed, "address: %s is invalid", address)
+	}
+
+	if v.IsMine {
+		return openwallet.Errorf(openwallet.ErrAdressDecodeFailed, "address: %s belongs to the wallet itself", address)
+	}
+
+	return nil
+}
+
 //远程验证地址格式
 func (wm *WalletManager) ValidateAddressRemote(address string) (bool, error) {
 	if wm.Config.enableserver {
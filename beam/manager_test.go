@@ -16,7 +16,12 @@
 package beam
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/astaxie/beego/config"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"testing"
 )
@@ -51,3 +56,165 @@ func TestWalletManager_BackupWalletData(t *testing.T) {
 		return
 	}
 }
+
+func TestWalletManager_CancelTransaction(t *testing.T) {
+
+	txs := map[string]string{
+		"sending":  `{"txId":"sending","income":false,"status":1,"status_string":"in progress"}`,
+		"incoming": `{"txId":"incoming","income":true,"status":1,"status_string":"in progress"}`,
+		"done":     `{"txId":"done","income":false,"status":3,"status_string":"completed"}`,
+	}
+	canceled := make([]string, 0)
+	server := newRPCTestServer(rpcTestMethods{
+		"tx_status": func(params map[string]interface{}) (string, error) {
+			return txs[params["txId"].(string)], nil
+		},
+		"tx_cancel": func(params map[string]interface{}) (string, error) {
+			canceled = append(canceled, params["txId"].(string))
+			return "true", nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if err := wm.CancelTransaction("sending"); err != nil {
+		t.Errorf("CancelTransaction unexpected error: %v", err)
+	}
+	if err := wm.CancelTransaction("incoming"); err == nil {
+		t.Errorf("CancelTransaction incoming tx should fail")
+	}
+	if err := wm.CancelTransaction("done"); err == nil {
+		t.Errorf("CancelTransaction completed tx should fail")
+	}
+	if len(canceled) != 1 || canceled[0] != "sending" {
+		t.Errorf("canceled = %v, want [sending]", canceled)
+	}
+}
+
+func TestWalletManager_DeleteTransaction(t *testing.T) {
+
+	txs := map[string]string{
+		"canceled": `{"txId":"canceled","status":2,"status_string":"cancelled"}`,
+		"failed":   `{"txId":"failed","status":4,"status_string":"failed"}`,
+		"done":     `{"txId":"done","status":3,"status_string":"completed"}`,
+	}
+	deleted := make([]string, 0)
+	server := newRPCTestServer(rpcTestMethods{
+		"tx_status": func(params map[string]interface{}) (string, error) {
+			return txs[params["txId"].(string)], nil
+		},
+		"tx_list": func(params map[string]interface{}) (string, error) {
+			filter, _ := params["filter"].(map[string]interface{})
+			if filter["status"] == float64(TxStatusFailed) {
+				return "[" + txs["failed"] + "]", nil
+			}
+			return "[]", nil
+		},
+		"tx_delete": func(params map[string]interface{}) (string, error) {
+			deleted = append(deleted, params["txId"].(string))
+			return "true", nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if err := wm.DeleteTransaction("canceled"); err != nil {
+		t.Errorf("DeleteTransaction unexpected error: %v", err)
+	}
+	if err := wm.DeleteTransaction("done"); err == nil {
+		t.Errorf("DeleteTransaction completed tx should fail")
+	}
+	count, err := wm.DeleteFailedTransactions()
+	if err != nil || count != 1 {
+		t.Errorf("DeleteFailedTransactions = %d, err = %v, want 1", count, err)
+	}
+	if len(deleted) != 2 || deleted[1] != "failed" {
+		t.Errorf("deleted = %v, want [canceled failed]", deleted)
+	}
+}
+
+func TestWalletManager_CreateAddress(t *testing.T) {
+
+	var params map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		var body struct {
+			Params map[string]string `json:"params"`
+		}
+		json.Unmarshal(data, &body)
+		params = body.Params
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"addr"}`))
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	addr, err := wm.CreateAddress("", "", "")
+	if err != nil || addr != "addr" {
+		t.Errorf("CreateAddress = %s, err = %v", addr, err)
+	}
+	if params["expiration"] != AddressExpirationNever || params["comment"] != DefaultAddressComment || params["type"] != "" {
+		t.Errorf("CreateAddress default params = %v", params)
+	}
+
+	wm.CreateAddress(AddressTypeRegular, AddressExpiration24h, "deposit")
+	if params["expiration"] != AddressExpiration24h || params["comment"] != "deposit" {
+		t.Errorf("CreateAddress params = %v", params)
+	}
+
+	if _, err := wm.CreateAddress("", "1y", ""); err == nil {
+		t.Errorf("CreateAddress with unknown expiration should fail")
+	}
+	if _, err := wm.CreateAddress("secret", "", ""); err == nil {
+		t.Errorf("CreateAddress with unknown type should fail")
+	}
+
+	//隐私地址需要开启shielded
+	if _, err := wm.CreateAddress(AddressTypeMaxPrivacy, "", ""); err == nil {
+		t.Errorf("CreateAddress max_privacy should fail before shielded enabled")
+	}
+	wm.Config.features[FeatureShielded] = true
+	if _, err := wm.CreateAddress(AddressTypeMaxPrivacy, "", ""); err != nil || params["type"] != AddressTypeMaxPrivacy {
+		t.Errorf("CreateAddress max_privacy params = %v, err = %v", params, err)
+	}
+
+	//旧版钱包API不支持地址类型
+	c, _ := wm.httpWalletClient()
+	c.SetWalletAPIVersion(&WalletAPIVersion{Major: 5})
+	if _, err := wm.CreateAddress(AddressTypeOffline, "", ""); err == nil {
+		t.Errorf("CreateAddress offline should fail on wallet api v5")
+	}
+}
+
+func TestWalletManager_CheckWithdrawalAddress(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		var body struct {
+			Params map[string]string `json:"params"`
+		}
+		json.Unmarshal(data, &body)
+		address := body.Params["address"]
+		w.Write([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":{"is_valid":%v,"is_mine":%v}}`,
+			address != "bad", address == "mine")))
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if err := wm.checkWithdrawalAddress("other"); err != nil {
+		t.Errorf("checkWithdrawalAddress unexpected error: %v", err)
+	}
+	if err := wm.checkWithdrawalAddress("bad"); err == nil {
+		t.Errorf("checkWithdrawalAddress invalid address should fail")
+	}
+	if err := wm.checkWithdrawalAddress("mine"); err == nil {
+		t.Errorf("checkWithdrawalAddress own address should fail")
+	}
+}
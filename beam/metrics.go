@@ -0,0 +1,148 @@
+package beam
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace = "beam_adapter"
+)
+
+//Metrics 适配器监控指标，使用独立的registry，避免多个WalletManager重复注册
+type Metrics struct {
+	registry *prometheus.Registry
+
+	//区块扫描器
+	BlocksScanned     prometheus.Counter
+	TxExtracted       prometheus.Counter
+	ExtractFailures   prometheus.Counter
+	UnscanRecords     prometheus.Gauge
+	ScannerLag        prometheus.Gauge
+	BlockScanDuration prometheus.Histogram
+
+	//汇总任务
+	SummarySkipped *prometheus.CounterVec
+
+	//钱包API和浏览器API请求，标签为api和method，包含重试
+	RPCRequests *prometheus.CounterVec
+	RPCErrors   *prometheus.CounterVec
+	RPCDuration *prometheus.HistogramVec
+}
+
+//NewMetrics 创建监控指标
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+	}
+
+	m.BlocksScanned = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "scanner",
+		Name:      "blocks_scanned_total",
+		Help:      "Number of blocks scanned.",
+	})
+	m.TxExtracted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "scanner",
+		Name:      "transactions_extracted_total",
+		Help:      "Number of transactions extracted.",
+	})
+	m.ExtractFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "scanner",
+		Name:      "extract_failures_total",
+		Help:      "Number of transaction extraction failures.",
+	})
+	m.UnscanRecords = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "scanner",
+		Name:      "unscan_records",
+		Help:      "Number of outstanding unscan records.",
+	})
+	m.ScannerLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "scanner",
+		Name:      "lag_blocks",
+		Help:      "Chain tip height minus scanned height.",
+	})
+	m.BlockScanDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "scanner",
+		Name:      "block_duration_seconds",
+		Help:      "Time spent processing a single block.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	m.SummarySkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "summary",
+		Name:      "skipped_total",
+		Help:      "Number of summary runs deferred because the system is degraded.",
+	}, []string{"reason"})
+	m.RPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "rpc",
+		Name:      "requests_total",
+		Help:      "Number of wallet and explorer API calls.",
+	}, []string{"api", "method"})
+	m.RPCErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "rpc",
+		Name:      "errors_total",
+		Help:      "Number of failed wallet and explorer API calls by error class.",
+	}, []string{"api", "method", "class"})
+	m.RPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "rpc",
+		Name:      "duration_seconds",
+		Help:      "Latency of wallet and explorer API calls including retries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"api", "method"})
+
+	m.registry.MustRegister(
+		m.BlocksScanned,
+		m.TxExtracted,
+		m.ExtractFailures,
+		m.UnscanRecords,
+		m.ScannerLag,
+		m.BlockScanDuration,
+		m.SummarySkipped,
+		m.RPCRequests,
+		m.RPCErrors,
+		m.RPCDuration,
+	)
+
+	return m
+}
+
+//Registry 指标注册表，可用于注册其他模块的指标
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+//Handler prometheus抓取接口
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+//StartMetricsServer 开启监控指标HTTP服务，需要开启metrics功能并配置metricsaddr
+func (wm *WalletManager) StartMetricsServer() {
+
+	if !wm.IsFeatureEnabled(FeatureMetrics) || len(wm.Config.metricsaddr) == 0 {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", wm.Metrics.Handler())
+
+	wm.Log.Infof("Metrics server start to listen [%s] ...", wm.Config.metricsaddr)
+
+	go func() {
+		err := http.ListenAndServe(wm.Config.metricsaddr, mux)
+		if err != nil {
+			wm.Log.Errorf("metrics server unexpected error: %v", err)
+		}
+	}()
+}
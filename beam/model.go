@@ -6,18 +6,23 @@ import (
 	"github.com/blocktree/openwallet/crypto"
 	"github.com/blocktree/openwallet/openwallet"
 	"github.com/tidwall/gjson"
+	"time"
 )
 
 type Block struct {
-	Chainwork     string
-	Hash          string
-	Found         bool
-	PrevBlockHash string
-	Time          int64
-	Height        uint64
-	inputs        []interface{}
-	kernels       []interface{}
-	outputs       []interface{}
+	Chainwork        string
+	Hash             string `storm:"id"`
+	Found            bool
+	PrevBlockHash    string
+	Time             int64
+	Height           uint64 `storm:"index"`
+	KernelCount      int64  //区块内核数量，近似区块交易数，-1表示未知
+	Empty            bool   //区块没有用户交易：没有输入且最多一个内核（挖矿奖励），花费隐私池UTXO的交易没有普通输入
+	Subsidy          uint64 //区块挖矿奖励
+	CoinbaseMaturity uint64 //挖矿奖励输出的成熟高度，0表示区块没有挖矿奖励输出
+	inputs           []interface{}
+	kernels          []interface{}
+	outputs          []interface{}
 
 	/*
 		{
@@ -62,6 +67,51 @@ func NewBlock(result *gjson.Result) *Block {
 	obj.Time = result.Get("timestamp").Int()
 	obj.Found = result.Get("found").Bool()
 
+	kernels := result.Get("kernels")
+	if kernels.IsArray() {
+		obj.KernelCount = int64(len(kernels.Array()))
+	} else {
+		obj.KernelCount = -1
+	}
+
+	//花费隐私池UTXO的交易没有普通输入，除挖矿奖励外还有其他内核时不是空区块
+	inputs := result.Get("inputs")
+	obj.Empty = inputs.IsArray() && len(inputs.Array()) == 0 && obj.KernelCount <= 1
+
+	obj.Subsidy = result.Get("subsidy").Uint()
+	for _, output := range result.Get("outputs").Array() {
+		if output.Get("coinbase").Bool() {
+			obj.CoinbaseMaturity = output.Get("maturity").Uint()
+			break
+		}
+	}
+
+	return &obj
+}
+
+//NewBlockFromDetails 解析钱包API的block_details，
+//只有区块头，内核和输出数量未知，不能判断空区块和挖矿奖励
+func NewBlockFromDetails(result *gjson.Result) *Block {
+	obj := Block{}
+	obj.Hash = result.Get("block_hash").String()
+	obj.Chainwork = result.Get("chainwork").String()
+	obj.PrevBlockHash = result.Get("previous_block").String()
+	obj.Height = result.Get("height").Uint()
+	obj.Time = result.Get("timestamp").Int()
+	obj.Found = true
+	obj.KernelCount = -1
+
+	/*
+		{
+		  "block_hash": "c2a7315b63b1de6106a185c1c79219001ef5e3a07c217db227b079bbb9dd9b64",
+		  "chainwork": "0x384fdc718a20",
+		  "difficulty": 157.9972152709961,
+		  "height": 20516,
+		  "previous_block": "4b9e35b467b416e0d307dd94bd2fdce6e720b6b3a029dca822ccab3ac57c6d22",
+		  "timestamp": 1550157362
+		}
+	*/
+
 	return &obj
 }
 
@@ -93,9 +143,16 @@ type Transaction struct {
 	Income        bool
 	Status        int64
 	StatusString  string
+	FailureReason string //失败的原因，交易单失败时有值
 	Confirmations uint64
 	BlockHeight   uint64
 	BlockHash     string
+	Coinbase      bool     //挖矿奖励，由区块的挖矿奖励输出生成
+	AssetID       int64    //资产ID，0为BEAM，其他为机密资产
+	TxType        int64    //交易类型
+	Shielded      bool     //隐私池交易，发送到离线地址、最大隐私地址或花费隐私池中的UTXO
+	Token         string   //付款使用的地址，离线地址收款时为离线地址
+	ContractIDs   []string //合约交易调用的shader合约ID
 
 	/*
 			{
@@ -111,7 +168,17 @@ type Transaction struct {
 		        "status": 3,
 		        "status_string": "received",
 		        "txId": "72f8f349f9244b11b0e6471250ca68a1",
-		        "value": 10000
+		        "value": 10000,
+		        "asset_id": 0,
+		        "tx_type": 0,
+		        "address_type": "regular",
+		        "token": "",
+		        "invoke_data": [
+		            {
+		                "contract_id": "8a2c8b5d5bcbd4e8c0c95b3a2ed1bb9b9b2e4ad2e0b6c74bc5bffba0e1d7e1f9",
+		                "fee": 100000
+		            }
+		        ]
 		    }
 	*/
 }
@@ -127,20 +194,32 @@ func NewTransaction(result *gjson.Result) *Transaction {
 	obj.Sender = result.Get("sender").String()
 	obj.Status = result.Get("status").Int()
 	obj.StatusString = result.Get("status_string").String()
+	obj.FailureReason = result.Get("failure_reason").String()
 	obj.TxID = result.Get("txId").String()
 	obj.Value = result.Get("value").Uint()
 	obj.Confirmations = result.Get("confirmations").Uint()
 	obj.BlockHeight = result.Get("height").Uint()
+	obj.AssetID = result.Get("asset_id").Int()
+	obj.TxType = result.Get("tx_type").Int()
+	obj.Shielded = isShieldedTxType(obj.TxType) || isShieldedAddressType(result.Get("address_type").String())
+	obj.Token = result.Get("token").String()
+	for _, invoke := range result.Get("invoke_data").Array() {
+		obj.ContractIDs = append(obj.ContractIDs, invoke.Get("contract_id").String())
+	}
 
 	return &obj
 }
 
 //UnscanRecords 扫描失败的区块及交易
 type UnscanRecord struct {
-	ID          string `storm:"id"` // primary key
-	BlockHeight uint64
-	TxID        string
-	Reason      string
+	ID            string `storm:"id"` // primary key
+	BlockHeight   uint64
+	TxID          string
+	Reason        string
+	Attempts      int   //已重试次数
+	NextRetryTime int64 //下次可重试的时间戳
+	Dead          bool  //超过最大重试次数，进入死信状态
+	CreateTime    int64
 }
 
 func NewUnscanRecord(height uint64, txID, reason string) *UnscanRecord {
@@ -148,6 +227,7 @@ func NewUnscanRecord(height uint64, txID, reason string) *UnscanRecord {
 	obj.BlockHeight = height
 	obj.TxID = txID
 	obj.Reason = reason
+	obj.CreateTime = time.Now().Unix()
 	obj.ID = common.Bytes2Hex(crypto.SHA256([]byte(fmt.Sprintf("%d_%s", height, txID))))
 	return &obj
 }
@@ -224,6 +304,77 @@ func NewWalletStatus(result *gjson.Result) *WalletStatus {
 	return &obj
 }
 
+type Utxo struct {
+	ID           string
+	AssetID      int64
+	Amount       uint64
+	Type         string
+	Maturity     uint64
+	CreateTxID   string
+	SpentTxID    string
+	Status       int64
+	StatusString string
+
+	/*
+		{
+		    "id": "12446927d4ee8b6a58b1ad15c2d32fd28a9f7b1c2ba50b69d6c5b6ff5b9dfc31",
+		    "asset_id": 0,
+		    "amount": 8000000000,
+		    "type": "mine",
+		    "maturity": 20756,
+		    "createTxId": "",
+		    "spentTxId": "",
+		    "status": 2,
+		    "status_string": "maturing"
+		}
+	*/
+}
+
+//IsShielded 隐私池中的UTXO，花费时按隐私输入收取手续费
+func (u *Utxo) IsShielded() bool {
+	return u.Type == UtxoTypeShielded
+}
+
+func NewUtxo(result *gjson.Result) *Utxo {
+	obj := Utxo{}
+	obj.ID = result.Get("id").String()
+	obj.AssetID = result.Get("asset_id").Int()
+	obj.Amount = result.Get("amount").Uint()
+	obj.Type = result.Get("type").String()
+	obj.Maturity = result.Get("maturity").Uint()
+	obj.CreateTxID = result.Get("createTxId").String()
+	obj.SpentTxID = result.Get("spentTxId").String()
+	obj.Status = result.Get("status").Int()
+	obj.StatusString = result.Get("status_string").String()
+	return &obj
+}
+
+//AddressValidation 地址验证结果
+type AddressValidation struct {
+	IsValid  bool
+	IsMine   bool
+	Type     string
+	Payments int //离线地址剩余的付款凭证数量
+
+	/*
+		{
+		    "is_valid": true,
+		    "is_mine": false,
+		    "type": "offline",
+		    "payments": 10
+		}
+	*/
+}
+
+func NewAddressValidation(result *gjson.Result) *AddressValidation {
+	obj := AddressValidation{}
+	obj.IsValid = result.Get("is_valid").Bool()
+	obj.IsMine = result.Get("is_mine").Bool()
+	obj.Type = result.Get("type").String()
+	obj.Payments = int(result.Get("payments").Int())
+	return &obj
+}
+
 type AddressCreateResult struct {
 	Success bool
 	Err     error
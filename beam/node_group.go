@@ -0,0 +1,355 @@
+package beam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/astaxie/beego/config"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/common/file"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//节点组中的节点角色
+	NodeRoleScanner = "scanner"
+	NodeRoleSender  = "sender"
+)
+
+//GroupNode 节点组中的节点，每个节点是独立的beam钱包（wallet-api）和适配器配置
+type GroupNode struct {
+	Name    string
+	Role    string
+	Manager *WalletManager
+}
+
+//NodeBalance 节点的钱包余额，Available已扣除提现队列预留的余额，单位groth
+type NodeBalance struct {
+	Name      string
+	Role      string
+	Available uint64
+	Receiving uint64
+	Sending   uint64
+	Maturing  uint64
+	Locked    uint64
+}
+
+//NodeGroup 多节点部署：一个扫块节点和多个发送节点，扫块、充值地址和查询使用扫块节点，
+//提现交易单按可用余额路由到发送节点，余额汇总全部节点的钱包；
+//NodeGroup嵌入扫块节点的WalletManager，可以作为AssetsAdapter注册
+type NodeGroup struct {
+	*WalletManager
+	senders   []*GroupNode
+	txDecoder *NodeGroupDecoder
+}
+
+//NewNodeGroup 以扫块节点创建节点组，发送节点通过AddSender或sendnodes配置添加
+func NewNodeGroup(scanner *WalletManager) *NodeGroup {
+	g := &NodeGroup{WalletManager: scanner}
+	g.txDecoder = &NodeGroupDecoder{group: g}
+	return g
+}
+
+//AddSender 添加发送节点，节点名称不能重复
+func (g *NodeGroup) AddSender(name string, wm *WalletManager) error {
+	if len(name) == 0 || wm == nil {
+		return fmt.Errorf("sender node name and wallet manager are required")
+	}
+	if name == NodeRoleScanner || g.Sender(name) != nil {
+		return fmt.Errorf("node: %s already exists", name)
+	}
+	g.senders = append(g.senders, &GroupNode{Name: name, Role: NodeRoleSender, Manager: wm})
+	return nil
+}
+
+//Sender 按名称查找发送节点，不存在时返回nil
+func (g *NodeGroup) Sender(name string) *GroupNode {
+	for _, node := range g.senders {
+		if node.Name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+//Senders 全部发送节点
+func (g *NodeGroup) Senders() []*GroupNode {
+	return g.senders
+}
+
+//Nodes 全部节点，扫块节点在前
+func (g *NodeGroup) Nodes() []*GroupNode {
+	nodes := []*GroupNode{{Name: NodeRoleScanner, Role: NodeRoleScanner, Manager: g.WalletManager}}
+	return append(nodes, g.senders...)
+}
+
+//LoadAssetsConfig 加载扫块节点的配置，再按sendnodes加载发送节点的配置文件，
+//节点名称为配置文件名（不含扩展名），发送节点的数据库在扫块节点数据目录的nodes/<名称>下
+func (g *NodeGroup) LoadAssetsConfig(c config.Configer) error {
+
+	err := g.WalletManager.LoadAssetsConfig(c)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range g.senders {
+		if shutdownErr := node.Manager.Shutdown(context.Background()); shutdownErr != nil {
+			g.Log.Warningf("shutdown sender node: %s failed, unexpected error: %v", node.Name, shutdownErr)
+		}
+	}
+	g.senders = nil
+
+	for _, path := range g.Config.sendnodes {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		nc, err := config.NewConfig("ini", path)
+		if err != nil {
+			return fmt.Errorf("load sender node: %s config failed, %v", name, err)
+		}
+		wm := NewWalletManager()
+		wm.Config.dbPath = filepath.Join(g.Config.dbPath, "nodes", name)
+		file.MkdirAll(wm.Config.dbPath)
+		if err = wm.LoadAssetsConfig(nc); err != nil {
+			return fmt.Errorf("load sender node: %s config failed, %v", name, err)
+		}
+		if err = g.AddSender(name, wm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//GetTransactionDecoder 交易单解析器，交易单路由到发送节点
+func (g *NodeGroup) GetTransactionDecoder() openwallet.TransactionDecoder {
+	return g.txDecoder
+}
+
+//Shutdown 关闭全部发送节点和扫块节点
+func (g *NodeGroup) Shutdown(ctx context.Context) error {
+	for _, node := range g.senders {
+		if err := node.Manager.Shutdown(ctx); err != nil {
+			g.Log.Errorf("sender node: %s shutdown unexpected error: %v", node.Name, err)
+		}
+	}
+	return g.WalletManager.Shutdown(ctx)
+}
+
+//nodeBalance 查询节点钱包余额
+func nodeBalance(node *GroupNode) (*NodeBalance, error) {
+	status, err := node.Manager.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("node: %s wallet status failed, %v", node.Name, err)
+	}
+	return &NodeBalance{
+		Name:      node.Name,
+		Role:      node.Role,
+		Available: node.Manager.unreservedBalance(status.Available),
+		Receiving: status.Receiving,
+		Sending:   status.Sending,
+		Maturing:  status.Maturing,
+		Locked:    status.Locked,
+	}, nil
+}
+
+//NodeBalances 查询全部节点的钱包余额，任一节点查询失败时返回错误
+func (g *NodeGroup) NodeBalances() ([]*NodeBalance, error) {
+	nodes := g.Nodes()
+	list := make([]*NodeBalance, 0, len(nodes))
+	for _, node := range nodes {
+		b, err := nodeBalance(node)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, b)
+	}
+	return list, nil
+}
+
+//TotalBalance 汇总全部节点的BEAM余额，确认余额为可用余额之和，未确认余额为接收中余额之和
+func (g *NodeGroup) TotalBalance() (*openwallet.Balance, error) {
+
+	list, err := g.NodeBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	var available, receiving uint64
+	for _, b := range list {
+		available += b.Available
+		receiving += b.Receiving
+	}
+
+	confirmBalance := common.IntToDecimals(int64(available), g.Decimal())
+	unconfirmedBalance := common.IntToDecimals(int64(receiving), g.Decimal())
+	return &openwallet.Balance{
+		Symbol:           g.Symbol(),
+		Balance:          confirmBalance.Add(unconfirmedBalance).String(),
+		ConfirmBalance:   confirmBalance.String(),
+		UnconfirmBalance: unconfirmedBalance.String(),
+	}, nil
+}
+
+//rankedSenders 按可用余额从大到小排序的发送节点，查询失败的节点不参与发送
+func (g *NodeGroup) rankedSenders() []*GroupNode {
+
+	type ranked struct {
+		node      *GroupNode
+		available uint64
+	}
+
+	list := make([]ranked, 0, len(g.senders))
+	for _, node := range g.senders {
+		b, err := nodeBalance(node)
+		if err != nil {
+			g.Log.Warningf("skip sender %v", err)
+			continue
+		}
+		list = append(list, ranked{node: node, available: b.Available})
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].available > list[j].available
+	})
+
+	nodes := make([]*GroupNode, 0, len(list))
+	for _, r := range list {
+		nodes = append(nodes, r.node)
+	}
+	return nodes
+}
+
+//NodeGroupDecoder 节点组的交易单解析器：创建交易单时按可用余额从大到小尝试发送节点，
+//余额不足时换下一个节点，选中的节点记到交易单扩展参数的node，提交时发送到该节点
+type NodeGroupDecoder struct {
+	openwallet.TransactionDecoderBase
+	group *NodeGroup
+}
+
+//isInsufficientError 是否为余额或手续费不足，可以换其他节点发送
+func isInsufficientError(err error) bool {
+	owErr, ok := err.(*openwallet.Error)
+	if !ok {
+		return false
+	}
+	switch owErr.Code() {
+	case openwallet.ErrInsufficientBalanceOfAccount, openwallet.ErrInsufficientBalanceOfAddress,
+		openwallet.ErrInsufficientFees, openwallet.ErrInsufficientTokenBalanceOfAddress:
+		return true
+	}
+	return false
+}
+
+//setRawTxNode 把发送节点写入交易单扩展参数，保留其他扩展参数
+func setRawTxNode(rawTx *openwallet.RawTransaction, name string) {
+	ext := make(map[string]interface{})
+	if len(rawTx.ExtParam) > 0 {
+		for k, v := range gjson.Parse(rawTx.ExtParam).Map() {
+			ext[k] = v.Value()
+		}
+	}
+	ext["node"] = name
+	data, _ := json.Marshal(ext)
+	rawTx.ExtParam = string(data)
+}
+
+//rawTxNode 交易单扩展参数中指定的发送节点：{"node": "..."}
+func rawTxNode(rawTx *openwallet.RawTransaction) string {
+	if len(rawTx.ExtParam) == 0 {
+		return ""
+	}
+	return gjson.Get(rawTx.ExtParam, "node").String()
+}
+
+//senderFor 交易单的发送节点：扩展参数指定的节点，或已发送过该业务订单号的节点
+func (decoder *NodeGroupDecoder) senderFor(rawTx *openwallet.RawTransaction) (*GroupNode, error) {
+	if name := rawTxNode(rawTx); len(name) > 0 {
+		node := decoder.group.Sender(name)
+		if node == nil {
+			return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "sender node: %s not found", name)
+		}
+		return node, nil
+	}
+	if len(rawTx.Sid) > 0 {
+		for _, node := range decoder.group.senders {
+			if txID, _ := node.Manager.GetSendTxID(rawTx.Sid); len(txID) > 0 {
+				return node, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+//route 在发送节点上执行f，没有指定节点时按可用余额依次尝试，余额不足时换下一个节点
+func (decoder *NodeGroupDecoder) route(rawTx *openwallet.RawTransaction, f func(node *GroupNode) error) error {
+
+	node, err := decoder.senderFor(rawTx)
+	if err != nil {
+		return err
+	}
+	if node != nil {
+		return f(node)
+	}
+
+	nodes := decoder.group.rankedSenders()
+	if len(nodes) == 0 {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "no sender node is available")
+	}
+	for _, node := range nodes {
+		err = f(node)
+		if err == nil || !isInsufficientError(err) {
+			return err
+		}
+		decoder.group.Log.Infof("sender node: %s balance is not enough, try next node", node.Name)
+	}
+	return err
+}
+
+//CreateRawTransaction 创建交易单，选中的发送节点记到扩展参数
+func (decoder *NodeGroupDecoder) CreateRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
+	return decoder.route(rawTx, func(node *GroupNode) error {
+		err := node.Manager.TxDecoder.CreateRawTransaction(wrapper, rawTx)
+		if err == nil {
+			setRawTxNode(rawTx, node.Name)
+		}
+		return err
+	})
+}
+
+//SignRawTransaction 签名交易单
+func (decoder *NodeGroupDecoder) SignRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
+	return decoder.route(rawTx, func(node *GroupNode) error {
+		return node.Manager.TxDecoder.SignRawTransaction(wrapper, rawTx)
+	})
+}
+
+//VerifyRawTransaction 验证交易单
+func (decoder *NodeGroupDecoder) VerifyRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
+	return decoder.route(rawTx, func(node *GroupNode) error {
+		return node.Manager.TxDecoder.VerifyRawTransaction(wrapper, rawTx)
+	})
+}
+
+//SubmitRawTransaction 在交易单的发送节点广播交易单，没有创建过的交易单按可用余额选择节点
+func (decoder *NodeGroupDecoder) SubmitRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) (*openwallet.Transaction, error) {
+	var tx *openwallet.Transaction
+	err := decoder.route(rawTx, func(node *GroupNode) error {
+		sent, err := node.Manager.TxDecoder.SubmitRawTransaction(wrapper, rawTx)
+		if err != nil {
+			return err
+		}
+		sent.SetExtParam("node", node.Name)
+		tx = sent
+		return nil
+	})
+	return tx, err
+}
+
+//GetRawTransactionFeeRate 获取交易单的费率，使用扫块节点的配置
+func (decoder *NodeGroupDecoder) GetRawTransactionFeeRate() (feeRate string, unit string, err error) {
+	return decoder.group.TxDecoder.GetRawTransactionFeeRate()
+}
@@ -0,0 +1,97 @@
+package beam
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func newNodeGroupTestNode(t *testing.T, available uint64, sent *int) (*WalletManager, func()) {
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list":        rpcResult(`[{"address":"self","own":true,"comment":"self"}]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false,"type":"regular"}`),
+		"wallet_status":    rpcResult(fmt.Sprintf(`{"available":%d,"receiving":10}`, available)),
+		"generate_tx_id":   rpcResult(`"tx1"`),
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			*sent++
+			return fmt.Sprintf(`{"txId":"%s"}`, params["txId"]), nil
+		},
+	})
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	return wm, server.Close
+}
+
+func TestNodeGroup(t *testing.T) {
+
+	var scannerSent, smallSent, largeSent int
+	scanner, closeScanner := newNodeGroupTestNode(t, 100, &scannerSent)
+	defer closeScanner()
+	small, closeSmall := newNodeGroupTestNode(t, 1000, &smallSent)
+	defer closeSmall()
+	large, closeLarge := newNodeGroupTestNode(t, 100000000, &largeSent)
+	defer closeLarge()
+
+	g := NewNodeGroup(scanner)
+	var _ openwallet.AssetsAdapter = g
+	if err := g.AddSender("small", small); err != nil {
+		t.Errorf("AddSender small failed: %v", err)
+	}
+	if err := g.AddSender("large", large); err != nil {
+		t.Errorf("AddSender large failed: %v", err)
+	}
+	if err := g.AddSender("large", large); err == nil {
+		t.Errorf("AddSender duplicated name should fail")
+	}
+
+	balance, err := g.TotalBalance()
+	if err != nil {
+		t.Errorf("TotalBalance failed: %v", err)
+		return
+	}
+	if balance.ConfirmBalance != "1.000011" || balance.UnconfirmBalance != "0.0000003" {
+		t.Errorf("TotalBalance = %s / %s", balance.ConfirmBalance, balance.UnconfirmBalance)
+	}
+
+	//按可用余额选择发送节点，节点记到扩展参数，提交时发送到同一节点
+	decoder := g.GetTransactionDecoder()
+	rawTx := &openwallet.RawTransaction{
+		Coin:     openwallet.Coin{Symbol: Symbol},
+		To:       map[string]string{"to": "0.00001"},
+		ExtParam: `{"from":"self"}`,
+	}
+	if err = decoder.CreateRawTransaction(nil, rawTx); err != nil {
+		t.Errorf("CreateRawTransaction failed: %v", err)
+		return
+	}
+	if node := gjson.Get(rawTx.ExtParam, "node").String(); node != "large" || gjson.Get(rawTx.ExtParam, "from").String() != "self" {
+		t.Errorf("CreateRawTransaction ext param = %s", rawTx.ExtParam)
+	}
+	tx, err := decoder.SubmitRawTransaction(nil, rawTx)
+	if err != nil {
+		t.Errorf("SubmitRawTransaction failed: %v", err)
+		return
+	}
+	if largeSent != 1 || smallSent != 0 || scannerSent != 0 || tx.GetExtParam().Get("node").String() != "large" {
+		t.Errorf("SubmitRawTransaction sent scanner: %d small: %d large: %d", scannerSent, smallSent, largeSent)
+	}
+
+	//指定节点时不再选择，余额不足直接返回错误
+	rawTx = &openwallet.RawTransaction{
+		Coin:     openwallet.Coin{Symbol: Symbol},
+		To:       map[string]string{"to": "0.00001"},
+		ExtParam: `{"node":"small"}`,
+	}
+	if err = decoder.CreateRawTransaction(nil, rawTx); err == nil {
+		t.Errorf("CreateRawTransaction on small node should fail")
+	}
+	rawTx.ExtParam = `{"node":"unknown"}`
+	if err = decoder.CreateRawTransaction(nil, rawTx); err == nil {
+		t.Errorf("CreateRawTransaction on unknown node should fail")
+	}
+}
@@ -0,0 +1,232 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//离线地址默认包含的付款凭证（voucher）数量，每笔离线付款消耗一个
+	DefaultOfflinePayments = 10
+
+	//钱包创建离线地址时最多包含的付款凭证数量
+	MaxOfflinePayments = 100
+)
+
+//WalletAddress addr_list返回的钱包地址
+type WalletAddress struct {
+	Address    string
+	WalletID   string //地址对应的SBBS钱包ID，离线地址收到的付款记在该ID上
+	Type       string
+	Comment    string
+	Own        bool
+	Expired    bool
+	CreateTime int64
+	Duration   int64 //有效期，单位秒，0表示永不过期
+
+	/*
+		{
+		    "address": "3ab404a243fd09f827e8941e419e523a5b21e17c70563bfbc211dbe0e87ca95",
+		    "wallet_id": "3ab404a243fd09f827e8941e419e523a5b21e17c70563bfbc211dbe0e87ca95",
+		    "type": "regular",
+		    "comment": "self",
+		    "create_time": 1553174321,
+		    "duration": 1520,
+		    "expired": false,
+		    "own": true
+		}
+	*/
+}
+
+func NewWalletAddress(result *gjson.Result) *WalletAddress {
+	obj := WalletAddress{}
+	obj.Address = result.Get("address").String()
+	obj.WalletID = result.Get("wallet_id").String()
+	obj.Type = result.Get("type").String()
+	obj.Comment = result.Get("comment").String()
+	obj.Own = result.Get("own").Bool()
+	obj.Expired = result.Get("expired").Bool()
+	obj.CreateTime = result.Get("create_time").Int()
+	obj.Duration = result.Get("duration").Int()
+	return &obj
+}
+
+//ListAddresses 列出钱包的地址，own为true时只列出自己的地址
+func (c *WalletClient) ListAddresses(ctx context.Context, own bool) ([]*WalletAddress, error) {
+
+	request := map[string]interface{}{
+		"own": own,
+	}
+
+	r, err := c.callContext(ctx, "addr_list", request)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]*WalletAddress, 0)
+	for _, a := range r.Array() {
+		addrs = append(addrs, NewWalletAddress(&a))
+	}
+	return addrs, nil
+}
+
+//CreateOfflineAddress 创建包含payments个付款凭证的离线地址，钱包离线时付款方使用凭证付款
+func (c *WalletClient) CreateOfflineAddress(ctx context.Context, payments int, expiration, comment string) (string, error) {
+
+	request := map[string]interface{}{
+		"type":             AddressTypeOffline,
+		"offline_payments": payments,
+		"expiration":       expiration,
+		"comment":          comment,
+	}
+
+	r, err := c.callContext(ctx, "create_address", request)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+//OfflineAddress 本地登记的离线充值地址，扫描时把隐私池收款对应到该地址
+type OfflineAddress struct {
+	Address    string `storm:"id"`
+	WalletID   string `storm:"index"`
+	Type       string
+	Payments   int //创建时包含的付款凭证数量，公开离线地址为0
+	Comment    string
+	CreateTime int64
+}
+
+func (wm *WalletManager) openOfflineAddressDB() (*storm.DB, error) {
+	return storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+}
+
+//CreateOfflineAddress 创建离线充值地址，payments为付款凭证数量，为0时使用默认数量，
+//钱包离线时仍可收款，需要开启shielded
+func (wm *WalletManager) CreateOfflineAddress(payments int, comment string) (string, error) {
+
+	if payments == 0 {
+		payments = DefaultOfflinePayments
+	}
+	if payments < 0 || payments > MaxOfflinePayments {
+		return "", fmt.Errorf("offline payments must be between 1 and %d", MaxOfflinePayments)
+	}
+
+	err := wm.checkAddressType(AddressTypeOffline)
+	if err != nil {
+		return "", err
+	}
+
+	address, err := wm.walletClient.CreateOfflineAddress(context.Background(), payments, AddressExpirationNever, comment)
+	if err != nil {
+		return "", err
+	}
+	wm.addressBook.addCreated(AddressTypeOffline, comment, address)
+
+	return address, wm.registerOfflineAddress(address, AddressTypeOffline, payments, comment)
+}
+
+//CreatePublicOfflineAddress 创建公开离线充值地址，不需要付款凭证，可重复收款，隐私性低于离线地址，需要开启shielded
+func (wm *WalletManager) CreatePublicOfflineAddress(comment string) (string, error) {
+
+	address, err := wm.CreateAddress(AddressTypePublicOffline, AddressExpirationNever, comment)
+	if err != nil {
+		return "", err
+	}
+
+	return address, wm.registerOfflineAddress(address, AddressTypePublicOffline, 0, comment)
+}
+
+//registerOfflineAddress 登记离线地址及其SBBS钱包ID，钱包API不返回钱包ID时只能按交易的token匹配
+func (wm *WalletManager) registerOfflineAddress(address, addressType string, payments int, comment string) error {
+
+	record := &OfflineAddress{
+		Address:    address,
+		Type:       addressType,
+		Payments:   payments,
+		Comment:    comment,
+		CreateTime: time.Now().Unix(),
+	}
+
+	addrs, err := wm.walletClient.ListAddresses(context.Background(), true)
+	if err != nil {
+		wm.Log.Warningf("list addresses for offline address: %s failed, unexpected error: %v", address, err)
+	}
+	for _, a := range addrs {
+		if a.Address == address {
+			record.WalletID = a.WalletID
+			break
+		}
+	}
+
+	db, err := wm.openOfflineAddressDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Save(record)
+}
+
+//ListOfflineAddresses 列出本地登记的离线地址，按创建时间排序
+func (wm *WalletManager) ListOfflineAddresses() ([]*OfflineAddress, error) {
+
+	db, err := wm.openOfflineAddressDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*OfflineAddress
+	err = db.All(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreateTime < list[j].CreateTime
+	})
+	return list, nil
+}
+
+//OfflinePayments 离线地址剩余的付款凭证数量，用完后付款方需要钱包在线时才能付款
+func (wm *WalletManager) OfflinePayments(address string) (int, error) {
+
+	v, err := wm.walletClient.GetAddressValidation(context.Background(), address)
+	if err != nil {
+		return 0, err
+	}
+	if !v.IsValid || !isShieldedAddressType(v.Type) {
+		return 0, fmt.Errorf("address: %s is not an offline address", address)
+	}
+	return v.Payments, nil
+}
+
+//offlineReceiver 隐私池收款对应的离线充值地址，先按交易的token匹配，再按SBBS钱包ID匹配，没有登记时返回空
+func (wm *WalletManager) offlineReceiver(tx *Transaction) string {
+
+	if !tx.Shielded || !tx.Income {
+		return ""
+	}
+
+	db, err := wm.openOfflineAddressDB()
+	if err != nil {
+		return ""
+	}
+	defer db.Close()
+
+	var record OfflineAddress
+	if len(tx.Token) > 0 && db.One("Address", tx.Token, &record) == nil {
+		return record.Address
+	}
+	if len(tx.Receiver) > 0 && db.One("WalletID", tx.Receiver, &record) == nil {
+		return record.Address
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package beam
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func newOfflineAddressTestServer(created *map[string]interface{}) *httptest.Server {
+	return newRPCTestServer(rpcTestMethods{
+		"create_address": func(params map[string]interface{}) (string, error) {
+			*created = params
+			if params["type"] == AddressTypePublicOffline {
+				return `"public"`, nil
+			}
+			return `"offline"`, nil
+		},
+		"addr_list": rpcResult(`[{"address":"from","wallet_id":"w0","own":true,"comment":"self","type":"regular"},` +
+			`{"address":"offline","wallet_id":"w1","own":true,"comment":"self","type":"offline"},` +
+			`{"address":"public","wallet_id":"w2","own":true,"comment":"self","type":"public_offline"}]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":true,"type":"offline","payments":7}`),
+	})
+}
+
+func TestWalletManager_CreateOfflineAddress(t *testing.T) {
+
+	var created map[string]interface{}
+	server := newOfflineAddressTestServer(&created)
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	//离线地址需要开启shielded
+	if _, err := wm.CreateOfflineAddress(0, ""); err == nil {
+		t.Errorf("CreateOfflineAddress should fail before shielded enabled")
+	}
+	wm.Config.features[FeatureShielded] = true
+
+	if _, err := wm.CreateOfflineAddress(MaxOfflinePayments+1, ""); err == nil {
+		t.Errorf("CreateOfflineAddress should fail when payments exceed max")
+	}
+
+	addr, err := wm.CreateOfflineAddress(0, "deposit")
+	if err != nil || addr != "offline" {
+		t.Errorf("CreateOfflineAddress = %s, err = %v", addr, err)
+		return
+	}
+	if created["type"] != AddressTypeOffline || created["offline_payments"] != float64(DefaultOfflinePayments) || created["comment"] != "deposit" {
+		t.Errorf("create_address params = %v", created)
+	}
+
+	addr, err = wm.CreatePublicOfflineAddress("")
+	if err != nil || addr != "public" || created["type"] != AddressTypePublicOffline {
+		t.Errorf("CreatePublicOfflineAddress = %s, err = %v, params = %v", addr, err, created)
+	}
+
+	list, err := wm.ListOfflineAddresses()
+	if err != nil || len(list) != 2 {
+		t.Errorf("ListOfflineAddresses = %d, err = %v", len(list), err)
+		return
+	}
+	if list[0].WalletID != "w1" || list[0].Payments != DefaultOfflinePayments || list[1].WalletID != "w2" {
+		t.Errorf("offline addresses = %+v, %+v", list[0], list[1])
+	}
+
+	if payments, err := wm.OfflinePayments("offline"); err != nil || payments != 7 {
+		t.Errorf("OfflinePayments = %d, err = %v", payments, err)
+	}
+
+	//隐私地址不能作为发送地址
+	addrs, err := wm.walletClient.GetAddressList(context.Background())
+	if err != nil || len(addrs) != 1 || addrs[0] != "from" {
+		t.Errorf("GetAddressList = %v, err = %v", addrs, err)
+	}
+}
+
+func TestWalletManager_OfflineReceiver(t *testing.T) {
+
+	var created map[string]interface{}
+	server := newOfflineAddressTestServer(&created)
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.features[FeatureShielded] = true
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if _, err := wm.CreateOfflineAddress(3, ""); err != nil {
+		t.Errorf("CreateOfflineAddress unexpected error: %v", err)
+		return
+	}
+
+	tests := []struct {
+		tx   string
+		want string
+	}{
+		{`{"txId":"t1","income":true,"tx_type":8,"token":"offline"}`, "offline"},
+		{`{"txId":"t2","income":true,"tx_type":8,"receiver":"w1"}`, "offline"},
+		{`{"txId":"t3","income":true,"tx_type":8,"receiver":"w9"}`, ""},
+		{`{"txId":"t4","income":false,"tx_type":7,"token":"offline"}`, ""},
+		{`{"txId":"t5","income":true,"tx_type":0,"receiver":"w1"}`, ""},
+	}
+	for _, test := range tests {
+		result := gjson.Parse(test.tx)
+		tx := NewTransaction(&result)
+		if got := wm.offlineReceiver(tx); got != test.want {
+			t.Errorf("offlineReceiver(%s) = %s, want %s", test.tx, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package beam
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//地址所有权挑战的状态
+	OwnershipChallengePending  = "pending"
+	OwnershipChallengeVerified = "verified"
+	OwnershipChallengeExpired  = "expired"
+)
+
+//PaymentProof verify_payment_proof返回的付款证明内容，由接收方钱包签名
+type PaymentProof struct {
+	IsValid  bool
+	AssetID  int64
+	Sender   string //发送方SBBS钱包ID
+	Receiver string //接收方SBBS钱包ID
+	Amount   uint64
+	Kernel   string
+
+	/*
+		{
+		    "is_valid": true,
+		    "asset_id": 0,
+		    "sender": "1b516fb39884a3281bc0761f97817782a8bc51fdb1336882a2c7efebdb400d00d4",
+		    "receiver": "19d0adff5f02787819d8df43b442a49b43e72a8b0d04a7cf995237a0422d2be83b6",
+		    "amount": 12342342,
+		    "kernel": "ee3b10d4adba3ddb2b8ea9e2a9c8c8e7ee4ec3c3e3f2d5b7bb2aa8ef67bda34f"
+		}
+	*/
+}
+
+func NewPaymentProof(result *gjson.Result) *PaymentProof {
+	obj := PaymentProof{}
+	obj.IsValid = result.Get("is_valid").Bool()
+	obj.AssetID = result.Get("asset_id").Int()
+	obj.Sender = result.Get("sender").String()
+	obj.Receiver = result.Get("receiver").String()
+	obj.Amount = result.Get("amount").Uint()
+	obj.Kernel = result.Get("kernel").String()
+	return &obj
+}
+
+//ExportPaymentProof 导出自己发送的交易的付款证明
+func (c *WalletClient) ExportPaymentProof(ctx context.Context, txid string) (string, error) {
+	request := map[string]interface{}{
+		"txId": txid,
+	}
+
+	r, err := c.callContext(ctx, "export_payment_proof", request)
+	if err != nil {
+		return "", err
+	}
+
+	return r.Get("payment_proof").String(), nil
+}
+
+//VerifyPaymentProof 校验付款证明，不要求交易属于当前钱包
+func (c *WalletClient) VerifyPaymentProof(ctx context.Context, proof string) (*PaymentProof, error) {
+	request := map[string]interface{}{
+		"payment_proof": proof,
+	}
+
+	r, err := c.callContext(ctx, "verify_payment_proof", request)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPaymentProof(r), nil
+}
+
+//OwnershipChallenge 地址所有权挑战：用户从要证明的地址向Receiver付款Amount，
+//再提交发送方钱包导出的付款证明，付款证明的发送方和金额匹配时证明用户控制该地址
+type OwnershipChallenge struct {
+	ID               string `storm:"id"`
+	Address          string `storm:"index"` //要证明的地址
+	Receiver         string //收款的挑战地址
+	ReceiverWalletID string //挑战地址的SBBS钱包ID，付款证明中的接收方为钱包ID
+	Amount           uint64 //挑战金额，单位groth
+	Status           string
+	Kernel           string `storm:"index"` //已使用的付款证明
+	CreateTime       int64
+	ExpireTime       int64
+	VerifyTime       int64
+}
+
+func (wm *WalletManager) openOwnershipDB() (*storm.DB, error) {
+	return storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+}
+
+//randomChallengeAmount 1到MaxOwnershipChallengeAmount之间的随机金额
+func randomChallengeAmount() (uint64, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b)%MaxOwnershipChallengeAmount + 1, nil
+}
+
+//CreateOwnershipChallenge 为外部地址创建所有权挑战，挑战地址是新创建的永不过期地址，
+//挑战在ownershipchallengettl后过期
+func (wm *WalletManager) CreateOwnershipChallenge(address string) (*OwnershipChallenge, error) {
+
+	v, err := wm.walletClient.GetAddressValidation(context.Background(), address)
+	if err != nil {
+		return nil, err
+	}
+	if !v.IsValid {
+		return nil, fmt.Errorf("address: %s is invalid", address)
+	}
+	if v.IsMine {
+		return nil, fmt.Errorf("address: %s belongs to the wallet itself", address)
+	}
+
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		return nil, err
+	}
+	amount, err := randomChallengeAmount()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	challenge := &OwnershipChallenge{
+		ID:         hex.EncodeToString(id),
+		Address:    address,
+		Amount:     amount,
+		Status:     OwnershipChallengePending,
+		CreateTime: now.Unix(),
+		ExpireTime: now.Add(wm.Config.ownershipchallengettl).Unix(),
+	}
+
+	challenge.Receiver, err = wm.CreateAddress(AddressTypeRegular, AddressExpirationNever, "ownership:"+challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := wm.walletClient.ListAddresses(context.Background(), true)
+	if err != nil {
+		wm.Log.Warningf("list addresses for challenge address: %s failed, unexpected error: %v", challenge.Receiver, err)
+	}
+	for _, a := range addrs {
+		if a.Address == challenge.Receiver {
+			challenge.ReceiverWalletID = a.WalletID
+			break
+		}
+	}
+
+	db, err := wm.openOwnershipDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return challenge, db.Save(challenge)
+}
+
+//GetOwnershipChallenge 查询所有权挑战
+func (wm *WalletManager) GetOwnershipChallenge(id string) (*OwnershipChallenge, error) {
+
+	db, err := wm.openOwnershipDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var challenge OwnershipChallenge
+	if err = db.One("ID", id, &challenge); err != nil {
+		return nil, fmt.Errorf("ownership challenge: %s not found", id)
+	}
+	return &challenge, nil
+}
+
+//OwnershipChallenges 列出地址的所有权挑战，按创建时间排序
+func (wm *WalletManager) OwnershipChallenges(address string) ([]*OwnershipChallenge, error) {
+
+	db, err := wm.openOwnershipDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*OwnershipChallenge
+	err = db.Find("Address", address, &list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreateTime < list[j].CreateTime
+	})
+	return list, nil
+}
+
+//sameAddress 两个地址是否为同一身份，按规范化和地址映射比较
+func (wm *WalletManager) sameAddress(a, b string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	return wm.addressMapper.Resolve(a) == wm.addressMapper.Resolve(b)
+}
+
+//VerifyOwnershipProof 校验用户提交的付款证明，通过后挑战标记为verified；
+//付款证明中的发送方为SBBS钱包ID，要证明的地址是token时需要先把钱包ID映射到该地址
+func (wm *WalletManager) VerifyOwnershipProof(id, proof string) (*OwnershipChallenge, error) {
+
+	challenge, err := wm.GetOwnershipChallenge(id)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Status == OwnershipChallengeVerified {
+		return challenge, nil
+	}
+	if challenge.Status == OwnershipChallengePending && time.Now().Unix() > challenge.ExpireTime {
+		challenge.Status = OwnershipChallengeExpired
+		if saveErr := wm.saveOwnershipChallenge(challenge); saveErr != nil {
+			return nil, saveErr
+		}
+	}
+	if challenge.Status != OwnershipChallengePending {
+		return nil, fmt.Errorf("ownership challenge: %s is %s", id, challenge.Status)
+	}
+
+	p, err := wm.walletClient.VerifyPaymentProof(context.Background(), proof)
+	if err != nil {
+		return nil, err
+	}
+	if !p.IsValid {
+		return nil, fmt.Errorf("payment proof is invalid")
+	}
+	if p.AssetID != 0 || p.Amount != challenge.Amount {
+		return nil, fmt.Errorf("payment proof amount: %d asset: %d does not match the challenge", p.Amount, p.AssetID)
+	}
+	if !wm.sameAddress(p.Receiver, challenge.Receiver) && !wm.sameAddress(p.Receiver, challenge.ReceiverWalletID) {
+		return nil, fmt.Errorf("payment proof receiver: %s is not the challenge address", p.Receiver)
+	}
+	if !wm.sameAddress(p.Sender, challenge.Address) {
+		return nil, fmt.Errorf("payment proof sender: %s is not the address: %s", p.Sender, challenge.Address)
+	}
+
+	db, err := wm.openOwnershipDB()
+	if err != nil {
+		return nil, err
+	}
+	var used OwnershipChallenge
+	err = db.One("Kernel", p.Kernel, &used)
+	db.Close()
+	if err == nil {
+		return nil, fmt.Errorf("payment proof has been used by challenge: %s", used.ID)
+	}
+
+	challenge.Status = OwnershipChallengeVerified
+	challenge.Kernel = p.Kernel
+	challenge.VerifyTime = time.Now().Unix()
+	return challenge, wm.saveOwnershipChallenge(challenge)
+}
+
+func (wm *WalletManager) saveOwnershipChallenge(challenge *OwnershipChallenge) error {
+
+	db, err := wm.openOwnershipDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Save(challenge)
+}
+
+//ExportPaymentProof 导出提现交易的付款证明，第三方可用任意beam钱包校验接收方已收到付款
+func (wm *WalletManager) ExportPaymentProof(txid string) (string, error) {
+	return wm.walletClient.ExportPaymentProof(context.Background(), txid)
+}
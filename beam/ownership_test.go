@@ -0,0 +1,86 @@
+package beam
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestOwnershipChallenge(t *testing.T) {
+
+	userAddress := "3ab404a243fd09f827e8941e419e523a5b21e17c70563bfbc211dbe0e87ca95"
+	challengeWalletID := "2b11e4cbbd3c8f2a2d4d7ad1b6c4f0aa5ff28b0fdc27d0ef9e7d2bd0c77bf12"
+	proofs := make(map[string]string)
+
+	server := newRPCTestServer(rpcTestMethods{
+		"validate_address": func(params map[string]interface{}) (string, error) {
+			return fmt.Sprintf(`{"is_valid":true,"is_mine":%v,"type":"regular"}`, params["address"] == "mine"), nil
+		},
+		"create_address": rpcResult(`"challenge1"`),
+		"addr_list":      rpcResult(fmt.Sprintf(`[{"address":"challenge1","wallet_id":"%s","type":"regular","own":true}]`, challengeWalletID)),
+		"verify_payment_proof": func(params map[string]interface{}) (string, error) {
+			return proofs[params["payment_proof"].(string)], nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if _, err := wm.CreateOwnershipChallenge("mine"); err == nil {
+		t.Errorf("CreateOwnershipChallenge for own address should fail")
+	}
+
+	challenge, err := wm.CreateOwnershipChallenge(userAddress)
+	if err != nil {
+		t.Errorf("CreateOwnershipChallenge unexpected error: %v", err)
+		return
+	}
+	if challenge.Receiver != "challenge1" || challenge.ReceiverWalletID != challengeWalletID ||
+		challenge.Amount == 0 || challenge.Amount > MaxOwnershipChallengeAmount {
+		t.Errorf("challenge = %+v", challenge)
+	}
+
+	proof := func(valid bool, sender string, amount uint64, kernel string) string {
+		return fmt.Sprintf(`{"is_valid":%v,"asset_id":0,"sender":"%s","receiver":"%s","amount":%d,"kernel":"%s"}`,
+			valid, sender, challengeWalletID, amount, kernel)
+	}
+	proofs["invalid"] = proof(false, userAddress, challenge.Amount, "k0")
+	proofs["amount"] = proof(true, userAddress, challenge.Amount+1, "k1")
+	proofs["sender"] = proof(true, "1"+userAddress[1:], challenge.Amount, "k2")
+	proofs["ok"] = proof(true, "00"+userAddress, challenge.Amount, "k3")
+
+	for _, name := range []string{"invalid", "amount", "sender"} {
+		if _, err = wm.VerifyOwnershipProof(challenge.ID, name); err == nil {
+			t.Errorf("VerifyOwnershipProof %s should fail", name)
+		}
+	}
+
+	verified, err := wm.VerifyOwnershipProof(challenge.ID, "ok")
+	if err != nil || verified.Status != OwnershipChallengeVerified || verified.Kernel != "k3" {
+		t.Errorf("VerifyOwnershipProof = %+v, err = %v", verified, err)
+	}
+
+	//同一个付款证明不能用于其他挑战
+	second, _ := wm.CreateOwnershipChallenge(userAddress)
+	proofs["reuse"] = proof(true, userAddress, second.Amount, "k3")
+	if _, err = wm.VerifyOwnershipProof(second.ID, "reuse"); err == nil {
+		t.Errorf("VerifyOwnershipProof with used kernel should fail")
+	}
+
+	//过期的挑战
+	wm.Config.ownershipchallengettl = -time.Second
+	expired, _ := wm.CreateOwnershipChallenge(userAddress)
+	proofs["expired"] = proof(true, userAddress, expired.Amount, "k4")
+	if _, err = wm.VerifyOwnershipProof(expired.ID, "expired"); err == nil {
+		t.Errorf("VerifyOwnershipProof expired challenge should fail")
+	}
+	if c, _ := wm.GetOwnershipChallenge(expired.ID); c.Status != OwnershipChallengeExpired {
+		t.Errorf("expired challenge status = %s", c.Status)
+	}
+
+	if list, _ := wm.OwnershipChallenges(userAddress); len(list) != 3 {
+		t.Errorf("OwnershipChallenges = %d, want 3", len(list))
+	}
+}
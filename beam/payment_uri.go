@@ -0,0 +1,103 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	//PaymentURIScheme 收款URI的协议名
+	PaymentURIScheme = "beam"
+
+	//DefaultQRCodeScale 收款二维码每个模块的像素数
+	DefaultQRCodeScale = 8
+)
+
+//PaymentRequest 充值地址的收款请求，前端直接展示URI和二维码
+type PaymentRequest struct {
+	Address string `json:"address"`
+	Amount  string `json:"amount,omitempty"`  //收款金额，单位BEAM，为空时由付款方填写
+	Comment string `json:"comment,omitempty"` //交易备注，交易所用于匹配充值
+	URI     string `json:"uri"`
+	QRCode  []byte `json:"qrCode,omitempty"` //二维码PNG图片，内容为URI
+}
+
+//BuildPaymentURI 构造收款URI：beam:<地址>?amount=<金额>&comment=<备注>，金额和备注为空时不写入
+func BuildPaymentURI(address, amount, comment string) string {
+
+	query := make([]string, 0, 2)
+	if len(amount) > 0 {
+		query = append(query, "amount="+url.QueryEscape(amount))
+	}
+	if len(comment) > 0 {
+		query = append(query, "comment="+url.QueryEscape(comment))
+	}
+
+	uri := PaymentURIScheme + ":" + address
+	if len(query) > 0 {
+		uri += "?" + strings.Join(query, "&")
+	}
+	return uri
+}
+
+//PaymentQRCode 把收款URI编码为二维码PNG图片（M级纠错），scale为每个模块的像素数
+func PaymentQRCode(uri string, scale int) ([]byte, error) {
+	qr, err := qrcode.New(uri, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	//位图包含四周留白的模块
+	return qr.PNG(len(qr.Bitmap()) * scale)
+}
+
+//CreatePaymentRequest 为钱包自己的充值地址生成收款URI，qrScale大于0时同时生成二维码；
+//金额不能超过BEAM的精度，已归档的地址不能收款
+func (wm *WalletManager) CreatePaymentRequest(address, amount, comment string, qrScale int) (*PaymentRequest, error) {
+
+	v, err := wm.walletClient.GetAddressValidation(context.Background(), address)
+	if err != nil {
+		return nil, err
+	}
+	if !v.IsValid || !v.IsMine {
+		return nil, fmt.Errorf("address: %s does not belong to the wallet", address)
+	}
+	if entry, findErr := wm.addressBook.Get(address); findErr == nil && entry.Archived {
+		return nil, fmt.Errorf("address: %s is archived", address)
+	}
+
+	if len(amount) > 0 {
+		value, err := decimal.NewFromString(amount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount: %s", amount)
+		}
+		if !value.IsPositive() {
+			return nil, fmt.Errorf("amount must be positive")
+		}
+		if !value.Equal(value.Truncate(wm.Decimal())) {
+			return nil, fmt.Errorf("amount: %s exceeds %d decimals", amount, wm.Decimal())
+		}
+		amount = value.String()
+	}
+	if err = checkTxComment(comment); err != nil {
+		return nil, err
+	}
+
+	request := &PaymentRequest{
+		Address: address,
+		Amount:  amount,
+		Comment: comment,
+		URI:     BuildPaymentURI(address, amount, comment),
+	}
+	if qrScale > 0 {
+		request.QRCode, err = PaymentQRCode(request.URI, qrScale)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
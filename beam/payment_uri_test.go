@@ -0,0 +1,76 @@
+package beam
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestBuildPaymentURI(t *testing.T) {
+
+	tests := []struct {
+		amount  string
+		comment string
+		want    string
+	}{
+		{"", "", "beam:1f2e3d"},
+		{"1.5", "", "beam:1f2e3d?amount=1.5"},
+		{"", "order 42&x", "beam:1f2e3d?comment=order+42%26x"},
+		{"0.00000001", "用户", "beam:1f2e3d?amount=0.00000001&comment=%E7%94%A8%E6%88%B7"},
+	}
+	for _, test := range tests {
+		if uri := BuildPaymentURI("1f2e3d", test.amount, test.comment); uri != test.want {
+			t.Errorf("BuildPaymentURI(%s, %s) = %s, want %s", test.amount, test.comment, uri, test.want)
+		}
+	}
+}
+
+func TestCreatePaymentRequest(t *testing.T) {
+
+	server := newRPCTestServer(rpcTestMethods{
+		"validate_address": func(params map[string]interface{}) (string, error) {
+			if params["address"] == "other" {
+				return `{"is_valid":true,"is_mine":false}`, nil
+			}
+			return `{"is_valid":true,"is_mine":true}`, nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	request, err := wm.CreatePaymentRequest("deposit", "1.50", "order 42", DefaultQRCodeScale)
+	if err != nil {
+		t.Fatalf("CreatePaymentRequest unexpected error: %v", err)
+	}
+	if request.URI != "beam:deposit?amount=1.5&comment=order+42" || !bytes.HasPrefix(request.QRCode, []byte("\x89PNG")) {
+		t.Errorf("payment request = %s, qr code %d bytes", request.URI, len(request.QRCode))
+	}
+	if img, err := png.Decode(bytes.NewReader(request.QRCode)); err != nil || img.Bounds().Dx()%DefaultQRCodeScale != 0 {
+		t.Errorf("qr code image is invalid: %v", err)
+	}
+	if request, _ = wm.CreatePaymentRequest("deposit", "", "", 0); request.URI != "beam:deposit" || request.QRCode != nil {
+		t.Errorf("payment request without amount = %+v", request)
+	}
+
+	for _, args := range [][]string{
+		{"other", "1", ""},
+		{"deposit", "0", ""},
+		{"deposit", "abc", ""},
+		{"deposit", "0.000000001", ""},
+		{"deposit", "1", strings.Repeat("x", MaxTxCommentLength+1)},
+	} {
+		if _, err = wm.CreatePaymentRequest(args[0], args[1], args[2], 0); err == nil {
+			t.Errorf("CreatePaymentRequest(%s, %s) should fail", args[0], args[1])
+		}
+	}
+
+	wm.addressBook.add(AddressTypeRegular, "", AddressPurposeDeposit, "deposit")
+	wm.addressBook.Archive("deposit")
+	if _, err = wm.CreatePaymentRequest("deposit", "", "", 0); err == nil {
+		t.Errorf("payment request of archived address should fail")
+	}
+}
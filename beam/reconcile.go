@@ -0,0 +1,208 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	//对账差异类型
+	ReconcileMissingInLedger = "missing_in_ledger" //钱包已完成的交易没有记账，可能是漏扫的区块
+	ReconcileNotInWallet     = "not_in_wallet"     //账本有记录但钱包没有该交易或交易已取消、失败，可能是回滚的区块
+	ReconcileAmountMismatch  = "amount_mismatch"   //钱包和账本的金额不一致
+	ReconcileInProgress      = "in_progress"       //钱包中处理中的交易，已计入余额但未上链，通常会自行消失
+)
+
+//ReconcileItem 对账差异，金额为对BEAM余额的影响，单位groth，可以为负数
+type ReconcileItem struct {
+	TxID         string `json:"txid"`
+	Kind         string `json:"kind"`
+	BlockHeight  uint64 `json:"blockHeight"`
+	BlockHash    string `json:"blockHash,omitempty"`
+	WalletAmount int64  `json:"walletAmount"`
+	LedgerAmount int64  `json:"ledgerAmount"`
+	Detail       string `json:"detail,omitempty"`
+}
+
+//Difference 钱包金额减账本金额
+func (item *ReconcileItem) Difference() int64 {
+	return item.WalletAmount - item.LedgerAmount
+}
+
+//ReconcileReport 钱包余额与本地账本的对账报告，单位groth
+type ReconcileReport struct {
+	WalletBalance int64            `json:"walletBalance"` //available + maturing + receiving
+	LedgerBalance int64            `json:"ledgerBalance"` //账本入账减出账和手续费
+	Difference    int64            `json:"difference"`    //钱包余额减账本余额
+	Explained     int64            `json:"explained"`     //差异交易合计的差额
+	Unexplained   int64            `json:"unexplained"`   //无法对应到交易的差额，如开始扫块前的余额
+	Items         []*ReconcileItem `json:"items"`
+	Height        uint64           `json:"height"`
+	Time          int64            `json:"time"`
+}
+
+//Balanced 钱包余额与账本一致
+func (r *ReconcileReport) Balanced() bool {
+	return r.Difference == 0
+}
+
+//walletTxEffect 钱包交易单对BEAM余额的影响：收款加金额，付款减金额和手续费，转给自己只减手续费，
+//取消和失败的交易不影响余额；机密资产交易只计入手续费
+func walletTxEffect(tx *Transaction, own map[string]bool) int64 {
+	if tx.Status == TxStatusCanceled || tx.Status == TxStatusFailed {
+		return 0
+	}
+	value := int64(tx.Value)
+	if tx.AssetID != BeamAssetID {
+		value = 0
+	}
+	if tx.Income {
+		return value
+	}
+	if own[tx.Receiver] {
+		return -int64(tx.Fee)
+	}
+	return -value - int64(tx.Fee)
+}
+
+//ledgerRecordEffect 账本记录对BEAM余额的影响，机密资产记录只计入手续费
+func ledgerRecordEffect(r *LedgerRecord, decimals int32) (int64, error) {
+	effect := int64(0)
+	if r.AssetID == BeamAssetID {
+		amount, err := decimal.NewFromString(r.Amount)
+		if err != nil {
+			return 0, fmt.Errorf("ledger record: %s amount: %s is invalid", r.ID, r.Amount)
+		}
+		effect = amount.Shift(decimals).IntPart()
+		if r.Direction == LedgerDirectionOut {
+			effect = -effect
+		}
+	}
+	if r.Direction == LedgerDirectionOut && len(r.Fees) > 0 {
+		fees, err := decimal.NewFromString(r.Fees)
+		if err != nil {
+			return 0, fmt.Errorf("ledger record: %s fees: %s is invalid", r.ID, r.Fees)
+		}
+		effect -= fees.Shift(decimals).IntPart()
+	}
+	return effect, nil
+}
+
+//Reconcile 对比钱包余额（available + maturing + receiving）与本地账本记账的BEAM余额，
+//按交易单比较钱包交易记录和账本记录，列出可能造成差异的交易和区块，按区块高度排序
+func (wm *WalletManager) Reconcile() (*ReconcileReport, error) {
+
+	status, err := wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := wm.walletClient.ListAddresses(context.Background(), true)
+	if err != nil {
+		return nil, err
+	}
+	own := make(map[string]bool)
+	for _, address := range addresses {
+		own[address.Address] = true
+	}
+
+	txs, err := NewTxListIterator(wm.walletClient, TxListFilter{}, wm.Config.txpagesize).collect(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return nil, err
+	}
+	var records []*LedgerRecord
+	err = db.All(&records)
+	db.Close()
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	report := &ReconcileReport{
+		WalletBalance: int64(status.Available + status.Maturing + status.Receiving),
+		Items:         make([]*ReconcileItem, 0),
+		Height:        status.CurrentHeight,
+		Time:          time.Now().Unix(),
+	}
+
+	//账本按交易单合计
+	ledger := make(map[string]*ReconcileItem)
+	for _, r := range records {
+		effect, err := ledgerRecordEffect(r, wm.Decimal())
+		if err != nil {
+			return nil, err
+		}
+		report.LedgerBalance += effect
+		item, ok := ledger[r.TxID]
+		if !ok {
+			item = &ReconcileItem{TxID: r.TxID, BlockHeight: r.BlockHeight, BlockHash: r.BlockHash}
+			ledger[r.TxID] = item
+		}
+		item.LedgerAmount += effect
+	}
+
+	for _, tx := range txs {
+		item, recorded := ledger[tx.TxID]
+		delete(ledger, tx.TxID)
+		if !recorded {
+			item = &ReconcileItem{TxID: tx.TxID, BlockHeight: tx.BlockHeight, BlockHash: tx.BlockHash}
+		}
+		item.WalletAmount = walletTxEffect(tx, own)
+		if item.Difference() == 0 {
+			continue
+		}
+
+		switch {
+		case tx.Status != TxStatusCompleted && item.WalletAmount != 0:
+			item.Kind = ReconcileInProgress
+		case tx.Status != TxStatusCompleted:
+			item.Kind = ReconcileNotInWallet
+		case !recorded:
+			item.Kind = ReconcileMissingInLedger
+		default:
+			item.Kind = ReconcileAmountMismatch
+		}
+		item.Detail = fmt.Sprintf("wallet tx status: %s", tx.StatusString)
+		if item.BlockHeight == 0 {
+			item.BlockHeight = tx.BlockHeight
+		}
+		report.Items = append(report.Items, item)
+	}
+
+	//钱包中没有的账本交易
+	for _, item := range ledger {
+		if item.LedgerAmount == 0 {
+			continue
+		}
+		item.Kind = ReconcileNotInWallet
+		item.Detail = "tx not found in wallet"
+		report.Items = append(report.Items, item)
+	}
+
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		if report.Items[i].BlockHeight != report.Items[j].BlockHeight {
+			return report.Items[i].BlockHeight < report.Items[j].BlockHeight
+		}
+		return report.Items[i].TxID < report.Items[j].TxID
+	})
+
+	report.Difference = report.WalletBalance - report.LedgerBalance
+	for _, item := range report.Items {
+		report.Explained += item.Difference()
+	}
+	report.Unexplained = report.Difference - report.Explained
+
+	wm.Log.Infof("Reconcile: wallet balance: %d, ledger balance: %d, difference: %d, explained: %d, items: %d",
+		report.WalletBalance, report.LedgerBalance, report.Difference, report.Explained, len(report.Items))
+	return report, nil
+}
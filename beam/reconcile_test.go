@@ -0,0 +1,76 @@
+package beam
+
+import (
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+
+	server := newRPCTestServer(rpcTestMethods{
+		"wallet_status": rpcResult(`{"current_height":300,"available":169800000,"receiving":10000000,"maturing":0}`),
+		"addr_list":     rpcResult(`[{"address":"a","own":true},{"address":"self","own":true}]`),
+		"tx_list": rpcResult(`[
+			{"txId":"t1","income":true,"value":100000000,"fee":0,"status":3,"height":100,"receiver":"a"},
+			{"txId":"t2","income":false,"value":30000000,"fee":100000,"status":3,"height":110,"receiver":"external"},
+			{"txId":"t3","income":true,"value":50000000,"fee":0,"status":3,"height":120,"receiver":"a"},
+			{"txId":"t5","income":true,"value":10000000,"fee":0,"status":1,"height":0,"receiver":"a"},
+			{"txId":"t6","income":true,"value":45000000,"fee":0,"status":3,"height":140,"receiver":"a"},
+			{"txId":"t7","income":false,"value":20000000,"fee":100000,"status":3,"height":150,"receiver":"self"},
+			{"txId":"t8","income":false,"value":70000000,"fee":100000,"status":4,"height":0,"receiver":"external"}
+		]`),
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	record := func(txID, address, direction, amount, fees string, height uint64) *LedgerRecord {
+		r := NewLedgerRecord(txID, address, direction)
+		r.Amount, r.Fees, r.BlockHeight = amount, fees, height
+		return r
+	}
+	err := wm.SaveLedgerRecords(
+		record("t1", "a", LedgerDirectionIn, "1", "", 100),
+		record("t2", "a", LedgerDirectionOut, "0.3", "0.001", 110),
+		record("t4", "a", LedgerDirectionIn, "0.2", "", 130),
+		record("t6", "a", LedgerDirectionIn, "0.4", "", 140),
+		record("t7", "a", LedgerDirectionOut, "0.2", "0.001", 150),
+		record("t7", "self", LedgerDirectionIn, "0.2", "", 150),
+	)
+	if err != nil {
+		t.Fatalf("SaveLedgerRecords unexpected error: %v", err)
+	}
+
+	report, err := wm.Reconcile()
+	if err != nil {
+		t.Fatalf("Reconcile unexpected error: %v", err)
+	}
+	if report.WalletBalance != 179800000 || report.LedgerBalance != 129800000 || report.Difference != 50000000 {
+		t.Errorf("balances = %d, %d, difference = %d", report.WalletBalance, report.LedgerBalance, report.Difference)
+	}
+	if report.Explained != 45000000 || report.Unexplained != 5000000 || report.Balanced() {
+		t.Errorf("explained = %d, unexplained = %d", report.Explained, report.Unexplained)
+	}
+
+	want := []struct {
+		txID string
+		kind string
+	}{
+		{"t5", ReconcileInProgress},
+		{"t3", ReconcileMissingInLedger},
+		{"t4", ReconcileNotInWallet},
+		{"t6", ReconcileAmountMismatch},
+	}
+	if len(report.Items) != len(want) {
+		t.Fatalf("items = %d, want %d", len(report.Items), len(want))
+	}
+	for i, w := range want {
+		if report.Items[i].TxID != w.txID || report.Items[i].Kind != w.kind {
+			t.Errorf("item %d = %+v, want %s %s", i, report.Items[i], w.txID, w.kind)
+		}
+	}
+	if report.Items[3].Difference() != 5000000 || report.Items[2].BlockHeight != 130 {
+		t.Errorf("mismatch item = %+v, not in wallet item = %+v", report.Items[3], report.Items[2])
+	}
+}
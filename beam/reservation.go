@@ -0,0 +1,145 @@
+package beam
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+const (
+	//余额预留的来源
+	ReservationSourceWithdrawalQueue = "withdrawalqueue" //提现队列中未广播的提现请求
+)
+
+//BalanceReservation 为已接受但未广播的提现预留的余额，广播后钱包可用余额已扣除，预留随之释放
+type BalanceReservation struct {
+	Key        string `storm:"id"` //业务标识，提现队列为幂等键
+	Source     string `storm:"index"`
+	Amount     uint64 //预留金额，含手续费，单位groth
+	CreateTime int64
+	UpdateTime int64
+}
+
+//ReservationLedger 余额预留账本，记录在本地数据库，钱包可用余额减去预留总额为可以提现的余额
+type ReservationLedger struct {
+	wm *WalletManager
+	mu sync.Mutex
+}
+
+func newReservationLedger(wm *WalletManager) *ReservationLedger {
+	return &ReservationLedger{wm: wm}
+}
+
+//Reservations 余额预留账本
+func (wm *WalletManager) Reservations() *ReservationLedger {
+	return wm.reservations
+}
+
+func (l *ReservationLedger) open() (*storm.DB, error) {
+	return storm.Open(filepath.Join(l.wm.Config.dbPath, l.wm.Config.BlockchainFile))
+}
+
+//Reserve 预留余额，相同key重复预留时更新金额
+func (l *ReservationLedger) Reserve(key, source string, amount uint64) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	db, err := l.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	now := time.Now().Unix()
+	var r BalanceReservation
+	if err := db.One("Key", key, &r); err != nil {
+		r = BalanceReservation{Key: key, CreateTime: now}
+	}
+	r.Source = source
+	r.Amount = amount
+	r.UpdateTime = now
+	return db.Save(&r)
+}
+
+//Release 释放预留，没有预留时忽略
+func (l *ReservationLedger) Release(key string) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	db, err := l.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteStruct(&BalanceReservation{Key: key})
+	if err == storm.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+//List 列出全部预留，按创建时间排序
+func (l *ReservationLedger) List() ([]*BalanceReservation, error) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	db, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*BalanceReservation
+	err = db.All(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreateTime < list[j].CreateTime
+	})
+	return list, nil
+}
+
+//Total 预留总额，exclude中的key不计入
+func (l *ReservationLedger) Total(exclude ...string) (uint64, error) {
+
+	list, err := l.List()
+	if err != nil {
+		return 0, err
+	}
+
+	excluded := make(map[string]bool)
+	for _, key := range exclude {
+		excluded[key] = true
+	}
+
+	total := uint64(0)
+	for _, r := range list {
+		if !excluded[r.Key] {
+			total += r.Amount
+		}
+	}
+	return total, nil
+}
+
+//unreservedBalance 可用余额减去预留总额，不足时为0；账本读取失败时不扣除，记录日志
+func (wm *WalletManager) unreservedBalance(available uint64, exclude ...string) uint64 {
+
+	reserved, err := wm.reservations.Total(exclude...)
+	if err != nil {
+		wm.Log.Warningf("load balance reservations failed, unexpected error: %v", err)
+		return available
+	}
+	if reserved >= available {
+		return 0
+	}
+	return available - reserved
+}
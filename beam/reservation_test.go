@@ -0,0 +1,101 @@
+package beam
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestReservationLedger(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	l := wm.Reservations()
+
+	l.Reserve("a", ReservationSourceWithdrawalQueue, 100)
+	l.Reserve("b", ReservationSourceWithdrawalQueue, 200)
+	l.Reserve("a", ReservationSourceWithdrawalQueue, 300)
+
+	if total, err := l.Total(); err != nil || total != 500 {
+		t.Errorf("Total = %d, %v, want 500", total, err)
+	}
+	if total, _ := l.Total("a"); total != 200 {
+		t.Errorf("Total exclude a = %d, want 200", total)
+	}
+	if got := wm.unreservedBalance(400); got != 0 {
+		t.Errorf("unreservedBalance(400) = %d, want 0", got)
+	}
+	if got := wm.unreservedBalance(1000, "b"); got != 700 {
+		t.Errorf("unreservedBalance(1000, b) = %d, want 700", got)
+	}
+
+	if err := l.Release("a"); err != nil {
+		t.Errorf("Release unexpected error: %v", err)
+	}
+	if err := l.Release("unknown"); err != nil {
+		t.Errorf("Release unknown unexpected error: %v", err)
+	}
+	if list, _ := l.List(); len(list) != 1 || list[0].Key != "b" {
+		t.Errorf("List = %+v", list)
+	}
+}
+
+func TestWithdrawalQueue_Reservation(t *testing.T) {
+
+	var (
+		status   = TxStatusInProgress
+		failSend bool
+		sends    int
+	)
+	server := newWithdrawalQueueTestServer(&status, &failSend, &sends)
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	q := wm.WithdrawalQueue()
+
+	//排队的提现预留金额和手续费
+	q.Enqueue("order-1", "to", 99990000, 100, "")
+	q.Enqueue("order-2", "to", 1000, 100, "")
+	if total, _ := wm.Reservations().Total(); total != 99991200 {
+		t.Errorf("reserved = %d, want 99991200", total)
+	}
+
+	//直接提现不能使用预留的余额
+	decoder := NewTransactionDecoder(wm)
+	rawTx := &openwallet.RawTransaction{
+		Coin: openwallet.Coin{Symbol: Symbol},
+		To:   map[string]string{"to": "0.01"},
+	}
+	if _, err := decoder.SubmitRawTransaction(nil, rawTx); err == nil {
+		t.Errorf("SubmitRawTransaction should fail when balance is reserved")
+	}
+
+	//取消后释放预留
+	q.Cancel("order-2")
+	if total, _ := wm.Reservations().Total(); total != 99990100 {
+		t.Errorf("reserved after cancel = %d, want 99990100", total)
+	}
+
+	//发送失败的请求保留预留，广播后释放
+	failSend = true
+	q.Process()
+	if r, _ := q.Get("order-1"); r.State != WithdrawalStateFailed {
+		t.Errorf("order-1 = %+v, want failed", r)
+	}
+	if total, _ := wm.Reservations().Total(); total != 99990100 {
+		t.Errorf("reserved after failure = %d, want 99990100", total)
+	}
+
+	failSend = false
+	q.Retry("order-1")
+	q.Process()
+	if r, _ := q.Get("order-1"); r.State != WithdrawalStateSent {
+		t.Errorf("order-1 = %+v, want sent", r)
+	}
+	if total, _ := wm.Reservations().Total(); total != 0 {
+		t.Errorf("reserved after sent = %d, want 0", total)
+	}
+}
@@ -1,12 +1,16 @@
 package beam
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/blocktree/openwallet/log"
 	"github.com/imroc/req"
 	"github.com/tidwall/gjson"
 	"net/http"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // A Client is a Bitcoin RPC client. It performs RPCs over HTTP using JSON
@@ -16,16 +20,47 @@ type WalletClient struct {
 	WalletAPI, ExplorerAPI string
 	Debug                  bool
 	client                 *req.Req
+	endpoints              *walletEndpoints
+	opts                   *rpcOptions
+	auth                   atomic.Value //WalletAPIAuth
+	authSource             func() (WalletAPIAuth, error)
+	explorer               *ExplorerClient
+	blockSources           []string
+	batchUnsupported       int32
+	txPageSize             uint64
+	version                atomic.Value //*WalletAPIVersion
+	limiter                *rateLimiter
+	breaker                *circuitBreaker
+	ws                     *wsTransport
+}
+
+//rpcOptions 请求的重试和超时设置，钱包API和浏览器API共用
+type rpcOptions struct {
+	retryPolicy    RetryPolicy
+	timeout        time.Duration
+	methodTimeouts map[string]time.Duration
+	metrics        *Metrics
+	tracer         *rpcTracer
 }
 
 func NewWalletClient(walletAPI, explorerAPI string, debug bool) *WalletClient {
 
-	walletAPI = strings.TrimSuffix(walletAPI, "/")
+	endpoints := newWalletEndpoints(walletAPI)
+	if len(endpoints.status) > 0 {
+		walletAPI = endpoints.status[0].URL
+	}
 	explorerAPI = strings.TrimSuffix(explorerAPI, "/")
 	c := WalletClient{
 		WalletAPI:   walletAPI,
 		ExplorerAPI: explorerAPI,
 		Debug:       debug,
+		endpoints:   endpoints,
+		opts: &rpcOptions{
+			retryPolicy: defaultRetryPolicy(),
+			timeout:     DefaultRPCTimeout,
+		},
+		blockSources: []string{BlockSourceExplorer},
+		txPageSize:   DefaultTxPageSize,
 	}
 
 	api := req.New()
@@ -33,74 +68,172 @@ func NewWalletClient(walletAPI, explorerAPI string, debug bool) *WalletClient {
 	//trans.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	c.client = api
 
+	//浏览器API共用http连接、TLS、重试和超时设置
+	c.explorer = &ExplorerClient{
+		URL:    explorerAPI,
+		Debug:  debug,
+		client: api,
+		opts:   c.opts,
+	}
+
 	return &c
 }
 
-// Call calls a remote procedure on another node, specified by the path.
-func (c *WalletClient) call(method string, request interface{}) (*gjson.Result, error) {
+// callContext calls a remote procedure, the request is aborted when ctx is done.
+func (c *WalletClient) callContext(ctx context.Context, method string, request interface{}) (result *gjson.Result, err error) {
 
 	var (
-		body = make(map[string]interface{}, 0)
+		data []byte
 	)
 
 	if c.client == nil {
 		return nil, fmt.Errorf("API url is not setup. ")
 	}
 
-	authHeader := req.Header{
-		"Accept":       "application/json",
-		"Content-Type": "application/json",
-	}
+	defer func(start time.Time) {
+		c.opts.observe(rpcAPIWallet, method, start, err)
+	}(time.Now())
 
-	//json-rpc
-	body["jsonrpc"] = "2.0"
-	body["id"] = 1
-	body["method"] = method
-	body["params"] = request
+	//熔断中不发送请求
+	if err = c.breaker.allow(); err != nil {
+		return nil, err
+	}
 
-	if c.Debug {
-		log.Std.Info("Start Request API...")
+	//只读方法遇到临时错误时按重试策略重试
+	err = c.opts.retry(ctx, !walletAPIWriteMethods[method], func() (err error) {
+		data, err = c.send(ctx, method, newRPCBody(1, method, c.adaptRequest(method, request)))
+		return err
+	})
+	c.breaker.record(err)
+	if err != nil {
+		return nil, err
 	}
 
-	r, err := c.client.Post(c.WalletAPI, req.BodyJSON(&body), authHeader)
+	resp := gjson.ParseBytes(data)
 
-	if c.Debug {
-		log.Std.Info("Request API Completed")
+	res := resp.Get("result")
+
+	return &res, nil
+}
+
+// newRPCBody json-rpc request body
+func newRPCBody(id int, method string, request interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  request,
 	}
+}
 
-	if c.Debug {
-		log.Std.Info("%+v", r)
+// send sends the json-rpc request by the configured transport and returns the response body.
+func (c *WalletClient) send(ctx context.Context, method string, body map[string]interface{}) ([]byte, error) {
+
+	if c.ws != nil {
+		data, err := c.ws.call(ctx, method, body)
+		if err != nil {
+			return nil, err
+		}
+		return data, rpcError(gjson.ParseBytes(data))
 	}
 
+	r, err := c.postEndpoints(ctx, method, body)
 	if err != nil {
 		return nil, err
 	}
-
-	resp := gjson.ParseBytes(r.Bytes())
-	err = c.isError(r)
-	if err != nil {
+	//认证失败时重新读取轮换后的认证信息，有变化时重试一次
+	if r.Response().StatusCode == http.StatusUnauthorized {
+		if reloaded, reloadErr := c.reloadAuth(); reloadErr != nil {
+			log.Std.Warn("reload wallet api auth failed, unexpected error: %v", reloadErr)
+		} else if reloaded {
+			if r, err = c.postEndpoints(ctx, method, body); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = isError(r); err != nil {
 		return nil, err
 	}
+	return r.Bytes(), nil
+}
 
-	result := resp.Get("result")
+// postEndpoints tries the available wallet API endpoints in turn until one responds.
+func (c *WalletClient) postEndpoints(ctx context.Context, method string, body interface{}) (*req.Resp, error) {
 
-	return &result, nil
-}
+	var (
+		r       *req.Resp
+		err     error
+		lastErr = fmt.Errorf("API url is not setup. ")
+	)
+
+	//依次尝试可用的钱包API节点，节点不可用时切换到下一个
+	for _, u := range c.endpoints.urls() {
+		//扫描和管理请求共用限流，避免追块时压垮共享的钱包节点
+		if err = c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		r, err = c.post(ctx, u, method, body)
+		if err == nil && r.Response().StatusCode < http.StatusInternalServerError {
+			c.endpoints.mark(u, nil)
+			break
+		}
+		if err == nil {
+			err = isError(r)
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
 
-// GET
-func (c *WalletClient) get(path string) (*gjson.Result, error) {
+		c.endpoints.mark(u, err)
+		log.Std.Warn("wallet api: %s request failed, unexpected error: %v", u, err)
+		lastErr = err
+		r = nil
 
-	if c.client == nil {
-		return nil, fmt.Errorf("API url is not setup. ")
+		//非幂等的方法只有确定请求没有发出时才切换节点
+		if walletAPIWriteMethods[method] && !isDialError(err) {
+			return nil, err
+		}
+	}
+
+	if r == nil {
+		return nil, lastErr
 	}
 
+	return r, nil
+}
+
+// post json-rpc request body to the wallet API url, the response body is read within the method timeout.
+func (c *WalletClient) post(ctx context.Context, url, method string, body interface{}) (*req.Resp, error) {
+
+	ctx, cancel := c.opts.withTimeout(ctx, method)
+	defer cancel()
+
+	authHeader := c.authHeader(req.Header{
+		"Accept":       "application/json",
+		"Content-Type": "application/json",
+	})
+
 	if c.Debug {
 		log.Std.Info("Start Request API...")
 	}
 
-	path = c.ExplorerAPI + "/" + path
+	start := time.Now()
+	r, err := c.client.Post(url, req.BodyJSON(&body), authHeader, ctx)
+	if err == nil {
+		_, err = r.ToBytes()
+	}
 
-	r, err := c.client.Get(path)
+	if c.opts.tracer != nil {
+		request, _ := json.Marshal(body)
+		var (
+			response []byte
+			status   int
+		)
+		if r != nil && r.Response() != nil {
+			response, status = r.Bytes(), r.Response().StatusCode
+		}
+		c.opts.tracer.trace(newTraceID(), rpcAPIWallet, method, url, start, request, response, status, err)
+	}
 
 	if c.Debug {
 		log.Std.Info("Request API Completed")
@@ -114,25 +247,24 @@ func (c *WalletClient) get(path string) (*gjson.Result, error) {
 		return nil, err
 	}
 
-	resp := gjson.ParseBytes(r.Bytes())
-	err = c.isError(r)
-	if err != nil {
-		return nil, err
-	}
-
-	return &resp, nil
+	return r, nil
 }
 
 //isError 是否报错
-func (c *WalletClient) isError(r *req.Resp) error {
+func isError(r *req.Resp) error {
 
 	if r.Response().StatusCode != http.StatusOK {
 		message := r.Response().Status
 		status := r.Response().StatusCode
-		return fmt.Errorf("[%d]%s", status, message)
+		return &rpcStatusError{code: status, status: message}
 	}
 
-	result := gjson.ParseBytes(r.Bytes())
+	return rpcError(gjson.ParseBytes(r.Bytes()))
+
+}
+
+//rpcError json-rpc响应中的错误
+func rpcError(result gjson.Result) error {
 
 	if result.Get("error").IsObject() {
 
@@ -143,18 +275,25 @@ func (c *WalletClient) isError(r *req.Resp) error {
 	}
 
 	return nil
+}
 
+//CreateAddress 创建普通地址，expiration为地址有效期，comment为地址标签
+func (c *WalletClient) CreateAddress(ctx context.Context, expiration, comment string) (string, error) {
+	return c.CreateAddressWithType(ctx, AddressTypeRegular, expiration, comment)
 }
 
-//CreateAddress
-func (c *WalletClient) CreateAddress() (string, error) {
+//CreateAddressWithType 创建指定类型的地址，普通地址不传type参数，兼容不支持地址类型的钱包API
+func (c *WalletClient) CreateAddressWithType(ctx context.Context, addressType, expiration, comment string) (string, error) {
 
 	request := map[string]interface{}{
-		"expiration": "never",
-		"comment":    "self", //标记自己创建的地址
+		"expiration": expiration,
+		"comment":    comment,
+	}
+	if addressType != AddressTypeRegular {
+		request["type"] = addressType
 	}
 
-	r, err := c.call("create_address", request)
+	r, err := c.callContext(ctx, "create_address", request)
 	if err != nil {
 		return "", err
 	}
@@ -164,7 +303,7 @@ func (c *WalletClient) CreateAddress() (string, error) {
 // CreateBatchAddress 批量创建地址
 // @count 连续创建数量
 // @workerSize 并行线程数。建议20条。
-func (c *WalletClient) CreateBatchAddress(count, workerSize uint64) ([]string, error) {
+func (c *WalletClient) CreateBatchAddress(ctx context.Context, count, workerSize uint64) ([]string, error) {
 
 	var (
 		quit         = make(chan struct{})
@@ -214,7 +353,7 @@ func (c *WalletClient) CreateBatchAddress(count, workerSize uint64) ([]string, e
 			go func(end chan struct{}, mProducer chan<- AddressCreateResult) {
 
 				//生成地址
-				addr, createErr := c.CreateAddress()
+				addr, createErr := c.CreateAddress(ctx, AddressExpirationNever, DefaultAddressComment)
 				result := AddressCreateResult{
 					Success: true,
 					Address: addr,
@@ -279,13 +418,13 @@ func batchCreateAddressRuntime(producer chan AddressCreateResult, worker chan Ad
 }
 
 //GetAddressList
-func (c *WalletClient) GetAddressList() ([]string, error) {
+func (c *WalletClient) GetAddressList(ctx context.Context) ([]string, error) {
 
 	request := map[string]interface{}{
 		"own": true,
 	}
 
-	r, err := c.call("addr_list", request)
+	r, err := c.callContext(ctx, "addr_list", request)
 	if err != nil {
 		return nil, err
 	}
@@ -296,6 +435,10 @@ func (c *WalletClient) GetAddressList() ([]string, error) {
 			own := a.Get("own").Bool()
 			expired := a.Get("expired").Bool()
 			commet := a.Get("comment").String()
+			//隐私地址不能作为发送地址
+			if isShieldedAddressType(a.Get("type").String()) {
+				continue
+			}
 			if own && expired == false && "self" == commet {
 				addrs = append(addrs, a.Get("address").String())
 			}
@@ -306,8 +449,18 @@ func (c *WalletClient) GetAddressList() ([]string, error) {
 	return addrs, nil
 }
 
-//SendTransaction
-func (c *WalletClient) SendTransaction(from, to string, value, fee uint64, comment string) (string, error) {
+//SendTransaction 发送交易，txID为generate_tx_id预先生成的交易ID，为空时由钱包生成
+func (c *WalletClient) SendTransaction(ctx context.Context, from, to string, value, fee uint64, comment, txID string) (string, error) {
+	return c.SendTransactionWithCoins(ctx, from, to, value, fee, comment, txID, nil)
+}
+
+//SendTransactionWithCoins 使用coinIDs指定的UTXO发送交易，coinIDs为空时由钱包选择UTXO
+func (c *WalletClient) SendTransactionWithCoins(ctx context.Context, from, to string, value, fee uint64, comment, txID string, coinIDs []string) (string, error) {
+	return c.sendTransaction(ctx, from, to, BeamAssetID, value, fee, comment, txID, coinIDs)
+}
+
+//sendTransaction 调用tx_send，assetID为0时发送BEAM
+func (c *WalletClient) sendTransaction(ctx context.Context, from, to string, assetID int64, value, fee uint64, comment, txID string, coinIDs []string) (string, error) {
 
 	request := map[string]interface{}{
 		"value":   value,
@@ -316,128 +469,161 @@ func (c *WalletClient) SendTransaction(from, to string, value, fee uint64, comme
 		"address": to,
 		"comment": comment,
 	}
+	if len(txID) > 0 {
+		request["txId"] = txID
+	}
+	if len(coinIDs) > 0 {
+		request["coins"] = coinIDs
+	}
+	if assetID != BeamAssetID {
+		request["asset_id"] = assetID
+	}
 
-	r, err := c.call("tx_send", request)
+	r, err := c.callContext(ctx, "tx_send", request)
 	if err != nil {
 		return "", err
 	}
 	return r.Get("txId").String(), nil
 }
 
-//GetBlockchainInfo
-func (c *WalletClient) GetBlockchainInfo() (*BlockchainInfo, error) {
+//GenerateTxID 预先生成交易ID，重发使用相同交易ID的交易不会重复发送
+func (c *WalletClient) GenerateTxID(ctx context.Context) (string, error) {
 
-	r, err := c.get("status")
+	r, err := c.callContext(ctx, "generate_tx_id", nil)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	chain := NewBlockchainInfo(r)
-	return chain, nil
+	return r.String(), nil
 }
 
-//GetBlockByHeight
-func (c *WalletClient) GetBlockByHeight(height uint64) (*Block, error) {
-	path := fmt.Sprintf("block?height=%d", height)
-	r, err := c.get(path)
-	if err != nil {
-		return nil, err
-	}
-	block := NewBlock(r)
-	return block, nil
+//SetBlockSources 设置区块数据来源，按顺序获取，前一个来源失败时使用下一个
+func (c *WalletClient) SetBlockSources(sources []string) {
+	c.blockSources = sources
 }
 
-//GetBlockByHash
-func (c *WalletClient) GetBlockByHash(hash string) (*Block, error) {
-	path := fmt.Sprintf("block?hash=%s", hash)
-	r, err := c.get(path)
-	if err != nil {
-		return nil, err
-	}
-	block := NewBlock(r)
-	return block, nil
+//Explorer 浏览器API
+func (c *WalletClient) Explorer() *ExplorerClient {
+	return c.explorer
 }
 
-//GetBlockByKernel
-func (c *WalletClient) GetBlockByKernel(kernel string) (*Block, error) {
-	path := fmt.Sprintf("block?kernel=%s", kernel)
-	r, err := c.get(path)
-	if err != nil {
-		return nil, err
+//GetBlockchainInfo
+func (c *WalletClient) GetBlockchainInfo(ctx context.Context) (*BlockchainInfo, error) {
+
+	var lastErr error
+	for _, source := range c.blockSources {
+		var (
+			chain *BlockchainInfo
+			err   error
+		)
+		switch source {
+		case BlockSourceWallet:
+			chain, err = c.GetWalletBlockchainInfo(ctx)
+		default:
+			chain, err = c.explorer.GetBlockchainInfo(ctx)
+		}
+		if err == nil || ctx.Err() != nil {
+			return chain, err
+		}
+		lastErr = err
 	}
-	block := NewBlock(r)
-	return block, nil
+	return nil, lastErr
 }
 
-//GetTransaction
-func (c *WalletClient) GetTransaction(txid string) (*Transaction, error) {
-	request := map[string]interface{}{
-		"txId": txid,
+//GetBlockByHeight
+func (c *WalletClient) GetBlockByHeight(ctx context.Context, height uint64) (*Block, error) {
+
+	var lastErr error
+	for _, source := range c.blockSources {
+		var (
+			block *Block
+			err   error
+		)
+		switch source {
+		case BlockSourceWallet:
+			block, err = c.GetBlockDetails(ctx, height)
+		default:
+			block, err = c.explorer.GetBlockByHeight(ctx, height)
+		}
+		if err == nil || ctx.Err() != nil {
+			return block, err
+		}
+		lastErr = err
 	}
+	return nil, lastErr
+}
 
-	r, err := c.call("tx_status", request)
-	if err != nil {
-		return nil, err
-	}
-	return NewTransaction(r), nil
+//GetBlockByHash 钱包API不能按hash查询区块，只使用浏览器API
+func (c *WalletClient) GetBlockByHash(ctx context.Context, hash string) (*Block, error) {
+	return c.explorer.GetBlockByHash(ctx, hash)
+}
+
+//GetBlockByKernel 钱包API不能按内核查询区块，只使用浏览器API
+func (c *WalletClient) GetBlockByKernel(ctx context.Context, kernel string) (*Block, error) {
+	return c.explorer.GetBlockByKernel(ctx, kernel)
 }
 
-//GetTransactionsByHeight
-func (c *WalletClient) GetTransactionsByHeight(height uint64) ([]*Transaction, error) {
+//GetBlockDetails 通过钱包API获取区块头
+func (c *WalletClient) GetBlockDetails(ctx context.Context, height uint64) (*Block, error) {
 	request := map[string]interface{}{
-		"filter": map[string]interface{}{
-			//"status": 4,
-			"height": height,
-		},
-		//"skip":  0,
-		//"count": 10,
+		"height": height,
 	}
 
-	r, err := c.call("tx_list", request)
+	r, err := c.callContext(ctx, "block_details", request)
 	if err != nil {
 		return nil, err
 	}
+	return NewBlockFromDetails(r), nil
+}
 
-	txs := make([]*Transaction, 0)
-	if r.IsArray() {
-		for _, obj := range r.Array() {
-			tx := NewTransaction(&obj)
-			txs = append(txs, tx)
-
-		}
+//GetWalletBlockchainInfo 通过钱包状态获取钱包节点同步到的最新区块
+func (c *WalletClient) GetWalletBlockchainInfo(ctx context.Context) (*BlockchainInfo, error) {
+	status, err := c.GetWalletStatus(ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	return txs, nil
+	return &BlockchainInfo{
+		Hash:   status.CurrentStateHash,
+		Height: status.CurrentHeight,
+	}, nil
 }
 
-//GetTransactionsByStatus
-func (c *WalletClient) GetTransactionsByStatus(status int) ([]*Transaction, error) {
+//GetTransaction
+func (c *WalletClient) GetTransaction(ctx context.Context, txid string) (*Transaction, error) {
 	request := map[string]interface{}{
-		"filter": map[string]interface{}{
-			"status": status,
-		},
+		"txId": txid,
 	}
 
-	r, err := c.call("tx_list", request)
+	r, err := c.callContext(ctx, "tx_status", request)
 	if err != nil {
 		return nil, err
 	}
+	return NewTransaction(r), nil
+}
 
-	txs := make([]*Transaction, 0)
-	if r.IsArray() {
-		for _, obj := range r.Array() {
-			tx := NewTransaction(&obj)
-			txs = append(txs, tx)
+//GetTransactionsByHeight 分页获取指定高度的全部交易单
+func (c *WalletClient) GetTransactionsByHeight(ctx context.Context, height uint64) ([]*Transaction, error) {
+	return c.listTransactions(ctx, HeightFilter(height))
+}
 
-		}
-	}
+//GetTransactionsByHeightPage 分页获取指定高度的交易单
+func (c *WalletClient) GetTransactionsByHeightPage(ctx context.Context, height, skip, count uint64) ([]*Transaction, error) {
+	return c.ListTransactions(ctx, HeightFilter(height), skip, count)
+}
 
-	return txs, nil
+//GetTransactionsByStatus 分页获取指定状态的全部交易单
+func (c *WalletClient) GetTransactionsByStatus(ctx context.Context, status int) ([]*Transaction, error) {
+	return c.listTransactions(ctx, StatusFilter(status))
+}
+
+//GetUtxoList 获取钱包的全部UTXO
+func (c *WalletClient) GetUtxoList(ctx context.Context) ([]*Utxo, error) {
+	return c.ListUTXO(ctx, UtxoFilter{})
 }
 
 //GetWalletStatus
-func (c *WalletClient) GetWalletStatus() (*WalletStatus, error) {
+func (c *WalletClient) GetWalletStatus(ctx context.Context) (*WalletStatus, error) {
 
-	r, err := c.call("wallet_status", nil)
+	r, err := c.callContext(ctx, "wallet_status", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -445,12 +631,12 @@ func (c *WalletClient) GetWalletStatus() (*WalletStatus, error) {
 }
 
 //CancelTx 取消交易
-func (c *WalletClient) CancelTx(txid string) (bool, error) {
+func (c *WalletClient) CancelTx(ctx context.Context, txid string) (bool, error) {
 	request := map[string]interface{}{
 		"txId": txid,
 	}
 
-	r, err := c.call("tx_cancel", request)
+	r, err := c.callContext(ctx, "tx_cancel", request)
 	if err != nil {
 		return false, err
 	}
@@ -458,16 +644,39 @@ func (c *WalletClient) CancelTx(txid string) (bool, error) {
 	return r.Bool(), nil
 }
 
-//CancelTx 取消交易
-func (c *WalletClient) ValidateAddress(address string) (bool, error) {
+//DeleteTx 从钱包历史中删除交易
+func (c *WalletClient) DeleteTx(ctx context.Context, txid string) (bool, error) {
 	request := map[string]interface{}{
-		"address": address,
+		"txId": txid,
 	}
 
-	r, err := c.call("validate_address", request)
+	r, err := c.callContext(ctx, "tx_delete", request)
 	if err != nil {
 		return false, err
 	}
 
-	return r.Get("is_valid").Bool(), nil
+	return r.Bool(), nil
+}
+
+//ValidateAddress 验证地址格式
+func (c *WalletClient) ValidateAddress(ctx context.Context, address string) (bool, error) {
+	v, err := c.GetAddressValidation(ctx, address)
+	if err != nil {
+		return false, err
+	}
+	return v.IsValid, nil
+}
+
+//GetAddressValidation 验证地址，返回地址是否有效和是否为钱包自己的地址
+func (c *WalletClient) GetAddressValidation(ctx context.Context, address string) (*AddressValidation, error) {
+	request := map[string]interface{}{
+		"address": address,
+	}
+
+	r, err := c.callContext(ctx, "validate_address", request)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAddressValidation(r), nil
 }
@@ -1,12 +1,93 @@
 package beam
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/blocktree/openwallet/log"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
+//rpcTestError 模拟钱包API返回的json-rpc错误
+type rpcTestError struct {
+	Code    int
+	Message string
+}
+
+func (e *rpcTestError) Error() string {
+	return fmt.Sprintf("[%d]%s", e.Code, e.Message)
+}
+
+//rpcTestStatus 模拟钱包API返回的HTTP错误状态码
+type rpcTestStatus int
+
+func (s rpcTestStatus) Error() string {
+	return http.StatusText(int(s))
+}
+
+//rpcTestHandler 模拟钱包API的一个方法，返回result的json；返回*rpcTestError时响应json-rpc错误，
+//返回rpcTestStatus时只响应HTTP状态码
+type rpcTestHandler func(params map[string]interface{}) (string, error)
+
+//rpcTestMethods 按方法名分发请求的模拟钱包API，未定义的方法返回-32601
+type rpcTestMethods map[string]rpcTestHandler
+
+func (m rpcTestMethods) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ID     json.RawMessage        `json:"id"`
+		Method string                 `json:"method"`
+		Params map[string]interface{} `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32700,"message":"Parse error"}}`))
+		return
+	}
+	if len(body.ID) == 0 {
+		body.ID = json.RawMessage("1")
+	}
+
+	handler, ok := m[body.Method]
+	if !ok {
+		handler = rpcFailure(-32601, "Method not found")
+	}
+	result, err := handler(body.Params)
+	switch e := err.(type) {
+	case nil:
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%s}`, body.ID, result)
+	case rpcTestStatus:
+		w.WriteHeader(int(e))
+	case *rpcTestError:
+		data, _ := json.Marshal(map[string]interface{}{"code": e.Code, "message": e.Message})
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":%s}`, body.ID, data)
+	default:
+		data, _ := json.Marshal(map[string]interface{}{"code": -32603, "message": e.Error()})
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":%s}`, body.ID, data)
+	}
+}
+
+//newRPCTestServer 启动模拟钱包API的httptest服务
+func newRPCTestServer(methods rpcTestMethods) *httptest.Server {
+	return httptest.NewServer(methods)
+}
+
+//rpcResult 固定返回result的方法
+func rpcResult(result string) rpcTestHandler {
+	return func(map[string]interface{}) (string, error) {
+		return result, nil
+	}
+}
+
+//rpcFailure 固定返回json-rpc错误的方法
+func rpcFailure(code int, message string) rpcTestHandler {
+	return func(map[string]interface{}) (string, error) {
+		return "", &rpcTestError{Code: code, Message: message}
+	}
+}
+
 func TestWalletClient_GetBlockchainInfo(t *testing.T) {
-	b, err := tw.walletClient.GetBlockchainInfo()
+	b, err := tw.walletClient.GetBlockchainInfo(context.Background())
 	if err != nil {
 		t.Errorf("GetBlockchainInfo failed unexpected error: %v\n", err)
 	} else {
@@ -15,7 +96,7 @@ func TestWalletClient_GetBlockchainInfo(t *testing.T) {
 }
 
 func TestWalletClient_CreateAddress(t *testing.T) {
-	addr, err := tw.walletClient.CreateAddress()
+	addr, err := tw.walletClient.CreateAddress(context.Background(), AddressExpirationNever, DefaultAddressComment)
 	if err != nil {
 		t.Errorf("CreateAddress failed unexpected error: %v\n", err)
 	} else {
@@ -24,7 +105,7 @@ func TestWalletClient_CreateAddress(t *testing.T) {
 }
 
 func TestWalletClient_CreateBatchAddress(t *testing.T) {
-	addrs, err := tw.walletClient.CreateBatchAddress(2000, 20)
+	addrs, err := tw.walletClient.CreateBatchAddress(context.Background(), 2000, 20)
 	if err != nil {
 		t.Errorf("CreateBatchAddress failed unexpected error: %v\n", err)
 		return
@@ -37,7 +118,7 @@ func TestWalletClient_CreateBatchAddress(t *testing.T) {
 
 func TestWalletClient_GetBlockByHeight(t *testing.T) {
 
-	block, err := tw.walletClient.GetBlockByHeight(161025)
+	block, err := tw.walletClient.GetBlockByHeight(context.Background(), 161025)
 	if err != nil {
 		t.Errorf("GetBlockByHeight failed unexpected error: %v\n", err)
 	} else {
@@ -47,7 +128,7 @@ func TestWalletClient_GetBlockByHeight(t *testing.T) {
 
 func TestWalletClient_GetBlockByHash(t *testing.T) {
 
-	block, err := tw.walletClient.GetBlockByHash("c9b4584d7a8eda016c26b4c8cb6f55775c415eaf36c460b9180df00f0cd3bbf3")
+	block, err := tw.walletClient.GetBlockByHash(context.Background(), "c9b4584d7a8eda016c26b4c8cb6f55775c415eaf36c460b9180df00f0cd3bbf3")
 	if err != nil {
 		t.Errorf("GetBlockByHash failed unexpected error: %v\n", err)
 	} else {
@@ -57,7 +138,7 @@ func TestWalletClient_GetBlockByHash(t *testing.T) {
 
 func TestWalletClient_GetBlockByKernel(t *testing.T) {
 
-	block, err := tw.walletClient.GetBlockByKernel("22abe54b476951179f58ff8da9f06332fc138e9f33f35c3f04b7ea3c71d45fd6")
+	block, err := tw.walletClient.GetBlockByKernel(context.Background(), "22abe54b476951179f58ff8da9f06332fc138e9f33f35c3f04b7ea3c71d45fd6")
 	if err != nil {
 		t.Errorf("GetBlockByKernel failed unexpected error: %v\n", err)
 	} else {
@@ -66,7 +147,7 @@ func TestWalletClient_GetBlockByKernel(t *testing.T) {
 }
 
 func TestWalletClient_GetTransaction(t *testing.T) {
-	tx, err := tw.walletClient.GetTransaction("72f8f349f9244b11b0e6471250ca68a1")
+	tx, err := tw.walletClient.GetTransaction(context.Background(), "72f8f349f9244b11b0e6471250ca68a1")
 	if err != nil {
 		t.Errorf("GetTransaction failed unexpected error: %v\n", err)
 	} else {
@@ -75,7 +156,7 @@ func TestWalletClient_GetTransaction(t *testing.T) {
 }
 
 func TestWalletClient_GetTransactionsByHeight(t *testing.T) {
-	txs, err := tw.walletClient.GetTransactionsByHeight(237304)
+	txs, err := tw.walletClient.GetTransactionsByHeight(context.Background(), 237304)
 	if err != nil {
 		t.Errorf("GetTransactionsByHeight failed unexpected error: %v\n", err)
 		return
@@ -87,7 +168,7 @@ func TestWalletClient_GetTransactionsByHeight(t *testing.T) {
 }
 
 func TestWalletClient_GetAddressList(t *testing.T) {
-	addrs, err := tw.walletClient.GetAddressList()
+	addrs, err := tw.walletClient.GetAddressList(context.Background())
 	if err != nil {
 		t.Errorf("GetAddressList failed unexpected error: %v\n", err)
 		return
@@ -99,7 +180,7 @@ func TestWalletClient_GetAddressList(t *testing.T) {
 }
 
 func TestWalletClient_GetWalletStatus(t *testing.T) {
-	wallet, err := tw.walletClient.GetWalletStatus()
+	wallet, err := tw.walletClient.GetWalletStatus(context.Background())
 	if err != nil {
 		t.Errorf("GetWalletStatus failed unexpected error: %v\n", err)
 		return
@@ -113,7 +194,7 @@ func TestWalletClient_SendTransaction(t *testing.T) {
 	to := "19179fae58832b5a59129cd866905646d7547d1dddd1f97c3663affb924a01fa65c"
 	amount := uint64(45738)
 	fee := uint64(1)
-	txid, err := tw.walletClient.SendTransaction(from, to, amount, fee, "")
+	txid, err := tw.walletClient.SendTransaction(context.Background(), from, to, amount, fee, "", "")
 	if err != nil {
 		t.Errorf("GetWalletStatus failed unexpected error: %v\n", err)
 		return
@@ -123,7 +204,7 @@ func TestWalletClient_SendTransaction(t *testing.T) {
 }
 
 func TestWalletClient_GetTransactionsByStatus(t *testing.T) {
-	txs, err := tw.walletClient.GetTransactionsByStatus(TxStatusInProgress)
+	txs, err := tw.walletClient.GetTransactionsByStatus(context.Background(), TxStatusInProgress)
 	if err != nil {
 		t.Errorf("GetTransactionsByStatus failed unexpected error: %v\n", err)
 		return
@@ -136,7 +217,7 @@ func TestWalletClient_GetTransactionsByStatus(t *testing.T) {
 }
 
 func TestWalletClient_CancelTx(t *testing.T) {
-	flag, err := tw.walletClient.CancelTx("46bf4426eb8142f58898ba9ccf9b351b")
+	flag, err := tw.walletClient.CancelTx(context.Background(), "46bf4426eb8142f58898ba9ccf9b351b")
 	if err != nil {
 		t.Errorf("CancelTx failed unexpected error: %v\n", err)
 		return
@@ -147,7 +228,7 @@ func TestWalletClient_CancelTx(t *testing.T) {
 }
 
 func TestValidateAddress(t *testing.T) {
-	isVaild, err := tw.walletClient.ValidateAddress("46bf4426eb8142f58898ba9ccf9b351b")
+	isVaild, err := tw.walletClient.ValidateAddress(context.Background(), "46bf4426eb8142f58898ba9ccf9b351b")
 	if err != nil {
 		t.Errorf("vaild address failed unexpected error: %v\n", err)
 		return
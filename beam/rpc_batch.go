@@ -0,0 +1,170 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blocktree/openwallet/log"
+	"github.com/imroc/req"
+	"github.com/tidwall/gjson"
+)
+
+//RPCRequest 批量请求中的一个钱包API请求
+type RPCRequest struct {
+	Method string
+	Params interface{}
+}
+
+//RPCResult 批量请求中一个请求的结果
+type RPCResult struct {
+	Result *gjson.Result
+	Err    error
+}
+
+//CallBatch 一次往返调用多个钱包API只读方法，结果顺序与请求一致，
+//钱包API不支持批量请求时改为逐个调用
+func (c *WalletClient) CallBatch(ctx context.Context, requests []RPCRequest) (results []RPCResult, err error) {
+
+	if len(requests) == 0 {
+		return nil, nil
+	}
+
+	for _, request := range requests {
+		if walletAPIWriteMethods[request.Method] {
+			return nil, fmt.Errorf("method %s can not be called in batch", request.Method)
+		}
+	}
+
+	//websocket连接上的请求本身是复用的，并发发送即可
+	if c.ws != nil {
+		return c.callConcurrent(ctx, requests), nil
+	}
+
+	if atomic.LoadInt32(&c.batchUnsupported) == 1 {
+		return c.callEach(ctx, requests), nil
+	}
+
+	body := make([]map[string]interface{}, 0, len(requests))
+	for i, request := range requests {
+		body = append(body, newRPCBody(i+1, request.Method, c.adaptRequest(request.Method, request.Params)))
+	}
+
+	defer func(start time.Time) {
+		c.opts.observe(rpcAPIWallet, "batch", start, err)
+	}(time.Now())
+
+	if err = c.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	var r *req.Resp
+	err = c.opts.retry(ctx, true, func() (err error) {
+		r, err = c.postEndpoints(ctx, requests[0].Method, body)
+		return err
+	})
+	c.breaker.record(err)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := gjson.ParseBytes(r.Bytes())
+	if !resp.IsArray() {
+		err = isError(r)
+		if err == nil {
+			err = fmt.Errorf("batch response is not an array")
+		}
+		log.Std.Warn("wallet api does not support batch request, call one by one, unexpected error: %v", err)
+		atomic.StoreInt32(&c.batchUnsupported, 1)
+		return c.callEach(ctx, requests), nil
+	}
+
+	results = make([]RPCResult, len(requests))
+	for i := range results {
+		results[i].Err = fmt.Errorf("no response for request: %s", requests[i].Method)
+	}
+	for _, item := range resp.Array() {
+		id := int(item.Get("id").Int())
+		if id < 1 || id > len(requests) {
+			continue
+		}
+		if item.Get("error").IsObject() {
+			results[id-1] = RPCResult{Err: fmt.Errorf("[%d]%s",
+				item.Get("error.code").Int(),
+				item.Get("error.message").String())}
+			continue
+		}
+		result := item.Get("result")
+		results[id-1] = RPCResult{Result: &result}
+	}
+
+	return results, nil
+}
+
+//callEach 逐个调用
+func (c *WalletClient) callEach(ctx context.Context, requests []RPCRequest) []RPCResult {
+	results := make([]RPCResult, 0, len(requests))
+	for _, request := range requests {
+		result, err := c.callContext(ctx, request.Method, request.Params)
+		results = append(results, RPCResult{Result: result, Err: err})
+	}
+	return results
+}
+
+//callConcurrent 并发调用，结果顺序与请求一致
+func (c *WalletClient) callConcurrent(ctx context.Context, requests []RPCRequest) []RPCResult {
+	results := make([]RPCResult, len(requests))
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		wg.Add(1)
+		go func(i int, request RPCRequest) {
+			defer wg.Done()
+			result, err := c.callContext(ctx, request.Method, request.Params)
+			results[i] = RPCResult{Result: result, Err: err}
+		}(i, request)
+	}
+	wg.Wait()
+	return results
+}
+
+//GetTransactionsByHeights 一次批量请求获取多个高度的交易单
+func (c *WalletClient) GetTransactionsByHeights(ctx context.Context, heights []uint64) (map[uint64][]*Transaction, error) {
+
+	requests := make([]RPCRequest, 0, len(heights))
+	for _, height := range heights {
+		requests = append(requests, RPCRequest{
+			Method: "tx_list",
+			Params: HeightFilter(height).params(0, c.txPageSize),
+		})
+	}
+
+	results, err := c.CallBatch(ctx, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	txMap := make(map[uint64][]*Transaction, len(heights))
+	for i, result := range results {
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		txs := make([]*Transaction, 0)
+		for _, obj := range result.Result.Array() {
+			txs = append(txs, NewTransaction(&obj))
+		}
+		//第一页已满，继续分页拉取剩余的交易单
+		if uint64(len(txs)) >= c.txPageSize {
+			it := c.IterateTransactions(HeightFilter(heights[i]), 0)
+			it.skip = uint64(len(txs))
+			txs, err = it.collect(ctx, txs)
+			if err != nil {
+				return nil, err
+			}
+		}
+		txMap[heights[i]] = txs
+	}
+
+	return txMap, nil
+}
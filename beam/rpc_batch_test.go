@@ -0,0 +1,110 @@
+package beam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWalletClient_CallBatch(t *testing.T) {
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		data, _ := ioutil.ReadAll(r.Body)
+		var body []map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("batch body is not an array: %s", data)
+			return
+		}
+		//倒序返回，第二个请求返回错误
+		w.Write([]byte(`[
+			{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"Method not found"}},
+			{"jsonrpc":"2.0","id":1,"result":[{"txId":"a","height":10}]}
+		]`))
+	}))
+	defer server.Close()
+
+	c := NewWalletClient(server.URL, server.URL, false)
+	results, err := c.CallBatch(context.Background(), []RPCRequest{
+		{Method: "tx_list"},
+		{Method: "unknown"},
+	})
+	if err != nil || len(results) != 2 || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("CallBatch results = %d, calls = %d, err = %v", len(results), calls, err)
+	}
+	if results[0].Err != nil || results[0].Result.Array()[0].Get("txId").String() != "a" {
+		t.Errorf("CallBatch result[0] = %v, err = %v", results[0].Result, results[0].Err)
+	}
+	if results[1].Err == nil {
+		t.Errorf("CallBatch result[1] should be error")
+	}
+
+	//写方法不能批量调用
+	_, err = c.CallBatch(context.Background(), []RPCRequest{{Method: "tx_send"}})
+	if err == nil {
+		t.Errorf("CallBatch tx_send should be error")
+	}
+}
+
+func TestWalletClient_CallBatchUnsupported(t *testing.T) {
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		data, _ := ioutil.ReadAll(r.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			//不支持批量请求
+			w.Write([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid request"}}`))
+			return
+		}
+		height := body["params"].(map[string]interface{})["filter"].(map[string]interface{})["height"]
+		w.Write([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":[{"txId":"tx%v","height":%v}]}`, height, height)))
+	}))
+	defer server.Close()
+
+	c := NewWalletClient(server.URL, server.URL, false)
+	txMap, err := c.GetTransactionsByHeights(context.Background(), []uint64{10, 11})
+	if err != nil || len(txMap) != 2 {
+		t.Fatalf("GetTransactionsByHeights = %v, err = %v", txMap, err)
+	}
+	if len(txMap[11]) != 1 || txMap[11][0].TxID != "tx11" {
+		t.Errorf("GetTransactionsByHeights[11] = %v", txMap[11])
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("calls = %d, want 3", n)
+	}
+
+	//确认不支持后直接逐个请求
+	atomic.StoreInt32(&calls, 0)
+	c.GetTransactionsByHeights(context.Background(), []uint64{12})
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("calls = %d, want 1", n)
+	}
+}
+
+func TestTxPrefetch(t *testing.T) {
+
+	p := newTxPrefetch()
+	p.put(map[uint64][]*Transaction{10: {{TxID: "a"}}})
+	if !p.has(10) {
+		t.Errorf("prefetch should have height 10")
+	}
+	if txs, ok := p.take(10); !ok || len(txs) != 1 {
+		t.Errorf("take(10) = %v, %v", txs, ok)
+	}
+	if _, ok := p.take(10); ok {
+		t.Errorf("take(10) twice should miss")
+	}
+	p.put(map[uint64][]*Transaction{11: nil})
+	p.reset()
+	if p.has(11) {
+		t.Errorf("prefetch should be empty after reset")
+	}
+}
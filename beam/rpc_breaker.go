@@ -0,0 +1,145 @@
+package beam
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/blocktree/openwallet/log"
+)
+
+//熔断器状态
+const (
+	circuitClosed   = 0 //正常请求
+	circuitOpen     = 1 //熔断，直接拒绝请求
+	circuitHalfOpen = 2 //冷却结束，只放行一个探测请求
+)
+
+//ErrCircuitOpen 钱包API连续失败后熔断，冷却期内不再发送请求
+var ErrCircuitOpen = errors.New("wallet api circuit is open")
+
+//circuitBreaker 钱包API连续失败threshold次后熔断，冷却cooldown后放行一个请求探测是否恢复，nil表示不熔断
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     int
+	openedAt  time.Time
+	probing   bool
+}
+
+//newCircuitBreaker threshold不大于0时不熔断
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+//allow 是否可以发送请求，冷却结束后只放行一个探测请求
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		return nil
+	case circuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+//record 记录请求结果，只有连接失败、超时和5xx算作节点不可用
+func (b *circuitBreaker) record(err error) {
+	if b == nil || err == ErrCircuitOpen {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	//调用方取消的请求不能说明节点是否可用
+	if ue, ok := err.(*url.Error); err == context.Canceled || ok && ue.Err == context.Canceled {
+		b.probing = false
+		return
+	}
+
+	if err == nil || !isRetryableError(err) {
+		if b.state != circuitClosed {
+			log.Std.Info("wallet api recovered, circuit closed")
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		if b.state == circuitClosed {
+			log.Std.Warn("wallet api failed %d times, circuit open for %v, unexpected error: %v", b.failures, b.cooldown, err)
+		}
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+		b.probing = false
+	}
+}
+
+//isOpen 是否熔断中，冷却结束等待探测时仍算熔断
+func (b *circuitBreaker) isOpen() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state != circuitClosed
+}
+
+//SetCircuitBreaker 设置熔断，连续失败threshold次后熔断cooldown，threshold不大于0时不熔断
+func (c *WalletClient) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	c.breaker = newCircuitBreaker(threshold, cooldown)
+}
+
+//CircuitOpen 钱包API是否熔断中
+func (c *WalletClient) CircuitOpen() bool {
+	return c.breaker.isOpen()
+}
+
+//ProbeCircuit 熔断中时查询钱包状态探测是否恢复，恢复或未熔断时返回nil
+func (c *WalletClient) ProbeCircuit(ctx context.Context) error {
+	if !c.CircuitOpen() {
+		return nil
+	}
+	_, err := c.GetWalletStatus(ctx)
+	return err
+}
+
+//circuitOpen 钱包API是否熔断中，其他钱包API实现不熔断
+func (wm *WalletManager) circuitOpen() bool {
+	c, ok := wm.httpWalletClient()
+	return ok && c.CircuitOpen()
+}
+
+//probeCircuit 熔断中时探测钱包API是否恢复
+func (wm *WalletManager) probeCircuit(ctx context.Context) error {
+	c, ok := wm.httpWalletClient()
+	if !ok {
+		return nil
+	}
+	return c.ProbeCircuit(ctx)
+}
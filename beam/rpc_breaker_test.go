@@ -0,0 +1,88 @@
+package beam
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+
+	b := newCircuitBreaker(2, 20*time.Millisecond)
+	failure := &rpcStatusError{code: 502, status: "502 Bad Gateway"}
+
+	b.record(failure)
+	if b.isOpen() || b.allow() != nil {
+		t.Errorf("circuit should be closed after 1 failure")
+	}
+
+	//RPC错误说明节点可用，重新计数
+	b.record(&rpcStatusError{code: 404, status: "404 Not Found"})
+	b.record(failure)
+	if b.isOpen() {
+		t.Errorf("circuit should be closed after non-retryable error")
+	}
+
+	b.record(failure)
+	if !b.isOpen() || b.allow() != ErrCircuitOpen {
+		t.Errorf("circuit should be open after 2 failures")
+	}
+
+	//冷却结束后只放行一个探测请求
+	time.Sleep(30 * time.Millisecond)
+	if b.allow() != nil || b.allow() != ErrCircuitOpen {
+		t.Errorf("circuit should allow only one probe after cooldown")
+	}
+	b.record(failure)
+	if b.allow() != ErrCircuitOpen {
+		t.Errorf("circuit should reopen after probe failed")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	b.allow()
+	b.record(nil)
+	if b.isOpen() || b.allow() != nil {
+		t.Errorf("circuit should be closed after probe succeeded")
+	}
+}
+
+func TestWalletClient_CircuitBreaker(t *testing.T) {
+
+	var (
+		calls int32
+		down  int32 = 1
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}))
+	defer server.Close()
+
+	c := NewWalletClient(server.URL, server.URL, false)
+	c.SetRetryPolicy(RetryPolicy{})
+	c.SetCircuitBreaker(3, 20*time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		c.GetWalletStatus(context.Background())
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 || !c.CircuitOpen() {
+		t.Errorf("calls = %d, circuit open = %v, want 3 calls and open", n, c.CircuitOpen())
+	}
+
+	if err := c.ProbeCircuit(context.Background()); err != ErrCircuitOpen {
+		t.Errorf("ProbeCircuit during cooldown = %v, want ErrCircuitOpen", err)
+	}
+
+	atomic.StoreInt32(&down, 0)
+	time.Sleep(30 * time.Millisecond)
+	if err := c.ProbeCircuit(context.Background()); err != nil || c.CircuitOpen() {
+		t.Errorf("ProbeCircuit after recovery = %v, circuit open = %v", err, c.CircuitOpen())
+	}
+}
@@ -0,0 +1,66 @@
+package beam
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	//请求的API，监控指标的api标签
+	rpcAPIWallet   = "wallet"
+	rpcAPIExplorer = "explorer"
+)
+
+//errorClass 请求错误的分类，区分是节点问题还是请求问题
+func errorClass(err error) string {
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err
+	}
+	switch e := err.(type) {
+	case *rpcStatusError:
+		if e.code >= 500 {
+			return "http_5xx"
+		}
+		return "http_4xx"
+	case net.Error:
+		if e.Timeout() {
+			return "timeout"
+		}
+		return "connection"
+	}
+	switch {
+	case err == ErrCircuitOpen:
+		return "circuit_open"
+	case err == context.DeadlineExceeded:
+		return "timeout"
+	case err == context.Canceled:
+		return "canceled"
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return "connection"
+	case strings.HasPrefix(err.Error(), "["):
+		//钱包API返回的json-rpc错误
+		return "rpc"
+	}
+	return "other"
+}
+
+//SetMetrics 设置监控指标，钱包API和浏览器API共用
+func (c *WalletClient) SetMetrics(m *Metrics) {
+	c.opts.metrics = m
+}
+
+//observe 记录一次调用的次数、错误和耗时，包含重试
+func (o *rpcOptions) observe(api, method string, start time.Time, err error) {
+	if o.metrics == nil {
+		return
+	}
+	o.metrics.RPCRequests.WithLabelValues(api, method).Inc()
+	o.metrics.RPCDuration.WithLabelValues(api, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		o.metrics.RPCErrors.WithLabelValues(api, method, errorClass(err)).Inc()
+	}
+}
@@ -0,0 +1,63 @@
+package beam
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&rpcStatusError{code: 502}, "http_5xx"},
+		{&rpcStatusError{code: 404}, "http_4xx"},
+		{&url.Error{Op: "Post", Err: context.DeadlineExceeded}, "timeout"},
+		{&url.Error{Op: "Post", Err: context.Canceled}, "canceled"},
+		{ErrCircuitOpen, "circuit_open"},
+		{errors.New("[-32601]Method not found"), "rpc"},
+		{errors.New("unknown"), "other"},
+	}
+	for _, tt := range tests {
+		if got := errorClass(tt.err); got != tt.want {
+			t.Errorf("errorClass(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWalletClient_Metrics(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/status" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Method not found"}}`))
+	}))
+	defer server.Close()
+
+	m := NewMetrics()
+	c := NewWalletClient(server.URL, server.URL, false)
+	c.SetRetryPolicy(RetryPolicy{})
+	c.SetMetrics(m)
+
+	c.GetWalletStatus(context.Background())
+	c.GetWalletStatus(context.Background())
+	c.Explorer().GetBlockchainInfo(context.Background())
+
+	if n := testutil.ToFloat64(m.RPCRequests.WithLabelValues(rpcAPIWallet, "wallet_status")); n != 2 {
+		t.Errorf("wallet_status requests = %v, want 2", n)
+	}
+	if n := testutil.ToFloat64(m.RPCErrors.WithLabelValues(rpcAPIWallet, "wallet_status", "rpc")); n != 2 {
+		t.Errorf("wallet_status rpc errors = %v, want 2", n)
+	}
+	if n := testutil.ToFloat64(m.RPCErrors.WithLabelValues(rpcAPIExplorer, "status", "http_5xx")); n != 1 {
+		t.Errorf("explorer status 5xx errors = %v, want 1", n)
+	}
+}
@@ -0,0 +1,86 @@
+package beam
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//rateLimiter 令牌桶限流，限制发往钱包API的请求速率，nil表示不限流
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 //每秒生成的令牌数
+	burst  float64 //令牌桶容量
+	tokens float64
+	last   time.Time
+}
+
+//newRateLimiter 每秒rps个请求，最多突发burst个，rps不大于0时不限流
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+//reserve 预约一个令牌，返回需要等待的时间
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+//cancel 归还未使用的令牌
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	l.tokens++
+	l.mu.Unlock()
+}
+
+//Wait 等待可以发送请求，ctx取消时返回错误
+func (l *rateLimiter) Wait(ctx context.Context) error {
+
+	if l == nil {
+		return nil
+	}
+
+	wait := l.reserve()
+	if wait == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
+
+//SetRateLimit 设置钱包API请求限流，每秒rps个请求，最多突发burst个，rps不大于0时不限流
+func (c *WalletClient) SetRateLimit(rps float64, burst int) {
+	c.limiter = newRateLimiter(rps, burst)
+}
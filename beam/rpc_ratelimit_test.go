@@ -0,0 +1,56 @@
+package beam
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Wait(t *testing.T) {
+
+	var l *rateLimiter
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("nil limiter Wait unexpected error: %v", err)
+	}
+
+	l = newRateLimiter(100, 2)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		l.Wait(context.Background())
+	}
+	//突发2个，之后每10ms一个
+	if d := time.Since(start); d < 15*time.Millisecond || d > 200*time.Millisecond {
+		t.Errorf("4 requests took %v, want about 20ms", d)
+	}
+
+	l = newRateLimiter(1, 1)
+	l.Wait(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err == nil {
+		t.Errorf("Wait should fail when ctx is done")
+	}
+}
+
+func TestWalletClient_RateLimit(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}))
+	defer server.Close()
+
+	c := NewWalletClient(server.URL, server.URL, false)
+	c.SetRateLimit(50, 1)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetWalletStatus(context.Background()); err != nil {
+			t.Fatalf("GetWalletStatus unexpected error: %v", err)
+		}
+	}
+	if d := time.Since(start); d < 35*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 40ms", d)
+	}
+}
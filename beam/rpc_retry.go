@@ -0,0 +1,105 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/blocktree/openwallet/log"
+)
+
+//RetryPolicy 只读请求遇到临时错误时的重试策略，等待时间按指数增长并加入随机抖动
+type RetryPolicy struct {
+	Count    int           //最大重试次数，0表示不重试
+	Delay    time.Duration //第一次重试的等待时间，每次重试翻倍
+	MaxDelay time.Duration //最长等待时间
+	Jitter   float64       //随机抖动比例，0.2表示等待时间上下浮动20%
+}
+
+//defaultRetryPolicy 默认重试策略
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Count:    DefaultRPCRetryCount,
+		Delay:    DefaultRPCRetryDelay,
+		MaxDelay: DefaultRPCRetryMaxDelay,
+		Jitter:   DefaultRPCRetryJitter,
+	}
+}
+
+//backoff 第attempt次重试的等待时间，attempt从0开始
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.Delay
+	for i := 0; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration(float64(delay) * p.Jitter * (rand.Float64()*2 - 1))
+	}
+	return delay
+}
+
+//rpcStatusError API返回的HTTP状态码错误
+type rpcStatusError struct {
+	code   int
+	status string
+}
+
+func (e *rpcStatusError) Error() string {
+	return fmt.Sprintf("[%d]%s", e.code, e.status)
+}
+
+//isRetryableError 连接断开、超时和服务端5xx错误可以重试，钱包API返回的业务错误不重试
+func isRetryableError(err error) bool {
+	switch e := err.(type) {
+	case *rpcStatusError:
+		return e.code >= 500
+	case *wsConnError:
+		return true
+	case *url.Error:
+		//调用方取消时不会再重试，这里的超时是单次请求的方法超时
+		return e.Err != context.Canceled
+	case net.Error:
+		return true
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF || err == context.DeadlineExceeded
+}
+
+//SetRetryPolicy 设置只读请求的重试策略
+func (c *WalletClient) SetRetryPolicy(policy RetryPolicy) {
+	c.opts.retryPolicy = policy
+}
+
+//retry 执行请求，readOnly的请求遇到可重试的错误时按重试策略重新执行
+func (o *rpcOptions) retry(ctx context.Context, readOnly bool, request func() error) error {
+
+	err := request()
+	if !readOnly {
+		return err
+	}
+
+	for attempt := 0; attempt < o.retryPolicy.Count && err != nil && isRetryableError(err); attempt++ {
+		if ctx.Err() != nil {
+			return err
+		}
+
+		delay := o.retryPolicy.backoff(attempt)
+		log.Std.Warn("request failed, retry %d after %v, unexpected error: %v", attempt+1, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		err = request()
+	}
+
+	return err
+}
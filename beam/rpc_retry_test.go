@@ -0,0 +1,83 @@
+package beam
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+
+	p := RetryPolicy{Delay: 100 * time.Millisecond, MaxDelay: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if d := p.backoff(i); d != w {
+			t.Errorf("backoff(%d) = %v, want %v", i, d, w)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		if d := p.backoff(0); d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Errorf("backoff with jitter = %v, out of range", d)
+		}
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&rpcStatusError{code: 502, status: "502 Bad Gateway"}, true},
+		{&rpcStatusError{code: 404, status: "404 Not Found"}, false},
+		{io.EOF, true},
+		{errors.New("[-32600]Invalid request"), false},
+	}
+	for _, tt := range tests {
+		if got := isRetryableError(tt.err); got != tt.want {
+			t.Errorf("isRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWalletClient_Retry(t *testing.T) {
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//前两次请求返回502
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"current_height":100,"txId":"a"}}`))
+	}))
+	defer server.Close()
+
+	c := NewWalletClient(server.URL, server.URL, false)
+	c.SetRetryPolicy(RetryPolicy{Count: 3, Delay: time.Millisecond})
+
+	_, err := c.GetWalletStatus(context.Background())
+	if err != nil || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("GetWalletStatus calls = %d, err = %v, want 3 calls", calls, err)
+	}
+
+	//非幂等方法不重试
+	atomic.StoreInt32(&calls, 0)
+	_, err = c.SendTransaction(context.Background(), "a", "b", 1, 1, "", "")
+	if err == nil || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("SendTransaction calls = %d, err = %v, want 1 call and error", calls, err)
+	}
+
+	//浏览器API
+	atomic.StoreInt32(&calls, 0)
+	_, err = c.GetBlockchainInfo(context.Background())
+	if err != nil || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("GetBlockchainInfo calls = %d, err = %v, want 3 calls", calls, err)
+	}
+}
@@ -0,0 +1,56 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//SetTimeouts 设置请求超时，0表示不超时，
+//methodTimeouts按钱包API方法名（如tx_list）或浏览器API路径（如block）单独配置
+func (c *WalletClient) SetTimeouts(timeout time.Duration, methodTimeouts map[string]time.Duration) {
+	c.opts.timeout = timeout
+	c.opts.methodTimeouts = methodTimeouts
+}
+
+//withTimeout 为一次请求设置方法的超时，节点无响应时请求不会一直阻塞
+func (o *rpcOptions) withTimeout(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	timeout := o.timeout
+	if t, ok := o.methodTimeouts[method]; ok {
+		timeout = t
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+//explorerMethod 浏览器API路径对应的方法名，去掉查询参数
+func explorerMethod(path string) string {
+	if i := strings.Index(path, "?"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+//parseMethodTimeouts 解析方法超时配置，格式：tx_list:60s,block:10s
+func parseMethodTimeouts(s string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		kv := strings.SplitN(item, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid method timeout: %s", item)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid method timeout: %s, %v", item, err)
+		}
+		timeouts[strings.TrimSpace(kv[0])] = timeout
+	}
+	return timeouts, nil
+}
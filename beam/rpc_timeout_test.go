@@ -0,0 +1,64 @@
+package beam
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseMethodTimeouts(t *testing.T) {
+
+	timeouts, err := parseMethodTimeouts("tx_list:60s, block:10s,")
+	if err != nil {
+		t.Errorf("parseMethodTimeouts failed unexpected error: %v", err)
+		return
+	}
+	if timeouts["tx_list"] != 60*time.Second || timeouts["block"] != 10*time.Second {
+		t.Errorf("unexpected timeouts: %v", timeouts)
+	}
+
+	if _, err := parseMethodTimeouts("tx_list"); err == nil {
+		t.Errorf("parseMethodTimeouts should fail without timeout")
+	}
+}
+
+func TestWalletClient_MethodTimeout(t *testing.T) {
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//模拟无响应的节点
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := NewWalletClient(server.URL, server.URL, false)
+	c.SetRetryPolicy(RetryPolicy{})
+	c.SetTimeouts(time.Minute, map[string]time.Duration{
+		"wallet_status": 50 * time.Millisecond,
+		"status":        50 * time.Millisecond,
+	})
+
+	start := time.Now()
+	if _, err := c.GetWalletStatus(context.Background()); err == nil {
+		t.Errorf("GetWalletStatus should be timeout")
+	}
+	if _, err := c.GetBlockchainInfo(context.Background()); err == nil {
+		t.Errorf("GetBlockchainInfo should be timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("method timeout is not applied, elapsed: %v", elapsed)
+	}
+
+	//调用方取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetBlockByHeight(ctx, 1); err == nil {
+		t.Errorf("GetBlockByHeight should be canceled")
+	}
+}
@@ -0,0 +1,117 @@
+package beam
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/blocktree/openwallet/log"
+	"github.com/imroc/req"
+)
+
+const (
+	//API Key默认的请求头
+	DefaultWalletAPIKeyHeader = "X-API-Key"
+)
+
+//WalletAPIAuth 钱包API的认证信息，通过不可信网络访问远程钱包API时使用
+type WalletAPIAuth struct {
+	User         string //Basic Auth用户名
+	Password     string //Basic Auth密码
+	APIKey       string //API Key
+	APIKeyHeader string //API Key的请求头，默认X-API-Key
+}
+
+//NewTLSConfig 创建TLS配置
+//caFile: 自定义CA证书，为空时使用系统CA
+//certFile，keyFile: 客户端证书和私钥，双向认证时配置
+//insecure: 不校验服务端证书，只用于测试
+func NewTLSConfig(caFile, certFile, keyFile string, insecure bool) (*tls.Config, error) {
+
+	cfg := &tls.Config{InsecureSkipVerify: insecure}
+
+	if len(caFile) > 0 {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA file: %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(certFile) > 0 || len(keyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+//SetTLSConfig 设置https请求的TLS配置，钱包API和浏览器API共用
+func (c *WalletClient) SetTLSConfig(cfg *tls.Config) {
+	trans, ok := c.client.Client().Transport.(*http.Transport)
+	if !ok {
+		return
+	}
+	trans.TLSClientConfig = cfg
+}
+
+//SetAuth 设置钱包API的认证信息，浏览器API是公开数据不需要认证
+func (c *WalletClient) SetAuth(auth WalletAPIAuth) {
+	if len(auth.APIKeyHeader) == 0 {
+		auth.APIKeyHeader = DefaultWalletAPIKeyHeader
+	}
+	c.auth.Store(auth)
+}
+
+//SetAuthSource 设置认证信息的来源，钱包API返回401时重新读取，认证信息有变化时重试一次
+func (c *WalletClient) SetAuthSource(source func() (WalletAPIAuth, error)) {
+	c.authSource = source
+}
+
+//currentAuth 当前的认证信息
+func (c *WalletClient) currentAuth() WalletAPIAuth {
+	auth, _ := c.auth.Load().(WalletAPIAuth)
+	return auth
+}
+
+//reloadAuth 从来源重新读取认证信息，返回是否有变化
+func (c *WalletClient) reloadAuth() (bool, error) {
+	if c.authSource == nil {
+		return false, nil
+	}
+	auth, err := c.authSource()
+	if err != nil {
+		return false, err
+	}
+	if len(auth.APIKeyHeader) == 0 {
+		auth.APIKeyHeader = DefaultWalletAPIKeyHeader
+	}
+	if auth == c.currentAuth() {
+		return false, nil
+	}
+	c.SetAuth(auth)
+	log.Std.Info("wallet api auth has been reloaded")
+	return true, nil
+}
+
+//authHeader 钱包API请求的认证请求头
+func (c *WalletClient) authHeader(header req.Header) req.Header {
+	auth := c.currentAuth()
+	if len(auth.User) > 0 {
+		header["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(auth.User+":"+auth.Password))
+	}
+	if len(auth.APIKey) > 0 {
+		header[auth.APIKeyHeader] = auth.APIKey
+	}
+	return header
+}
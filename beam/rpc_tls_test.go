@@ -0,0 +1,57 @@
+package beam
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalletClient_TLSAndAuth(t *testing.T) {
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "beam" || password != "secret" || r.Header.Get("X-Token") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"current_height":100}}`))
+	}))
+	defer server.Close()
+
+	c := NewWalletClient(server.URL, "", false)
+	c.SetRetryPolicy(RetryPolicy{})
+
+	//未信任服务端证书
+	if _, err := c.GetWalletStatus(context.Background()); err == nil {
+		t.Errorf("GetWalletStatus should fail with unknown certificate")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, data, 0600); err != nil {
+		t.Errorf("write CA file failed unexpected error: %v", err)
+		return
+	}
+
+	tlsConfig, err := NewTLSConfig(caFile, "", "", false)
+	if err != nil {
+		t.Errorf("NewTLSConfig failed unexpected error: %v", err)
+		return
+	}
+	c.SetTLSConfig(tlsConfig)
+
+	//缺少认证信息
+	if _, err := c.GetWalletStatus(context.Background()); err == nil {
+		t.Errorf("GetWalletStatus should fail without auth")
+	}
+
+	c.SetAuth(WalletAPIAuth{User: "beam", Password: "secret", APIKey: "key", APIKeyHeader: "X-Token"})
+	status, err := c.GetWalletStatus(context.Background())
+	if err != nil || status.CurrentHeight != 100 {
+		t.Errorf("GetWalletStatus = %+v, %v", status, err)
+	}
+}
@@ -0,0 +1,133 @@
+package beam
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blocktree/openwallet/log"
+)
+
+//traceRedactKeys 跟踪记录中需要隐藏的字段
+var traceRedactKeys = map[string]bool{
+	"password": true,
+	"pass":     true,
+	"secret":   true,
+	"seed":     true,
+	"token":    true,
+	"key":      true,
+	"apikey":   true,
+	"api_key":  true,
+}
+
+//rpcTracer 记录钱包API和浏览器API的请求和响应，dir为空时写日志，否则每次请求写一个文件
+type rpcTracer struct {
+	dir string
+}
+
+//rpcTrace 一次请求的跟踪记录，id关联请求和响应
+type rpcTrace struct {
+	ID       string          `json:"id"`
+	API      string          `json:"api"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Time     string          `json:"time"`
+	Duration string          `json:"duration"`
+	Status   int             `json:"status,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+//newTraceID 生成关联ID
+func newTraceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//sanitizeJSON 隐藏敏感字段，不是json时原样记录为字符串
+func sanitizeJSON(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		raw, _ := json.Marshal(string(data))
+		return raw
+	}
+	raw, _ := json.Marshal(redact(v))
+	return raw
+}
+
+func redact(v interface{}) interface{} {
+	switch obj := v.(type) {
+	case map[string]interface{}:
+		for k, item := range obj {
+			if traceRedactKeys[strings.ToLower(k)] {
+				obj[k] = "***"
+				continue
+			}
+			obj[k] = redact(item)
+		}
+	case []interface{}:
+		for i, item := range obj {
+			obj[i] = redact(item)
+		}
+	}
+	return v
+}
+
+//trace 记录一次请求，request和response为原始的json数据
+func (t *rpcTracer) trace(id, api, method, url string, start time.Time, request, response []byte, status int, err error) {
+	if t == nil {
+		return
+	}
+
+	record := rpcTrace{
+		ID:       id,
+		API:      api,
+		Method:   method,
+		URL:      url,
+		Time:     start.Format(time.RFC3339Nano),
+		Duration: time.Since(start).String(),
+		Status:   status,
+		Request:  sanitizeJSON(request),
+		Response: sanitizeJSON(response),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	data, _ := json.Marshal(&record)
+	if len(t.dir) == 0 {
+		log.Std.Info("rpc trace: %s", data)
+		return
+	}
+
+	file := filepath.Join(t.dir, fmt.Sprintf("%s_%s_%s.json", start.Format("20060102T150405.000"), id, strings.Replace(method, "/", "_", -1)))
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		log.Std.Warn("rpc trace write file: %s failed, unexpected error: %v", file, err)
+	}
+}
+
+//SetTrace 开启请求跟踪，dir为空时写日志，否则每次请求写一个json文件到dir
+func (c *WalletClient) SetTrace(enable bool, dir string) error {
+	if !enable {
+		c.opts.tracer = nil
+		return nil
+	}
+	if len(dir) > 0 {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	c.opts.tracer = &rpcTracer{dir: dir}
+	return nil
+}
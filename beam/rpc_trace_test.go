@@ -0,0 +1,53 @@
+package beam
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeJSON(t *testing.T) {
+	raw := sanitizeJSON([]byte(`{"params":{"password":"123","list":[{"API_KEY":"abc","value":1}]}}`))
+	s := string(raw)
+	if strings.Contains(s, "123") || strings.Contains(s, "abc") || !strings.Contains(s, `"value":1`) {
+		t.Errorf("sanitizeJSON = %s", s)
+	}
+	if raw := sanitizeJSON([]byte("not json")); string(raw) != `"not json"` {
+		t.Errorf("sanitizeJSON not json = %s", raw)
+	}
+}
+
+func TestWalletClient_Trace(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"current_height":100}}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	c := NewWalletClient(server.URL, server.URL, false)
+	if err := c.SetTrace(true, dir); err != nil {
+		t.Fatalf("SetTrace unexpected error: %v", err)
+	}
+
+	c.GetWalletStatus(context.Background())
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*_wallet_status.json"))
+	if len(files) != 1 {
+		t.Fatalf("trace files = %v, want 1", files)
+	}
+	data, _ := ioutil.ReadFile(files[0])
+	var record rpcTrace
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("trace file unexpected error: %v", err)
+	}
+	if len(record.ID) == 0 || record.Status != 200 || !strings.Contains(string(record.Request), `"wallet_status"`) ||
+		!strings.Contains(string(record.Response), `"current_height":100`) {
+		t.Errorf("trace record = %s", data)
+	}
+}
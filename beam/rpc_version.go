@@ -0,0 +1,181 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//WalletAPIVersion 钱包API版本
+type WalletAPIVersion struct {
+	Major       int
+	Minor       int
+	BeamVersion string //beam钱包版本
+}
+
+//legacyWalletAPIVersion 不支持get_version的旧版钱包API
+var legacyWalletAPIVersion = WalletAPIVersion{Major: 5, Minor: 0}
+
+//ParseWalletAPIVersion 解析钱包API版本，格式：6.1
+func ParseWalletAPIVersion(s string) (*WalletAPIVersion, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ".", 2)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet api version: %s", s)
+	}
+	v := WalletAPIVersion{Major: major}
+	if len(parts) == 2 {
+		v.Minor, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid wallet api version: %s", s)
+		}
+	}
+	return &v, nil
+}
+
+func (v *WalletAPIVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+//Before 是否低于指定版本
+func (v *WalletAPIVersion) Before(major, minor int) bool {
+	return v.Major < major || (v.Major == major && v.Minor < minor)
+}
+
+//isMethodNotFound 钱包API不支持该方法
+func isMethodNotFound(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "[-32601]")
+}
+
+//GetVersion 获取钱包API版本，v6之前的钱包API不支持
+func (c *WalletClient) GetVersion(ctx context.Context) (*WalletAPIVersion, error) {
+
+	r, err := c.callContext(ctx, "get_version", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	v := WalletAPIVersion{
+		Major:       int(r.Get("api_version_major").Int()),
+		Minor:       int(r.Get("api_version_minor").Int()),
+		BeamVersion: r.Get("beam_version").String(),
+	}
+	if v.Major == 0 {
+		parsed, err := ParseWalletAPIVersion(r.Get("api_version").String())
+		if err != nil {
+			return nil, err
+		}
+		v.Major, v.Minor = parsed.Major, parsed.Minor
+	}
+	return &v, nil
+}
+
+//NegotiateVersion 探测钱包API版本，按版本适配请求参数，不支持get_version时按旧版处理
+func (c *WalletClient) NegotiateVersion(ctx context.Context) (*WalletAPIVersion, error) {
+
+	v, err := c.GetVersion(ctx)
+	if isMethodNotFound(err) {
+		legacy := legacyWalletAPIVersion
+		v, err = &legacy, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetWalletAPIVersion(v)
+	return v, nil
+}
+
+//SetWalletAPIVersion 设置钱包API版本，不探测
+func (c *WalletClient) SetWalletAPIVersion(v *WalletAPIVersion) {
+	c.version.Store(v)
+}
+
+//WalletAPIVersion 当前使用的钱包API版本，未探测时返回nil
+func (c *WalletClient) WalletAPIVersion() *WalletAPIVersion {
+	v, _ := c.version.Load().(*WalletAPIVersion)
+	return v
+}
+
+//compatAdapter 低于指定版本的钱包API需要调整的请求参数
+type compatAdapter struct {
+	method string
+	major  int
+	minor  int
+	adapt  func(request map[string]interface{})
+}
+
+//compatAdapters 各版本钱包API的请求差异
+var compatAdapters = []compatAdapter{
+	//v6之前不支持按资产过滤UTXO，资产在本地过滤
+	{method: "get_utxo", major: 6, adapt: func(request map[string]interface{}) {
+		delete(request, "assets")
+		delete(request, "filter")
+	}},
+	//v6之前不支持列出机密资产的交易单
+	{method: "tx_list", major: 6, adapt: func(request map[string]interface{}) {
+		delete(request, "assets")
+	}},
+	//v6之前地址有效期不支持auto
+	{method: "create_address", major: 6, adapt: func(request map[string]interface{}) {
+		if request["expiration"] == AddressExpirationAuto {
+			request["expiration"] = AddressExpiration24h
+		}
+	}},
+}
+
+//adaptRequest 按钱包API版本调整请求参数，版本未知时不调整
+func (c *WalletClient) adaptRequest(method string, request interface{}) interface{} {
+
+	v := c.WalletAPIVersion()
+	params, ok := request.(map[string]interface{})
+	if v == nil || !ok {
+		return request
+	}
+
+	copied := false
+	for _, a := range compatAdapters {
+		if a.method != method || !v.Before(a.major, a.minor) {
+			continue
+		}
+		//复制请求参数，不修改调用方的参数
+		if !copied {
+			params = copyParams(params)
+			copied = true
+		}
+		a.adapt(params)
+	}
+
+	return params
+}
+
+func copyParams(params map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		copied[k] = v
+	}
+	return copied
+}
+
+//NegotiateWalletAPIVersion 探测钱包API版本，配置了walletapiversion时使用配置的版本
+func (wm *WalletManager) NegotiateWalletAPIVersion() {
+
+	c, ok := wm.httpWalletClient()
+	if !ok {
+		return
+	}
+
+	if len(wm.Config.walletapiversion) > 0 {
+		v, _ := ParseWalletAPIVersion(wm.Config.walletapiversion)
+		c.SetWalletAPIVersion(v)
+		return
+	}
+
+	v, err := c.NegotiateVersion(context.Background())
+	if err != nil {
+		wm.Log.Warningf("negotiate wallet api version failed, unexpected error: %v", err)
+		return
+	}
+	wm.Log.Infof("wallet api version: %s, beam version: %s", v.String(), v.BeamVersion)
+}
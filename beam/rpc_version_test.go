@@ -0,0 +1,76 @@
+package beam
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseWalletAPIVersion(t *testing.T) {
+	v, err := ParseWalletAPIVersion("6.1")
+	if err != nil || v.Major != 6 || v.Minor != 1 {
+		t.Errorf("ParseWalletAPIVersion(6.1) = %v, err = %v", v, err)
+	}
+	if !v.Before(7, 0) || !v.Before(6, 2) || v.Before(6, 1) || v.Before(5, 9) {
+		t.Errorf("version %s compare failed", v)
+	}
+	if _, err := ParseWalletAPIVersion("v6"); err == nil {
+		t.Errorf("ParseWalletAPIVersion(v6) should fail")
+	}
+}
+
+func TestWalletClient_NegotiateVersion(t *testing.T) {
+
+	supported := true
+	var lastParams map[string]interface{}
+	capture := func(result string) rpcTestHandler {
+		return func(params map[string]interface{}) (string, error) {
+			lastParams = params
+			return result, nil
+		}
+	}
+	server := newRPCTestServer(rpcTestMethods{
+		"get_version": func(params map[string]interface{}) (string, error) {
+			lastParams = params
+			if !supported {
+				return "", &rpcTestError{Code: -32601, Message: "Method not found"}
+			}
+			return `{"api_version":"7.1","api_version_major":7,"api_version_minor":1,"beam_version":"7.1.13105"}`, nil
+		},
+		"get_utxo":       capture(`[]`),
+		"create_address": capture(`[]`),
+	})
+	defer server.Close()
+
+	c := NewWalletClient(server.URL, server.URL, false)
+	assetID := int64(1)
+
+	//版本未知时不调整参数
+	c.ListUTXO(context.Background(), UtxoFilter{AssetID: &assetID})
+	if lastParams["assets"] != true {
+		t.Errorf("params should not be adapted before negotiation: %v", lastParams)
+	}
+
+	v, err := c.NegotiateVersion(context.Background())
+	if err != nil || v.String() != "7.1" || v.BeamVersion != "7.1.13105" {
+		t.Errorf("NegotiateVersion = %v, err = %v", v, err)
+	}
+	c.ListUTXO(context.Background(), UtxoFilter{AssetID: &assetID})
+	if lastParams["assets"] != true || lastParams["filter"] == nil {
+		t.Errorf("v7 params = %v", lastParams)
+	}
+
+	//旧版钱包API
+	supported = false
+	v, err = c.NegotiateVersion(context.Background())
+	if err != nil || *v != legacyWalletAPIVersion || c.WalletAPIVersion() != v {
+		t.Errorf("NegotiateVersion legacy = %v, err = %v", v, err)
+	}
+	c.ListUTXO(context.Background(), UtxoFilter{AssetID: &assetID})
+	if _, ok := lastParams["assets"]; ok {
+		t.Errorf("v5 params should not have assets: %v", lastParams)
+	}
+	c.CreateAddress(context.Background(), AddressExpirationAuto, DefaultAddressComment)
+	if lastParams["expiration"] != AddressExpiration24h {
+		t.Errorf("v5 create_address params = %v", lastParams)
+	}
+}
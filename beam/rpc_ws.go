@@ -0,0 +1,340 @@
+package beam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blocktree/openwallet/log"
+	"github.com/gorilla/websocket"
+	"github.com/imroc/req"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//钱包API的传输方式
+	WalletAPITransportHTTP      = "http"      //每个请求一次http调用
+	WalletAPITransportWebsocket = "websocket" //共用一个websocket长连接，同时接收钱包推送的事件
+)
+
+//wsConnError websocket连接断开或读写失败，只读请求可以重连后重试
+type wsConnError struct {
+	err error
+}
+
+func (e *wsConnError) Error() string {
+	return fmt.Sprintf("wallet api websocket closed: %v", e.err)
+}
+
+//wsTransport 钱包API的websocket传输，多个请求通过id复用同一个连接，
+//没有对应请求的消息是钱包推送的事件，交给事件处理函数
+type wsTransport struct {
+	c       *WalletClient
+	mu      sync.Mutex
+	conn    *wsConn
+	nextID  uint64
+	handler atomic.Value //func([]byte)
+}
+
+//wsConn 一个websocket连接和等待响应的请求
+type wsConn struct {
+	url     string
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint64]chan []byte
+	events  [][]byte
+	notify  chan struct{}
+	done    chan struct{}
+	err     error
+}
+
+//SetTransport 设置钱包API的传输方式，websocket连接在第一次请求时建立，断开后下次请求自动重连
+func (c *WalletClient) SetTransport(transport string) error {
+	switch transport {
+	case WalletAPITransportHTTP, "":
+		if c.ws != nil {
+			c.ws.close()
+		}
+		c.ws = nil
+	case WalletAPITransportWebsocket:
+		if c.ws == nil {
+			c.ws = &wsTransport{c: c}
+		}
+	default:
+		return fmt.Errorf("unknown wallet api transport: %s", transport)
+	}
+	return nil
+}
+
+//Transport 钱包API的传输方式
+func (c *WalletClient) Transport() string {
+	if c.ws != nil {
+		return WalletAPITransportWebsocket
+	}
+	return WalletAPITransportHTTP
+}
+
+//OnWalletEvent 设置websocket推送事件的处理函数，http传输没有推送事件
+func (c *WalletClient) OnWalletEvent(handler func(event []byte)) {
+	if c.ws != nil {
+		c.ws.handler.Store(handler)
+	}
+}
+
+//Close 关闭websocket连接
+func (c *WalletClient) Close() {
+	if c.ws != nil {
+		c.ws.close()
+	}
+}
+
+//wsURL 钱包API地址转为websocket地址
+func wsURL(u string) string {
+	switch {
+	case strings.HasPrefix(u, "https://"):
+		return "wss://" + strings.TrimPrefix(u, "https://")
+	case strings.HasPrefix(u, "http://"):
+		return "ws://" + strings.TrimPrefix(u, "http://")
+	}
+	return u
+}
+
+//connect 返回当前的连接，未连接或已断开时依次尝试可用的钱包API节点建立连接
+func (t *wsTransport) connect(ctx context.Context) (*wsConn, error) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn != nil && !t.conn.closed() {
+		return t.conn, nil
+	}
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: t.c.opts.timeout,
+	}
+	if trans, ok := t.c.client.Client().Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = trans.TLSClientConfig
+	}
+
+	header := http.Header{}
+	for k, v := range t.c.authHeader(req.Header{}) {
+		header.Set(k, v)
+	}
+
+	lastErr := fmt.Errorf("API url is not setup. ")
+	for _, u := range t.c.endpoints.urls() {
+		conn, _, err := dialer.DialContext(ctx, wsURL(u), header)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			t.c.endpoints.mark(u, err)
+			log.Std.Warn("wallet api: %s websocket connect failed, unexpected error: %v", u, err)
+			lastErr = err
+			continue
+		}
+		t.c.endpoints.mark(u, nil)
+
+		t.conn = &wsConn{
+			url:     u,
+			conn:    conn,
+			pending: make(map[uint64]chan []byte),
+			notify:  make(chan struct{}, 1),
+			done:    make(chan struct{}),
+		}
+		go t.readLoop(t.conn)
+		go t.eventLoop(t.conn)
+		return t.conn, nil
+	}
+
+	return nil, lastErr
+}
+
+//close 关闭当前连接
+func (t *wsTransport) close() {
+	t.mu.Lock()
+	conn := t.conn
+	t.conn = nil
+	t.mu.Unlock()
+
+	if conn != nil {
+		conn.close(fmt.Errorf("closed by client"))
+	}
+}
+
+//readLoop 读取响应和推送事件，直到连接断开
+func (t *wsTransport) readLoop(conn *wsConn) {
+	for {
+		_, data, err := conn.conn.ReadMessage()
+		if err != nil {
+			conn.close(err)
+			return
+		}
+
+		//请求的id是数字，推送事件的id是事件名
+		id := gjson.GetBytes(data, "id")
+		if id.Type == gjson.Number && conn.deliver(id.Uint(), data) {
+			continue
+		}
+
+		conn.pushEvent(data)
+	}
+}
+
+//eventLoop 按顺序处理推送事件，事件处理可能调用钱包API，不能阻塞读取响应
+func (t *wsTransport) eventLoop(conn *wsConn) {
+	for {
+		select {
+		case <-conn.notify:
+		case <-conn.done:
+			return
+		}
+
+		for _, data := range conn.popEvents() {
+			if handler, ok := t.handler.Load().(func([]byte)); ok && handler != nil {
+				handler(data)
+			}
+		}
+	}
+}
+
+//call 发送请求并等待相同id的响应，返回响应内容
+func (t *wsTransport) call(ctx context.Context, method string, body map[string]interface{}) ([]byte, error) {
+
+	ctx, cancel := t.c.opts.withTimeout(ctx, method)
+	defer cancel()
+
+	if err := t.c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	conn, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	//每个请求使用不同的id，响应按id分发
+	id := atomic.AddUint64(&t.nextID, 1)
+	message := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		message[k] = v
+	}
+	message["id"] = id
+	request, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := conn.register(id)
+	defer conn.unregister(id)
+
+	start := time.Now()
+	response, err := conn.roundTrip(ctx, request, ch)
+
+	if t.c.opts.tracer != nil {
+		t.c.opts.tracer.trace(newTraceID(), rpcAPIWallet, method, conn.url, start, request, response, 0, err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+//roundTrip 写入请求，等待响应、连接断开或ctx结束
+func (conn *wsConn) roundTrip(ctx context.Context, request []byte, ch chan []byte) ([]byte, error) {
+
+	conn.writeMu.Lock()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.conn.SetWriteDeadline(deadline)
+	}
+	err := conn.conn.WriteMessage(websocket.TextMessage, request)
+	conn.writeMu.Unlock()
+	if err != nil {
+		conn.close(err)
+		return nil, &wsConnError{err: err}
+	}
+
+	select {
+	case response := <-ch:
+		return response, nil
+	case <-conn.done:
+		return nil, &wsConnError{err: conn.err}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (conn *wsConn) register(id uint64) chan []byte {
+	ch := make(chan []byte, 1)
+	conn.mu.Lock()
+	conn.pending[id] = ch
+	conn.mu.Unlock()
+	return ch
+}
+
+func (conn *wsConn) unregister(id uint64) {
+	conn.mu.Lock()
+	delete(conn.pending, id)
+	conn.mu.Unlock()
+}
+
+//deliver 把响应交给等待的请求，没有对应请求时返回false
+func (conn *wsConn) deliver(id uint64, data []byte) bool {
+	conn.mu.Lock()
+	ch, ok := conn.pending[id]
+	delete(conn.pending, id)
+	conn.mu.Unlock()
+	if ok {
+		ch <- data
+	}
+	return ok
+}
+
+func (conn *wsConn) pushEvent(data []byte) {
+	conn.mu.Lock()
+	conn.events = append(conn.events, data)
+	conn.mu.Unlock()
+
+	select {
+	case conn.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (conn *wsConn) popEvents() [][]byte {
+	conn.mu.Lock()
+	events := conn.events
+	conn.events = nil
+	conn.mu.Unlock()
+	return events
+}
+
+func (conn *wsConn) closed() bool {
+	select {
+	case <-conn.done:
+		return true
+	default:
+		return false
+	}
+}
+
+//close 关闭连接，等待中的请求返回连接错误
+func (conn *wsConn) close(err error) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.closed() {
+		return
+	}
+	conn.err = err
+	close(conn.done)
+	conn.conn.Close()
+}
@@ -0,0 +1,182 @@
+package beam
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type wsTestRequest struct {
+	ID     uint64                 `json:"id"`
+	Method string                 `json:"method"`
+	Params map[string]interface{} `json:"params"`
+}
+
+//newWSTestServer 模拟websocket模式的钱包API，handle返回每个请求的响应，返回nil时不响应
+func newWSTestServer(t *testing.T, handle func(conn *websocket.Conn, request wsTestRequest) interface{}) (*httptest.Server, *int32) {
+	var dials int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&dials, 1)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade unexpected error: %v", err)
+			return
+		}
+		defer conn.Close()
+		for {
+			var request wsTestRequest
+			if err := conn.ReadJSON(&request); err != nil {
+				return
+			}
+			result := handle(conn, request)
+			if result == nil {
+				continue
+			}
+			conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": result})
+		}
+	}))
+	return server, &dials
+}
+
+func TestWsURL(t *testing.T) {
+	cases := map[string]string{
+		"http://127.0.0.1:10000/api/wallet":  "ws://127.0.0.1:10000/api/wallet",
+		"https://127.0.0.1:10000/api/wallet": "wss://127.0.0.1:10000/api/wallet",
+		"ws://127.0.0.1:10000":               "ws://127.0.0.1:10000",
+	}
+	for u, want := range cases {
+		if got := wsURL(u); got != want {
+			t.Errorf("wsURL(%s) = %s, want %s", u, got, want)
+		}
+	}
+}
+
+func TestWalletClient_WebsocketTransport(t *testing.T) {
+
+	//先收齐两个请求，再倒序响应，验证按id分发
+	var held []wsTestRequest
+	server, dials := newWSTestServer(t, func(conn *websocket.Conn, request wsTestRequest) interface{} {
+		switch request.Method {
+		case "tx_status":
+			held = append(held, request)
+			if len(held) < 2 {
+				return nil
+			}
+			for i := len(held) - 1; i >= 0; i-- {
+				conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": held[i].ID, "result": map[string]interface{}{"txId": held[i].Params["txId"]}})
+			}
+			held = nil
+			conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": WalletEventUtxosChanged, "result": map[string]interface{}{}})
+			return nil
+		case "wallet_status":
+			//响应后断开，下一次请求重新连接
+			conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": map[string]interface{}{"available": 100}})
+			conn.Close()
+			return nil
+		}
+		return nil
+	})
+	defer server.Close()
+
+	c := NewWalletClient(server.URL, "", false)
+	if err := c.SetTransport(WalletAPITransportWebsocket); err != nil {
+		t.Errorf("SetTransport unexpected error: %v", err)
+		return
+	}
+	defer c.Close()
+
+	events := make(chan string, 1)
+	c.OnWalletEvent(func(event []byte) {
+		var msg struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(event, &msg)
+		events <- msg.ID
+	})
+
+	results, err := c.CallBatch(context.Background(), []RPCRequest{
+		{Method: "tx_status", Params: map[string]interface{}{"txId": "a"}},
+		{Method: "tx_status", Params: map[string]interface{}{"txId": "b"}},
+	})
+	if err != nil {
+		t.Errorf("CallBatch unexpected error: %v", err)
+		return
+	}
+	for i, txid := range []string{"a", "b"} {
+		if results[i].Err != nil || results[i].Result.Get("txId").String() != txid {
+			t.Errorf("result %d = %v, %v, want %s", i, results[i].Result, results[i].Err, txid)
+		}
+	}
+
+	select {
+	case event := <-events:
+		if event != WalletEventUtxosChanged {
+			t.Errorf("event = %s, want %s", event, WalletEventUtxosChanged)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("wallet event is not received")
+	}
+
+	status, err := c.GetWalletStatus(context.Background())
+	if err != nil || status.Available != 100 {
+		t.Errorf("GetWalletStatus = %v, %v", status, err)
+	}
+
+	//连接已被服务端关闭，下一次请求重新连接
+	c.SetRetryPolicy(RetryPolicy{Count: 1, Delay: 10 * time.Millisecond})
+	time.Sleep(50 * time.Millisecond)
+	status, err = c.GetWalletStatus(context.Background())
+	if err != nil || status.Available != 100 {
+		t.Errorf("GetWalletStatus after reconnect = %v, %v", status, err)
+	}
+	if n := atomic.LoadInt32(dials); n != 2 {
+		t.Errorf("dials = %d, want 2", n)
+	}
+}
+
+func TestWalletEventListener_Websocket(t *testing.T) {
+
+	server, _ := newWSTestServer(t, func(conn *websocket.Conn, request wsTestRequest) interface{} {
+		switch request.Method {
+		case "ev_subunsub":
+			conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": true})
+			conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": WalletEventUtxosChanged, "result": map[string]interface{}{}})
+			return nil
+		case "tx_list":
+			return []interface{}{}
+		case "wallet_status":
+			return map[string]interface{}{"available": 200}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	c := NewWalletClient(server.URL, "", false)
+	c.SetTransport(WalletAPITransportWebsocket)
+	defer c.Close()
+
+	wm := NewWalletManager()
+	wm.SetWalletAPI(c)
+	l := NewWalletEventListenerWS(wm, c)
+	l.Start()
+	defer l.Stop()
+
+	//事件处理中查询钱包状态，不能阻塞响应的读取
+	deadline := time.Now().Add(2 * time.Second)
+	for l.WalletStatus() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status := l.WalletStatus(); status == nil || status.Available != 200 {
+		t.Errorf("WalletStatus = %v, want available 200", status)
+	}
+	if !l.Connected() {
+		t.Errorf("listener should be connected")
+	}
+}
@@ -0,0 +1,82 @@
+package beam
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	//计算扫描速度的采样区块数
+	scanRateWindow = 100
+)
+
+//ScanProgress 区块扫描进度
+type ScanProgress struct {
+	ScannedHeight   uint64              `json:"scannedHeight"`
+	ChainTip        uint64              `json:"chainTip"`
+	BlocksRemaining uint64              `json:"blocksRemaining"`
+	BlocksPerSecond float64             `json:"blocksPerSecond"`
+	ETA             time.Duration       `json:"eta"`
+	Scanning        bool                `json:"scanning"`
+	WalletSync      *WalletSyncProgress `json:"walletSync,omitempty"` //订阅钱包事件时，钱包推送的同步进度
+}
+
+//scanRate 记录最近扫描区块的时间，用于计算扫描速度
+type scanRate struct {
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (r *scanRate) record(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.times = append(r.times, t)
+	if len(r.times) > scanRateWindow {
+		r.times = r.times[len(r.times)-scanRateWindow:]
+	}
+}
+
+//blocksPerSecond 最近采样窗口的扫描速度
+func (r *scanRate) blocksPerSecond() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.times) < 2 {
+		return 0
+	}
+	elapsed := r.times[len(r.times)-1].Sub(r.times[0]).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(len(r.times)-1) / elapsed
+}
+
+//GetScanProgress 获取区块扫描进度
+func (bs *BEAMBlockScanner) GetScanProgress() (*ScanProgress, error) {
+
+	chainTip, err := bs.GetBlockHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &ScanProgress{
+		ScannedHeight:   bs.GetScannedBlockHeight(),
+		ChainTip:        chainTip,
+		BlocksPerSecond: bs.rate.blocksPerSecond(),
+		Scanning:        bs.Scanning,
+	}
+
+	if progress.ChainTip > progress.ScannedHeight {
+		progress.BlocksRemaining = progress.ChainTip - progress.ScannedHeight
+	}
+
+	if progress.BlocksPerSecond > 0 {
+		progress.ETA = time.Duration(float64(progress.BlocksRemaining)/progress.BlocksPerSecond) * time.Second
+	}
+
+	if bs.wm.walletEvents != nil && bs.wm.walletEvents.Connected() {
+		walletSync := bs.wm.walletEvents.SyncProgress()
+		progress.WalletSync = &walletSync
+	}
+
+	return progress, nil
+}
@@ -0,0 +1,74 @@
+package beam
+
+import (
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//以下扫描目标v2类型与新版openwallet的定义保持一致，
+//当前依赖的openwallet v1.5.2没有这些类型，升级openwallet后删除本文件的类型定义，改为实现openwallet包内的接口
+
+const (
+	ScanTargetTypeAccountAddress  = 0 //账户地址
+	ScanTargetTypeAccountAlias    = 1 //账户别名
+	ScanTargetTypeContractAddress = 2 //合约地址
+	ScanTargetTypeContractAlias   = 3 //合约别名
+	ScanTargetTypeAddressPubKey   = 4 //地址公钥
+	ScanTargetTypeAddressMemo     = 5 //地址备注
+)
+
+//ScanTargetParam 扫描目标参数
+type ScanTargetParam struct {
+	ScanTarget     string
+	Symbol         string
+	ScanTargetType uint64
+}
+
+//ScanTargetResult 扫描目标查找结果
+type ScanTargetResult struct {
+	SourceKey  string
+	Exist      bool
+	TargetInfo interface{}
+}
+
+//BlockScanTargetFuncV2 扫描目标查找函数v2
+type BlockScanTargetFuncV2 func(target ScanTargetParam) ScanTargetResult
+
+//SetBlockScanTargetFuncV2 设置区块扫描过程，查找扫描对象过程，设置后优先于v1的ScanTargetFunc
+func (bs *BEAMBlockScanner) SetBlockScanTargetFuncV2(scanTargetFuncV2 BlockScanTargetFuncV2) error {
+	bs.Mu.Lock()
+	defer bs.Mu.Unlock()
+	bs.scanTargetFuncV2 = scanTargetFuncV2
+	return nil
+}
+
+//scanTargetFunc 扫描时使用的查找函数，设置了v2时转换为v1的调用方式
+func (bs *BEAMBlockScanner) scanTargetFunc() openwallet.BlockScanTargetFunc {
+	bs.Mu.RLock()
+	defer bs.Mu.RUnlock()
+	if bs.scanTargetFuncV2 == nil {
+		return bs.ScanTargetFunc
+	}
+	return ScanTargetFuncFromV2(bs.wm.Symbol(), bs.scanTargetFuncV2)
+}
+
+//ScanTargetFuncFromV2 把v2的查找函数转换为v1，地址模型按账户地址查找，账户模型按账户别名查找
+func ScanTargetFuncFromV2(symbol string, scanTargetFuncV2 BlockScanTargetFuncV2) openwallet.BlockScanTargetFunc {
+	return func(target openwallet.ScanTarget) (string, bool) {
+		param := ScanTargetParam{
+			ScanTarget:     target.Address,
+			Symbol:         symbol,
+			ScanTargetType: ScanTargetTypeAccountAddress,
+		}
+		if target.BalanceModelType == openwallet.BalanceModelTypeAccount {
+			param.ScanTarget = target.Alias
+			param.ScanTargetType = ScanTargetTypeAccountAlias
+		}
+		result := scanTargetFuncV2(param)
+		return result.SourceKey, result.Exist
+	}
+}
+
+//ExtractTransactionDataV2 使用v2的查找函数提取交易单
+func (bs *BEAMBlockScanner) ExtractTransactionDataV2(txid string, scanTargetFuncV2 BlockScanTargetFuncV2) (map[string][]*openwallet.TxExtractData, error) {
+	return bs.ExtractTransactionData(txid, ScanTargetFuncFromV2(bs.wm.Symbol(), scanTargetFuncV2))
+}
@@ -0,0 +1,274 @@
+package beam
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/blocktree/openwallet/log"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	//启动时从标准输入读取
+	secretPrompt = "prompt"
+
+	//配置加密密钥的环境变量，没有配置secretkeyfile时使用
+	SecretKeyEnv = "BEAM_SECRET_KEY"
+	//加密值的密钥派生盐长度
+	secretSaltSize = 16
+)
+
+//secretSource 敏感配置的来源，配置值为<scheme>:<引用>且有对应的SecretProvider时每次由提供者解析最新的值，
+//prompt只在启动时读取一次，其他值为明文
+type secretSource struct {
+	name     string //配置项名称
+	source   string //配置的原始值
+	value    string //prompt读取的值
+	provider SecretProvider
+	ref      string
+}
+
+//newSecretSource 解析敏感配置，prompt时立即从标准输入读取，其他来源立即解析一次以便启动时发现错误
+func newSecretSource(name, source string, providers *SecretProviders) (*secretSource, error) {
+	s := &secretSource{name: name, source: strings.TrimSpace(source)}
+	if s.source == secretPrompt {
+		value, err := promptSecret(name)
+		if err != nil {
+			return nil, err
+		}
+		s.value = value
+	} else {
+		s.provider, s.ref = providers.lookup(s.source)
+	}
+	if _, err := s.Value(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//Value 敏感配置当前的值
+func (s *secretSource) Value() (string, error) {
+	if s == nil {
+		return "", nil
+	}
+	if s.source == secretPrompt {
+		return s.value, nil
+	}
+	if s.provider != nil {
+		value, err := s.provider.Resolve(s.ref)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", s.name, err)
+		}
+		return value, nil
+	}
+	return s.source, nil
+}
+
+//String 敏感配置当前的值，读取失败时为空
+func (s *secretSource) String() string {
+	value, err := s.Value()
+	if err != nil {
+		log.Std.Warn("read %v", err)
+	}
+	return value
+}
+
+//Update 轮换后更新敏感配置，文件来源写入新值，其他来源只更新内存中的值
+func (s *secretSource) Update(value string) error {
+	if _, ok := s.provider.(*FileSecretProvider); ok {
+		return ioutil.WriteFile(s.ref, []byte(value+"\n"), 0600)
+	}
+	s.source = secretPrompt
+	s.value = value
+	s.provider = nil
+	return nil
+}
+
+//loadSecretKey 配置加密密钥：keyFile的内容，没有配置时使用BEAM_SECRET_KEY环境变量，都没有时为空
+func loadSecretKey(keyFile string) (string, error) {
+	if len(keyFile) > 0 {
+		data, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return "", fmt.Errorf("secretkeyfile: %v", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return os.Getenv(SecretKeyEnv), nil
+}
+
+//encryptSecret 加密配置值：enc:base64(盐|nonce|密文)，密钥为口令，按scrypt派生
+func encryptSecret(value, key string) (string, error) {
+	if len(key) == 0 {
+		return "", fmt.Errorf("secret key is not configured, set secretkeyfile or %s", SecretKeyEnv)
+	}
+	salt := make([]byte, secretSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	gcm, err := passphraseCipher(key, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := append(append(salt, nonce...), gcm.Seal(nil, nonce, []byte(value), nil)...)
+	return SecretSchemeEnc + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+//decryptSecret 解密encryptSecret生成的配置值，ref为去掉enc:的部分
+func decryptSecret(ref, key string) (string, error) {
+	if len(key) == 0 {
+		return "", fmt.Errorf("secret key is not configured, set secretkeyfile or %s", SecretKeyEnv)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(ref)
+	if err != nil {
+		return "", fmt.Errorf("encrypted value is invalid")
+	}
+	if len(sealed) < secretSaltSize {
+		return "", fmt.Errorf("encrypted value is invalid")
+	}
+	gcm, err := passphraseCipher(key, sealed[:secretSaltSize])
+	if err != nil {
+		return "", err
+	}
+	sealed = sealed[secretSaltSize:]
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted value is invalid")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("secret key is wrong or the encrypted value is corrupted")
+	}
+	return string(plain), nil
+}
+
+//EncryptSecret 用配置的密钥（secretkeyfile或BEAM_SECRET_KEY）加密敏感配置值，结果可直接写入配置文件
+func (wm *WalletManager) EncryptSecret(value string) (string, error) {
+	return encryptSecret(value, wm.Config.secretkey)
+}
+
+//promptSecret 从标准输入读取一行作为敏感配置的值，标准输入是终端时不回显，无人值守部署请使用env:或file:
+func promptSecret(name string) (string, error) {
+	fmt.Fprintf(os.Stderr, "Enter %s: ", name)
+	if fd := int(os.Stdin.Fd()); terminal.IsTerminal(fd) {
+		value, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("read %s from terminal failed: %v", name, err)
+		}
+		return string(value), nil
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && len(line) == 0 {
+		return "", fmt.Errorf("read %s from stdin failed: %v", name, err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+//ReadSecret 读取命令行传入的敏感值，source为prompt（从标准输入读取）、file:<路径>（文件权限不能超过0600）或env:<变量名>，
+//不接受明文，避免助记词和密码出现在进程参数中
+func ReadSecret(name, source string) (string, error) {
+	source = strings.TrimSpace(source)
+	if source == secretPrompt {
+		return promptSecret(name)
+	}
+	provider, ref := newSecretProviders().lookup(source)
+	if provider == nil {
+		return "", fmt.Errorf("%s must be prompt, file:<path> or env:<name>", name)
+	}
+	if provider.Scheme() == SecretSchemeFile {
+		info, err := os.Stat(ref)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", name, err)
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			return "", fmt.Errorf("%s: file %s is accessible by other users, chmod 600", name, ref)
+		}
+	}
+	value, err := provider.Resolve(ref)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	return value, nil
+}
+
+//walletAPIAuth 按配置的来源读取钱包API当前的认证信息
+func (wm *WalletManager) walletAPIAuth() (WalletAPIAuth, error) {
+	auth := wm.Config.walletapiauth
+	password, err := wm.Config.walletapipassword.Value()
+	if err != nil {
+		return auth, err
+	}
+	apiKey, err := wm.Config.walletapikey.Value()
+	if err != nil {
+		return auth, err
+	}
+	auth.Password = password
+	auth.APIKey = apiKey
+	return auth, nil
+}
+
+//ReloadWalletAPIAuth 重新读取钱包API的认证信息，代理的密码或API Key轮换后调用；
+//钱包API返回401时客户端会自动重新读取并重试一次
+func (wm *WalletManager) ReloadWalletAPIAuth() error {
+	c, ok := wm.httpWalletClient()
+	if !ok {
+		return fmt.Errorf("wallet api client does not support auth")
+	}
+	_, err := c.reloadAuth()
+	return err
+}
+
+//ChangeWalletPassword 通过beam-wallet命令行（walletcli）修改wallet.db的密码，oldPass为空时使用walletpassword配置；
+//命令行需要独占打开wallet.db，只能在钱包API停止时执行，成功后walletpassword为file:来源时把新密码写入该文件
+func (wm *WalletManager) ChangeWalletPassword(ctx context.Context, oldPass, newPass string) error {
+
+	if len(wm.Config.walletdatafile) == 0 {
+		return fmt.Errorf("walletdatafile is not configured")
+	}
+	if len(oldPass) == 0 {
+		current, err := wm.Config.walletpassword.Value()
+		if err != nil {
+			return err
+		}
+		oldPass = current
+	}
+	if len(oldPass) == 0 || len(newPass) == 0 {
+		return fmt.Errorf("wallet password is empty")
+	}
+	if oldPass == newPass {
+		return fmt.Errorf("new wallet password is the same as the old one")
+	}
+	if _, err := os.Stat(wm.Config.walletdatafile); err != nil {
+		return err
+	}
+
+	//密码不放在命令行参数中，按beam-wallet的提示顺序从标准输入传入：旧密码、新密码、确认新密码
+	cmd := exec.CommandContext(ctx, wm.Config.walletcli, "change_password",
+		"--wallet_path="+wm.Config.walletdatafile,
+	)
+	cmd.Stdin = strings.NewReader(oldPass + "\n" + newPass + "\n" + newPass + "\n")
+	if _, err := cmd.CombinedOutput(); err != nil {
+		//输出可能包含密码，不写日志
+		return fmt.Errorf("beam-wallet change_password failed: %v", err)
+	}
+
+	if wm.Config.walletpassword == nil {
+		wm.Config.walletpassword = &secretSource{name: "walletpassword"}
+	}
+	if err := wm.Config.walletpassword.Update(newPass); err != nil {
+		return fmt.Errorf("wallet password changed, but save the new password failed: %v", err)
+	}
+
+	wm.Log.Infof("wallet password of %s has been changed", wm.Config.walletdatafile)
+	return nil
+}
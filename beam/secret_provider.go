@@ -0,0 +1,317 @@
+package beam
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//内置敏感配置提供者的scheme
+	SecretSchemeEnv    = "env"    //环境变量，如env:BEAM_WALLET_API_PASSWORD
+	SecretSchemeFile   = "file"   //文件内容，去掉首尾空白，如file:/run/secrets/beam
+	SecretSchemeEnc    = "enc"    //secret encrypt生成的加密值，用secretkeyfile或BEAM_SECRET_KEY的密钥解密
+	SecretSchemeVault  = "vault"  //HashiCorp Vault KV，如vault:secret/data/beam#walletapipassword
+	SecretSchemeAWSKMS = "awskms" //AWS KMS加密的密文（base64），启动时调用KMS Decrypt解密
+)
+
+//SecretProvider 敏感配置的提供者，配置值为<Scheme>:<引用>时由对应的提供者解析，
+//可通过WalletManager.SecretProviders().Register注册其他KMS/HSM的实现，需要在LoadAssetsConfig之前注册
+type SecretProvider interface {
+	Scheme() string
+	Resolve(ref string) (string, error)
+}
+
+//SecretProviders 已注册的敏感配置提供者
+type SecretProviders struct {
+	mu        sync.RWMutex
+	providers map[string]SecretProvider
+}
+
+//newSecretProviders 创建提供者列表，内置环境变量和文件提供者
+func newSecretProviders() *SecretProviders {
+	p := &SecretProviders{providers: make(map[string]SecretProvider)}
+	p.Register(&EnvSecretProvider{})
+	p.Register(&FileSecretProvider{})
+	return p
+}
+
+//Register 注册提供者，scheme相同时替换
+func (p *SecretProviders) Register(provider SecretProvider) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.providers[provider.Scheme()] = provider
+}
+
+//Get 按scheme查找提供者
+func (p *SecretProviders) Get(scheme string) (SecretProvider, bool) {
+	if p == nil {
+		return nil, false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	provider, ok := p.providers[scheme]
+	return provider, ok
+}
+
+//lookup 按配置值的scheme查找提供者，没有对应的提供者时配置值为明文
+func (p *SecretProviders) lookup(source string) (SecretProvider, string) {
+	i := strings.Index(source, ":")
+	if i <= 0 {
+		return nil, ""
+	}
+	provider, ok := p.Get(source[:i])
+	if !ok {
+		return nil, ""
+	}
+	return provider, source[i+1:]
+}
+
+//SecretProviders 敏感配置提供者
+func (wm *WalletManager) SecretProviders() *SecretProviders {
+	return wm.secretProviders
+}
+
+//EnvSecretProvider 从环境变量读取
+type EnvSecretProvider struct{}
+
+func (p *EnvSecretProvider) Scheme() string {
+	return SecretSchemeEnv
+}
+
+func (p *EnvSecretProvider) Resolve(ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+}
+
+//FileSecretProvider 从文件读取，去掉首尾空白
+type FileSecretProvider struct{}
+
+func (p *FileSecretProvider) Scheme() string {
+	return SecretSchemeFile
+}
+
+func (p *FileSecretProvider) Resolve(ref string) (string, error) {
+	data, err := ioutil.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+//EncSecretProvider 解密secret encrypt生成的加密值
+type EncSecretProvider struct {
+	Key string
+}
+
+func (p *EncSecretProvider) Scheme() string {
+	return SecretSchemeEnc
+}
+
+func (p *EncSecretProvider) Resolve(ref string) (string, error) {
+	return decryptSecret(ref, p.Key)
+}
+
+//VaultSecretProvider 从HashiCorp Vault的KV引擎读取，引用格式为<路径>#<字段>，
+//KV v2的路径包含data，如secret/data/beam#walletapipassword
+type VaultSecretProvider struct {
+	Addr   string
+	Token  string
+	client *http.Client
+}
+
+//NewVaultSecretProvider 创建Vault提供者
+func NewVaultSecretProvider(addr, token string) *VaultSecretProvider {
+	return &VaultSecretProvider{
+		Addr:   strings.TrimSuffix(addr, "/"),
+		Token:  token,
+		client: &http.Client{Timeout: DefaultRPCTimeout},
+	}
+}
+
+func (p *VaultSecretProvider) Scheme() string {
+	return SecretSchemeVault
+}
+
+func (p *VaultSecretProvider) Resolve(ref string) (string, error) {
+
+	i := strings.LastIndex(ref, "#")
+	if i <= 0 || i == len(ref)-1 {
+		return "", fmt.Errorf("vault secret reference: %s should be <path>#<field>", ref)
+	}
+	path, field := strings.TrimPrefix(ref[:i], "/"), ref[i+1:]
+
+	req, err := http.NewRequest(http.MethodGet, p.Addr+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault read %s failed: %s %s", path, resp.Status, gjson.GetBytes(body, "errors").String())
+	}
+
+	//KV v2的值在data.data下，KV v1在data下
+	result := gjson.GetBytes(body, "data")
+	if data := result.Get("data"); data.IsObject() {
+		result = data
+	}
+	value, ok := result.Map()[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field: %s", path, field)
+	}
+	return value.String(), nil
+}
+
+//AWSKMSSecretProvider 调用AWS KMS Decrypt解密，引用为KMS加密得到的CiphertextBlob（base64），
+//凭证读取AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY和AWS_SESSION_TOKEN环境变量
+type AWSKMSSecretProvider struct {
+	Region    string
+	Endpoint  string //为空时使用https://kms.<Region>.amazonaws.com
+	AccessKey string
+	SecretKey string
+	Token     string
+	client    *http.Client
+}
+
+//NewAWSKMSSecretProvider 创建AWS KMS提供者，凭证从环境变量读取
+func NewAWSKMSSecretProvider(region, endpoint string) *AWSKMSSecretProvider {
+	if len(endpoint) == 0 {
+		endpoint = "https://kms." + region + ".amazonaws.com"
+	}
+	return &AWSKMSSecretProvider{
+		Region:    region,
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		Token:     os.Getenv("AWS_SESSION_TOKEN"),
+		client:    &http.Client{Timeout: DefaultRPCTimeout},
+	}
+}
+
+func (p *AWSKMSSecretProvider) Scheme() string {
+	return SecretSchemeAWSKMS
+}
+
+func (p *AWSKMSSecretProvider) Resolve(ref string) (string, error) {
+
+	if len(p.AccessKey) == 0 || len(p.SecretKey) == 0 {
+		return "", fmt.Errorf("aws credentials are not set")
+	}
+
+	body, err := json.Marshal(map[string]string{"CiphertextBlob": ref})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+	if len(p.Token) > 0 {
+		req.Header.Set("X-Amz-Security-Token", p.Token)
+	}
+	signAWSRequest(req, body, p.Region, "kms", p.AccessKey, p.SecretKey, time.Now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("aws kms decrypt failed: %s %s %s", resp.Status,
+			gjson.GetBytes(data, "__type").String(), gjson.GetBytes(data, "message").String())
+	}
+
+	plain, err := base64.StdEncoding.DecodeString(gjson.GetBytes(data, "Plaintext").String())
+	if err != nil {
+		return "", fmt.Errorf("aws kms plaintext is invalid")
+	}
+	return string(plain), nil
+}
+
+//signAWSRequest 按AWS Signature Version 4签名请求，签名全部请求头和host
+func signAWSRequest(req *http.Request, body []byte, region, service, accessKey, secretKey string, now time.Time) {
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,127 @@
+package beam
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestVaultSecretProvider(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/beam":
+			w.Write([]byte(`{"data":{"data":{"walletapipassword":"from-vault","wallet.key":"dotted"},"metadata":{"version":1}}}`))
+		case "/v1/kv/beam":
+			w.Write([]byte(`{"data":{"walletapikey":"from-kv1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	providers := newSecretProviders()
+	providers.Register(NewVaultSecretProvider(server.URL+"/", "root-token"))
+
+	tests := map[string]string{
+		"vault:secret/data/beam#walletapipassword": "from-vault",
+		"vault:secret/data/beam#wallet.key":        "dotted",
+		"vault:kv/beam#walletapikey":               "from-kv1",
+	}
+	for source, want := range tests {
+		s, err := newSecretSource("test", source, providers)
+		if err != nil || s.String() != want {
+			t.Errorf("vault secret: %s = %v, %v", source, s, err)
+		}
+	}
+	for _, source := range []string{"vault:secret/data/beam#missing", "vault:secret/data/other#field", "vault:secret/data/beam"} {
+		if _, err := newSecretSource("test", source, providers); err == nil {
+			t.Errorf("vault secret: %s should fail", source)
+		}
+	}
+
+	providers.Register(NewVaultSecretProvider(server.URL, "wrong-token"))
+	if _, err := newSecretSource("test", "vault:secret/data/beam#walletapipassword", providers); err == nil {
+		t.Errorf("vault secret with wrong token should fail")
+	}
+}
+
+func TestAWSKMSSecretProvider(t *testing.T) {
+
+	ciphertext := base64.StdEncoding.EncodeToString([]byte("kms-blob"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.Header.Get("X-Amz-Target") != "TrentService.Decrypt" ||
+			!strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(auth, "/us-east-1/kms/aws4_request") ||
+			!strings.Contains(auth, "SignedHeaders=content-type;host;x-amz-date;x-amz-target") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"InvalidSignatureException","message":"bad signature"}`))
+			return
+		}
+		var body [512]byte
+		n, _ := r.Body.Read(body[:])
+		if gjson.GetBytes(body[:n], "CiphertextBlob").String() != ciphertext {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"InvalidCiphertextException"}`))
+			return
+		}
+		w.Write([]byte(`{"KeyId":"key","Plaintext":"` + base64.StdEncoding.EncodeToString([]byte("from-kms")) + `"}`))
+	}))
+	defer server.Close()
+
+	kms := NewAWSKMSSecretProvider("us-east-1", server.URL)
+	kms.AccessKey, kms.SecretKey, kms.Token = "AKID", "secret", ""
+	providers := newSecretProviders()
+	providers.Register(kms)
+
+	s, err := newSecretSource("walletpassword", "awskms:"+ciphertext, providers)
+	if err != nil || s.String() != "from-kms" {
+		t.Errorf("aws kms secret = %v, %v", s, err)
+	}
+	if _, err = newSecretSource("walletpassword", "awskms:"+base64.StdEncoding.EncodeToString([]byte("other")), providers); err == nil {
+		t.Errorf("aws kms secret with invalid ciphertext should fail")
+	}
+
+	kms.AccessKey = ""
+	if _, err = newSecretSource("walletpassword", "awskms:"+ciphertext, providers); err == nil {
+		t.Errorf("aws kms secret without credentials should fail")
+	}
+}
+
+func TestSignAWSRequest(t *testing.T) {
+
+	//AWS SigV4测试集的get-vanilla
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	now, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+	signAWSRequest(req, nil, "us-east-1", "service", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", now)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("signAWSRequest = %s", got)
+	}
+}
+
+func TestSecretProviders_Register(t *testing.T) {
+
+	providers := newSecretProviders()
+	if s, err := newSecretSource("test", "vault:secret/data/beam#key", providers); err != nil || s.String() != "vault:secret/data/beam#key" {
+		t.Errorf("unregistered scheme should be plain text: %v, %v", s, err)
+	}
+	var nilProviders *SecretProviders
+	if s, err := newSecretSource("test", "env:PATH", nilProviders); err != nil || s.String() != "env:PATH" {
+		t.Errorf("nil providers should be plain text: %v, %v", s, err)
+	}
+}
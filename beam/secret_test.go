@@ -0,0 +1,192 @@
+package beam
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretSource(t *testing.T) {
+
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	ioutil.WriteFile(secretFile, []byte("  from-file\n"), 0600)
+	os.Setenv("BEAM_TEST_SECRET", "from-env")
+	defer os.Unsetenv("BEAM_TEST_SECRET")
+
+	tests := map[string]string{
+		"plain":                "plain",
+		"env:BEAM_TEST_SECRET": "from-env",
+		"file:" + secretFile:   "from-file",
+	}
+	for source, want := range tests {
+		s, err := newSecretSource("test", source, newSecretProviders())
+		if err != nil || s.String() != want {
+			t.Errorf("secret source: %s = %s, %v", source, s.String(), err)
+		}
+	}
+	if _, err := newSecretSource("test", "env:BEAM_TEST_SECRET_MISSING", newSecretProviders()); err == nil {
+		t.Errorf("missing environment variable should fail")
+	}
+	if _, err := newSecretSource("test", "file:"+filepath.Join(dir, "missing"), newSecretProviders()); err == nil {
+		t.Errorf("missing secret file should fail")
+	}
+}
+
+func TestReadSecret(t *testing.T) {
+
+	dir := t.TempDir()
+	seedFile := filepath.Join(dir, "seed")
+	ioutil.WriteFile(seedFile, []byte("word1 word2\n"), 0600)
+	openFile := filepath.Join(dir, "open")
+	ioutil.WriteFile(openFile, []byte("word1 word2\n"), 0644)
+	os.Setenv("BEAM_TEST_SEED", "word3")
+	defer os.Unsetenv("BEAM_TEST_SEED")
+
+	if value, err := ReadSecret("seed", "file:"+seedFile); err != nil || value != "word1 word2" {
+		t.Errorf("ReadSecret file = %s, %v", value, err)
+	}
+	if value, err := ReadSecret("seed", "env:BEAM_TEST_SEED"); err != nil || value != "word3" {
+		t.Errorf("ReadSecret env = %s, %v", value, err)
+	}
+	if _, err := ReadSecret("seed", "file:"+openFile); err == nil {
+		t.Errorf("ReadSecret should reject a file readable by other users")
+	}
+	if _, err := ReadSecret("seed", "word1 word2"); err == nil {
+		t.Errorf("ReadSecret should reject a plain value")
+	}
+}
+
+func TestWalletClient_ReloadAuth(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, _ := r.BasicAuth(); password != "rotated" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"current_height":100}}`))
+	}))
+	defer server.Close()
+
+	secretFile := filepath.Join(t.TempDir(), "password")
+	ioutil.WriteFile(secretFile, []byte("old"), 0600)
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.walletapiauth = WalletAPIAuth{User: "beam"}
+	wm.Config.walletapipassword, _ = newSecretSource("walletapipassword", "file:"+secretFile, newSecretProviders())
+	client := NewWalletClient(server.URL, server.URL, false)
+	auth, _ := wm.walletAPIAuth()
+	client.SetAuth(auth)
+	client.SetAuthSource(wm.walletAPIAuth)
+	wm.walletClient = client
+
+	if _, err := client.GetWalletStatus(context.Background()); err == nil {
+		t.Errorf("GetWalletStatus with the old password should fail")
+	}
+
+	//代理轮换密码后，401时重新读取并重试
+	ioutil.WriteFile(secretFile, []byte("rotated"), 0600)
+	status, err := client.GetWalletStatus(context.Background())
+	if err != nil || status.CurrentHeight != 100 {
+		t.Errorf("GetWalletStatus after rotation = %+v, %v", status, err)
+	}
+	if client.currentAuth().Password != "rotated" {
+		t.Errorf("wallet api auth is not reloaded")
+	}
+	if err = wm.ReloadWalletAPIAuth(); err != nil {
+		t.Errorf("ReloadWalletAPIAuth failed: %v", err)
+	}
+}
+
+func TestWalletManager_ChangeWalletPassword(t *testing.T) {
+
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	stdinFile := filepath.Join(dir, "stdin")
+	cli := filepath.Join(dir, "beam-wallet")
+	ioutil.WriteFile(cli, []byte("#!/bin/sh\necho \"$@\" > "+argsFile+"\ncat > "+stdinFile+"\n"), 0700)
+	passFile := filepath.Join(dir, "walletpass")
+	ioutil.WriteFile(passFile, []byte("old\n"), 0600)
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.walletcli = cli
+	wm.Config.walletdatafile = filepath.Join(dir, "wallet.db")
+	wm.Config.walletpassword, _ = newSecretSource("walletpassword", "file:"+passFile, newSecretProviders())
+
+	if err := wm.ChangeWalletPassword(context.Background(), "", "new"); err == nil {
+		t.Errorf("ChangeWalletPassword without wallet data file should fail")
+	}
+	ioutil.WriteFile(wm.Config.walletdatafile, []byte("wallet"), 0600)
+	if err := wm.ChangeWalletPassword(context.Background(), "", "old"); err == nil {
+		t.Errorf("ChangeWalletPassword to the same password should fail")
+	}
+	if err := wm.ChangeWalletPassword(context.Background(), "", "new"); err != nil {
+		t.Errorf("ChangeWalletPassword failed: %v", err)
+		return
+	}
+	args, _ := ioutil.ReadFile(argsFile)
+	if !strings.Contains(string(args), "change_password") || strings.Contains(string(args), "pass=") {
+		t.Errorf("wallet cli args = %s", args)
+	}
+	if stdin, _ := ioutil.ReadFile(stdinFile); string(stdin) != "old\nnew\nnew\n" {
+		t.Errorf("wallet cli stdin = %q", stdin)
+	}
+	if wm.Config.walletpassword.String() != "new" {
+		t.Errorf("wallet password file is not updated")
+	}
+}
+
+func encSecretProviders(key string) *SecretProviders {
+	providers := newSecretProviders()
+	providers.Register(&EncSecretProvider{Key: key})
+	return providers
+}
+
+func TestEncryptSecret(t *testing.T) {
+
+	if _, err := encryptSecret("password", ""); err == nil {
+		t.Errorf("encryptSecret without key should fail")
+	}
+
+	encrypted, err := encryptSecret("password", "ops-key")
+	if err != nil || !strings.HasPrefix(encrypted, "enc:") || strings.Contains(encrypted, "password") {
+		t.Errorf("encryptSecret = %s, %v", encrypted, err)
+		return
+	}
+	if again, _ := encryptSecret("password", "ops-key"); again == encrypted {
+		t.Errorf("encryptSecret should use a random salt")
+	}
+
+	s, err := newSecretSource("walletapipassword", encrypted, encSecretProviders("ops-key"))
+	if err != nil || s.String() != "password" {
+		t.Errorf("decrypted secret = %s, %v", s.String(), err)
+	}
+	if _, err = newSecretSource("walletapipassword", encrypted, encSecretProviders("wrong-key")); err == nil {
+		t.Errorf("decrypt with wrong key should fail")
+	}
+	if _, err = newSecretSource("walletapipassword", encrypted, encSecretProviders("")); err == nil {
+		t.Errorf("decrypt without key should fail")
+	}
+	if _, err = newSecretSource("walletapipassword", "enc:invalid", encSecretProviders("ops-key")); err == nil {
+		t.Errorf("decrypt invalid value should fail")
+	}
+
+	//密钥文件优先于环境变量
+	os.Setenv(SecretKeyEnv, "env-key")
+	defer os.Unsetenv(SecretKeyEnv)
+	if key, _ := loadSecretKey(""); key != "env-key" {
+		t.Errorf("loadSecretKey env = %s", key)
+	}
+	keyFile := filepath.Join(t.TempDir(), "key")
+	ioutil.WriteFile(keyFile, []byte("file-key\n"), 0600)
+	if key, _ := loadSecretKey(keyFile); key != "file-key" {
+		t.Errorf("loadSecretKey file = %s", key)
+	}
+}
@@ -0,0 +1,124 @@
+package beam
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	//tx_send发送失败的分类
+	SendFailureInsufficientFunds = "insufficient_funds" //可用余额或UTXO不足
+	SendFailureAddressExpired    = "address_expired"    //发送或接收地址已过期
+	SendFailureNodeOffline       = "node_offline"       //钱包API或节点不可用
+	SendFailureFeeTooLow         = "fee_too_low"        //手续费低于最低手续费
+	SendFailureUnknown           = "unknown"            //其他错误
+)
+
+//SendError 分类后的发送失败错误，Err为钱包API返回的原始错误
+type SendError struct {
+	Class string
+	TxID  string
+	To    string
+	Err   error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("send tx to: %s failed (%s): %v", e.To, e.Class, e.Err)
+}
+
+//SendFailureClass 发送失败的分类，不是发送失败错误时返回空
+func SendFailureClass(err error) string {
+	if e, ok := err.(*SendError); ok {
+		return e.Class
+	}
+	return ""
+}
+
+//sendErrorCause 发送失败错误的原始错误
+func sendErrorCause(err error) error {
+	if e, ok := err.(*SendError); ok {
+		return e.Err
+	}
+	return err
+}
+
+//classifySendError 按错误类型和钱包API返回的错误信息分类
+func classifySendError(err error) string {
+
+	if isRetryableError(err) || err == ErrCircuitOpen {
+		return SendFailureNodeOffline
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "not enough") || strings.Contains(msg, "insufficient") || strings.Contains(msg, "no money"):
+		return SendFailureInsufficientFunds
+	case strings.Contains(msg, "expired"):
+		return SendFailureAddressExpired
+	case strings.Contains(msg, "fee") && (strings.Contains(msg, "minimum") || strings.Contains(msg, "too low") || strings.Contains(msg, "less than")):
+		return SendFailureFeeTooLow
+	case strings.Contains(msg, "not connected") || strings.Contains(msg, "node is offline") || strings.Contains(msg, "no connection"):
+		return SendFailureNodeOffline
+	}
+	return SendFailureUnknown
+}
+
+//SendFailureEvent 发送失败事件
+type SendFailureEvent struct {
+	Class   string `json:"class"`
+	TxID    string `json:"txid"`
+	Sid     string `json:"sid"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	AssetID int64  `json:"assetId"`
+	Value   uint64 `json:"value"`
+	Fee     uint64 `json:"fee"`
+	Comment string `json:"comment"`
+	Error   string `json:"error"`
+	Time    int64  `json:"time"`
+}
+
+//SendFailureHandler 发送失败的处理
+type SendFailureHandler func(event *SendFailureEvent)
+
+//AddSendFailureHandler 添加指定分类的发送失败处理，class为空时处理全部分类，需要在启动提现相关任务前添加
+func (wm *WalletManager) AddSendFailureHandler(class string, handler SendFailureHandler) {
+	wm.sendFailureHandlers[class] = append(wm.sendFailureHandlers[class], handler)
+}
+
+//sendFailed 发送失败时分类错误，记录日志并通知对应分类的处理
+func (wm *WalletManager) sendFailed(err error, from, to string, assetID int64, withdrawal *WithdrawalAmount, comment, txID, sid string) error {
+
+	sendErr := &SendError{
+		Class: classifySendError(err),
+		TxID:  txID,
+		To:    to,
+		Err:   err,
+	}
+
+	event := &SendFailureEvent{
+		Class:   sendErr.Class,
+		TxID:    txID,
+		Sid:     sid,
+		From:    from,
+		To:      to,
+		AssetID: assetID,
+		Value:   withdrawal.Send,
+		Fee:     withdrawal.Fee,
+		Comment: comment,
+		Error:   err.Error(),
+		Time:    time.Now().Unix(),
+	}
+
+	wm.Log.Warningf("send tx: %s to: %s failed, class: %s, unexpected error: %v", txID, to, event.Class, err)
+
+	for _, handler := range wm.sendFailureHandlers[event.Class] {
+		handler(event)
+	}
+	for _, handler := range wm.sendFailureHandlers[""] {
+		handler(event)
+	}
+
+	return sendErr
+}
@@ -0,0 +1,83 @@
+package beam
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClassifySendError(t *testing.T) {
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errors.New("[-32603]Failed to send transaction. Not enough funds"), SendFailureInsufficientFunds},
+		{errors.New("[-32602]Address is expired"), SendFailureAddressExpired},
+		{errors.New("[-32602]Failed to initiate the send operation. The minimum fee is 100 GROTH."), SendFailureFeeTooLow},
+		{errors.New("[-32603]wallet is not connected to the node"), SendFailureNodeOffline},
+		{io.EOF, SendFailureNodeOffline},
+		{ErrCircuitOpen, SendFailureNodeOffline},
+		{&rpcStatusError{code: 502, status: "Bad Gateway"}, SendFailureNodeOffline},
+		{errors.New("[-32003]Invalid address."), SendFailureUnknown},
+	}
+	for _, test := range tests {
+		if got := classifySendError(test.err); got != test.want {
+			t.Errorf("classifySendError(%v) = %s, want %s", test.err, got, test.want)
+		}
+	}
+}
+
+func TestWalletManager_SendFailureHandler(t *testing.T) {
+
+	var message string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"` + message + `"}}`))
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	var (
+		funds []*SendFailureEvent
+		all   []*SendFailureEvent
+	)
+	wm.AddSendFailureHandler(SendFailureInsufficientFunds, func(event *SendFailureEvent) {
+		funds = append(funds, event)
+	})
+	wm.AddSendFailureHandler("", func(event *SendFailureEvent) {
+		all = append(all, event)
+	})
+
+	withdrawal := &WithdrawalAmount{Send: 1000, Fee: 100}
+
+	message = "Not enough funds"
+	_, err := wm.sendWithdrawal("from", "to", withdrawal, "memo", "tx1", "order-1", false)
+	if SendFailureClass(err) != SendFailureInsufficientFunds || isResendableError(err) {
+		t.Errorf("sendWithdrawal err = %v, class = %s", err, SendFailureClass(err))
+	}
+	if len(funds) != 1 || funds[0].TxID != "tx1" || funds[0].Sid != "order-1" || funds[0].Value != 1000 || funds[0].Fee != 100 {
+		t.Errorf("insufficient funds events = %+v", funds)
+	}
+
+	message = "The minimum fee is 100 GROTH"
+	_, err = wm.sendWithdrawal("from", "to", withdrawal, "", "tx2", "", false)
+	if SendFailureClass(err) != SendFailureFeeTooLow {
+		t.Errorf("sendWithdrawal err = %v, class = %s", err, SendFailureClass(err))
+	}
+	if len(funds) != 1 || len(all) != 2 || all[1].Class != SendFailureFeeTooLow {
+		t.Errorf("events = %+v, %+v", funds, all)
+	}
+
+	//熔断的错误仍可重发
+	if !isResendableError(&SendError{Class: SendFailureNodeOffline, Err: ErrCircuitOpen}) {
+		t.Errorf("circuit open send error should be resendable")
+	}
+	if SendFailureClass(errors.New("other")) != "" {
+		t.Errorf("SendFailureClass of other error should be empty")
+	}
+}
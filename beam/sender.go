@@ -0,0 +1,85 @@
+package beam
+
+import (
+	"context"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+//SenderAddress 提现使用的发送地址，from为空时使用withdrawfrom配置，都为空时使用钱包的第一个不是充值地址的地址，
+//全部是充值地址时创建一个发送地址；指定的地址必须是钱包自己的、未过期的普通地址，且不能是充值地址，使用后标记为发送地址。
+//beam钱包的所有地址共用同一个UTXO池，发送地址只决定接收方看到的对方地址，不能隔离资金
+func (wm *WalletManager) SenderAddress(from string) (string, error) {
+
+	addresses, err := wm.walletClient.GetAddressList(context.Background())
+	if err != nil {
+		return "", err
+	}
+	if len(addresses) == 0 {
+		return "", openwallet.Errorf(openwallet.ErrAccountNotAddress, "wallet address is not created")
+	}
+
+	purposes, err := wm.addressBook.purposes()
+	if err != nil {
+		return "", err
+	}
+
+	if len(from) == 0 {
+		from = wm.Config.withdrawfrom
+	}
+	if len(from) == 0 {
+		for _, address := range addresses {
+			if purposes[address] != AddressPurposeDeposit {
+				wm.markSendAddress(address, purposes)
+				return address, nil
+			}
+		}
+		return wm.createSendAddress()
+	}
+
+	for _, address := range addresses {
+		if address != from {
+			continue
+		}
+		if purposes[from] == AddressPurposeDeposit {
+			return "", openwallet.Errorf(openwallet.ErrAccountNotAddress, "sender address: %s is a deposit address", from)
+		}
+		wm.markSendAddress(from, purposes)
+		return from, nil
+	}
+	return "", openwallet.Errorf(openwallet.ErrAccountNotAddress, "sender address: %s is not a sendable address of the wallet", from)
+}
+
+//markSendAddress 未分类的地址作为发送地址使用后标记为发送地址，标记失败不影响发送，记录日志
+func (wm *WalletManager) markSendAddress(address string, purposes map[string]string) {
+	if len(purposes[address]) > 0 {
+		return
+	}
+	if err := wm.addressBook.SetPurpose(address, AddressPurposeSend); err != nil {
+		wm.Log.Warningf("mark address: %s as send address failed, unexpected error: %v", address, err)
+	}
+}
+
+//createSendAddress 钱包的地址全部是充值地址时，创建永不过期的发送地址
+func (wm *WalletManager) createSendAddress() (string, error) {
+
+	address, err := wm.CreateAddress(AddressTypeRegular, AddressExpirationNever, "")
+	if err != nil {
+		return "", err
+	}
+	if err = wm.addressBook.SetPurpose(address, AddressPurposeSend); err != nil {
+		return "", err
+	}
+
+	wm.Log.Infof("all wallet addresses are deposit addresses, create send address: %s", address)
+	return address, nil
+}
+
+//rawTxSender 交易单扩展参数中指定的发送地址：{"from": "..."}
+func rawTxSender(rawTx *openwallet.RawTransaction) string {
+	if len(rawTx.ExtParam) == 0 {
+		return ""
+	}
+	return gjson.Get(rawTx.ExtParam, "from").String()
+}
@@ -0,0 +1,119 @@
+package beam
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_SenderAddress(t *testing.T) {
+
+	var from string
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list": rpcResult(`[{"address":"ops","own":true,"comment":"self"},{"address":"hot","own":true,"comment":"self"},` +
+			`{"address":"old","own":true,"comment":"self","expired":true}]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false,"type":"regular"}`),
+		"wallet_status":    rpcResult(`{"available":100000000}`),
+		"generate_tx_id":   rpcResult(`"tx1"`),
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			from = params["from"].(string)
+			return fmt.Sprintf(`{"txId":"%s"}`, params["txId"]), nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if address, err := wm.SenderAddress(""); err != nil || address != "ops" {
+		t.Errorf("SenderAddress default = %s, %v", address, err)
+	}
+	wm.Config.withdrawfrom = "hot"
+	if address, err := wm.SenderAddress(""); err != nil || address != "hot" {
+		t.Errorf("SenderAddress withdrawfrom = %s, %v", address, err)
+	}
+	if address, err := wm.SenderAddress("ops"); err != nil || address != "ops" {
+		t.Errorf("SenderAddress ops = %s, %v", address, err)
+	}
+	//过期地址和其他钱包的地址不能作为发送地址
+	for _, address := range []string{"old", "other"} {
+		if _, err := wm.SenderAddress(address); err == nil {
+			t.Errorf("SenderAddress(%s) should fail", address)
+		}
+	}
+
+	//交易单扩展参数指定发送地址
+	decoder := NewTransactionDecoder(wm)
+	rawTx := &openwallet.RawTransaction{
+		Coin:     openwallet.Coin{Symbol: Symbol},
+		To:       map[string]string{"to": "0.00001"},
+		ExtParam: `{"from":"ops"}`,
+	}
+	if _, err := decoder.SubmitRawTransaction(nil, rawTx); err != nil || from != "ops" {
+		t.Errorf("SubmitRawTransaction from = %s, %v", from, err)
+	}
+	rawTx.ExtParam = `{"from":"other"}`
+	if _, err := decoder.SubmitRawTransaction(nil, rawTx); err == nil {
+		t.Errorf("SubmitRawTransaction from other wallet address should fail")
+	}
+
+	//提现队列指定发送地址，发送地址不同时幂等键冲突
+	q := wm.WithdrawalQueue()
+	if _, err := q.EnqueueFrom("order-1", "ops", "to", 1000, 100, ""); err != nil {
+		t.Errorf("EnqueueFrom unexpected error: %v", err)
+	}
+	if _, err := q.EnqueueFrom("order-1", "hot", "to", 1000, 100, ""); err != ErrWithdrawalKeyConflict {
+		t.Errorf("EnqueueFrom with other sender = %v, want ErrWithdrawalKeyConflict", err)
+	}
+	if _, err := q.EnqueueFrom("order-2", "other", "to", 1000, 100, ""); err == nil {
+		t.Errorf("EnqueueFrom from other wallet address should fail")
+	}
+	q.Process()
+	if from != "ops" {
+		t.Errorf("queued withdrawal from = %s, want ops", from)
+	}
+}
+
+func TestWalletManager_SenderAddressPurpose(t *testing.T) {
+
+	created := 0
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list": rpcResult(`[{"address":"deposit1","own":true,"comment":"self"},{"address":"ops","own":true,"comment":"self"}]`),
+		"create_address": func(map[string]interface{}) (string, error) {
+			created++
+			return `"send1"`, nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	wm.AddressBook().addCreated(AddressTypeRegular, DefaultAddressComment, "deposit1")
+
+	//充值地址不能作为发送地址，默认跳过充值地址
+	if _, err := wm.SenderAddress("deposit1"); err == nil {
+		t.Errorf("SenderAddress(deposit1) should fail")
+	}
+	if address, err := wm.SenderAddress(""); err != nil || address != "ops" {
+		t.Errorf("SenderAddress default = %s, %v", address, err)
+	}
+	if entry, err := wm.AddressBook().Get("ops"); err != nil || entry.Purpose != AddressPurposeSend {
+		t.Errorf("ops entry = %+v, %v", entry, err)
+	}
+
+	//全部是充值地址时创建发送地址
+	wm.AddressBook().SetPurpose("ops", AddressPurposeDeposit)
+	if address, err := wm.SenderAddress(""); err != nil || address != "send1" || created != 1 {
+		t.Errorf("SenderAddress without send address = %s, %v, created = %d", address, err, created)
+	}
+	if entry, err := wm.AddressBook().Get("send1"); err != nil || entry.Purpose != AddressPurposeSend {
+		t.Errorf("send1 entry = %+v, %v", entry, err)
+	}
+	if err := wm.AddressBook().SetPurpose("ops", "change"); err == nil {
+		t.Errorf("SetPurpose with unknown purpose should fail")
+	}
+}
@@ -1,6 +1,7 @@
 package beam
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/blocktree/openwallet/log"
 	"github.com/blocktree/openwallet/openwallet"
@@ -41,6 +42,7 @@ func NewServer(wm *WalletManager) (*Server, error) {
 	node.HandleFunc("getTransactionsByHeight", t.getTransactionsByHeight)
 	node.HandleFunc("getTransaction", t.getTransaction)
 	node.HandleFunc("createBatchAddress", t.createBatchAddress)
+	node.HandleFunc("createAddressForAccount", t.createAddressForAccount)
 	node.HandleFunc("getWalletBalance", t.getWalletBalance)
 	node.HandleFunc("getWalletAddress", t.getWalletAddress)
 	node.HandleFunc("getBlockByHeight", t.getBlockByHeight)
@@ -138,7 +140,7 @@ func (server *Server) getTransactionsByHeight(ctx *owtp.Context) {
 	}
 
 	height := ctx.Params().Get("height").Uint()
-	txs, err := server.wm.walletClient.GetTransactionsByHeight(height)
+	txs, err := server.wm.walletClient.GetTransactionsByHeight(context.Background(), height)
 	if err != nil {
 		ctx.Response(nil, owtp.ErrCustomError, err.Error())
 		return
@@ -159,7 +161,7 @@ func (server *Server) getTransaction(ctx *owtp.Context) {
 	}
 
 	txid := ctx.Params().Get("txid").String()
-	tx, err := server.wm.walletClient.GetTransaction(txid)
+	tx, err := server.wm.walletClient.GetTransaction(context.Background(), txid)
 	if err != nil {
 		ctx.Response(nil, owtp.ErrCustomError, err.Error())
 		return
@@ -194,6 +196,29 @@ func (server *Server) createBatchAddress(ctx *owtp.Context) {
 	server.wm.Log.Infof("---------------------------------------")
 }
 
+func (server *Server) createAddressForAccount(ctx *owtp.Context) {
+
+	if !server.checkTrustNode(ctx.PID) {
+		ctx.Response(nil, owtp.ErrDenialOfService, "the node is not trusted")
+		return
+	}
+
+	accountID := ctx.Params().Get("accountID").String()
+	index := ctx.Params().Get("index").Uint()
+	server.wm.Log.Infof("Client call [createAddressForAccount]")
+	server.wm.Log.Infof("accountID: %s, index: %d", accountID, index)
+
+	address, err := server.wm.CreateAddressForAccount(accountID, index)
+	if err != nil {
+		ctx.Response(nil, owtp.ErrCustomError, err.Error())
+		return
+	}
+
+	ctx.Response(map[string]string{"address": address}, owtp.StatusSuccess, "success")
+
+	server.wm.Log.Infof("---------------------------------------")
+}
+
 func (server *Server) getWalletBalance(ctx *owtp.Context) {
 	server.wm.Log.Infof("Client call [getWalletBalance]")
 
@@ -247,7 +272,7 @@ func (server *Server) getBlockByHeight(ctx *owtp.Context) {
 	}
 
 	height := ctx.Params().Get("height").Uint()
-	block, err := server.wm.walletClient.GetBlockByHeight(height)
+	block, err := server.wm.walletClient.GetBlockByHeight(context.Background(), height)
 	if err != nil {
 		ctx.Response(nil, owtp.ErrCustomError, err.Error())
 		return
@@ -267,7 +292,7 @@ func (server *Server) GetWalletStatus(ctx *owtp.Context) {
 		return
 	}
 
-	wallet, err := server.wm.walletClient.GetWalletStatus()
+	wallet, err := server.wm.walletClient.GetWalletStatus(context.Background())
 	if err != nil {
 		ctx.Response(nil, owtp.ErrCustomError, err.Error())
 		return
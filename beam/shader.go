@@ -0,0 +1,248 @@
+package beam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+//ContractInvokeResult invoke_contract返回的结果
+type ContractInvokeResult struct {
+	Output  string //shader输出的json
+	TxID    string //shader生成的合约交易ID，create_tx为true时返回
+	RawData []byte //shader生成的合约交易数据，create_tx为false时返回，通过process_invoke_data创建交易
+
+	/*
+		{
+		    "output": "{\"contracts\": [{\"cid\": \"8a2c...\", \"Height\": 2140}]}",
+		    "txid": "f2d4a5e0a25e4b5c9d8f8de0b7a4a1f3",
+		    "raw_data": [2, 0, 0, 0]
+		}
+	*/
+}
+
+func NewContractInvokeResult(result *gjson.Result) *ContractInvokeResult {
+	obj := ContractInvokeResult{}
+	obj.Output = result.Get("output").String()
+	obj.TxID = result.Get("txid").String()
+	if raw := result.Get("raw_data"); raw.IsArray() {
+		for _, b := range raw.Array() {
+			obj.RawData = append(obj.RawData, byte(b.Uint()))
+		}
+	}
+	return &obj
+}
+
+//Result 解析shader输出的json
+func (r *ContractInvokeResult) Result() gjson.Result {
+	return gjson.Parse(r.Output)
+}
+
+//bytesToArray 钱包API的字节数组参数为数字数组
+func bytesToArray(data []byte) []int {
+	array := make([]int, len(data))
+	for i, b := range data {
+		array[i] = int(b)
+	}
+	return array
+}
+
+//InvokeContract 调用shader，contract为shader字节码，为空时使用钱包API主机上的contractFile，
+//args为逗号分隔的key=value参数，createTx为false时只返回交易数据不创建交易
+func (c *WalletClient) InvokeContract(ctx context.Context, contract []byte, contractFile, args string, createTx bool) (*ContractInvokeResult, error) {
+
+	request := map[string]interface{}{
+		"args":      args,
+		"create_tx": createTx,
+	}
+	if len(contract) > 0 {
+		request["contract"] = bytesToArray(contract)
+	} else if len(contractFile) > 0 {
+		request["contract_file"] = contractFile
+	}
+
+	r, err := c.callContext(ctx, "invoke_contract", request)
+	if err != nil {
+		return nil, err
+	}
+	return NewContractInvokeResult(r), nil
+}
+
+//ProcessInvokeData 使用invoke_contract返回的交易数据创建合约交易
+func (c *WalletClient) ProcessInvokeData(ctx context.Context, data []byte) (string, error) {
+
+	request := map[string]interface{}{
+		"data": bytesToArray(data),
+	}
+
+	r, err := c.callContext(ctx, "process_invoke_data", request)
+	if err != nil {
+		return "", err
+	}
+	return r.Get("txid").String(), nil
+}
+
+//ShaderCall 调用shader的参数
+type ShaderCall struct {
+	Contract     []byte            //shader字节码
+	ContractFile string            //钱包API主机上的shader文件路径，Contract为空时使用
+	Args         map[string]string //调用参数，如role、action、cid，为空时shader返回方法说明
+}
+
+//args 调用参数按key排序后格式化为key=value,key=value
+func (call *ShaderCall) args() string {
+	keys := make([]string, 0, len(call.Args))
+	for k := range call.Args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := make([]string, 0, len(keys))
+	for _, k := range keys {
+		args = append(args, k+"="+call.Args[k])
+	}
+	return strings.Join(args, ",")
+}
+
+//checkShaders 检查是否支持调用shader，需要开启shaders，钱包API版本不低于v6.1
+func (wm *WalletManager) checkShaders(call *ShaderCall) error {
+
+	if len(call.Contract) == 0 && len(call.ContractFile) == 0 {
+		return fmt.Errorf("shader contract is empty")
+	}
+	return wm.checkShaderAPI()
+}
+
+//checkShaderAPI 检查是否开启shaders，钱包API版本不低于v6.1
+func (wm *WalletManager) checkShaderAPI() error {
+
+	if !wm.IsFeatureEnabled(FeatureShaders) {
+		return fmt.Errorf("invoke contract requires feature shaders")
+	}
+	if c, ok := wm.httpWalletClient(); ok {
+		if v := c.WalletAPIVersion(); v != nil && v.Before(6, 1) {
+			return fmt.Errorf("invoke contract is not supported by wallet api v%s", v)
+		}
+	}
+	return nil
+}
+
+//CallShader 调用shader的只读方法，不创建交易，shader生成的交易数据在RawData返回
+func (wm *WalletManager) CallShader(call *ShaderCall) (*ContractInvokeResult, error) {
+
+	err := wm.checkShaders(call)
+	if err != nil {
+		return nil, err
+	}
+
+	return wm.walletClient.InvokeContract(context.Background(), call.Contract, call.ContractFile, call.args(), false)
+}
+
+//InvokeShader 调用shader并由钱包创建合约交易，合约交易会花费钱包余额，受处理中提现数量限制
+func (wm *WalletManager) InvokeShader(call *ShaderCall) (*ContractInvokeResult, error) {
+
+	err := wm.checkShaders(call)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := wm.withdrawals.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return wm.walletClient.InvokeContract(context.Background(), call.Contract, call.ContractFile, call.args(), true)
+}
+
+//ProcessShaderData 用CallShader返回的RawData由钱包创建合约交易，返回交易ID，与InvokeShader一样受处理中提现数量限制
+func (wm *WalletManager) ProcessShaderData(data []byte) (string, error) {
+
+	if len(data) == 0 {
+		return "", fmt.Errorf("shader invoke data is empty")
+	}
+	err := wm.checkShaderAPI()
+	if err != nil {
+		return "", err
+	}
+
+	release, err := wm.withdrawals.Acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	return wm.walletClient.ProcessInvokeData(context.Background(), data)
+}
+
+//ShaderABI 本地登记的shader方法说明，合约地址为合约ID
+type ShaderABI struct {
+	Address    string `storm:"id"`
+	ABI        string //shader不带参数调用时输出的方法说明json
+	UpdateTime int64
+}
+
+func (decoder *ContractDecoder) openDB() (*storm.DB, error) {
+	return storm.Open(filepath.Join(decoder.wm.Config.dbPath, decoder.wm.Config.BlockchainFile))
+}
+
+//GetABIInfo 查询本地登记的shader方法说明
+func (decoder *ContractDecoder) GetABIInfo(address string) (*openwallet.ABIInfo, error) {
+
+	db, err := decoder.openDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var record ShaderABI
+	err = db.One("Address", address, &record)
+	if err != nil {
+		return nil, fmt.Errorf("contract: %s abi not found", address)
+	}
+
+	return &openwallet.ABIInfo{Address: record.Address, ABI: gjson.Parse(record.ABI).Value()}, nil
+}
+
+//SetABIInfo 登记shader方法说明，abi为json字符串或可序列化为json的结构
+func (decoder *ContractDecoder) SetABIInfo(address string, abi openwallet.ABIInfo) error {
+
+	if len(address) == 0 {
+		return fmt.Errorf("contract address is empty")
+	}
+
+	record := &ShaderABI{
+		Address:    address,
+		UpdateTime: time.Now().Unix(),
+	}
+	switch v := abi.ABI.(type) {
+	case string:
+		record.ABI = v
+	case []byte:
+		record.ABI = string(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		record.ABI = string(data)
+	}
+	if !gjson.Valid(record.ABI) {
+		return fmt.Errorf("contract: %s abi is not valid json", address)
+	}
+
+	db, err := decoder.openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Save(record)
+}
@@ -0,0 +1,135 @@
+package beam
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestWalletManager_InvokeShader(t *testing.T) {
+
+	var params map[string]interface{}
+	server := newRPCTestServer(rpcTestMethods{
+		"invoke_contract": func(p map[string]interface{}) (string, error) {
+			params = p
+			if p["create_tx"] == true {
+				return `{"output":"{}","txid":"contract-tx"}`, nil
+			}
+			return `{"output":"{\"contracts\":[{\"cid\":\"c1\"}]}","raw_data":[1,2,255]}`, nil
+		},
+		"process_invoke_data": func(p map[string]interface{}) (string, error) {
+			params = p
+			return `{"txid":"processed-tx"}`, nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	call := &ShaderCall{
+		Contract: []byte{0, 97, 115},
+		Args:     map[string]string{"role": "manager", "action": "view"},
+	}
+
+	//shader调用需要开启shaders
+	if _, err := wm.CallShader(call); err == nil {
+		t.Errorf("CallShader should fail before shaders enabled")
+	}
+	wm.Config.features[FeatureShaders] = true
+
+	result, err := wm.CallShader(call)
+	if err != nil {
+		t.Errorf("CallShader unexpected error: %v", err)
+		return
+	}
+	if params["args"] != "action=view,role=manager" || params["create_tx"] != false || len(params["contract"].([]interface{})) != 3 {
+		t.Errorf("invoke_contract params = %v", params)
+	}
+	if result.Result().Get("contracts.0.cid").String() != "c1" || len(result.RawData) != 3 || result.RawData[2] != 255 {
+		t.Errorf("CallShader result = %+v", result)
+	}
+
+	result, err = wm.InvokeShader(&ShaderCall{ContractFile: "shaders/app.wasm", Args: map[string]string{"role": "user"}})
+	if err != nil || result.TxID != "contract-tx" || params["contract_file"] != "shaders/app.wasm" {
+		t.Errorf("InvokeShader = %+v, err = %v, params = %v", result, err, params)
+	}
+
+	if _, err := wm.InvokeShader(&ShaderCall{}); err == nil {
+		t.Errorf("InvokeShader should fail without contract")
+	}
+
+	txid, err := wm.ProcessShaderData([]byte{1, 2, 255})
+	if err != nil || txid != "processed-tx" || len(params["data"].([]interface{})) != 3 {
+		t.Errorf("ProcessShaderData = %s, err = %v, params = %v", txid, err, params)
+	}
+	if _, err := wm.ProcessShaderData(nil); err == nil {
+		t.Errorf("ProcessShaderData should fail without data")
+	}
+
+	//旧版钱包API不支持shader调用
+	c, _ := wm.httpWalletClient()
+	c.SetWalletAPIVersion(&WalletAPIVersion{Major: 6, Minor: 0})
+	if _, err := wm.CallShader(call); err == nil {
+		t.Errorf("CallShader should fail on wallet api v6.0")
+	}
+}
+
+func TestContractDecoder_ABIInfo(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	decoder := NewContractDecoder(wm)
+
+	if _, err := decoder.GetABIInfo("c1"); err == nil {
+		t.Errorf("GetABIInfo should fail before set")
+	}
+	if err := decoder.SetABIInfo("c1", openwallet.ABIInfo{ABI: "not json"}); err == nil {
+		t.Errorf("SetABIInfo should fail for invalid json")
+	}
+	err := decoder.SetABIInfo("c1", openwallet.ABIInfo{ABI: map[string]interface{}{"roles": []string{"manager"}}})
+	if err != nil {
+		t.Errorf("SetABIInfo unexpected error: %v", err)
+		return
+	}
+	abi, err := decoder.GetABIInfo("c1")
+	if err != nil || abi.Address != "c1" {
+		t.Errorf("GetABIInfo = %+v, err = %v", abi, err)
+		return
+	}
+	if roles, ok := abi.ABI.(map[string]interface{})["roles"].([]interface{}); !ok || roles[0] != "manager" {
+		t.Errorf("GetABIInfo abi = %v", abi.ABI)
+	}
+}
+
+func TestExtractTransaction_Contract(t *testing.T) {
+
+	result := gjson.Parse(`{"txId":"t1","tx_type":12,"value":100,"fee":100,"invoke_data":[{"contract_id":"c1"}]}`)
+	tx := NewTransaction(&result)
+	if len(tx.ContractIDs) != 1 || tx.ContractIDs[0] != "c1" {
+		t.Errorf("ContractIDs = %v", tx.ContractIDs)
+	}
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.features[FeatureShaders] = true
+	scanner := NewBEAMBlockScanner(wm)
+	tx.BlockHeight = 10
+
+	extract := scanner.ExtractTransaction(context.Background(), tx.BlockHeight, "h10", tx, func(target openwallet.ScanTarget) (string, bool) {
+		if target.Address == "c1" {
+			return "dapp", true
+		}
+		return "", false
+	})
+	data := extract.extractData["dapp"]
+	if !extract.Success || len(data) != 1 {
+		t.Errorf("extract data = %v", extract.extractData)
+		return
+	}
+	if ids, _ := data[0].Transaction.GetExtParam().Get("contract_ids").Value().([]interface{}); len(ids) != 1 || ids[0] != "c1" {
+		t.Errorf("contract_ids ext param = %v", data[0].Transaction.ExtParam)
+	}
+}
@@ -0,0 +1,58 @@
+package beam
+
+import (
+	"fmt"
+
+	"github.com/blocktree/openwallet/common"
+)
+
+const (
+	//汇总手续费的支付方式
+	SummaryFeeFromAmount  = "amount"  //从汇总金额中扣除
+	SummaryFeeFromReserve = "reserve" //从summaryfeereserve预留的余额支付，汇总地址收到完整金额
+)
+
+//summaryMinBalance 汇总后热钱包至少保留的余额
+func (wm *WalletManager) summaryMinBalance() uint64 {
+	return common.StringNumToBigIntWithExp(wm.Config.summaryminbalance, wm.Decimal()).Uint64()
+}
+
+//summaryFeeReserve 按reserve方式支付手续费时预留的余额
+func (wm *WalletManager) summaryFeeReserve() uint64 {
+	if wm.Config.summaryfeefrom != SummaryFeeFromReserve {
+		return 0
+	}
+	return common.StringNumToBigIntWithExp(wm.Config.summaryfeereserve, wm.Decimal()).Uint64()
+}
+
+//summarySweepAmount 计算可汇总的到账金额：余额先保留summaryminbalance和retained中较大的一个，
+//fee从汇总金额扣除或由预留的手续费余额支付；余额不超过保留金额时返回0，不足以支付手续费时返回错误
+func (wm *WalletManager) summarySweepAmount(available, retained, fee uint64) (uint64, error) {
+
+	keep := wm.summaryMinBalance()
+	if retained > keep {
+		keep = retained
+	}
+	if available <= keep {
+		wm.Log.Infof("Summary balance: %d not above min balance: %d, skip", available, keep)
+		return 0, nil
+	}
+	amount := available - keep
+
+	switch wm.Config.summaryfeefrom {
+	case SummaryFeeFromReserve:
+		reserve := wm.summaryFeeReserve()
+		if reserve < fee {
+			return 0, fmt.Errorf("summary fee reserve: %d not enough to pay fee: %d", reserve, fee)
+		}
+		if amount <= reserve {
+			return 0, fmt.Errorf("summary amount: %d not enough to keep fee reserve: %d", amount, reserve)
+		}
+		return amount - reserve, nil
+	default:
+		if amount <= fee {
+			return 0, fmt.Errorf("summary amount: %d not enough to pay fee: %d", amount, fee)
+		}
+		return amount - fee, nil
+	}
+}
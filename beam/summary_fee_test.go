@@ -0,0 +1,76 @@
+package beam
+
+import (
+	"testing"
+)
+
+func TestSummarySweepAmount(t *testing.T) {
+
+	wm := NewWalletManager()
+
+	tests := []struct {
+		minBalance string
+		feeFrom    string
+		feeReserve string
+		available  uint64
+		retained   uint64
+		fee        uint64
+		want       uint64
+		wantErr    bool
+	}{
+		{"", SummaryFeeFromAmount, "", 1000, 0, 100, 900, false},
+		{"0.000005", SummaryFeeFromAmount, "", 1000, 0, 100, 400, false},
+		{"0.000005", SummaryFeeFromAmount, "", 1000, 700, 100, 200, false},
+		{"0.00001", SummaryFeeFromAmount, "", 1000, 0, 100, 0, false},
+		{"0.000009", SummaryFeeFromAmount, "", 1000, 0, 100, 0, true},
+		{"0.000005", SummaryFeeFromReserve, "0.000002", 1000, 0, 100, 300, false},
+		{"0.000005", SummaryFeeFromReserve, "0.0000005", 1000, 0, 100, 0, true},
+		{"0.000005", SummaryFeeFromReserve, "0.000006", 1000, 0, 100, 0, true},
+	}
+
+	for i, test := range tests {
+		wm.Config.summaryminbalance = test.minBalance
+		wm.Config.summaryfeefrom = test.feeFrom
+		wm.Config.summaryfeereserve = test.feeReserve
+		amount, err := wm.summarySweepAmount(test.available, test.retained, test.fee)
+		if amount != test.want || (err != nil) != test.wantErr {
+			t.Errorf("case %d: summarySweepAmount = %d, err = %v, want %d", i, amount, err, test.want)
+		}
+	}
+}
+
+func TestPlanSummarySplitMinBalance(t *testing.T) {
+
+	server := newRPCTestServer(rpcTestMethods{
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false,"type":"regular"}`),
+		"calc_change":      rpcResult(`{"change":0,"explicit_fee":0}`),
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	wm.Config.summaryminbalance = "0.5"
+	fee, _ := wm.EstimateFee(150000000, []string{"cold"})
+	targets, _ := parseSummarySplit("cold:80%,ops:20%", 8)
+
+	results, err := wm.planSummarySplit(150000000, targets)
+	if err != nil {
+		t.Fatalf("planSummarySplit unexpected error: %v", err)
+	}
+	rest := 100000000 - 2*fee
+	if results[0].Amount != rest*80/100 || results[1].Amount != rest-rest*80/100 || results[0].Fee != fee {
+		t.Errorf("amounts = %d, %d, fee = %d", results[0].Amount, results[1].Amount, results[0].Fee)
+	}
+
+	//手续费由预留的余额支付，目标按比例分配扣除保留和预留后的余额
+	wm.Config.summaryfeefrom = SummaryFeeFromReserve
+	wm.Config.summaryfeereserve = "0.01"
+	results, err = wm.planSummarySplit(150000000, targets)
+	if err != nil || results[0].Amount != 79200000 || results[1].Amount != 19800000 {
+		t.Errorf("reserve fee amounts = %v, err = %v", results, err)
+	}
+
+	if _, err = wm.planSummarySplit(40000000, targets); err == nil {
+		t.Errorf("balance below min balance should fail")
+	}
+}
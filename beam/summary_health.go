@@ -0,0 +1,96 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	//汇总跳过原因
+	SummarySkipNodeUnavailable    = "node_unavailable"    //钱包节点或浏览器不可用
+	SummarySkipNodeLag            = "node_lag"            //钱包节点同步落后
+	SummarySkipScannerLag         = "scanner_lag"         //区块扫描落后
+	SummarySkipPendingWithdrawals = "pending_withdrawals" //处理中的提现过多
+	SummarySkipMinInterval        = "min_interval"        //距离上一次汇总不足summarymininterval
+)
+
+//SummarySkippedEvent 汇总任务因系统状态不佳被推迟
+type SummarySkippedEvent struct {
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+	Time   int64  `json:"time"`
+}
+
+//SummarySkippedHandler 汇总跳过事件处理
+type SummarySkippedHandler func(event *SummarySkippedEvent)
+
+//AddSummarySkippedHandler 添加汇总跳过事件处理，需要在启动汇总任务前添加
+func (wm *WalletManager) AddSummarySkippedHandler(handler SummarySkippedHandler) {
+	wm.summarySkippedHandlers = append(wm.summarySkippedHandlers, handler)
+}
+
+//CheckSummaryHealth 检查汇总间隔、节点状态、扫描进度和处理中的提现，返回nil表示可以执行汇总
+func (wm *WalletManager) CheckSummaryHealth() *SummarySkippedEvent {
+
+	if event := wm.checkSummaryInterval(); event != nil {
+		return event
+	}
+
+	status, err := wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return newSummarySkippedEvent(SummarySkipNodeUnavailable, "get wallet status failed: %v", err)
+	}
+
+	chain, err := wm.walletClient.GetBlockchainInfo(context.Background())
+	if err != nil {
+		return newSummarySkippedEvent(SummarySkipNodeUnavailable, "get blockchain info failed: %v", err)
+	}
+
+	maxLag := wm.Config.summarymaxlag
+	if maxLag > 0 {
+		if chain.Height > status.CurrentHeight+maxLag {
+			return newSummarySkippedEvent(SummarySkipNodeLag, "wallet height: %d, chain height: %d", status.CurrentHeight, chain.Height)
+		}
+
+		if wm.Blockscanner.Scanning {
+			scannedHeight := wm.Blockscanner.GetScannedBlockHeight()
+			if chain.Height > scannedHeight+maxLag {
+				return newSummarySkippedEvent(SummarySkipScannerLag, "scanned height: %d, chain height: %d", scannedHeight, chain.Height)
+			}
+		}
+	}
+
+	maxPending := wm.Config.summarymaxpendingwithdrawals
+	if maxPending > 0 {
+		txs, err := wm.GetInFlightWithdrawals()
+		if err != nil {
+			return newSummarySkippedEvent(SummarySkipNodeUnavailable, "get in-flight withdrawals failed: %v", err)
+		}
+		pending := len(txs) + wm.withdrawals.Waiting()
+		if pending >= maxPending {
+			return newSummarySkippedEvent(SummarySkipPendingWithdrawals, "pending withdrawals: %d", pending)
+		}
+	}
+
+	return nil
+}
+
+func newSummarySkippedEvent(reason, format string, a ...interface{}) *SummarySkippedEvent {
+	return &SummarySkippedEvent{
+		Reason: reason,
+		Detail: fmt.Sprintf(format, a...),
+		Time:   time.Now().Unix(),
+	}
+}
+
+//emitSummarySkipped 记录汇总跳过事件，通知事件处理
+func (wm *WalletManager) emitSummarySkipped(event *SummarySkippedEvent) {
+
+	wm.Log.Warningf("summary task skipped, reason: %s, %s", event.Reason, event.Detail)
+	wm.Metrics.SummarySkipped.WithLabelValues(event.Reason).Inc()
+
+	for _, handler := range wm.summarySkippedHandlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,63 @@
+package beam
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEmitSummarySkipped(t *testing.T) {
+
+	wm := NewWalletManager()
+
+	var received []*SummarySkippedEvent
+	wm.AddSummarySkippedHandler(func(event *SummarySkippedEvent) {
+		received = append(received, event)
+	})
+
+	event := newSummarySkippedEvent(SummarySkipScannerLag, "scanned height: %d, chain height: %d", 100, 200)
+	wm.emitSummarySkipped(event)
+
+	if len(received) != 1 {
+		t.Errorf("handler received %d events, want 1", len(received))
+		return
+	}
+	if received[0].Reason != SummarySkipScannerLag {
+		t.Errorf("reason = %s, want %s", received[0].Reason, SummarySkipScannerLag)
+	}
+	if received[0].Detail != "scanned height: 100, chain height: 200" {
+		t.Errorf("unexpected detail: %s", received[0].Detail)
+	}
+}
+
+func TestTriggerSummary(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if _, err := wm.TriggerSummary(); err == nil {
+		t.Errorf("trigger summary without summary address should fail")
+	}
+
+	wm.Config.summaryaddress = "summary"
+	var skipped []*SummarySkippedEvent
+	wm.AddSummarySkippedHandler(func(event *SummarySkippedEvent) {
+		skipped = append(skipped, event)
+	})
+	if _, err := wm.TriggerSummary(); err == nil || len(skipped) != 1 || skipped[0].Reason != SummarySkipNodeUnavailable {
+		t.Errorf("trigger summary with unavailable node = %v, skipped = %v", err, skipped)
+	}
+
+	//定时汇总正在执行时不重复执行
+	wm.summaryRunning <- struct{}{}
+	if _, err := wm.TriggerSummary(); err == nil || len(skipped) != 1 {
+		t.Errorf("trigger summary while running = %v, skipped = %d", err, len(skipped))
+	}
+	<-wm.summaryRunning
+}
@@ -0,0 +1,190 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//SummaryPolicy openwallet账户的汇总策略，字段为空时使用汇总请求中的值
+type SummaryPolicy struct {
+	AccountID  string `storm:"id"`
+	Threshold  string //最低汇总金额，单位BEAM
+	Address    string //汇总地址
+	UpdateTime int64
+}
+
+//parseSummaryAccounts 解析账户汇总策略配置，格式：<账户ID>:<最低汇总金额>:<汇总地址>,...，金额或地址可以为空
+func parseSummaryAccounts(s string) (map[string]*SummaryPolicy, error) {
+	policies := make(map[string]*SummaryPolicy)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		fields := strings.Split(item, ":")
+		if len(fields) != 3 || len(strings.TrimSpace(fields[0])) == 0 {
+			return nil, fmt.Errorf("invalid summary account: %s", item)
+		}
+		policy := &SummaryPolicy{
+			AccountID: strings.TrimSpace(fields[0]),
+			Threshold: strings.TrimSpace(fields[1]),
+			Address:   strings.TrimSpace(fields[2]),
+		}
+		if err := checkSummaryThreshold(policy.Threshold); err != nil {
+			return nil, err
+		}
+		policies[policy.AccountID] = policy
+	}
+	return policies, nil
+}
+
+//checkSummaryThreshold 最低汇总金额需要是非负数，空表示不限制
+func checkSummaryThreshold(threshold string) error {
+	if len(threshold) == 0 {
+		return nil
+	}
+	value, err := decimal.NewFromString(threshold)
+	if err != nil || value.IsNegative() {
+		return fmt.Errorf("invalid summary threshold: %s", threshold)
+	}
+	return nil
+}
+
+func (wm *WalletManager) openSummaryPolicyDB() (*storm.DB, error) {
+	return storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+}
+
+//SetSummaryPolicy 设置账户的汇总策略，保存在本地数据库，优先于summaryaccounts配置，threshold和address不能都为空
+func (wm *WalletManager) SetSummaryPolicy(accountID, threshold, address string) error {
+
+	if len(accountID) == 0 {
+		return fmt.Errorf("account id is empty")
+	}
+	if len(threshold) == 0 && len(address) == 0 {
+		return fmt.Errorf("summary threshold and address are both empty")
+	}
+	if err := checkSummaryThreshold(threshold); err != nil {
+		return err
+	}
+	if len(address) > 0 {
+		v, err := wm.walletClient.GetAddressValidation(context.Background(), address)
+		if err != nil {
+			return err
+		}
+		if !v.IsValid {
+			return fmt.Errorf("summary address: %s is invalid", address)
+		}
+	}
+
+	db, err := wm.openSummaryPolicyDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	policy := &SummaryPolicy{AccountID: accountID, Threshold: threshold, Address: address, UpdateTime: time.Now().Unix()}
+	if err = db.Save(policy); err != nil {
+		return err
+	}
+
+	wm.Log.Infof("set summary policy of account: %s, threshold: %s, address: %s", accountID, threshold, address)
+	return nil
+}
+
+//RemoveSummaryPolicy 删除数据库中账户的汇总策略，summaryaccounts配置的策略恢复生效
+func (wm *WalletManager) RemoveSummaryPolicy(accountID string) error {
+
+	db, err := wm.openSummaryPolicyDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteStruct(&SummaryPolicy{AccountID: accountID})
+	if err == storm.ErrNotFound {
+		return fmt.Errorf("summary policy of account: %s not found", accountID)
+	}
+	return err
+}
+
+//GetSummaryPolicy 账户的汇总策略，数据库中的策略优先于summaryaccounts配置，都没有时返回nil
+func (wm *WalletManager) GetSummaryPolicy(accountID string) (*SummaryPolicy, error) {
+
+	db, err := wm.openSummaryPolicyDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var policy SummaryPolicy
+	err = db.One("AccountID", accountID, &policy)
+	if err == nil {
+		return &policy, nil
+	}
+	if err != storm.ErrNotFound {
+		return nil, err
+	}
+	return wm.Config.summaryaccounts[accountID], nil
+}
+
+//ListSummaryPolicies 列出全部账户的汇总策略，按账户ID排序
+func (wm *WalletManager) ListSummaryPolicies() ([]*SummaryPolicy, error) {
+
+	db, err := wm.openSummaryPolicyDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var saved []*SummaryPolicy
+	err = db.All(&saved)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	policies := make(map[string]*SummaryPolicy)
+	for id, policy := range wm.Config.summaryaccounts {
+		policies[id] = policy
+	}
+	for _, policy := range saved {
+		policies[policy.AccountID] = policy
+	}
+
+	list := make([]*SummaryPolicy, 0, len(policies))
+	for _, policy := range policies {
+		list = append(list, policy)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].AccountID < list[j].AccountID
+	})
+	return list, nil
+}
+
+//applySummaryPolicy 按汇总账户的策略替换汇总请求的最低汇总金额和汇总地址
+func (wm *WalletManager) applySummaryPolicy(sumRawTx *openwallet.SummaryRawTransaction) error {
+
+	if sumRawTx.Account == nil {
+		return nil
+	}
+	policy, err := wm.GetSummaryPolicy(sumRawTx.Account.AccountID)
+	if err != nil || policy == nil {
+		return err
+	}
+
+	if len(policy.Threshold) > 0 {
+		sumRawTx.MinTransfer = policy.Threshold
+	}
+	if len(policy.Address) > 0 {
+		sumRawTx.SummaryAddress = policy.Address
+	}
+	wm.Log.Debugf("account: %s summary by policy, min transfer: %s, address: %s", policy.AccountID, sumRawTx.MinTransfer, sumRawTx.SummaryAddress)
+	return nil
+}
@@ -0,0 +1,98 @@
+package beam
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestParseSummaryAccounts(t *testing.T) {
+
+	policies, err := parseSummaryAccounts(" hot:0.5:cold1, merchant::cold2,fees:10: ")
+	if err != nil || len(policies) != 3 {
+		t.Fatalf("parseSummaryAccounts = %v, err = %v", policies, err)
+	}
+	if p := policies["hot"]; p.Threshold != "0.5" || p.Address != "cold1" {
+		t.Errorf("hot policy = %+v", p)
+	}
+	if p := policies["merchant"]; p.Threshold != "" || p.Address != "cold2" {
+		t.Errorf("merchant policy = %+v", p)
+	}
+	if p := policies["fees"]; p.Threshold != "10" || p.Address != "" {
+		t.Errorf("fees policy = %+v", p)
+	}
+
+	for _, s := range []string{"hot:1", ":1:cold", "hot:-1:cold", "hot:abc:cold"} {
+		if _, err = parseSummaryAccounts(s); err == nil {
+			t.Errorf("parseSummaryAccounts(%s) should fail", s)
+		}
+	}
+}
+
+func TestSummaryPolicy(t *testing.T) {
+
+	server := newRPCTestServer(rpcTestMethods{
+		"validate_address": func(params map[string]interface{}) (string, error) {
+			if params["address"] == "bad" {
+				return `{"is_valid":false}`, nil
+			}
+			return `{"is_valid":true,"is_mine":false}`, nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	wm.Config.summaryaccounts, _ = parseSummaryAccounts("hot:0.5:cold1,merchant:100:")
+
+	summary := func(accountID string) *openwallet.SummaryRawTransaction {
+		sumRawTx := &openwallet.SummaryRawTransaction{
+			Account:        &openwallet.AssetsAccount{AccountID: accountID},
+			MinTransfer:    "1",
+			SummaryAddress: "request",
+		}
+		if err := wm.applySummaryPolicy(sumRawTx); err != nil {
+			t.Errorf("applySummaryPolicy unexpected error: %v", err)
+		}
+		return sumRawTx
+	}
+
+	if s := summary("hot"); s.MinTransfer != "0.5" || s.SummaryAddress != "cold1" {
+		t.Errorf("hot summary = %s, %s", s.MinTransfer, s.SummaryAddress)
+	}
+	if s := summary("merchant"); s.MinTransfer != "100" || s.SummaryAddress != "request" {
+		t.Errorf("merchant summary = %s, %s", s.MinTransfer, s.SummaryAddress)
+	}
+	if s := summary("other"); s.MinTransfer != "1" || s.SummaryAddress != "request" {
+		t.Errorf("other summary = %s, %s", s.MinTransfer, s.SummaryAddress)
+	}
+
+	//数据库中的策略优先于配置
+	if err := wm.SetSummaryPolicy("merchant", "20", "cold3"); err != nil {
+		t.Fatalf("SetSummaryPolicy unexpected error: %v", err)
+	}
+	if s := summary("merchant"); s.MinTransfer != "20" || s.SummaryAddress != "cold3" {
+		t.Errorf("merchant summary after set = %s, %s", s.MinTransfer, s.SummaryAddress)
+	}
+	list, _ := wm.ListSummaryPolicies()
+	if len(list) != 2 || list[0].AccountID != "hot" || list[1].Threshold != "20" {
+		t.Errorf("ListSummaryPolicies = %+v", list)
+	}
+
+	if err := wm.RemoveSummaryPolicy("merchant"); err != nil {
+		t.Errorf("RemoveSummaryPolicy unexpected error: %v", err)
+	}
+	if s := summary("merchant"); s.MinTransfer != "100" {
+		t.Errorf("merchant summary after remove = %s", s.MinTransfer)
+	}
+	if err := wm.RemoveSummaryPolicy("merchant"); err == nil {
+		t.Errorf("remove missing policy should fail")
+	}
+
+	for _, args := range [][]string{{"", "1", ""}, {"hot", "", ""}, {"hot", "-1", ""}, {"hot", "", "bad"}} {
+		if err := wm.SetSummaryPolicy(args[0], args[1], args[2]); err == nil {
+			t.Errorf("SetSummaryPolicy(%v) should fail", args)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package beam
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/common"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	//汇总的触发方式
+	SummaryTriggerSchedule = "schedule" //定时或cron汇总
+	SummaryTriggerManual   = "manual"   //手动触发
+
+	//汇总报告的状态
+	SummaryStatusSent           = "sent"            //全部发送成功
+	SummaryStatusPartial        = "partial"         //拆分汇总部分发送失败
+	SummaryStatusBelowThreshold = "below_threshold" //余额未超过阈值，没有发送
+	SummaryStatusSkipped        = "skipped"         //系统状态不佳，推迟汇总
+	SummaryStatusFailed         = "failed"          //汇总失败
+)
+
+//SummaryFailure 汇总中一个目标地址的失败原因
+type SummaryFailure struct {
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
+
+//SummaryRunReport 一次汇总执行的报告，保存在本地数据库，配置summarywebhook时推送给资金管理
+type SummaryRunReport struct {
+	ID        int64             `storm:"id,increment" json:"id"`
+	Trigger   string            `json:"trigger"`
+	Status    string            `json:"status"`
+	Swept     uint64            `json:"swept"` //汇总到账金额，单位groth
+	Fees      uint64            `json:"fees"`  //手续费，单位groth
+	TxIDs     []string          `json:"txids"`
+	Failures  []*SummaryFailure `json:"failures,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	StartTime int64             `json:"startTime"`
+	EndTime   int64             `json:"endTime"`
+	Notified  bool              `json:"notified,omitempty"` //webhook推送成功
+}
+
+func newSummaryRunReport(trigger string) *SummaryRunReport {
+	return &SummaryRunReport{
+		Trigger:   trigger,
+		TxIDs:     make([]string, 0),
+		StartTime: time.Now().Unix(),
+	}
+}
+
+//addSplitReport 记录拆分汇总每个目标的结果
+func (r *SummaryRunReport) addSplitReport(report *SummarySplitReport) {
+	r.Swept, r.Fees = report.Sent, report.Fees
+	r.TxIDs = report.TxIDs()
+	for _, result := range report.Results {
+		if len(result.Error) > 0 {
+			r.Failures = append(r.Failures, &SummaryFailure{Address: result.Address, Error: result.Error})
+		}
+	}
+	switch {
+	case len(report.Results) == 0:
+		r.Status = SummaryStatusBelowThreshold
+	case len(r.Failures) == 0:
+		r.Status = SummaryStatusSent
+	case len(r.TxIDs) > 0:
+		r.Status = SummaryStatusPartial
+	default:
+		r.Status = SummaryStatusFailed
+	}
+}
+
+//addSummaryResult 记录汇总到summaryaddress的结果，summaryAmount和fee为SummaryWalletProcess返回的金额
+func (r *SummaryRunReport) addSummaryResult(address, txid, summaryAmount, fee string, err error, decimals int32) {
+	if err != nil {
+		r.Status = SummaryStatusFailed
+		r.Failures = append(r.Failures, &SummaryFailure{Address: address, Error: err.Error()})
+	}
+	if len(txid) == 0 {
+		if err == nil {
+			r.Status = SummaryStatusBelowThreshold
+		}
+		return
+	}
+
+	//拆分为多笔时txid逗号分隔，summaryAmount为扣减的余额（负数），包含手续费
+	feeDec, _ := decimal.NewFromString(fee)
+	total, _ := decimal.NewFromString(summaryAmount)
+	r.Status = SummaryStatusSent
+	if err != nil {
+		r.Status = SummaryStatusPartial
+	}
+	r.TxIDs = append(r.TxIDs, strings.Split(txid, ",")...)
+	r.Fees = uint64(feeDec.Shift(decimals).IntPart())
+	r.Swept = uint64(total.Neg().Sub(feeDec).Shift(decimals).IntPart())
+}
+
+//saveSummaryReport 保存汇总报告并推送webhook，失败只记录日志，不影响汇总结果
+func (wm *WalletManager) saveSummaryReport(report *SummaryRunReport) {
+
+	report.EndTime = time.Now().Unix()
+	wm.Log.Infof("Summary report: trigger: %s, status: %s, swept: %s, fees: %s, txids: %v, failures: %d",
+		report.Trigger, report.Status,
+		common.IntToDecimals(int64(report.Swept), wm.Decimal()).String(),
+		common.IntToDecimals(int64(report.Fees), wm.Decimal()).String(),
+		report.TxIDs, len(report.Failures))
+
+	if len(wm.Config.summarywebhook) > 0 && wm.IsFeatureEnabled(FeatureWebhooks) {
+		if err := wm.postSummaryReport(report); err != nil {
+			wm.Log.Errorf("post summary report to webhook failed, unexpected error: %v", err)
+		} else {
+			report.Notified = true
+		}
+	}
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		wm.Log.Errorf("save summary report failed, unexpected error: %v", err)
+		return
+	}
+	defer db.Close()
+	if err = db.Save(report); err != nil {
+		wm.Log.Errorf("save summary report failed, unexpected error: %v", err)
+	}
+}
+
+//postSummaryReport 把汇总报告以JSON推送到summarywebhook，返回非2xx状态码时视为失败
+func (wm *WalletManager) postSummaryReport(report *SummaryRunReport) error {
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, wm.Config.summarywebhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: DefaultRPCTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("summary webhook response: %s %s", resp.Status, string(data))
+	}
+	return nil
+}
+
+//ListSummaryReports 最近的汇总报告，按执行时间倒序，limit为0时返回全部
+func (wm *WalletManager) ListSummaryReports(limit int) ([]*SummaryRunReport, error) {
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var reports []*SummaryRunReport
+	if limit > 0 {
+		err = db.All(&reports, storm.Reverse(), storm.Limit(limit))
+	} else {
+		err = db.All(&reports, storm.Reverse())
+	}
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return reports, nil
+}
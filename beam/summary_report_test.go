@@ -0,0 +1,105 @@
+package beam
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSummaryRunReportResults(t *testing.T) {
+
+	report := newSummaryRunReport(SummaryTriggerSchedule)
+	report.addSummaryResult("cold", "tx1", "-0.5", "0.0000015", nil, 8)
+	if report.Status != SummaryStatusSent || report.Swept != 49999850 || report.Fees != 150 || len(report.TxIDs) != 1 {
+		t.Errorf("summary result report = %+v", report)
+	}
+
+	report = newSummaryRunReport(SummaryTriggerSchedule)
+	report.addSummaryResult("cold", "", "", "", fmt.Errorf("send failed"), 8)
+	if report.Status != SummaryStatusFailed || len(report.Failures) != 1 || report.Failures[0].Address != "cold" {
+		t.Errorf("failed summary report = %+v", report)
+	}
+
+	report = newSummaryRunReport(SummaryTriggerManual)
+	report.addSplitReport(&SummarySplitReport{
+		Sent: 800,
+		Fees: 150,
+		Results: []*SummarySplitResult{
+			{Address: "cold", Amount: 800, Fee: 150, TxID: "tx1"},
+			{Address: "ops", Amount: 200, Fee: 150, Error: "send failed"},
+		},
+	})
+	if report.Status != SummaryStatusPartial || report.Swept != 800 || report.Fees != 150 ||
+		len(report.TxIDs) != 1 || len(report.Failures) != 1 || report.Failures[0].Address != "ops" {
+		t.Errorf("split summary report = %+v", report)
+	}
+
+	report = newSummaryRunReport(SummaryTriggerManual)
+	report.addSplitReport(&SummarySplitReport{Results: []*SummarySplitResult{}})
+	if report.Status != SummaryStatusBelowThreshold {
+		t.Errorf("split report below threshold status = %s", report.Status)
+	}
+}
+
+func TestSummaryReportWebhook(t *testing.T) {
+
+	methods := rpcTestMethods{
+		"wallet_status": rpcResult(`{"current_height":100,"available":1000}`),
+		"tx_list":       rpcResult(`[]`),
+	}
+	wallet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"height":100}`))
+			return
+		}
+		methods.ServeHTTP(w, r)
+	}))
+	defer wallet.Close()
+
+	var received []*SummaryRunReport
+	webhookStatus := http.StatusOK
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report SummaryRunReport
+		json.NewDecoder(r.Body).Decode(&report)
+		received = append(received, &report)
+		w.WriteHeader(webhookStatus)
+	}))
+	defer webhook.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.summaryaddress = "cold"
+	wm.Config.summarythreshold = "1"
+	wm.Config.summarywebhook = webhook.URL
+	wm.walletClient = NewWalletClient(wallet.URL, wallet.URL, false)
+
+	//未开启webhooks功能时只保存报告
+	if _, err := wm.TriggerSummary(); err != nil {
+		t.Fatalf("TriggerSummary unexpected error: %v", err)
+	}
+	if len(received) != 0 {
+		t.Errorf("webhook received %d reports with feature disabled", len(received))
+	}
+
+	wm.Config.features[FeatureWebhooks] = true
+	wm.TriggerSummary()
+	if len(received) != 1 || received[0].Trigger != SummaryTriggerManual || received[0].Status != SummaryStatusBelowThreshold {
+		t.Errorf("webhook received = %v", received)
+	}
+
+	webhookStatus = http.StatusInternalServerError
+	wm.SummaryWallets()
+
+	reports, err := wm.ListSummaryReports(0)
+	if err != nil || len(reports) != 3 {
+		t.Fatalf("ListSummaryReports = %d, err = %v", len(reports), err)
+	}
+	if reports[0].Trigger != SummaryTriggerSchedule || reports[0].Notified || !reports[1].Notified || reports[2].Notified {
+		t.Errorf("reports = %+v, %+v, %+v", reports[0], reports[1], reports[2])
+	}
+	if reports, _ = wm.ListSummaryReports(1); len(reports) != 1 || reports[0].ID != 3 {
+		t.Errorf("ListSummaryReports(1) = %v", reports)
+	}
+}
@@ -0,0 +1,263 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blocktree/openwallet/common"
+	"github.com/shopspring/decimal"
+)
+
+//SummaryTarget 拆分汇总的目标地址，按比例或固定金额分配
+type SummaryTarget struct {
+	Address string
+	Ratio   decimal.Decimal //分配比例，单位%，固定金额时为0
+	Amount  uint64          //固定金额
+}
+
+//SummarySplitResult 拆分汇总中一个目标的发送结果
+type SummarySplitResult struct {
+	Address string `json:"address"`
+	Amount  uint64 `json:"amount"` //到账金额
+	Fee     uint64 `json:"fee"`
+	TxID    string `json:"txid,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+//SummarySplitReport 一次拆分汇总的合并报告
+type SummarySplitReport struct {
+	Balance uint64                `json:"balance"` //参与汇总的余额
+	Sent    uint64                `json:"sent"`    //成功发送的到账金额
+	Fees    uint64                `json:"fees"`    //成功发送的手续费
+	Results []*SummarySplitResult `json:"results"`
+	Time    int64                 `json:"time"`
+}
+
+//TxIDs 发送成功的交易ID
+func (r *SummarySplitReport) TxIDs() []string {
+	txids := make([]string, 0, len(r.Results))
+	for _, result := range r.Results {
+		if len(result.TxID) > 0 {
+			txids = append(txids, result.TxID)
+		}
+	}
+	return txids
+}
+
+//parseSummarySplit 解析拆分汇总配置，格式：<地址>:<比例>%或<地址>:<固定金额>,...，如cold:80%,ops:20%；
+//先分配固定金额，剩余按比例分配，有比例时合计需要为100%，只有固定金额时剩余的余额不汇总
+func parseSummarySplit(s string, decimals int32) ([]*SummaryTarget, error) {
+
+	targets := make([]*SummaryTarget, 0)
+	addresses := make(map[string]bool)
+	ratios := decimal.Zero
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		kv := strings.SplitN(item, ":", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return nil, fmt.Errorf("invalid summary split: %s", item)
+		}
+		target := &SummaryTarget{Address: strings.TrimSpace(kv[0])}
+		if addresses[target.Address] {
+			return nil, fmt.Errorf("duplicate summary split address: %s", target.Address)
+		}
+		addresses[target.Address] = true
+
+		value := strings.TrimSpace(kv[1])
+		if strings.HasSuffix(value, "%") {
+			ratio, err := decimal.NewFromString(strings.TrimSuffix(value, "%"))
+			if err != nil || !ratio.IsPositive() {
+				return nil, fmt.Errorf("invalid summary split ratio: %s", item)
+			}
+			target.Ratio = ratio
+			ratios = ratios.Add(ratio)
+		} else {
+			amount, err := decimal.NewFromString(value)
+			if err != nil || !amount.IsPositive() || !amount.Equal(amount.Truncate(decimals)) {
+				return nil, fmt.Errorf("invalid summary split amount: %s", item)
+			}
+			target.Amount = uint64(amount.Shift(decimals).IntPart())
+		}
+		targets = append(targets, target)
+	}
+
+	if len(targets) > 0 && ratios.IsPositive() && !ratios.Equal(decimal.New(100, 0)) {
+		return nil, fmt.Errorf("summary split ratios add up to %s%%, want 100%%", ratios.String())
+	}
+	return targets, nil
+}
+
+//planSummarySplit 按目标分配余额：先保留summaryminbalance并按summaryfeefrom支付每笔发送的手续费，
+//再分配固定金额，剩余按比例分配，最后一个按比例分配的目标得到取整的余数
+func (wm *WalletManager) planSummarySplit(balance uint64, targets []*SummaryTarget) ([]*SummarySplitResult, error) {
+
+	results := make([]*SummarySplitResult, len(targets))
+	fees, fixed := uint64(0), uint64(0)
+	lastRatio := -1
+	for i, target := range targets {
+		fee, err := wm.EstimateFee(balance, []string{target.Address})
+		if err != nil {
+			return nil, err
+		}
+		results[i] = &SummarySplitResult{Address: target.Address, Amount: target.Amount, Fee: fee}
+		fees += fee
+		fixed += target.Amount
+		if target.Ratio.IsPositive() {
+			lastRatio = i
+		}
+	}
+
+	//保留summaryminbalance，按summaryfeefrom支付手续费
+	distributable, err := wm.summarySweepAmount(balance, 0, fees)
+	if err != nil {
+		return nil, err
+	}
+	if distributable <= fixed {
+		return nil, fmt.Errorf("summary balance: %d not enough to pay fixed amounts: %d and fees: %d", balance, fixed, fees)
+	}
+
+	rest := distributable - fixed
+
+	//每笔不超过summarymaxamount，按比例最大的目标限制剩余金额，超出的部分留到下次汇总
+	maxAmount := wm.summaryMaxAmount()
+	if maxAmount > 0 {
+		maxRatio := decimal.Zero
+		for _, target := range targets {
+			if target.Amount > maxAmount {
+				return nil, fmt.Errorf("summary split amount: %d of %s exceeds summarymaxamount: %d", target.Amount, target.Address, maxAmount)
+			}
+			if target.Ratio.GreaterThan(maxRatio) {
+				maxRatio = target.Ratio
+			}
+		}
+		if maxRatio.IsPositive() {
+			limit := uint64(decimal.New(int64(maxAmount), 0).Mul(decimal.New(100, 0)).Div(maxRatio).IntPart())
+			if rest > limit {
+				rest = limit
+			}
+		}
+	}
+
+	allocated := uint64(0)
+	for i, target := range targets {
+		if !target.Ratio.IsPositive() {
+			continue
+		}
+		if i == lastRatio {
+			//余数可能让最后一个目标超过限制几个groth
+			results[i].Amount = rest - allocated
+			if maxAmount > 0 && results[i].Amount > maxAmount {
+				results[i].Amount = maxAmount
+			}
+			continue
+		}
+		amount := uint64(decimal.New(int64(rest), 0).Mul(target.Ratio).Div(decimal.New(100, 0)).IntPart())
+		results[i].Amount = amount
+		allocated += amount
+	}
+	return results, nil
+}
+
+//SummarySplitProcess 余额超过summarythreshold时按summarysplit拆分汇总到多个地址，逐笔发送，
+//某一笔失败时继续发送其他目标，失败记录在报告中
+func (wm *WalletManager) SummarySplitProcess(targets []*SummaryTarget) (*SummarySplitReport, error) {
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("summary split targets are empty")
+	}
+
+	status, err := wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("get local wallet balance failed, unexpected error: %v", err)
+	}
+
+	//预留给未广播提现的余额和归档地址收到的余额不汇总
+	available, err := wm.summaryBalance(wm.unreservedBalance(status.Available))
+	if err != nil {
+		return nil, err
+	}
+	balance := common.IntToDecimals(int64(available), wm.Decimal())
+	threshold, _ := decimal.NewFromString(wm.Config.summarythreshold)
+
+	wm.Log.Infof("Summary Wallet Current Balance: %v, threshold: %v", balance.String(), threshold.String())
+
+	report := &SummarySplitReport{Balance: available, Results: make([]*SummarySplitResult, 0), Time: time.Now().Unix()}
+	if !balance.GreaterThan(threshold) || available <= wm.summaryMinBalance() {
+		return report, nil
+	}
+
+	results, err := wm.planSummarySplit(available, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	//发送地址使用withdrawfrom配置或钱包的第一个地址
+	from, err := wm.SenderAddress("")
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		report.Results = append(report.Results, result)
+		if result.Amount == 0 {
+			result.Error = "amount is zero"
+			continue
+		}
+
+		//按配置选择并锁定UTXO，后面的发送不会选中前面已使用的UTXO
+		sid := fmt.Sprintf("summary_%d_%d", report.Time, i)
+		coinIDs, selectErr := wm.selectWithdrawalCoins(result.Amount+result.Fee, sid)
+		if selectErr != nil {
+			result.Error = selectErr.Error()
+			continue
+		}
+
+		//锁定了UTXO时预先生成交易ID，发送结果不确定时UTXO锁绑定该交易ID
+		txID := ""
+		if len(coinIDs) > 0 {
+			txID, selectErr = wm.walletClient.GenerateTxID(context.Background())
+			if selectErr != nil {
+				if unlockErr := wm.utxoLocks.unlock(coinIDs); unlockErr != nil {
+					wm.Log.Errorf("release utxo locks failed, unexpected error: %v", unlockErr)
+				}
+				result.Error = selectErr.Error()
+				continue
+			}
+		}
+
+		txid, sendErr := wm.walletClient.SendTransactionWithCoins(context.Background(), from, result.Address, result.Amount, result.Fee, "", txID, coinIDs)
+		if sendErr != nil {
+			wm.releaseUnsentCoins(coinIDs, txID, sendErr)
+			result.Error = sendErr.Error()
+			wm.Log.Errorf("summary to address: %s failed, unexpected error: %v", result.Address, sendErr)
+			continue
+		}
+		if len(coinIDs) > 0 {
+			if bindErr := wm.utxoLocks.bind(coinIDs, txid); bindErr != nil {
+				wm.Log.Errorf("bind utxo locks to tx: %s failed, unexpected error: %v", txid, bindErr)
+			}
+		}
+
+		result.TxID = txid
+		report.Sent += result.Amount
+		report.Fees += result.Fee
+		wm.Log.Infof("[Success] summary to address: %s, amount: %d, fee: %d, txid: %s", result.Address, result.Amount, result.Fee, txid)
+	}
+
+	wm.Log.Infof("Summary split report: balance: %d, sent: %d, fees: %d, txs: %d/%d", report.Balance, report.Sent, report.Fees, len(report.TxIDs()), len(report.Results))
+
+	//完成一次汇总备份一次wallet.db
+	if len(report.TxIDs()) > 0 {
+		if backErr := wm.BackupWalletData(); backErr != nil {
+			wm.Log.Infof("Backup wallet data failed: %v", backErr)
+		} else {
+			wm.Log.Infof("Backup wallet data success")
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,87 @@
+package beam
+
+import (
+	"testing"
+)
+
+func TestParseSummarySplit(t *testing.T) {
+
+	targets, err := parseSummarySplit("cold:80%, ops:20% ,fees:1.5", 8)
+	if err != nil || len(targets) != 3 {
+		t.Fatalf("parseSummarySplit = %v, err = %v", targets, err)
+	}
+	if targets[0].Address != "cold" || targets[0].Ratio.String() != "80" || targets[0].Amount != 0 {
+		t.Errorf("cold target = %+v", targets[0])
+	}
+	if targets[2].Address != "fees" || targets[2].Amount != 150000000 || !targets[2].Ratio.IsZero() {
+		t.Errorf("fees target = %+v", targets[2])
+	}
+	if targets, err = parseSummarySplit("ops:1,fees:2", 8); err != nil || len(targets) != 2 {
+		t.Errorf("fixed amounts only = %v, err = %v", targets, err)
+	}
+
+	for _, s := range []string{"cold:80%,ops:10%", "cold", ":50%", "cold:0%", "cold:-1", "cold:0.000000001", "cold:50%,cold:50%", "cold:abc"} {
+		if _, err = parseSummarySplit(s, 8); err == nil {
+			t.Errorf("parseSummarySplit(%s) should fail", s)
+		}
+	}
+}
+
+func TestSummarySplitProcess(t *testing.T) {
+
+	sent := make(map[string]float64)
+	server := newRPCTestServer(rpcTestMethods{
+		"wallet_status":    rpcResult(`{"current_height":100,"available":100000000}`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false,"type":"regular"}`),
+		"calc_change":      rpcResult(`{"change":0,"explicit_fee":0}`),
+		"addr_list":        rpcResult(`[{"address":"self","own":true,"comment":"self"}]`),
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			address, _ := params["address"].(string)
+			if address == "broken" {
+				return "", &rpcTestError{Code: -32001, Message: "send failed"}
+			}
+			sent[address] = params["value"].(float64)
+			return `{"txId":"tx-` + address + `"}`, nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.summarythreshold = "0.5"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	fee, _ := wm.EstimateFee(100000000, []string{"cold"})
+
+	targets, _ := parseSummarySplit("fixed:0.1,cold:80%,ops:20%", 8)
+	report, err := wm.SummarySplitProcess(targets)
+	if err != nil {
+		t.Fatalf("SummarySplitProcess unexpected error: %v", err)
+	}
+	rest := uint64(100000000) - 3*fee - 10000000
+	cold := rest * 80 / 100
+	if sent["fixed"] != 10000000 || uint64(sent["cold"]) != cold || uint64(sent["ops"]) != rest-cold {
+		t.Errorf("sent = %v, want fixed 10000000, cold %d, ops %d", sent, cold, rest-cold)
+	}
+	if report.Sent != 100000000-3*fee || report.Fees != 3*fee || len(report.TxIDs()) != 3 {
+		t.Errorf("report = %+v", report)
+	}
+
+	//某一笔失败时继续发送其他目标
+	targets, _ = parseSummarySplit("broken:50%,ops:50%", 8)
+	report, err = wm.SummarySplitProcess(targets)
+	if err != nil || len(report.Results) != 2 || len(report.Results[0].Error) == 0 || report.Results[1].TxID != "tx-ops" {
+		t.Errorf("report with failed target = %+v, err = %v", report, err)
+	}
+
+	//余额不足以支付固定金额
+	targets, _ = parseSummarySplit("fixed:2", 8)
+	if _, err = wm.SummarySplitProcess(targets); err == nil {
+		t.Errorf("fixed amount over balance should fail")
+	}
+
+	//未超过阈值时不汇总
+	wm.Config.summarythreshold = "5"
+	if report, err = wm.SummarySplitProcess(targets); err != nil || len(report.Results) != 0 {
+		t.Errorf("report below threshold = %+v, err = %v", report, err)
+	}
+}
@@ -0,0 +1,112 @@
+package beam
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/common"
+)
+
+const (
+	summaryBucket = "summary" // summary state
+)
+
+//summaryMaxAmount 每笔汇总交易的最大金额，0表示不限制
+func (wm *WalletManager) summaryMaxAmount() uint64 {
+	return common.StringNumToBigIntWithExp(wm.Config.summarymaxamount, wm.Decimal()).Uint64()
+}
+
+//summaryChunks 计算汇总到address的每笔金额和每笔手续费：不超过summarymaxamount时只发一笔，
+//超过时拆分为多笔，每笔单独支付手续费，最多summarymaxtxs笔，超出的部分留到下次汇总
+func (wm *WalletManager) summaryChunks(available uint64, address string) ([]uint64, uint64, error) {
+
+	fee, err := wm.EstimateFee(available, []string{address})
+	if err != nil {
+		return nil, 0, err
+	}
+	amount, err := wm.summarySweepAmount(available, 0, fee)
+	if err != nil || amount == 0 {
+		return nil, 0, err
+	}
+
+	maxAmount := wm.summaryMaxAmount()
+	if maxAmount == 0 || amount <= maxAmount {
+		return []uint64{amount}, fee, nil
+	}
+
+	//拆分后每笔都有找零，按单笔最大金额重新估算手续费
+	fee, err = wm.EstimateFee(maxAmount, []string{address})
+	if err != nil {
+		return nil, 0, err
+	}
+	n := (amount + maxAmount - 1) / maxAmount
+	if maxTxs := uint64(wm.Config.summarymaxtxs); maxTxs > 0 && n > maxTxs {
+		n = maxTxs
+	}
+	total, err := wm.summarySweepAmount(available, 0, n*fee)
+	if err != nil || total == 0 {
+		return nil, 0, err
+	}
+
+	amounts := make([]uint64, 0, n)
+	for i := uint64(0); i < n && total > 0; i++ {
+		chunk := total
+		if chunk > maxAmount {
+			chunk = maxAmount
+		}
+		amounts = append(amounts, chunk)
+		total -= chunk
+	}
+	return amounts, fee, nil
+}
+
+//lastSummarySweep 上一次发送了汇总交易的时间，没有记录时返回零值
+func (wm *WalletManager) lastSummarySweep() time.Time {
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return time.Time{}
+	}
+	defer db.Close()
+
+	var sweepTime int64
+	db.Get(summaryBucket, "lastSweepTime", &sweepTime)
+	if sweepTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sweepTime, 0)
+}
+
+//saveLastSummarySweep 记录发送了汇总交易的时间
+func (wm *WalletManager) saveLastSummarySweep(t time.Time) error {
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	sweepTime := t.Unix()
+	return db.Set(summaryBucket, "lastSweepTime", &sweepTime)
+}
+
+//checkSummaryInterval 距离上一次发送汇总交易不足summarymininterval时返回跳过事件
+func (wm *WalletManager) checkSummaryInterval() *SummarySkippedEvent {
+	if wm.Config.summarymininterval <= 0 {
+		return nil
+	}
+	last := wm.lastSummarySweep()
+	if next := last.Add(wm.Config.summarymininterval); !last.IsZero() && time.Now().Before(next) {
+		return newSummarySkippedEvent(SummarySkipMinInterval, "last sweep: %s, next sweep after: %s",
+			last.Format("2006-01-02 15:04:05"), next.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+//checkSummaryThrottle summarysplit的目标数量不能超过summarymaxtxs
+func checkSummaryThrottle(targets []*SummaryTarget, maxTxs int) error {
+	if maxTxs > 0 && len(targets) > maxTxs {
+		return fmt.Errorf("summarysplit has %d targets, more than summarymaxtxs: %d", len(targets), maxTxs)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package beam
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/common"
+)
+
+func newSummaryThrottleServer(sent *[]uint64, failAfter int) *httptest.Server {
+	return newRPCTestServer(rpcTestMethods{
+		"wallet_status":    rpcResult(`{"current_height":100,"available":100000000}`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false,"type":"regular"}`),
+		"calc_change":      rpcResult(`{"change":1,"explicit_fee":0}`),
+		"addr_list":        rpcResult(`[{"address":"self","own":true,"comment":"self"}]`),
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			if failAfter >= 0 && len(*sent) >= failAfter {
+				return "", &rpcTestError{Code: -32001, Message: "send failed"}
+			}
+			*sent = append(*sent, uint64(params["value"].(float64)))
+			return fmt.Sprintf(`{"txId":"tx%d"}`, len(*sent)), nil
+		},
+	})
+}
+
+func TestSummaryMaxAmount(t *testing.T) {
+
+	var sent []uint64
+	server := newSummaryThrottleServer(&sent, -1)
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.summarythreshold = "0.5"
+	wm.Config.summarymaxamount = "0.3"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	fee, _ := wm.EstimateFee(30000000, []string{"cold"})
+
+	//余额1 BEAM拆分为0.3、0.3、0.3和剩余部分
+	txid, _, feeAmount, err := wm.SummaryWalletProcess("cold")
+	if err != nil || len(sent) != 4 || strings.Count(txid, ",") != 3 {
+		t.Fatalf("SummaryWalletProcess = %s, sent = %v, err = %v", txid, sent, err)
+	}
+	if sent[0] != 30000000 || sent[3] != 10000000-4*fee {
+		t.Errorf("sent amounts = %v", sent)
+	}
+	if feeAmount != common.IntToDecimals(int64(4*fee), 8).String() {
+		t.Errorf("fee amount = %s, want %d groth", feeAmount, 4*fee)
+	}
+
+	//每次最多发送2笔
+	sent = sent[:0]
+	wm.Config.summarymaxtxs = 2
+	if txid, _, _, err = wm.SummaryWalletProcess("cold"); err != nil || len(sent) != 2 || sent[1] != 30000000 {
+		t.Errorf("SummaryWalletProcess with max txs = %s, sent = %v, err = %v", txid, sent, err)
+	}
+
+	//拆分汇总每个目标不超过最大金额，按比例缩减
+	targets, _ := parseSummarySplit("cold:60%,ops:40%", 8)
+	results, err := wm.planSummarySplit(100000000, targets)
+	if err != nil || results[0].Amount != 30000000 || results[1].Amount != 20000000 {
+		t.Errorf("planSummarySplit with max amount = %v, err = %v", results, err)
+	}
+	targets, _ = parseSummarySplit("cold:0.5", 8)
+	if _, err = wm.planSummarySplit(100000000, targets); err == nil {
+		t.Errorf("fixed amount over summarymaxamount should fail")
+	}
+}
+
+func TestSummaryMaxAmountPartial(t *testing.T) {
+
+	var sent []uint64
+	server := newSummaryThrottleServer(&sent, 1)
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.summarythreshold = "0.5"
+	wm.Config.summarymaxamount = "0.3"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	txid, summaryAmount, feeAmount, err := wm.SummaryWalletProcess("cold")
+	if err == nil || txid != "tx1" {
+		t.Fatalf("SummaryWalletProcess = %s, err = %v", txid, err)
+	}
+
+	report := newSummaryRunReport(SummaryTriggerManual)
+	report.addSummaryResult("cold", txid, summaryAmount, feeAmount, err, 8)
+	if report.Status != SummaryStatusPartial || report.Swept != 30000000 || len(report.Failures) != 1 {
+		t.Errorf("partial report = %+v", report)
+	}
+}
+
+func TestSummaryMinInterval(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+
+	if event := wm.checkSummaryInterval(); event != nil {
+		t.Errorf("checkSummaryInterval without min interval = %v", event)
+	}
+	wm.Config.summarymininterval = time.Hour
+	if event := wm.checkSummaryInterval(); event != nil {
+		t.Errorf("checkSummaryInterval without last sweep = %v", event)
+	}
+
+	wm.saveLastSummarySweep(time.Now().Add(-10 * time.Minute))
+	if event := wm.checkSummaryInterval(); event == nil || event.Reason != SummarySkipMinInterval {
+		t.Errorf("checkSummaryInterval within min interval = %v", event)
+	}
+	wm.saveLastSummarySweep(time.Now().Add(-2 * time.Hour))
+	if event := wm.checkSummaryInterval(); event != nil {
+		t.Errorf("checkSummaryInterval after min interval = %v", event)
+	}
+
+	targets, _ := parseSummarySplit("a:50%,b:30%,c:20%", 8)
+	if err := checkSummaryThrottle(targets, 2); err == nil {
+		t.Errorf("split targets over summarymaxtxs should fail")
+	}
+	if err := checkSummaryThrottle(targets, 0); err != nil {
+		t.Errorf("checkSummaryThrottle unlimited = %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//计算最大发送金额时按calc_change调整手续费的最多次数
+	sweepFeeRounds = 3
+)
+
+//spendableCoins 未被提现交易锁定的可花费UTXO
+func (wm *WalletManager) spendableCoins() ([]*Utxo, error) {
+
+	status, err := wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	assetID := int64(0)
+	filter := SpendableFilter(status.CurrentHeight)
+	filter.AssetID = &assetID
+	utxos, err := wm.walletClient.ListUTXO(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	locks, err := wm.utxoLocks.all()
+	if err != nil {
+		return nil, err
+	}
+	locked := make(map[string]bool, len(locks))
+	for _, lock := range locks {
+		locked[lock.UtxoID] = true
+	}
+
+	available := make([]*Utxo, 0, len(utxos))
+	for _, utxo := range utxos {
+		if !locked[utxo.ID] {
+			available = append(available, utxo)
+		}
+	}
+	return available, nil
+}
+
+//sweepAmount 发送coins全部金额到to时的发送金额和手续费，没有找零输出，
+//先按最低手续费规则估算，隐私池UTXO按隐私输入计算，再按calc_change返回的手续费调整
+func (wm *WalletManager) sweepAmount(to string, coins []*Utxo) (*WithdrawalAmount, error) {
+
+	total := sumCoins(coins)
+
+	v, err := wm.walletClient.GetAddressValidation(context.Background(), to)
+	if err != nil {
+		return nil, err
+	}
+
+	params := FeeParams{Kernels: 1}
+	if isShieldedAddressType(v.Type) {
+		params.ShieldedOutputs++
+	} else {
+		params.Outputs++
+	}
+	params.addInputs(coins)
+	fee := wm.applyFee(params.MinFee())
+
+	for i := 0; i < sweepFeeRounds; i++ {
+		if total <= fee {
+			return nil, openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "wallet balance %d not enough to pay fee %d", total, fee)
+		}
+		change, err := wm.walletClient.CalcChange(context.Background(), total-fee, fee)
+		if err != nil || change.ExplicitFee <= fee {
+			return &WithdrawalAmount{Send: total - fee, Fee: fee}, nil
+		}
+		fee = change.ExplicitFee
+	}
+	return nil, fmt.Errorf("sweep fee of amount %d is not stable", total)
+}
+
+//checkSweepReservations 全部余额发送没有找零，不能保留提现队列预留的余额，有预留时拒绝发送
+func (wm *WalletManager) checkSweepReservations() error {
+
+	reserved, err := wm.reservations.Total()
+	if err != nil {
+		return err
+	}
+	if reserved > 0 {
+		return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "balance %d is reserved for queued withdrawals, send all after they are broadcast", reserved)
+	}
+	return nil
+}
+
+//MaxSendAmount 全部未锁定的可花费余额发送到to时，接收方到账金额和手续费，有余额预留时返回错误
+func (wm *WalletManager) MaxSendAmount(to string) (*WithdrawalAmount, error) {
+
+	err := wm.checkSweepReservations()
+	if err != nil {
+		return nil, err
+	}
+
+	coins, err := wm.spendableCoins()
+	if err != nil {
+		return nil, err
+	}
+	if len(coins) == 0 {
+		return nil, openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "%v", ErrInsufficientCoins)
+	}
+
+	return wm.sweepAmount(to, coins)
+}
+
+//SendAll 把全部未锁定的可花费余额扣除手续费后在一笔交易中发送到to，用于冷钱包迁移和最终归集，
+//使用的UTXO全部锁定，交易结束前其他提现不会选中；提现队列有余额预留时拒绝发送
+func (wm *WalletManager) SendAll(to, comment string) (string, *WithdrawalAmount, error) {
+
+	err := wm.checkWithdrawalAddress(to)
+	if err != nil {
+		return "", nil, err
+	}
+
+	release, err := wm.withdrawals.Acquire()
+	if err != nil {
+		return "", nil, err
+	}
+	defer release()
+
+	err = wm.checkSweepReservations()
+	if err != nil {
+		return "", nil, err
+	}
+
+	coins, err := wm.spendableCoins()
+	if err != nil {
+		return "", nil, err
+	}
+
+	coins, err = wm.utxoLocks.lockAvailable(coins, "")
+	if err != nil {
+		return "", nil, openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "%v", err)
+	}
+
+	coinIDs := make([]string, 0, len(coins))
+	for _, utxo := range coins {
+		coinIDs = append(coinIDs, utxo.ID)
+	}
+	unlock := func() {
+		if unlockErr := wm.utxoLocks.unlock(coinIDs); unlockErr != nil {
+			wm.Log.Errorf("release utxo locks failed, unexpected error: %v", unlockErr)
+		}
+	}
+
+	withdrawal, err := wm.sweepAmount(to, coins)
+	if err != nil {
+		unlock()
+		return "", nil, err
+	}
+
+	from, err := wm.SenderAddress("")
+	if err != nil {
+		unlock()
+		return "", nil, err
+	}
+
+	//提现策略检查
+	done, err := wm.withdrawalPolicy.Evaluate(&WithdrawalPolicyRequest{From: from, To: to, AssetID: BeamAssetID, Amount: withdrawal.Send, Fee: withdrawal.Fee})
+	if err != nil {
+		unlock()
+		return "", nil, err
+	}
+
+	txid, err := wm.walletClient.SendTransactionWithCoins(context.Background(), from, to, withdrawal.Send, withdrawal.Fee, comment, "", coinIDs)
+	done(err == nil || !txNotSent(err))
+	if err != nil {
+		unlock()
+		return "", nil, err
+	}
+
+	if bindErr := wm.utxoLocks.bind(coinIDs, txid); bindErr != nil {
+		wm.Log.Errorf("bind utxo locks to tx: %s failed, unexpected error: %v", txid, bindErr)
+	}
+
+	wm.Log.Infof("send all %d coins to: %s, amount: %d, fee: %d, txid: %s", len(coinIDs), to, withdrawal.Send, withdrawal.Fee, txid)
+	return txid, withdrawal, nil
+}
@@ -0,0 +1,96 @@
+package beam
+
+import (
+	"testing"
+)
+
+func TestWalletManager_SendAll(t *testing.T) {
+
+	var sent map[string]interface{}
+	server := newRPCTestServer(rpcTestMethods{
+		"wallet_status": rpcResult(`{"current_height":100,"available":835}`),
+		"get_utxo": rpcResult(`[
+			{"id":"big","asset_id":0,"amount":800,"maturity":10,"status":1},
+			{"id":"b","asset_id":0,"amount":20,"maturity":10,"status":1},
+			{"id":"a","asset_id":0,"amount":10,"maturity":10,"status":1},
+			{"id":"young","asset_id":0,"amount":5,"maturity":200,"status":1}
+		]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false,"type":"regular"}`),
+		//钱包要求的手续费高于估算值
+		"calc_change": rpcResult(`{"change":0,"explicit_fee":150}`),
+		"addr_list":   rpcResult(`[{"address":"self-addr","own":true,"expired":false,"comment":"self"}]`),
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			sent = params
+			return `{"txId":"tx1"}`, nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	//b已被其他提现锁定
+	if _, err := wm.utxoLocks.selectAndLock(CoinSelectLargestFirst, []*Utxo{{ID: "b", Amount: 20}}, 20, "order-1"); err != nil {
+		t.Errorf("selectAndLock unexpected error: %v", err)
+		return
+	}
+
+	//提现队列有余额预留时不能全部发送
+	wm.Reservations().Reserve("queued-1", ReservationSourceWithdrawalQueue, 100)
+	if _, err := wm.MaxSendAmount("to"); err == nil {
+		t.Errorf("MaxSendAmount should fail while balance is reserved")
+	}
+	if _, _, err := wm.SendAll("to", "sweep"); err == nil || sent != nil {
+		t.Errorf("SendAll should fail while balance is reserved, sent: %v", sent)
+	}
+	wm.Reservations().Release("queued-1")
+
+	max, err := wm.MaxSendAmount("to")
+	if err != nil || max.Send != 660 || max.Fee != 150 {
+		t.Errorf("MaxSendAmount = %+v, %v, want 660 and 150", max, err)
+	}
+
+	txid, withdrawal, err := wm.SendAll("to", "sweep")
+	if err != nil || txid != "tx1" || withdrawal.Send != 660 {
+		t.Errorf("SendAll = %s, %+v, %v", txid, withdrawal, err)
+		return
+	}
+	coins, _ := sent["coins"].([]interface{})
+	if len(coins) != 2 || sent["value"] != float64(660) || sent["fee"] != float64(150) {
+		t.Errorf("tx_send params = %v", sent)
+	}
+
+	//全部UTXO已锁定
+	if _, _, err = wm.SendAll("to", ""); err == nil {
+		t.Errorf("SendAll should fail when all coins are locked")
+	}
+	locks, _ := wm.GetUtxoLocks()
+	if len(locks) != 3 {
+		t.Errorf("locks = %d, want 3", len(locks))
+	}
+}
+
+func TestWalletManager_SweepAmountShieldedInputs(t *testing.T) {
+
+	server := newRPCTestServer(rpcTestMethods{
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false,"type":"regular"}`),
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.fixfees = "0"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	coins := []*Utxo{
+		{ID: "a", Amount: 100000},
+		{ID: "s1", Amount: 100000, Type: UtxoTypeShielded},
+		{ID: "s2", Amount: 100000, Type: UtxoTypeShielded},
+	}
+	withdrawal, err := wm.sweepAmount("to", coins)
+	want := FeeParams{Kernels: 1, Outputs: 1, ShieldedInputs: 2}.MinFee()
+	if err != nil || withdrawal.Fee != want || withdrawal.Send != 300000-want {
+		t.Errorf("sweepAmount = %+v, %v, want fee %d", withdrawal, err, want)
+	}
+}
@@ -1,10 +1,12 @@
 package beam
 
 import (
+	"context"
 	"fmt"
 	"github.com/blocktree/openwallet/common"
 	"github.com/blocktree/openwallet/openwallet"
 	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
 	"math/big"
 	"time"
 )
@@ -37,51 +39,86 @@ func (decoder *TransactionDecoder) CreateRawTransaction(wrapper openwallet.Walle
 		amount = v
 	}
 
+	//合约代币为机密资产
+	assetID, err := ParseAssetID(rawTx.Coin)
+	if err != nil {
+		return err
+	}
+
 	amountDec, _ := decimal.NewFromString(amount)
-	amountDec = amountDec.Shift(decoder.wm.Decimal())
+	amountDec = amountDec.Shift(decoder.wm.assetDecimals(assetID))
+
+	//验证提现地址
+	err = decoder.wm.checkWithdrawalAddress(to)
+	if err != nil {
+		return err
+	}
 
-	//取一个地址作为发送
-	addresses, err := decoder.wm.walletClient.GetAddressList()
+	//提现地址白名单和策略文件的destinations，扩展参数whitelist_override可跳过白名单
+	err = decoder.wm.withdrawalPolicy.CheckDestination(to, rawTxWhitelistOverride(rawTx))
 	if err != nil {
 		return err
 	}
 
-	if addresses == nil || len(addresses) == 0 {
-		return openwallet.Errorf(openwallet.ErrAccountNotAddress, "wallet address is not created")
+	//验证交易备注
+	if _, err = rawTxComment(rawTx); err != nil {
+		return err
 	}
 
-	from := addresses[0]
+	//发送地址，扩展参数没有指定时使用withdrawfrom配置或钱包的第一个地址
+	from, err := decoder.wm.SenderAddress(rawTxSender(rawTx))
+	if err != nil {
+		return err
+	}
 
 	if len(rawTx.FeeRate) > 0 {
 		fixFees = common.StringNumToBigIntWithExp(rawTx.FeeRate, decoder.wm.Decimal())
 		rawTx.Fees = rawTx.FeeRate
 	} else {
-		fixFees = common.StringNumToBigIntWithExp(decoder.wm.Config.fixfees, decoder.wm.Decimal())
-		rawTx.FeeRate = decoder.wm.Config.fixfees
-		rawTx.Fees = decoder.wm.Config.fixfees
+		fee, err := decoder.wm.estimateWithdrawalFee(assetID, uint64(amountDec.IntPart()), to)
+		if err != nil {
+			return err
+		}
+		fixFees = new(big.Int).SetUint64(fee)
+		rawTx.FeeRate = common.IntToDecimals(int64(fee), decoder.wm.Decimal()).String()
+		rawTx.Fees = rawTx.FeeRate
 	}
 
 	if fixFees.Cmp(big.NewInt(0)) <= 0 {
 		return openwallet.Errorf(openwallet.ErrUnknownException, "fee is lower than 0")
 	}
 
-	walletStatus, err := decoder.wm.walletClient.GetWalletStatus()
-	if err != nil {
-		return err
-	}
+	var withdrawal *WithdrawalAmount
+	if assetID != BeamAssetID {
+		//机密资产全额发送，手续费另外使用BEAM支付
+		withdrawal, err = decoder.wm.assetWithdrawal(assetID, uint64(amountDec.IntPart()), fixFees.Uint64())
+		if err != nil {
+			return err
+		}
+	} else {
+		walletStatus, err := decoder.wm.walletClient.GetWalletStatus(context.Background())
+		if err != nil {
+			return err
+		}
 
-	sendAmount := uint64(amountDec.IntPart())
+		//按提现方式计算发送金额和手续费
+		withdrawal, err = decoder.wm.CalcWithdrawal(uint64(amountDec.IntPart()), fixFees.Uint64(), decoder.wm.Config.withdrawmode)
+		if err != nil {
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%v", err)
+		}
 
-	//判断钱包余额是否足够
-	if walletStatus.Available < sendAmount+fixFees.Uint64() {
-		return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "wallet available balance is not enough")
+		//判断钱包余额是否足够
+		//提现队列中未广播的提现预留的余额不能使用
+		if decoder.wm.unreservedBalance(walletStatus.Available) < withdrawal.Total() {
+			return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "wallet available balance is not enough")
+		}
 	}
 
+	sendAmount := common.IntToDecimals(int64(withdrawal.Send), decoder.wm.assetDecimals(assetID)).String()
 	txFrom = []string{fmt.Sprintf("%s:%s", from, amount)}
-	txTo = []string{fmt.Sprintf("%s:%s", to, amount)}
+	txTo = []string{fmt.Sprintf("%s:%s", to, sendAmount)}
 
-	rawTx.FeeRate = decoder.wm.Config.fixfees
-	rawTx.Fees = decoder.wm.Config.fixfees
+	rawTx.Fees = common.IntToDecimals(int64(withdrawal.Fee), decoder.wm.Decimal()).String()
 	rawTx.IsBuilt = true
 	rawTx.TxFrom = txFrom
 	rawTx.TxTo = txTo
@@ -119,47 +156,109 @@ func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.Walle
 		amount = v
 	}
 
+	//合约代币为机密资产
+	assetID, err := ParseAssetID(rawTx.Coin)
+	if err != nil {
+		return nil, err
+	}
+
 	amountDec, _ := decimal.NewFromString(amount)
-	amountDec = amountDec.Shift(decoder.wm.Decimal())
+	amountDec = amountDec.Shift(decoder.wm.assetDecimals(assetID))
+
+	//业务订单号已发送过时返回已发送的交易单，网络超时后重试不会重复发送
+	var txID string
+	if len(rawTx.Sid) > 0 {
+		txID, _ = decoder.wm.GetSendTxID(rawTx.Sid)
+		if len(txID) > 0 {
+			sentTx, err := decoder.wm.walletClient.GetTransaction(context.Background(), txID)
+			if err == nil && sentTx.TxID == txID {
+				decoder.wm.Log.Infof("Transaction [%s] of sid: %s has been submitted.", txID, rawTx.Sid)
+				return decoder.submittedTransaction(rawTx, sentTx), nil
+			}
+		}
+	}
 
-	//取一个地址作为发送
-	addresses, err := decoder.wm.walletClient.GetAddressList()
+	//验证提现地址
+	err = decoder.wm.checkWithdrawalAddress(to)
 	if err != nil {
 		return nil, err
 	}
 
-	if addresses == nil || len(addresses) == 0 {
-		return nil, fmt.Errorf("wallet address is not created")
+	//交易备注，扩展参数指定，随交易发给接收方，扫块时原样提取
+	comment, err := rawTxComment(rawTx)
+	if err != nil {
+		return nil, err
 	}
 
-	from := addresses[0]
+	//发送地址，扩展参数没有指定时使用withdrawfrom配置或钱包的第一个地址
+	from, err := decoder.wm.SenderAddress(rawTxSender(rawTx))
+	if err != nil {
+		return nil, err
+	}
 
 	if len(rawTx.FeeRate) > 0 {
 		fixFees = common.StringNumToBigIntWithExp(rawTx.FeeRate, decoder.wm.Decimal())
 		rawTx.Fees = rawTx.FeeRate
 	} else {
-		fixFees = common.StringNumToBigIntWithExp(decoder.wm.Config.fixfees, decoder.wm.Decimal())
-		rawTx.FeeRate = decoder.wm.Config.fixfees
-		rawTx.Fees = decoder.wm.Config.fixfees
+		fee, err := decoder.wm.estimateWithdrawalFee(assetID, uint64(amountDec.IntPart()), to)
+		if err != nil {
+			return nil, err
+		}
+		fixFees = new(big.Int).SetUint64(fee)
+		rawTx.FeeRate = common.IntToDecimals(int64(fee), decoder.wm.Decimal()).String()
+		rawTx.Fees = rawTx.FeeRate
 	}
 
 	if fixFees.Cmp(big.NewInt(0)) <= 0 {
 		return nil, openwallet.Errorf(openwallet.ErrUnknownException, "fee is lower than 0")
 	}
 
-	walletStatus, err := decoder.wm.walletClient.GetWalletStatus()
-	if err != nil {
-		return nil, err
-	}
+	var withdrawal *WithdrawalAmount
+	if assetID != BeamAssetID {
+		//机密资产全额发送，手续费另外使用BEAM支付
+		withdrawal, err = decoder.wm.assetWithdrawal(assetID, uint64(amountDec.IntPart()), fixFees.Uint64())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		walletStatus, err := decoder.wm.walletClient.GetWalletStatus(context.Background())
+		if err != nil {
+			return nil, err
+		}
 
-	sendAmount := uint64(amountDec.IntPart())
+		//按提现方式计算发送金额和手续费
+		withdrawal, err = decoder.wm.CalcWithdrawal(uint64(amountDec.IntPart()), fixFees.Uint64(), decoder.wm.Config.withdrawmode)
+		if err != nil {
+			return nil, openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "%v", err)
+		}
 
-	//判断钱包余额是否足够
-	if walletStatus.Available < sendAmount+fixFees.Uint64() {
-		return nil, openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "wallet available balance is not enough")
+		//判断钱包余额是否足够
+		//提现队列中未广播的提现预留的余额不能使用
+		if decoder.wm.unreservedBalance(walletStatus.Available) < withdrawal.Total() {
+			return nil, openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "wallet available balance is not enough")
+		}
+	}
+
+	//预先生成交易ID并记录，重试时使用相同的交易ID，钱包不会重复发送，开启重发时没有业务订单号也需要交易ID
+	if (len(rawTx.Sid) > 0 || decoder.wm.IsFeatureEnabled(FeatureTxResend)) && len(txID) == 0 {
+		txID, err = decoder.wm.walletClient.GenerateTxID(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		if len(rawTx.Sid) > 0 {
+			err = decoder.wm.SaveSendTxID(rawTx.Sid, txID)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	txid, err := decoder.wm.walletClient.SendTransaction(from, to, sendAmount, fixFees.Uint64(), "")
+	var txid string
+	if assetID != BeamAssetID {
+		txid, err = decoder.wm.sendAssetWithdrawal(from, to, assetID, withdrawal, comment, txID, rawTxWhitelistOverride(rawTx))
+	} else {
+		txid, err = decoder.wm.sendWithdrawalOrResend(from, to, withdrawal, comment, txID, rawTx.Sid, rawTxWhitelistOverride(rawTx))
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -169,31 +268,65 @@ func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.Walle
 	rawTx.TxID = txid
 	rawTx.IsSubmit = true
 
-	txFrom := []string{fmt.Sprintf("%s:%s", from, amount)}
-	txTo := []string{fmt.Sprintf("%s:%s", to, amount)}
+	decimals := decoder.wm.assetDecimals(assetID)
 
-	decimals := decoder.wm.Decimal()
+	sendAmount := common.IntToDecimals(int64(withdrawal.Send), decimals).String()
+	rawTx.Fees = common.IntToDecimals(int64(withdrawal.Fee), decoder.wm.Decimal()).String()
+	txFrom := []string{fmt.Sprintf("%s:%s", from, amount)}
+	txTo := []string{fmt.Sprintf("%s:%s", to, sendAmount)}
 
 	//记录一个交易单
 	tx := &openwallet.Transaction{
 		From:       txFrom,
 		To:         txTo,
-		Amount:     amount,
+		Amount:     sendAmount,
 		Coin:       rawTx.Coin,
 		TxID:       rawTx.TxID,
 		Decimal:    decimals,
 		Fees:       rawTx.Fees,
 		SubmitTime: time.Now().Unix(),
 	}
+	if len(comment) > 0 {
+		tx.SetExtParam("comment", comment)
+	}
 
 	tx.WxID = openwallet.GenTransactionWxID(tx)
 
 	return tx, nil
 }
 
+//submittedTransaction 已发送的钱包交易单转为openwallet交易单
+func (decoder *TransactionDecoder) submittedTransaction(rawTx *openwallet.RawTransaction, sentTx *Transaction) *openwallet.Transaction {
+
+	decimals := decoder.wm.assetDecimals(sentTx.AssetID)
+	amount := common.IntToDecimals(int64(sentTx.Value), decimals).String()
+
+	rawTx.TxID = sentTx.TxID
+	rawTx.Fees = common.IntToDecimals(int64(sentTx.Fee), decoder.wm.Decimal()).String()
+	rawTx.IsSubmit = true
+
+	tx := &openwallet.Transaction{
+		From:       []string{fmt.Sprintf("%s:%s", sentTx.Sender, amount)},
+		To:         []string{fmt.Sprintf("%s:%s", sentTx.Receiver, amount)},
+		Amount:     amount,
+		Coin:       rawTx.Coin,
+		TxID:       rawTx.TxID,
+		Decimal:    decimals,
+		Fees:       rawTx.Fees,
+		SubmitTime: sentTx.CreateTime,
+	}
+	if len(sentTx.Comment) > 0 {
+		tx.SetExtParam("comment", sentTx.Comment)
+	}
+
+	tx.WxID = openwallet.GenTransactionWxID(tx)
+
+	return tx
+}
+
 //GetRawTransactionFeeRate 获取交易单的费率
 func (decoder *TransactionDecoder) GetRawTransactionFeeRate() (feeRate string, unit string, err error) {
-	return decoder.wm.Config.fixfees, "TX", nil
+	return common.IntToDecimals(int64(decoder.wm.DefaultFee()), decoder.wm.Decimal()).String(), "TX", nil
 }
 
 //CreateSummaryRawTransaction 创建汇总交易
@@ -219,6 +352,11 @@ func (decoder *TransactionDecoder) CreateSummaryRawTransaction(wrapper openwalle
 //CreateSummaryRawTransactionWithError 创建汇总交易，返回能原始交易单数组（包含带错误的原始交易单）
 func (decoder *TransactionDecoder) CreateSummaryRawTransactionWithError(wrapper openwallet.WalletDAI, sumRawTx *openwallet.SummaryRawTransaction) ([]*openwallet.RawTransactionWithError, error) {
 
+	//账户配置了汇总策略时按策略的最低汇总金额和汇总地址
+	if err := decoder.wm.applySummaryPolicy(sumRawTx); err != nil {
+		return nil, err
+	}
+
 	var (
 		decimals        = decoder.wm.Decimal()
 		rawTxArray      = make([]*openwallet.RawTransactionWithError, 0)
@@ -233,37 +371,50 @@ func (decoder *TransactionDecoder) CreateSummaryRawTransactionWithError(wrapper
 
 	if len(sumRawTx.FeeRate) > 0 {
 		fixFees = common.StringNumToBigIntWithExp(sumRawTx.FeeRate, decoder.wm.Decimal())
-	} else {
-		fixFees = common.StringNumToBigIntWithExp(decoder.wm.Config.fixfees, decoder.wm.Decimal())
-		sumRawTx.FeeRate = decoder.wm.Config.fixfees
 	}
 
-	if fixFees.Cmp(big.NewInt(0)) <= 0 {
-		return nil, openwallet.Errorf(openwallet.ErrUnknownException, "fee is lower than 0")
+	walletStatus, err := decoder.wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return nil, err
 	}
 
-	walletStatus, err := decoder.wm.walletClient.GetWalletStatus()
+	//预留给未广播提现的余额和归档地址收到的余额不汇总
+	available, err := decoder.wm.summaryBalance(decoder.wm.unreservedBalance(walletStatus.Available))
 	if err != nil {
 		return nil, err
 	}
 
+	if len(sumRawTx.FeeRate) == 0 {
+		fee, err := decoder.wm.EstimateFee(available, []string{sumRawTx.SummaryAddress})
+		if err != nil {
+			return nil, err
+		}
+		fixFees = new(big.Int).SetUint64(fee)
+		sumRawTx.FeeRate = common.IntToDecimals(int64(fee), decoder.wm.Decimal()).String()
+	}
+
+	if fixFees.Cmp(big.NewInt(0)) <= 0 {
+		return nil, openwallet.Errorf(openwallet.ErrUnknownException, "fee is lower than 0")
+	}
+
 	//检查余额是否超过最低转账
 	addrBalance_BI := new(big.Int)
-	addrBalance_BI.SetUint64(walletStatus.Available)
-	addrBalance := common.IntToDecimals(int64(walletStatus.Available), decoder.wm.Decimal())
+	addrBalance_BI.SetUint64(available)
+	addrBalance := common.IntToDecimals(int64(available), decoder.wm.Decimal())
 
 	if addrBalance_BI.Cmp(minTransfer) < 0 || addrBalance_BI.Cmp(big.NewInt(0)) <= 0 {
 		return rawTxArray, nil
 	}
-	//计算汇总数量 = 余额 - 保留余额
-	sumAmount_BI := new(big.Int)
-	sumAmount_BI.Sub(addrBalance_BI, retainedBalance)
-
-	//减去手续费
-	sumAmount_BI.Sub(sumAmount_BI, fixFees)
-	if sumAmount_BI.Cmp(big.NewInt(0)) <= 0 {
+	//计算汇总数量 = 余额 - 保留余额（与summaryminbalance取较大的一个） - 手续费，
+	//summaryfeefrom为reserve时改为减去预留的手续费余额
+	sweep, sweepErr := decoder.wm.summarySweepAmount(available, retainedBalance.Uint64(), fixFees.Uint64())
+	if sweepErr != nil {
+		decoder.wm.Log.Warningf("summary skipped: %v", sweepErr)
+	}
+	if sweep == 0 {
 		return rawTxArray, nil
 	}
+	sumAmount_BI := new(big.Int).SetUint64(sweep)
 
 	sumAmount := common.BigIntToDecimals(sumAmount_BI, decimals)
 	feesAmount := common.BigIntToDecimals(fixFees, decimals)
@@ -279,6 +430,7 @@ func (decoder *TransactionDecoder) CreateSummaryRawTransactionWithError(wrapper
 		To: map[string]string{
 			sumRawTx.SummaryAddress: sumAmount.StringFixed(decoder.wm.Decimal()),
 		},
+		FeeRate:  sumRawTx.FeeRate,
 		Required: 1,
 	}
 
@@ -293,3 +445,20 @@ func (decoder *TransactionDecoder) CreateSummaryRawTransactionWithError(wrapper
 
 	return rawTxArray, nil
 }
+
+//rawTxComment 交易单扩展参数中指定的交易备注：{"comment": "..."}
+func rawTxComment(rawTx *openwallet.RawTransaction) (string, error) {
+	if len(rawTx.ExtParam) == 0 {
+		return "", nil
+	}
+	comment := gjson.Get(rawTx.ExtParam, "comment").String()
+	return comment, checkTxComment(comment)
+}
+
+//checkTxComment 交易备注不能超过MaxTxCommentLength字节
+func checkTxComment(comment string) error {
+	if len(comment) > MaxTxCommentLength {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "comment length: %d exceeds %d bytes", len(comment), MaxTxCommentLength)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package beam
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestTransactionDecoder_SubmitRawTransactionWithSid(t *testing.T) {
+
+	var (
+		sends int
+		sent  = make(map[string]bool)
+	)
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list":        rpcResult(`[{"address":"from","own":true,"comment":"self"}]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false}`),
+		"wallet_status":    rpcResult(`{"available":100000000}`),
+		"generate_tx_id":   rpcResult(`"pregenerated"`),
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			sends++
+			txID := params["txId"].(string)
+			sent[txID] = true
+			return fmt.Sprintf(`{"txId":"%s"}`, txID), nil
+		},
+		"tx_status": func(params map[string]interface{}) (string, error) {
+			txID := params["txId"].(string)
+			if !sent[txID] {
+				return "", &rpcTestError{Code: -32003, Message: "tx not found"}
+			}
+			return fmt.Sprintf(`{"txId":"%s","sender":"from","receiver":"to","value":100,"fee":100,"status":1}`, txID), nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	decoder := NewTransactionDecoder(wm)
+
+	for i := 0; i < 2; i++ {
+		rawTx := &openwallet.RawTransaction{
+			Sid: "order-1",
+			To:  map[string]string{"to": "0.000001"},
+		}
+		tx, err := decoder.SubmitRawTransaction(nil, rawTx)
+		if err != nil {
+			t.Fatalf("SubmitRawTransaction unexpected error: %v", err)
+		}
+		if tx.TxID != "pregenerated" || rawTx.TxID != "pregenerated" {
+			t.Errorf("SubmitRawTransaction txid = %s, want pregenerated", tx.TxID)
+		}
+	}
+
+	if sends != 1 {
+		t.Errorf("tx_send calls = %d, want 1", sends)
+	}
+
+	txID, err := wm.GetSendTxID("order-1")
+	if err != nil || txID != "pregenerated" {
+		t.Errorf("GetSendTxID = %s, err = %v", txID, err)
+	}
+}
+
+func TestTransactionDecoder_SubmitRawTransactionWithComment(t *testing.T) {
+
+	var sendParams map[string]interface{}
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list":        rpcResult(`[{"address":"from","own":true,"comment":"self"}]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false}`),
+		"wallet_status":    rpcResult(`{"available":100000000}`),
+		"generate_tx_id":   rpcResult(`"pregenerated"`),
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			sendParams = params
+			return `{"txId":"pregenerated"}`, nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	decoder := NewTransactionDecoder(wm)
+
+	rawTx := &openwallet.RawTransaction{
+		To:       map[string]string{"to": "0.000001"},
+		ExtParam: `{"comment":"order-1001"}`,
+	}
+	tx, err := decoder.SubmitRawTransaction(nil, rawTx)
+	if err != nil {
+		t.Fatalf("SubmitRawTransaction unexpected error: %v", err)
+	}
+	if sendParams["comment"] != "order-1001" {
+		t.Errorf("tx_send comment = %v, want order-1001", sendParams["comment"])
+	}
+	if tx.GetExtParam().Get("comment").String() != "order-1001" {
+		t.Errorf("tx ext param = %v", tx.ExtParam)
+	}
+
+	//备注超过最大长度
+	rawTx = &openwallet.RawTransaction{
+		To:       map[string]string{"to": "0.000001"},
+		ExtParam: fmt.Sprintf(`{"comment":"%0*d"}`, MaxTxCommentLength+1, 0),
+	}
+	sendParams = nil
+	if _, err := decoder.SubmitRawTransaction(nil, rawTx); err == nil || sendParams != nil {
+		t.Errorf("SubmitRawTransaction should fail when comment exceeds max length")
+	}
+}
@@ -0,0 +1,80 @@
+package beam
+
+import (
+	"time"
+
+	"github.com/blocktree/openwallet/timer"
+)
+
+//ExpiredTxEvent 发送超时的提现交易单被自动取消
+type ExpiredTxEvent struct {
+	TxID       string `json:"txid"`
+	Receiver   string `json:"receiver"`
+	Comment    string `json:"comment"`
+	Value      uint64 `json:"value"`
+	Fee        uint64 `json:"fee"`
+	CreateTime int64  `json:"createTime"`
+	Age        int64  `json:"age"` //发送时长，单位秒
+	Canceled   bool   `json:"canceled"`
+	Error      string `json:"error,omitempty"`
+	Time       int64  `json:"time"`
+}
+
+//ExpiredTxHandler 发送超时交易单的告警处理
+type ExpiredTxHandler func(event *ExpiredTxEvent)
+
+//AddExpiredTxHandler 添加发送超时交易单的告警处理，需要在启动扫描、汇总或超时检查任务前添加
+func (wm *WalletManager) AddExpiredTxHandler(handler ExpiredTxHandler) {
+	wm.expiredTxHandlers = append(wm.expiredTxHandlers, handler)
+}
+
+//notifyExpiredTx 记录告警日志并通知告警处理
+func (wm *WalletManager) notifyExpiredTx(tx *Transaction, now time.Time, canceled bool, err error) {
+
+	event := &ExpiredTxEvent{
+		TxID:       tx.TxID,
+		Receiver:   tx.Receiver,
+		Comment:    tx.Comment,
+		Value:      tx.Value,
+		Fee:        tx.Fee,
+		CreateTime: tx.CreateTime,
+		Age:        now.Unix() - tx.CreateTime,
+		Canceled:   canceled,
+		Time:       now.Unix(),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	wm.Log.Warningf("expired tx: %s to: %s, comment: %s, value: %d, sending for %ds, canceled: %v %s",
+		event.TxID, event.Receiver, event.Comment, event.Value, event.Age, event.Canceled, event.Error)
+
+	for _, handler := range wm.expiredTxHandlers {
+		handler(event)
+	}
+}
+
+//txSendingTimeout 交易单的发送超时，开启shielded时隐私池交易使用单独的超时
+func (wm *WalletManager) txSendingTimeout(tx *Transaction) time.Duration {
+	if tx.Shielded && wm.IsFeatureEnabled(FeatureShielded) {
+		return wm.Config.shieldedtxsendingtimeout
+	}
+	return wm.Config.txsendingtimeout
+}
+
+//StartExpiredTxWatcher 启动发送超时交易单的检查任务，不依赖区块扫描和汇总任务
+func (wm *WalletManager) StartExpiredTxWatcher() {
+
+	if !wm.IsFeatureEnabled(FeatureExpiredTxWatcher) || wm.expiredTxWatcher != nil {
+		return
+	}
+
+	wm.Log.Infof("The timer for expired tx watcher start now. Execute by every %v seconds.", wm.Config.txexpirycheckperiod.Seconds())
+
+	wm.expiredTxWatcher = timer.NewTask(wm.Config.txexpirycheckperiod, func() {
+		if err := wm.ClearExpireTx(); err != nil {
+			wm.Log.Errorf("clear expired tx unexpected error: %v", err)
+		}
+	})
+	wm.expiredTxWatcher.Start()
+}
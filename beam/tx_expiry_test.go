@@ -0,0 +1,95 @@
+package beam
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestWalletManager_ClearExpireTx(t *testing.T) {
+
+	var (
+		canceled []string
+		old      = time.Now().Add(-time.Hour).Unix()
+	)
+	server := newRPCTestServer(rpcTestMethods{
+		"tx_list": func(params map[string]interface{}) (string, error) {
+			if params["skip"] != float64(0) {
+				return `[]`, nil
+			}
+			return fmt.Sprintf(`[
+				{"txId":"stuck","status":1,"income":false,"receiver":"offline-addr","comment":"order-1","value":1000,"create_time":%d},
+				{"txId":"deposit","status":1,"income":true,"create_time":%d},
+				{"txId":"fresh","status":1,"income":false,"create_time":%d}
+			]`, old, old, time.Now().Unix()), nil
+		},
+		"tx_cancel": func(params map[string]interface{}) (string, error) {
+			canceled = append(canceled, params["txId"].(string))
+			return "true", nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.txsendingtimeout = 5 * time.Minute
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	var events []*ExpiredTxEvent
+	wm.AddExpiredTxHandler(func(event *ExpiredTxEvent) {
+		events = append(events, event)
+	})
+
+	wm.utxoLocks.selectAndLock(CoinSelectLargestFirst, testCoins(10), 10, "order-1")
+	wm.utxoLocks.bind([]string{"a"}, "stuck")
+
+	if err := wm.ClearExpireTx(); err != nil {
+		t.Errorf("ClearExpireTx unexpected error: %v", err)
+		return
+	}
+
+	if len(canceled) != 1 || canceled[0] != "stuck" {
+		t.Errorf("canceled = %v, want stuck", canceled)
+	}
+	if len(events) != 1 || events[0].Receiver != "offline-addr" || events[0].Comment != "order-1" || !events[0].Canceled || events[0].Age < 3600 {
+		t.Errorf("events = %+v", events)
+	}
+	if locks, _ := wm.GetUtxoLocks(); len(locks) != 0 {
+		t.Errorf("locks = %+v, want released", locks)
+	}
+}
+
+func TestWalletManager_TxSendingTimeout(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.txsendingtimeout = 5 * time.Minute
+	wm.Config.shieldedtxsendingtimeout = time.Hour
+
+	for _, tt := range []struct {
+		json     string
+		shielded bool
+	}{
+		{`{"txId":"t1","tx_type":0}`, false},
+		{`{"txId":"t2","tx_type":7}`, true},
+		{`{"txId":"t3","tx_type":8}`, true},
+		{`{"txId":"t4","tx_type":0,"address_type":"max_privacy"}`, true},
+	} {
+		result := gjson.Parse(tt.json)
+		tx := NewTransaction(&result)
+		if tx.Shielded != tt.shielded {
+			t.Errorf("%s Shielded = %v, want %v", tx.TxID, tx.Shielded, tt.shielded)
+		}
+		//未开启shielded时使用普通交易的超时
+		if timeout := wm.txSendingTimeout(tx); timeout != wm.Config.txsendingtimeout {
+			t.Errorf("%s timeout = %v before shielded enabled", tx.TxID, timeout)
+		}
+	}
+
+	wm.Config.features[FeatureShielded] = true
+	result := gjson.Parse(`{"txId":"t2","tx_type":7}`)
+	if timeout := wm.txSendingTimeout(NewTransaction(&result)); timeout != time.Hour {
+		t.Errorf("shielded timeout = %v, want 1h", timeout)
+	}
+}
@@ -0,0 +1,116 @@
+package beam
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+//GetTxHistoryByAddress 从本地账本查询地址的交易记录，from、to为交易时间（unix秒）的范围，0表示不限制，
+//按时间倒序返回最多limit条，limit不大于0时返回全部；只包含扫块提取和迁移导入的交易，不需要遍历钱包的tx_list
+func (wm *WalletManager) GetTxHistoryByAddress(address string, from, to int64, limit int) ([]*openwallet.Transaction, error) {
+
+	address = wm.addressMapper.Resolve(address)
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return nil, err
+	}
+	var records []*LedgerRecord
+	err = db.Find("Address", address, &records)
+	db.Close()
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	//同一交易的入账和出账合并为一条交易记录
+	groups := make(map[string][]*LedgerRecord)
+	txids := make([]string, 0)
+	for _, r := range records {
+		if (from > 0 && r.CreateTime < from) || (to > 0 && r.CreateTime > to) {
+			continue
+		}
+		if _, ok := groups[r.TxID]; !ok {
+			txids = append(txids, r.TxID)
+		}
+		groups[r.TxID] = append(groups[r.TxID], r)
+	}
+
+	sort.SliceStable(txids, func(i, j int) bool {
+		a, b := groups[txids[i]][0], groups[txids[j]][0]
+		if a.CreateTime != b.CreateTime {
+			return a.CreateTime > b.CreateTime
+		}
+		return a.BlockHeight > b.BlockHeight
+	})
+	if limit > 0 && len(txids) > limit {
+		txids = txids[:limit]
+	}
+
+	coins := make(map[int64]openwallet.Coin)
+	list := make([]*openwallet.Transaction, 0, len(txids))
+	for _, txid := range txids {
+		list = append(list, wm.ledgerTransaction(address, groups[txid], coins))
+	}
+	return list, nil
+}
+
+//ledgerTransaction 把地址在同一交易的账本记录转为交易记录，coins缓存机密资产信息
+func (wm *WalletManager) ledgerTransaction(address string, records []*LedgerRecord, coins map[int64]openwallet.Coin) *openwallet.Transaction {
+
+	var in, out *LedgerRecord
+	for _, r := range records {
+		if r.Direction == LedgerDirectionOut {
+			out = r
+		} else {
+			in = r
+		}
+	}
+
+	first := records[0]
+	coin, ok := coins[first.AssetID]
+	if !ok {
+		coin = wm.AssetCoin(first.AssetID)
+		coins[first.AssetID] = coin
+	}
+
+	direction := first.Direction
+	if in != nil && out != nil {
+		direction = "self"
+	}
+
+	fees := "0"
+	from, to := first.Peer, first.Peer
+	if out != nil {
+		from = address
+		if len(out.Fees) > 0 {
+			fees = out.Fees
+		}
+	}
+	if in != nil {
+		to = address
+	}
+
+	tx := &openwallet.Transaction{
+		TxID:        first.TxID,
+		Coin:        coin,
+		Amount:      first.Amount,
+		Fees:        fees,
+		Decimal:     wm.assetDecimals(first.AssetID),
+		BlockHash:   first.BlockHash,
+		BlockHeight: first.BlockHeight,
+		ConfirmTime: first.CreateTime,
+		Status:      "1",
+		From:        []string{from + ":" + first.Amount},
+		To:          []string{to + ":" + first.Amount},
+	}
+	tx.SetExtParam("direction", direction)
+	tx.SetExtParam("source", first.Source)
+	if len(first.AccountID) > 0 {
+		tx.SetExtParam("account_id", first.AccountID)
+	}
+	tx.WxID = openwallet.GenTransactionWxID(tx)
+	return tx
+}
@@ -0,0 +1,62 @@
+package beam
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+func TestGetTxHistoryByAddress(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	scanner := NewBEAMBlockScanner(wm)
+
+	txs := []string{
+		`{"txId":"t1","income":true,"sender":"payer","receiver":"deposit","value":100000000,"fee":100,"create_time":1000}`,
+		`{"txId":"t2","income":false,"sender":"deposit","receiver":"other","value":50000000,"fee":100,"create_time":2000}`,
+		`{"txId":"t3","income":true,"sender":"payer","receiver":"deposit","value":300000000,"fee":100,"create_time":3000}`,
+		`{"txId":"t4","income":true,"sender":"payer","receiver":"another","value":100000000,"fee":100,"create_time":4000}`,
+	}
+	for i, s := range txs {
+		result := gjson.Parse(s)
+		extract := scanner.ExtractTransaction(context.Background(), uint64(10+i), "h", NewTransaction(&result), func(target openwallet.ScanTarget) (string, bool) {
+			return "user", target.Address == "deposit" || target.Address == "another"
+		})
+		for key, list := range extract.extractData {
+			for _, data := range list {
+				scanner.recordLedger(key, data)
+			}
+		}
+	}
+
+	list, err := wm.GetTxHistoryByAddress("deposit", 0, 0, 0)
+	if err != nil || len(list) != 3 {
+		t.Errorf("GetTxHistoryByAddress = %d, err = %v", len(list), err)
+		return
+	}
+	if list[0].TxID != "t3" || list[2].TxID != "t1" {
+		t.Errorf("history order = %s, %s, %s", list[0].TxID, list[1].TxID, list[2].TxID)
+	}
+
+	in := list[2]
+	if in.Amount != "1" || in.Fees != "0" || in.From[0] != "payer:1" || in.To[0] != "deposit:1" ||
+		in.GetExtParam().Get("direction").String() != LedgerDirectionIn {
+		t.Errorf("deposit tx = %+v", in)
+	}
+	out := list[1]
+	if out.Amount != "0.5" || out.Fees != "0.000001" || out.From[0] != "deposit:0.5" || out.To[0] != "other:0.5" ||
+		out.GetExtParam().Get("direction").String() != LedgerDirectionOut {
+		t.Errorf("withdrawal tx = %+v", out)
+	}
+
+	list, _ = wm.GetTxHistoryByAddress("deposit", 1500, 3000, 1)
+	if len(list) != 1 || list[0].TxID != "t3" {
+		t.Errorf("GetTxHistoryByAddress range = %v", list)
+	}
+	if list, _ = wm.GetTxHistoryByAddress("unknown", 0, 0, 0); len(list) != 0 {
+		t.Errorf("GetTxHistoryByAddress unknown = %d, want 0", len(list))
+	}
+}
@@ -0,0 +1,141 @@
+package beam
+
+import (
+	"context"
+)
+
+//TxListFilter tx_list的服务端过滤条件
+type TxListFilter struct {
+	Status *int   //交易状态，nil表示不过滤
+	Height uint64 //区块高度，0表示不过滤
+}
+
+//HeightFilter 按区块高度过滤
+func HeightFilter(height uint64) TxListFilter {
+	return TxListFilter{Height: height}
+}
+
+//StatusFilter 按交易状态过滤
+func StatusFilter(status int) TxListFilter {
+	return TxListFilter{Status: &status}
+}
+
+//params tx_list的请求参数，包含机密资产的交易单
+func (f TxListFilter) params(skip, count uint64) map[string]interface{} {
+	filter := make(map[string]interface{})
+	if f.Status != nil {
+		filter["status"] = *f.Status
+	}
+	if f.Height > 0 {
+		filter["height"] = f.Height
+	}
+	request := map[string]interface{}{
+		"filter": filter,
+		"skip":   skip,
+		"assets": true,
+	}
+	if count > 0 {
+		request["count"] = count
+	}
+	return request
+}
+
+//SetTxPageSize 设置分页拉取交易单的每页数量
+func (c *WalletClient) SetTxPageSize(pageSize uint64) {
+	if pageSize == 0 {
+		pageSize = DefaultTxPageSize
+	}
+	c.txPageSize = pageSize
+}
+
+//ListTransactions 获取一页过滤后的交易单
+func (c *WalletClient) ListTransactions(ctx context.Context, filter TxListFilter, skip, count uint64) ([]*Transaction, error) {
+
+	r, err := c.callContext(ctx, "tx_list", filter.params(skip, count))
+	if err != nil {
+		return nil, err
+	}
+
+	txs := make([]*Transaction, 0)
+	if r.IsArray() {
+		for _, obj := range r.Array() {
+			txs = append(txs, NewTransaction(&obj))
+		}
+	}
+
+	return txs, nil
+}
+
+//TxListIterator 分页遍历tx_list，每次只加载一页交易单
+type TxListIterator struct {
+	api      WalletAPI
+	filter   TxListFilter
+	pageSize uint64
+	skip     uint64
+	done     bool
+}
+
+//IterateTransactions 创建分页遍历器，pageSize为0时使用客户端的每页数量
+func (c *WalletClient) IterateTransactions(filter TxListFilter, pageSize uint64) *TxListIterator {
+	if pageSize == 0 {
+		pageSize = c.txPageSize
+	}
+	return NewTxListIterator(c, filter, pageSize)
+}
+
+//NewTxListIterator 创建分页遍历器，通过api的ListTransactions分页获取
+func NewTxListIterator(api WalletAPI, filter TxListFilter, pageSize uint64) *TxListIterator {
+	if pageSize == 0 {
+		pageSize = DefaultTxPageSize
+	}
+	return &TxListIterator{
+		api:      api,
+		filter:   filter,
+		pageSize: pageSize,
+	}
+}
+
+//Next 获取下一页交易单，遍历结束后返回空
+func (it *TxListIterator) Next(ctx context.Context) ([]*Transaction, error) {
+
+	if it.done {
+		return nil, nil
+	}
+
+	txs, err := it.api.ListTransactions(ctx, it.filter, it.skip, it.pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	it.skip += uint64(len(txs))
+	if uint64(len(txs)) < it.pageSize {
+		it.done = true
+	}
+
+	return txs, nil
+}
+
+//Done 是否已遍历结束
+func (it *TxListIterator) Done() bool {
+	return it.done
+}
+
+//listTransactions 分页获取全部过滤后的交易单
+func (c *WalletClient) listTransactions(ctx context.Context, filter TxListFilter) ([]*Transaction, error) {
+	return c.IterateTransactions(filter, 0).collect(ctx, nil)
+}
+
+//collect 拉取剩余的所有页，追加到txs
+func (it *TxListIterator) collect(ctx context.Context, txs []*Transaction) ([]*Transaction, error) {
+	if txs == nil {
+		txs = make([]*Transaction, 0)
+	}
+	for !it.Done() {
+		page, err := it.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, page...)
+	}
+	return txs, nil
+}
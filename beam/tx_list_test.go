@@ -0,0 +1,76 @@
+package beam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTxListFilter_Params(t *testing.T) {
+
+	params := StatusFilter(TxStatusPending).params(0, 10)
+	filter := params["filter"].(map[string]interface{})
+	if filter["status"] != TxStatusPending || filter["height"] != nil || params["count"] != uint64(10) {
+		t.Errorf("StatusFilter params = %v", params)
+	}
+
+	params = HeightFilter(100).params(20, 0)
+	filter = params["filter"].(map[string]interface{})
+	if filter["height"] != uint64(100) || filter["status"] != nil || params["skip"] != uint64(20) || params["count"] != nil {
+		t.Errorf("HeightFilter params = %v", params)
+	}
+}
+
+func TestWalletClient_IterateTransactions(t *testing.T) {
+
+	const total = 7
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		var body struct {
+			Params struct {
+				Skip  int `json:"skip"`
+				Count int `json:"count"`
+			} `json:"params"`
+		}
+		json.Unmarshal(data, &body)
+		requests = append(requests, fmt.Sprintf("%d/%d", body.Params.Skip, body.Params.Count))
+
+		txs := make([]string, 0)
+		for i := body.Params.Skip; i < total && i < body.Params.Skip+body.Params.Count; i++ {
+			txs = append(txs, fmt.Sprintf(`{"txId":"tx%d","height":10}`, i))
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":[` + strings.Join(txs, ",") + `]}`))
+	}))
+	defer server.Close()
+
+	c := NewWalletClient(server.URL, server.URL, false)
+	it := c.IterateTransactions(HeightFilter(10), 3)
+	count := 0
+	for !it.Done() {
+		txs, err := it.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Next unexpected error: %v", err)
+		}
+		count += len(txs)
+	}
+	if count != total || strings.Join(requests, ",") != "0/3,3/3,6/3" {
+		t.Errorf("iterate count = %d, requests = %v", count, requests)
+	}
+
+	//整页时多请求一次空页
+	requests = nil
+	c.SetTxPageSize(7)
+	txs, err := c.GetTransactionsByHeight(context.Background(), 10)
+	if err != nil || len(txs) != total || txs[6].TxID != "tx6" {
+		t.Errorf("GetTransactionsByHeight = %d, err = %v", len(txs), err)
+	}
+	if strings.Join(requests, ",") != "0/7,7/7" {
+		t.Errorf("GetTransactionsByHeight requests = %v", requests)
+	}
+}
@@ -0,0 +1,69 @@
+package beam
+
+import (
+	"context"
+	"sync"
+)
+
+//txPrefetch 追块时批量预取的后续区块交易单，每个高度只使用一次
+type txPrefetch struct {
+	mu  sync.Mutex
+	txs map[uint64][]*Transaction
+}
+
+func newTxPrefetch() *txPrefetch {
+	return &txPrefetch{txs: make(map[uint64][]*Transaction)}
+}
+
+//take 取出预取的交易单
+func (p *txPrefetch) take(height uint64) ([]*Transaction, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	txs, ok := p.txs[height]
+	delete(p.txs, height)
+	return txs, ok
+}
+
+//has 是否已预取
+func (p *txPrefetch) has(height uint64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.txs[height]
+	return ok
+}
+
+func (p *txPrefetch) put(txMap map[uint64][]*Transaction) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for height, txs := range txMap {
+		p.txs[height] = txs
+	}
+}
+
+//reset 清空预取数据，区块分叉后预取的交易单不再可靠
+func (p *txPrefetch) reset() {
+	p.mu.Lock()
+	p.txs = make(map[uint64][]*Transaction)
+	p.mu.Unlock()
+}
+
+//PrefetchTransactions 一次批量请求预取[from, to]高度的钱包交易单，
+//只能预取钱包已同步的高度，未同步的高度交易单还不完整
+func (wm *WalletManager) PrefetchTransactions(ctx context.Context, from, to uint64) error {
+
+	if from > to || wm.txPrefetch.has(from) {
+		return nil
+	}
+
+	heights := make([]uint64, 0, to-from+1)
+	for height := from; height <= to; height++ {
+		heights = append(heights, height)
+	}
+
+	txMap, err := wm.walletClient.GetTransactionsByHeights(ctx, heights)
+	if err != nil {
+		return err
+	}
+	wm.txPrefetch.put(txMap)
+	return nil
+}
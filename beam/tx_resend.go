@@ -0,0 +1,205 @@
+package beam
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/blocktree/openwallet/timer"
+)
+
+const (
+	//重发交易单的状态
+	ResendStatePending = "pending" //等待重发
+	ResendStateSent    = "sent"    //重发成功
+	ResendStateFailed  = "failed"  //超过最大次数或遇到不可重试的错误，不再重发
+)
+
+//ResendTx 发送时遇到网络等临时错误的交易单，使用相同的交易ID重发，钱包不会重复发送
+type ResendTx struct {
+	TxID          string `storm:"id"`
+	Sid           string `storm:"index"` //业务订单号
+	From          string
+	To            string
+	Send          uint64
+	Fee           uint64
+	Comment       string
+	Override      bool   //跳过提现地址白名单
+	State         string `storm:"index"`
+	Attempts      int
+	Reason        string //最近一次失败的原因
+	NextRetryTime int64
+	CreateTime    int64
+	UpdateTime    int64
+}
+
+//isResendableError 发送遇到的临时错误，包括钱包API熔断
+func isResendableError(err error) bool {
+	err = sendErrorCause(err)
+	return isRetryableError(err) || err == ErrCircuitOpen
+}
+
+func (wm *WalletManager) openResendDB() (*storm.DB, error) {
+	return storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+}
+
+//saveResendTx 保存重发交易单
+func (wm *WalletManager) saveResendTx(tx *ResendTx) error {
+	db, err := wm.openResendDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx.UpdateTime = time.Now().Unix()
+	return db.Save(tx)
+}
+
+//scheduleResend 记录发送失败的交易单，按退避时间重发
+func (wm *WalletManager) scheduleResend(from, to string, withdrawal *WithdrawalAmount, comment, txID, sid string, override bool, sendErr error) error {
+	now := time.Now()
+	tx := &ResendTx{
+		TxID:          txID,
+		Sid:           sid,
+		From:          from,
+		To:            to,
+		Send:          withdrawal.Send,
+		Fee:           withdrawal.Fee,
+		Comment:       comment,
+		Override:      override,
+		State:         ResendStatePending,
+		Attempts:      1,
+		Reason:        sendErr.Error(),
+		NextRetryTime: now.Add(unscanRetryDelay(1, wm.Config.resendbackoff, wm.Config.resendmaxbackoff)).Unix(),
+		CreateTime:    now.Unix(),
+	}
+	return wm.saveResendTx(tx)
+}
+
+//GetResendTx 查询重发交易单
+func (wm *WalletManager) GetResendTx(txID string) (*ResendTx, error) {
+	db, err := wm.openResendDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var tx ResendTx
+	err = db.One("TxID", txID, &tx)
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+//ListResendTxs 按创建时间列出重发交易单，state为空时列出全部
+func (wm *WalletManager) ListResendTxs(state string) ([]*ResendTx, error) {
+	db, err := wm.openResendDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*ResendTx
+	if len(state) == 0 {
+		err = db.All(&list)
+	} else {
+		err = db.Find("State", state, &list)
+	}
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreateTime < list[j].CreateTime
+	})
+	return list, nil
+}
+
+//ResendFailedTxs 重发到期的交易单，钱包已有该交易ID时记为成功，
+//失败次数达到resendmaxattempts或遇到不可重试的错误时进入failed状态
+func (wm *WalletManager) ResendFailedTxs() error {
+
+	list, err := wm.ListResendTxs(ResendStatePending)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, tx := range list {
+		if tx.NextRetryTime > now.Unix() {
+			continue
+		}
+
+		//上次发送可能已被钱包接收
+		if sent, err := wm.walletClient.GetTransaction(context.Background(), tx.TxID); err == nil && sent.TxID == tx.TxID {
+			tx.State = ResendStateSent
+			wm.Log.Infof("resend tx: %s has been accepted by the wallet", tx.TxID)
+			wm.saveResendTx(tx)
+			continue
+		}
+
+		_, err = wm.sendWithdrawal(tx.From, tx.To, &WithdrawalAmount{Send: tx.Send, Fee: tx.Fee}, tx.Comment, tx.TxID, tx.Sid, tx.Override)
+		tx.Attempts++
+		if err == nil {
+			tx.State = ResendStateSent
+			wm.Log.Infof("resend tx: %s successfully after %d attempts", tx.TxID, tx.Attempts)
+			wm.saveResendTx(tx)
+			continue
+		}
+
+		tx.Reason = err.Error()
+		if !isResendableError(err) || tx.Attempts >= wm.Config.resendmaxattempts {
+			tx.State = ResendStateFailed
+			wm.Log.Errorf("resend tx: %s failed %d times, give up, unexpected error: %v", tx.TxID, tx.Attempts, err)
+		} else {
+			tx.NextRetryTime = now.Add(unscanRetryDelay(tx.Attempts, wm.Config.resendbackoff, wm.Config.resendmaxbackoff)).Unix()
+			wm.Log.Warningf("resend tx: %s failed %d times, unexpected error: %v", tx.TxID, tx.Attempts, err)
+		}
+		wm.saveResendTx(tx)
+	}
+
+	return nil
+}
+
+//sendWithdrawalOrResend 发送提现交易，开启重发时遇到临时错误记录交易单等待重发，
+//返回的错误说明交易单会重发，调用方使用相同的业务订单号重试不会重复发送
+func (wm *WalletManager) sendWithdrawalOrResend(from, to string, withdrawal *WithdrawalAmount, comment, txID, sid string, override bool) (string, error) {
+
+	txid, err := wm.sendWithdrawal(from, to, withdrawal, comment, txID, sid, override)
+	if err == nil {
+		return txid, nil
+	}
+
+	if !wm.IsFeatureEnabled(FeatureTxResend) || len(txID) == 0 || !isResendableError(err) {
+		return "", err
+	}
+
+	if saveErr := wm.scheduleResend(from, to, withdrawal, comment, txID, sid, override, err); saveErr != nil {
+		wm.Log.Errorf("schedule resend of tx: %s failed, unexpected error: %v", txID, saveErr)
+		return "", err
+	}
+
+	wm.Log.Warningf("send tx: %s failed, scheduled to resend, unexpected error: %v", txID, err)
+	return "", openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "send tx: %s failed, scheduled to resend: %v", txID, err)
+}
+
+//StartTxResender 启动交易单重发任务
+func (wm *WalletManager) StartTxResender() {
+
+	if !wm.IsFeatureEnabled(FeatureTxResend) || wm.txResender != nil {
+		return
+	}
+
+	wm.Log.Infof("The timer for tx resender start now. Execute by every %v seconds.", wm.Config.resendperiod.Seconds())
+
+	wm.txResender = timer.NewTask(wm.Config.resendperiod, func() {
+		if err := wm.ResendFailedTxs(); err != nil {
+			wm.Log.Errorf("resend failed txs unexpected error: %v", err)
+		}
+	})
+	wm.txResender.Start()
+}
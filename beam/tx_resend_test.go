@@ -0,0 +1,92 @@
+package beam
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWalletManager_ResendFailedTxs(t *testing.T) {
+
+	var (
+		ids     int
+		failing = true
+		sends   = make(map[string]int)
+		sent    = make(map[string]bool)
+	)
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list":        rpcResult(`[{"address":"from","own":true,"comment":"self"}]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false}`),
+		"wallet_status":    rpcResult(`{"available":100000000}`),
+		"generate_tx_id": func(map[string]interface{}) (string, error) {
+			ids++
+			return fmt.Sprintf(`"tx%d"`, ids), nil
+		},
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			txID := params["txId"].(string)
+			sends[txID]++
+			if failing {
+				return "", rpcTestStatus(http.StatusServiceUnavailable)
+			}
+			sent[txID] = true
+			return fmt.Sprintf(`{"txId":"%s"}`, txID), nil
+		},
+		"tx_status": func(params map[string]interface{}) (string, error) {
+			txID := params["txId"].(string)
+			if !sent[txID] {
+				return "", &rpcTestError{Code: -32003, Message: "tx not found"}
+			}
+			return fmt.Sprintf(`{"txId":"%s","status":1}`, txID), nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.Config.features[FeatureTxResend] = true
+	wm.Config.resendbackoff = 0
+	wm.Config.resendmaxattempts = 3
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	decoder := NewTransactionDecoder(wm)
+
+	submit := func() {
+		_, err := decoder.SubmitRawTransaction(nil, &openwallet.RawTransaction{To: map[string]string{"to": "0.000001"}})
+		if err == nil {
+			t.Errorf("SubmitRawTransaction should fail")
+		}
+	}
+
+	//没有业务订单号时也预先生成交易ID，失败后等待重发
+	submit()
+	tx, err := wm.GetResendTx("tx1")
+	if err != nil || tx.State != ResendStatePending || tx.Attempts != 1 || tx.To != "to" || tx.Send != 100 {
+		t.Errorf("GetResendTx = %+v, %v", tx, err)
+		return
+	}
+
+	wm.ResendFailedTxs()
+	failing = false
+	wm.ResendFailedTxs()
+	tx, _ = wm.GetResendTx("tx1")
+	if tx.State != ResendStateSent || tx.Attempts != 3 || sends["tx1"] != 3 {
+		t.Errorf("resent tx = %+v, sends = %d", tx, sends["tx1"])
+	}
+
+	//重发次数用完后进入failed状态
+	failing = true
+	submit()
+	wm.ResendFailedTxs()
+	wm.ResendFailedTxs()
+	wm.ResendFailedTxs()
+	tx, _ = wm.GetResendTx("tx2")
+	if tx.State != ResendStateFailed || tx.Attempts != 3 || len(tx.Reason) == 0 || sends["tx2"] != 3 {
+		t.Errorf("failed tx = %+v, sends = %d", tx, sends["tx2"])
+	}
+
+	if list, _ := wm.ListResendTxs(ResendStatePending); len(list) != 0 {
+		t.Errorf("pending resend txs = %d, want 0", len(list))
+	}
+}
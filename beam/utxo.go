@@ -0,0 +1,261 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/blocktree/openwallet/common"
+)
+
+//UTXO状态
+const (
+	UtxoStatusUnavailable = 0 //不可用，所在交易未完成
+	UtxoStatusAvailable   = 1 //可用
+	UtxoStatusMaturing    = 2 //未成熟
+	UtxoStatusOutgoing    = 3 //发送中
+	UtxoStatusIncoming    = 4 //接收中
+	UtxoStatusSpent       = 6 //已花费
+)
+
+const (
+	//隐私池中的UTXO类型
+	UtxoTypeShielded = "shld"
+)
+
+const (
+	//一次拆分最多生成的UTXO数量，一次合并最多使用的UTXO数量
+	MaxSplitCoins = 100
+
+	//合并UTXO交易的备注
+	consolidateComment = "consolidate"
+)
+
+//UtxoFilter get_utxo的过滤条件，零值表示不过滤
+type UtxoFilter struct {
+	Status      []int64 //UTXO状态，任一匹配
+	AssetID     *int64  //资产ID，nil表示不过滤，0为BEAM
+	MaxMaturity uint64  //成熟高度不超过该高度，0表示不过滤
+}
+
+//SpendableFilter 在指定高度可花费的UTXO
+func SpendableFilter(height uint64) UtxoFilter {
+	return UtxoFilter{
+		Status:      []int64{UtxoStatusAvailable},
+		MaxMaturity: height,
+	}
+}
+
+//params get_utxo的请求参数，资产在钱包API端过滤
+func (f UtxoFilter) params(skip, count uint64) map[string]interface{} {
+	request := map[string]interface{}{
+		"skip":  skip,
+		"count": count,
+	}
+	if f.AssetID != nil {
+		request["assets"] = true
+		request["filter"] = map[string]interface{}{
+			"asset_id": *f.AssetID,
+		}
+	}
+	return request
+}
+
+//match 是否符合过滤条件，钱包API不支持的条件在本地过滤
+func (f UtxoFilter) match(utxo *Utxo) bool {
+	if f.AssetID != nil && utxo.AssetID != *f.AssetID {
+		return false
+	}
+	if f.MaxMaturity > 0 && utxo.Maturity > f.MaxMaturity {
+		return false
+	}
+	if len(f.Status) == 0 {
+		return true
+	}
+	for _, status := range f.Status {
+		if utxo.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+//ListUTXO 分页获取钱包符合过滤条件的UTXO
+func (c *WalletClient) ListUTXO(ctx context.Context, filter UtxoFilter) ([]*Utxo, error) {
+
+	utxos := make([]*Utxo, 0)
+	for skip := uint64(0); ; skip += c.txPageSize {
+		r, err := c.callContext(ctx, "get_utxo", filter.params(skip, c.txPageSize))
+		if err != nil {
+			return nil, err
+		}
+
+		page := r.Array()
+		for _, obj := range page {
+			utxo := NewUtxo(&obj)
+			if filter.match(utxo) {
+				utxos = append(utxos, utxo)
+			}
+		}
+		if uint64(len(page)) < c.txPageSize {
+			break
+		}
+	}
+
+	return utxos, nil
+}
+
+//ListUTXO 获取钱包符合过滤条件的UTXO
+func (wm *WalletManager) ListUTXO(filter UtxoFilter) ([]*Utxo, error) {
+	return wm.walletClient.ListUTXO(context.Background(), filter)
+}
+
+//SplitCoins 把钱包的UTXO拆分为coins指定面额的多个UTXO，fee为0时使用钱包的最低手续费
+func (c *WalletClient) SplitCoins(ctx context.Context, coins []uint64, fee uint64) (string, error) {
+
+	request := map[string]interface{}{
+		"coins": coins,
+	}
+	if fee > 0 {
+		request["fee"] = fee
+	}
+
+	r, err := c.callContext(ctx, "tx_split", request)
+	if err != nil {
+		return "", err
+	}
+	return r.Get("txId").String(), nil
+}
+
+//ConsolidateCoins 使用coinIDs指定的UTXO发送value到钱包自己的地址to，合并为一个UTXO
+func (c *WalletClient) ConsolidateCoins(ctx context.Context, to string, coinIDs []string, value, fee uint64) (string, error) {
+
+	request := map[string]interface{}{
+		"value":   value,
+		"fee":     fee,
+		"address": to,
+		"coins":   coinIDs,
+		"comment": consolidateComment,
+	}
+
+	r, err := c.callContext(ctx, "tx_send", request)
+	if err != nil {
+		return "", err
+	}
+	return r.Get("txId").String(), nil
+}
+
+//SplitAmounts count个面额为denomination的拆分金额
+func SplitAmounts(denomination uint64, count int) []uint64 {
+	coins := make([]uint64, 0, count)
+	for i := 0; i < count; i++ {
+		coins = append(coins, denomination)
+	}
+	return coins
+}
+
+//fixFee 配置的固定手续费，最小单位
+func (wm *WalletManager) fixFee() uint64 {
+	return common.StringNumToBigIntWithExp(wm.Config.fixfees, wm.Decimal()).Uint64()
+}
+
+//SplitUTXO 预先把大额UTXO拆分为coins指定面额的UTXO，多笔提现可以并行使用，fee为0时使用配置的固定手续费
+func (wm *WalletManager) SplitUTXO(coins []uint64, fee uint64) (string, error) {
+
+	if len(coins) == 0 || len(coins) > MaxSplitCoins {
+		return "", fmt.Errorf("split coins count should be between 1 and %d", MaxSplitCoins)
+	}
+
+	if fee == 0 {
+		fee = wm.fixFee()
+	}
+
+	total := fee
+	for _, coin := range coins {
+		if coin == 0 {
+			return "", fmt.Errorf("split coin amount can not be zero")
+		}
+		total += coin
+	}
+
+	status, err := wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return "", err
+	}
+	if status.Available < total {
+		return "", fmt.Errorf("wallet available balance: %d is not enough to split: %d", status.Available, total)
+	}
+
+	txID, err := wm.walletClient.SplitCoins(context.Background(), coins, fee)
+	if err != nil {
+		return "", err
+	}
+
+	wm.Log.Infof("Split UTXO into %d coins, total: %d, fee: %d, tx: %s", len(coins), total-fee, fee, txID)
+	return txID, nil
+}
+
+//ConsolidateUTXO 把金额小于maxAmount的可用UTXO合并到钱包自己的地址，从小到大最多合并maxCoins个，
+//maxAmount为0表示不限金额，maxCoins为0表示MaxSplitCoins，fee为0时使用配置的固定手续费
+func (wm *WalletManager) ConsolidateUTXO(maxAmount uint64, maxCoins int, fee uint64) (string, error) {
+
+	if maxCoins <= 0 || maxCoins > MaxSplitCoins {
+		maxCoins = MaxSplitCoins
+	}
+
+	if fee == 0 {
+		fee = wm.fixFee()
+	}
+
+	status, err := wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	assetID := int64(0)
+	filter := SpendableFilter(status.CurrentHeight)
+	filter.AssetID = &assetID
+	utxos, err := wm.walletClient.ListUTXO(context.Background(), filter)
+	if err != nil {
+		return "", err
+	}
+
+	sort.Slice(utxos, func(i, j int) bool {
+		return utxos[i].Amount < utxos[j].Amount
+	})
+
+	var (
+		coinIDs = make([]string, 0, maxCoins)
+		total   uint64
+	)
+	for _, utxo := range utxos {
+		if len(coinIDs) == maxCoins || (maxAmount > 0 && utxo.Amount >= maxAmount) {
+			break
+		}
+		coinIDs = append(coinIDs, utxo.ID)
+		total += utxo.Amount
+	}
+
+	if len(coinIDs) < 2 {
+		return "", fmt.Errorf("no enough coins to consolidate")
+	}
+	if total <= fee {
+		return "", fmt.Errorf("consolidate amount: %d is not enough to pay fee: %d", total, fee)
+	}
+
+	addresses, err := wm.walletClient.GetAddressList(context.Background())
+	if err != nil {
+		return "", err
+	}
+	if len(addresses) == 0 {
+		return "", fmt.Errorf("wallet has no address to receive consolidated coins")
+	}
+
+	txID, err := wm.walletClient.ConsolidateCoins(context.Background(), addresses[0], coinIDs, total-fee, fee)
+	if err != nil {
+		return "", err
+	}
+
+	wm.Log.Infof("Consolidate %d coins, total: %d, fee: %d, tx: %s", len(coinIDs), total, fee, txID)
+	return txID, nil
+}
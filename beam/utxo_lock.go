@@ -0,0 +1,239 @@
+package beam
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+//UtxoLock 提现交易占用的UTXO，交易完成、取消或失败后释放
+type UtxoLock struct {
+	UtxoID   string `storm:"id"`
+	TxID     string `storm:"index"` //为空表示交易还未发出
+	Sid      string //业务订单号
+	LockTime int64
+}
+
+//utxoLockTable 记录在本地数据库的UTXO锁，并发提现和重启后都不会重复选择已占用的UTXO
+type utxoLockTable struct {
+	wm *WalletManager
+	mu sync.Mutex
+}
+
+func newUtxoLockTable(wm *WalletManager) *utxoLockTable {
+	return &utxoLockTable{wm: wm}
+}
+
+func (t *utxoLockTable) open() (*storm.DB, error) {
+	return storm.Open(filepath.Join(t.wm.Config.dbPath, t.wm.Config.BlockchainFile))
+}
+
+//all 所有UTXO锁
+func (t *utxoLockTable) all() ([]*UtxoLock, error) {
+	db, err := t.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var locks []*UtxoLock
+	err = db.All(&locks)
+	if err != nil {
+		return nil, err
+	}
+	return locks, nil
+}
+
+//selectAndLock 从未锁定的UTXO中选择并锁定，选择和锁定一起完成，避免并发提现选中相同的UTXO
+func (t *utxoLockTable) selectAndLock(strategy string, utxos []*Utxo, target uint64, sid string) ([]*Utxo, error) {
+	return t.lockWith(utxos, sid, func(available []*Utxo) ([]*Utxo, error) {
+		return SelectCoins(strategy, available, target)
+	})
+}
+
+//lockAvailable 锁定全部未锁定的UTXO
+func (t *utxoLockTable) lockAvailable(utxos []*Utxo, sid string) ([]*Utxo, error) {
+	return t.lockWith(utxos, sid, func(available []*Utxo) ([]*Utxo, error) {
+		if len(available) == 0 {
+			return nil, ErrInsufficientCoins
+		}
+		return available, nil
+	})
+}
+
+//lockWith 由pick从未锁定的UTXO中选择并锁定
+func (t *utxoLockTable) lockWith(utxos []*Utxo, sid string, pick func(available []*Utxo) ([]*Utxo, error)) ([]*Utxo, error) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	db, err := t.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var locks []*UtxoLock
+	err = db.All(&locks)
+	if err != nil {
+		return nil, err
+	}
+	locked := make(map[string]bool, len(locks))
+	for _, lock := range locks {
+		locked[lock.UtxoID] = true
+	}
+
+	available := make([]*Utxo, 0, len(utxos))
+	for _, utxo := range utxos {
+		if !locked[utxo.ID] {
+			available = append(available, utxo)
+		}
+	}
+
+	selected, err := pick(available)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, utxo := range selected {
+		err = tx.Save(&UtxoLock{UtxoID: utxo.ID, Sid: sid, LockTime: now})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return selected, nil
+}
+
+//bind 交易发出后记录占用UTXO的交易ID
+func (t *utxoLockTable) bind(coinIDs []string, txID string) error {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	db, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, id := range coinIDs {
+		err = db.UpdateField(&UtxoLock{UtxoID: id}, "TxID", txID)
+		if err != nil && err != storm.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+//unlock 释放UTXO锁
+func (t *utxoLockTable) unlock(coinIDs []string) error {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	db, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, id := range coinIDs {
+		err = db.DeleteStruct(&UtxoLock{UtxoID: id})
+		if err != nil && err != storm.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+//unlockTx 释放交易占用的UTXO锁
+func (t *utxoLockTable) unlockTx(txID string) error {
+
+	locks, err := t.all()
+	if err != nil {
+		return err
+	}
+
+	coinIDs := make([]string, 0)
+	for _, lock := range locks {
+		if lock.TxID == txID {
+			coinIDs = append(coinIDs, lock.UtxoID)
+		}
+	}
+	if len(coinIDs) == 0 {
+		return nil
+	}
+	return t.unlock(coinIDs)
+}
+
+//GetUtxoLocks 提现交易占用的UTXO
+func (wm *WalletManager) GetUtxoLocks() ([]*UtxoLock, error) {
+	return wm.utxoLocks.all()
+}
+
+//ReleaseUtxoLocks 释放已完成、取消或失败的交易占用的UTXO，
+//交易没有发出的锁超过utxolocktimeout后释放
+func (wm *WalletManager) ReleaseUtxoLocks() error {
+
+	locks, err := wm.utxoLocks.all()
+	if err != nil {
+		return err
+	}
+
+	var (
+		expired  = make([]string, 0)
+		txLocks  = make(map[string][]string)
+		deadline = time.Now().Add(-wm.Config.utxolocktimeout).Unix()
+	)
+	for _, lock := range locks {
+		if len(lock.TxID) == 0 {
+			if lock.LockTime < deadline {
+				expired = append(expired, lock.UtxoID)
+			}
+			continue
+		}
+		txLocks[lock.TxID] = append(txLocks[lock.TxID], lock.UtxoID)
+	}
+
+	if len(expired) > 0 {
+		wm.Log.Warningf("release %d utxo locks without transaction", len(expired))
+		err = wm.utxoLocks.unlock(expired)
+		if err != nil {
+			return err
+		}
+	}
+
+	for txID, coinIDs := range txLocks {
+		tx, err := wm.walletClient.GetTransaction(context.Background(), txID)
+		if err != nil {
+			wm.Log.Std.Warn("get tx: %s of utxo locks failed, unexpected error: %v", txID, err)
+			continue
+		}
+
+		switch tx.Status {
+		case TxStatusCompleted, TxStatusCanceled, TxStatusFailed:
+			err = wm.utxoLocks.unlock(coinIDs)
+			if err != nil {
+				return err
+			}
+			wm.Log.Std.Info("tx: %s is %s, release %d utxo locks", txID, tx.StatusString, len(coinIDs))
+		}
+	}
+
+	return nil
+}
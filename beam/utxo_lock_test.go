@@ -0,0 +1,95 @@
+package beam
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUtxoLockTable(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	utxos := testCoins(50, 30)
+
+	first, err := wm.utxoLocks.selectAndLock(CoinSelectLargestFirst, utxos, 40, "order-1")
+	if err != nil || coinIDs(first) != "a" {
+		t.Errorf("first selection = %s, %v, want a", coinIDs(first), err)
+		return
+	}
+
+	//已锁定的UTXO不会再被选中，重新创建的锁表从数据库读取
+	wm.utxoLocks = newUtxoLockTable(wm)
+	second, err := wm.utxoLocks.selectAndLock(CoinSelectLargestFirst, utxos, 20, "order-2")
+	if err != nil || coinIDs(second) != "b" {
+		t.Errorf("second selection = %s, %v, want b", coinIDs(second), err)
+		return
+	}
+	if _, err = wm.utxoLocks.selectAndLock(CoinSelectLargestFirst, utxos, 10, "order-3"); err != ErrInsufficientCoins {
+		t.Errorf("third selection should fail, got %v", err)
+	}
+
+	if err = wm.utxoLocks.bind([]string{"a"}, "tx1"); err != nil {
+		t.Errorf("bind unexpected error: %v", err)
+	}
+	if err = wm.utxoLocks.unlockTx("tx1"); err != nil {
+		t.Errorf("unlockTx unexpected error: %v", err)
+	}
+	locks, _ := wm.GetUtxoLocks()
+	if len(locks) != 1 || locks[0].UtxoID != "b" || locks[0].Sid != "order-2" {
+		t.Errorf("locks after unlockTx = %+v", locks)
+	}
+}
+
+func TestWalletManager_ReleaseUtxoLocks(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		var body struct {
+			Params struct {
+				TxID string `json:"txId"`
+			} `json:"params"`
+		}
+		json.Unmarshal(data, &body)
+		status := TxStatusInProgress
+		if body.Params.TxID == "done" {
+			status = TxStatusCompleted
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"result":  map[string]interface{}{"txId": body.Params.TxID, "status": status},
+		})
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if _, err := wm.utxoLocks.selectAndLock(CoinSelectConsolidate, testCoins(1, 2, 3), 6, ""); err != nil {
+		t.Errorf("selectAndLock unexpected error: %v", err)
+		return
+	}
+	wm.utxoLocks.bind([]string{"a"}, "done")
+	wm.utxoLocks.bind([]string{"b"}, "sending")
+
+	//未发出的锁还没有超时
+	if err := wm.ReleaseUtxoLocks(); err != nil {
+		t.Errorf("ReleaseUtxoLocks unexpected error: %v", err)
+	}
+	locks, _ := wm.GetUtxoLocks()
+	if len(locks) != 2 {
+		t.Errorf("locks = %d, want 2", len(locks))
+	}
+
+	wm.Config.utxolocktimeout = -time.Minute
+	wm.ReleaseUtxoLocks()
+	locks, _ = wm.GetUtxoLocks()
+	if len(locks) != 1 || locks[0].TxID != "sending" {
+		t.Errorf("locks = %+v, want only sending", locks)
+	}
+}
@@ -0,0 +1,131 @@
+package beam
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUtxoFilter_Match(t *testing.T) {
+
+	assetID := int64(1)
+	utxo := &Utxo{AssetID: 0, Maturity: 100, Status: UtxoStatusAvailable}
+	tests := []struct {
+		filter UtxoFilter
+		want   bool
+	}{
+		{UtxoFilter{}, true},
+		{SpendableFilter(100), true},
+		{SpendableFilter(99), false},
+		{UtxoFilter{Status: []int64{UtxoStatusMaturing, UtxoStatusAvailable}}, true},
+		{UtxoFilter{Status: []int64{UtxoStatusSpent}}, false},
+		{UtxoFilter{AssetID: &assetID}, false},
+	}
+	for i, tt := range tests {
+		if got := tt.filter.match(utxo); got != tt.want {
+			t.Errorf("case %d match = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestWalletClient_ListUTXO(t *testing.T) {
+
+	var params []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		var body struct {
+			Params map[string]interface{} `json:"params"`
+		}
+		json.Unmarshal(data, &body)
+		params = append(params, body.Params)
+		if body.Params["skip"].(float64) > 0 {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":[
+				{"id":"c","asset_id":0,"amount":3,"type":"norm","maturity":30,"status":6}
+			]}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":[
+			{"id":"a","asset_id":0,"amount":1,"type":"mine","maturity":10,"status":1},
+			{"id":"b","asset_id":0,"amount":2,"type":"mine","maturity":20,"status":2}
+		]}`))
+	}))
+	defer server.Close()
+
+	c := NewWalletClient(server.URL, server.URL, false)
+	c.SetTxPageSize(2)
+
+	utxos, err := c.ListUTXO(context.Background(), UtxoFilter{})
+	if err != nil || len(utxos) != 3 || len(params) != 2 {
+		t.Fatalf("ListUTXO = %d, requests = %d, err = %v", len(utxos), len(params), err)
+	}
+	if utxos[0].ID != "a" || utxos[0].Type != UtxoTypeMine || utxos[2].Status != UtxoStatusSpent {
+		t.Errorf("ListUTXO = %+v", utxos)
+	}
+
+	params = nil
+	assetID := int64(0)
+	utxos, err = c.ListUTXO(context.Background(), UtxoFilter{AssetID: &assetID, MaxMaturity: 20, Status: []int64{UtxoStatusMaturing}})
+	if err != nil || len(utxos) != 1 || utxos[0].ID != "b" {
+		t.Errorf("ListUTXO with filter = %v, err = %v", utxos, err)
+	}
+	if filter, ok := params[0]["filter"].(map[string]interface{}); !ok || filter["asset_id"] != float64(0) {
+		t.Errorf("ListUTXO params = %v", params[0])
+	}
+}
+
+func TestWalletManager_SplitAndConsolidateUTXO(t *testing.T) {
+
+	sent := make(map[string]map[string]interface{})
+	send := func(method string) rpcTestHandler {
+		return func(params map[string]interface{}) (string, error) {
+			sent[method] = params
+			return `{"txId":"tx1"}`, nil
+		}
+	}
+	server := newRPCTestServer(rpcTestMethods{
+		"wallet_status": rpcResult(`{"current_height":100,"available":1000}`),
+		"get_utxo": rpcResult(`[
+			{"id":"big","asset_id":0,"amount":800,"maturity":10,"status":1},
+			{"id":"b","asset_id":0,"amount":20,"maturity":10,"status":1},
+			{"id":"a","asset_id":0,"amount":10,"maturity":10,"status":1},
+			{"id":"young","asset_id":0,"amount":5,"maturity":200,"status":1}
+		]`),
+		"addr_list": rpcResult(`[{"address":"self-addr","own":true,"expired":false,"comment":"self"}]`),
+		"tx_split":  send("tx_split"),
+		"tx_send":   send("tx_send"),
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if _, err := wm.SplitUTXO(SplitAmounts(100, 10), 1); err == nil {
+		t.Errorf("SplitUTXO should fail when balance is not enough")
+	}
+
+	txID, err := wm.SplitUTXO(SplitAmounts(100, 5), 1)
+	if err != nil || txID != "tx1" {
+		t.Errorf("SplitUTXO = %s, err = %v", txID, err)
+	}
+	if coins, ok := sent["tx_split"]["coins"].([]interface{}); !ok || len(coins) != 5 || sent["tx_split"]["fee"] != float64(1) {
+		t.Errorf("tx_split params = %v", sent["tx_split"])
+	}
+
+	//只合并已成熟且小于100的UTXO
+	txID, err = wm.ConsolidateUTXO(100, 0, 1)
+	if err != nil || txID != "tx1" {
+		t.Errorf("ConsolidateUTXO = %s, err = %v", txID, err)
+	}
+	params := sent["tx_send"]
+	coins, _ := params["coins"].([]interface{})
+	if len(coins) != 2 || coins[0] != "a" || coins[1] != "b" || params["value"] != float64(29) || params["address"] != "self-addr" {
+		t.Errorf("tx_send params = %v", params)
+	}
+
+	if _, err = wm.ConsolidateUTXO(15, 0, 1); err == nil {
+		t.Errorf("ConsolidateUTXO should fail with only one coin")
+	}
+}
@@ -0,0 +1,77 @@
+package beam
+
+import (
+	"context"
+)
+
+//WalletAPI 钱包节点操作，WalletManager和区块扫描器只依赖该接口，
+//可替换为其他传输方式的实现或测试用的模拟实现
+type WalletAPI interface {
+
+	//地址
+	CreateAddress(ctx context.Context, expiration, comment string) (string, error)
+	CreateAddressWithType(ctx context.Context, addressType, expiration, comment string) (string, error)
+	CreateOfflineAddress(ctx context.Context, payments int, expiration, comment string) (string, error)
+	CreateBatchAddress(ctx context.Context, count, workerSize uint64) ([]string, error)
+	GetAddressList(ctx context.Context) ([]string, error)
+	ListAddresses(ctx context.Context, own bool) ([]*WalletAddress, error)
+	ValidateAddress(ctx context.Context, address string) (bool, error)
+	GetAddressValidation(ctx context.Context, address string) (*AddressValidation, error)
+	EditAddress(ctx context.Context, address, comment, expiration string) error
+	ExportPaymentProof(ctx context.Context, txid string) (string, error)
+	VerifyPaymentProof(ctx context.Context, proof string) (*PaymentProof, error)
+
+	//钱包
+	GetWalletStatus(ctx context.Context) (*WalletStatus, error)
+	GetUtxoList(ctx context.Context) ([]*Utxo, error)
+	ListUTXO(ctx context.Context, filter UtxoFilter) ([]*Utxo, error)
+
+	//交易
+	GetTransaction(ctx context.Context, txid string) (*Transaction, error)
+	ListTransactions(ctx context.Context, filter TxListFilter, skip, count uint64) ([]*Transaction, error)
+	IterateTransactions(filter TxListFilter, pageSize uint64) *TxListIterator
+	GetTransactionsByHeight(ctx context.Context, height uint64) ([]*Transaction, error)
+	GetTransactionsByHeightPage(ctx context.Context, height, skip, count uint64) ([]*Transaction, error)
+	GetTransactionsByHeights(ctx context.Context, heights []uint64) (map[uint64][]*Transaction, error)
+	GetTransactionsByStatus(ctx context.Context, status int) ([]*Transaction, error)
+	GenerateTxID(ctx context.Context) (string, error)
+	SendTransaction(ctx context.Context, from, to string, value, fee uint64, comment, txID string) (string, error)
+	SendTransactionWithCoins(ctx context.Context, from, to string, value, fee uint64, comment, txID string, coinIDs []string) (string, error)
+	SendAssetTransaction(ctx context.Context, from, to string, assetID int64, value, fee uint64, comment, txID string) (string, error)
+	CancelTx(ctx context.Context, txid string) (bool, error)
+	DeleteTx(ctx context.Context, txid string) (bool, error)
+	CalcChange(ctx context.Context, amount, fee uint64) (*ChangeResult, error)
+	SplitCoins(ctx context.Context, coins []uint64, fee uint64) (string, error)
+	ConsolidateCoins(ctx context.Context, to string, coinIDs []string, value, fee uint64) (string, error)
+
+	//资产
+	GetAssetInfo(ctx context.Context, assetID int64) (*AssetInfo, error)
+
+	//合约
+	InvokeContract(ctx context.Context, contract []byte, contractFile, args string, createTx bool) (*ContractInvokeResult, error)
+	ProcessInvokeData(ctx context.Context, data []byte) (string, error)
+
+	//区块
+	GetBlockchainInfo(ctx context.Context) (*BlockchainInfo, error)
+	GetBlockByHeight(ctx context.Context, height uint64) (*Block, error)
+	GetBlockByHash(ctx context.Context, hash string) (*Block, error)
+	GetBlockByKernel(ctx context.Context, kernel string) (*Block, error)
+}
+
+var _ WalletAPI = (*WalletClient)(nil)
+
+//SetWalletAPI 替换钱包节点操作的实现
+func (wm *WalletManager) SetWalletAPI(api WalletAPI) {
+	wm.walletClient = api
+}
+
+//WalletAPI 钱包节点操作的实现
+func (wm *WalletManager) WalletAPI() WalletAPI {
+	return wm.walletClient
+}
+
+//httpWalletClient 本地封装的钱包API客户端（http或websocket传输），节点切换、熔断和版本探测只有该实现支持
+func (wm *WalletManager) httpWalletClient() (*WalletClient, bool) {
+	c, ok := wm.walletClient.(*WalletClient)
+	return c, ok
+}
@@ -0,0 +1,75 @@
+package beam
+
+import (
+	"context"
+	"testing"
+)
+
+//fakeWalletAPI 测试用的钱包API，只实现用到的方法
+type fakeWalletAPI struct {
+	WalletAPI
+	txs []*Transaction
+}
+
+func (f *fakeWalletAPI) GetTransactionsByHeight(ctx context.Context, height uint64) ([]*Transaction, error) {
+	trxs := make([]*Transaction, 0)
+	for _, tx := range f.txs {
+		if tx.BlockHeight == height {
+			trxs = append(trxs, tx)
+		}
+	}
+	return trxs, nil
+}
+
+func (f *fakeWalletAPI) ListTransactions(ctx context.Context, filter TxListFilter, skip, count uint64) ([]*Transaction, error) {
+	if skip >= uint64(len(f.txs)) {
+		return []*Transaction{}, nil
+	}
+	end := skip + count
+	if end > uint64(len(f.txs)) {
+		end = uint64(len(f.txs))
+	}
+	return f.txs[skip:end], nil
+}
+
+func TestWalletManager_SetWalletAPI(t *testing.T) {
+
+	api := &fakeWalletAPI{txs: []*Transaction{
+		{TxID: "a", BlockHeight: 10},
+		{TxID: "b", BlockHeight: 11},
+		{TxID: "c", BlockHeight: 10},
+	}}
+
+	wm := NewWalletManager()
+	wm.SetWalletAPI(api)
+
+	if wm.WalletAPI() != api {
+		t.Errorf("WalletAPI() is not the configured implementation")
+	}
+	if wm.GetWalletEndpoints() != nil || wm.circuitOpen() || wm.probeCircuit(context.Background()) != nil {
+		t.Errorf("http only features should be disabled for other implementations")
+	}
+
+	trxs, err := wm.GetTransactionsByHeight(context.Background(), 10)
+	if err != nil {
+		t.Errorf("GetTransactionsByHeight unexpected error: %v", err)
+		return
+	}
+	if len(trxs) != 2 {
+		t.Errorf("GetTransactionsByHeight got %d txs, want 2", len(trxs))
+	}
+
+	it := NewTxListIterator(api, TxListFilter{}, 2)
+	count := 0
+	for !it.Done() {
+		page, err := it.Next(context.Background())
+		if err != nil {
+			t.Errorf("Next unexpected error: %v", err)
+			return
+		}
+		count += len(page)
+	}
+	if count != len(api.txs) {
+		t.Errorf("iterator got %d txs, want %d", count, len(api.txs))
+	}
+}
@@ -0,0 +1,273 @@
+package beam
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	//备份文件头
+	walletBackupMagic = "BEAMBAK1"
+	//备份中的说明文件
+	walletBackupManifestFile = "manifest.json"
+	//密钥派生的盐长度
+	walletBackupSaltSize = 16
+)
+
+//WalletBackupManifest 备份中的说明文件，记录备份的内容
+type WalletBackupManifest struct {
+	Symbol     string   `json:"symbol"`
+	CreateTime int64    `json:"createTime"`
+	Height     uint64   `json:"height"` //备份时钱包的高度，查询失败时为0
+	Files      []string `json:"files"`
+}
+
+//passphraseKey 按口令和盐派生AES-256密钥
+func passphraseKey(password string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(password), salt, 1<<15, 8, 1, 32)
+}
+
+//ExportWalletBackup 把wallet.db和适配器本地数据库打包，用口令加密（scrypt派生密钥，AES-256-GCM）写入dir（为空时使用walletdatabackupdir），返回备份文件路径；
+//beam钱包API不提供助记词和私钥的查询，备份不包含助记词
+func (wm *WalletManager) ExportWalletBackup(dir, password string) (string, error) {
+
+	if len(password) == 0 {
+		return "", fmt.Errorf("wallet backup password is empty")
+	}
+	if len(wm.Config.walletdatafile) == 0 {
+		return "", fmt.Errorf("walletdatafile is not configured")
+	}
+	if len(dir) == 0 {
+		dir = wm.Config.walletdatabackupdir
+	}
+
+	walletData, err := ioutil.ReadFile(wm.Config.walletdatafile)
+	if err != nil {
+		return "", err
+	}
+	adapterData, err := wm.snapshotBlockchainDB()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	manifest := &WalletBackupManifest{
+		Symbol:     wm.Symbol(),
+		CreateTime: now.Unix(),
+		Files:      []string{filepath.Base(wm.Config.walletdatafile), wm.Config.BlockchainFile},
+	}
+	if status, statusErr := wm.walletClient.GetWalletStatus(context.Background()); statusErr == nil {
+		manifest.Height = status.CurrentHeight
+	} else {
+		wm.Log.Warningf("wallet status for backup manifest failed, unexpected error: %v", statusErr)
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	var archive bytes.Buffer
+	gw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gw)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{walletBackupManifestFile, manifestData},
+		{manifest.Files[0], walletData},
+		{manifest.Files[1], adapterData},
+	}
+	for _, f := range files {
+		header := &tar.Header{Name: f.name, Mode: 0600, Size: int64(len(f.data)), ModTime: now}
+		if err = tw.WriteHeader(header); err != nil {
+			return "", err
+		}
+		if _, err = tw.Write(f.data); err != nil {
+			return "", err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return "", err
+	}
+	if err = gw.Close(); err != nil {
+		return "", err
+	}
+
+	sealed, err := sealWalletBackup(archive.Bytes(), password)
+	if err != nil {
+		return "", err
+	}
+
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	backupFile := filepath.Join(dir, "beam-backup-"+strconv.FormatInt(now.Unix(), 10)+".bak")
+	if err = ioutil.WriteFile(backupFile, sealed, 0600); err != nil {
+		return "", err
+	}
+
+	wm.Log.Infof("export wallet backup to %s", backupFile)
+	return backupFile, nil
+}
+
+//snapshotBlockchainDB 在只读事务中导出适配器本地数据库的一致快照
+func (wm *WalletManager) snapshotBlockchainDB() ([]byte, error) {
+
+	db, err := storm.Open(filepath.Join(wm.Config.dbPath, wm.Config.BlockchainFile))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.Bolt.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var buf bytes.Buffer
+	if _, err = tx.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//RestoreWalletBackup 解密备份文件并把其中的文件解压到dir，dir中的同名文件会被覆盖，不会替换正在使用的钱包文件，
+//恢复时先停止钱包API，再把wallet.db和适配器数据库复制到配置的位置
+func (wm *WalletManager) RestoreWalletBackup(backupFile, password, dir string) (*WalletBackupManifest, error) {
+
+	sealed, err := ioutil.ReadFile(backupFile)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := openWalletBackup(sealed, password)
+	if err != nil {
+		return nil, err
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	var manifest *WalletBackupManifest
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		//只接受备份导出的文件名，不允许路径
+		if header.Name != filepath.Base(header.Name) || strings.HasPrefix(header.Name, ".") {
+			return nil, fmt.Errorf("wallet backup contains invalid file: %s", header.Name)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if header.Name == walletBackupManifestFile {
+			manifest = &WalletBackupManifest{}
+			if err = json.Unmarshal(data, manifest); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, header.Name), data, 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("wallet backup manifest is missing")
+	}
+	wm.Log.Infof("restore wallet backup of height: %d to %s", manifest.Height, dir)
+	return manifest, nil
+}
+
+//sealWalletBackup 加密备份：文件头|盐|nonce|密文
+func sealWalletBackup(plain []byte, password string) ([]byte, error) {
+
+	salt := make([]byte, walletBackupSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := passphraseCipher(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(walletBackupMagic)+len(salt)+len(nonce)+len(plain)+gcm.Overhead())
+	out = append(out, walletBackupMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plain, []byte(walletBackupMagic)), nil
+}
+
+//openWalletBackup 解密备份，口令错误或文件损坏时返回错误
+func openWalletBackup(sealed []byte, password string) ([]byte, error) {
+
+	if len(password) == 0 {
+		return nil, fmt.Errorf("wallet backup password is empty")
+	}
+	if !bytes.HasPrefix(sealed, []byte(walletBackupMagic)) || len(sealed) < len(walletBackupMagic)+walletBackupSaltSize {
+		return nil, fmt.Errorf("file is not a wallet backup")
+	}
+	sealed = sealed[len(walletBackupMagic):]
+	salt := sealed[:walletBackupSaltSize]
+	sealed = sealed[walletBackupSaltSize:]
+
+	gcm, err := passphraseCipher(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("file is not a wallet backup")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(walletBackupMagic))
+	if err != nil {
+		return nil, fmt.Errorf("wallet backup password is wrong or the file is corrupted")
+	}
+	return plain, nil
+}
+
+//passphraseCipher 按口令和盐创建AES-256-GCM，用于备份和配置中的加密值
+func passphraseCipher(password string, salt []byte) (cipher.AEAD, error) {
+	key, err := passphraseKey(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
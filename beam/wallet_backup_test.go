@@ -0,0 +1,72 @@
+package beam
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalletManager_ExportWalletBackup(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"current_height":1055,"available":100}}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	walletData := []byte("beam wallet.db content")
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.walletdatafile = filepath.Join(dir, "wallet.db")
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	if err := ioutil.WriteFile(wm.Config.walletdatafile, walletData, 0600); err != nil {
+		t.Errorf("write wallet.db failed: %v", err)
+		return
+	}
+	wm.addressBook.Add(AddressTypeRegular, "", "addr1")
+	if err := wm.addressBook.SetLabel("addr1", "user1"); err != nil {
+		t.Errorf("set label failed: %v", err)
+		return
+	}
+
+	if _, err := wm.ExportWalletBackup(dir, ""); err == nil {
+		t.Errorf("ExportWalletBackup without password should fail")
+	}
+	backupFile, err := wm.ExportWalletBackup(filepath.Join(dir, "backup"), "secret")
+	if err != nil {
+		t.Errorf("ExportWalletBackup failed: %v", err)
+		return
+	}
+	sealed, _ := ioutil.ReadFile(backupFile)
+	if bytes.Contains(sealed, walletData) {
+		t.Errorf("wallet backup is not encrypted")
+	}
+
+	if _, err = wm.RestoreWalletBackup(backupFile, "wrong", filepath.Join(dir, "wrong")); err == nil {
+		t.Errorf("RestoreWalletBackup with wrong password should fail")
+	}
+
+	restoreDir := filepath.Join(dir, "restore")
+	manifest, err := wm.RestoreWalletBackup(backupFile, "secret", restoreDir)
+	if err != nil {
+		t.Errorf("RestoreWalletBackup failed: %v", err)
+		return
+	}
+	if manifest.Height != 1055 || len(manifest.Files) != 2 {
+		t.Errorf("RestoreWalletBackup manifest = %+v", manifest)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(restoreDir, "wallet.db")); !bytes.Equal(data, walletData) {
+		t.Errorf("restored wallet.db = %s", data)
+	}
+
+	//恢复的适配器数据库包含地址簿
+	restored := NewWalletManager()
+	restored.Config.dbPath = restoreDir
+	entry, err := restored.addressBook.Get("addr1")
+	if err != nil || entry.Label != "user1" {
+		t.Errorf("restored address book entry = %+v, %v", entry, err)
+	}
+}
@@ -0,0 +1,111 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+//RestoreWalletFromSeed 通过beam-wallet命令行（walletcli）按助记词在walletdatafile创建新钱包，password为空时使用walletpassword配置，
+//钱包API没有恢复钱包的接口，且命令行需要独占打开wallet.db，只能在钱包API启动前执行，walletdatafile已存在时不会覆盖
+func (wm *WalletManager) RestoreWalletFromSeed(ctx context.Context, seed, password string) error {
+
+	if len(wm.Config.walletdatafile) == 0 {
+		return fmt.Errorf("walletdatafile is not configured")
+	}
+	if len(strings.Fields(seed)) == 0 {
+		return fmt.Errorf("seed phrase is empty")
+	}
+	if len(password) == 0 {
+		password = wm.Config.walletpassword.String()
+	}
+	if len(password) == 0 {
+		return fmt.Errorf("wallet password is empty")
+	}
+	if _, err := os.Stat(wm.Config.walletdatafile); err == nil {
+		return fmt.Errorf("wallet data file: %s already exists", wm.Config.walletdatafile)
+	}
+
+	//助记词和密码不放在命令行参数中，按beam-wallet的提示顺序从标准输入传入：密码、确认密码、助记词（以分号分隔）
+	cmd := exec.CommandContext(ctx, wm.Config.walletcli, "restore",
+		"--wallet_path="+wm.Config.walletdatafile,
+	)
+	cmd.Stdin = strings.NewReader(password + "\n" + password + "\n" + strings.Join(strings.Fields(seed), ";") + "\n")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		//输出可能包含助记词，不写日志
+		return fmt.Errorf("beam-wallet restore failed: %v", err)
+	}
+	if _, err = os.Stat(wm.Config.walletdatafile); err != nil {
+		return fmt.Errorf("beam-wallet restore did not create the wallet data file: %s", strings.TrimSpace(string(output)))
+	}
+
+	wm.Log.Infof("restore wallet to %s, start the wallet api to rescan the utxos", wm.Config.walletdatafile)
+	return nil
+}
+
+//WaitWalletSynced 等待钱包API可用并同步到链上最新高度（恢复的钱包连接节点后会重新扫描UTXO），
+//超过walletsynctimeout或ctx取消时返回错误，返回同步完成时的钱包状态
+func (wm *WalletManager) WaitWalletSynced(ctx context.Context, period time.Duration) (*WalletStatus, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, wm.Config.walletsynctimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		status, err := wm.walletClient.GetWalletStatus(ctx)
+		if err == nil {
+			chain, chainErr := wm.walletClient.GetBlockchainInfo(ctx)
+			if chainErr == nil && status.CurrentHeight > 0 && status.CurrentHeight >= chain.Height {
+				return status, nil
+			}
+			if chainErr != nil {
+				err = chainErr
+			} else {
+				wm.Log.Infof("wallet is syncing, wallet height: %d, chain height: %d", status.CurrentHeight, chain.Height)
+			}
+		}
+		if err != nil {
+			wm.Log.Infof("wait for the wallet api, unexpected error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait for the wallet to sync timeout: %v", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+//PrimeScannerState 把本地扫描高度设为钱包当前高度，恢复的钱包不需要从头扫块，
+//之前的交易已在钱包中，需要的历史记录通过import legacy导入
+func (wm *WalletManager) PrimeScannerState(status *WalletStatus) {
+	wm.SaveLocalNewBlock(status.CurrentHeight, status.CurrentStateHash)
+	wm.Log.Infof("prime the block scanner to height: %d", status.CurrentHeight)
+}
+
+//BootstrapWallet 新部署的引导：seed不为空时先用助记词恢复钱包，ready在需要启动钱包API时调用（可为nil），
+//再等待钱包同步完成并把扫描高度设为当前高度
+func (wm *WalletManager) BootstrapWallet(ctx context.Context, seed, password string, ready func()) (*WalletStatus, error) {
+
+	if len(seed) > 0 {
+		if err := wm.RestoreWalletFromSeed(ctx, seed, password); err != nil {
+			return nil, err
+		}
+	}
+	if ready != nil {
+		ready()
+	}
+
+	status, err := wm.WaitWalletSynced(ctx, DefaultWalletSyncCheckPeriod)
+	if err != nil {
+		return nil, err
+	}
+	wm.PrimeScannerState(status)
+	return status, nil
+}
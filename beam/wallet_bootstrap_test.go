@@ -0,0 +1,91 @@
+package beam
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWalletManager_BootstrapWallet(t *testing.T) {
+
+	//钱包每次查询同步一个区块，追上链上高度后同步完成
+	walletHeight := uint64(99)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/status") {
+			w.Write([]byte(`{"height":100,"hash":"h100"}`))
+			return
+		}
+		walletHeight++
+		w.Write([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":{"current_height":%d,"current_state_hash":"h%d"}}`,
+			walletHeight, walletHeight)))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	stdinFile := filepath.Join(dir, "stdin")
+	//模拟beam-wallet命令行：记录参数和标准输入并创建钱包文件
+	cli := filepath.Join(dir, "beam-wallet")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\ncat > " + stdinFile + "\nfor a in \"$@\"; do case $a in --wallet_path=*) touch \"${a#--wallet_path=}\";; esac; done\n"
+	if err := ioutil.WriteFile(cli, []byte(script), 0700); err != nil {
+		t.Errorf("write wallet cli failed: %v", err)
+		return
+	}
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.walletcli = cli
+	wm.Config.walletdatafile = filepath.Join(dir, "wallet.db")
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	if err := wm.RestoreWalletFromSeed(context.Background(), "  ", "pass"); err == nil {
+		t.Errorf("RestoreWalletFromSeed with empty seed should fail")
+	}
+
+	ready := false
+	status, err := wm.BootstrapWallet(context.Background(), "word1 word2 word3", "pass", func() { ready = true })
+	if err != nil {
+		t.Errorf("BootstrapWallet failed: %v", err)
+		return
+	}
+	args, _ := ioutil.ReadFile(argsFile)
+	if !strings.Contains(string(args), "restore") || strings.Contains(string(args), "word1") || strings.Contains(string(args), "pass") {
+		t.Errorf("wallet cli args = %s", args)
+	}
+	if stdin, _ := ioutil.ReadFile(stdinFile); string(stdin) != "pass\npass\nword1;word2;word3\n" {
+		t.Errorf("wallet cli stdin = %q", stdin)
+	}
+	if !ready || status.CurrentHeight != 100 {
+		t.Errorf("BootstrapWallet ready: %v, height: %d", ready, status.CurrentHeight)
+	}
+	if height, hash := wm.GetLocalNewBlock(); height != 100 || hash != "h100" {
+		t.Errorf("local block = %d, %s", height, hash)
+	}
+
+	//钱包文件已存在时不覆盖
+	if _, err = os.Stat(wm.Config.walletdatafile); err != nil {
+		t.Errorf("wallet data file is not created: %v", err)
+	}
+	if err = wm.RestoreWalletFromSeed(context.Background(), "word1", "pass"); err == nil {
+		t.Errorf("RestoreWalletFromSeed should not overwrite the wallet data file")
+	}
+
+	walletHeight = 96
+	if status, err = wm.WaitWalletSynced(context.Background(), 10*time.Millisecond); err != nil || status.CurrentHeight != 100 {
+		t.Errorf("WaitWalletSynced = %+v, %v", status, err)
+	}
+
+	//同步超时
+	wm.Config.walletsynctimeout = 50 * time.Millisecond
+	server.Close()
+	if _, err = wm.WaitWalletSynced(context.Background(), 10*time.Millisecond); err == nil {
+		t.Errorf("WaitWalletSynced should timeout")
+	}
+}
@@ -0,0 +1,176 @@
+package beam
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blocktree/openwallet/timer"
+)
+
+const (
+	//多个钱包API的选择方式
+	WalletAPIPolicyFailover   = "failover"   //优先使用排在前面的可用节点
+	WalletAPIPolicyRoundRobin = "roundrobin" //轮流使用可用节点
+)
+
+//walletAPIWriteMethods 非幂等的钱包API方法，请求可能已送达时不能切换节点重发
+var walletAPIWriteMethods = map[string]bool{
+	"tx_send":   true,
+	"tx_cancel": true,
+	"tx_delete": true,
+	"tx_split":  true,
+	//重复请求会多创建地址
+	"create_address": true,
+	//create_tx为true时钱包创建合约交易
+	"invoke_contract":     true,
+	"process_invoke_data": true,
+}
+
+//WalletEndpointStatus 钱包API节点状态
+type WalletEndpointStatus struct {
+	URL       string `json:"url"`
+	Healthy   bool   `json:"healthy"`
+	LastError string `json:"lastError"`
+	CheckTime int64  `json:"checkTime"`
+}
+
+//walletEndpoints 钱包API节点列表，请求失败的节点标记为不可用，由健康检查恢复
+type walletEndpoints struct {
+	mu     sync.RWMutex
+	status []*WalletEndpointStatus
+	policy string
+	next   uint32
+}
+
+//newWalletEndpoints 创建钱包API节点列表，walletAPI可配置多个地址，用逗号分隔
+func newWalletEndpoints(walletAPI string) *walletEndpoints {
+	e := &walletEndpoints{policy: WalletAPIPolicyFailover}
+	for _, u := range strings.Split(walletAPI, ",") {
+		u = strings.TrimSuffix(strings.TrimSpace(u), "/")
+		if len(u) == 0 {
+			continue
+		}
+		e.status = append(e.status, &WalletEndpointStatus{URL: u, Healthy: true})
+	}
+	return e
+}
+
+//urls 本次请求依次尝试的节点，可用节点按选择方式排序，不可用节点放在最后
+func (e *walletEndpoints) urls() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	healthy := make([]string, 0, len(e.status))
+	unhealthy := make([]string, 0)
+	for _, s := range e.status {
+		if s.Healthy {
+			healthy = append(healthy, s.URL)
+		} else {
+			unhealthy = append(unhealthy, s.URL)
+		}
+	}
+
+	if e.policy == WalletAPIPolicyRoundRobin && len(healthy) > 1 {
+		start := int(atomic.AddUint32(&e.next, 1) % uint32(len(healthy)))
+		healthy = append(healthy[start:], healthy[:start]...)
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+//mark 记录节点的请求结果
+func (e *walletEndpoints) mark(u string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range e.status {
+		if s.URL != u {
+			continue
+		}
+		s.Healthy = err == nil
+		s.LastError = ""
+		if err != nil {
+			s.LastError = err.Error()
+		}
+		s.CheckTime = time.Now().Unix()
+	}
+}
+
+//Status 节点状态
+func (e *walletEndpoints) Status() []WalletEndpointStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	list := make([]WalletEndpointStatus, 0, len(e.status))
+	for _, s := range e.status {
+		list = append(list, *s)
+	}
+	return list
+}
+
+//isDialError 连接节点失败，请求没有发出
+func isDialError(err error) bool {
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err
+	}
+	if oe, ok := err.(*net.OpError); ok {
+		return oe.Op == "dial"
+	}
+	return false
+}
+
+//SetWalletAPIPolicy 设置多个钱包API的选择方式
+func (c *WalletClient) SetWalletAPIPolicy(policy string) {
+	c.endpoints.mu.Lock()
+	c.endpoints.policy = policy
+	c.endpoints.mu.Unlock()
+}
+
+//WalletEndpoints 钱包API节点状态
+func (c *WalletClient) WalletEndpoints() []WalletEndpointStatus {
+	return c.endpoints.Status()
+}
+
+//CheckWalletEndpoints 查询每个钱包API节点的钱包状态，更新节点是否可用
+func (c *WalletClient) CheckWalletEndpoints() {
+	for _, s := range c.endpoints.Status() {
+		r, err := c.post(context.Background(), s.URL, "wallet_status", newRPCBody(1, "wallet_status", nil))
+		if err == nil {
+			err = isError(r)
+		}
+		c.endpoints.mark(s.URL, err)
+	}
+}
+
+//GetWalletEndpoints 钱包API节点状态
+func (wm *WalletManager) GetWalletEndpoints() []WalletEndpointStatus {
+	c, ok := wm.httpWalletClient()
+	if !ok {
+		return nil
+	}
+	return c.WalletEndpoints()
+}
+
+//StartWalletEndpointChecker 启动钱包API节点健康检查，只配置一个节点时不启动
+func (wm *WalletManager) StartWalletEndpointChecker() {
+
+	c, ok := wm.httpWalletClient()
+	if !ok || len(c.WalletEndpoints()) < 2 || wm.walletEndpointChecker != nil {
+		return
+	}
+
+	wm.Log.Infof("The timer for wallet api health check start now. Execute by every %v seconds.", wm.Config.walletapihealthcheckperiod.Seconds())
+
+	wm.walletEndpointChecker = timer.NewTask(wm.Config.walletapihealthcheckperiod, func() {
+		c.CheckWalletEndpoints()
+		for _, s := range c.WalletEndpoints() {
+			if !s.Healthy {
+				wm.Log.Warningf("wallet api: %s is unavailable, unexpected error: %s", s.URL, s.LastError)
+			}
+		}
+	})
+	wm.walletEndpointChecker.Start()
+}
@@ -0,0 +1,68 @@
+package beam
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWalletClient_Failover(t *testing.T) {
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"current_height":100}}`))
+	}))
+	defer up.Close()
+
+	c := NewWalletClient(down.URL+","+up.URL+"/", "", false)
+	if c.WalletAPI != down.URL {
+		t.Errorf("WalletAPI = %s, want %s", c.WalletAPI, down.URL)
+	}
+
+	status, err := c.GetWalletStatus(context.Background())
+	if err != nil {
+		t.Errorf("GetWalletStatus failed unexpected error: %v", err)
+		return
+	}
+	if status.CurrentHeight != 100 {
+		t.Errorf("CurrentHeight = %d, want 100", status.CurrentHeight)
+	}
+
+	endpoints := c.WalletEndpoints()
+	if endpoints[0].Healthy || !endpoints[1].Healthy {
+		t.Errorf("unexpected endpoints: %+v", endpoints)
+	}
+
+	//不可用节点排在最后
+	urls := c.endpoints.urls()
+	if urls[0] != up.URL || urls[1] != down.URL {
+		t.Errorf("urls = %v", urls)
+	}
+
+	//连接失败的请求没有发出，非幂等方法也可以切换节点
+	_, err = c.post(context.Background(), down.URL, "tx_send", nil)
+	if !isDialError(err) {
+		t.Errorf("isDialError(%v) = false, want true", err)
+	}
+}
+
+func TestWalletEndpoints_RoundRobin(t *testing.T) {
+
+	e := newWalletEndpoints("http://a, http://b,,http://c")
+	e.policy = WalletAPIPolicyRoundRobin
+	if len(e.status) != 3 {
+		t.Errorf("endpoints count = %d, want 3", len(e.status))
+		return
+	}
+
+	first := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		first[e.urls()[0]] = true
+	}
+	if len(first) != 3 {
+		t.Errorf("round robin should use every endpoint, got %v", first)
+	}
+}
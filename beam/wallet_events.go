@@ -0,0 +1,388 @@
+package beam
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//钱包API推送的事件
+	WalletEventSyncProgress = "ev_sync_progress" //钱包同步进度
+	WalletEventTxsChanged   = "ev_txs_changed"   //交易单变化
+	WalletEventAddrsChanged = "ev_addrs_changed" //地址变化
+	WalletEventUtxosChanged = "ev_utxos_changed" //UTXO变化，即余额变化
+
+	//钱包事件连接断开后的重连等待时间
+	walletEventReconnectDelay = 5 * time.Second
+)
+
+const (
+	//ev_txs_changed的变化类型
+	walletEventChangeAdded   = 0
+	walletEventChangeRemoved = 1
+	walletEventChangeUpdated = 2
+	walletEventChangeReset   = 3
+)
+
+//WalletSyncProgress 钱包同步进度
+type WalletSyncProgress struct {
+	Current    uint64 `json:"current"`
+	Total      uint64 `json:"total"`
+	UpdateTime int64  `json:"updateTime"`
+}
+
+//WalletEventListener 通过钱包API的TCP或websocket长连接订阅钱包事件，
+//跟踪发送中的交易单，已扫描区块中新完成的交易单直接提取，减少轮询tx_list
+type WalletEventListener struct {
+	wm     *WalletManager
+	addr   string
+	client *WalletClient //websocket传输的钱包API客户端，不为空时通过该连接订阅
+
+	mu           sync.RWMutex
+	connected    bool
+	inProgress   map[string]*Transaction
+	syncProgress WalletSyncProgress
+	walletStatus *WalletStatus
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+//NewWalletEventListener 创建钱包事件订阅，addr为钱包API的TCP地址
+func NewWalletEventListener(wm *WalletManager, addr string) *WalletEventListener {
+	return &WalletEventListener{
+		wm:         wm,
+		addr:       addr,
+		inProgress: make(map[string]*Transaction),
+	}
+}
+
+//NewWalletEventListenerWS 创建钱包事件订阅，通过钱包API客户端的websocket连接接收事件
+func NewWalletEventListenerWS(wm *WalletManager, client *WalletClient) *WalletEventListener {
+	l := NewWalletEventListener(wm, client.WalletAPI)
+	l.client = client
+	return l
+}
+
+//StartWalletEventListener 开启钱包事件订阅，需要开启walletevents功能，
+//钱包API使用websocket传输时复用该连接，否则需要配置walleteventapi
+func (wm *WalletManager) StartWalletEventListener() {
+
+	if !wm.IsFeatureEnabled(FeatureWalletEvents) {
+		return
+	}
+
+	if c, ok := wm.httpWalletClient(); ok && c.Transport() == WalletAPITransportWebsocket {
+		wm.walletEvents = NewWalletEventListenerWS(wm, c)
+	} else if len(wm.Config.walleteventapi) > 0 {
+		wm.walletEvents = NewWalletEventListener(wm, wm.Config.walleteventapi)
+	} else {
+		return
+	}
+	wm.walletEvents.Start()
+}
+
+//Start 后台连接钱包API，断线后自动重连
+func (l *WalletEventListener) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+	l.done = make(chan struct{})
+	go l.run(ctx)
+}
+
+//Stop 关闭连接，等待后台线程退出
+func (l *WalletEventListener) Stop() {
+	if l.cancel == nil {
+		return
+	}
+	l.cancel()
+	<-l.done
+}
+
+//Connected 是否已连接并订阅钱包事件
+func (l *WalletEventListener) Connected() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.connected
+}
+
+//InProgressTxs 事件跟踪的发送中交易单
+func (l *WalletEventListener) InProgressTxs() []*Transaction {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	txs := make([]*Transaction, 0, len(l.inProgress))
+	for _, tx := range l.inProgress {
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+//WalletStatus 最近一次余额变化后查询的钱包状态，未收到余额变化事件时返回nil
+func (l *WalletEventListener) WalletStatus() *WalletStatus {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.walletStatus
+}
+
+//SyncProgress 最近一次推送的钱包同步进度
+func (l *WalletEventListener) SyncProgress() WalletSyncProgress {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.syncProgress
+}
+
+func (l *WalletEventListener) run(ctx context.Context) {
+	defer close(l.done)
+
+	for {
+		err := l.listen(ctx)
+		l.setConnected(false)
+		if ctx.Err() != nil {
+			return
+		}
+
+		l.wm.Log.Errorf("wallet event connection closed, unexpected error: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(walletEventReconnectDelay):
+		}
+	}
+}
+
+//subscribeParams 订阅的钱包事件
+func subscribeParams() map[string]interface{} {
+	return map[string]interface{}{
+		WalletEventSyncProgress: true,
+		WalletEventTxsChanged:   true,
+		WalletEventAddrsChanged: true,
+		WalletEventUtxosChanged: true,
+	}
+}
+
+//listen 建立连接并订阅事件，读取事件直到连接断开
+func (l *WalletEventListener) listen(ctx context.Context) error {
+
+	if l.client != nil {
+		return l.listenWS(ctx)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", l.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	//ctx结束时关闭连接，中断读取
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	subscribe := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "ev_subunsub",
+		"method":  "ev_subunsub",
+		"params":  subscribeParams(),
+	}
+	data, err := json.Marshal(subscribe)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+
+	//事件只推送变化，连接后先全量加载发送中的交易单
+	err = l.resetInProgress()
+	if err != nil {
+		return err
+	}
+
+	l.wm.Log.Infof("wallet event subscribed [%s]", l.addr)
+	l.setConnected(true)
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			l.handle(line)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//listenWS 在钱包API的websocket连接上订阅事件，连接断开后由run重新订阅
+func (l *WalletEventListener) listenWS(ctx context.Context) error {
+
+	l.client.OnWalletEvent(l.handle)
+
+	conn, err := l.client.ws.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.client.callContext(ctx, "ev_subunsub", subscribeParams())
+	if err != nil {
+		return err
+	}
+
+	err = l.resetInProgress()
+	if err != nil {
+		return err
+	}
+
+	l.wm.Log.Infof("wallet event subscribed [%s] by websocket", l.addr)
+	l.setConnected(true)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-conn.done:
+		return conn.err
+	}
+}
+
+func (l *WalletEventListener) setConnected(connected bool) {
+	l.mu.Lock()
+	l.connected = connected
+	l.mu.Unlock()
+}
+
+//resetInProgress 通过tx_list重新加载发送中的交易单
+func (l *WalletEventListener) resetInProgress() error {
+	txs, err := l.wm.walletClient.GetTransactionsByStatus(context.Background(), TxStatusInProgress)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.inProgress = make(map[string]*Transaction, len(txs))
+	for _, tx := range txs {
+		l.inProgress[tx.TxID] = tx
+	}
+	l.mu.Unlock()
+	return nil
+}
+
+//handle 处理一条钱包事件
+func (l *WalletEventListener) handle(line []byte) {
+
+	msg := gjson.ParseBytes(line)
+	if msg.Get("error").IsObject() {
+		l.wm.Log.Errorf("wallet event error: [%d]%s",
+			msg.Get("error.code").Int(),
+			msg.Get("error.message").String())
+		return
+	}
+
+	//事件名在id字段，兼容使用method字段的版本
+	event := msg.Get("method").String()
+	if len(event) == 0 {
+		event = msg.Get("id").String()
+	}
+	result := msg.Get("result")
+
+	switch event {
+	case WalletEventTxsChanged:
+		l.handleTxsChanged(&result)
+	case WalletEventSyncProgress:
+		l.mu.Lock()
+		l.syncProgress = WalletSyncProgress{
+			Current:    result.Get("current").Uint(),
+			Total:      result.Get("total").Uint(),
+			UpdateTime: time.Now().Unix(),
+		}
+		l.mu.Unlock()
+	case WalletEventUtxosChanged:
+		l.refreshWalletStatus()
+	case WalletEventAddrsChanged:
+		for _, a := range result.Get("addrs").Array() {
+			if a.Get("own").Bool() && a.Get("expired").Bool() && a.Get("comment").String() == "self" {
+				l.wm.Log.Std.Warn("wallet address: %s is expired", a.Get("address").String())
+			}
+		}
+	}
+}
+
+//refreshWalletStatus 余额变化后重新查询钱包状态
+func (l *WalletEventListener) refreshWalletStatus() {
+	status, err := l.wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		l.wm.Log.Errorf("refresh wallet status failed, unexpected error: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	l.walletStatus = status
+	l.mu.Unlock()
+}
+
+//handleTxsChanged 更新发送中的交易单，新完成的交易单交给扫描器
+func (l *WalletEventListener) handleTxsChanged(result *gjson.Result) {
+
+	change := result.Get("change").Int()
+	if change == walletEventChangeReset {
+		err := l.resetInProgress()
+		if err != nil {
+			l.wm.Log.Errorf("reload in progress transactions failed, unexpected error: %v", err)
+		}
+	}
+
+	for _, obj := range result.Get("txs").Array() {
+		tx := NewTransaction(&obj)
+
+		l.mu.Lock()
+		if change == walletEventChangeRemoved || tx.Status != TxStatusInProgress {
+			delete(l.inProgress, tx.TxID)
+		} else {
+			l.inProgress[tx.TxID] = tx
+		}
+		l.mu.Unlock()
+
+		if change != walletEventChangeRemoved && tx.Status == TxStatusCompleted {
+			l.wm.Blockscanner.extractEventTransaction(tx)
+		}
+	}
+}
+
+//extractEventTransaction 提取钱包事件推送的已完成交易单，
+//区块未扫描的由扫描器正常处理，已记账的交易单不重复通知
+func (bs *BEAMBlockScanner) extractEventTransaction(tx *Transaction) {
+
+	scanTargetFunc := bs.scanTargetFunc()
+	if scanTargetFunc == nil || tx.BlockHeight == 0 {
+		return
+	}
+
+	scannedHeight, _ := bs.wm.GetLocalNewBlock()
+	if tx.BlockHeight > scannedHeight {
+		return
+	}
+
+	records, err := bs.wm.GetLedgerRecordsByTxID(tx.TxID)
+	if err != nil || len(records) > 0 {
+		return
+	}
+
+	bs.wm.Log.Std.Info("wallet event tx: %s completed on scanned height: %d, extract it", tx.TxID, tx.BlockHeight)
+	result := bs.ExtractTransaction(bs.context(), tx.BlockHeight, "", tx, scanTargetFunc)
+	bs.saveExtractResult(tx.BlockHeight, result)
+}
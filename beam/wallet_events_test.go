@@ -0,0 +1,28 @@
+package beam
+
+import (
+	"testing"
+)
+
+func TestWalletEventListener_Handle(t *testing.T) {
+
+	wm := NewWalletManager()
+	l := NewWalletEventListener(wm, "")
+
+	l.handle([]byte(`{"jsonrpc":"2.0","id":"ev_txs_changed","result":{"change":0,"change_str":"added","txs":[{"txId":"tx1","status":1,"create_time":1559873867},{"txId":"tx2","status":1,"create_time":1559873867}]}}`))
+	if txs := l.InProgressTxs(); len(txs) != 2 {
+		t.Errorf("InProgressTxs = %d, want 2", len(txs))
+	}
+
+	l.handle([]byte(`{"jsonrpc":"2.0","id":"ev_txs_changed","result":{"change":2,"change_str":"updated","txs":[{"txId":"tx1","status":3,"height":100}]}}`))
+	l.handle([]byte(`{"jsonrpc":"2.0","id":"ev_txs_changed","result":{"change":1,"change_str":"removed","txs":[{"txId":"tx2","status":1}]}}`))
+	if txs := l.InProgressTxs(); len(txs) != 0 {
+		t.Errorf("InProgressTxs = %d, want 0", len(txs))
+	}
+
+	l.handle([]byte(`{"jsonrpc":"2.0","id":"ev_sync_progress","result":{"current":5,"total":10}}`))
+	progress := l.SyncProgress()
+	if progress.Current != 5 || progress.Total != 10 {
+		t.Errorf("SyncProgress = %+v, want current 5 total 10", progress)
+	}
+}
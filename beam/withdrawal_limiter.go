@@ -0,0 +1,140 @@
+package beam
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+const (
+	//提现处理中数量达到上限时的处理方式
+	WithdrawalOverflowQueue      = "queue"      //排队等待
+	WithdrawalOverflowReject     = "reject"     //直接拒绝
+	WithdrawalOverflowShedOldest = "shedoldest" //取消最早的发送中交易单，包括汇总、归集和合并碎片的交易单
+
+	//排队时查询处理中交易单的间隔
+	withdrawalQueuePollInterval = 3 * time.Second
+)
+
+//WithdrawalLimiter 限制同时处理中的提现交易数量，避免突发提现耗尽钱包UTXO
+type WithdrawalLimiter struct {
+	wm      *WalletManager
+	mu      sync.Mutex
+	waiting int32
+}
+
+//NewWithdrawalLimiter 创建提现限制器
+func NewWithdrawalLimiter(wm *WalletManager) *WithdrawalLimiter {
+	return &WithdrawalLimiter{wm: wm}
+}
+
+//Acquire 获取提现额度，成功后必须在交易单提交完成后调用release
+func (l *WithdrawalLimiter) Acquire() (release func(), err error) {
+
+	max := l.wm.Config.maxinflightwithdrawals
+	if max <= 0 {
+		return func() {}, nil
+	}
+
+	policy := l.wm.Config.withdrawaloverflow
+	if policy == WithdrawalOverflowQueue {
+		if int(atomic.AddInt32(&l.waiting, 1)) > l.wm.Config.withdrawalqueuesize {
+			atomic.AddInt32(&l.waiting, -1)
+			return nil, openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "withdrawal queue is full")
+		}
+		defer atomic.AddInt32(&l.waiting, -1)
+	}
+
+	//串行提交，保证检查数量和提交交易单之间没有其他提现
+	l.mu.Lock()
+	deadline := time.Now().Add(l.wm.Config.withdrawalqueuetimeout)
+	refused := make(map[string]bool) //钱包拒绝取消的交易单，不再重试
+
+	for {
+		txs, err := l.wm.GetInFlightWithdrawals()
+		if err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+
+		if len(txs) < max {
+			return l.mu.Unlock, nil
+		}
+
+		switch policy {
+		case WithdrawalOverflowReject:
+			l.mu.Unlock()
+			return nil, openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "in-flight withdrawals reached the limit: %d", max)
+		case WithdrawalOverflowShedOldest:
+			oldest := oldestCancelableWithdrawal(txs, refused)
+			if oldest == nil {
+				l.mu.Unlock()
+				return nil, openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "in-flight withdrawals reached the limit: %d, no transaction can be canceled", max)
+			}
+			l.wm.Log.Warningf("in-flight withdrawals reached the limit: %d, cancel the oldest tx: %s", max, oldest.TxID)
+			flag, err := l.wm.walletClient.CancelTx(context.Background(), oldest.TxID)
+			if err != nil {
+				l.mu.Unlock()
+				return nil, err
+			}
+			if !flag {
+				l.wm.Log.Warningf("cancel tx: %s refused by wallet, try the next one", oldest.TxID)
+				refused[oldest.TxID] = true
+				continue
+			}
+			if err := l.wm.utxoLocks.unlockTx(oldest.TxID); err != nil {
+				l.wm.Log.Errorf("release utxo locks of tx: %s failed, unexpected error: %v", oldest.TxID, err)
+			}
+		default:
+			if time.Now().After(deadline) {
+				l.mu.Unlock()
+				return nil, openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "wait for in-flight withdrawals timeout")
+			}
+			time.Sleep(withdrawalQueuePollInterval)
+		}
+	}
+}
+
+//Waiting 排队中的提现数量
+func (l *WithdrawalLimiter) Waiting() int {
+	return int(atomic.LoadInt32(&l.waiting))
+}
+
+//GetInFlightWithdrawals 获取处理中的提现交易单，包括汇总、归集和合并碎片等所有发出的交易单
+func (wm *WalletManager) GetInFlightWithdrawals() ([]*Transaction, error) {
+
+	withdrawals := make([]*Transaction, 0)
+	for _, status := range []int{TxStatusPending, TxStatusInProgress, TxStatusRegistering} {
+		txs, err := wm.walletClient.GetTransactionsByStatus(context.Background(), status)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range txs {
+			if !tx.Income {
+				withdrawals = append(withdrawals, tx)
+			}
+		}
+	}
+	return withdrawals, nil
+}
+
+//oldestCancelableWithdrawal 最早创建的可取消交易单，已上链打包中的交易单和skip中的交易单不能取消，
+//汇总、归集和合并碎片的交易单同样会被取消
+func oldestCancelableWithdrawal(txs []*Transaction, skip map[string]bool) *Transaction {
+	var oldest *Transaction
+	for _, tx := range txs {
+		if tx.Status != TxStatusPending && tx.Status != TxStatusInProgress {
+			continue
+		}
+		if skip[tx.TxID] {
+			continue
+		}
+		if oldest == nil || tx.CreateTime < oldest.CreateTime {
+			oldest = tx
+		}
+	}
+	return oldest
+}
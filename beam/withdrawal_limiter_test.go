@@ -0,0 +1,98 @@
+package beam
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestOldestCancelableWithdrawal(t *testing.T) {
+
+	txs := []*Transaction{
+		{TxID: "registering", Status: TxStatusRegistering, CreateTime: 100},
+		{TxID: "newer", Status: TxStatusInProgress, CreateTime: 300},
+		{TxID: "older", Status: TxStatusPending, CreateTime: 200},
+	}
+
+	oldest := oldestCancelableWithdrawal(txs, nil)
+	if oldest == nil || oldest.TxID != "older" {
+		t.Errorf("oldestCancelableWithdrawal = %+v, want older", oldest)
+	}
+
+	oldest = oldestCancelableWithdrawal(txs, map[string]bool{"older": true})
+	if oldest == nil || oldest.TxID != "newer" {
+		t.Errorf("oldestCancelableWithdrawal with skip = %+v, want newer", oldest)
+	}
+
+	oldest = oldestCancelableWithdrawal(txs[:1], nil)
+	if oldest != nil {
+		t.Errorf("oldestCancelableWithdrawal = %+v, want nil", oldest)
+	}
+}
+
+func TestWithdrawalLimiter_ShedOldest(t *testing.T) {
+
+	var (
+		inFlight = []string{"refused", "stuck", "fresh"}
+		canceled []string
+	)
+	server := newRPCTestServer(rpcTestMethods{
+		"tx_list": func(params map[string]interface{}) (string, error) {
+			filter, _ := params["filter"].(map[string]interface{})
+			if params["skip"] != float64(0) || filter["status"] != float64(TxStatusInProgress) {
+				return `[]`, nil
+			}
+			txs := make([]string, 0, len(inFlight))
+			for i, txID := range inFlight {
+				txs = append(txs, fmt.Sprintf(`{"txId":"%s","status":1,"income":false,"create_time":%d}`, txID, 100+i))
+			}
+			return "[" + strings.Join(txs, ",") + "]", nil
+		},
+		"tx_cancel": func(params map[string]interface{}) (string, error) {
+			txID := params["txId"].(string)
+			canceled = append(canceled, txID)
+			if txID == "refused" {
+				return "false", nil
+			}
+			for i, id := range inFlight {
+				if id == txID {
+					inFlight = append(inFlight[:i], inFlight[i+1:]...)
+					break
+				}
+			}
+			return "true", nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.maxinflightwithdrawals = 3
+	wm.Config.withdrawaloverflow = WithdrawalOverflowShedOldest
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	wm.utxoLocks.selectAndLock(CoinSelectLargestFirst, testCoins(10), 10, "order-1")
+	wm.utxoLocks.bind([]string{"a"}, "stuck")
+
+	//钱包拒绝取消最早的交易单时改为取消下一笔，取消后释放UTXO锁
+	release, err := wm.withdrawals.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire unexpected error: %v", err)
+	}
+	release()
+	if strings.Join(canceled, ",") != "refused,stuck" {
+		t.Errorf("tx_cancel = %v, want refused,stuck", canceled)
+	}
+	if locks, _ := wm.GetUtxoLocks(); len(locks) != 0 {
+		t.Errorf("locks = %+v, want released", locks)
+	}
+
+	//剩下的交易单都不能取消时返回错误，不会一直重试
+	wm.Config.maxinflightwithdrawals = 1
+	if _, err = wm.withdrawals.Acquire(); err == nil {
+		t.Errorf("Acquire should fail when no transaction can be canceled")
+	}
+	if len(inFlight) != 1 || inFlight[0] != "refused" {
+		t.Errorf("in-flight = %v, want refused", inFlight)
+	}
+}
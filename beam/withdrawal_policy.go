@@ -0,0 +1,559 @@
+package beam
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/common"
+	"github.com/blocktree/openwallet/crypto"
+	"github.com/shopspring/decimal"
+)
+
+//WithdrawalPolicy 提现策略，配置在withdrawalpolicyfile指定的json文件中，文件修改后自动重新加载；
+//金额单位为BEAM，为空表示不限制，金额限制只对BEAM提现生效
+type WithdrawalPolicy struct {
+	MaxPerTx          string   `json:"maxPerTx"`          //单笔提现最大金额
+	MaxPerDay         string   `json:"maxPerDay"`         //每天（UTC）提现总额上限
+	Destinations      []string `json:"destinations"`      //允许提现的地址或通配符模式，为空表示不限制
+	ApprovalThreshold string   `json:"approvalThreshold"` //提现金额达到该金额时需要审批，为空表示全部提现都需要审批
+	RequiredApprovals int      `json:"requiredApprovals"` //需要的不同审批人数量，0表示不需要审批
+
+	maxPerTx          uint64
+	maxPerDay         uint64
+	approvalThreshold uint64
+}
+
+//parse 把BEAM金额转换为groth
+func (p *WithdrawalPolicy) parse(decimals int32) error {
+
+	parse := func(name, value string) (uint64, error) {
+		if len(value) == 0 {
+			return 0, nil
+		}
+		d, err := decimal.NewFromString(value)
+		if err != nil || d.Sign() < 0 {
+			return 0, fmt.Errorf("withdrawal policy %s: %s is invalid", name, value)
+		}
+		return uint64(d.Shift(decimals).IntPart()), nil
+	}
+
+	var err error
+	if p.maxPerTx, err = parse("maxPerTx", p.MaxPerTx); err != nil {
+		return err
+	}
+	if p.maxPerDay, err = parse("maxPerDay", p.MaxPerDay); err != nil {
+		return err
+	}
+	if p.approvalThreshold, err = parse("approvalThreshold", p.ApprovalThreshold); err != nil {
+		return err
+	}
+	if p.RequiredApprovals < 0 {
+		return fmt.Errorf("withdrawal policy requiredApprovals: %d is invalid", p.RequiredApprovals)
+	}
+	p.Destinations, err = parseAddressPatterns(strings.Join(p.Destinations, ","))
+	return err
+}
+
+//WithdrawalPolicyRequest 提现策略检查的提现请求
+type WithdrawalPolicyRequest struct {
+	Sid     string //业务标识，提现审批按Sid登记
+	TxID    string //交易ID，重发同一交易ID时不重复计入每日总额
+	From    string
+	To      string
+	AssetID int64
+	Amount  uint64 //发送金额，不含手续费
+	Fee     uint64
+	//跳过提现地址白名单，需要配置允许withdrawwhitelistoverride，策略文件的destinations不能跳过
+	Override bool
+}
+
+//WithdrawalPolicyError 提现被策略拒绝
+type WithdrawalPolicyError struct {
+	Rule   string
+	Reason string
+}
+
+func (e *WithdrawalPolicyError) Error() string {
+	return fmt.Sprintf("withdrawal rejected by policy %s: %s", e.Rule, e.Reason)
+}
+
+const (
+	//提现策略规则
+	WithdrawalPolicyRuleFile         = "file"         //策略文件无法加载
+	WithdrawalPolicyRuleMaxPerTx     = "max_per_tx"   //单笔金额限制
+	WithdrawalPolicyRuleMaxPerDay    = "max_per_day"  //每日总额限制
+	WithdrawalPolicyRuleDestinations = "destinations" //策略文件的提现地址
+	WithdrawalPolicyRuleWhitelist    = "whitelist"    //提现地址白名单（enablewithdrawwhitelist）
+	WithdrawalPolicyRuleApprovals    = "approvals"    //审批
+	WithdrawalPolicyRuleHook         = "hook"         //自定义策略
+)
+
+//WithdrawalPolicyHook 自定义提现策略，返回错误时拒绝提现
+type WithdrawalPolicyHook func(req *WithdrawalPolicyRequest) error
+
+//WithdrawalPolicyDecision 提现策略的检查结果，记录在本地数据库用于审计
+type WithdrawalPolicyDecision struct {
+	ID      int64  `storm:"id,increment"`
+	Sid     string `storm:"index"`
+	From    string
+	To      string
+	AssetID int64
+	Amount  uint64
+	Allowed bool
+	Rule    string //拒绝的规则
+	Reason  string
+	Time    int64
+}
+
+//WithdrawalApproval 提现审批记录
+type WithdrawalApproval struct {
+	ID       string `storm:"id"`
+	Sid      string `storm:"index"`
+	Approver string
+	Time     int64
+}
+
+//WithdrawalPolicyUsage 每天（UTC）已发送的BEAM提现总额
+type WithdrawalPolicyUsage struct {
+	Day    string `storm:"id"`
+	Amount uint64
+}
+
+//WithdrawalPolicyUsageRecord 计入每日总额的一笔提现，按交易ID登记，没有交易ID时按Sid登记
+type WithdrawalPolicyUsageRecord struct {
+	Key    string `storm:"id"`
+	Day    string //计入的日期（UTC）
+	Amount uint64
+	Time   int64
+}
+
+//usageKey 每日总额按交易ID去重，没有交易ID时按Sid
+func (req *WithdrawalPolicyRequest) usageKey() string {
+	if len(req.TxID) > 0 {
+		return req.TxID
+	}
+	return req.Sid
+}
+
+//WithdrawalPolicyEngine 提现策略引擎，每次发送提现前检查
+type WithdrawalPolicyEngine struct {
+	wm      *WalletManager
+	mu      sync.Mutex
+	policy  *WithdrawalPolicy
+	modTime time.Time
+	hooks   []WithdrawalPolicyHook
+}
+
+func newWithdrawalPolicyEngine(wm *WalletManager) *WithdrawalPolicyEngine {
+	return &WithdrawalPolicyEngine{wm: wm}
+}
+
+//WithdrawalPolicy 提现策略引擎
+func (wm *WalletManager) WithdrawalPolicy() *WithdrawalPolicyEngine {
+	return wm.withdrawalPolicy
+}
+
+//AddHook 添加自定义提现策略，在配置文件的策略通过后按添加顺序检查
+func (e *WithdrawalPolicyEngine) AddHook(hook WithdrawalPolicyHook) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.hooks = append(e.hooks, hook)
+}
+
+func (e *WithdrawalPolicyEngine) open() (*storm.DB, error) {
+	return storm.Open(filepath.Join(e.wm.Config.dbPath, e.wm.Config.BlockchainFile))
+}
+
+//Reload 重新加载策略文件，没有配置策略文件时清除策略
+func (e *WithdrawalPolicyEngine) Reload() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.modTime = time.Time{}
+	return e.load()
+}
+
+//Policy 当前生效的策略，没有配置时返回nil
+func (e *WithdrawalPolicyEngine) Policy() (*WithdrawalPolicy, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	return e.policy, nil
+}
+
+//load 策略文件修改时间变化时重新加载，加载失败时保留原来的策略并返回错误
+func (e *WithdrawalPolicyEngine) load() error {
+
+	file := e.wm.Config.withdrawalpolicyfile
+	if len(file) == 0 {
+		e.policy = nil
+		return nil
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("load withdrawal policy: %s failed, unexpected error: %v", file, err)
+	}
+	if e.policy != nil && info.ModTime().Equal(e.modTime) {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("load withdrawal policy: %s failed, unexpected error: %v", file, err)
+	}
+	var policy WithdrawalPolicy
+	if err = json.Unmarshal(data, &policy); err != nil {
+		return fmt.Errorf("load withdrawal policy: %s failed, unexpected error: %v", file, err)
+	}
+	if err = policy.parse(e.wm.Decimal()); err != nil {
+		return err
+	}
+
+	e.policy = &policy
+	e.modTime = info.ModTime()
+	e.wm.Log.Infof("withdrawal policy: %s loaded", file)
+	return nil
+}
+
+//Approve 登记提现审批，同一审批人重复审批只记录一次
+func (e *WithdrawalPolicyEngine) Approve(sid, approver string) error {
+
+	if len(sid) == 0 || len(approver) == 0 {
+		return fmt.Errorf("sid and approver can not be empty")
+	}
+
+	db, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	id := common.Bytes2Hex(crypto.SHA256([]byte(sid + "_" + approver)))
+	var exist WithdrawalApproval
+	if findErr := db.One("ID", id, &exist); findErr == nil {
+		return nil
+	}
+	e.wm.Log.Infof("withdrawal: %s approved by: %s", sid, approver)
+	return db.Save(&WithdrawalApproval{ID: id, Sid: sid, Approver: approver, Time: time.Now().Unix()})
+}
+
+//Approvals 提现的审批记录
+func (e *WithdrawalPolicyEngine) Approvals(sid string) ([]*WithdrawalApproval, error) {
+
+	db, err := e.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*WithdrawalApproval
+	err = db.Find("Sid", sid, &list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+//Decisions 策略检查记录，sid为空时列出全部
+func (e *WithdrawalPolicyEngine) Decisions(sid string) ([]*WithdrawalPolicyDecision, error) {
+
+	db, err := e.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*WithdrawalPolicyDecision
+	if len(sid) > 0 {
+		err = db.Find("Sid", sid, &list)
+	} else {
+		err = db.All(&list)
+	}
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+//DailyUsage 当天（UTC）已发送的BEAM提现总额
+func (e *WithdrawalPolicyEngine) DailyUsage() (uint64, error) {
+
+	db, err := e.open()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var usage WithdrawalPolicyUsage
+	err = db.One("Day", policyDay(), &usage)
+	if err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
+	return usage.Amount, nil
+}
+
+func policyDay() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
+
+//usageCounted 提现是否已计入每日总额，如超时后重发的同一交易ID
+func (e *WithdrawalPolicyEngine) usageCounted(key string) (bool, error) {
+
+	if len(key) == 0 {
+		return false, nil
+	}
+
+	db, err := e.open()
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var record WithdrawalPolicyUsageRecord
+	err = db.One("Key", key, &record)
+	if err == storm.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+//addUsage 调整day的提现总额并登记或删除key的计入记录，delta为负数时回退
+func (e *WithdrawalPolicyEngine) addUsage(day, key string, delta int64) error {
+
+	db, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	usage := WithdrawalPolicyUsage{Day: day}
+	if err = tx.One("Day", usage.Day, &usage); err != nil && err != storm.ErrNotFound {
+		return err
+	}
+	if delta < 0 && uint64(-delta) > usage.Amount {
+		usage.Amount = 0
+	} else {
+		usage.Amount = uint64(int64(usage.Amount) + delta)
+	}
+	if err = tx.Save(&usage); err != nil {
+		return err
+	}
+
+	if len(key) > 0 {
+		record := &WithdrawalPolicyUsageRecord{Key: key, Day: day, Amount: uint64(delta), Time: time.Now().Unix()}
+		if delta < 0 {
+			err = tx.DeleteStruct(record)
+		} else {
+			err = tx.Save(record)
+		}
+		if err != nil && err != storm.ErrNotFound {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+//CheckDestination 创建交易单、提现排队和批量提现校验时预先检查提现地址，发送时Evaluate会再次检查
+func (e *WithdrawalPolicyEngine) CheckDestination(to string, override bool) error {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.load(); err != nil {
+		return &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleFile, Reason: err.Error()}
+	}
+	if _, policyErr := e.checkDestination(e.policy, to, override); policyErr != nil {
+		return policyErr
+	}
+	return nil
+}
+
+//checkDestination 提现地址需要匹配策略文件的destinations，开启enablewithdrawwhitelist时还需要在提现地址白名单中；
+//不在白名单中、override为true且配置允许withdrawwhitelistoverride时放行，overridden返回true
+func (e *WithdrawalPolicyEngine) checkDestination(policy *WithdrawalPolicy, to string, override bool) (overridden bool, policyErr *WithdrawalPolicyError) {
+
+	if policy != nil && len(policy.Destinations) > 0 && !matchAddress(policy.Destinations, to) {
+		return false, &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleDestinations, Reason: fmt.Sprintf("address: %s is not allowed", to)}
+	}
+
+	if !e.wm.Config.enablewithdrawwhitelist {
+		return false, nil
+	}
+	ok, err := e.wm.withdrawalWhitelist.Contains(to)
+	if err != nil {
+		return false, &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleWhitelist, Reason: fmt.Sprintf("load withdrawal whitelist failed: %v", err)}
+	}
+	if ok {
+		return false, nil
+	}
+	if override && e.wm.Config.withdrawwhitelistoverride {
+		return true, nil
+	}
+	if override {
+		return false, &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleWhitelist, Reason: fmt.Sprintf("address: %s is not in the withdrawal whitelist, and override is disabled", to)}
+	}
+	return false, &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleWhitelist, Reason: fmt.Sprintf("address: %s is not in the withdrawal whitelist", to)}
+}
+
+//check 按策略检查提现请求的金额和审批，返回拒绝的规则和原因，counted为true（已计入每日总额）时不再检查每日总额
+func (e *WithdrawalPolicyEngine) check(policy *WithdrawalPolicy, req *WithdrawalPolicyRequest, counted bool) *WithdrawalPolicyError {
+
+	if req.AssetID == BeamAssetID {
+		if policy.maxPerTx > 0 && req.Amount > policy.maxPerTx {
+			return &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleMaxPerTx, Reason: fmt.Sprintf("amount: %d exceeds %d", req.Amount, policy.maxPerTx)}
+		}
+		if policy.maxPerDay > 0 && !counted {
+			used, err := e.DailyUsage()
+			if err != nil {
+				return &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleMaxPerDay, Reason: fmt.Sprintf("load daily usage failed: %v", err)}
+			}
+			if used+req.Amount > policy.maxPerDay {
+				return &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleMaxPerDay, Reason: fmt.Sprintf("daily amount: %d + %d exceeds %d", used, req.Amount, policy.maxPerDay)}
+			}
+		}
+	}
+
+	if policy.RequiredApprovals > 0 && (req.AssetID != BeamAssetID || req.Amount >= policy.approvalThreshold) {
+		if len(req.Sid) == 0 {
+			return &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleApprovals, Reason: "withdrawal requires approvals but sid is empty"}
+		}
+		approvals, err := e.Approvals(req.Sid)
+		if err != nil {
+			return &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleApprovals, Reason: fmt.Sprintf("load approvals failed: %v", err)}
+		}
+		if len(approvals) < policy.RequiredApprovals {
+			return &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleApprovals, Reason: fmt.Sprintf("%d of %d approvals", len(approvals), policy.RequiredApprovals)}
+		}
+	}
+
+	return nil
+}
+
+//Evaluate 发送提现前检查策略，通过时计入当天的提现总额，done在发送结束后调用，sent为false（确定没有发出）时回退；
+//同一交易ID（没有时为Sid）已计入时不重复计入，也不再检查每日总额，done不回退，计入的金额以第一次发送为准
+func (e *WithdrawalPolicyEngine) Evaluate(req *WithdrawalPolicyRequest) (done func(sent bool), err error) {
+
+	//检查和计入当天总额需要串行，避免并发提现超过每日限制
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	done = func(sent bool) {}
+
+	loadErr := e.load()
+	limited := loadErr == nil && e.policy != nil && e.policy.maxPerDay > 0 && req.AssetID == BeamAssetID
+	key := req.usageKey()
+	counted := false
+	if limited {
+		if counted, err = e.usageCounted(key); err != nil {
+			return done, err
+		}
+	}
+
+	var policyErr *WithdrawalPolicyError
+	switch {
+	case loadErr != nil:
+		//策略文件无法加载时拒绝提现，不能在没有策略的情况下放行
+		policyErr = &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleFile, Reason: loadErr.Error()}
+	default:
+		var overridden bool
+		overridden, policyErr = e.checkDestination(e.policy, req.To, req.Override)
+		if overridden {
+			e.wm.Log.Warningf("address: %s is not in the withdrawal whitelist, sent with override", req.To)
+		}
+		if policyErr == nil && e.policy != nil {
+			policyErr = e.check(e.policy, req, counted)
+		}
+	}
+	if policyErr == nil {
+		for _, hook := range e.hooks {
+			if hookErr := hook(req); hookErr != nil {
+				policyErr = &WithdrawalPolicyError{Rule: WithdrawalPolicyRuleHook, Reason: hookErr.Error()}
+				break
+			}
+		}
+	}
+
+	e.record(req, policyErr)
+	if policyErr != nil {
+		return done, policyErr
+	}
+
+	if limited && !counted {
+		//回退到计入的日期，发送跨过UTC零点时不影响新一天的总额
+		day := policyDay()
+		if err = e.addUsage(day, key, int64(req.Amount)); err != nil {
+			return done, err
+		}
+		done = func(sent bool) {
+			if sent {
+				return
+			}
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if rollbackErr := e.addUsage(day, key, -int64(req.Amount)); rollbackErr != nil {
+				e.wm.Log.Errorf("rollback withdrawal policy daily usage failed, unexpected error: %v", rollbackErr)
+			}
+		}
+	}
+	return done, nil
+}
+
+//txNotSent tx_send的错误能确定交易没有发出：钱包API返回了错误、请求被拒绝或连接没有建立；
+//超时、连接中断和网关错误时钱包可能已接受交易，不能回退当天的提现总额
+func txNotSent(err error) bool {
+	err = sendErrorCause(err)
+	switch errorClass(err) {
+	case "rpc", "http_4xx", "circuit_open":
+		return true
+	}
+	return isDialError(err)
+}
+
+//record 记录策略检查结果
+func (e *WithdrawalPolicyEngine) record(req *WithdrawalPolicyRequest, policyErr *WithdrawalPolicyError) {
+
+	decision := &WithdrawalPolicyDecision{
+		Sid:     req.Sid,
+		From:    req.From,
+		To:      req.To,
+		AssetID: req.AssetID,
+		Amount:  req.Amount,
+		Allowed: policyErr == nil,
+		Time:    time.Now().Unix(),
+	}
+	if policyErr != nil {
+		decision.Rule = policyErr.Rule
+		decision.Reason = policyErr.Reason
+		e.wm.Log.Warningf("withdrawal: %s to: %s amount: %d rejected by policy %s: %s", req.Sid, req.To, req.Amount, policyErr.Rule, policyErr.Reason)
+	} else if e.policy != nil || len(e.hooks) > 0 {
+		e.wm.Log.Infof("withdrawal: %s to: %s amount: %d allowed by policy", req.Sid, req.To, req.Amount)
+	} else {
+		//没有配置策略时不记录
+		return
+	}
+
+	db, err := e.open()
+	if err != nil {
+		e.wm.Log.Errorf("save withdrawal policy decision failed, unexpected error: %v", err)
+		return
+	}
+	defer db.Close()
+	if err = db.Save(decision); err != nil {
+		e.wm.Log.Errorf("save withdrawal policy decision failed, unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package beam
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithdrawalPolicyEngine_Evaluate(t *testing.T) {
+
+	var sends int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sends++
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"txId":"tx"}}`))
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	file := filepath.Join(wm.Config.dbPath, "policy.json")
+	writePolicy := func(policy string, modTime time.Time) {
+		ioutil.WriteFile(file, []byte(policy), 0644)
+		os.Chtimes(file, modTime, modTime)
+	}
+	writePolicy(`{"maxPerTx":"1","maxPerDay":"1.5","destinations":["hot*"],"approvalThreshold":"0.5","requiredApprovals":2}`, time.Now().Add(-time.Hour))
+	wm.Config.withdrawalpolicyfile = file
+	if err := wm.WithdrawalPolicy().Reload(); err != nil {
+		t.Fatalf("Reload unexpected error: %v", err)
+	}
+
+	send := func(sid, to string, amount uint64) error {
+		_, err := wm.sendWithdrawal("from", to, &WithdrawalAmount{Send: amount, Fee: 100}, "", "", sid, false)
+		return err
+	}
+	rule := func(err error) string {
+		if e, ok := err.(*WithdrawalPolicyError); ok {
+			return e.Rule
+		}
+		return fmt.Sprintf("%v", err)
+	}
+
+	if err := send("s1", "cold", 1000); rule(err) != WithdrawalPolicyRuleDestinations {
+		t.Errorf("destination not allowed err = %v", err)
+	}
+	if err := send("s1", "hot1", 200000000); rule(err) != WithdrawalPolicyRuleMaxPerTx {
+		t.Errorf("max per tx err = %v", err)
+	}
+	//低于审批金额不需要审批
+	if err := send("s1", "hot1", 10000000); err != nil {
+		t.Errorf("small withdrawal unexpected error: %v", err)
+	}
+	if err := send("s2", "hot1", 100000000); rule(err) != WithdrawalPolicyRuleApprovals {
+		t.Errorf("approvals err = %v", err)
+	}
+	wm.WithdrawalPolicy().Approve("s2", "alice")
+	wm.WithdrawalPolicy().Approve("s2", "alice")
+	if err := send("s2", "hot1", 100000000); rule(err) != WithdrawalPolicyRuleApprovals {
+		t.Errorf("approvals from the same approver err = %v", err)
+	}
+	wm.WithdrawalPolicy().Approve("s2", "bob")
+	if err := send("s2", "hot1", 100000000); err != nil {
+		t.Errorf("approved withdrawal unexpected error: %v", err)
+	}
+	if used, _ := wm.WithdrawalPolicy().DailyUsage(); used != 110000000 {
+		t.Errorf("DailyUsage = %d, want 110000000", used)
+	}
+	if err := send("s3", "hot1", 45000000); rule(err) != WithdrawalPolicyRuleMaxPerDay {
+		t.Errorf("max per day err = %v", err)
+	}
+	if sends != 2 {
+		t.Errorf("tx_send calls = %d, want 2", sends)
+	}
+
+	//策略文件修改后自动重新加载
+	writePolicy(`{"maxPerDay":"10"}`, time.Now())
+	if err := send("s3", "cold", 45000000); err != nil {
+		t.Errorf("reloaded policy unexpected error: %v", err)
+	}
+
+	//自定义策略
+	wm.WithdrawalPolicy().AddHook(func(req *WithdrawalPolicyRequest) error {
+		if req.To == "blocked" {
+			return fmt.Errorf("blocked by risk control")
+		}
+		return nil
+	})
+	if err := send("s4", "blocked", 1000); rule(err) != WithdrawalPolicyRuleHook {
+		t.Errorf("hook err = %v", err)
+	}
+
+	decisions, err := wm.WithdrawalPolicy().Decisions("")
+	if err != nil || len(decisions) != 9 {
+		t.Errorf("Decisions = %d, err = %v", len(decisions), err)
+	}
+	if decisions, _ = wm.WithdrawalPolicy().Decisions("s2"); len(decisions) != 3 || !decisions[2].Allowed || decisions[0].Rule != WithdrawalPolicyRuleApprovals {
+		t.Errorf("s2 decisions = %+v", decisions)
+	}
+
+	//策略文件无法加载时拒绝提现
+	os.Remove(file)
+	if err := send("s5", "hot1", 1000); rule(err) != WithdrawalPolicyRuleFile {
+		t.Errorf("missing policy file err = %v", err)
+	}
+}
+
+func TestWithdrawalPolicyEngine_RollbackUsage(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"Not enough funds"}}`))
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+
+	file := filepath.Join(wm.Config.dbPath, "policy.json")
+	ioutil.WriteFile(file, []byte(`{"maxPerDay":"0.00001"}`), 0644)
+	wm.Config.withdrawalpolicyfile = file
+
+	if _, err := wm.sendWithdrawal("from", "to", &WithdrawalAmount{Send: 1000, Fee: 100}, "", "", "s1", false); err == nil {
+		t.Errorf("sendWithdrawal should fail")
+	}
+	//发送失败时回退当天的提现总额
+	if used, err := wm.WithdrawalPolicy().DailyUsage(); err != nil || used != 0 {
+		t.Errorf("DailyUsage = %d, err = %v", used, err)
+	}
+
+	//网关超时时钱包可能已接受交易，不回退
+	timeout := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	defer timeout.Close()
+	wm.walletClient = NewWalletClient(timeout.URL, timeout.URL, false)
+
+	if _, err := wm.sendWithdrawal("from", "to", &WithdrawalAmount{Send: 1000, Fee: 100}, "", "t2", "s2", false); err == nil {
+		t.Errorf("sendWithdrawal should fail")
+	}
+	if used, err := wm.WithdrawalPolicy().DailyUsage(); err != nil || used != 1000 {
+		t.Errorf("DailyUsage after timeout = %d, err = %v, want 1000", used, err)
+	}
+
+	//重发同一交易ID不重复计入，也不会超过每日限制
+	for i := 0; i < 2; i++ {
+		_, err := wm.sendWithdrawal("from", "to", &WithdrawalAmount{Send: 1000, Fee: 100}, "", "t2", "s2", false)
+		if _, rejected := err.(*WithdrawalPolicyError); err == nil || rejected {
+			t.Errorf("resend tx t2 err = %v, want send error", err)
+		}
+	}
+	if used, err := wm.WithdrawalPolicy().DailyUsage(); err != nil || used != 1000 {
+		t.Errorf("DailyUsage after resend = %d, err = %v, want 1000", used, err)
+	}
+
+	//其他交易仍受每日限制
+	_, err := wm.sendWithdrawal("from", "to", &WithdrawalAmount{Send: 1000, Fee: 100}, "", "t3", "s3", false)
+	if policyErr, ok := err.(*WithdrawalPolicyError); !ok || policyErr.Rule != WithdrawalPolicyRuleMaxPerDay {
+		t.Errorf("send tx t3 err = %v, want max_per_day", err)
+	}
+}
@@ -0,0 +1,499 @@
+package beam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/timer"
+)
+
+const (
+	//提现请求的状态
+	WithdrawalStateQueued    = "queued"    //排队等待发送
+	WithdrawalStateSending   = "sending"   //发送中，重启后按交易ID确认是否已发出
+	WithdrawalStateSent      = "sent"      //已发出，等待交易完成
+	WithdrawalStateCompleted = "completed" //交易完成
+	WithdrawalStateFailed    = "failed"    //发送或交易失败，可以重试
+	WithdrawalStateCanceled  = "canceled"  //已取消
+)
+
+var (
+	//ErrWithdrawalKeyConflict 相同的幂等键已用于参数不同的提现请求
+	ErrWithdrawalKeyConflict = errors.New("idempotency key is already used by another withdrawal")
+	//ErrWithdrawalNotFound 提现请求不存在
+	ErrWithdrawalNotFound = errors.New("withdrawal not found")
+)
+
+//WithdrawalRequest 提现队列中的提现请求，以调用方提供的幂等键作为ID
+type WithdrawalRequest struct {
+	Key        string `storm:"id"`
+	From       string //发送地址，为空时使用withdrawfrom配置或钱包的第一个地址
+	To         string
+	Amount     uint64
+	Fee        uint64 //为0时按最低手续费规则估算
+	Comment    string
+	State      string `storm:"index"`
+	TxID       string //发送前预先生成，重启后使用相同的交易ID不会重复发送
+	Send       uint64 //接收方到账金额
+	Fees       uint64 //实际手续费
+	Error      string
+	Attempts   int
+	CreateTime int64 `storm:"index"`
+	UpdateTime int64
+}
+
+//sameParams 重复提交的请求参数是否一致
+func (r *WithdrawalRequest) sameParams(from, to string, amount, fee uint64, comment string) bool {
+	return r.From == from && r.To == to && r.Amount == amount && r.Fee == fee && r.Comment == comment
+}
+
+//WithdrawalQueue 提现队列，按提交顺序逐个发送，状态变化记录在本地数据库
+type WithdrawalQueue struct {
+	wm *WalletManager
+	//mu 保护状态变化，processMu 保证同一时间只有一个发送流程
+	mu        sync.Mutex
+	processMu sync.Mutex
+	task      *timer.TaskTimer
+}
+
+func newWithdrawalQueue(wm *WalletManager) *WithdrawalQueue {
+	return &WithdrawalQueue{wm: wm}
+}
+
+func (q *WithdrawalQueue) open() (*storm.DB, error) {
+	return storm.Open(filepath.Join(q.wm.Config.dbPath, q.wm.Config.BlockchainFile))
+}
+
+//load 读取提现请求
+func (q *WithdrawalQueue) load(key string) (*WithdrawalRequest, error) {
+	db, err := q.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var r WithdrawalRequest
+	err = db.One("Key", key, &r)
+	if err == storm.ErrNotFound {
+		return nil, ErrWithdrawalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+//save 保存提现请求，并按状态更新余额预留
+func (q *WithdrawalQueue) save(r *WithdrawalRequest) error {
+	db, err := q.open()
+	if err != nil {
+		return err
+	}
+
+	r.UpdateTime = time.Now().Unix()
+	err = db.Save(r)
+	db.Close()
+	if err != nil {
+		return err
+	}
+
+	q.syncReservation(r)
+	return nil
+}
+
+//syncReservation 未广播的提现请求预留余额，已发出、完成或取消后释放
+func (q *WithdrawalQueue) syncReservation(r *WithdrawalRequest) {
+
+	var err error
+	switch r.State {
+	case WithdrawalStateQueued, WithdrawalStateSending, WithdrawalStateFailed:
+		err = q.wm.reservations.Reserve(r.Key, ReservationSourceWithdrawalQueue, r.Amount+q.reservedFee(r))
+	default:
+		err = q.wm.reservations.Release(r.Key)
+	}
+	if err != nil {
+		q.wm.Log.Warningf("update balance reservation of withdrawal: %s failed, unexpected error: %v", r.Key, err)
+	}
+}
+
+//reservedFee 预留的手续费，没有指定手续费时按最低手续费规则估算，估算失败时不预留手续费
+func (q *WithdrawalQueue) reservedFee(r *WithdrawalRequest) uint64 {
+	if r.Fee > 0 {
+		return r.Fee
+	}
+	fee, err := q.wm.EstimateFee(r.Amount, []string{r.To})
+	if err != nil {
+		return 0
+	}
+	return fee
+}
+
+//transition 提现请求为from中的状态时，执行update并保存
+func (q *WithdrawalQueue) transition(key string, update func(r *WithdrawalRequest), from ...string) (*WithdrawalRequest, error) {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	r, err := q.load(key)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := false
+	for _, state := range from {
+		if r.State == state {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("withdrawal: %s is %s", key, r.State)
+	}
+
+	update(r)
+	err = q.save(r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+//Enqueue 提交提现请求，相同幂等键且参数一致时返回已有的请求，参数不一致时返回ErrWithdrawalKeyConflict
+func (q *WithdrawalQueue) Enqueue(key, to string, amount, fee uint64, comment string) (*WithdrawalRequest, error) {
+	return q.EnqueueFrom(key, "", to, amount, fee, comment)
+}
+
+//EnqueueFrom 提交指定发送地址的提现请求，from为空时发送时使用withdrawfrom配置或钱包的第一个地址
+func (q *WithdrawalQueue) EnqueueFrom(key, from, to string, amount, fee uint64, comment string) (*WithdrawalRequest, error) {
+
+	if len(key) == 0 {
+		return nil, fmt.Errorf("idempotency key is empty")
+	}
+
+	if amount == 0 {
+		return nil, fmt.Errorf("withdrawal amount is zero")
+	}
+
+	if err := checkTxComment(comment); err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	r, err := q.load(key)
+	if err == nil {
+		if !r.sameParams(from, to, amount, fee, comment) {
+			return nil, ErrWithdrawalKeyConflict
+		}
+		return r, nil
+	}
+	if err != ErrWithdrawalNotFound {
+		return nil, err
+	}
+
+	err = q.wm.checkWithdrawalAddress(to)
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.wm.withdrawalPolicy.CheckDestination(to, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(from) > 0 {
+		if _, err = q.wm.SenderAddress(from); err != nil {
+			return nil, err
+		}
+	}
+
+	r = &WithdrawalRequest{
+		Key:        key,
+		From:       from,
+		To:         to,
+		Amount:     amount,
+		Fee:        fee,
+		Comment:    comment,
+		State:      WithdrawalStateQueued,
+		CreateTime: time.Now().Unix(),
+	}
+	err = q.save(r)
+	if err != nil {
+		return nil, err
+	}
+
+	q.wm.Log.Infof("withdrawal: %s queued, to: %s, amount: %d", key, to, amount)
+	return r, nil
+}
+
+//Get 查询提现请求
+func (q *WithdrawalQueue) Get(key string) (*WithdrawalRequest, error) {
+	return q.load(key)
+}
+
+//List 按提交顺序列出提现请求，state为空时列出全部
+func (q *WithdrawalQueue) List(state string) ([]*WithdrawalRequest, error) {
+	db, err := q.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*WithdrawalRequest
+	if len(state) == 0 {
+		err = db.All(&list)
+	} else {
+		err = db.Find("State", state, &list)
+	}
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreateTime < list[j].CreateTime
+	})
+	return list, nil
+}
+
+//Retry 重新发送失败的提现请求，使用新的交易ID
+func (q *WithdrawalQueue) Retry(key string) (*WithdrawalRequest, error) {
+	return q.transition(key, func(r *WithdrawalRequest) {
+		r.State = WithdrawalStateQueued
+		r.TxID = ""
+		r.Error = ""
+	}, WithdrawalStateFailed)
+}
+
+//Cancel 取消提现请求，已发出的交易需要钱包能取消，发送中的请求不能取消
+func (q *WithdrawalQueue) Cancel(key string) (*WithdrawalRequest, error) {
+
+	r, err := q.load(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.State == WithdrawalStateSent {
+		err = q.wm.CancelTransaction(r.TxID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return q.transition(key, func(r *WithdrawalRequest) {
+		r.State = WithdrawalStateCanceled
+	}, WithdrawalStateQueued, WithdrawalStateFailed, WithdrawalStateSent)
+}
+
+//Process 依次发送排队的提现请求，并更新已发出交易的状态，
+//钱包API暂时不可用时停止本轮发送，保持提交顺序
+func (q *WithdrawalQueue) Process() {
+
+	q.processMu.Lock()
+	defer q.processMu.Unlock()
+
+	//先处理上次中断在发送中的请求
+	for _, state := range []string{WithdrawalStateSending, WithdrawalStateQueued} {
+		list, err := q.List(state)
+		if err != nil {
+			q.wm.Log.Errorf("list %s withdrawals unexpected error: %v", state, err)
+			return
+		}
+		for _, r := range list {
+			if !q.execute(r.Key) {
+				return
+			}
+		}
+	}
+
+	list, err := q.List(WithdrawalStateSent)
+	if err != nil {
+		q.wm.Log.Errorf("list sent withdrawals unexpected error: %v", err)
+		return
+	}
+	for _, r := range list {
+		q.refresh(r)
+	}
+}
+
+//execute 发送一个提现请求，钱包API暂时不可用时返回false
+func (q *WithdrawalQueue) execute(key string) bool {
+
+	r, err := q.transition(key, func(r *WithdrawalRequest) {
+		r.State = WithdrawalStateSending
+		r.Attempts++
+	}, WithdrawalStateQueued, WithdrawalStateSending)
+	if err != nil {
+		//处理期间已被取消
+		return true
+	}
+
+	txid, withdrawal, err := q.send(r)
+	if err != nil {
+		if isResendableError(err) {
+			q.wm.Log.Std.Warn("withdrawal: %s send failed, retry later, unexpected error: %v", key, err)
+			q.transition(key, func(r *WithdrawalRequest) {
+				r.State = WithdrawalStateQueued
+				r.Error = err.Error()
+			}, WithdrawalStateSending)
+			return false
+		}
+
+		q.wm.Log.Errorf("withdrawal: %s send failed, unexpected error: %v", key, err)
+		q.transition(key, func(r *WithdrawalRequest) {
+			r.State = WithdrawalStateFailed
+			r.Error = err.Error()
+		}, WithdrawalStateSending)
+		return true
+	}
+
+	_, err = q.transition(key, func(r *WithdrawalRequest) {
+		r.State = WithdrawalStateSent
+		r.TxID = txid
+		r.Error = ""
+		if withdrawal != nil {
+			r.Send = withdrawal.Send
+			r.Fees = withdrawal.Fee
+		}
+	}, WithdrawalStateSending)
+	if err != nil {
+		q.wm.Log.Errorf("withdrawal: %s save sent state unexpected error: %v", key, err)
+		return true
+	}
+
+	q.wm.Log.Infof("withdrawal: %s sent, txid: %s", key, txid)
+	return true
+}
+
+//send 发送提现交易，交易ID在发送前保存，已发出的交易不会重复发送
+func (q *WithdrawalQueue) send(r *WithdrawalRequest) (string, *WithdrawalAmount, error) {
+
+	wm := q.wm
+
+	if len(r.TxID) > 0 {
+		//上次发送中断，钱包已有该交易时直接记为已发出
+		if tx, err := wm.walletClient.GetTransaction(context.Background(), r.TxID); err == nil && tx != nil {
+			return tx.TxID, &WithdrawalAmount{Send: tx.Value, Fee: tx.Fee}, nil
+		}
+	} else {
+		txID, err := wm.walletClient.GenerateTxID(context.Background())
+		if err != nil {
+			return "", nil, err
+		}
+		_, err = q.transition(r.Key, func(r *WithdrawalRequest) {
+			r.TxID = txID
+		}, WithdrawalStateSending)
+		if err != nil {
+			return "", nil, err
+		}
+		r.TxID = txID
+	}
+
+	fee := r.Fee
+	if fee == 0 {
+		estimated, err := wm.EstimateFee(r.Amount, []string{r.To})
+		if err != nil {
+			return "", nil, err
+		}
+		fee = estimated
+	}
+
+	withdrawal, err := wm.CalcWithdrawal(r.Amount, fee, wm.Config.withdrawmode)
+	if err != nil {
+		return "", nil, err
+	}
+
+	walletStatus, err := wm.walletClient.GetWalletStatus(context.Background())
+	if err != nil {
+		return "", nil, err
+	}
+	//其他未广播的提现请求预留的余额不能使用
+	if wm.unreservedBalance(walletStatus.Available, r.Key) < withdrawal.Total() {
+		return "", nil, fmt.Errorf("wallet available balance is not enough")
+	}
+
+	from, err := wm.SenderAddress(r.From)
+	if err != nil {
+		return "", nil, err
+	}
+
+	txid, err := wm.sendWithdrawal(from, r.To, withdrawal, r.Comment, r.TxID, r.Key, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return txid, withdrawal, nil
+}
+
+//refresh 按钱包交易状态更新已发出的提现请求
+func (q *WithdrawalQueue) refresh(r *WithdrawalRequest) {
+
+	tx, err := q.wm.walletClient.GetTransaction(context.Background(), r.TxID)
+	if err != nil {
+		q.wm.Log.Std.Warn("get tx: %s of withdrawal: %s failed, unexpected error: %v", r.TxID, r.Key, err)
+		return
+	}
+
+	var update func(r *WithdrawalRequest)
+	switch tx.Status {
+	case TxStatusCompleted:
+		update = func(r *WithdrawalRequest) {
+			r.State = WithdrawalStateCompleted
+		}
+	case TxStatusFailed:
+		update = func(r *WithdrawalRequest) {
+			r.State = WithdrawalStateFailed
+			r.Error = fmt.Sprintf("tx: %s is %s", tx.TxID, tx.StatusString)
+		}
+	case TxStatusCanceled:
+		update = func(r *WithdrawalRequest) {
+			r.State = WithdrawalStateCanceled
+		}
+	default:
+		return
+	}
+
+	r, err = q.transition(r.Key, update, WithdrawalStateSent)
+	if err != nil {
+		return
+	}
+	q.wm.Log.Infof("withdrawal: %s is %s", r.Key, r.State)
+}
+
+//Start 启动提现队列的定时发送
+func (q *WithdrawalQueue) Start(period time.Duration) {
+	if q.task != nil {
+		return
+	}
+	q.task = timer.NewTask(period, q.Process)
+	q.task.Start()
+}
+
+//Stop 停止定时发送
+func (q *WithdrawalQueue) Stop() {
+	if q.task != nil {
+		q.task.Stop()
+		q.task = nil
+	}
+}
+
+//WithdrawalQueue 提现队列
+func (wm *WalletManager) WithdrawalQueue() *WithdrawalQueue {
+	return wm.withdrawalQueue
+}
+
+//StartWithdrawalQueue 启动提现队列，未开启功能时只接收请求不发送
+func (wm *WalletManager) StartWithdrawalQueue() {
+
+	if !wm.IsFeatureEnabled(FeatureWithdrawalQueue) {
+		return
+	}
+
+	wm.Log.Infof("The timer for withdrawal queue start now. Execute by every %v seconds.", wm.Config.withdrawalsendperiod.Seconds())
+	wm.withdrawalQueue.Start(wm.Config.withdrawalsendperiod)
+}
@@ -0,0 +1,118 @@
+package beam
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+//newWithdrawalQueueTestServer 模拟钱包API，status为已发出交易的状态，failSend为true时tx_send返回错误
+func newWithdrawalQueueTestServer(status *int, failSend *bool, sends *int) *httptest.Server {
+	var (
+		ids  int
+		sent = make(map[string]bool)
+	)
+	return newRPCTestServer(rpcTestMethods{
+		"addr_list":        rpcResult(`[{"address":"from","own":true,"comment":"self"}]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false}`),
+		"wallet_status":    rpcResult(`{"available":100000000}`),
+		"generate_tx_id": func(map[string]interface{}) (string, error) {
+			ids++
+			return fmt.Sprintf(`"tx%d"`, ids), nil
+		},
+		"tx_send": func(params map[string]interface{}) (string, error) {
+			if *failSend {
+				return "", &rpcTestError{Code: -32001, Message: "send failed"}
+			}
+			*sends++
+			txID := params["txId"].(string)
+			sent[txID] = true
+			return fmt.Sprintf(`{"txId":"%s"}`, txID), nil
+		},
+		"tx_status": func(params map[string]interface{}) (string, error) {
+			txID := params["txId"].(string)
+			if !sent[txID] {
+				return "", &rpcTestError{Code: -32003, Message: "tx not found"}
+			}
+			return fmt.Sprintf(`{"txId":"%s","value":1000,"fee":100,"status":%d}`, txID, *status), nil
+		},
+	})
+}
+
+func TestWithdrawalQueue(t *testing.T) {
+
+	var (
+		status   = TxStatusInProgress
+		failSend bool
+		sends    int
+	)
+	server := newWithdrawalQueueTestServer(&status, &failSend, &sends)
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	q := wm.WithdrawalQueue()
+
+	//相同的幂等键重复提交返回已有的请求，参数不同时冲突
+	for i := 0; i < 2; i++ {
+		r, err := q.Enqueue("order-1", "to", 1000, 100, "")
+		if err != nil || r.State != WithdrawalStateQueued {
+			t.Errorf("Enqueue = %+v, %v", r, err)
+			return
+		}
+	}
+	if _, err := q.Enqueue("order-1", "to", 2000, 100, ""); err != ErrWithdrawalKeyConflict {
+		t.Errorf("Enqueue with other params = %v, want ErrWithdrawalKeyConflict", err)
+	}
+	q.Enqueue("order-2", "to", 1000, 100, "")
+
+	q.Process()
+	q.Process()
+	if sends != 2 {
+		t.Errorf("tx_send calls = %d, want 2", sends)
+	}
+	list, _ := q.List(WithdrawalStateSent)
+	if len(list) != 2 || list[0].Key != "order-1" || list[0].TxID != "tx1" || list[1].TxID != "tx2" {
+		t.Errorf("sent withdrawals = %+v", list)
+	}
+
+	status = TxStatusCompleted
+	q.Process()
+	r, _ := q.Get("order-1")
+	if r.State != WithdrawalStateCompleted || r.Send != 1000 || r.Fees != 100 {
+		t.Errorf("order-1 = %+v, want completed", r)
+	}
+
+	//发送失败后可以重试或取消
+	failSend = true
+	q.Enqueue("order-3", "to", 1000, 100, "")
+	q.Process()
+	r, _ = q.Get("order-3")
+	if r.State != WithdrawalStateFailed || r.Attempts != 1 {
+		t.Errorf("order-3 = %+v, want failed", r)
+	}
+	if _, err := q.Cancel("order-1"); err == nil {
+		t.Errorf("completed withdrawal should not be canceled")
+	}
+
+	failSend = false
+	if r, err := q.Retry("order-3"); err != nil || r.State != WithdrawalStateQueued || len(r.TxID) > 0 {
+		t.Errorf("Retry = %+v, %v", r, err)
+	}
+	q.Process()
+	r, _ = q.Get("order-3")
+	if r.State != WithdrawalStateCompleted || r.Attempts != 2 {
+		t.Errorf("order-3 after retry = %+v, want completed", r)
+	}
+
+	q.Enqueue("order-4", "to", 1000, 100, "")
+	if r, err := q.Cancel("order-4"); err != nil || r.State != WithdrawalStateCanceled {
+		t.Errorf("Cancel = %+v, %v", r, err)
+	}
+	q.Process()
+	if sends != 3 {
+		t.Errorf("tx_send calls = %d, want 3", sends)
+	}
+}
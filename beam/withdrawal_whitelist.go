@@ -0,0 +1,141 @@
+package beam
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+//WhitelistEntry 提现地址白名单中的地址，通过whitelist add登记
+type WhitelistEntry struct {
+	Address    string `storm:"id"`
+	Label      string //地址说明，如交易所冷钱包
+	AddedBy    string //登记人
+	CreateTime int64
+}
+
+//WithdrawalWhitelist 提现地址白名单，由配置的地址模式（withdrawwhitelist）和本地数据库中登记的地址组成，
+//enablewithdrawwhitelist开启后，由提现策略引擎在发送提现前检查，提现到不在白名单中的地址被拒绝
+type WithdrawalWhitelist struct {
+	wm *WalletManager
+}
+
+func newWithdrawalWhitelist(wm *WalletManager) *WithdrawalWhitelist {
+	return &WithdrawalWhitelist{wm: wm}
+}
+
+//WithdrawalWhitelist 提现地址白名单
+func (wm *WalletManager) WithdrawalWhitelist() *WithdrawalWhitelist {
+	return wm.withdrawalWhitelist
+}
+
+func (w *WithdrawalWhitelist) open() (*storm.DB, error) {
+	return storm.Open(filepath.Join(w.wm.Config.dbPath, w.wm.Config.BlockchainFile))
+}
+
+//Add 登记白名单地址，地址需要格式正确且不是钱包自己的地址，已登记的地址更新说明
+func (w *WithdrawalWhitelist) Add(address, label, addedBy string) error {
+
+	address = strings.TrimSpace(address)
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+	if err := w.wm.checkWithdrawalAddress(address); err != nil {
+		return err
+	}
+
+	db, err := w.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	entry := &WhitelistEntry{Address: address, Label: label, AddedBy: addedBy, CreateTime: time.Now().Unix()}
+	var old WhitelistEntry
+	if err = db.One("Address", address, &old); err == nil {
+		entry.CreateTime = old.CreateTime
+	}
+	if err = db.Save(entry); err != nil {
+		return err
+	}
+
+	w.wm.Log.Infof("add address: %s to the withdrawal whitelist by: %s", address, addedBy)
+	return nil
+}
+
+//Remove 移除白名单地址
+func (w *WithdrawalWhitelist) Remove(address string) error {
+
+	db, err := w.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteStruct(&WhitelistEntry{Address: address})
+	if err == storm.ErrNotFound {
+		return fmt.Errorf("address: %s is not in the withdrawal whitelist", address)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.wm.Log.Infof("remove address: %s from the withdrawal whitelist", address)
+	return nil
+}
+
+//List 列出登记的白名单地址，按登记时间排序，不包含配置的地址模式
+func (w *WithdrawalWhitelist) List() ([]*WhitelistEntry, error) {
+
+	db, err := w.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var list []*WhitelistEntry
+	err = db.All(&list)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreateTime < list[j].CreateTime
+	})
+	return list, nil
+}
+
+//Contains 地址是否匹配配置的地址模式或已登记
+func (w *WithdrawalWhitelist) Contains(address string) (bool, error) {
+
+	if matchAddress(w.wm.Config.withdrawwhitelist, address) {
+		return true, nil
+	}
+
+	db, err := w.open()
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	var entry WhitelistEntry
+	err = db.One("Address", address, &entry)
+	if err == storm.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+//rawTxWhitelistOverride 交易单扩展参数中指定跳过提现地址白名单：{"whitelist_override": true}
+func rawTxWhitelistOverride(rawTx *openwallet.RawTransaction) bool {
+	if len(rawTx.ExtParam) == 0 {
+		return false
+	}
+	return gjson.Get(rawTx.ExtParam, "whitelist_override").Bool()
+}
+
@@ -0,0 +1,93 @@
+package beam
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/blocktree/openwallet/openwallet"
+)
+
+func TestWithdrawalWhitelist(t *testing.T) {
+
+	var sends int
+	server := newRPCTestServer(rpcTestMethods{
+		"addr_list":        rpcResult(`[{"address":"from","own":true,"comment":"self"}]`),
+		"validate_address": rpcResult(`{"is_valid":true,"is_mine":false}`),
+		"wallet_status":    rpcResult(`{"available":100000000}`),
+		"generate_tx_id":   rpcResult(`"pregenerated"`),
+		"tx_send": func(map[string]interface{}) (string, error) {
+			sends++
+			return `{"txId":"pregenerated"}`, nil
+		},
+	})
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.dbPath = t.TempDir()
+	wm.Config.fixfees = "0.000001"
+	wm.Config.enablewithdrawwhitelist = true
+	wm.Config.withdrawwhitelist = []string{"cold*"}
+	wm.walletClient = NewWalletClient(server.URL, server.URL, false)
+	decoder := NewTransactionDecoder(wm)
+
+	submit := func(to, extParam string) error {
+		rawTx := &openwallet.RawTransaction{To: map[string]string{to: "0.000001"}, ExtParam: extParam}
+		_, err := decoder.SubmitRawTransaction(nil, rawTx)
+		return err
+	}
+
+	if err, ok := submit("exchange", "").(*WithdrawalPolicyError); !ok || err.Rule != WithdrawalPolicyRuleWhitelist {
+		t.Errorf("withdrawal to address not in the whitelist should be rejected by the whitelist rule, got: %v", err)
+	}
+	if err := submit("exchange", `{"whitelist_override":true}`); err == nil {
+		t.Errorf("whitelist override should fail when withdrawwhitelistoverride is disabled")
+	}
+	if err := submit("cold1", ""); err != nil {
+		t.Errorf("withdrawal to configured pattern unexpected error: %v", err)
+	}
+
+	if err := wm.WithdrawalWhitelist().Add("exchange", "exchange deposit", "alice"); err != nil {
+		t.Fatalf("Add unexpected error: %v", err)
+	}
+	if err := submit("exchange", ""); err != nil {
+		t.Errorf("withdrawal to added address unexpected error: %v", err)
+	}
+	list, _ := wm.WithdrawalWhitelist().List()
+	if len(list) != 1 || list[0].Label != "exchange deposit" || list[0].AddedBy != "alice" {
+		t.Errorf("List = %+v", list)
+	}
+
+	if err := wm.WithdrawalWhitelist().Remove("exchange"); err != nil {
+		t.Errorf("Remove unexpected error: %v", err)
+	}
+	if err := wm.WithdrawalWhitelist().Remove("exchange"); err == nil {
+		t.Errorf("Remove address not in the whitelist should fail")
+	}
+	if ok, _ := wm.WithdrawalWhitelist().Contains("exchange"); ok {
+		t.Errorf("removed address is still in the whitelist")
+	}
+
+	wm.Config.withdrawwhitelistoverride = true
+	if err := submit("exchange", `{"whitelist_override":true}`); err != nil {
+		t.Errorf("whitelist override unexpected error: %v", err)
+	}
+	if sends != 3 {
+		t.Errorf("tx_send calls = %d, want 3", sends)
+	}
+
+	//策略文件的destinations与白名单都需要通过，override不能跳过destinations
+	file := filepath.Join(wm.Config.dbPath, "policy.json")
+	ioutil.WriteFile(file, []byte(`{"destinations":["cold*"]}`), 0644)
+	wm.Config.withdrawalpolicyfile = file
+	if err, ok := wm.WithdrawalPolicy().CheckDestination("exchange", true).(*WithdrawalPolicyError); !ok || err.Rule != WithdrawalPolicyRuleDestinations {
+		t.Errorf("CheckDestination should be rejected by the destinations rule, got: %v", err)
+	}
+	wm.Config.withdrawalpolicyfile = ""
+
+	//关闭白名单时不检查
+	wm.Config.enablewithdrawwhitelist = false
+	if err := wm.WithdrawalPolicy().CheckDestination("other", false); err != nil {
+		t.Errorf("disabled whitelist unexpected error: %v", err)
+	}
+}
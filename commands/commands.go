@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"github.com/Assetsadapter/beam-adapter/beam"
 	"github.com/astaxie/beego/config"
@@ -9,6 +11,17 @@ import (
 	"github.com/blocktree/openwallet/owtp"
 	"github.com/mr-tron/base58"
 	"gopkg.in/urfave/cli.v1"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	//优雅关闭的最长等待时间
+	shutdownTimeout = 30 * time.Second
 )
 
 var (
@@ -31,9 +44,675 @@ var (
 			Action:    randomGenerateClientInfo,
 			Category:  "BEAM-SERVER COMMANDS",
 		},
+		{
+			//导入其他集成的数据
+			Name:     "import",
+			Usage:    "import data from other BEAM integrations",
+			Category: "BEAM-SERVER COMMANDS",
+			Subcommands: []cli.Command{
+				{
+					//导入历史充值提现记录
+					Name:      "legacy",
+					Usage:     "import deposit/withdrawal history (csv or json) exported from other BEAM integrations",
+					ArgsUsage: "",
+					Action:    importLegacy,
+					Flags: []cli.Flag{
+						FileFlag,
+					},
+				},
+			},
+		},
+		{
+			//提现
+			Name:     "withdraw",
+			Usage:    "send withdrawals from the wallet",
+			Category: "BEAM-SERVER COMMANDS",
+			Subcommands: []cli.Command{
+				{
+					//按CSV批量提现
+					Name:      "batch",
+					Usage:     "send withdrawals listed in a csv file (address,amount,memo), rerun the same file to resume from the failed row",
+					ArgsUsage: "",
+					Action:    batchWithdraw,
+					Flags: []cli.Flag{
+						FileFlag,
+						DryRunFlag,
+					},
+				},
+				{
+					//查询批量提现批次
+					Name:      "report",
+					Usage:     "show the per-recipient status of a batch withdrawal, list all batches when id is empty",
+					ArgsUsage: "",
+					Action:    batchReport,
+					Flags: []cli.Flag{
+						BatchIDFlag,
+					},
+				},
+				{
+					//发送全部余额
+					Name:      "all",
+					Usage:     "send all spendable balance minus fee to an address in one transaction",
+					ArgsUsage: "",
+					Action:    sendAll,
+					Flags: []cli.Flag{
+						ToFlag,
+						DryRunFlag,
+					},
+				},
+				{
+					//审批提现
+					Name:      "approve",
+					Usage:     "approve a withdrawal by sid for the withdrawal policy, list approvals when approver is empty",
+					ArgsUsage: "",
+					Action:    approveWithdrawal,
+					Flags: []cli.Flag{
+						SidFlag,
+						ApproverFlag,
+					},
+				},
+			},
+		},
+		{
+			//提现地址白名单
+			Name:     "whitelist",
+			Usage:    "manage the withdrawal whitelist, effective when enablewithdrawwhitelist is true",
+			Category: "BEAM-SERVER COMMANDS",
+			Subcommands: []cli.Command{
+				{
+					//登记白名单地址
+					Name:      "add",
+					Usage:     "add an address to the withdrawal whitelist",
+					ArgsUsage: "",
+					Action:    addWhitelist,
+					Flags: []cli.Flag{
+						AddressFlag,
+						LabelFlag,
+						OperatorFlag,
+					},
+				},
+				{
+					//移除白名单地址
+					Name:      "remove",
+					Usage:     "remove an address from the withdrawal whitelist",
+					ArgsUsage: "",
+					Action:    removeWhitelist,
+					Flags: []cli.Flag{
+						AddressFlag,
+					},
+				},
+				{
+					//列出白名单地址
+					Name:      "list",
+					Usage:     "list the addresses added to the withdrawal whitelist, the withdrawwhitelist patterns are not listed",
+					ArgsUsage: "",
+					Action:    listWhitelist,
+				},
+			},
+		},
+		{
+			//地址簿
+			Name:     "address",
+			Usage:    "manage the address book of addresses created through the wallet api",
+			Category: "BEAM-SERVER COMMANDS",
+			Subcommands: []cli.Command{
+				{
+					//列出或搜索地址
+					Name:      "list",
+					Usage:     "list addresses in the address book, search by address, label or comment when query is set",
+					ArgsUsage: "",
+					Action:    listAddressBook,
+					Flags: []cli.Flag{
+						QueryFlag,
+					},
+				},
+				{
+					//设置地址标签
+					Name:      "label",
+					Usage:     "set the label of an address, such as the user who owns the deposit address",
+					ArgsUsage: "",
+					Action:    labelAddress,
+					Flags: []cli.Flag{
+						AddressFlag,
+						LabelFlag,
+					},
+				},
+				{
+					//设置地址用途
+					Name:      "purpose",
+					Usage:     "set the purpose of an address: deposit addresses can not be used as the withdrawal sender, send, or empty to unclassify",
+					ArgsUsage: "",
+					Action:    purposeAddress,
+					Flags: []cli.Flag{
+						AddressFlag,
+						PurposeFlag,
+					},
+				},
+				{
+					//归档充值地址
+					Name:      "archive",
+					Usage:     "archive a deposit address: it is no longer allocated by the address pool and its balance is not summarized, deposits still arrive with an alert",
+					ArgsUsage: "",
+					Action:    archiveAddress,
+					Flags: []cli.Flag{
+						AddressFlag,
+					},
+				},
+				{
+					//取消归档
+					Name:      "unarchive",
+					Usage:     "unarchive a deposit address",
+					ArgsUsage: "",
+					Action:    unarchiveAddress,
+					Flags: []cli.Flag{
+						AddressFlag,
+					},
+				},
+				{
+					//收款URI
+					Name:      "uri",
+					Usage:     "build the payment uri of a deposit address with optional amount and comment, and write the qr code png when file is set",
+					ArgsUsage: "",
+					Action:    paymentURI,
+					Flags: []cli.Flag{
+						AddressFlag,
+						AmountFlag,
+						CommentFlag,
+						FileFlag,
+					},
+				},
+				{
+					//同步钱包地址
+					Name:      "sync",
+					Usage:     "add the wallet's own addresses that are not in the address book yet, and map their wallet ids",
+					ArgsUsage: "",
+					Action:    syncAddressBook,
+				},
+				{
+					//地址交易记录
+					Name:      "history",
+					Usage:     "list the extracted transactions of an address from the local ledger, newest first",
+					ArgsUsage: "",
+					Action:    addressHistory,
+					Flags: []cli.Flag{
+						AddressFlag,
+						LimitFlag,
+					},
+				},
+				{
+					//地址使用统计
+					Name:      "stats",
+					Usage:     "show the usage statistics of an address: first seen, last deposit, tx count and lifetime volume",
+					ArgsUsage: "",
+					Action:    addressStats,
+					Flags: []cli.Flag{
+						AddressFlag,
+						RebuildFlag,
+					},
+				},
+				{
+					//休眠地址
+					Name:      "dormant",
+					Usage:     "list deposit addresses without transactions for the idle time",
+					ArgsUsage: "",
+					Action:    dormantAddresses,
+					Flags: []cli.Flag{
+						IdleFlag,
+					},
+				},
+				{
+					//入账最多的地址
+					Name:      "top",
+					Usage:     "list addresses with the largest lifetime deposit volume of an asset",
+					ArgsUsage: "",
+					Action:    topDepositors,
+					Flags: []cli.Flag{
+						AssetFlag,
+						LimitFlag,
+					},
+				},
+				{
+					//地址在指定高度的余额
+					Name:      "balance",
+					Usage:     "compute the balance of an address at a block height from the local ledger",
+					ArgsUsage: "",
+					Action:    addressBalanceAtHeight,
+					Flags: []cli.Flag{
+						AddressFlag,
+						HeightFlag,
+					},
+				},
+				{
+					//导出地址
+					Name:      "export",
+					Usage:     "export the addresses in the address book with labels and expire time (csv or json)",
+					ArgsUsage: "",
+					Action:    exportAddresses,
+					Flags: []cli.Flag{
+						FileFlag,
+					},
+				},
+				{
+					//导入地址
+					Name:      "import",
+					Usage:     "import addresses exported from another adapter instance (csv or json) into the address book",
+					ArgsUsage: "",
+					Action:    importAddresses,
+					Flags: []cli.Flag{
+						FileFlag,
+						VerifyFlag,
+					},
+				},
+				{
+					//恢复账户地址
+					Name:      "restore",
+					Usage:     "restore the local account address records from the comments of the wallet's own addresses",
+					ArgsUsage: "",
+					Action:    restoreAccountAddresses,
+				},
+				{
+					//创建地址所有权挑战
+					Name:      "challenge",
+					Usage:     "create an ownership challenge for an external address, the user pays the challenge amount from that address",
+					ArgsUsage: "",
+					Action:    createOwnershipChallenge,
+					Flags: []cli.Flag{
+						AddressFlag,
+					},
+				},
+				{
+					//校验地址所有权
+					Name:      "prove",
+					Usage:     "verify the payment proof of an ownership challenge",
+					ArgsUsage: "",
+					Action:    verifyOwnershipProof,
+					Flags: []cli.Flag{
+						ChallengeFlag,
+						ProofFlag,
+					},
+				},
+				{
+					//登记地址映射
+					Name:      "map",
+					Usage:     "map another form of an address, such as the sbbs wallet id, to the canonical deposit address",
+					ArgsUsage: "",
+					Action:    mapAddress,
+					Flags: []cli.Flag{
+						AddressFlag,
+						CanonicalFlag,
+					},
+				},
+				{
+					//删除地址映射
+					Name:      "unmap",
+					Usage:     "remove the mapping of an address",
+					ArgsUsage: "",
+					Action:    unmapAddress,
+					Flags: []cli.Flag{
+						AddressFlag,
+					},
+				},
+			},
+		},
+		{
+			//钱包备份
+			Name:     "wallet",
+			Usage:    "backup and restore the wallet data",
+			Category: "BEAM-SERVER COMMANDS",
+			Subcommands: []cli.Command{
+				{
+					//导出加密备份
+					Name:      "backup",
+					Usage:     "export wallet.db and the adapter database as an encrypted backup, use walletdatabackupdir when path is empty",
+					ArgsUsage: "",
+					Action:    backupWallet,
+					Flags: []cli.Flag{
+						PathFlag,
+						PasswordFlag,
+					},
+				},
+				{
+					//解密备份
+					Name:      "restore",
+					Usage:     "decrypt a wallet backup and extract the files to a directory",
+					ArgsUsage: "",
+					Action:    restoreWallet,
+					Flags: []cli.Flag{
+						FileFlag,
+						PathFlag,
+						PasswordFlag,
+					},
+				},
+				{
+					//恢复引导
+					Name:      "bootstrap",
+					Usage:     "restore a fresh wallet from the seed phrase, wait for the wallet api to sync and prime the block scanner to the current height",
+					ArgsUsage: "",
+					Action:    bootstrapWallet,
+					Flags: []cli.Flag{
+						SeedFlag,
+						WalletPassFlag,
+					},
+				},
+				{
+					//修改钱包密码
+					Name:      "passwd",
+					Usage:     "change the password of wallet.db by beam-wallet while the wallet api is stopped",
+					ArgsUsage: "",
+					Action:    changeWalletPassword,
+					Flags: []cli.Flag{
+						WalletPassFlag,
+						NewPassFlag,
+					},
+				},
+				{
+					//对账
+					Name:      "reconcile",
+					Usage:     "compare the wallet balance with the local ledger and list the txs likely responsible for the difference",
+					ArgsUsage: "",
+					Action:    reconcileWallet,
+				},
+			},
+		},
+		{
+			//报表
+			Name:     "report",
+			Usage:    "generate deposit and withdrawal reports from the local ledger",
+			Category: "BEAM-SERVER COMMANDS",
+			Subcommands: []cli.Command{
+				{
+					//日报
+					Name:      "daily",
+					Usage:     "summarize deposits and withdrawals per day and account, write to a csv or json file or print when file is empty",
+					ArgsUsage: "",
+					Action:    dailyReport,
+					Flags: []cli.Flag{
+						StartFlag,
+						EndFlag,
+						FileFlag,
+					},
+				},
+			},
+		},
+		{
+			//汇总
+			Name:     "summary",
+			Usage:    "run the summary task manually or check its schedule",
+			Category: "BEAM-SERVER COMMANDS",
+			Subcommands: []cli.Command{
+				{
+					//手动汇总
+					Name:      "run",
+					Usage:     "sweep the wallet balance to summaryaddress now, after the same health checks as the scheduled summary",
+					ArgsUsage: "",
+					Action:    runSummary,
+				},
+				{
+					//账户汇总策略
+					Name:  "policy",
+					Usage: "manage the summary threshold and address of openwallet accounts",
+					Subcommands: []cli.Command{
+						{
+							Name:      "set",
+							Usage:     "set the summary policy of an account, overrides summaryaccounts in the config file",
+							ArgsUsage: "",
+							Action:    setSummaryPolicy,
+							Flags: []cli.Flag{
+								AccountFlag,
+								ThresholdFlag,
+								ToFlag,
+							},
+						},
+						{
+							Name:      "remove",
+							Usage:     "remove the summary policy of an account set by policy set",
+							ArgsUsage: "",
+							Action:    removeSummaryPolicy,
+							Flags: []cli.Flag{
+								AccountFlag,
+							},
+						},
+						{
+							Name:      "list",
+							Usage:     "list the summary policies of accounts",
+							ArgsUsage: "",
+							Action:    listSummaryPolicies,
+						},
+					},
+				},
+				{
+					//汇总计划
+					Name:      "next",
+					Usage:     "print the next run times of summarycron",
+					ArgsUsage: "",
+					Action:    nextSummary,
+					Flags: []cli.Flag{
+						LimitFlag,
+					},
+				},
+				{
+					//汇总报告
+					Name:      "reports",
+					Usage:     "list the latest summary run reports",
+					ArgsUsage: "",
+					Action:    listSummaryReports,
+					Flags: []cli.Flag{
+						LimitFlag,
+					},
+				},
+			},
+		},
+		{
+			//配置加密
+			Name:     "secret",
+			Usage:    "manage encrypted values in the config file",
+			Category: "BEAM-SERVER COMMANDS",
+			Subcommands: []cli.Command{
+				{
+					//加密配置值
+					Name:      "encrypt",
+					Usage:     "encrypt a password or token with the key of secretkeyfile or BEAM_SECRET_KEY, print the enc: value for the config file",
+					ArgsUsage: "",
+					Action:    encryptSecret,
+					Flags: []cli.Flag{
+						ValueFlag,
+					},
+				},
+			},
+		},
 	}
 )
 
+func runSummary(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	txid, err := wm.TriggerSummary()
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("txid: %s\n", txid)
+	return nil
+}
+
+func setSummaryPolicy(c *cli.Context) error {
+	accountID := c.String("account")
+	if len(accountID) == 0 {
+		return fmt.Errorf("account id is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	err := wm.SetSummaryPolicy(accountID, c.String("threshold"), c.String("to"))
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	return nil
+}
+
+func removeSummaryPolicy(c *cli.Context) error {
+	accountID := c.String("account")
+	if len(accountID) == 0 {
+		return fmt.Errorf("account id is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	err := wm.RemoveSummaryPolicy(accountID)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	return nil
+}
+
+func listSummaryPolicies(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	list, err := wm.ListSummaryPolicies()
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	for _, policy := range list {
+		fmt.Printf("account: %s, threshold: %s, address: %s, update time: %d\n", policy.AccountID, policy.Threshold, policy.Address, policy.UpdateTime)
+	}
+	return nil
+}
+
+func nextSummary(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	schedule, err := wm.SummarySchedule()
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	if schedule == nil {
+		return fmt.Errorf("summarycron is not setup")
+	}
+
+	next := time.Now()
+	for i := 0; i < c.Int("limit"); i++ {
+		next = schedule.Next(next)
+		if next.IsZero() {
+			break
+		}
+		fmt.Println(next.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+func listSummaryReports(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	reports, err := wm.ListSummaryReports(c.Int("limit"))
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	for _, report := range reports {
+		fmt.Printf("id: %d, time: %s, trigger: %s, status: %s, swept: %d, fees: %d, txids: %s, failures: %d, notified: %v\n",
+			report.ID, time.Unix(report.StartTime, 0).Format("2006-01-02 15:04:05"), report.Trigger, report.Status,
+			report.Swept, report.Fees, strings.Join(report.TxIDs, ","), len(report.Failures), report.Notified)
+		for _, failure := range report.Failures {
+			fmt.Printf("    failed address: %s, error: %s\n", failure.Address, failure.Error)
+		}
+		if len(report.Error) > 0 {
+			fmt.Printf("    error: %s\n", report.Error)
+		}
+	}
+	return nil
+}
+
+func reconcileWallet(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	report, err := wm.Reconcile()
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("height: %d, wallet balance: %d, ledger balance: %d, difference: %d, explained: %d, unexplained: %d\n",
+		report.Height, report.WalletBalance, report.LedgerBalance, report.Difference, report.Explained, report.Unexplained)
+	for _, item := range report.Items {
+		fmt.Printf("txid: %s, kind: %s, height: %d, wallet: %d, ledger: %d, difference: %d, %s\n",
+			item.TxID, item.Kind, item.BlockHeight, item.WalletAmount, item.LedgerAmount, item.Difference(), item.Detail)
+	}
+	return nil
+}
+
+func dailyReport(c *cli.Context) error {
+	start := time.Now().UTC().AddDate(0, 0, -1)
+	if len(c.String("start")) > 0 {
+		t, err := time.Parse(beam.DailyReportDateFormat, c.String("start"))
+		if err != nil {
+			return fmt.Errorf("invalid start date: %s", c.String("start"))
+		}
+		start = t
+	}
+	end := start
+	if len(c.String("end")) > 0 {
+		t, err := time.Parse(beam.DailyReportDateFormat, c.String("end"))
+		if err != nil {
+			return fmt.Errorf("invalid end date: %s", c.String("end"))
+		}
+		end = t
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	if filePath := c.String("file"); len(filePath) > 0 {
+		exported, err := wm.ExportDailyReport(filePath, start, end)
+		if err != nil {
+			log.Error("unexpected error: ", err)
+			return err
+		}
+		fmt.Printf("exported: %d\n", exported)
+		return nil
+	}
+
+	rows, err := wm.DailyReport(start, end)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	for _, row := range rows {
+		fmt.Printf("date: %s, account: %s, asset: %d, deposits: %d, deposit amount: %s, withdrawals: %d, withdrawal amount: %s, fees: %s\n",
+			row.Date, row.AccountID, row.AssetID, row.Deposits, row.DepositAmount, row.Withdrawals, row.WithdrawalAmount, row.Fees)
+	}
+	return nil
+}
+
 func getWalleManager(c *cli.Context) *beam.WalletManager {
 	var (
 		err error
@@ -54,7 +733,7 @@ func getWalleManager(c *cli.Context) *beam.WalletManager {
 //walletserver 钱包服务
 func walletserver(c *cli.Context) error {
 	var (
-		endRunning = make(chan bool, 1)
+		endRunning = make(chan os.Signal, 1)
 	)
 	if wm := getWalleManager(c); wm != nil {
 		log.Error("doing something")
@@ -65,9 +744,801 @@ func walletserver(c *cli.Context) error {
 		//	return err
 		//}
 		//
+		signal.Notify(endRunning, syscall.SIGINT, syscall.SIGTERM)
 		<-endRunning
+
+		//等待正在处理的工作完成后退出
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return wm.Shutdown(ctx)
+	}
+
+	return nil
+}
+
+//importLegacy 导入其他BEAM集成的历史充值提现记录
+func importLegacy(c *cli.Context) error {
+	filePath := c.String("file")
+	if len(filePath) == 0 {
+		return fmt.Errorf("legacy file path is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	result, err := wm.ImportLegacyHistory(filePath)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("imported: %d, skipped: %d\n", result.Imported, result.Skipped)
+	return nil
+}
+
+//batchWithdraw 按CSV批量提现，输出每一行的结果
+func batchWithdraw(c *cli.Context) error {
+	filePath := c.String("file")
+	if len(filePath) == 0 {
+		return fmt.Errorf("batch withdrawal file path is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	report, err := wm.BatchWithdraw(filePath, c.Bool("dryrun"))
+	if report != nil {
+		for _, row := range report.Rows {
+			fmt.Printf("%d\t%s\t%s\t%s\t%s\t%s\n", row.Line, row.Address, row.Amount, row.Status, row.TxID, row.Error)
+		}
+		fmt.Printf("batch: %s, total amount: %d, total fee: %d, sent: %d, skipped: %d\n", report.BatchID, report.TotalAmount, report.TotalFee, report.Sent, report.Skipped)
+		if report.Checkpoint > 0 {
+			fmt.Printf("checkpoint: row %d\n", report.Checkpoint)
+		}
+	}
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	return nil
+}
+
+//batchReport 输出批次每一行的状态，没有指定批次时列出全部批次
+func batchReport(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	batchID := c.String("id")
+	if len(batchID) == 0 {
+		reports, err := wm.ListBatches()
+		if err != nil {
+			log.Error("unexpected error: ", err)
+			return err
+		}
+		for _, report := range reports {
+			fmt.Printf("%s\trows: %d\ttotal amount: %d\tcheckpoint: %d\n", report.BatchID, len(report.Rows), report.TotalAmount, report.Checkpoint)
+		}
+		return nil
+	}
+
+	report, err := wm.GetBatchReport(batchID)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	for _, row := range report.Rows {
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\t%s\n", row.Line, row.Address, row.Amount, row.Status, row.TxID, row.Error)
+	}
+	fmt.Printf("total amount: %d, total fee: %d, sent: %d, skipped: %d, confirmed: %d, failed: %d\n",
+		report.TotalAmount, report.TotalFee, report.Sent, report.Skipped, report.Confirmed, report.Failed)
+	if report.Checkpoint > 0 {
+		fmt.Printf("checkpoint: row %d\n", report.Checkpoint)
+	}
+	return nil
+}
+
+//sendAll 发送全部可花费余额，dryrun时只输出最大发送金额
+func sendAll(c *cli.Context) error {
+	to := c.String("to")
+	if len(to) == 0 {
+		return fmt.Errorf("receiver address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	if c.Bool("dryrun") {
+		withdrawal, err := wm.MaxSendAmount(to)
+		if err != nil {
+			log.Error("unexpected error: ", err)
+			return err
+		}
+		fmt.Printf("amount: %d, fee: %d\n", withdrawal.Send, withdrawal.Fee)
+		return nil
+	}
+
+	txid, withdrawal, err := wm.SendAll(to, "")
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("txid: %s, amount: %d, fee: %d\n", txid, withdrawal.Send, withdrawal.Fee)
+	return nil
+}
+
+func approveWithdrawal(c *cli.Context) error {
+	sid := c.String("sid")
+	if len(sid) == 0 {
+		return fmt.Errorf("sid is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	if approver := c.String("approver"); len(approver) > 0 {
+		err := wm.WithdrawalPolicy().Approve(sid, approver)
+		if err != nil {
+			log.Error("unexpected error: ", err)
+			return err
+		}
+	}
+
+	approvals, err := wm.WithdrawalPolicy().Approvals(sid)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	for _, a := range approvals {
+		fmt.Printf("sid: %s, approver: %s, time: %d\n", a.Sid, a.Approver, a.Time)
+	}
+	return nil
+}
+
+func addWhitelist(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	err := wm.WithdrawalWhitelist().Add(address, c.String("label"), c.String("operator"))
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	return nil
+}
+
+func removeWhitelist(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	err := wm.WithdrawalWhitelist().Remove(address)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	return nil
+}
+
+func listWhitelist(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	list, err := wm.WithdrawalWhitelist().List()
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	for _, entry := range list {
+		fmt.Printf("address: %s, label: %s, added by: %s, create time: %d\n", entry.Address, entry.Label, entry.AddedBy, entry.CreateTime)
+	}
+	return nil
+}
+
+func listAddressBook(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	var (
+		list []*beam.AddressBookEntry
+		err  error
+	)
+	if query := c.String("query"); len(query) > 0 {
+		list, err = wm.AddressBook().Search(query)
+	} else {
+		list, err = wm.AddressBook().List()
+	}
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	for _, entry := range list {
+		fmt.Printf("address: %s, type: %s, purpose: %s, archived: %v, label: %s, comment: %s, create time: %d\n", entry.Address, entry.Type, entry.Purpose, entry.Archived, entry.Label, entry.Comment, entry.CreateTime)
+	}
+	return nil
+}
+
+func labelAddress(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	err := wm.AddressBook().SetLabel(address, c.String("label"))
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	return nil
+}
+
+func purposeAddress(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	err := wm.AddressBook().SetPurpose(address, c.String("purpose"))
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	return nil
+}
+
+func archiveAddress(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	err := wm.AddressBook().Archive(address)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	return nil
+}
+
+func unarchiveAddress(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	err := wm.AddressBook().Unarchive(address)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	return nil
+}
+
+func paymentURI(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	filePath := c.String("file")
+	scale := 0
+	if len(filePath) > 0 {
+		scale = beam.DefaultQRCodeScale
+	}
+
+	request, err := wm.CreatePaymentRequest(address, c.String("amount"), c.String("comment"), scale)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	if len(filePath) > 0 {
+		err = ioutil.WriteFile(filePath, request.QRCode, 0644)
+		if err != nil {
+			log.Error("unexpected error: ", err)
+			return err
+		}
+	}
+
+	fmt.Printf("uri: %s\n", request.URI)
+	return nil
+}
+
+func syncAddressBook(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	added, err := wm.AddressBook().Sync()
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	mapped, err := wm.AddressMapper().Sync()
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("added: %d, mapped: %d\n", added, mapped)
+	return nil
+}
+
+func addressHistory(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	list, err := wm.GetTxHistoryByAddress(address, 0, 0, c.Int("limit"))
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	for _, tx := range list {
+		fmt.Printf("txid: %s, height: %d, time: %d, from: %v, to: %v, amount: %s, fees: %s, direction: %s\n",
+			tx.TxID, tx.BlockHeight, tx.ConfirmTime, tx.From, tx.To, tx.Amount, tx.Fees, tx.GetExtParam().Get("direction").String())
+	}
+	return nil
+}
+
+func addressStats(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 && !c.Bool("rebuild") {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	if c.Bool("rebuild") {
+		count, err := wm.RebuildAddressStats()
+		if err != nil {
+			log.Error("unexpected error: ", err)
+			return err
+		}
+		fmt.Printf("rebuilt: %d\n", count)
+		if len(address) == 0 {
+			return nil
+		}
+	}
+
+	list, err := wm.GetAddressStats(address)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	for _, s := range list {
+		printAddressStats(s)
+	}
+	return nil
+}
+
+func dormantAddresses(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	list, err := wm.DormantAddresses(c.Duration("idle"))
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	for _, s := range list {
+		printAddressStats(s)
+	}
+	return nil
+}
+
+func topDepositors(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	list, err := wm.TopDepositors(c.Int64("asset"), c.Int("limit"))
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	for _, s := range list {
+		printAddressStats(s)
+	}
+	return nil
+}
+
+func printAddressStats(s *beam.AddressStats) {
+	fmt.Printf("address: %s, asset: %d, account: %s, first seen: %d, last deposit: %d, last withdraw: %d, tx count: %d, deposits: %d, deposit volume: %s, withdraw volume: %s\n",
+		s.Address, s.AssetID, s.AccountID, s.FirstSeen, s.LastDeposit, s.LastWithdraw, s.TxCount, s.DepositCount, s.DepositVolume, s.WithdrawVolume)
+}
+
+func addressBalanceAtHeight(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	b, err := wm.GetBalanceAtHeight(address, c.Uint64("height"))
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("address: %s, height: %d, balance: %s\n", b.Address, c.Uint64("height"), b.Balance)
+	return nil
+}
+
+func exportAddresses(c *cli.Context) error {
+	filePath := c.String("file")
+	if len(filePath) == 0 {
+		return fmt.Errorf("address file path is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	exported, err := wm.ExportAddresses(filePath)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("exported: %d\n", exported)
+	return nil
+}
+
+func importAddresses(c *cli.Context) error {
+	filePath := c.String("file")
+	if len(filePath) == 0 {
+		return fmt.Errorf("address file path is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	result, err := wm.ImportAddresses(filePath, c.Bool("verify"))
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("imported: %d, updated: %d, skipped: %d\n", result.Imported, result.Updated, result.Skipped)
+	return nil
+}
+
+func restoreAccountAddresses(c *cli.Context) error {
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	restored, err := wm.RestoreAccountAddresses()
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("restored: %d\n", restored)
+	return nil
+}
+
+func createOwnershipChallenge(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	challenge, err := wm.CreateOwnershipChallenge(address)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("challenge: %s, pay: %d groth, to: %s, expire time: %d\n", challenge.ID, challenge.Amount, challenge.Receiver, challenge.ExpireTime)
+	return nil
+}
+
+func verifyOwnershipProof(c *cli.Context) error {
+	id := c.String("challenge")
+	proof := c.String("proof")
+	if len(id) == 0 || len(proof) == 0 {
+		return fmt.Errorf("challenge or proof is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	challenge, err := wm.VerifyOwnershipProof(id, proof)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("address: %s, status: %s\n", challenge.Address, challenge.Status)
+	return nil
+}
+
+func mapAddress(c *cli.Context) error {
+	address := c.String("address")
+	canonical := c.String("canonical")
+	if len(address) == 0 || len(canonical) == 0 {
+		return fmt.Errorf("address or canonical address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	err := wm.AddressMapper().Map(address, canonical)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	return nil
+}
+
+func unmapAddress(c *cli.Context) error {
+	address := c.String("address")
+	if len(address) == 0 {
+		return fmt.Errorf("address is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	err := wm.AddressMapper().Unmap(address)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+	return nil
+}
+
+//backupWallet 导出加密的钱包备份
+func backupWallet(c *cli.Context) error {
+	password := c.String("password")
+	if len(password) == 0 {
+		return fmt.Errorf("backup password is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	backupFile, err := wm.ExportWalletBackup(c.String("path"), password)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("backup: %s\n", backupFile)
+	return nil
+}
+
+//restoreWallet 解密钱包备份并解压到目录
+func restoreWallet(c *cli.Context) error {
+	backupFile := c.String("file")
+	if len(backupFile) == 0 {
+		return fmt.Errorf("backup file path is empty")
+	}
+	dir := c.String("path")
+	if len(dir) == 0 {
+		return fmt.Errorf("restore directory is empty")
+	}
+	password := c.String("password")
+	if len(password) == 0 {
+		return fmt.Errorf("backup password is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	manifest, err := wm.RestoreWalletBackup(backupFile, password, dir)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("restored: %v, height: %d, backup time: %s\n", manifest.Files, manifest.Height,
+		time.Unix(manifest.CreateTime, 0).Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+//readSecretFlag 按来源读取助记词、密码等参数，参数为空时返回空
+func readSecretFlag(c *cli.Context, flag, name string) (string, error) {
+	if len(c.String(flag)) == 0 {
+		return "", nil
+	}
+	return beam.ReadSecret(name, c.String(flag))
+}
+
+//bootstrapWallet 按助记词恢复钱包并等待同步，设置扫描高度
+func bootstrapWallet(c *cli.Context) error {
+	seed, err := readSecretFlag(c, "seed", "seed phrase")
+	if err != nil {
+		return err
+	}
+	seed = strings.Replace(seed, ";", " ", -1)
+	walletPass, err := readSecretFlag(c, "walletpass", "wallet password")
+	if err != nil {
+		return err
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	status, err := wm.BootstrapWallet(ctx, seed, walletPass, func() {
+		fmt.Println("waiting for the wallet api to start and sync...")
+	})
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Printf("wallet synced, block scanner starts from height: %d\n", status.CurrentHeight+1)
+	return nil
+}
+
+//changeWalletPassword 修改wallet.db的密码
+func changeWalletPassword(c *cli.Context) error {
+	walletPass, err := readSecretFlag(c, "walletpass", "wallet password")
+	if err != nil {
+		return err
+	}
+	newPass, err := readSecretFlag(c, "newpass", "new wallet password")
+	if err != nil {
+		return err
+	}
+	if len(newPass) == 0 {
+		return fmt.Errorf("new wallet password is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	err = wm.ChangeWalletPassword(context.Background(), walletPass, newPass)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
+	}
+
+	fmt.Println("wallet password changed, restart the wallet api with the new password")
+	return nil
+}
+
+//encryptSecret 加密配置值
+func encryptSecret(c *cli.Context) error {
+	value := c.String("value")
+	if len(value) == 0 {
+		fmt.Fprint(os.Stderr, "Enter value: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && len(line) == 0 {
+			return err
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+	if len(value) == 0 {
+		return fmt.Errorf("value is empty")
+	}
+
+	wm := getWalleManager(c)
+	if wm == nil {
+		return fmt.Errorf("load wallet manager failed")
+	}
+
+	encrypted, err := wm.EncryptSecret(value)
+	if err != nil {
+		log.Error("unexpected error: ", err)
+		return err
 	}
 
+	fmt.Println(encrypted)
 	return nil
 }
 
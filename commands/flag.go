@@ -1,6 +1,10 @@
 package commands
 
-import "gopkg.in/urfave/cli.v1"
+import (
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+)
 
 var (
 
@@ -44,6 +48,159 @@ var (
 		Usage: "file path",
 	}
 
+	ToFlag = cli.StringFlag{
+		Name: "to",
+		Usage: "receiver address",
+	}
+
+	DryRunFlag = cli.BoolFlag{
+		Name: "dryrun",
+		Usage: "validate only, do not send",
+	}
+
+	BatchIDFlag = cli.StringFlag{
+		Name: "id",
+		Usage: "batch id",
+	}
+
+	SidFlag = cli.StringFlag{
+		Name: "sid",
+		Usage: "withdrawal sid",
+	}
+
+	ApproverFlag = cli.StringFlag{
+		Name: "approver",
+		Usage: "approver name",
+	}
+
+	AddressFlag = cli.StringFlag{
+		Name: "address, a",
+		Usage: "address",
+	}
+
+	LabelFlag = cli.StringFlag{
+		Name: "label",
+		Usage: "address label",
+	}
+
+	PurposeFlag = cli.StringFlag{
+		Name: "purpose",
+		Usage: "address purpose: deposit or send",
+	}
+
+	OperatorFlag = cli.StringFlag{
+		Name: "operator",
+		Usage: "operator name",
+	}
+
+	QueryFlag = cli.StringFlag{
+		Name: "query, q",
+		Usage: "search query",
+	}
+
+	VerifyFlag = cli.BoolFlag{
+		Name: "verify",
+		Usage: "verify that the addresses belong to the wallet",
+	}
+
+	ChallengeFlag = cli.StringFlag{
+		Name: "challenge",
+		Usage: "ownership challenge id",
+	}
+
+	ProofFlag = cli.StringFlag{
+		Name: "proof",
+		Usage: "payment proof exported by the sender wallet",
+	}
+
+	LimitFlag = cli.IntFlag{
+		Name: "limit",
+		Usage: "max number of records",
+		Value: 100,
+	}
+
+	AssetFlag = cli.Int64Flag{
+		Name: "asset",
+		Usage: "confidential asset id, 0 for BEAM",
+	}
+
+	IdleFlag = cli.DurationFlag{
+		Name: "idle",
+		Usage: "idle time without transactions, such as 720h",
+		Value: 720 * time.Hour,
+	}
+
+	RebuildFlag = cli.BoolFlag{
+		Name: "rebuild",
+		Usage: "rebuild the statistics from the local ledger",
+	}
+
+	AmountFlag = cli.StringFlag{
+		Name: "amount",
+		Usage: "amount in BEAM",
+	}
+
+	CommentFlag = cli.StringFlag{
+		Name: "comment",
+		Usage: "transaction comment",
+	}
+
+	AccountFlag = cli.StringFlag{
+		Name: "account",
+		Usage: "openwallet account id",
+	}
+
+	ThresholdFlag = cli.StringFlag{
+		Name: "threshold",
+		Usage: "min summary amount in BEAM",
+	}
+
+	HeightFlag = cli.Uint64Flag{
+		Name: "height",
+		Usage: "block height",
+	}
+
+	CanonicalFlag = cli.StringFlag{
+		Name: "canonical",
+		Usage: "canonical deposit address",
+	}
+
+	PasswordFlag = cli.StringFlag{
+		Name: "password",
+		Usage: "wallet backup password",
+		EnvVar: "BEAM_BACKUP_PASSWORD",
+	}
+
+	SeedFlag = cli.StringFlag{
+		Name: "seed",
+		Usage: "source of the seed phrase to restore: prompt, file:<path> (mode 0600) or env:<name>, words separated by spaces or semicolons",
+	}
+
+	WalletPassFlag = cli.StringFlag{
+		Name: "walletpass",
+		Usage: "source of the wallet password: prompt, file:<path> (mode 0600) or env:<name>, use walletpassword when empty",
+	}
+
+	NewPassFlag = cli.StringFlag{
+		Name: "newpass",
+		Usage: "source of the new wallet password: prompt, file:<path> (mode 0600) or env:<name>",
+	}
+
+	ValueFlag = cli.StringFlag{
+		Name: "value",
+		Usage: "value to encrypt, read from stdin when empty",
+	}
+
+	StartFlag = cli.StringFlag{
+		Name: "start",
+		Usage: "first day of the report in UTC, format 2006-01-02, yesterday when empty",
+	}
+
+	EndFlag = cli.StringFlag{
+		Name: "end",
+		Usage: "last day of the report in UTC, format 2006-01-02, same as start when empty",
+	}
+
 	ConfFlag = cli.StringFlag{
 		Name: "conf, c",
 		Usage: "config file path",
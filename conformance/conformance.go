@@ -0,0 +1,207 @@
+// Package conformance 提供交易提取的标准测试向量。
+// 集成观测者接口的交易所可以用这些向量校验自己的消费者，
+// 确认对WxID、SID、数量、手续费的理解与适配器一致。
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/blocktree/openwallet/openwallet"
+	"github.com/tidwall/gjson"
+)
+
+//Vector 测试向量，输入为钱包API返回的交易单和订阅地址，输出为适配器应发出的通知
+type Vector struct {
+	Name        string                  `json:"name"`
+	BlockHeight uint64                  `json:"blockHeight"`
+	BlockHash   string                  `json:"blockHash"`
+	Tx          json.RawMessage         `json:"tx"`      //钱包API tx_status/tx_list返回的原始交易单
+	Targets     map[string]string       `json:"targets"` //订阅地址 => 数据源标识
+	Expected    []*ExpectedNotification `json:"expected"`
+}
+
+//ExpectedNotification 期望的提取通知
+type ExpectedNotification struct {
+	SourceKey string              `json:"sourceKey"`
+	WxID      string              `json:"wxID"`
+	TxID      string              `json:"txID"`
+	Amount    string              `json:"amount"`
+	Fees      string              `json:"fees"`
+	Comment   string              `json:"comment,omitempty"` //交易备注，ExtParam的comment字段
+	Inputs    []*ExpectedRecharge `json:"inputs"`
+	Outputs   []*ExpectedRecharge `json:"outputs"`
+}
+
+//ExpectedRecharge 期望的输入输出记录
+type ExpectedRecharge struct {
+	Sid     string `json:"sid"`
+	Address string `json:"address"`
+	Amount  string `json:"amount"`
+}
+
+//LoadVectors 加载测试向量文件
+func LoadVectors(file string) ([]*Vector, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var vectors []*Vector
+	err = json.Unmarshal(data, &vectors)
+	if err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+//TxResult 原始交易单的gjson结果，可传给beam.NewTransaction
+func (v *Vector) TxResult() *gjson.Result {
+	r := gjson.ParseBytes(v.Tx)
+	return &r
+}
+
+//ScanTargetFunc 按向量的订阅地址查找数据源标识
+func (v *Vector) ScanTargetFunc() openwallet.BlockScanTargetFunc {
+	return func(target openwallet.ScanTarget) (string, bool) {
+		key, ok := v.Targets[target.Address]
+		return key, ok
+	}
+}
+
+//Verify 校验提取结果与期望的通知完全一致
+func (v *Vector) Verify(extractData map[string][]*openwallet.TxExtractData) error {
+
+	count := 0
+	for _, array := range extractData {
+		count += len(array)
+	}
+	if count != len(v.Expected) {
+		return fmt.Errorf("%s: notification count = %d, want %d", v.Name, count, len(v.Expected))
+	}
+
+	for _, expected := range v.Expected {
+		array := extractData[expected.SourceKey]
+		if len(array) == 0 {
+			return fmt.Errorf("%s: missing notification for source key: %s", v.Name, expected.SourceKey)
+		}
+		matched := false
+		for _, data := range array {
+			if data.Transaction != nil && data.Transaction.WxID == expected.WxID {
+				if err := expected.verify(data); err != nil {
+					return fmt.Errorf("%s: %v", v.Name, err)
+				}
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: missing notification wxID: %s", v.Name, expected.WxID)
+		}
+	}
+
+	return nil
+}
+
+func (e *ExpectedNotification) verify(data *openwallet.TxExtractData) error {
+
+	tx := data.Transaction
+	if tx.TxID != e.TxID {
+		return fmt.Errorf("txID = %s, want %s", tx.TxID, e.TxID)
+	}
+	if tx.Amount != e.Amount {
+		return fmt.Errorf("amount = %s, want %s", tx.Amount, e.Amount)
+	}
+	if tx.Fees != e.Fees {
+		return fmt.Errorf("fees = %s, want %s", tx.Fees, e.Fees)
+	}
+	if comment := tx.GetExtParam().Get("comment").String(); comment != e.Comment {
+		return fmt.Errorf("comment = %s, want %s", comment, e.Comment)
+	}
+
+	inputs := make([]*ExpectedRecharge, 0, len(data.TxInputs))
+	for _, input := range data.TxInputs {
+		inputs = append(inputs, &ExpectedRecharge{Sid: input.Sid, Address: input.Address, Amount: input.Amount})
+	}
+	if err := compareRecharges("inputs", inputs, e.Inputs); err != nil {
+		return err
+	}
+
+	outputs := make([]*ExpectedRecharge, 0, len(data.TxOutputs))
+	for _, output := range data.TxOutputs {
+		outputs = append(outputs, &ExpectedRecharge{Sid: output.Sid, Address: output.Address, Amount: output.Amount})
+	}
+	return compareRecharges("outputs", outputs, e.Outputs)
+}
+
+func compareRecharges(name string, got, want []*ExpectedRecharge) error {
+	if len(got) != len(want) {
+		return fmt.Errorf("%s count = %d, want %d", name, len(got), len(want))
+	}
+	for i := range got {
+		if *got[i] != *want[i] {
+			return fmt.Errorf("%s[%d] = %+v, want %+v", name, i, *got[i], *want[i])
+		}
+	}
+	return nil
+}
+
+//Recorder 记录收到的通知的观测者，可以包装交易所自己的消费者，
+//把通过观测者接口收到的数据与测试向量比较
+type Recorder struct {
+	mu          sync.Mutex
+	next        openwallet.BlockScanNotificationObject
+	extractData map[string][]*openwallet.TxExtractData
+}
+
+//NewRecorder 创建通知记录器，next可以为nil
+func NewRecorder(next openwallet.BlockScanNotificationObject) *Recorder {
+	return &Recorder{
+		next:        next,
+		extractData: make(map[string][]*openwallet.TxExtractData),
+	}
+}
+
+//BlockScanNotify 新区块扫描完成通知
+func (r *Recorder) BlockScanNotify(header *openwallet.BlockHeader) error {
+	if r.next != nil {
+		return r.next.BlockScanNotify(header)
+	}
+	return nil
+}
+
+//BlockExtractDataNotify 区块提取结果通知
+func (r *Recorder) BlockExtractDataNotify(sourceKey string, data *openwallet.TxExtractData) error {
+	r.mu.Lock()
+	r.extractData[sourceKey] = append(r.extractData[sourceKey], data)
+	r.mu.Unlock()
+	if r.next != nil {
+		return r.next.BlockExtractDataNotify(sourceKey, data)
+	}
+	return nil
+}
+
+//ExtractData 已记录的通知
+func (r *Recorder) ExtractData() map[string][]*openwallet.TxExtractData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make(map[string][]*openwallet.TxExtractData, len(r.extractData))
+	for key, array := range r.extractData {
+		result[key] = append([]*openwallet.TxExtractData(nil), array...)
+	}
+	return result
+}
+
+//SourceKeys 已记录通知的数据源标识
+func (r *Recorder) SourceKeys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]string, 0, len(r.extractData))
+	for key := range r.extractData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,37 @@
+package conformance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Assetsadapter/beam-adapter/beam"
+)
+
+func TestVectors(t *testing.T) {
+	vectors, err := LoadVectors("testdata/vectors.json")
+	if err != nil {
+		t.Errorf("LoadVectors failed unexpected error: %v", err)
+		return
+	}
+
+	wm := beam.NewWalletManager()
+	wm.SetDBPath(t.TempDir())
+	for _, v := range vectors {
+		tx := beam.NewTransaction(v.TxResult())
+		result := wm.Blockscanner.ExtractTransaction(context.Background(), v.BlockHeight, v.BlockHash, tx, v.ScanTargetFunc())
+		if err := v.Verify(result.ExtractData()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		//通过观测者接口收到的数据与向量一致
+		recorder := NewRecorder(nil)
+		for key, array := range result.ExtractData() {
+			for _, data := range array {
+				recorder.BlockExtractDataNotify(key, data)
+			}
+		}
+		if err := v.Verify(recorder.ExtractData()); err != nil {
+			t.Errorf("recorder unexpected error: %v", err)
+		}
+	}
+}
@@ -9,12 +9,16 @@ require (
 	github.com/blocktree/go-owcrypt v1.0.1
 	github.com/blocktree/openwallet v1.5.2
 	github.com/golang/protobuf v1.3.1 // indirect
+	github.com/gorilla/websocket v1.4.0
 	github.com/imroc/req v0.2.3
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/mr-tron/base58 v1.1.1
+	github.com/prometheus/client_golang v1.0.0
 	github.com/shopspring/decimal v0.0.0-20180709203117-cd690d0c9e24
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/tidwall/gjson v1.2.1
-	golang.org/x/crypto v0.0.0-20190513172903-22d7a77e9e5f // indirect
+	golang.org/x/crypto v0.0.0-20190513172903-22d7a77e9e5f
+	golang.org/x/sys v0.10.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 	gopkg.in/urfave/cli.v1 v1.20.0
 )
@@ -1,35 +1,153 @@
 package openwtester
 
 import (
-	"github.com/Assetsadapter/beam-adapter/beam"
-	"github.com/astaxie/beego/config"
+	"fmt"
+	"os"
 	"path/filepath"
+	"sync"
+	"testing"
 	"time"
+
+	"github.com/Assetsadapter/beam-adapter/beam"
+	"github.com/astaxie/beego/config"
 )
 
+const (
+	//测试环境，通过环境变量BEAM_TEST_ENV选择，默认live
+	TestEnvMock    = "mock"    //本地模拟的钱包API和浏览器API，不需要节点
+	TestEnvRegtest = "regtest" //本地私链节点，读取conf/regtest.ini
+	TestEnvLive    = "live"    //真实节点，读取conf/live.ini，不存在时读取conf/server.ini
+
+	testEnvVar          = "BEAM_TEST_ENV"           //测试环境
+	testConfDirVar      = "BEAM_TEST_CONF_DIR"      //配置文件目录，默认conf
+	testReadyTimeoutVar = "BEAM_TEST_READY_TIMEOUT" //等待节点就绪的最长时间，默认10s
+
+	defaultTestReadyTimeout = 10 * time.Second
+	testReadyPollInterval   = 200 * time.Millisecond
+)
+
+//testNode 按测试环境延迟创建的钱包管理，同一环境的测试共用，可并行执行
+type testNode struct {
+	once sync.Once
+	wm   *beam.WalletManager
+	err  error
+}
+
 var (
-	serverNode *beam.WalletManager
-	clientNode *beam.WalletManager
+	testNodesMu sync.Mutex
+	testNodes   = make(map[string]*testNode)
 )
 
-func init() {
+//testEnv 当前测试环境
+func testEnv() string {
+	env := os.Getenv(testEnvVar)
+	if len(env) == 0 {
+		return TestEnvLive
+	}
+	return env
+}
+
+//testClientNode 获取当前测试环境的钱包管理，
+//envs为测试支持的环境，不传表示支持所有环境，当前环境不支持或节点未就绪时跳过测试
+func testClientNode(t *testing.T, envs ...string) *beam.WalletManager {
+
+	env := testEnv()
+	if len(envs) > 0 && !containsEnv(envs, env) {
+		t.Skipf("test env %s is not in %v", env, envs)
+	}
+
+	testNodesMu.Lock()
+	node, ok := testNodes[env]
+	if !ok {
+		node = &testNode{}
+		testNodes[env] = node
+	}
+	testNodesMu.Unlock()
+
+	node.once.Do(func() {
+		node.wm, node.err = testNewWalletManager(env)
+		if node.err == nil {
+			node.err = waitReady(node.wm, testReadyTimeout())
+		}
+	})
+
+	if node.err != nil {
+		t.Skipf("test env %s is not ready: %v", env, node.err)
+	}
+
+	return node.wm
+}
 
-	//serverNode = testNewWalletManager("server.ini")
-	//clientNode = testNewWalletManager("client.ini")
-	clientNode = testNewWalletManager("server.ini")
-	time.Sleep(1 * time.Second)
+func containsEnv(envs []string, env string) bool {
+	for _, e := range envs {
+		if e == env {
+			return true
+		}
+	}
+	return false
 }
 
-func testNewWalletManager(conf string) *beam.WalletManager {
-	wm := beam.NewWalletManager()
+//testNewWalletManager 按测试环境加载配置
+func testNewWalletManager(env string) (*beam.WalletManager, error) {
+
+	var (
+		c   config.Configer
+		err error
+	)
 
-	//读取配置
-	absFile := filepath.Join("conf", conf)
-	//log.Debug("absFile:", absFile)
-	c, err := config.NewConfig("ini", absFile)
+	switch env {
+	case TestEnvMock:
+		c, err = newMockConfig()
+	case TestEnvRegtest, TestEnvLive:
+		c, err = config.NewConfig("ini", testConfigFile(env))
+	default:
+		return nil, fmt.Errorf("unknown test env: %s", env)
+	}
 	if err != nil {
-		return nil
+		return nil, err
+	}
+
+	wm := beam.NewWalletManager()
+	err = wm.LoadAssetsConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return wm, nil
+}
+
+//testConfigFile 测试环境的配置文件，live环境兼容原来的server.ini
+func testConfigFile(env string) string {
+	dir := os.Getenv(testConfDirVar)
+	if len(dir) == 0 {
+		dir = "conf"
+	}
+
+	file := filepath.Join(dir, env+".ini")
+	if _, err := os.Stat(file); err != nil && env == TestEnvLive {
+		return filepath.Join(dir, "server.ini")
+	}
+	return file
+}
+
+func testReadyTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv(testReadyTimeoutVar))
+	if err != nil || timeout <= 0 {
+		return defaultTestReadyTimeout
+	}
+	return timeout
+}
+
+//waitReady 等待钱包API可访问
+func waitReady(wm *beam.WalletManager, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := wm.GetLocalWalletAddress()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(testReadyPollInterval)
 	}
-	wm.LoadAssetsConfig(c)
-	return wm
 }
@@ -12,6 +12,7 @@ import (
 )
 
 func TestCreateLocalAddress(t *testing.T) {
+	clientNode := testClientNode(t)
 	addrs, err := clientNode.CreateLocalWalletAddress(1, 2)
 	if err != nil {
 		t.Errorf("CreateRemoteWalletAddress failed unexpected error: %v\n", err)
@@ -24,6 +25,7 @@ func TestCreateLocalAddress(t *testing.T) {
 }
 
 func TestCreateRemoteAddress(t *testing.T) {
+	clientNode := testClientNode(t, TestEnvRegtest, TestEnvLive)
 	addrs, err := clientNode.CreateRemoteWalletAddress(1, 10)
 	if err != nil {
 		t.Errorf("CreateRemoteWalletAddress failed unexpected error: %v\n", err)
@@ -37,6 +39,7 @@ func TestCreateRemoteAddress(t *testing.T) {
 }
 
 func TestGetWalletBalance(t *testing.T) {
+	clientNode := testClientNode(t, TestEnvRegtest, TestEnvLive)
 	balanceLocal, err := clientNode.GetLocalWalletBalance()
 	if err != nil {
 		t.Errorf("GetLocalWalletBalance failed unexpected error: %v\n", err)
@@ -55,6 +58,7 @@ func TestGetWalletBalance(t *testing.T) {
 }
 
 func TestGetLocalWalletAddress(t *testing.T) {
+	clientNode := testClientNode(t)
 	addrs, err := clientNode.GetLocalWalletAddress()
 	if err != nil {
 		t.Errorf("CreateRemoteWalletAddress failed unexpected error: %v\n", err)
@@ -67,6 +71,7 @@ func TestGetLocalWalletAddress(t *testing.T) {
 }
 
 func TestGetRemoteWalletAddress(t *testing.T) {
+	clientNode := testClientNode(t, TestEnvRegtest, TestEnvLive)
 	addrs, err := clientNode.GetRemoteWalletAddress()
 	if err != nil {
 		t.Errorf("CreateRemoteWalletAddress failed unexpected error: %v\n", err)
@@ -79,6 +84,7 @@ func TestGetRemoteWalletAddress(t *testing.T) {
 }
 
 func TestGetRemoteBlockByHeight(t *testing.T) {
+	clientNode := testClientNode(t, TestEnvRegtest, TestEnvLive)
 	block, err := clientNode.GetRemoteBlockByHeight(1000)
 	if err != nil {
 		t.Errorf("CreateRemoteWalletAddress failed unexpected error: %v\n", err)
@@ -91,6 +97,7 @@ func TestGetRemoteBlockByHeight(t *testing.T) {
 }
 
 func TestSendTransaction(t *testing.T) {
+	clientNode := testClientNode(t)
 
 	rawTx := &openwallet.RawTransaction{
 		Coin: openwallet.Coin{
@@ -133,6 +140,7 @@ func TestSendTransaction(t *testing.T) {
 }
 
 func TestGetBalance(t *testing.T) {
+	clientNode := testClientNode(t, TestEnvRegtest, TestEnvLive)
 	scanner := clientNode.Blockscanner
 	balance, err := scanner.GetBalanceByAddress()
 	if err != nil {
@@ -143,6 +151,7 @@ func TestGetBalance(t *testing.T) {
 }
 
 func TestStartSummaryWallet(t *testing.T) {
+	clientNode := testClientNode(t)
 
 	txid, summaryAmount, feeAmount, err := clientNode.SummaryWalletProcess("22050821304db464e209b0dba622ffe3c711123471f0b300c7cc979df48e7a9a8a4")
 	if err != nil {
@@ -160,6 +169,7 @@ func TestGetKey(t *testing.T) {
 }
 
 func TestWalletManager_BackupWalletData(t *testing.T) {
+	clientNode := testClientNode(t, TestEnvRegtest, TestEnvLive)
 	err := clientNode.BackupWalletData()
 	if err != nil {
 		t.Errorf("BackupWalletData failed unexpected error: %v\n", err)
@@ -168,6 +178,7 @@ func TestWalletManager_BackupWalletData(t *testing.T) {
 }
 
 func TestValidateAddress(t *testing.T) {
+	clientNode := testClientNode(t)
 	isVaild, err := clientNode.ValidateAddress("b4ac9b088c4ea5f8b40f91113219216a596f082e27a809449c45add0c326e7dfb8")
 	if err != nil {
 		t.Errorf("vaild address failed unexpected error: %v\n", err)
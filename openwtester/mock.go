@@ -0,0 +1,155 @@
+package openwtester
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/astaxie/beego/config"
+)
+
+const (
+	mockChainHeight   = 1055
+	mockWalletAddress = "22d090004ab6de7e62d0d3829e0164d05cc065404ebc9874d181dc070d54237bbd8"
+)
+
+var (
+	mockServerOnce sync.Once
+	mockServer     *httptest.Server
+)
+
+//newMockConfig mock环境的配置，钱包API和浏览器API指向本地模拟服务，只开启服务端模式，不监听OWTP
+func newMockConfig() (config.Configer, error) {
+
+	mockServerOnce.Do(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/wallet", mockWalletAPI)
+		mux.HandleFunc("/explorer/status", mockExplorerStatus)
+		mux.HandleFunc("/explorer/block", mockExplorerBlock)
+		mockServer = httptest.NewServer(mux)
+	})
+
+	dir, err := ioutil.TempDir("", "beam-mock")
+	if err != nil {
+		return nil, err
+	}
+
+	ini := fmt.Sprintf(`
+walletapi = "%s/api/wallet"
+explorerapi = "%s/explorer"
+enableserver = true
+fixfees = "0.000001"
+logdir = "%s"
+walletdatabackupdir = "%s"
+
+[features]
+scanner = false
+summary = false
+server = false
+`, mockServer.URL, mockServer.URL, dir, dir+string(os.PathSeparator))
+
+	return config.NewConfigData("ini", []byte(ini))
+}
+
+//mockWalletAPI 模拟钱包API的json-rpc接口
+func mockWalletAPI(w http.ResponseWriter, r *http.Request) {
+
+	var request struct {
+		ID     interface{}            `json:"id"`
+		Method string                 `json:"method"`
+		Params map[string]interface{} `json:"params"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result interface{}
+	switch request.Method {
+	case "wallet_status":
+		result = map[string]interface{}{
+			"current_height":     mockChainHeight,
+			"current_state_hash": mockBlockHash(mockChainHeight),
+			"prev_state_hash":    mockBlockHash(mockChainHeight - 1),
+			"available":          100500000,
+			"receiving":          0,
+			"sending":            0,
+			"maturing":           0,
+			"locked":             0,
+		}
+	case "create_address":
+		result = mockWalletAddress
+	case "addr_list":
+		result = []map[string]interface{}{
+			{"address": mockWalletAddress, "own": true, "expired": false, "comment": "self"},
+		}
+	case "validate_address":
+		address, _ := request.Params["address"].(string)
+		result = map[string]interface{}{"is_valid": len(address) > 0, "is_mine": address == mockWalletAddress}
+	case "generate_tx_id":
+		result = "f9f5b2e9b1e34b2c8d5f1a7c3e2d4b6a"
+	case "tx_send":
+		txID, _ := request.Params["txId"].(string)
+		if len(txID) == 0 {
+			txID = "72f8f349f9244b11b0e6471250ca68a1"
+		}
+		result = map[string]interface{}{"txId": txID}
+	case "tx_cancel":
+		result = true
+	case "tx_list":
+		result = []interface{}{}
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      request.ID,
+			"error":   map[string]interface{}{"code": -32601, "message": "Method not found"},
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      request.ID,
+		"result":  result,
+	})
+}
+
+//mockExplorerStatus 模拟浏览器API的status接口
+func mockExplorerStatus(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"chainwork":   "0x38594101d0a0",
+		"hash":        mockBlockHash(mockChainHeight),
+		"height":      mockChainHeight,
+		"low_horizon": 0,
+		"timestamp":   1550158283,
+	})
+}
+
+//mockExplorerBlock 模拟浏览器API的block接口，只有挖矿奖励的空区块
+func mockExplorerBlock(w http.ResponseWriter, r *http.Request) {
+	height, err := strconv.ParseUint(r.URL.Query().Get("height"), 10, 64)
+	if err != nil || height == 0 || height > mockChainHeight {
+		json.NewEncoder(w).Encode(map[string]interface{}{"found": false})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"found":     true,
+		"hash":      mockBlockHash(height),
+		"prev":      mockBlockHash(height - 1),
+		"height":    height,
+		"timestamp": 1550157362 + int64(height)*60,
+		"inputs":    []interface{}{},
+		"kernels":   []interface{}{map[string]interface{}{"fee": 0}},
+		"outputs":   []interface{}{map[string]interface{}{"coinbase": true}},
+	})
+}
+
+func mockBlockHash(height uint64) string {
+	return fmt.Sprintf("%064x", height)
+}
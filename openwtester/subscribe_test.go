@@ -52,6 +52,7 @@ func (sub *subscriberSingle) BlockExtractDataNotify(sourceKey string, data *open
 }
 
 func TestSubscribeAddress(t *testing.T) {
+	clientNode := testClientNode(t, TestEnvRegtest, TestEnvLive)
 
 	var (
 		endRunning = make(chan bool, 1)
@@ -107,6 +108,7 @@ func TestSubscribeAddress(t *testing.T) {
 }
 
 func TestBlockScanner_ExtractTransactionData(t *testing.T) {
+	clientNode := testClientNode(t, TestEnvRegtest, TestEnvLive)
 
 	var (
 		symbol = "PESS"